@@ -4,14 +4,17 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lumberbarons/solar-controller/internal/app"
 	"github.com/lumberbarons/solar-controller/internal/config"
+	"github.com/lumberbarons/solar-controller/internal/controllers/voltgo"
 	"github.com/lumberbarons/solar-controller/internal/publishers"
 	log "github.com/sirupsen/logrus"
 )
@@ -40,6 +43,13 @@ func init() {
 // exit. Everything it does beyond that lives in functions that return errors, so
 // startup can be exercised by tests rather than terminating the process.
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "voltgo-scan" {
+		if err := runVoltgoScan(os.Args[2:], os.Stdout, newBLEConnector); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	flag.Parse()
 
 	if err := run(*configFilePath, *debugMode); err != nil {
@@ -124,6 +134,55 @@ func configureLogging(debugEnabled bool) {
 	log.SetLevel(log.InfoLevel)
 }
 
+func newBLEConnector() (voltgo.BatteryConnector, error) {
+	return voltgo.NewBLEConnector()
+}
+
+// runVoltgoScan is the voltgo-scan subcommand: it lists nearby Voltgo
+// batteries so their addresses can be copied into the configuration.
+func runVoltgoScan(args []string, out io.Writer, connect func() (voltgo.BatteryConnector, error)) error {
+	flags := flag.NewFlagSet("voltgo-scan", flag.ContinueOnError)
+	flags.SetOutput(out)
+	window := flags.String("window", voltgo.DefaultScanWindow.String(), "How long to listen, up to "+voltgo.MaxScanWindow.String())
+	all := flags.Bool("all", false, "List every Bluetooth LE device heard, not only Voltgo batteries")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	scanWindow, err := voltgo.ParseScanWindow(*window)
+	if err != nil {
+		return err
+	}
+
+	connector, err := connect()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := connector.Close(); err != nil {
+			log.Warnf("failed to release BLE adapter: %v", err)
+		}
+	}()
+
+	fmt.Fprintf(out, "Scanning for %s...\n", scanWindow)
+	devices, err := voltgo.ScanDevices(context.Background(), connector, scanWindow, *all)
+	if err != nil {
+		return err
+	}
+
+	if len(devices) == 0 {
+		fmt.Fprintln(out, "No Voltgo batteries found. Check the battery is awake and in range, or use -all to list every device heard.")
+		return nil
+	}
+
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ADDRESS\tNAME\tRSSI")
+	for _, device := range devices {
+		fmt.Fprintf(table, "%s\t%s\t%d dBm\n", device.Address, device.Name, device.RSSI)
+	}
+	return table.Flush()
+}
+
 // loadConfig reads and parses the config file at path.
 func loadConfig(path string) (config.Config, error) {
 	if path == "" {
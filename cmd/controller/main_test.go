@@ -1,11 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/lumberbarons/solar-controller/internal/config"
+	"github.com/lumberbarons/solar-controller/internal/controllers/voltgo"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -117,3 +121,81 @@ func TestRunReturnsConfigErrorsRatherThanExiting(t *testing.T) {
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "must specify config file path")
 }
+
+// fakeScanner is a BLE adapter that hears a fixed set of devices.
+type fakeScanner struct {
+	devices []voltgo.Device
+	scanErr error
+	closed  bool
+}
+
+func (f *fakeScanner) Connect(context.Context, string) (voltgo.BatteryClient, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeScanner) Scan(ctx context.Context) ([]voltgo.Device, error) {
+	<-ctx.Done()
+	return f.devices, f.scanErr
+}
+
+func (f *fakeScanner) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestRunVoltgoScan(t *testing.T) {
+	scanner := &fakeScanner{devices: []voltgo.Device{
+		{Address: "AA:BB:CC:DD:EE:01", Name: "VOLTGO-100", RSSI: -61},
+		{Address: "11:22:33:44:55:66", Name: "Kitchen speaker", RSSI: -40},
+	}}
+	connect := func() (voltgo.BatteryConnector, error) { return scanner, nil }
+
+	t.Run("lists voltgo batteries", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runVoltgoScan([]string{"-window", "10ms"}, &out, connect))
+
+		assert.Contains(t, out.String(), "Scanning for 10ms")
+		assert.Regexp(t, `AA:BB:CC:DD:EE:01\s+VOLTGO-100\s+-61 dBm`, out.String())
+		assert.NotContains(t, out.String(), "Kitchen speaker")
+		assert.True(t, scanner.closed, "the adapter was not released")
+	})
+
+	t.Run("lists every device with -all", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runVoltgoScan([]string{"-window", "10ms", "-all"}, &out, connect))
+
+		assert.Contains(t, out.String(), "Kitchen speaker")
+	})
+
+	t.Run("says so when nothing is found", func(t *testing.T) {
+		empty := func() (voltgo.BatteryConnector, error) { return &fakeScanner{}, nil }
+
+		var out bytes.Buffer
+		require.NoError(t, runVoltgoScan([]string{"-window", "10ms"}, &out, empty))
+
+		assert.Contains(t, out.String(), "No Voltgo batteries found")
+	})
+
+	t.Run("rejects a window over the limit", func(t *testing.T) {
+		err := runVoltgoScan([]string{"-window", "5m"}, &bytes.Buffer{}, connect)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "at most")
+	})
+
+	t.Run("reports a missing adapter", func(t *testing.T) {
+		noAdapter := func() (voltgo.BatteryConnector, error) { return nil, errors.New("no adapter") }
+
+		err := runVoltgoScan([]string{"-window", "10ms"}, &bytes.Buffer{}, noAdapter)
+		assert.EqualError(t, err, "no adapter")
+	})
+
+	t.Run("reports a failed scan", func(t *testing.T) {
+		failing := func() (voltgo.BatteryConnector, error) {
+			return &fakeScanner{scanErr: errors.New("adapter powered off")}, nil
+		}
+
+		err := runVoltgoScan([]string{"-window", "10ms"}, &bytes.Buffer{}, failing)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "adapter powered off")
+	})
+}
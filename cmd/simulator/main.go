@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/simulator"
+	log "github.com/sirupsen/logrus"
+)
+
+// options are the command line settings for one simulator run.
+type options struct {
+	registersPath  string
+	scriptPath     string
+	scriptInterval time.Duration
+	tcpAddress     string
+	rtu            bool
+	slaveID        uint
+}
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableColors: true,
+		FullTimestamp: true,
+	})
+}
+
+// main handles only flag parsing and turning a failure into a non-zero
+// exit; the simulator itself runs in run so it can be tested.
+func main() {
+	var opts options
+	flag.StringVar(&opts.registersPath, "registers", "testdata/simulator/epever.json", "Register map file")
+	flag.StringVar(&opts.scriptPath, "script", "", "Optional script of value changes over time, e.g. testdata/simulator/sunrise.json")
+	flag.DurationVar(&opts.scriptInterval, "script-interval", time.Second, "How often scripted values are updated")
+	flag.StringVar(&opts.tcpAddress, "tcp", "", "Serve Modbus TCP on this address, e.g. :5020")
+	flag.BoolVar(&opts.rtu, "rtu", true, "Serve Modbus RTU on a pseudo-terminal")
+	flag.UintVar(&opts.slaveID, "slave-id", 1, "RTU slave ID")
+	debug := flag.Bool("debug", false, "Log every request and response")
+	flag.Parse()
+
+	if *debug {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, opts, func(device string) {
+		// Printed plainly so scripts can pick the path up from stdout
+		fmt.Printf("RTU device: %s\n", device)
+	}); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run serves the register map until ctx is cancelled. rtuReady is called
+// with the pty path once the RTU server is up.
+func run(ctx context.Context, opts options, rtuReady func(device string)) error {
+	if !opts.rtu && opts.tcpAddress == "" {
+		return errors.New("nothing to serve: enable -rtu or set -tcp")
+	}
+	if opts.slaveID < 1 || opts.slaveID > 247 {
+		return fmt.Errorf("slave ID %d is outside 1-247", opts.slaveID)
+	}
+
+	registers, err := simulator.LoadRegisterMap(opts.registersPath)
+	if err != nil {
+		return err
+	}
+
+	var player *simulator.Player
+	if opts.scriptPath != "" {
+		script, err := simulator.LoadScript(opts.scriptPath)
+		if err != nil {
+			return err
+		}
+		if player, err = simulator.NewPlayer(registers, script); err != nil {
+			return fmt.Errorf("invalid script %s: %w", opts.scriptPath, err)
+		}
+		if opts.scriptInterval <= 0 {
+			return errors.New("script interval must be positive")
+		}
+	}
+
+	errs := make(chan error, 2)
+
+	if opts.tcpAddress != "" {
+		server, err := simulator.NewTCPServer(registers, opts.tcpAddress)
+		if err != nil {
+			return err
+		}
+		defer server.Close()
+		go func() { errs <- server.Serve() }()
+	}
+
+	if opts.rtu {
+		server, err := simulator.NewRTUServer(registers, byte(opts.slaveID))
+		if err != nil {
+			return err
+		}
+		defer server.Close()
+		go func() { errs <- server.Serve() }()
+		if rtuReady != nil {
+			rtuReady(server.DevicePath())
+		}
+	}
+
+	if player != nil {
+		go player.Run(ctx, opts.scriptInterval)
+	}
+
+	select {
+	case <-ctx.Done():
+		log.Info("simulator shutting down")
+		return nil
+	case err := <-errs:
+		return err
+	}
+}
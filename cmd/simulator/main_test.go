@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testdataDir = filepath.Join("..", "..", "testdata", "simulator")
+
+func TestRun(t *testing.T) {
+	t.Run("serves until the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		done := make(chan error, 1)
+		go func() {
+			done <- run(ctx, options{
+				registersPath:  filepath.Join(testdataDir, "epever.json"),
+				scriptPath:     filepath.Join(testdataDir, "sunrise.json"),
+				scriptInterval: 10 * time.Millisecond,
+				tcpAddress:     "127.0.0.1:0",
+				slaveID:        1,
+			}, nil)
+		}()
+
+		cancel()
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("run did not return after cancel")
+		}
+	})
+
+	t.Run("reports the RTU device", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ready := make(chan string, 1)
+		done := make(chan error, 1)
+		go func() {
+			done <- run(ctx, options{
+				registersPath: filepath.Join(testdataDir, "epever.json"),
+				rtu:           true,
+				slaveID:       1,
+			}, func(device string) { ready <- device })
+		}()
+
+		select {
+		case device := <-ready:
+			assert.NotEmpty(t, device)
+		case err := <-done:
+			t.Skipf("pty unavailable: %v", err)
+		}
+
+		cancel()
+		assert.NoError(t, <-done)
+	})
+}
+
+func TestRun_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    options
+		wantErr string
+	}{
+		{
+			name:    "nothing to serve",
+			opts:    options{registersPath: filepath.Join(testdataDir, "epever.json"), slaveID: 1},
+			wantErr: "nothing to serve",
+		},
+		{
+			name:    "slave ID out of range",
+			opts:    options{registersPath: filepath.Join(testdataDir, "epever.json"), rtu: true, slaveID: 248},
+			wantErr: "slave ID",
+		},
+		{
+			name:    "missing register file",
+			opts:    options{registersPath: filepath.Join(t.TempDir(), "missing.json"), tcpAddress: "127.0.0.1:0", slaveID: 1},
+			wantErr: "failed to read register file",
+		},
+		{
+			name: "missing script",
+			opts: options{
+				registersPath: filepath.Join(testdataDir, "epever.json"),
+				scriptPath:    filepath.Join(t.TempDir(), "missing.json"),
+				tcpAddress:    "127.0.0.1:0",
+				slaveID:       1,
+			},
+			wantErr: "failed to read script",
+		},
+		{
+			name: "script interval not positive",
+			opts: options{
+				registersPath: filepath.Join(testdataDir, "epever.json"),
+				scriptPath:    filepath.Join(testdataDir, "sunrise.json"),
+				tcpAddress:    "127.0.0.1:0",
+				slaveID:       1,
+			},
+			wantErr: "script interval",
+		},
+		{
+			name:    "invalid listen address",
+			opts:    options{registersPath: filepath.Join(testdataDir, "epever.json"), tcpAddress: "256.0.0.1:0", slaveID: 1},
+			wantErr: "failed to listen",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := run(context.Background(), tt.opts, nil)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,186 @@
+// Package alerts watches the metrics the controllers publish for the
+// conditions its rules describe, such as a state of charge below 20% for ten
+// minutes. Each rule is pending while its condition holds, firing once it has
+// held long enough, and resolved once it has cleared by the hysteresis; the
+// changes are published as alert events.
+package alerts
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	namespace = "alerts"
+
+	// The severities of a rule
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+
+	// The states of a rule
+	StateInactive = "inactive"
+	StatePending  = "pending"
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+var (
+	namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+	// conditionPattern is namespace/metric, an operator and a number
+	conditionPattern = regexp.MustCompile(`^\s*([a-z0-9][a-z0-9-]*/[a-z0-9][a-z0-9-]*)\s*(<=|>=|==|!=|<|>)\s*(\S+)\s*$`)
+)
+
+type Configuration struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule is one alert.
+type Rule struct {
+	// Name identifies the alert, such as low-soc
+	Name string `yaml:"name"`
+
+	// Condition compares a published metric with a number, such as
+	// voltgo/battery-soc < 20
+	Condition string `yaml:"condition"`
+
+	// For is how long the condition must hold before the alert fires, as a
+	// duration string (default: at once)
+	For string `yaml:"for"`
+
+	// Hysteresis is how far past the threshold the value must go back
+	// before a firing alert resolves, in the metric's unit
+	Hysteresis float64 `yaml:"hysteresis"`
+
+	// Severity is info, warning or critical (default: warning)
+	Severity string `yaml:"severity"`
+
+	// Cooldown is the least time between two firings of the alert, as a
+	// duration string (default: none)
+	Cooldown string `yaml:"cooldown"`
+
+	// AutoResolve resolves the alert once the condition clears (default:
+	// true). Otherwise it fires until resolved through the API
+	AutoResolve *bool `yaml:"autoResolve"`
+}
+
+// GetSeverity returns the configured severity or the default (warning).
+func (r *Rule) GetSeverity() string {
+	if r.Severity == "" {
+		return SeverityWarning
+	}
+	return r.Severity
+}
+
+// GetAutoResolve returns whether the alert resolves by itself (default: true).
+func (r *Rule) GetAutoResolve() bool {
+	return r.AutoResolve == nil || *r.AutoResolve
+}
+
+// Validate checks every rule and that their names are unique.
+func (c *Configuration) Validate() error {
+	names := make(map[string]bool, len(c.Rules))
+	for i, rule := range c.Rules {
+		if !namePattern.MatchString(rule.Name) {
+			return fmt.Errorf("alert rule %d: name %q must be lowercase letters, digits and dashes", i+1, rule.Name)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("alert rule %s is defined twice", rule.Name)
+		}
+		names[rule.Name] = true
+
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("alert rule %s: %w", rule.Name, err)
+		}
+	}
+	return nil
+}
+
+func (r *Rule) validate() error {
+	condition, err := parseCondition(r.Condition)
+	if err != nil {
+		return err
+	}
+	if _, err := parseDuration("for", r.For); err != nil {
+		return err
+	}
+	if _, err := parseDuration("cooldown", r.Cooldown); err != nil {
+		return err
+	}
+
+	switch r.GetSeverity() {
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		return fmt.Errorf("severity %q must be info, warning or critical", r.Severity)
+	}
+
+	if r.Hysteresis < 0 {
+		return errors.New("hysteresis must not be negative")
+	}
+	if r.Hysteresis > 0 && (condition.operator == "==" || condition.operator == "!=") {
+		return fmt.Errorf("hysteresis needs a <, <=, > or >= condition, not %s", condition.operator)
+	}
+	return nil
+}
+
+// parseDuration parses an optional non-negative duration.
+func parseDuration(name, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%s must not be negative", name)
+	}
+	return d, nil
+}
+
+// condition is a parsed Rule.Condition.
+type condition struct {
+	metric    string
+	operator  string
+	threshold float64
+}
+
+func parseCondition(value string) (condition, error) {
+	match := conditionPattern.FindStringSubmatch(value)
+	if match == nil {
+		return condition{}, fmt.Errorf("condition %q must be a metric, an operator and a number, such as voltgo/battery-soc < 20", value)
+	}
+	threshold, err := strconv.ParseFloat(match[3], 64)
+	if err != nil {
+		return condition{}, fmt.Errorf("condition %q: threshold %q is not a number", value, match[3])
+	}
+	return condition{metric: match[1], operator: match[2], threshold: threshold}, nil
+}
+
+// holds reports whether value meets the condition with the threshold moved
+// by margin to widen it: up for < and <=, down for > and >=. A firing alert
+// clears once its condition no longer holds with the hysteresis as margin.
+func (c condition) holds(value, margin float64) bool {
+	switch c.operator {
+	case "<":
+		return value < c.threshold+margin
+	case "<=":
+		return value <= c.threshold+margin
+	case ">":
+		return value > c.threshold-margin
+	case ">=":
+		return value >= c.threshold-margin
+	case "==":
+		return value == c.threshold
+	default:
+		return value != c.threshold
+	}
+}
+
+func (c condition) String() string {
+	return fmt.Sprintf("%s %s %s", c.metric, c.operator, strconv.FormatFloat(c.threshold, 'f', -1, 64))
+}
@@ -0,0 +1,106 @@
+package alerts
+
+import (
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	rule := func(name, condition string) Rule {
+		return Rule{Name: name, Condition: condition}
+	}
+
+	tests := []struct {
+		name    string
+		config  Configuration
+		wantErr bool
+	}{
+		{"no rules", Configuration{}, false},
+		{"full rule", Configuration{Rules: []Rule{{
+			Name: "low-soc", Condition: "voltgo/battery-soc < 20", For: "10m",
+			Hysteresis: 5, Severity: SeverityCritical, Cooldown: "1h", AutoResolve: boolPtr(false),
+		}}}, false},
+		{"two rules", Configuration{Rules: []Rule{rule("low-soc", "voltgo/battery-soc<20"), rule("fault", "epever/charging-status == 3")}}, false},
+		{"name with capitals", Configuration{Rules: []Rule{rule("LowSOC", "voltgo/battery-soc < 20")}}, true},
+		{"no name", Configuration{Rules: []Rule{rule("", "voltgo/battery-soc < 20")}}, true},
+		{"name used twice", Configuration{Rules: []Rule{rule("low-soc", "voltgo/battery-soc < 20"), rule("low-soc", "voltgo/battery-soc < 10")}}, true},
+		{"no namespace", Configuration{Rules: []Rule{rule("low-soc", "battery-soc < 20")}}, true},
+		{"no operator", Configuration{Rules: []Rule{rule("low-soc", "voltgo/battery-soc 20")}}, true},
+		{"threshold not a number", Configuration{Rules: []Rule{rule("low-soc", "voltgo/battery-soc < low")}}, true},
+		{"bad for", Configuration{Rules: []Rule{{Name: "low-soc", Condition: "voltgo/battery-soc < 20", For: "10 minutes"}}}, true},
+		{"negative cooldown", Configuration{Rules: []Rule{{Name: "low-soc", Condition: "voltgo/battery-soc < 20", Cooldown: "-1m"}}}, true},
+		{"bad severity", Configuration{Rules: []Rule{{Name: "low-soc", Condition: "voltgo/battery-soc < 20", Severity: "page"}}}, true},
+		{"negative hysteresis", Configuration{Rules: []Rule{{Name: "low-soc", Condition: "voltgo/battery-soc < 20", Hysteresis: -1}}}, true},
+		{"hysteresis on ==", Configuration{Rules: []Rule{{Name: "fault", Condition: "epever/charging-status == 3", Hysteresis: 1}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRuleDefaults(t *testing.T) {
+	rule := Rule{Name: "low-soc", Condition: "voltgo/battery-soc < 20"}
+	if got := rule.GetSeverity(); got != SeverityWarning {
+		t.Errorf("GetSeverity() = %q, want %q", got, SeverityWarning)
+	}
+	if !rule.GetAutoResolve() {
+		t.Error("GetAutoResolve() = false, want true")
+	}
+
+	rule.Severity, rule.AutoResolve = SeverityInfo, boolPtr(false)
+	if got := rule.GetSeverity(); got != SeverityInfo {
+		t.Errorf("GetSeverity() = %q, want %q", got, SeverityInfo)
+	}
+	if rule.GetAutoResolve() {
+		t.Error("GetAutoResolve() = true, want false")
+	}
+}
+
+func TestConditionHolds(t *testing.T) {
+	tests := []struct {
+		condition string
+		value     float64
+		margin    float64
+		want      bool
+	}{
+		{"voltgo/battery-soc < 20", 19.9, 0, true},
+		{"voltgo/battery-soc < 20", 20, 0, false},
+		{"voltgo/battery-soc < 20", 24, 5, true},
+		{"voltgo/battery-soc < 20", 25, 5, false},
+		{"voltgo/battery-soc <= 20", 20, 0, true},
+		{"voltgo/battery-soc <= 20", 25, 5, true},
+		{"epever/battery-temp > 45", 45.5, 0, true},
+		{"epever/battery-temp > 45", 45, 0, false},
+		{"epever/battery-temp > 45", 43, 3, true},
+		{"epever/battery-temp >= 45", 45, 0, true},
+		{"epever/battery-temp >= 45", 41.9, 3, false},
+		{"epever/charging-status == 3", 3, 0, true},
+		{"epever/charging-status == 3", 1, 0, false},
+		{"epever/charging-status != 0", 1, 0, true},
+		{"epever/charging-status != 0", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		c, err := parseCondition(tt.condition)
+		if err != nil {
+			t.Fatalf("parseCondition(%q) error = %v", tt.condition, err)
+		}
+		if got := c.holds(tt.value, tt.margin); got != tt.want {
+			t.Errorf("%s holds(%v, %v) = %v, want %v", tt.condition, tt.value, tt.margin, got, tt.want)
+		}
+	}
+}
+
+func TestConditionString(t *testing.T) {
+	c, err := parseCondition("  voltgo/battery-soc<20.50 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.String(), "voltgo/battery-soc < 20.5"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
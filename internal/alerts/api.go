@@ -0,0 +1,61 @@
+package alerts
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Status is an alert as GET /api/alerts lists it.
+type Status struct {
+	Name      string `json:"name"`
+	Severity  string `json:"severity"`
+	Condition string `json:"condition"`
+
+	// State is pending or firing, or with ?all=true also inactive or
+	// resolved
+	State string `json:"state"`
+
+	// Value is the last reading of the metric and Updated its time; Since
+	// is when the alert entered its state and FiredAt when it last fired.
+	// Times are Unix seconds, and each is null before it happens
+	Value   *float64 `json:"value"`
+	Updated *int64   `json:"updated"`
+	Since   *int64   `json:"since"`
+	FiredAt *int64   `json:"firedAt"`
+}
+
+// AlertsResponse is the body of GET /api/alerts.
+type AlertsResponse struct {
+	Alerts []Status `json:"alerts"`
+}
+
+// AlertsGet lists the pending and firing alerts, or every rule with
+// ?all=true.
+func (p *Publisher) AlertsGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, AlertsResponse{Alerts: p.Statuses(c.Query("all") == "true")})
+	}
+}
+
+// ResolvePost resolves a firing alert.
+func (p *Publisher) ResolvePost() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := p.Resolve(c.Param("name"))
+		switch {
+		case errors.Is(err, ErrUnknownAlert):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrNotFiring):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusNoContent, gin.H{})
+		}
+	}
+}
+
+// RegisterEndpoints adds the alert endpoints.
+func (p *Publisher) RegisterEndpoints(r *gin.Engine) {
+	r.GET("/api/alerts", p.AlertsGet())
+	r.POST("/api/alerts/:name/resolve", p.ResolvePost())
+}
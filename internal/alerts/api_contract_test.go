@@ -0,0 +1,32 @@
+package alerts
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// AlertsGet serialises AlertsResponse directly; each alert is checked on its
+// own.
+func TestAlertsMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(AlertsResponse{Alerts: []Status{}})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/alerts"),
+		testutil.JSONFieldNames(t, payload),
+		"AlertsResponse no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+
+	status, err := json.Marshal(Status{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/alerts#alerts[]"),
+		testutil.JSONFieldNames(t, status),
+		"Status no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
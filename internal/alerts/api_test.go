@@ -0,0 +1,86 @@
+package alerts
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestRouter(t *testing.T) (*gin.Engine, *Publisher) {
+	t.Helper()
+
+	publisher, _, _ := newTestPublisher(t,
+		Rule{Name: "low-soc", Condition: "voltgo/battery-soc < 20", AutoResolve: boolPtr(false)},
+		Rule{Name: "empty", Condition: "voltgo/battery-soc < 5"},
+	)
+	publishMetric(publisher, "voltgo/battery-soc", 15, 1000)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	publisher.RegisterEndpoints(router)
+	return router, publisher
+}
+
+func TestAlertsGet(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	tests := []struct {
+		target    string
+		wantNames []string
+	}{
+		{"/api/alerts", []string{"low-soc"}},
+		{"/api/alerts?all=true", []string{"low-soc", "empty"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", recorder.Code, http.StatusOK)
+			}
+
+			var response AlertsResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if len(response.Alerts) != len(tt.wantNames) {
+				t.Fatalf("alerts = %+v, want %v", response.Alerts, tt.wantNames)
+			}
+			for i, name := range tt.wantNames {
+				if response.Alerts[i].Name != name {
+					t.Errorf("alerts[%d] = %s, want %s", i, response.Alerts[i].Name, name)
+				}
+			}
+		})
+	}
+}
+
+func TestResolvePost(t *testing.T) {
+	router, publisher := newTestRouter(t)
+
+	tests := []struct {
+		name       string
+		wantStatus int
+	}{
+		{"missing", http.StatusNotFound},
+		{"empty", http.StatusConflict},
+		{"low-soc", http.StatusNoContent},
+		{"low-soc", http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/alerts/"+tt.name+"/resolve", nil))
+		if recorder.Code != tt.wantStatus {
+			t.Errorf("resolve %s: status = %d, want %d: %s", tt.name, recorder.Code, tt.wantStatus, recorder.Body.String())
+		}
+	}
+
+	if active := publisher.Statuses(false); len(active) != 0 {
+		t.Errorf("active alerts = %+v, want none", active)
+	}
+}
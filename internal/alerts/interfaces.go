@@ -0,0 +1,8 @@
+package alerts
+
+// MetricsCollector defines the interface for collecting and exposing metrics.
+// This abstraction allows for testing without the Prometheus global registry.
+type MetricsCollector interface {
+	// SetState updates the gauges of an alert to its state.
+	SetState(name, severity, state string)
+}
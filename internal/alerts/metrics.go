@@ -0,0 +1,64 @@
+package alerts
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Event is an alert firing or resolving.
+type Event struct {
+	Rule      string
+	Severity  string
+	State     string
+	Condition string
+
+	// Value is the reading that fired or resolved the alert, and Timestamp
+	// its time
+	Value     float64
+	Timestamp int64
+
+	Message string
+}
+
+// EventPayload is the JSON structure published for each event. Value, Unit,
+// Metric and Labels make it a metric like any other, alerts_firing labelled
+// by rule and severity, 1 when firing and 0 when resolved; the rest describes
+// the event.
+type EventPayload struct {
+	Value     int               `json:"value"`
+	Unit      string            `json:"unit"`
+	Timestamp int64             `json:"timestamp"`
+	Metric    string            `json:"metric"`
+	Labels    map[string]string `json:"labels"`
+
+	State     string  `json:"state"`
+	Severity  string  `json:"severity"`
+	Condition string  `json:"condition"`
+	Reading   float64 `json:"reading"`
+	Message   string  `json:"message"`
+}
+
+// ToJSON converts an Event to its JSON representation
+func (e *Event) ToJSON() (string, error) {
+	payload := EventPayload{
+		Unit:      "boolean",
+		Timestamp: e.Timestamp,
+		Metric:    "firing",
+		Labels:    map[string]string{"rule": e.Rule, "severity": e.Severity},
+		State:     e.State,
+		Severity:  e.Severity,
+		Condition: e.Condition,
+		Reading:   e.Value,
+		Message:   e.Message,
+	}
+	if e.State == StateFiring {
+		payload.Value = 1
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal event payload: %w", err)
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,64 @@
+package alerts
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+)
+
+// MockMetricsCollector is a mock implementation of MetricsCollector for testing.
+type MockMetricsCollector struct {
+	// States holds the last state set for each rule
+	States map[string]string
+}
+
+func (m *MockMetricsCollector) SetState(name, severity, state string) {
+	if m.States == nil {
+		m.States = make(map[string]string)
+	}
+	m.States[name] = state
+}
+
+// newTestPublisher creates a publisher of the given rules.
+func newTestPublisher(t *testing.T, rules ...Rule) (*Publisher, *testutil.MockMessagePublisher, *MockMetricsCollector) {
+	t.Helper()
+
+	next := &testutil.MockMessagePublisher{}
+	metrics := &MockMetricsCollector{}
+	publisher, err := NewPublisher(next, rules, metrics, "test-device-1")
+	if err != nil {
+		t.Fatalf("NewPublisher() error = %v", err)
+	}
+	return publisher, next, metrics
+}
+
+// publishMetric publishes a reading of a metric, such as voltgo/battery-soc,
+// at the given Unix time.
+func publishMetric(p *Publisher, metric string, value float64, timestamp int64) {
+	payload, _ := json.Marshal(map[string]any{"value": value, "unit": "percent", "timestamp": timestamp})
+	p.Publish("test-device-1/"+metric, string(payload))
+}
+
+// events decodes what was published to the alert topics.
+func events(t *testing.T, next *testutil.MockMessagePublisher) []EventPayload {
+	t.Helper()
+
+	var payloads []EventPayload
+	for _, call := range next.PublishCalls {
+		if !strings.HasPrefix(call.TopicSuffix, "test-device-1/alerts/") {
+			continue
+		}
+		var payload EventPayload
+		if err := json.Unmarshal([]byte(call.Payload), &payload); err != nil {
+			t.Fatalf("alert payload %s: %v", call.Payload, err)
+		}
+		payloads = append(payloads, payload)
+	}
+	return payloads
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
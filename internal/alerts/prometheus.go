@@ -0,0 +1,44 @@
+package alerts
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type PrometheusCollector struct {
+	firing  *prometheus.GaugeVec
+	pending *prometheus.GaugeVec
+}
+
+func NewPrometheusCollector() *PrometheusCollector {
+	return &PrometheusCollector{
+		firing: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "firing",
+				Help:      "Whether the alert is firing (1) or not (0).",
+			},
+			[]string{"rule", "severity"},
+		),
+		pending: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "pending",
+				Help:      "Whether the alert's condition holds but it has not fired yet (1) or not (0).",
+			},
+			[]string{"rule", "severity"},
+		),
+	}
+}
+
+func (a *PrometheusCollector) SetState(name, severity, state string) {
+	a.firing.WithLabelValues(name, severity).Set(boolToFloat(state == StateFiring))
+	a.pending.WithLabelValues(name, severity).Set(boolToFloat(state == StatePending))
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
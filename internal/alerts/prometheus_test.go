@@ -0,0 +1,37 @@
+package alerts
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Verify PrometheusCollector implements MetricsCollector
+var _ MetricsCollector = (*PrometheusCollector)(nil)
+
+// The collector registers with the global Prometheus registry via promauto,
+// so it can only be created once per test binary.
+func TestPrometheusCollector(t *testing.T) {
+	collector := NewPrometheusCollector()
+
+	tests := []struct {
+		state       string
+		wantFiring  float64
+		wantPending float64
+	}{
+		{StateInactive, 0, 0},
+		{StatePending, 0, 1},
+		{StateFiring, 1, 0},
+		{StateResolved, 0, 0},
+	}
+
+	for _, tt := range tests {
+		collector.SetState("low-soc", SeverityWarning, tt.state)
+		if got := testutil.ToFloat64(collector.firing.WithLabelValues("low-soc", SeverityWarning)); got != tt.wantFiring {
+			t.Errorf("%s: alerts_firing = %v, want %v", tt.state, got, tt.wantFiring)
+		}
+		if got := testutil.ToFloat64(collector.pending.WithLabelValues("low-soc", SeverityWarning)); got != tt.wantPending {
+			t.Errorf("%s: alerts_pending = %v, want %v", tt.state, got, tt.wantPending)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package alerts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/publish"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrUnknownAlert is returned for a rule that is not configured
+	ErrUnknownAlert = errors.New("no alert rule")
+
+	// ErrNotFiring is returned when resolving an alert that is not firing
+	ErrNotFiring = errors.New("alert is not firing")
+)
+
+// Publisher passes every message on to the publisher it wraps and evaluates
+// the rules that read it. The events of alerts that fire or resolve are
+// published through the same publisher.
+type Publisher struct {
+	next                publish.MessagePublisher
+	prometheusCollector MetricsCollector
+	deviceID            string
+	now                 func() time.Time
+
+	mu     sync.Mutex
+	alerts []*alert
+
+	// byMetric holds the alerts that read each metric, as namespace/metric
+	byMetric map[string][]*alert
+}
+
+// NewPublisher creates a publisher that evaluates rules over what passes
+// through it to next.
+func NewPublisher(next publish.MessagePublisher, rules []Rule, prometheusCollector MetricsCollector, deviceID string) (*Publisher, error) {
+	// Default device ID if not provided
+	if deviceID == "" {
+		deviceID = "controller-1"
+	}
+
+	p := &Publisher{
+		next:                next,
+		prometheusCollector: prometheusCollector,
+		deviceID:            deviceID,
+		now:                 time.Now,
+		byMetric:            make(map[string][]*alert),
+	}
+	for _, rule := range rules {
+		a, err := newAlert(rule)
+		if err != nil {
+			return nil, err
+		}
+		p.alerts = append(p.alerts, a)
+		p.byMetric[a.condition.metric] = append(p.byMetric[a.condition.metric], a)
+		p.prometheusCollector.SetState(a.Name, a.GetSeverity(), a.state)
+	}
+	return p, nil
+}
+
+// NewPublisherFromConfig wraps next with the configured rules. This is the
+// production entry point; without any rules it returns next unchanged and no
+// alerts.
+func NewPublisherFromConfig(config Configuration, next publish.MessagePublisher, deviceID string) (publish.MessagePublisher, *Publisher, error) {
+	if len(config.Rules) == 0 {
+		return next, nil, nil
+	}
+
+	log.Infof("evaluating %d alert rules", len(config.Rules))
+
+	p, err := NewPublisher(next, config.Rules, NewPrometheusCollector(), deviceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, p, nil
+}
+
+// Publish passes the message on, then evaluates the rules that read it if it
+// is a metric of this device.
+func (p *Publisher) Publish(topicSuffix, payload string) {
+	p.next.Publish(topicSuffix, payload)
+
+	name, ok := strings.CutPrefix(topicSuffix, p.deviceID+"/")
+	if !ok {
+		return
+	}
+	p.mu.Lock()
+	alerts := p.byMetric[name]
+	p.mu.Unlock()
+	if len(alerts) == 0 {
+		return
+	}
+
+	var metric struct {
+		Value     float64 `json:"value"`
+		Timestamp int64   `json:"timestamp"`
+	}
+	if err := json.Unmarshal([]byte(payload), &metric); err != nil || metric.Timestamp == 0 {
+		// Not a number, such as the voltgo cell-voltages list
+		return
+	}
+
+	var events []*Event
+	p.mu.Lock()
+	for _, a := range alerts {
+		state := a.state
+		if event := a.evaluate(metric.Value, metric.Timestamp); event != nil {
+			events = append(events, event)
+		}
+		if a.state != state {
+			p.prometheusCollector.SetState(a.Name, a.GetSeverity(), a.state)
+		}
+	}
+	p.mu.Unlock()
+
+	p.publishEvents(events)
+}
+
+// Resolve resolves a firing alert, which is how an alert without autoResolve
+// is cleared.
+func (p *Publisher) Resolve(name string) error {
+	p.mu.Lock()
+	var event *Event
+	var err error
+	switch a := p.alert(name); {
+	case a == nil:
+		err = fmt.Errorf("%w %s", ErrUnknownAlert, name)
+	case a.state != StateFiring:
+		err = fmt.Errorf("%s: %w but %s", name, ErrNotFiring, a.state)
+	default:
+		event = a.resolve(max(p.now().Unix(), a.since))
+		p.prometheusCollector.SetState(a.Name, a.GetSeverity(), a.state)
+	}
+	p.mu.Unlock()
+
+	if event != nil {
+		p.publishEvents([]*Event{event})
+	}
+	return err
+}
+
+// alert finds a rule's alert by name. Callers must hold mu.
+func (p *Publisher) alert(name string) *alert {
+	for _, a := range p.alerts {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// publishEvents publishes each event to its rule's topic.
+func (p *Publisher) publishEvents(events []*Event) {
+	for _, event := range events {
+		log.Warnf("alert %s", event.Message)
+
+		payload, err := event.ToJSON()
+		if err != nil {
+			log.Errorf("failed to marshal alert %s for publishing: %s", event.Rule, err)
+			continue
+		}
+
+		// Topic format: {deviceId}/alerts/{rule-name}
+		topicSuffix := fmt.Sprintf("%s/%s/%s", p.deviceID, namespace, event.Rule)
+		p.next.Publish(topicSuffix, payload)
+
+		log.Debugf("published alert %s to %s", event.Rule, topicSuffix)
+	}
+}
+
+// Statuses returns every alert in the order of the rules: only the pending
+// and firing ones unless all.
+func (p *Publisher) Statuses(all bool) []Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	statuses := []Status{}
+	for _, a := range p.alerts {
+		if all || a.state == StatePending || a.state == StateFiring {
+			statuses = append(statuses, a.status())
+		}
+	}
+	return statuses
+}
+
+// Close closes the wrapped publisher.
+func (p *Publisher) Close() {
+	p.next.Close()
+}
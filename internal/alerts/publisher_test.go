@@ -0,0 +1,174 @@
+package alerts
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+)
+
+func TestPublisherPassesMessagesOn(t *testing.T) {
+	publisher, next, _ := newTestPublisher(t, Rule{Name: "low-soc", Condition: "voltgo/battery-soc < 20"})
+
+	publisher.Publish("test-device-1/voltgo/cell-voltages", `{"value":[3.3,3.31],"unit":"volts","timestamp":1000}`)
+	publishMetric(publisher, "epever/battery-soc", 10, 1000)
+	publishMetric(publisher, "voltgo/battery-soc", 50, 1000)
+	publisher.Publish("other-device/voltgo/battery-soc", `{"value":10,"unit":"percent","timestamp":1060}`)
+
+	if len(next.PublishCalls) != 4 {
+		t.Fatalf("published %d messages, want the 4 passed on", len(next.PublishCalls))
+	}
+	if got := events(t, next); len(got) != 0 {
+		t.Errorf("events = %+v, want none", got)
+	}
+
+	publisher.Close()
+	if next.CloseCalls != 1 {
+		t.Errorf("CloseCalls = %d, want 1", next.CloseCalls)
+	}
+}
+
+func TestPublisherPublishesEvents(t *testing.T) {
+	publisher, next, metrics := newTestPublisher(t,
+		Rule{Name: "low-soc", Condition: "voltgo/battery-soc < 20", For: "2m", Severity: SeverityCritical},
+		Rule{Name: "empty", Condition: "voltgo/battery-soc < 5"},
+	)
+	if metrics.States["low-soc"] != StateInactive || metrics.States["empty"] != StateInactive {
+		t.Fatalf("States = %v, want both inactive", metrics.States)
+	}
+
+	publishMetric(publisher, "voltgo/battery-soc", 15, 1000)
+	if metrics.States["low-soc"] != StatePending {
+		t.Errorf("low-soc state = %s, want pending", metrics.States["low-soc"])
+	}
+	publishMetric(publisher, "voltgo/battery-soc", 14, 1120)
+	if metrics.States["low-soc"] != StateFiring {
+		t.Errorf("low-soc state = %s, want firing", metrics.States["low-soc"])
+	}
+	publishMetric(publisher, "voltgo/battery-soc", 30, 1180)
+
+	got := events(t, next)
+	if len(got) != 2 {
+		t.Fatalf("events = %+v, want fired and resolved", got)
+	}
+	fired, resolved := got[0], got[1]
+	if fired.Value != 1 || fired.State != StateFiring || fired.Timestamp != 1120 || fired.Reading != 14 {
+		t.Errorf("fired = %+v, want firing at 1120", fired)
+	}
+	if fired.Metric != "firing" || fired.Unit != "boolean" || fired.Labels["rule"] != "low-soc" || fired.Labels["severity"] != SeverityCritical {
+		t.Errorf("fired = %+v, want the alerts_firing metric of low-soc", fired)
+	}
+	if resolved.Value != 0 || resolved.State != StateResolved || resolved.Timestamp != 1180 || resolved.Condition != "voltgo/battery-soc < 20" {
+		t.Errorf("resolved = %+v, want resolved at 1180", resolved)
+	}
+
+	var topics []string
+	for _, call := range next.PublishCalls {
+		topics = append(topics, call.TopicSuffix)
+	}
+	want := []string{
+		"test-device-1/voltgo/battery-soc",
+		"test-device-1/voltgo/battery-soc",
+		"test-device-1/alerts/low-soc",
+		"test-device-1/voltgo/battery-soc",
+		"test-device-1/alerts/low-soc",
+	}
+	if len(topics) != len(want) {
+		t.Fatalf("topics = %v, want %v", topics, want)
+	}
+	for i := range want {
+		if topics[i] != want[i] {
+			t.Errorf("topics[%d] = %s, want %s", i, topics[i], want[i])
+		}
+	}
+}
+
+func TestPublisherResolve(t *testing.T) {
+	publisher, next, metrics := newTestPublisher(t,
+		Rule{Name: "fault", Condition: "epever/charging-status == 3", AutoResolve: boolPtr(false)},
+		Rule{Name: "low-soc", Condition: "voltgo/battery-soc < 20"},
+	)
+	publisher.now = func() time.Time { return time.Unix(2000, 0) }
+
+	publishMetric(publisher, "epever/charging-status", 3, 1000)
+	publishMetric(publisher, "epever/charging-status", 1, 1060)
+	if metrics.States["fault"] != StateFiring {
+		t.Fatalf("fault state = %s, want firing until resolved", metrics.States["fault"])
+	}
+
+	if err := publisher.Resolve("missing"); !errors.Is(err, ErrUnknownAlert) {
+		t.Errorf("Resolve(missing) error = %v, want ErrUnknownAlert", err)
+	}
+	if err := publisher.Resolve("low-soc"); !errors.Is(err, ErrNotFiring) {
+		t.Errorf("Resolve(low-soc) error = %v, want ErrNotFiring", err)
+	}
+	if err := publisher.Resolve("fault"); err != nil {
+		t.Fatalf("Resolve(fault) error = %v", err)
+	}
+	if metrics.States["fault"] != StateResolved {
+		t.Errorf("fault state = %s, want resolved", metrics.States["fault"])
+	}
+
+	got := events(t, next)
+	if len(got) != 2 || got[1].State != StateResolved || got[1].Timestamp != 2000 {
+		t.Errorf("events = %+v, want fired, then resolved at 2000", got)
+	}
+	if err := publisher.Resolve("fault"); !errors.Is(err, ErrNotFiring) {
+		t.Errorf("Resolve(fault) again error = %v, want ErrNotFiring", err)
+	}
+}
+
+func TestPublisherStatuses(t *testing.T) {
+	publisher, _, _ := newTestPublisher(t,
+		Rule{Name: "low-soc", Condition: "voltgo/battery-soc < 20"},
+		Rule{Name: "empty", Condition: "voltgo/battery-soc < 5"},
+		Rule{Name: "hot", Condition: "epever/battery-temp > 45", For: "5m"},
+	)
+
+	if got := publisher.Statuses(false); got == nil || len(got) != 0 {
+		t.Errorf("Statuses(false) = %+v, want an empty list", got)
+	}
+
+	publishMetric(publisher, "voltgo/battery-soc", 15, 1000)
+	publishMetric(publisher, "epever/battery-temp", 50, 1000)
+
+	active := publisher.Statuses(false)
+	if len(active) != 2 || active[0].Name != "low-soc" || active[0].State != StateFiring || active[1].Name != "hot" || active[1].State != StatePending {
+		t.Errorf("Statuses(false) = %+v, want low-soc firing and hot pending", active)
+	}
+	if all := publisher.Statuses(true); len(all) != 3 || all[1].Name != "empty" || all[1].State != StateInactive {
+		t.Errorf("Statuses(true) = %+v, want every rule", all)
+	}
+}
+
+func TestNewPublisherInvalidRule(t *testing.T) {
+	_, err := NewPublisher(&testutil.MockMessagePublisher{}, []Rule{{Name: "low-soc", Condition: "soc < 20"}}, &MockMetricsCollector{}, "")
+	if err == nil {
+		t.Error("NewPublisher() error = nil, want an error")
+	}
+}
+
+func TestNewPublisherFromConfigWithoutRules(t *testing.T) {
+	next := &testutil.MockMessagePublisher{}
+	publisher, alerts, err := NewPublisherFromConfig(Configuration{}, next, "test-device-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if publisher != next || alerts != nil {
+		t.Errorf("NewPublisherFromConfig() = %v, %v, want next unchanged and no alerts", publisher, alerts)
+	}
+}
+
+func TestEventToJSON(t *testing.T) {
+	event := &Event{Rule: "low-soc", Severity: SeverityWarning, State: StateFiring, Condition: "voltgo/battery-soc < 20", Value: 15, Timestamp: 1000, Message: "low-soc fired"}
+	payload, err := event.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"value":1,"unit":"boolean","timestamp":1000,"metric":"firing","labels":{"rule":"low-soc","severity":"warning"},` +
+		`"state":"firing","severity":"warning","condition":"voltgo/battery-soc \u003c 20","reading":15,"message":"low-soc fired"}`
+	if payload != want {
+		t.Errorf("ToJSON() = %s, want %s", payload, want)
+	}
+}
@@ -0,0 +1,145 @@
+package alerts
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// alert is a rule and where it stands.
+type alert struct {
+	Rule
+	condition   condition
+	forDuration time.Duration
+	cooldown    time.Duration
+	autoResolve bool
+
+	state string
+
+	// since is when the alert entered its state, firedAt when it last fired
+	// and updated the time of the reading value is, all in Unix seconds and
+	// zero before the first
+	since   int64
+	firedAt int64
+	updated int64
+	value   float64
+}
+
+func newAlert(rule Rule) (*alert, error) {
+	if err := rule.validate(); err != nil {
+		return nil, fmt.Errorf("alert rule %s: %w", rule.Name, err)
+	}
+	condition, _ := parseCondition(rule.Condition)
+	forDuration, _ := parseDuration("for", rule.For)
+	cooldown, _ := parseDuration("cooldown", rule.Cooldown)
+
+	return &alert{
+		Rule:        rule,
+		condition:   condition,
+		forDuration: forDuration,
+		cooldown:    cooldown,
+		autoResolve: rule.GetAutoResolve(),
+		state:       StateInactive,
+	}, nil
+}
+
+// evaluate moves the alert on by a reading of its metric and returns the
+// event when it fires or resolves. A reading no newer than the last is
+// dropped.
+//
+// An alert whose condition holds is pending until it has held for the rule's
+// for, and then until the cooldown since it last fired has passed; a pending
+// alert whose condition stops holding goes back to inactive. A firing alert
+// resolves once the condition no longer holds by the hysteresis, unless it
+// only resolves through the API.
+func (a *alert) evaluate(value float64, timestamp int64) *Event {
+	if timestamp <= a.updated {
+		return nil
+	}
+	a.value, a.updated = value, timestamp
+	if a.since == 0 {
+		// Inactive from the first reading
+		a.since = timestamp
+	}
+
+	switch a.state {
+	case StateInactive, StateResolved:
+		if !a.condition.holds(value, 0) {
+			return nil
+		}
+		a.enter(StatePending, timestamp)
+		return a.fireIfDue(timestamp)
+	case StatePending:
+		if !a.condition.holds(value, 0) {
+			a.enter(StateInactive, timestamp)
+			return nil
+		}
+		return a.fireIfDue(timestamp)
+	default:
+		if !a.autoResolve || a.condition.holds(value, a.Hysteresis) {
+			return nil
+		}
+		return a.resolve(timestamp)
+	}
+}
+
+// fireIfDue fires a pending alert whose condition has held long enough and
+// whose cooldown has passed.
+func (a *alert) fireIfDue(timestamp int64) *Event {
+	if time.Duration(timestamp-a.since)*time.Second < a.forDuration {
+		return nil
+	}
+	if a.firedAt != 0 && time.Duration(timestamp-a.firedAt)*time.Second < a.cooldown {
+		return nil
+	}
+	a.enter(StateFiring, timestamp)
+	a.firedAt = timestamp
+	return a.event()
+}
+
+// resolve resolves a firing alert.
+func (a *alert) resolve(timestamp int64) *Event {
+	a.enter(StateResolved, timestamp)
+	return a.event()
+}
+
+func (a *alert) enter(state string, timestamp int64) {
+	a.state, a.since = state, timestamp
+}
+
+// event describes the state the alert has just entered.
+func (a *alert) event() *Event {
+	verb := "fired"
+	if a.state == StateResolved {
+		verb = "resolved"
+	}
+	return &Event{
+		Rule:      a.Name,
+		Severity:  a.GetSeverity(),
+		State:     a.state,
+		Condition: a.condition.String(),
+		Value:     a.value,
+		Timestamp: a.since,
+		Message: fmt.Sprintf("%s %s: %s is %s (%s)", a.Name, verb, a.condition.metric,
+			strconv.FormatFloat(a.value, 'f', -1, 64), a.condition),
+	}
+}
+
+// status reports the alert for the API.
+func (a *alert) status() Status {
+	status := Status{
+		Name:      a.Name,
+		Severity:  a.GetSeverity(),
+		Condition: a.condition.String(),
+		State:     a.state,
+	}
+	if a.updated != 0 {
+		value, updated, since := a.value, a.updated, a.since
+		status.Value, status.Updated, status.Since = &value, &updated, &since
+	}
+	if a.firedAt != 0 {
+		firedAt := a.firedAt
+		status.FiredAt = &firedAt
+	}
+	return status
+}
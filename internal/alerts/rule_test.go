@@ -0,0 +1,148 @@
+package alerts
+
+import (
+	"testing"
+)
+
+// step is a reading and the state the alert should be in after it.
+type step struct {
+	value     float64
+	timestamp int64
+	wantState string
+}
+
+func runSteps(t *testing.T, rule Rule, steps []step) *alert {
+	t.Helper()
+
+	a, err := newAlert(rule)
+	if err != nil {
+		t.Fatalf("newAlert() error = %v", err)
+	}
+	for _, s := range steps {
+		a.evaluate(s.value, s.timestamp)
+		if a.state != s.wantState {
+			t.Fatalf("after %v at %d: state = %s, want %s", s.value, s.timestamp, a.state, s.wantState)
+		}
+	}
+	return a
+}
+
+func TestAlertFiresAtOnce(t *testing.T) {
+	a, err := newAlert(Rule{Name: "low-soc", Condition: "voltgo/battery-soc < 20"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if event := a.evaluate(25, 1000); event != nil || a.state != StateInactive {
+		t.Fatalf("evaluate(25) = %+v, state %s, want no event and inactive", event, a.state)
+	}
+	event := a.evaluate(15, 1060)
+	if event == nil || event.State != StateFiring || event.Timestamp != 1060 || event.Value != 15 {
+		t.Fatalf("evaluate(15) = %+v, want firing at 1060", event)
+	}
+	if want := "low-soc fired: voltgo/battery-soc is 15 (voltgo/battery-soc < 20)"; event.Message != want {
+		t.Errorf("Message = %q, want %q", event.Message, want)
+	}
+	if event := a.evaluate(10, 1120); event != nil {
+		t.Errorf("evaluate(10) while firing = %+v, want no event", event)
+	}
+	event = a.evaluate(21, 1180)
+	if event == nil || event.State != StateResolved || event.Severity != SeverityWarning {
+		t.Fatalf("evaluate(21) = %+v, want resolved", event)
+	}
+}
+
+func TestAlertFor(t *testing.T) {
+	rule := Rule{Name: "low-soc", Condition: "voltgo/battery-soc < 20", For: "10m"}
+	runSteps(t, rule, []step{
+		{15, 1000, StatePending},
+		{14, 1300, StatePending},
+		// Cleared before it fired
+		{22, 1500, StateInactive},
+		{15, 1560, StatePending},
+		{15, 2100, StatePending},
+		{15, 2160, StateFiring},
+	})
+}
+
+func TestAlertHysteresis(t *testing.T) {
+	rule := Rule{Name: "low-soc", Condition: "voltgo/battery-soc < 20", Hysteresis: 5}
+	runSteps(t, rule, []step{
+		{19, 1000, StateFiring},
+		{21, 1060, StateFiring},
+		{24.9, 1120, StateFiring},
+		{25, 1180, StateResolved},
+		{21, 1240, StateResolved},
+		{19, 1300, StateFiring},
+	})
+}
+
+func TestAlertCooldown(t *testing.T) {
+	rule := Rule{Name: "hot", Condition: "epever/battery-temp > 45", Cooldown: "1h"}
+	a := runSteps(t, rule, []step{
+		{46, 1000, StateFiring},
+		{44, 1060, StateResolved},
+		// Holds again within the hour, so waits pending
+		{46, 1120, StatePending},
+		{46, 4500, StatePending},
+		{46, 4600, StateFiring},
+	})
+	if a.firedAt != 4600 {
+		t.Errorf("firedAt = %d, want 4600", a.firedAt)
+	}
+}
+
+func TestAlertWithoutAutoResolve(t *testing.T) {
+	rule := Rule{Name: "fault", Condition: "epever/charging-status == 3", AutoResolve: boolPtr(false)}
+	a := runSteps(t, rule, []step{
+		{3, 1000, StateFiring},
+		{1, 1060, StateFiring},
+	})
+
+	event := a.resolve(1100)
+	if event == nil || event.State != StateResolved || event.Value != 1 {
+		t.Fatalf("resolve() = %+v, want resolved at the last reading", event)
+	}
+	if want := "fault resolved: epever/charging-status is 1 (epever/charging-status == 3)"; event.Message != want {
+		t.Errorf("Message = %q, want %q", event.Message, want)
+	}
+}
+
+func TestAlertDropsOldReadings(t *testing.T) {
+	a := runSteps(t, Rule{Name: "low-soc", Condition: "voltgo/battery-soc < 20"}, []step{
+		{25, 1000, StateInactive},
+		{15, 1000, StateInactive},
+		{15, 900, StateInactive},
+	})
+	if a.value != 25 {
+		t.Errorf("value = %v, want 25", a.value)
+	}
+}
+
+func TestAlertStatus(t *testing.T) {
+	a, err := newAlert(Rule{Name: "low-soc", Condition: "voltgo/battery-soc < 20", Severity: SeverityCritical})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := a.status()
+	if status.State != StateInactive || status.Value != nil || status.Since != nil || status.FiredAt != nil || status.Updated != nil {
+		t.Errorf("status() before a reading = %+v, want inactive without times", status)
+	}
+
+	a.evaluate(15, 1000)
+	a.evaluate(12, 1060)
+	status = a.status()
+	if status.Name != "low-soc" || status.Severity != SeverityCritical || status.Condition != "voltgo/battery-soc < 20" {
+		t.Errorf("status() = %+v, want the rule", status)
+	}
+	if status.State != StateFiring || *status.Value != 12 || *status.Updated != 1060 || *status.Since != 1000 || *status.FiredAt != 1000 {
+		t.Errorf("status() = %+v, want firing since 1000 at 12", status)
+	}
+}
+
+func TestNewAlertInvalidRule(t *testing.T) {
+	if _, err := newAlert(Rule{Name: "low-soc", Condition: "soc < 20"}); err == nil {
+		t.Error("newAlert() error = nil, want an error")
+	}
+}
@@ -21,3 +21,60 @@ func TestVersionInfoMatchesAPIContract(t *testing.T) {
 		"VersionInfo no longer marshals to the fields docs/api-contract.json "+
 			"records; update the contract and site/src/api/types.ts together")
 }
+
+// ControllersGet serialises ControllersResponse directly. The optional fields
+// of each entry have no omitempty, so every key is present whatever the state.
+func TestControllersResponseMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(ControllersResponse{Controllers: []ControllerStatus{}})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/controllers"),
+		testutil.JSONFieldNames(t, payload),
+		"ControllersResponse no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+
+	entry, err := json.Marshal(ControllerStatus{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/controllers#controllers[]"),
+		testutil.JSONFieldNames(t, entry),
+		"ControllerStatus no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
+
+// ControllerHealthGet serialises ControllerHealth, which embeds health.Health,
+// so the contract covers the fields of both.
+func TestControllerHealthMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(ControllerHealth{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/controllers/{name}/health"),
+		testutil.JSONFieldNames(t, payload),
+		"ControllerHealth no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
+
+// SnapshotGet serialises SnapshotResponse. Each entry's data is the
+// controller's own status, whose fields the controllers' contract tests pin.
+func TestSnapshotResponseMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(SnapshotResponse{Controllers: []ControllerSnapshot{}})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/snapshot"),
+		testutil.JSONFieldNames(t, payload),
+		"SnapshotResponse no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+
+	entry, err := json.Marshal(ControllerSnapshot{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/snapshot#controllers[]"),
+		testutil.JSONFieldNames(t, entry),
+		"ControllerSnapshot no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
@@ -11,12 +11,21 @@ import (
 
 	"github.com/gin-contrib/static"
 	"github.com/gin-gonic/gin"
+	"github.com/lumberbarons/solar-controller/internal/alerts"
 	"github.com/lumberbarons/solar-controller/internal/config"
 	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"github.com/lumberbarons/solar-controller/internal/controllers/canbms"
 	"github.com/lumberbarons/solar-controller/internal/controllers/epever"
+	"github.com/lumberbarons/solar-controller/internal/controllers/ina2xx"
+	"github.com/lumberbarons/solar-controller/internal/controllers/onewire"
 	"github.com/lumberbarons/solar-controller/internal/controllers/voltgo"
+	"github.com/lumberbarons/solar-controller/internal/history"
+	"github.com/lumberbarons/solar-controller/internal/notify"
 	"github.com/lumberbarons/solar-controller/internal/publish"
+	"github.com/lumberbarons/solar-controller/internal/reports"
 	staticfs "github.com/lumberbarons/solar-controller/internal/static"
+	"github.com/lumberbarons/solar-controller/internal/system"
+	"github.com/lumberbarons/solar-controller/internal/virtual"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 )
@@ -30,6 +39,11 @@ type Application struct {
 	publisher   publish.MessagePublisher
 	factories   []controllerFactory
 	controllers []controllers.SolarController
+	entries     []controllerEntry
+	history     *history.Store
+	reports     *reports.Publisher
+	alerts      *alerts.Publisher
+	notify      *notify.Publisher
 	version     VersionInfo
 }
 
@@ -38,7 +52,10 @@ type Application struct {
 // for a given config, and that each one receives the publisher, without needing
 // the real hardware: the production constructors open a serial port eagerly.
 type controllerFactory struct {
-	name  string
+	name string
+	// kind is the controller's Type, known before it is built so a
+	// controller waiting for its device can report it
+	kind  string
 	build func(cfg *config.SolarControllerConfiguration, publisher publish.MessagePublisher) (controllers.SolarController, error)
 }
 
@@ -48,14 +65,37 @@ func defaultControllerFactories() []controllerFactory {
 	return []controllerFactory{
 		{
 			name: "epever",
+			kind: controllers.TypeChargeController,
 			build: func(cfg *config.SolarControllerConfiguration, publisher publish.MessagePublisher) (controllers.SolarController, error) {
 				return epever.NewControllerFromConfig(cfg.Epever, publisher, cfg.DeviceID)
 			},
 		},
 		{
 			name: "voltgo",
+			kind: controllers.TypeBattery,
 			build: func(cfg *config.SolarControllerConfiguration, publisher publish.MessagePublisher) (controllers.SolarController, error) {
-				return voltgo.NewControllerFromConfig(cfg.Voltgo, publisher, cfg.DeviceID)
+				return voltgo.NewControllerFromConfig(cfg.Voltgo, publisher, cfg.DeviceID, cfg.DataDir)
+			},
+		},
+		{
+			name: "canbms",
+			kind: controllers.TypeBattery,
+			build: func(cfg *config.SolarControllerConfiguration, publisher publish.MessagePublisher) (controllers.SolarController, error) {
+				return canbms.NewControllerFromConfig(cfg.CanBMS, publisher, cfg.DeviceID)
+			},
+		},
+		{
+			name: "onewire",
+			kind: controllers.TypeTemperatureSensor,
+			build: func(cfg *config.SolarControllerConfiguration, publisher publish.MessagePublisher) (controllers.SolarController, error) {
+				return onewire.NewControllerFromConfig(cfg.OneWire, publisher, cfg.DeviceID)
+			},
+		},
+		{
+			name: "ina2xx",
+			kind: controllers.TypeCurrentSensor,
+			build: func(cfg *config.SolarControllerConfiguration, publisher publish.MessagePublisher) (controllers.SolarController, error) {
+				return ina2xx.NewControllerFromConfig(cfg.INA2xx, publisher, cfg.DeviceID)
 			},
 		},
 	}
@@ -75,9 +115,48 @@ func NewApplication(cfg *config.Config, publisher publish.MessagePublisher, vers
 }
 
 func newApplication(cfg *config.Config, publisher publish.MessagePublisher, version VersionInfo, factories []controllerFactory) (*Application, error) {
+	// History records what is published, virtual metrics included, so it
+	// wraps the publisher inside them
+	publisher, store, err := history.NewPublisherFromConfig(cfg.SolarController.History, publisher, cfg.SolarController.DeviceID, cfg.SolarController.DataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reports read what the controllers publish, virtual metrics included,
+	// and their end-of-day summaries are kept in the history
+	publisher, reporter, err := reports.NewPublisherFromConfig(cfg.SolarController.Reports, publisher, cfg.SolarController.DeviceID, cfg.SolarController.DataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Notifications send the alert events, so they wrap the publisher inside
+	// the alerts
+	publisher, notifier, err := notify.NewPublisherFromConfig(cfg.SolarController.Notifications, publisher, cfg.SolarController.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Alerts watch what the controllers publish, virtual metrics included,
+	// and their events are kept in the history
+	publisher, alerter, err := alerts.NewPublisherFromConfig(cfg.SolarController.Alerts, publisher, cfg.SolarController.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Virtual metrics are computed from what the controllers publish, so they
+	// wrap the publisher the controllers are given
+	publisher, err = virtual.NewPublisherFromConfig(cfg.SolarController.VirtualMetrics, publisher, cfg.SolarController.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+
 	app := &Application{
 		config:    cfg,
 		publisher: publisher,
+		history:   store,
+		reports:   reporter,
+		alerts:    alerter,
+		notify:    notifier,
 		version:   version,
 		factories: factories,
 	}
@@ -96,7 +175,8 @@ func newApplication(cfg *config.Config, publisher publish.MessagePublisher, vers
 
 	// Build controllers
 	if err := app.buildControllers(); err != nil {
-		return nil, fmt.Errorf("failed to build controllers: %w", err)
+		app.closeControllers()
+		return nil, err
 	}
 
 	// Setup routes
@@ -133,25 +213,79 @@ func authMiddleware(token string) gin.HandlerFunc {
 // buildControllers initializes all solar equipment controllers based on
 // configuration. A controller that reports itself disabled — because config
 // turned it off, or because it is enabled but missing a required field — is
-// dropped rather than started.
+// dropped rather than started. A controller whose device is unplugged
+// (controllers.ErrDeviceUnavailable) starts in a waiting state and keeps
+// retrying, so one missing adapter does not take down the rest of the
+// service. Any other constructor error is returned, as is a system balance
+// that is enabled but cannot start.
 func (a *Application) buildControllers() error {
 	var ctrlList []controllers.SolarController
+	var entries []controllerEntry
 
 	for _, factory := range a.factories {
 		controller, err := factory.build(&a.config.SolarController, a.publisher)
+		if err != nil && !errors.Is(err, controllers.ErrDeviceUnavailable) {
+			// Stop the ones already started
+			a.controllers = ctrlList
+			return fmt.Errorf("failed to start %s controller: %w", factory.name, err)
+		}
 		if err != nil {
-			return fmt.Errorf("failed to create %s controller: %w", factory.name, err)
+			pending := newPendingController(factory, &a.config.SolarController, a.publisher, err)
+			ctrlList = append(ctrlList, pending)
+			entries = append(entries, controllerEntry{name: factory.name, controller: pending, status: pending.status})
+			continue
 		}
 
 		if controller.Enabled() {
+			controllerUp.WithLabelValues(factory.name).Set(1)
+			controllerStartAttempts.WithLabelValues(factory.name).Inc()
 			ctrlList = append(ctrlList, controller)
+			entries = append(entries, controllerEntry{name: factory.name, controller: controller, status: fixedStatus(factory.name, controller.Type(), ControllerRunning)})
+		} else {
+			entries = append(entries, controllerEntry{name: factory.name, controller: controller, status: fixedStatus(factory.name, controller.Type(), ControllerDisabled)})
+		}
+	}
+
+	if a.config.SolarController.System.Enabled {
+		controller, err := buildSystem(&a.config.SolarController, a.publisher, entries)
+		if err != nil {
+			a.controllers = ctrlList
+			return fmt.Errorf("failed to start system balance: %w", err)
 		}
+		ctrlList = append(ctrlList, controller)
+		entries = append(entries, controllerEntry{name: controller.Name(), controller: controller, status: fixedStatus(controller.Name(), controller.Type(), ControllerRunning)})
 	}
 
 	a.controllers = ctrlList
+	a.entries = entries
 	return nil
 }
 
+// buildSystem starts the system balance over the epever and voltgo
+// controllers. Configuration validation has made sure both are enabled, so
+// each has an entry, running or waiting for its device; a waiting one is
+// joined once it comes up.
+func buildSystem(cfg *config.SolarControllerConfiguration, publisher publish.MessagePublisher, entries []controllerEntry) (controllers.SolarController, error) {
+	source := func(name string) (controllers.SolarController, error) {
+		for _, entry := range entries {
+			if entry.name == name && entry.status().State != ControllerDisabled {
+				return entry.controller, nil
+			}
+		}
+		return nil, fmt.Errorf("the %s controller is not running", name)
+	}
+
+	charger, err := source("epever")
+	if err != nil {
+		return nil, err
+	}
+	battery, err := source("voltgo")
+	if err != nil {
+		return nil, err
+	}
+	return system.NewControllerFromConfig(cfg.System, charger, battery, publisher, cfg.DeviceID, cfg.DataDir)
+}
+
 // setupRoutes configures all HTTP routes for the application.
 func (a *Application) setupRoutes() {
 	// Prometheus metrics endpoint
@@ -165,6 +299,31 @@ func (a *Application) setupRoutes() {
 		c.JSON(200, a.version)
 	})
 
+	// Controller lifecycle states
+	a.router.GET("/api/controllers", a.ControllersGet())
+	a.router.GET("/api/controllers/:name/health", a.ControllerHealthGet())
+	a.router.GET("/api/snapshot", a.SnapshotGet())
+
+	// History and the Prometheus query API over it
+	if a.history != nil {
+		a.history.RegisterEndpoints(a.router)
+	}
+
+	// Daily, monthly and yearly reports
+	if a.reports != nil {
+		a.reports.RegisterEndpoints(a.router)
+	}
+
+	// Active alerts
+	if a.alerts != nil {
+		a.alerts.RegisterEndpoints(a.router)
+	}
+
+	// Notification channels and their test sends
+	if a.notify != nil {
+		a.notify.RegisterEndpoints(a.router)
+	}
+
 	// Register controller-specific endpoints
 	for _, controller := range a.controllers {
 		if controller.Enabled() {
@@ -244,14 +403,17 @@ func (a *Application) Close() error {
 		a.publisher.Close()
 	}
 
-	// Close all controllers
+	a.closeControllers()
+	return nil
+}
+
+// closeControllers closes every controller that was started.
+func (a *Application) closeControllers() {
 	for _, controller := range a.controllers {
 		if err := controller.Close(); err != nil {
 			log.Errorf("failed to close controller: %v", err)
 		}
 	}
-
-	return nil
 }
 
 // Router returns the Gin router instance for testing purposes.
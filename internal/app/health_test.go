@@ -0,0 +1,117 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lumberbarons/solar-controller/internal/config"
+	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"github.com/lumberbarons/solar-controller/internal/health"
+	"github.com/lumberbarons/solar-controller/internal/publish"
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// healthyController is a fakeController that reports a fixed health.
+type healthyController struct {
+	fakeController
+	health health.Health
+}
+
+func (h *healthyController) Health() health.Health { return h.health }
+
+func controllerHealth(t *testing.T, app *Application, name string) ControllerHealth {
+	t.Helper()
+
+	w := get(t, app, "/api/controllers/"+name+"/health")
+	require.Equal(t, http.StatusOK, w.Code, "body: %s", w.Body)
+
+	var response ControllerHealth
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func TestControllerHealthGet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useFastPendingRetry(t)
+
+	age := 42.0
+	lastSuccess := time.Date(2025, 6, 21, 12, 0, 0, 0, time.UTC)
+	healthy := &healthyController{
+		fakeController: fakeController{name: "epever", enabled: true},
+		health: health.Health{
+			Availability:        health.Online,
+			Stale:               true,
+			Age:                 &age,
+			LastSuccess:         &lastSuccess,
+			LastError:           "timeout",
+			ConsecutiveFailures: 1,
+		},
+	}
+	flaky := &flakyFactory{fake: &fakeController{name: "voltgo", enabled: true}}
+
+	factories := []controllerFactory{
+		{
+			name: "epever",
+			build: func(_ *config.SolarControllerConfiguration, _ publish.MessagePublisher) (controllers.SolarController, error) {
+				return healthy, nil
+			},
+		},
+		flaky.factory(),
+		newFakeFactory(&fakeController{name: "canbms", enabled: false}),
+		newFakeFactory(&fakeController{name: "onewire", enabled: true}),
+	}
+
+	app, err := newApplication(minimalConfig(), testutil.NewMockPublisher(), getTestVersionInfo(), factories)
+	require.NoError(t, err)
+	defer app.Close()
+
+	t.Run("running controller reports its tracker", func(t *testing.T) {
+		got := controllerHealth(t, app, "epever")
+		assert.Equal(t, ControllerRunning, got.State)
+		assert.Equal(t, healthy.health.Availability, got.Availability)
+		assert.True(t, got.Stale)
+		require.NotNil(t, got.Age)
+		assert.InDelta(t, 42, *got.Age, 0.001)
+		assert.Equal(t, 1, got.ConsecutiveFailures)
+	})
+
+	t.Run("waiting controller is offline with the constructor error", func(t *testing.T) {
+		got := controllerHealth(t, app, "voltgo")
+		assert.Equal(t, ControllerWaiting, got.State)
+		assert.Equal(t, health.Offline, got.Availability)
+		assert.Contains(t, got.LastError, "no such file or directory")
+	})
+
+	t.Run("disabled controller is unknown", func(t *testing.T) {
+		got := controllerHealth(t, app, "canbms")
+		assert.Equal(t, ControllerDisabled, got.State)
+		assert.Equal(t, health.Unknown, got.Availability)
+	})
+
+	t.Run("controller without a tracker is unknown", func(t *testing.T) {
+		got := controllerHealth(t, app, "onewire")
+		assert.Equal(t, ControllerRunning, got.State)
+		assert.Equal(t, health.Unknown, got.Availability)
+	})
+
+	t.Run("unknown controller is not found", func(t *testing.T) {
+		w := get(t, app, "/api/controllers/nope/health")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("late controller reports its own health once running", func(t *testing.T) {
+		flaky.plugIn()
+		require.Eventually(t, func() bool {
+			return controllerHealth(t, app, "voltgo").State == ControllerRunning
+		}, 5*time.Second, 5*time.Millisecond)
+
+		got := controllerHealth(t, app, "voltgo")
+		assert.Equal(t, health.Unknown, got.Availability)
+		assert.Empty(t, got.LastError)
+	})
+}
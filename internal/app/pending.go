@@ -0,0 +1,245 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lumberbarons/solar-controller/internal/config"
+	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"github.com/lumberbarons/solar-controller/internal/health"
+	"github.com/lumberbarons/solar-controller/internal/publish"
+	log "github.com/sirupsen/logrus"
+)
+
+// Backoff between attempts to build a controller whose device was not there
+// at startup. Variables so tests can shorten them.
+var (
+	pendingRetryMin = 5 * time.Second
+	pendingRetryMax = 5 * time.Minute
+)
+
+// pendingController stands in for a controller whose constructor failed,
+// typically because its device was unplugged at boot. It retries the
+// constructor in the background and, until that succeeds, answers the
+// controller's /api routes with 503 and the reason. Once the device comes
+// up, requests are passed to the real controller's routes.
+//
+// Routes cannot be added to the main router while it is serving, so the real
+// controller registers its endpoints on a private engine, and the pending
+// controller owns the whole /api/{name} prefix and forwards to it.
+type pendingController struct {
+	factory   controllerFactory
+	cfg       *config.SolarControllerConfiguration
+	publisher publish.MessagePublisher
+
+	mu          sync.Mutex
+	controller  controllers.SolarController
+	handler     *gin.Engine
+	lastErr     error
+	attempts    int
+	nextAttempt time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newPendingController records the constructor's first failure and starts
+// retrying it.
+func newPendingController(factory controllerFactory, cfg *config.SolarControllerConfiguration, publisher publish.MessagePublisher, err error) *pendingController {
+	p := &pendingController{
+		factory:     factory,
+		cfg:         cfg,
+		publisher:   publisher,
+		lastErr:     err,
+		attempts:    1,
+		nextAttempt: time.Now().Add(pendingRetryMin),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	controllerUp.WithLabelValues(factory.name).Set(0)
+	controllerStartAttempts.WithLabelValues(factory.name).Inc()
+	log.Warnf("%s controller waiting for its device, retrying in %s: %v", factory.name, pendingRetryMin, err)
+
+	go p.retry()
+	return p
+}
+
+// retry calls the constructor with exponential backoff until it succeeds or
+// the controller is closed.
+func (p *pendingController) retry() {
+	defer close(p.done)
+
+	backoff := pendingRetryMin
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-time.After(backoff):
+		}
+
+		controllerStartAttempts.WithLabelValues(p.factory.name).Inc()
+		controller, err := p.factory.build(p.cfg, p.publisher)
+		if err == nil && !controller.Enabled() {
+			err = fmt.Errorf("%s controller came up disabled", p.factory.name)
+		}
+
+		if err != nil {
+			backoff = min(backoff*2, pendingRetryMax)
+
+			p.mu.Lock()
+			p.lastErr = err
+			p.attempts++
+			p.nextAttempt = time.Now().Add(backoff)
+			p.mu.Unlock()
+
+			log.Warnf("%s controller still waiting for its device, retrying in %s: %v", p.factory.name, backoff, err)
+			continue
+		}
+
+		handler := gin.New()
+		handler.Use(gin.Recovery())
+		handler.NoRoute(func(c *gin.Context) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		})
+		controller.RegisterEndpoints(handler)
+
+		p.mu.Lock()
+		closed := false
+		select {
+		case <-p.stop:
+			closed = true
+		default:
+			p.controller = controller
+			p.handler = handler
+			p.lastErr = nil
+			p.attempts++
+		}
+		p.mu.Unlock()
+
+		if closed {
+			// Close raced with a successful build; the new controller has
+			// nobody to close it otherwise
+			_ = controller.Close()
+			return
+		}
+
+		controllerUp.WithLabelValues(p.factory.name).Set(1)
+		log.Infof("%s controller started after %d attempts", p.factory.name, p.attempts)
+		return
+	}
+}
+
+// RegisterEndpoints claims the controller's /api prefix for every method.
+func (p *pendingController) RegisterEndpoints(r *gin.Engine) {
+	prefix := fmt.Sprintf("/api/%s", p.factory.name)
+	r.Any(prefix, p.serve)
+	r.Any(prefix+"/*path", p.serve)
+}
+
+func (p *pendingController) serve(c *gin.Context) {
+	p.mu.Lock()
+	handler, err, next := p.handler, p.lastErr, p.nextAttempt
+	p.mu.Unlock()
+
+	if handler != nil {
+		handler.ServeHTTP(c.Writer, c.Request)
+		return
+	}
+
+	retryAfter := max(int(time.Until(next).Seconds()+0.5), 1)
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"error": fmt.Sprintf("%s is waiting for its device: %v", p.factory.name, err),
+		"state": string(ControllerWaiting),
+	})
+}
+
+// Name returns the factory's name, which the controller's endpoints are
+// under.
+func (p *pendingController) Name() string {
+	return p.factory.name
+}
+
+// Type returns the factory's kind, so it is known before the controller is
+// built.
+func (p *pendingController) Type() string {
+	return p.factory.kind
+}
+
+// Snapshot returns the real controller's latest data once it is running, and
+// nil until then.
+func (p *pendingController) Snapshot() any {
+	p.mu.Lock()
+	controller := p.controller
+	p.mu.Unlock()
+
+	if controller == nil {
+		return nil
+	}
+	return controller.Snapshot()
+}
+
+// Enabled is true: the controller is configured, just not running yet.
+func (p *pendingController) Enabled() bool {
+	return true
+}
+
+// Close stops retrying and closes the real controller if it came up.
+func (p *pendingController) Close() error {
+	p.mu.Lock()
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+	}
+	p.mu.Unlock()
+
+	<-p.done
+
+	p.mu.Lock()
+	controller := p.controller
+	p.mu.Unlock()
+
+	if controller != nil {
+		return controller.Close()
+	}
+	return nil
+}
+
+// status reports the controller for /api/controllers.
+func (p *pendingController) status() ControllerStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := ControllerStatus{
+		Name:     p.factory.name,
+		Type:     p.factory.kind,
+		State:    ControllerRunning,
+		Attempts: p.attempts,
+	}
+	if p.handler == nil {
+		next := p.nextAttempt
+		status.State = ControllerWaiting
+		status.Error = p.lastErr.Error()
+		status.NextAttempt = &next
+	}
+	return status
+}
+
+// Health reports the real controller's health once it is running. Until
+// then the controller is offline, with the constructor's error.
+func (p *pendingController) Health() health.Health {
+	p.mu.Lock()
+	controller, err := p.controller, p.lastErr
+	p.mu.Unlock()
+
+	if controller != nil {
+		return controller.Health()
+	}
+	return health.Health{Availability: health.Offline, LastError: err.Error()}
+}
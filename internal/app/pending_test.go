@@ -0,0 +1,242 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lumberbarons/solar-controller/internal/config"
+	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"github.com/lumberbarons/solar-controller/internal/publish"
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useFastPendingRetry shortens the pending-controller backoff for one test.
+func useFastPendingRetry(t *testing.T) {
+	t.Helper()
+
+	previousMin, previousMax := pendingRetryMin, pendingRetryMax
+	pendingRetryMin, pendingRetryMax = 10*time.Millisecond, 20*time.Millisecond
+	t.Cleanup(func() {
+		pendingRetryMin, pendingRetryMax = previousMin, previousMax
+	})
+}
+
+// flakyFactory fails until its device is "plugged in", then builds fake.
+type flakyFactory struct {
+	mu      sync.Mutex
+	present bool
+	calls   int
+	fake    *fakeController
+}
+
+func (f *flakyFactory) plugIn() {
+	f.mu.Lock()
+	f.present = true
+	f.mu.Unlock()
+}
+
+func (f *flakyFactory) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func (f *flakyFactory) factory() controllerFactory {
+	return controllerFactory{
+		name: f.fake.name,
+		kind: f.fake.kind,
+		build: func(_ *config.SolarControllerConfiguration, publisher publish.MessagePublisher) (controllers.SolarController, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			f.calls++
+			if !f.present {
+				return nil, fmt.Errorf("%w: open /dev/ttyUSB0: no such file or directory", controllers.ErrDeviceUnavailable)
+			}
+			f.fake.publisher = publisher
+			return f.fake, nil
+		},
+	}
+}
+
+func get(t *testing.T, app *Application, path string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, path, nil)
+	require.NoError(t, err)
+	app.Router().ServeHTTP(w, req)
+	return w
+}
+
+func controllerStatuses(t *testing.T, app *Application) map[string]ControllerStatus {
+	t.Helper()
+
+	w := get(t, app, "/api/controllers")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response ControllersResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	statuses := make(map[string]ControllerStatus, len(response.Controllers))
+	for _, status := range response.Controllers {
+		statuses[status.Name] = status
+	}
+	return statuses
+}
+
+func TestPendingController_AnswersServiceUnavailableUntilTheDeviceComesUp(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useFastPendingRetry(t)
+
+	flaky := &flakyFactory{fake: &fakeController{name: "epever", kind: controllers.TypeChargeController, enabled: true}}
+	publisher := testutil.NewMockPublisher()
+
+	app, err := newApplication(minimalConfig(), publisher, getTestVersionInfo(), []controllerFactory{flaky.factory()})
+	require.NoError(t, err)
+	defer app.Close()
+
+	// The factory names the controller before it can be built
+	require.Len(t, app.controllers, 1)
+	assert.Equal(t, "epever", app.controllers[0].Name())
+	assert.Equal(t, controllers.TypeChargeController, app.controllers[0].Type())
+
+	w := get(t, app, "/api/epever/metrics")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "no such file or directory",
+		"the 503 should say why the controller is not running")
+
+	status := controllerStatuses(t, app)["epever"]
+	assert.Equal(t, ControllerWaiting, status.State)
+	assert.Contains(t, status.Error, "no such file or directory")
+	assert.NotNil(t, status.NextAttempt)
+
+	// It keeps retrying while the device is absent
+	require.Eventually(t, func() bool { return flaky.callCount() >= 3 }, 5*time.Second, 5*time.Millisecond)
+
+	flaky.plugIn()
+	require.Eventually(t, func() bool {
+		return controllerStatuses(t, app)["epever"].State == ControllerRunning
+	}, 5*time.Second, 5*time.Millisecond)
+
+	w = get(t, app, "/api/epever/metrics")
+	assert.Equal(t, http.StatusOK, w.Code, "requests should reach the controller once it is running")
+	assert.JSONEq(t, `{"controller": "epever"}`, w.Body.String())
+	assert.Same(t, publisher, flaky.fake.publisher, "the late-built controller did not get the publisher")
+
+	// Routes the controller did not register are still a JSON 404
+	w = get(t, app, "/api/epever/nope")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	status = controllerStatuses(t, app)["epever"]
+	assert.Empty(t, status.Error)
+	assert.Nil(t, status.NextAttempt)
+	assert.Equal(t, flaky.callCount(), status.Attempts)
+}
+
+func TestPendingController_KeepsWaitingWhileTheControllerComesUpDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useFastPendingRetry(t)
+
+	flaky := &flakyFactory{fake: &fakeController{name: "epever", enabled: false}}
+
+	app, err := newApplication(minimalConfig(), testutil.NewMockPublisher(), getTestVersionInfo(), []controllerFactory{flaky.factory()})
+	require.NoError(t, err)
+	defer app.Close()
+
+	flaky.plugIn()
+	require.Eventually(t, func() bool {
+		return strings.Contains(controllerStatuses(t, app)["epever"].Error, "came up disabled")
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, ControllerWaiting, controllerStatuses(t, app)["epever"].State)
+}
+
+func TestPendingController_CloseStopsRetrying(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useFastPendingRetry(t)
+
+	flaky := &flakyFactory{fake: &fakeController{name: "epever", enabled: true}}
+
+	app, err := newApplication(minimalConfig(), testutil.NewMockPublisher(), getTestVersionInfo(), []controllerFactory{flaky.factory()})
+	require.NoError(t, err)
+
+	require.NoError(t, app.Close())
+	calls := flaky.callCount()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, calls, flaky.callCount(), "the constructor was retried after Close")
+}
+
+func TestPendingController_CloseClosesTheLateController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useFastPendingRetry(t)
+
+	flaky := &flakyFactory{fake: &fakeController{name: "epever", enabled: true}}
+
+	app, err := newApplication(minimalConfig(), testutil.NewMockPublisher(), getTestVersionInfo(), []controllerFactory{flaky.factory()})
+	require.NoError(t, err)
+
+	flaky.plugIn()
+	require.Eventually(t, func() bool {
+		return controllerStatuses(t, app)["epever"].State == ControllerRunning
+	}, 5*time.Second, 5*time.Millisecond)
+
+	require.NoError(t, app.Close())
+	assert.True(t, flaky.fake.closed, "a controller that started late was not closed on shutdown")
+}
+
+func TestControllersGet_ListsEveryController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useFastPendingRetry(t)
+
+	factories := []controllerFactory{
+		newFakeFactory(&fakeController{name: "epever", kind: controllers.TypeChargeController, enabled: true}),
+		newFakeFactory(&fakeController{name: "voltgo", kind: controllers.TypeBattery, enabled: false}),
+		{
+			name: "canbms",
+			kind: controllers.TypeBattery,
+			build: func(*config.SolarControllerConfiguration, publish.MessagePublisher) (controllers.SolarController, error) {
+				return nil, fmt.Errorf("open can0: %w", controllers.ErrDeviceUnavailable)
+			},
+		},
+	}
+
+	app, err := newApplication(minimalConfig(), testutil.NewMockPublisher(), getTestVersionInfo(), factories)
+	require.NoError(t, err)
+	defer app.Close()
+
+	w := get(t, app, "/api/controllers")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response ControllersResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	names := make([]string, 0, len(response.Controllers))
+	for _, status := range response.Controllers {
+		names = append(names, status.Name)
+	}
+	assert.Equal(t, []string{"epever", "voltgo", "canbms"}, names, "controllers are listed in factory order")
+
+	types := make([]string, 0, len(response.Controllers))
+	for _, status := range response.Controllers {
+		types = append(types, status.Type)
+	}
+	assert.Equal(t, []string{controllers.TypeChargeController, controllers.TypeBattery, controllers.TypeBattery}, types,
+		"a waiting controller reports the type of its factory")
+
+	assert.Equal(t, ControllerRunning, response.Controllers[0].State)
+	assert.Equal(t, 1, response.Controllers[0].Attempts)
+	assert.Equal(t, ControllerDisabled, response.Controllers[1].State)
+	assert.Equal(t, 0, response.Controllers[1].Attempts)
+	assert.Equal(t, ControllerWaiting, response.Controllers[2].State)
+	assert.Equal(t, "open can0: device unavailable", response.Controllers[2].Error)
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lumberbarons/solar-controller/internal/config"
+	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"github.com/lumberbarons/solar-controller/internal/health"
+	"github.com/lumberbarons/solar-controller/internal/publish"
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func snapshot(t *testing.T, app *Application) SnapshotResponse {
+	t.Helper()
+
+	w := get(t, app, "/api/snapshot")
+	require.Equal(t, http.StatusOK, w.Code, "body: %s", w.Body)
+
+	var response SnapshotResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func TestSnapshotGet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useFastPendingRetry(t)
+
+	healthy := &healthyController{
+		fakeController: fakeController{
+			name:     "epever",
+			kind:     controllers.TypeChargeController,
+			enabled:  true,
+			snapshot: map[string]float64{"batteryVoltage": 13.2},
+		},
+		health: health.Health{Availability: health.Online},
+	}
+	flaky := &flakyFactory{fake: &fakeController{
+		name:     "voltgo",
+		kind:     controllers.TypeBattery,
+		enabled:  true,
+		snapshot: map[string]int{"soc": 87},
+	}}
+
+	factories := []controllerFactory{
+		newFakeFactory(&fakeController{name: "ina2xx", kind: controllers.TypeCurrentSensor, enabled: true}),
+		{
+			name: "epever",
+			build: func(_ *config.SolarControllerConfiguration, _ publish.MessagePublisher) (controllers.SolarController, error) {
+				return healthy, nil
+			},
+		},
+		flaky.factory(),
+		newFakeFactory(&fakeController{name: "canbms", kind: controllers.TypeBattery, enabled: false}),
+	}
+
+	app, err := newApplication(minimalConfig(), testutil.NewMockPublisher(), getTestVersionInfo(), factories)
+	require.NoError(t, err)
+	defer app.Close()
+
+	t.Run("lists every controller that is not disabled", func(t *testing.T) {
+		before := time.Now().Unix()
+		got := snapshot(t, app)
+		assert.GreaterOrEqual(t, got.Timestamp, before)
+
+		names := make([]string, 0, len(got.Controllers))
+		for _, entry := range got.Controllers {
+			names = append(names, entry.Name)
+		}
+		assert.Equal(t, []string{"ina2xx", "epever", "voltgo"}, names, "controllers are listed in factory order")
+	})
+
+	t.Run("carries each controller's data and health", func(t *testing.T) {
+		got := snapshot(t, app).Controllers
+
+		assert.Nil(t, got[0].Data, "a controller that has not collected yet has no data")
+		assert.Equal(t, controllers.TypeCurrentSensor, got[0].Type)
+
+		assert.Equal(t, ControllerRunning, got[1].State)
+		assert.Equal(t, controllers.TypeChargeController, got[1].Type)
+		assert.Equal(t, health.Online, got[1].Health.Availability)
+		assert.Equal(t, map[string]any{"batteryVoltage": 13.2}, got[1].Data)
+	})
+
+	t.Run("waiting controller has no data until it is running", func(t *testing.T) {
+		got := snapshot(t, app).Controllers[2]
+		assert.Equal(t, ControllerWaiting, got.State)
+		assert.Equal(t, controllers.TypeBattery, got.Type)
+		assert.Equal(t, health.Offline, got.Health.Availability)
+		assert.Nil(t, got.Data)
+
+		flaky.plugIn()
+		require.Eventually(t, func() bool {
+			return snapshot(t, app).Controllers[2].State == ControllerRunning
+		}, 5*time.Second, 5*time.Millisecond)
+
+		got = snapshot(t, app).Controllers[2]
+		assert.Equal(t, map[string]any{"soc": float64(87)}, got.Data)
+	})
+}
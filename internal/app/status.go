@@ -0,0 +1,176 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"github.com/lumberbarons/solar-controller/internal/health"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ControllerState is where a configured controller is in its lifecycle.
+type ControllerState string
+
+const (
+	// ControllerRunning controllers are collecting.
+	ControllerRunning ControllerState = "running"
+	// ControllerWaiting controllers failed to start, usually because their
+	// device was missing, and are retrying in the background.
+	ControllerWaiting ControllerState = "waiting"
+	// ControllerDisabled controllers are turned off, or missing a required
+	// setting, in configuration.
+	ControllerDisabled ControllerState = "disabled"
+)
+
+// ControllerStatus is one entry of GET /api/controllers.
+type ControllerStatus struct {
+	Name string `json:"name"`
+
+	// Type is the kind of equipment, such as "battery"
+	Type  string          `json:"type"`
+	State ControllerState `json:"state"`
+
+	// Error is why a waiting controller could not start.
+	Error string `json:"error"`
+
+	// Attempts counts constructor calls, including the one at startup.
+	Attempts int `json:"attempts"`
+
+	// NextAttempt is when a waiting controller will retry.
+	NextAttempt *time.Time `json:"nextAttempt"`
+}
+
+// ControllersResponse is the body of GET /api/controllers.
+type ControllersResponse struct {
+	Controllers []ControllerStatus `json:"controllers"`
+}
+
+// Registered once for the process: promauto uses the global registry, and
+// tests build many Applications.
+var (
+	controllerUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "solar_controller",
+		Name:      "controller_up",
+		Help:      "Whether a configured controller is running (1) or waiting for its device (0).",
+	}, []string{"controller"})
+
+	controllerStartAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "solar_controller",
+		Name:      "controller_start_attempts_total",
+		Help:      "Attempts to start a controller, including the one at startup.",
+	}, []string{"controller"})
+)
+
+// ControllerHealth is the body of GET /api/controllers/{name}/health.
+type ControllerHealth struct {
+	Name  string          `json:"name"`
+	State ControllerState `json:"state"`
+	health.Health
+}
+
+// ControllerSnapshot is one entry of GET /api/snapshot.
+type ControllerSnapshot struct {
+	Name   string          `json:"name"`
+	Type   string          `json:"type"`
+	State  ControllerState `json:"state"`
+	Health health.Health   `json:"health"`
+
+	// Data is the controller's latest status, shaped as its metrics endpoint
+	// returns it less the health. It is null until the first successful
+	// collection, which includes while the controller is waiting.
+	Data any `json:"data"`
+}
+
+// SnapshotResponse is the body of GET /api/snapshot.
+type SnapshotResponse struct {
+	// Timestamp is when the snapshot was taken, as a Unix timestamp
+	Timestamp   int64                `json:"timestamp"`
+	Controllers []ControllerSnapshot `json:"controllers"`
+}
+
+// controllerEntry ties a factory name to its controller and the source of its
+// current status. A disabled controller is kept for its name and type only.
+type controllerEntry struct {
+	name       string
+	controller controllers.SolarController
+	status     func() ControllerStatus
+}
+
+// ControllersGet lists every controller the binary supports and its state.
+func (a *Application) ControllersGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		response := ControllersResponse{Controllers: make([]ControllerStatus, 0, len(a.entries))}
+		for _, entry := range a.entries {
+			response.Controllers = append(response.Controllers, entry.status())
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// ControllerHealthGet reports one controller's data freshness. Controllers
+// that are disabled, or do not track freshness, report an unknown
+// availability.
+func (a *Application) ControllerHealthGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		for _, entry := range a.entries {
+			if entry.name != name {
+				continue
+			}
+
+			response := ControllerHealth{
+				Name:   name,
+				State:  entry.status().State,
+				Health: health.Health{Availability: health.Unknown},
+			}
+			if response.State != ControllerDisabled {
+				response.Health = entry.controller.Health()
+			}
+			c.JSON(http.StatusOK, response)
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("unknown controller %q", name)})
+	}
+}
+
+// SnapshotGet returns the latest data of every controller that is not
+// disabled in one response, so a dashboard needs a single request rather than
+// one per controller.
+func (a *Application) SnapshotGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		response := SnapshotResponse{
+			Timestamp:   time.Now().Unix(),
+			Controllers: make([]ControllerSnapshot, 0, len(a.entries)),
+		}
+		for _, entry := range a.entries {
+			status := entry.status()
+			if status.State == ControllerDisabled {
+				continue
+			}
+			response.Controllers = append(response.Controllers, ControllerSnapshot{
+				Name:   entry.name,
+				Type:   status.Type,
+				State:  status.State,
+				Health: entry.controller.Health(),
+				Data:   entry.controller.Snapshot(),
+			})
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// fixedStatus returns a status source for a controller whose state cannot
+// change after startup.
+func fixedStatus(name, kind string, state ControllerState) func() ControllerStatus {
+	return func() ControllerStatus {
+		attempts := 1
+		if state == ControllerDisabled {
+			attempts = 0
+		}
+		return ControllerStatus{Name: name, Type: kind, State: state, Attempts: attempts}
+	}
+}
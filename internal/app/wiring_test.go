@@ -1,15 +1,26 @@
 package app
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lumberbarons/solar-controller/internal/alerts"
 	"github.com/lumberbarons/solar-controller/internal/config"
 	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"github.com/lumberbarons/solar-controller/internal/health"
+	"github.com/lumberbarons/solar-controller/internal/history"
+	"github.com/lumberbarons/solar-controller/internal/notify"
 	"github.com/lumberbarons/solar-controller/internal/publish"
+	"github.com/lumberbarons/solar-controller/internal/reports"
+	"github.com/lumberbarons/solar-controller/internal/system"
 	"github.com/lumberbarons/solar-controller/internal/testutil"
+	"github.com/lumberbarons/solar-controller/internal/virtual"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -19,12 +30,24 @@ import (
 // opens the device eagerly, which is why buildControllers takes factories.
 type fakeController struct {
 	name       string
+	kind       string
+	snapshot   any
 	enabled    bool
 	publisher  publish.MessagePublisher
 	registered bool
 	closed     bool
 }
 
+func (f *fakeController) Name() string { return f.name }
+
+func (f *fakeController) Type() string { return f.kind }
+
+func (f *fakeController) Health() health.Health {
+	return health.Health{Availability: health.Unknown}
+}
+
+func (f *fakeController) Snapshot() any { return f.snapshot }
+
 func (f *fakeController) RegisterEndpoints(r *gin.Engine) {
 	f.registered = true
 	r.GET("/api/"+f.name+"/metrics", func(c *gin.Context) {
@@ -44,6 +67,7 @@ func (f *fakeController) Close() error {
 func newFakeFactory(fake *fakeController) controllerFactory {
 	return controllerFactory{
 		name: fake.name,
+		kind: fake.kind,
 		build: func(_ *config.SolarControllerConfiguration, publisher publish.MessagePublisher) (controllers.SolarController, error) {
 			fake.publisher = publisher
 			return fake, nil
@@ -170,20 +194,54 @@ func TestBuildControllers_EveryControllerReceivesThePublisher(t *testing.T) {
 	assert.Same(t, publisher, voltgoFake.publisher, "voltgo was built without the configured publisher")
 }
 
-func TestBuildControllers_ConstructorFailureNamesTheController(t *testing.T) {
+// A missing device used to abort startup, so one unplugged adapter took down
+// every other controller and the web UI with it. It now leaves that
+// controller waiting; see pending_test.go.
+func TestBuildControllers_MissingDeviceDoesNotAbortStartup(t *testing.T) {
 	gin.SetMode(gin.TestMode)
+	useFastPendingRetry(t)
 
-	factories := []controllerFactory{{
-		name: "epever",
-		build: func(*config.SolarControllerConfiguration, publish.MessagePublisher) (controllers.SolarController, error) {
-			return nil, errors.New("serial port unavailable")
+	other := &fakeController{name: "voltgo", enabled: true}
+	factories := []controllerFactory{
+		{
+			name: "epever",
+			build: func(*config.SolarControllerConfiguration, publish.MessagePublisher) (controllers.SolarController, error) {
+				return nil, fmt.Errorf("serial port unavailable: %w", controllers.ErrDeviceUnavailable)
+			},
 		},
-	}}
+		newFakeFactory(other),
+	}
+
+	app, err := newApplication(minimalConfig(), testutil.NewMockPublisher(), getTestVersionInfo(), factories)
+	require.NoError(t, err)
+	defer app.Close()
+
+	assert.Len(t, app.controllers, 2)
+	assert.True(t, other.registered, "a healthy controller was not started alongside a waiting one")
+}
+
+// Retrying only helps a device that is missing. Any other constructor error,
+// such as a state file that cannot be read, would leave the controller
+// waiting forever behind a 503, so it fails startup instead.
+func TestBuildControllers_OtherConstructorErrorAbortsStartup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	started := &fakeController{name: "voltgo", enabled: true}
+	factories := []controllerFactory{
+		newFakeFactory(started),
+		{
+			name: "epever",
+			build: func(*config.SolarControllerConfiguration, publish.MessagePublisher) (controllers.SolarController, error) {
+				return nil, errors.New("open /var/lib/solar-controller/epever.json: permission denied")
+			},
+		},
+	}
 
 	_, err := newApplication(minimalConfig(), testutil.NewMockPublisher(), getTestVersionInfo(), factories)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "epever")
-	assert.Contains(t, err.Error(), "serial port unavailable")
+	assert.Contains(t, err.Error(), "permission denied")
+	assert.True(t, started.closed, "a controller started before the failure was not closed")
 }
 
 func TestClose_ClosesEveryStartedController(t *testing.T) {
@@ -213,7 +271,7 @@ func TestDefaultControllerFactories(t *testing.T) {
 		names = append(names, factory.name)
 	}
 
-	assert.Equal(t, []string{"epever", "voltgo"}, names)
+	assert.Equal(t, []string{"epever", "voltgo", "canbms", "onewire", "ina2xx"}, names)
 }
 
 // The real epever constructor is exercised here rather than through a fake, so
@@ -282,3 +340,277 @@ func TestDefaultFactories_VoltgoNotStartedWithoutAddress(t *testing.T) {
 		})
 	}
 }
+
+// As above, the real canbms constructor is used. The enabled-with-an-interface
+// case is absent because that path binds a SocketCAN socket.
+func TestDefaultFactories_CanBMSNotStartedWithoutInterface(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		enabled bool
+	}{
+		{name: "disabled", enabled: false},
+		{name: "enabled but no interface", enabled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := minimalConfig()
+			cfg.SolarController.CanBMS.Enabled = tt.enabled
+
+			app, err := NewApplication(cfg, testutil.NewMockPublisher(), getTestVersionInfo())
+			require.NoError(t, err)
+			defer app.Close()
+
+			assert.Empty(t, app.controllers)
+
+			assert.NotContains(t, registeredPaths(app), "/api/canbms/metrics",
+				"canbms endpoints were registered for a controller that never started")
+		})
+	}
+}
+
+// Unlike the other controllers, onewire needs no hardware to start: it reads
+// a sysfs tree, so an empty directory stands in for the w1 bus and both sides
+// of the decision can be checked.
+func TestDefaultFactories_OneWireStartedOnlyWhenEnabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		enabled bool
+	}{
+		{name: "disabled", enabled: false},
+		{name: "enabled", enabled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := minimalConfig()
+			cfg.SolarController.OneWire.Enabled = tt.enabled
+			cfg.SolarController.OneWire.DevicesPath = t.TempDir()
+			cfg.SolarController.OneWire.PublishPeriod = 60
+
+			app, err := NewApplication(cfg, testutil.NewMockPublisher(), getTestVersionInfo())
+			require.NoError(t, err)
+			defer app.Close()
+
+			if tt.enabled {
+				assert.Len(t, app.controllers, 1)
+				assert.Contains(t, registeredPaths(app), "/api/onewire/metrics")
+			} else {
+				assert.Empty(t, app.controllers)
+				assert.NotContains(t, registeredPaths(app), "/api/onewire/metrics",
+					"onewire endpoints were registered for a controller that never started")
+			}
+		})
+	}
+}
+
+// As for canbms, the real ina2xx constructor is used. The enabled-with-a-shunt
+// case is absent because that path opens an I2C bus.
+func TestDefaultFactories_INA2xxNotStartedWithoutShunt(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		enabled bool
+	}{
+		{name: "disabled", enabled: false},
+		{name: "enabled but no shunt", enabled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := minimalConfig()
+			cfg.SolarController.INA2xx.Enabled = tt.enabled
+
+			app, err := NewApplication(cfg, testutil.NewMockPublisher(), getTestVersionInfo())
+			require.NoError(t, err)
+			defer app.Close()
+
+			assert.Empty(t, app.controllers)
+
+			assert.NotContains(t, registeredPaths(app), "/api/ina2xx/metrics",
+				"ina2xx endpoints were registered for a controller that never started")
+		})
+	}
+}
+
+// The system balance is built over the epever and voltgo controllers after the
+// factories, so it needs both running. The fakes' snapshots are not the real
+// statuses, so the balance starts but has nothing to join.
+//
+// The balance's Prometheus collector registers globally, so it is only started
+// once in this test binary.
+func TestBuildControllers_SystemBalance(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	systemConfig := func() *config.Config {
+		cfg := minimalConfig()
+		cfg.SolarController.System = system.Configuration{Enabled: true, PublishPeriod: 60}
+		return cfg
+	}
+
+	t.Run("started over the epever and voltgo controllers", func(t *testing.T) {
+		factories := []controllerFactory{
+			newFakeFactory(&fakeController{name: "epever", kind: controllers.TypeChargeController, enabled: true}),
+			newFakeFactory(&fakeController{name: "voltgo", kind: controllers.TypeBattery, enabled: true}),
+		}
+		app, err := newApplication(systemConfig(), testutil.NewMockPublisher(), getTestVersionInfo(), factories)
+		require.NoError(t, err)
+		defer app.Close()
+
+		assert.Contains(t, registeredPaths(app), "/api/system")
+		assert.Equal(t, ControllerStatus{Name: "system", Type: controllers.TypeSystem, State: ControllerRunning, Attempts: 1},
+			controllerStatuses(t, app)["system"])
+		assert.Equal(t, http.StatusNoContent, get(t, app, "/api/system").Code)
+	})
+
+	t.Run("fails startup without a running voltgo controller", func(t *testing.T) {
+		epever := &fakeController{name: "epever", kind: controllers.TypeChargeController, enabled: true}
+		factories := []controllerFactory{
+			newFakeFactory(epever),
+			newFakeFactory(&fakeController{name: "voltgo", kind: controllers.TypeBattery, enabled: false}),
+		}
+		_, err := newApplication(systemConfig(), testutil.NewMockPublisher(), getTestVersionInfo(), factories)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the voltgo controller is not running")
+		assert.True(t, epever.closed, "the controllers already started were not closed")
+	})
+}
+
+// Virtual metrics wrap the publisher the controllers are given, so what they
+// publish still reaches it and the virtual metrics follow.
+func TestBuildControllers_VirtualMetricsWrapThePublisher(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := minimalConfig()
+	cfg.SolarController.DeviceID = "test-device-1"
+	cfg.SolarController.VirtualMetrics = []virtual.Definition{
+		{Name: "double-power", Expression: "epever.array_power * 2", Unit: "watts"},
+	}
+	fake := &fakeController{name: "epever", enabled: true}
+	publisher := testutil.NewMockPublisher()
+
+	app, err := newApplication(cfg, publisher, getTestVersionInfo(), []controllerFactory{newFakeFactory(fake)})
+	require.NoError(t, err)
+	defer app.Close()
+
+	require.NotSame(t, publisher, fake.publisher)
+	fake.publisher.Publish("test-device-1/epever/array-power", `{"value":250,"unit":"watts","timestamp":1000}`)
+	fake.publisher.Publish("test-device-1/epever/availability", `{"value":1}`)
+
+	topics := make([]string, 0, len(publisher.PublishCalls))
+	for _, call := range publisher.PublishCalls {
+		topics = append(topics, call.TopicSuffix)
+	}
+	assert.Equal(t, []string{
+		"test-device-1/epever/array-power",
+		"test-device-1/epever/availability",
+		"test-device-1/virtual/double-power",
+	}, topics)
+}
+
+// The Prometheus collectors of the history, the reports, the alerts and the
+// notifications register globally, so only one test may enable them.
+func TestBuildControllers_HistoryReportsAndAlertsRecordWhatIsPublished(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	webhook := make(chan notify.Notification, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n notify.Notification
+		if json.NewDecoder(r.Body).Decode(&n) == nil {
+			webhook <- n
+		}
+	}))
+	defer receiver.Close()
+
+	cfg := minimalConfig()
+	cfg.SolarController.DeviceID = "test-device-1"
+	cfg.SolarController.DataDir = t.TempDir()
+	cfg.SolarController.History = history.Configuration{Enabled: true}
+	cfg.SolarController.Reports = reports.Configuration{Enabled: true}
+	cfg.SolarController.Alerts = alerts.Configuration{Rules: []alerts.Rule{{Name: "strong-sun", Condition: "epever/charging-power > 280"}}}
+	cfg.SolarController.Notifications = notify.Configuration{Channels: []notify.Channel{
+		{Name: "hook", Type: notify.TypeWebhook, Webhook: &notify.WebhookConfiguration{URL: receiver.URL}},
+	}}
+	fake := &fakeController{name: "epever", enabled: true}
+	publisher := testutil.NewMockPublisher()
+
+	app, err := newApplication(cfg, publisher, getTestVersionInfo(), []controllerFactory{newFakeFactory(fake)})
+	require.NoError(t, err)
+	defer app.Close()
+
+	now := time.Now()
+	yesterday := now.AddDate(0, 0, -1)
+	fake.publisher.Publish("test-device-1/epever/charging-power", fmt.Sprintf(`{"value":250,"unit":"watts","timestamp":%d}`, yesterday.Unix()))
+	require.Len(t, publisher.PublishCalls, 1, "history, reports and alerts pass every message on")
+
+	// The first reading of today closes yesterday's report
+	fake.publisher.Publish("test-device-1/epever/charging-power", fmt.Sprintf(`{"value":300,"unit":"watts","timestamp":%d}`, now.Unix()))
+
+	recorder := httptest.NewRecorder()
+	app.Router().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/history?metric=epever/charging-power", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var result history.Result
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+	assert.Equal(t, "watts", result.Unit)
+	require.NotEmpty(t, result.Points)
+	assert.Equal(t, 300.0, result.Points[len(result.Points)-1].Value)
+
+	recorder = httptest.NewRecorder()
+	app.Router().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/reports/daily", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var report reports.Report
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &report))
+	require.Len(t, report.Summaries, 2)
+	assert.Equal(t, yesterday.Format("2006-01-02"), report.Summaries[0].Period)
+	assert.Equal(t, 250.0, *report.Summaries[0].PeakPVPower)
+
+	// The end-of-day summary is kept in the history too
+	recorder = httptest.NewRecorder()
+	app.Router().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/label/__name__/values", nil))
+	assert.JSONEq(t, `{"status":"success","data":["alerts_strong_sun","epever_charging_power","reports_peak_pv_power_daily","reports_pv_energy_daily"]}`, recorder.Body.String())
+
+	// Today's reading fired the alert
+	recorder = httptest.NewRecorder()
+	app.Router().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/alerts", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var active alerts.AlertsResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &active))
+	require.Len(t, active.Alerts, 1)
+	assert.Equal(t, "strong-sun", active.Alerts[0].Name)
+	assert.Equal(t, alerts.StateFiring, active.Alerts[0].State)
+
+	// ... and the webhook was told
+	select {
+	case n := <-webhook:
+		assert.Equal(t, "strong-sun", n.Rule)
+		assert.Equal(t, alerts.StateFiring, n.State)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the webhook received no notification")
+	}
+
+	recorder = httptest.NewRecorder()
+	app.Router().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/notifications", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestBuildControllers_HistoryReportsAndAlertsDisabledRegisterNoEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	app, err := newApplication(minimalConfig(), testutil.NewMockPublisher(), getTestVersionInfo(), nil)
+	require.NoError(t, err)
+	defer app.Close()
+
+	for _, target := range []string{"/api/history?metric=epever/array-power", "/api/reports/daily", "/api/alerts", "/api/notifications"} {
+		recorder := httptest.NewRecorder()
+		app.Router().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusNotFound, recorder.Code, target)
+	}
+}
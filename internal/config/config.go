@@ -3,13 +3,22 @@ package config
 import (
 	"fmt"
 
+	"github.com/lumberbarons/solar-controller/internal/alerts"
+	"github.com/lumberbarons/solar-controller/internal/controllers/canbms"
 	"github.com/lumberbarons/solar-controller/internal/controllers/epever"
+	"github.com/lumberbarons/solar-controller/internal/controllers/ina2xx"
+	"github.com/lumberbarons/solar-controller/internal/controllers/onewire"
 	"github.com/lumberbarons/solar-controller/internal/controllers/voltgo"
+	"github.com/lumberbarons/solar-controller/internal/history"
+	"github.com/lumberbarons/solar-controller/internal/notify"
 	"github.com/lumberbarons/solar-controller/internal/publishers/file"
 	"github.com/lumberbarons/solar-controller/internal/publishers/mqtt"
 	"github.com/lumberbarons/solar-controller/internal/publishers/remotewrite"
 	"github.com/lumberbarons/solar-controller/internal/publishers/sns"
 	"github.com/lumberbarons/solar-controller/internal/publishers/solace"
+	"github.com/lumberbarons/solar-controller/internal/reports"
+	"github.com/lumberbarons/solar-controller/internal/system"
+	"github.com/lumberbarons/solar-controller/internal/virtual"
 	"gopkg.in/yaml.v3"
 )
 
@@ -18,12 +27,15 @@ type Config struct {
 }
 
 type SolarControllerConfiguration struct {
-	HTTPPort    int                       `yaml:"httpPort"`
-	BindAddress string                    `yaml:"bindAddress"`
-	Auth        AuthConfiguration         `yaml:"auth"`
-	TLS         TLSConfiguration          `yaml:"tls"`
-	Debug       bool                      `yaml:"debug"`
-	DeviceID    string                    `yaml:"deviceId"`
+	HTTPPort    int               `yaml:"httpPort"`
+	BindAddress string            `yaml:"bindAddress"`
+	Auth        AuthConfiguration `yaml:"auth"`
+	TLS         TLSConfiguration  `yaml:"tls"`
+	Debug       bool              `yaml:"debug"`
+	DeviceID    string            `yaml:"deviceId"`
+	// DataDir is where controllers keep state that must survive a restart,
+	// such as running totals. Empty keeps it in memory only.
+	DataDir     string                    `yaml:"dataDir"`
 	Mqtt        mqtt.Configuration        `yaml:"mqtt"`
 	Solace      solace.Configuration      `yaml:"solace"`
 	SNS         sns.Configuration         `yaml:"sns"`
@@ -31,6 +43,26 @@ type SolarControllerConfiguration struct {
 	RemoteWrite remotewrite.Configuration `yaml:"remoteWrite"`
 	Epever      epever.Configuration      `yaml:"epever"`
 	Voltgo      voltgo.Configuration      `yaml:"voltgo"`
+	CanBMS      canbms.Configuration      `yaml:"canbms"`
+	OneWire     onewire.Configuration     `yaml:"onewire"`
+	INA2xx      ina2xx.Configuration      `yaml:"ina2xx"`
+	System      system.Configuration      `yaml:"system"`
+
+	// VirtualMetrics are computed from the metrics the controllers publish
+	VirtualMetrics []virtual.Definition `yaml:"virtualMetrics"`
+
+	// History keeps the published metrics under DataDir for charts and
+	// Grafana
+	History history.Configuration `yaml:"history"`
+
+	// Reports summarise each day from the published metrics
+	Reports reports.Configuration `yaml:"reports"`
+
+	// Alerts watch the published metrics for the conditions of their rules
+	Alerts alerts.Configuration `yaml:"alerts"`
+
+	// Notifications send alerts by email, push or webhook
+	Notifications notify.Configuration `yaml:"notifications"`
 }
 
 // AuthConfiguration holds API authentication settings. When Token is set,
@@ -164,11 +196,14 @@ func (c *Config) Validate() error {
 		if c.SolarController.Epever.PublishPeriod <= 0 {
 			return fmt.Errorf("epever publish period must be positive")
 		}
+		if err := c.SolarController.Epever.Validate(); err != nil {
+			return fmt.Errorf("invalid epever configuration: %w", err)
+		}
 	}
 
 	// Validate Voltgo configuration if enabled
 	if c.SolarController.Voltgo.Enabled {
-		if c.SolarController.Voltgo.Address == "" {
+		if c.SolarController.Voltgo.Address == "" && len(c.SolarController.Voltgo.Batteries) == 0 {
 			return fmt.Errorf("voltgo address is required when voltgo is enabled")
 		}
 		if c.SolarController.Voltgo.PublishPeriod <= 0 {
@@ -179,5 +214,75 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// Validate CAN BMS configuration if enabled
+	if c.SolarController.CanBMS.Enabled {
+		if c.SolarController.CanBMS.Interface == "" {
+			return fmt.Errorf("canbms interface is required when canbms is enabled")
+		}
+		if c.SolarController.CanBMS.PublishPeriod <= 0 {
+			return fmt.Errorf("canbms publish period must be positive")
+		}
+		if err := c.SolarController.CanBMS.Validate(); err != nil {
+			return fmt.Errorf("invalid canbms configuration: %w", err)
+		}
+	}
+
+	// Validate 1-Wire configuration if enabled
+	if c.SolarController.OneWire.Enabled {
+		if c.SolarController.OneWire.PublishPeriod <= 0 {
+			return fmt.Errorf("onewire publish period must be positive")
+		}
+		if err := c.SolarController.OneWire.Validate(); err != nil {
+			return fmt.Errorf("invalid onewire configuration: %w", err)
+		}
+	}
+
+	// Validate INA2xx configuration if enabled
+	if c.SolarController.INA2xx.Enabled {
+		if c.SolarController.INA2xx.ShuntResistance <= 0 || c.SolarController.INA2xx.MaxCurrent <= 0 {
+			return fmt.Errorf("ina2xx shunt resistance and max current are required when ina2xx is enabled")
+		}
+		if c.SolarController.INA2xx.PublishPeriod <= 0 {
+			return fmt.Errorf("ina2xx publish period must be positive")
+		}
+		if err := c.SolarController.INA2xx.Validate(); err != nil {
+			return fmt.Errorf("invalid ina2xx configuration: %w", err)
+		}
+	}
+
+	// The system balance joins the epever and voltgo readings, so it needs
+	// both
+	if c.SolarController.System.Enabled {
+		if !c.SolarController.Epever.Enabled || !c.SolarController.Voltgo.Enabled {
+			return fmt.Errorf("system needs the epever and voltgo controllers enabled")
+		}
+		if c.SolarController.System.PublishPeriod <= 0 {
+			return fmt.Errorf("system publish period must be positive")
+		}
+		if err := c.SolarController.System.Validate(); err != nil {
+			return fmt.Errorf("invalid system configuration: %w", err)
+		}
+	}
+
+	if err := virtual.Validate(c.SolarController.VirtualMetrics); err != nil {
+		return err
+	}
+
+	if err := c.SolarController.History.Validate(); err != nil {
+		return fmt.Errorf("invalid history configuration: %w", err)
+	}
+
+	if err := c.SolarController.Reports.Validate(); err != nil {
+		return fmt.Errorf("invalid reports configuration: %w", err)
+	}
+
+	if err := c.SolarController.Alerts.Validate(); err != nil {
+		return fmt.Errorf("invalid alerts configuration: %w", err)
+	}
+
+	if err := c.SolarController.Notifications.Validate(); err != nil {
+		return fmt.Errorf("invalid notifications configuration: %w", err)
+	}
+
 	return nil
 }
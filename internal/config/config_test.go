@@ -258,6 +258,57 @@ solarController:
 			wantErr: true,
 			errMsg:  "epever publish period must be positive",
 		},
+		{
+			name: "epever timing profile with overrides",
+			yaml: `
+solarController:
+  httpPort: 8080
+  epever:
+    enabled: true
+    serialPort: /dev/ttyUSB0
+    publishPeriod: 60
+    timing:
+      profile: slow
+      baudRate: 9600
+      parity: even
+      interFrameDelay: 400ms
+      adaptive: true
+`,
+			wantErr: false,
+			check: func(t *testing.T, c Config) {
+				timing, err := c.SolarController.Epever.Timing.Resolve()
+				if err != nil {
+					t.Fatalf("Resolve failed: %v", err)
+				}
+				if timing.BaudRate != 9600 {
+					t.Errorf("BaudRate = %d, want 9600", timing.BaudRate)
+				}
+				if timing.InterFrameDelay != 400*time.Millisecond {
+					t.Errorf("InterFrameDelay = %s, want 400ms", timing.InterFrameDelay)
+				}
+				if timing.CommitDelay != time.Second {
+					t.Errorf("CommitDelay = %s, want the slow profile's 1s", timing.CommitDelay)
+				}
+				if !timing.Adaptive {
+					t.Error("Adaptive should be true")
+				}
+			},
+		},
+		{
+			name: "epever timing with an invalid duration",
+			yaml: `
+solarController:
+  httpPort: 8080
+  epever:
+    enabled: true
+    serialPort: /dev/ttyUSB0
+    publishPeriod: 60
+    timing:
+      commitDelay: soon
+`,
+			wantErr: true,
+			errMsg:  "invalid epever configuration: timing: invalid commitDelay",
+		},
 		{
 			name: "voltgo configuration valid with all fields",
 			yaml: `
@@ -357,6 +408,487 @@ solarController:
 			wantErr: true,
 			errMsg:  "invalid voltgo configuration",
 		},
+		{
+			name: "voltgo batteries instead of an address",
+			yaml: `
+solarController:
+  httpPort: 8080
+  voltgo:
+    enabled: true
+    publishPeriod: 60
+    batteries:
+      - name: house-a
+        address: AA:BB:CC:DD:EE:01
+      - name: house-b
+        address: AA:BB:CC:DD:EE:02
+`,
+			wantErr: false,
+			check: func(t *testing.T, c Config) {
+				batteries := c.SolarController.Voltgo.BatteryList()
+				if len(batteries) != 2 || batteries[1].Name != "house-b" || batteries[1].Address != "AA:BB:CC:DD:EE:02" {
+					t.Errorf("Voltgo batteries = %+v, want house-a and house-b", batteries)
+				}
+			},
+		},
+		{
+			name: "voltgo batteries with a duplicate name",
+			yaml: `
+solarController:
+  httpPort: 8080
+  voltgo:
+    enabled: true
+    publishPeriod: 60
+    batteries:
+      - name: house-a
+        address: AA:BB:CC:DD:EE:01
+      - name: house-a
+        address: AA:BB:CC:DD:EE:02
+`,
+			wantErr: true,
+			errMsg:  "invalid voltgo configuration",
+		},
+		{
+			name: "canbms configuration valid with all fields",
+			yaml: `
+solarController:
+  httpPort: 8080
+  canbms:
+    enabled: true
+    interface: can0
+    publishPeriod: 30
+    staleAfter: 20s
+`,
+			wantErr: false,
+			check: func(t *testing.T, c Config) {
+				if !c.SolarController.CanBMS.Enabled {
+					t.Error("CanBMS should be enabled")
+				}
+				if c.SolarController.CanBMS.Interface != "can0" {
+					t.Errorf("CanBMS Interface = %s, want can0", c.SolarController.CanBMS.Interface)
+				}
+				if got := c.SolarController.CanBMS.GetStaleAfter(); got != 20*time.Second {
+					t.Errorf("CanBMS StaleAfter = %s, want 20s", got)
+				}
+			},
+		},
+		{
+			name: "canbms enabled but no interface",
+			yaml: `
+solarController:
+  httpPort: 8080
+  canbms:
+    enabled: true
+    publishPeriod: 30
+`,
+			wantErr: true,
+			errMsg:  "canbms interface is required",
+		},
+		{
+			name: "canbms enabled but zero publish period",
+			yaml: `
+solarController:
+  httpPort: 8080
+  canbms:
+    enabled: true
+    interface: can0
+`,
+			wantErr: true,
+			errMsg:  "canbms publish period must be positive",
+		},
+		{
+			name: "canbms enabled but unparseable stale window",
+			yaml: `
+solarController:
+  httpPort: 8080
+  canbms:
+    enabled: true
+    interface: can0
+    publishPeriod: 30
+    staleAfter: soon
+`,
+			wantErr: true,
+			errMsg:  "invalid canbms configuration",
+		},
+		{
+			name: "onewire configuration valid with named sensors",
+			yaml: `
+solarController:
+  httpPort: 8080
+  onewire:
+    enabled: true
+    devicesPath: /tmp/w1
+    publishPeriod: 60
+    sensors:
+      - id: 28-0316a2798aff
+        name: battery-box
+      - id: 28-0416a1b2c3ff
+        name: enclosure
+`,
+			wantErr: false,
+			check: func(t *testing.T, c Config) {
+				if !c.SolarController.OneWire.Enabled {
+					t.Error("OneWire should be enabled")
+				}
+				if c.SolarController.OneWire.DevicesPath != "/tmp/w1" {
+					t.Errorf("OneWire DevicesPath = %s, want /tmp/w1", c.SolarController.OneWire.DevicesPath)
+				}
+				if len(c.SolarController.OneWire.Sensors) != 2 || c.SolarController.OneWire.Sensors[1].Name != "enclosure" {
+					t.Errorf("OneWire Sensors = %+v, want battery-box and enclosure", c.SolarController.OneWire.Sensors)
+				}
+			},
+		},
+		{
+			name: "onewire enabled but zero publish period",
+			yaml: `
+solarController:
+  httpPort: 8080
+  onewire:
+    enabled: true
+`,
+			wantErr: true,
+			errMsg:  "onewire publish period must be positive",
+		},
+		{
+			name: "onewire sensor name unusable in a topic",
+			yaml: `
+solarController:
+  httpPort: 8080
+  onewire:
+    enabled: true
+    publishPeriod: 60
+    sensors:
+      - id: 28-0316a2798aff
+        name: Battery Box
+`,
+			wantErr: true,
+			errMsg:  "invalid onewire configuration",
+		},
+		{
+			name: "ina2xx configuration valid with all fields",
+			yaml: `
+solarController:
+  httpPort: 8080
+  ina2xx:
+    enabled: true
+    device: /dev/i2c-3
+    address: 0x41
+    chip: ina219
+    shuntResistance: 0.1
+    maxCurrent: 3.2
+    publishPeriod: 10
+`,
+			wantErr: false,
+			check: func(t *testing.T, c Config) {
+				cfg := c.SolarController.INA2xx
+				if !cfg.Enabled {
+					t.Error("INA2xx should be enabled")
+				}
+				if cfg.GetDevice() != "/dev/i2c-3" || cfg.GetAddress() != 0x41 || cfg.GetChip() != "ina219" {
+					t.Errorf("INA2xx = %s 0x%02x %s, want /dev/i2c-3 0x41 ina219", cfg.GetDevice(), cfg.GetAddress(), cfg.GetChip())
+				}
+				if cfg.ShuntResistance != 0.1 || cfg.MaxCurrent != 3.2 {
+					t.Errorf("INA2xx shunt = %gΩ/%gA, want 0.1Ω/3.2A", cfg.ShuntResistance, cfg.MaxCurrent)
+				}
+			},
+		},
+		{
+			name: "ina2xx enabled but no shunt resistance",
+			yaml: `
+solarController:
+  httpPort: 8080
+  ina2xx:
+    enabled: true
+    maxCurrent: 20
+    publishPeriod: 10
+`,
+			wantErr: true,
+			errMsg:  "ina2xx shunt resistance and max current are required",
+		},
+		{
+			name: "ina2xx enabled but zero publish period",
+			yaml: `
+solarController:
+  httpPort: 8080
+  ina2xx:
+    enabled: true
+    shuntResistance: 0.002
+    maxCurrent: 20
+`,
+			wantErr: true,
+			errMsg:  "ina2xx publish period must be positive",
+		},
+		{
+			name: "ina2xx max current beyond the shunt range",
+			yaml: `
+solarController:
+  httpPort: 8080
+  ina2xx:
+    enabled: true
+    shuntResistance: 0.01
+    maxCurrent: 20
+    publishPeriod: 10
+`,
+			wantErr: true,
+			errMsg:  "invalid ina2xx configuration",
+		},
+		{
+			name: "system configuration valid with epever and voltgo",
+			yaml: `
+solarController:
+  httpPort: 8080
+  epever:
+    enabled: true
+    serialPort: /dev/ttyUSB0
+    publishPeriod: 30
+  voltgo:
+    enabled: true
+    address: AA:BB:CC:DD:EE:FF
+    publishPeriod: 60
+  system:
+    enabled: true
+    publishPeriod: 60
+    maxSkew: 90s
+`,
+			wantErr: false,
+			check: func(t *testing.T, c Config) {
+				cfg := c.SolarController.System
+				if !cfg.Enabled || cfg.PublishPeriod != 60 {
+					t.Errorf("System = %+v, want enabled every 60s", cfg)
+				}
+				if cfg.GetMaxSkew() != 90*time.Second {
+					t.Errorf("System max skew = %s, want 90s", cfg.GetMaxSkew())
+				}
+			},
+		},
+		{
+			name: "system enabled without voltgo",
+			yaml: `
+solarController:
+  httpPort: 8080
+  epever:
+    enabled: true
+    serialPort: /dev/ttyUSB0
+    publishPeriod: 30
+  system:
+    enabled: true
+    publishPeriod: 60
+`,
+			wantErr: true,
+			errMsg:  "system needs the epever and voltgo controllers enabled",
+		},
+		{
+			name: "system enabled but zero publish period",
+			yaml: `
+solarController:
+  httpPort: 8080
+  epever:
+    enabled: true
+    serialPort: /dev/ttyUSB0
+    publishPeriod: 30
+  voltgo:
+    enabled: true
+    address: AA:BB:CC:DD:EE:FF
+    publishPeriod: 60
+  system:
+    enabled: true
+`,
+			wantErr: true,
+			errMsg:  "system publish period must be positive",
+		},
+		{
+			name: "system max skew not a duration",
+			yaml: `
+solarController:
+  httpPort: 8080
+  epever:
+    enabled: true
+    serialPort: /dev/ttyUSB0
+    publishPeriod: 30
+  voltgo:
+    enabled: true
+    address: AA:BB:CC:DD:EE:FF
+    publishPeriod: 60
+  system:
+    enabled: true
+    publishPeriod: 60
+    maxSkew: soon
+`,
+			wantErr: true,
+			errMsg:  "invalid system configuration",
+		},
+		{
+			name: "virtual metrics valid",
+			yaml: `
+solarController:
+  httpPort: 8080
+  virtualMetrics:
+    - name: mppt-efficiency
+      expression: epever.charging_power / epever.array_power * 100
+      unit: percent
+      min: 0
+      max: 100
+`,
+			wantErr: false,
+			check: func(t *testing.T, c Config) {
+				if len(c.SolarController.VirtualMetrics) != 1 {
+					t.Fatalf("VirtualMetrics = %+v, want one", c.SolarController.VirtualMetrics)
+				}
+				metric := c.SolarController.VirtualMetrics[0]
+				if metric.Name != "mppt-efficiency" || metric.Unit != "percent" || metric.Min == nil || *metric.Max != 100 {
+					t.Errorf("VirtualMetrics[0] = %+v, want mppt-efficiency clamped to 0-100", metric)
+				}
+			},
+		},
+		{
+			name: "virtual metric with a bad expression",
+			yaml: `
+solarController:
+  httpPort: 8080
+  virtualMetrics:
+    - name: mppt-efficiency
+      expression: epever.charging_power /
+      unit: percent
+`,
+			wantErr: true,
+			errMsg:  "virtual metric mppt-efficiency",
+		},
+		{
+			name: "history with retention",
+			yaml: `
+solarController:
+  httpPort: 8080
+  history:
+    enabled: true
+    retention:
+      raw: 24h
+      fiveMinute: 14d
+      hour: 365d
+`,
+			wantErr: false,
+			check: func(t *testing.T, c Config) {
+				retention := c.SolarController.History.GetRetention()
+				if retention.Raw != 24*time.Hour || retention.FiveMinute != 14*24*time.Hour {
+					t.Errorf("GetRetention() = %+v, want 24h raw and 14d in 5m buckets", retention)
+				}
+			},
+		},
+		{
+			name: "history retention that shortens",
+			yaml: `
+solarController:
+  httpPort: 8080
+  history:
+    enabled: true
+    retention:
+      raw: 60d
+`,
+			wantErr: true,
+			errMsg:  "invalid history configuration",
+		},
+		{
+			name: "reports with a load source",
+			yaml: `
+solarController:
+  httpPort: 8080
+  reports:
+    enabled: true
+    sources:
+      loadPower: ina2xx/power
+`,
+			wantErr: false,
+			check: func(t *testing.T, c Config) {
+				if c.SolarController.Reports.Sources.LoadPower != "ina2xx/power" {
+					t.Errorf("load source = %q, want ina2xx/power", c.SolarController.Reports.Sources.LoadPower)
+				}
+			},
+		},
+		{
+			name: "reports with a source that is not a metric",
+			yaml: `
+solarController:
+  httpPort: 8080
+  reports:
+    enabled: true
+    sources:
+      soc: battery-soc
+`,
+			wantErr: true,
+			errMsg:  "invalid reports configuration",
+		},
+		{
+			name: "alert rule",
+			yaml: `
+solarController:
+  httpPort: 8080
+  alerts:
+    rules:
+      - name: low-soc
+        condition: voltgo/battery-soc < 20
+        for: 10m
+        hysteresis: 5
+        severity: critical
+`,
+			wantErr: false,
+			check: func(t *testing.T, c Config) {
+				rules := c.SolarController.Alerts.Rules
+				if len(rules) != 1 || rules[0].Condition != "voltgo/battery-soc < 20" || rules[0].Hysteresis != 5 {
+					t.Errorf("alert rules = %+v, want low-soc", rules)
+				}
+			},
+		},
+		{
+			name: "alert rule with a bad condition",
+			yaml: `
+solarController:
+  httpPort: 8080
+  alerts:
+    rules:
+      - name: low-soc
+        condition: battery-soc below 20
+`,
+			wantErr: true,
+			errMsg:  "invalid alerts configuration",
+		},
+		{
+			name: "notification channels",
+			yaml: `
+solarController:
+  httpPort: 8080
+  notifications:
+    channels:
+      - name: phone
+        type: ntfy
+        minSeverity: warning
+        ntfy:
+          url: https://ntfy.sh/cabin-alerts
+      - name: email
+        type: smtp
+        smtp:
+          host: mail.example.com
+          from: cabin@example.com
+          to: [me@example.com]
+`,
+			wantErr: false,
+			check: func(t *testing.T, c Config) {
+				channels := c.SolarController.Notifications.Channels
+				if len(channels) != 2 || channels[0].Ntfy.URL != "https://ntfy.sh/cabin-alerts" || channels[1].SMTP.To[0] != "me@example.com" {
+					t.Errorf("notification channels = %+v, want phone and email", channels)
+				}
+			},
+		},
+		{
+			name: "notification channel without its section",
+			yaml: `
+solarController:
+  httpPort: 8080
+  notifications:
+    channels:
+      - name: phone
+        type: gotify
+`,
+			wantErr: true,
+			errMsg:  "invalid notifications configuration",
+		},
 		{
 			name: "MQTT configuration valid with all fields",
 			yaml: `
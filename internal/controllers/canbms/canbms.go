@@ -0,0 +1,288 @@
+package canbms
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-co-op/gocron"
+	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"github.com/lumberbarons/solar-controller/internal/health"
+	"github.com/lumberbarons/solar-controller/internal/publish"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	namespace = "canbms"
+
+	// defaultStaleAfter is how long the last SOC and measurement frames stay
+	// current. Pylontech-compatible packs repeat them about once a second.
+	defaultStaleAfter = 10 * time.Second
+
+	// collectTimeout bounds a collection cycle. Reading the cached bus state
+	// is immediate; the bound only guards against a wedged lock.
+	collectTimeout = 10 * time.Second
+)
+
+type Configuration struct {
+	Enabled       bool   `yaml:"enabled"`
+	Interface     string `yaml:"interface"`
+	PublishPeriod int    `yaml:"publishPeriod"`
+
+	// OfflineAfter is how many failed collection cycles in a row publish the
+	// controller as offline (default: 3)
+	OfflineAfter int `yaml:"offlineAfter"`
+
+	// StaleAfter is how long without SOC or measurement frames before a
+	// collection cycle fails, as a duration string (default: 10s)
+	StaleAfter string `yaml:"staleAfter"`
+}
+
+// Validate checks the configuration for errors. Only called when enabled.
+func (c *Configuration) Validate() error {
+	if c.StaleAfter != "" {
+		if _, err := time.ParseDuration(c.StaleAfter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetStaleAfter returns the configured stale window or the default (10s).
+func (c *Configuration) GetStaleAfter() time.Duration {
+	if c.StaleAfter == "" {
+		return defaultStaleAfter
+	}
+	staleAfter, err := time.ParseDuration(c.StaleAfter)
+	if err != nil {
+		return defaultStaleAfter
+	}
+	return staleAfter
+}
+
+type Controller struct {
+	collector           *Collector
+	publisher           publish.MessagePublisher
+	prometheusCollector MetricsCollector
+	scheduler           *gocron.Scheduler
+	deviceID            string
+	health              *health.Tracker
+	last                controllers.Latest[BatteryStatus]
+}
+
+// NewController creates a new CAN BMS controller with dependency injection for testing.
+// For production use, call NewControllerFromConfig instead.
+func NewController(
+	collector *Collector,
+	publisher publish.MessagePublisher,
+	prometheusCollector MetricsCollector,
+	deviceID string,
+	publishPeriod int,
+	offlineAfter int,
+) (*Controller, error) {
+	if collector == nil {
+		return &Controller{}, nil
+	}
+
+	// Default device ID if not provided
+	if deviceID == "" {
+		deviceID = "controller-1"
+	}
+
+	s := gocron.NewScheduler(time.UTC)
+
+	controller := &Controller{
+		collector:           collector,
+		publisher:           publisher,
+		prometheusCollector: prometheusCollector,
+		deviceID:            deviceID,
+		health:              health.NewTracker(publisher, deviceID, namespace, time.Duration(publishPeriod)*time.Second, offlineAfter),
+		scheduler:           s,
+	}
+
+	collector.Start()
+
+	// The first cycle is left to the scheduler rather than run immediately:
+	// until the BMS has sent a round of frames there is nothing to publish.
+	_, err := s.Every(publishPeriod).Seconds().WaitForSchedule().Do(controller.collectAndPublish)
+	if err != nil {
+		_ = collector.Close()
+		return nil, fmt.Errorf("failed to start canbms publisher %w", err)
+	}
+
+	s.StartAsync()
+
+	return controller, nil
+}
+
+// newControllerForTest creates a Controller without starting the scheduler or the bus reader.
+// This allows tests to feed frames and call collectAndPublish synchronously without racing.
+func newControllerForTest(
+	collector *Collector,
+	publisher publish.MessagePublisher,
+	prometheusCollector MetricsCollector,
+	deviceID string,
+) *Controller {
+	if deviceID == "" {
+		deviceID = "controller-1"
+	}
+	return &Controller{
+		collector:           collector,
+		publisher:           publisher,
+		prometheusCollector: prometheusCollector,
+		deviceID:            deviceID,
+		health:              health.NewTracker(publisher, deviceID, namespace, 0, 0),
+	}
+}
+
+// NewControllerFromConfig creates a new CAN BMS controller from configuration.
+// This is the production entry point that creates all concrete dependencies.
+func NewControllerFromConfig(config Configuration, publisher publish.MessagePublisher, deviceID string) (*Controller, error) {
+	if !config.Enabled {
+		log.Info("canbms disabled via configuration")
+		return &Controller{}, nil
+	}
+
+	if config.Interface == "" {
+		log.Warn("canbms enabled but no CAN interface provided")
+		return &Controller{}, nil
+	}
+
+	reader, err := NewSocketCANReader(config.Interface)
+	if err != nil {
+		return nil, err
+	}
+
+	prometheusCollector := NewPrometheusCollector()
+	collector := NewCollector(reader, prometheusCollector, config.GetStaleAfter())
+
+	log.Infof("listening for BMS frames on %s", config.Interface)
+
+	return NewController(
+		collector,
+		publisher,
+		prometheusCollector,
+		deviceID,
+		config.PublishPeriod,
+		config.OfflineAfter,
+	)
+}
+
+func (b *Controller) collectAndPublish() {
+	log.Debug("collecting and publishing metrics for canbms controller")
+
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	status, err := b.collector.GetStatus(ctx)
+	if err != nil {
+		log.Errorf("failed to collect metrics from CAN BMS: %s", err)
+		b.prometheusCollector.IncrementFailures()
+		b.health.Failure(err)
+
+		// Publish failure metric to message broker
+		failureMetric := CreateCollectionFailureMetric()
+		payload, err := failureMetric.ToJSON()
+		if err != nil {
+			log.Errorf("failed to marshal failure metric: %s", err)
+			return
+		}
+
+		topicSuffix := fmt.Sprintf("%s/%s/%s", b.deviceID, namespace, failureMetric.Name)
+		b.publisher.Publish(topicSuffix, payload)
+		log.Debugf("published failure metric to %s", topicSuffix)
+
+		return
+	}
+
+	b.last.Set(status)
+
+	b.prometheusCollector.SetMetrics(status)
+
+	// Convert status to individual metrics
+	metrics := ConvertStatusToMetrics(status)
+
+	// Publish each metric individually
+	for _, metric := range metrics {
+		payload, err := metric.ToJSON()
+		if err != nil {
+			log.Errorf("failed to marshal metric %s for publishing: %s", metric.Name, err)
+			continue
+		}
+
+		// Topic format: {deviceId}/canbms/{metric-name}
+		topicSuffix := fmt.Sprintf("%s/%s/%s", b.deviceID, namespace, metric.Name)
+		b.publisher.Publish(topicSuffix, payload)
+
+		log.Debugf("published metric %s to %s", metric.Name, topicSuffix)
+	}
+
+	b.health.Success()
+
+	log.Debug("collection done for canbms controller")
+}
+
+func (b *Controller) MetricsGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := b.last.Last()
+
+		if status == nil {
+			c.JSON(http.StatusNoContent, gin.H{})
+			return
+		}
+		c.JSON(http.StatusOK, struct {
+			*BatteryStatus
+			Health health.Health `json:"health"`
+		}{status, b.Health()})
+	}
+}
+
+// Health reports how fresh the last status is.
+func (b *Controller) Health() health.Health {
+	if b.health == nil {
+		return health.Health{Availability: health.Unknown}
+	}
+	return b.health.Health()
+}
+
+// Name returns the controller's name, which its endpoints are under.
+func (b *Controller) Name() string {
+	return namespace
+}
+
+// Type returns the kind of equipment the controller reads.
+func (b *Controller) Type() string {
+	return controllers.TypeBattery
+}
+
+// Snapshot returns the last status, or nil before the first collection.
+func (b *Controller) Snapshot() any {
+	return b.last.Snapshot()
+}
+
+func (b *Controller) RegisterEndpoints(r *gin.Engine) {
+	if b.collector == nil {
+		return
+	}
+
+	prefix := fmt.Sprintf("/api/%s", namespace)
+
+	r.GET(fmt.Sprintf("%s/metrics", prefix), b.MetricsGet())
+}
+
+func (b *Controller) Enabled() bool {
+	return b.collector != nil
+}
+
+func (b *Controller) Close() error {
+	if b.scheduler != nil {
+		b.scheduler.Stop()
+		log.Debug("canbms scheduler stopped")
+	}
+	if b.collector != nil {
+		return b.collector.Close()
+	}
+	return nil
+}
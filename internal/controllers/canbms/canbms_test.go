@@ -0,0 +1,161 @@
+package canbms
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+)
+
+func TestController_CollectAndPublish(t *testing.T) {
+	t.Run("publishes failure metric when the bus is silent", func(t *testing.T) {
+		mockMetrics := &MockMetricsCollector{}
+		mockPublisher := &testutil.MockMessagePublisher{}
+		collector := NewCollector(&MockFrameReader{}, mockMetrics, 0)
+
+		controller := newControllerForTest(collector, mockPublisher, mockMetrics, "test-device-1")
+		controller.collectAndPublish()
+
+		if mockMetrics.FailuresCount != 1 {
+			t.Errorf("Expected FailuresCount = 1, got %d", mockMetrics.FailuresCount)
+		}
+		if len(mockPublisher.PublishCalls) != 1 {
+			t.Fatalf("Expected 1 publish call, got %d", len(mockPublisher.PublishCalls))
+		}
+		if got := mockPublisher.PublishCalls[0].TopicSuffix; got != "test-device-1/canbms/collection-failure" {
+			t.Errorf("Expected failure topic, got %q", got)
+		}
+	})
+
+	t.Run("publishes metrics from received frames", func(t *testing.T) {
+		mockMetrics := &MockMetricsCollector{}
+		mockPublisher := &testutil.MockMessagePublisher{}
+		collector := NewCollector(&MockFrameReader{}, mockMetrics, 0)
+		feed(collector, testFrames(), time.Now())
+
+		controller := newControllerForTest(collector, mockPublisher, mockMetrics, "test-device-1")
+		controller.collectAndPublish()
+
+		if controller.Snapshot() == nil {
+			t.Error("Snapshot() = nil after a collection, want the status")
+		}
+
+		if mockMetrics.FailuresCount != 0 {
+			t.Errorf("Expected FailuresCount = 0, got %d", mockMetrics.FailuresCount)
+		}
+		if len(mockMetrics.SetMetricsCalls) != 1 {
+			t.Errorf("Expected 1 SetMetrics call, got %d", len(mockMetrics.SetMetricsCalls))
+		}
+
+		metricNames := []string{
+			"battery-voltage", "battery-current", "battery-power",
+			"battery-soc", "battery-soh", "battery-temp",
+			"charge-voltage-limit", "charge-current-limit",
+			"discharge-current-limit", "discharge-voltage-limit",
+			"protection-flags", "warning-flags", "module-count",
+			"availability",
+		}
+		if len(mockPublisher.PublishCalls) != len(metricNames) {
+			t.Fatalf("Expected %d publish calls, got %d", len(metricNames), len(mockPublisher.PublishCalls))
+		}
+		for i, name := range metricNames {
+			if got, want := mockPublisher.PublishCalls[i].TopicSuffix, "test-device-1/canbms/"+name; got != want {
+				t.Errorf("publish %d topic = %q, want %q", i, got, want)
+			}
+		}
+
+		var payload MetricPayload
+		if err := json.Unmarshal([]byte(mockPublisher.PublishCalls[6].Payload), &payload); err != nil {
+			t.Fatalf("Failed to unmarshal payload: %v", err)
+		}
+		if payload.Value != 56.8 || payload.Unit != "volts" {
+			t.Errorf("charge-voltage-limit payload = %+v, want 56.8 volts", payload)
+		}
+	})
+}
+
+func TestController_Endpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	collector := NewCollector(&MockFrameReader{}, &MockMetricsCollector{}, 0)
+	controller := newControllerForTest(collector, &testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "")
+
+	router := gin.New()
+	controller.RegisterEndpoints(router)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/canbms/metrics", nil))
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("before first collection: status = %d, want 204", recorder.Code)
+	}
+
+	feed(collector, testFrames(), time.Now())
+	controller.collectAndPublish()
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/canbms/metrics", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("after collection: status = %d, want 200", recorder.Code)
+	}
+
+	var status BatteryStatus
+	if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Failed to unmarshal status: %v", err)
+	}
+	if status.SOC != 76 {
+		t.Errorf("SOC = %d, want 76", status.SOC)
+	}
+}
+
+func TestController_Disabled(t *testing.T) {
+	controller, err := NewControllerFromConfig(Configuration{Enabled: false}, &testutil.MockMessagePublisher{}, "")
+	if err != nil {
+		t.Fatalf("NewControllerFromConfig() error = %v", err)
+	}
+	if controller.Enabled() {
+		t.Error("disabled controller reports enabled")
+	}
+
+	router := gin.New()
+	controller.RegisterEndpoints(router)
+	if len(router.Routes()) != 0 {
+		t.Errorf("disabled controller registered %d routes", len(router.Routes()))
+	}
+	if err := controller.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+func TestConfiguration_GetStaleAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", defaultStaleAfter},
+		{"30s", 30 * time.Second},
+		{"bogus", defaultStaleAfter},
+	}
+	for _, tt := range tests {
+		config := Configuration{StaleAfter: tt.value}
+		if got := config.GetStaleAfter(); got != tt.want {
+			t.Errorf("GetStaleAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	bad := Configuration{StaleAfter: "bogus"}
+	if err := bad.Validate(); err == nil {
+		t.Error("Validate() accepted an invalid staleAfter")
+	}
+}
+
+func TestController_NameAndType(t *testing.T) {
+	controller := &Controller{}
+	if controller.Name() != "canbms" || controller.Type() != controllers.TypeBattery {
+		t.Errorf("Name(), Type() = %q, %q, want canbms, %q", controller.Name(), controller.Type(), controllers.TypeBattery)
+	}
+}
@@ -0,0 +1,169 @@
+package canbms
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// readErrorBackoff is how long the read loop waits after a bus error before
+// reading again, so a downed interface does not spin.
+const readErrorBackoff = time.Second
+
+// Collector listens to the CAN bus in the background and keeps the latest
+// decoded value of every frame. The BMS pushes frames on its own schedule,
+// so unlike the polled controllers a collection cycle reads that state
+// rather than the bus.
+type Collector struct {
+	reader              FrameReader
+	prometheusCollector MetricsCollector
+	staleAfter          time.Duration
+
+	mu       sync.RWMutex
+	state    BatteryStatus
+	received map[uint32]time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type BatteryStatus struct {
+	Timestamp         int64        `json:"timestamp"`
+	Voltage           float64      `json:"voltage"`
+	Current           float64      `json:"current"`
+	Temperature       float64      `json:"temperature"`
+	SOC               int          `json:"soc"`
+	SOH               int          `json:"soh"`
+	Limits            ChargeLimits `json:"limits"`
+	Alarms            Alarms       `json:"alarms"`
+	ActiveProtections []string     `json:"activeProtections"`
+	ActiveWarnings    []string     `json:"activeWarnings"`
+}
+
+func NewCollector(reader FrameReader, prometheusCollector MetricsCollector, staleAfter time.Duration) *Collector {
+	if staleAfter <= 0 {
+		staleAfter = defaultStaleAfter
+	}
+	return &Collector{
+		reader:              reader,
+		prometheusCollector: prometheusCollector,
+		staleAfter:          staleAfter,
+		received:            make(map[uint32]time.Time),
+	}
+}
+
+// Start begins reading frames in the background until Close is called.
+func (c *Collector) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+		c.run(ctx)
+	}()
+}
+
+func (c *Collector) run(ctx context.Context) {
+	for {
+		frame, err := c.reader.ReadFrame(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Warnf("canbms read failed: %s", err)
+			c.prometheusCollector.IncrementFrameErrors()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(readErrorBackoff):
+			}
+			continue
+		}
+
+		c.handleFrame(frame, time.Now())
+	}
+}
+
+// handleFrame decodes a frame into the current state. Frames with IDs the
+// controller does not use are ignored; a known frame that fails to decode
+// is counted and dropped, leaving the previous value in place.
+func (c *Collector) handleFrame(frame Frame, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+	switch frame.ID {
+	case frameChargeLimits:
+		var limits ChargeLimits
+		if limits, err = DecodeChargeLimits(frame); err == nil {
+			c.state.Limits = limits
+		}
+	case frameSOC:
+		var soc, soh int
+		if soc, soh, err = DecodeSOC(frame); err == nil {
+			c.state.SOC, c.state.SOH = soc, soh
+		}
+	case frameMeasurements:
+		var voltage, current, temperature float64
+		if voltage, current, temperature, err = DecodeMeasurements(frame); err == nil {
+			c.state.Voltage, c.state.Current, c.state.Temperature = voltage, current, temperature
+		}
+	case frameAlarms:
+		var alarms Alarms
+		if alarms, err = DecodeAlarms(frame); err == nil {
+			c.state.Alarms = alarms
+		}
+	default:
+		return
+	}
+
+	if err != nil {
+		log.Debugf("canbms dropped frame: %s", err)
+		c.prometheusCollector.IncrementFrameErrors()
+		return
+	}
+	c.received[frame.ID] = now
+}
+
+// GetStatus returns the latest decoded state. It fails when the SOC or
+// measurement frames have not arrived within the stale window, since
+// publishing a frozen reading as current would hide a dead bus.
+func (c *Collector) GetStatus(_ context.Context) (*BatteryStatus, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := time.Now()
+	for _, id := range []uint32{frameSOC, frameMeasurements} {
+		at, ok := c.received[id]
+		if !ok {
+			return nil, fmt.Errorf("no 0x%03X frame received from the BMS yet", id)
+		}
+		if age := now.Sub(at); age > c.staleAfter {
+			return nil, fmt.Errorf("no 0x%03X frame received from the BMS for %s", id, age.Round(time.Second))
+		}
+	}
+
+	status := c.state
+	status.Timestamp = now.Unix()
+	status.ActiveProtections = ActiveFlags(status.Alarms.Protection)
+	status.ActiveWarnings = ActiveFlags(status.Alarms.Warning)
+
+	log.Debugf("canbms status - %.2fV/%.1fA, SOC %d%%, SOH %d%%, %.1f°C, protection 0x%04X, warning 0x%04X",
+		status.Voltage, status.Current, status.SOC, status.SOH, status.Temperature,
+		status.Alarms.Protection, status.Alarms.Warning)
+
+	return &status, nil
+}
+
+// Close stops the read loop and releases the bus.
+func (c *Collector) Close() error {
+	if c.cancel != nil {
+		c.cancel()
+		<-c.done
+	}
+	return c.reader.Close()
+}
@@ -0,0 +1,132 @@
+package canbms
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testFrames is one broadcast round from a pack: 53.20V, -10.0A, 20.5°C,
+// SOC 76%, SOH 98%, no alarms, one module.
+func testFrames() []Frame {
+	return []Frame{
+		{ID: frameChargeLimits, Data: []byte{0x38, 0x02, 0xE8, 0x03, 0xDC, 0x05, 0xD6, 0x01}},
+		{ID: frameSOC, Data: []byte{0x4C, 0x00, 0x62, 0x00}},
+		{ID: frameMeasurements, Data: []byte{0xC8, 0x14, 0x9C, 0xFF, 0xCD, 0x00}},
+		{ID: frameAlarms, Data: []byte{0x00, 0x00, 0x00, 0x00, 0x01, 0x50, 0x4E, 0x00}},
+	}
+}
+
+func feed(collector *Collector, frames []Frame, at time.Time) {
+	for _, frame := range frames {
+		collector.handleFrame(frame, at)
+	}
+}
+
+func TestCollector_GetStatus(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("decodes a full round of frames", func(t *testing.T) {
+		collector := NewCollector(&MockFrameReader{}, &MockMetricsCollector{}, 0)
+		feed(collector, testFrames(), time.Now())
+
+		status, err := collector.GetStatus(ctx)
+		if err != nil {
+			t.Fatalf("GetStatus() error = %v", err)
+		}
+
+		if status.Voltage != 53.2 || status.Current != -10 || status.Temperature != 20.5 {
+			t.Errorf("measurements = %v/%v/%v, want 53.2/-10/20.5", status.Voltage, status.Current, status.Temperature)
+		}
+		if status.SOC != 76 || status.SOH != 98 {
+			t.Errorf("SOC/SOH = %d/%d, want 76/98", status.SOC, status.SOH)
+		}
+		if status.Limits.ChargeVoltage != 56.8 || status.Limits.DischargeCurrent != 150 {
+			t.Errorf("limits = %+v", status.Limits)
+		}
+		if status.Alarms.ModuleCount != 1 {
+			t.Errorf("ModuleCount = %d, want 1", status.Alarms.ModuleCount)
+		}
+		if len(status.ActiveProtections) != 0 || len(status.ActiveWarnings) != 0 {
+			t.Errorf("active alarms = %v/%v, want none", status.ActiveProtections, status.ActiveWarnings)
+		}
+		if status.Timestamp == 0 {
+			t.Error("expected a timestamp")
+		}
+	})
+
+	t.Run("fails before the first measurement frame", func(t *testing.T) {
+		collector := NewCollector(&MockFrameReader{}, &MockMetricsCollector{}, 0)
+		collector.handleFrame(testFrames()[1], time.Now())
+
+		_, err := collector.GetStatus(ctx)
+		if err == nil || !strings.Contains(err.Error(), "0x356") {
+			t.Errorf("GetStatus() error = %v, want one naming frame 0x356", err)
+		}
+	})
+
+	t.Run("fails once frames go stale", func(t *testing.T) {
+		collector := NewCollector(&MockFrameReader{}, &MockMetricsCollector{}, 5*time.Second)
+		feed(collector, testFrames(), time.Now().Add(-time.Minute))
+
+		if _, err := collector.GetStatus(ctx); err == nil {
+			t.Error("GetStatus() succeeded on frames a minute old")
+		}
+	})
+
+	t.Run("undecodable frame keeps the previous value", func(t *testing.T) {
+		metrics := &MockMetricsCollector{}
+		collector := NewCollector(&MockFrameReader{}, metrics, 0)
+		feed(collector, testFrames(), time.Now())
+		collector.handleFrame(Frame{ID: frameSOC, Data: []byte{0x10}}, time.Now())
+
+		status, err := collector.GetStatus(ctx)
+		if err != nil {
+			t.Fatalf("GetStatus() error = %v", err)
+		}
+		if status.SOC != 76 {
+			t.Errorf("SOC = %d, want previous value 76", status.SOC)
+		}
+		if metrics.FrameErrorsCount != 1 {
+			t.Errorf("FrameErrorsCount = %d, want 1", metrics.FrameErrorsCount)
+		}
+	})
+
+	t.Run("ignores unrelated frame IDs", func(t *testing.T) {
+		metrics := &MockMetricsCollector{}
+		collector := NewCollector(&MockFrameReader{}, metrics, 0)
+		collector.handleFrame(Frame{ID: 0x35E, Data: []byte("PYLON   ")}, time.Now())
+
+		if metrics.FrameErrorsCount != 0 {
+			t.Errorf("FrameErrorsCount = %d, want 0", metrics.FrameErrorsCount)
+		}
+		if len(collector.received) != 0 {
+			t.Errorf("unrelated frame was recorded as received")
+		}
+	})
+}
+
+func TestCollector_StartReadsFromTheBus(t *testing.T) {
+	reader := &MockFrameReader{Frames: testFrames()}
+	collector := NewCollector(reader, &MockMetricsCollector{}, 0)
+	collector.Start()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := collector.GetStatus(context.Background()); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("collector did not pick up frames from the reader")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := collector.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if reader.CloseCalls != 1 {
+		t.Errorf("reader CloseCalls = %d, want 1", reader.CloseCalls)
+	}
+}
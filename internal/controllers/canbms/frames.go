@@ -0,0 +1,143 @@
+package canbms
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Pylontech-compatible CAN frame IDs. The battery broadcasts each of these
+// roughly once a second; all multi-byte fields are little-endian.
+const (
+	frameChargeLimits = 0x351
+	frameSOC          = 0x355
+	frameMeasurements = 0x356
+	frameAlarms       = 0x359
+)
+
+// Frame is a classic CAN frame as read from the bus.
+type Frame struct {
+	ID   uint32
+	Data []byte
+}
+
+// ChargeLimits holds the limits the BMS asks the charger and inverter to
+// respect, decoded from frame 0x351.
+type ChargeLimits struct {
+	ChargeVoltage    float64 `json:"chargeVoltage"`
+	ChargeCurrent    float64 `json:"chargeCurrent"`
+	DischargeCurrent float64 `json:"dischargeCurrent"`
+	DischargeVoltage float64 `json:"dischargeVoltage"`
+}
+
+// Alarms holds the protection and warning flags decoded from frame 0x359.
+// Protection flags mean the BMS has acted (or is about to); warnings are the
+// early stage of the same conditions.
+type Alarms struct {
+	Protection  uint16 `json:"protection"`
+	Warning     uint16 `json:"warning"`
+	ModuleCount int    `json:"moduleCount"`
+}
+
+// Protection and warning flag bits. Frame 0x359 carries protection in bytes
+// 0-1 and warnings in bytes 2-3 using the same bit layout, so one table
+// decodes both.
+const (
+	flagCellOverVoltage      = 1 << 1
+	flagCellUnderVoltage     = 1 << 2
+	flagCellOverTemp         = 1 << 3
+	flagCellUnderTemp        = 1 << 4
+	flagDischargeOverCurrent = 1 << 7
+	flagChargeOverCurrent    = 1 << 8
+	flagSystemError          = 1 << 11
+)
+
+// alarmFlags maps each flag bit to the name it is reported under.
+var alarmFlags = []struct {
+	bit  uint16
+	name string
+}{
+	{flagCellOverVoltage, "cell-over-voltage"},
+	{flagCellUnderVoltage, "cell-under-voltage"},
+	{flagCellOverTemp, "cell-over-temp"},
+	{flagCellUnderTemp, "cell-under-temp"},
+	{flagDischargeOverCurrent, "discharge-over-current"},
+	{flagChargeOverCurrent, "charge-over-current"},
+	{flagSystemError, "system-error"},
+}
+
+// ActiveFlags returns the names of the flags set in bits, in table order.
+func ActiveFlags(bits uint16) []string {
+	active := make([]string, 0)
+	for _, flag := range alarmFlags {
+		if bits&flag.bit != 0 {
+			active = append(active, flag.name)
+		}
+	}
+	return active
+}
+
+// requireLength rejects a frame whose payload is shorter than the decoder
+// needs. Longer payloads are accepted: some BMS firmware pads every frame
+// to eight bytes.
+func requireLength(frame Frame, n int) error {
+	if len(frame.Data) < n {
+		return fmt.Errorf("frame 0x%03X: expected at least %d bytes, got %d", frame.ID, n, len(frame.Data))
+	}
+	return nil
+}
+
+func u16(data []byte, offset int) uint16 {
+	return binary.LittleEndian.Uint16(data[offset : offset+2])
+}
+
+func s16(data []byte, offset int) int16 {
+	return int16(binary.LittleEndian.Uint16(data[offset : offset+2]))
+}
+
+// DecodeChargeLimits decodes frame 0x351: charge voltage (0.1 V), charge
+// current (0.1 A), discharge current (0.1 A), discharge voltage (0.1 V).
+func DecodeChargeLimits(frame Frame) (ChargeLimits, error) {
+	if err := requireLength(frame, 8); err != nil {
+		return ChargeLimits{}, err
+	}
+	return ChargeLimits{
+		ChargeVoltage:    float64(u16(frame.Data, 0)) / 10,
+		ChargeCurrent:    float64(s16(frame.Data, 2)) / 10,
+		DischargeCurrent: float64(s16(frame.Data, 4)) / 10,
+		DischargeVoltage: float64(u16(frame.Data, 6)) / 10,
+	}, nil
+}
+
+// DecodeSOC decodes frame 0x355: state of charge and state of health, both
+// in whole percent.
+func DecodeSOC(frame Frame) (soc, soh int, err error) {
+	if err := requireLength(frame, 4); err != nil {
+		return 0, 0, err
+	}
+	return int(u16(frame.Data, 0)), int(u16(frame.Data, 2)), nil
+}
+
+// DecodeMeasurements decodes frame 0x356: pack voltage (0.01 V), current
+// (0.1 A, negative while discharging) and temperature (0.1 °C).
+func DecodeMeasurements(frame Frame) (voltage, current, temperature float64, err error) {
+	if err := requireLength(frame, 6); err != nil {
+		return 0, 0, 0, err
+	}
+	voltage = float64(s16(frame.Data, 0)) / 100
+	current = float64(s16(frame.Data, 2)) / 10
+	temperature = float64(s16(frame.Data, 4)) / 10
+	return voltage, current, temperature, nil
+}
+
+// DecodeAlarms decodes frame 0x359: protection flags (bytes 0-1), warning
+// flags (bytes 2-3) and the number of battery modules (byte 4).
+func DecodeAlarms(frame Frame) (Alarms, error) {
+	if err := requireLength(frame, 5); err != nil {
+		return Alarms{}, err
+	}
+	return Alarms{
+		Protection:  u16(frame.Data, 0),
+		Warning:     u16(frame.Data, 2),
+		ModuleCount: int(frame.Data[4]),
+	}, nil
+}
@@ -0,0 +1,114 @@
+package canbms
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeChargeLimits(t *testing.T) {
+	// 56.8V, 100.0A, 150.0A, 47.0V
+	frame := Frame{ID: frameChargeLimits, Data: []byte{0x38, 0x02, 0xE8, 0x03, 0xDC, 0x05, 0xD6, 0x01}}
+
+	limits, err := DecodeChargeLimits(frame)
+	if err != nil {
+		t.Fatalf("DecodeChargeLimits() error = %v", err)
+	}
+
+	want := ChargeLimits{ChargeVoltage: 56.8, ChargeCurrent: 100, DischargeCurrent: 150, DischargeVoltage: 47}
+	if limits != want {
+		t.Errorf("DecodeChargeLimits() = %+v, want %+v", limits, want)
+	}
+}
+
+func TestDecodeSOC(t *testing.T) {
+	soc, soh, err := DecodeSOC(Frame{ID: frameSOC, Data: []byte{0x57, 0x00, 0x63, 0x00}})
+	if err != nil {
+		t.Fatalf("DecodeSOC() error = %v", err)
+	}
+	if soc != 87 || soh != 99 {
+		t.Errorf("DecodeSOC() = %d, %d, want 87, 99", soc, soh)
+	}
+}
+
+func TestDecodeMeasurements(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		voltage     float64
+		current     float64
+		temperature float64
+	}{
+		{
+			name:        "charging",
+			data:        []byte{0x2C, 0x15, 0x7D, 0x00, 0xD2, 0x00}, // 54.20V, 12.5A, 21.0°C
+			voltage:     54.2,
+			current:     12.5,
+			temperature: 21,
+		},
+		{
+			name:        "discharging below freezing",
+			data:        []byte{0xF8, 0x13, 0x83, 0xFF, 0xE2, 0xFF}, // 51.12V, -12.5A, -3.0°C
+			voltage:     51.12,
+			current:     -12.5,
+			temperature: -3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			voltage, current, temperature, err := DecodeMeasurements(Frame{ID: frameMeasurements, Data: tt.data})
+			if err != nil {
+				t.Fatalf("DecodeMeasurements() error = %v", err)
+			}
+			if voltage != tt.voltage || current != tt.current || temperature != tt.temperature {
+				t.Errorf("DecodeMeasurements() = %v, %v, %v, want %v, %v, %v",
+					voltage, current, temperature, tt.voltage, tt.current, tt.temperature)
+			}
+		})
+	}
+}
+
+func TestDecodeAlarms(t *testing.T) {
+	// Protection: cell over voltage + charge over current; warning: cell
+	// under temp; two modules
+	frame := Frame{ID: frameAlarms, Data: []byte{0x02, 0x01, 0x10, 0x00, 0x02, 0x50, 0x4E, 0x00}}
+
+	alarms, err := DecodeAlarms(frame)
+	if err != nil {
+		t.Fatalf("DecodeAlarms() error = %v", err)
+	}
+
+	if alarms.ModuleCount != 2 {
+		t.Errorf("ModuleCount = %d, want 2", alarms.ModuleCount)
+	}
+	if got, want := ActiveFlags(alarms.Protection), []string{"cell-over-voltage", "charge-over-current"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("protection flags = %v, want %v", got, want)
+	}
+	if got, want := ActiveFlags(alarms.Warning), []string{"cell-under-temp"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("warning flags = %v, want %v", got, want)
+	}
+}
+
+func TestActiveFlags_NoneSetIsEmptyNotNil(t *testing.T) {
+	// An empty slice marshals to [] rather than null in the HTTP API
+	if flags := ActiveFlags(0); flags == nil || len(flags) != 0 {
+		t.Errorf("ActiveFlags(0) = %#v, want empty slice", flags)
+	}
+}
+
+func TestDecode_ShortFrames(t *testing.T) {
+	short := Frame{ID: 0x351, Data: []byte{0x01, 0x02}}
+
+	if _, err := DecodeChargeLimits(short); err == nil {
+		t.Error("DecodeChargeLimits() accepted a 2-byte frame")
+	}
+	if _, _, err := DecodeSOC(Frame{ID: frameSOC, Data: []byte{0x01}}); err == nil {
+		t.Error("DecodeSOC() accepted a 1-byte frame")
+	}
+	if _, _, _, err := DecodeMeasurements(Frame{ID: frameMeasurements, Data: []byte{0x01, 0x02, 0x03}}); err == nil {
+		t.Error("DecodeMeasurements() accepted a 3-byte frame")
+	}
+	if _, err := DecodeAlarms(Frame{ID: frameAlarms, Data: []byte{0x01, 0x02, 0x03, 0x04}}); err == nil {
+		t.Error("DecodeAlarms() accepted a 4-byte frame")
+	}
+}
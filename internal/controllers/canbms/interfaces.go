@@ -0,0 +1,30 @@
+package canbms
+
+import (
+	"context"
+)
+
+// FrameReader defines the interface for reading frames from a CAN bus.
+// This abstraction allows for testing without a CAN interface.
+type FrameReader interface {
+	// ReadFrame blocks until a frame arrives, the context is done, or the
+	// bus fails.
+	ReadFrame(ctx context.Context) (Frame, error)
+
+	// Close releases the underlying bus socket.
+	Close() error
+}
+
+// MetricsCollector defines the interface for collecting and exposing metrics.
+// This abstraction allows for testing without the Prometheus global registry.
+type MetricsCollector interface {
+	// IncrementFailures increments the collection failure counter.
+	IncrementFailures()
+
+	// IncrementFrameErrors increments the counter of frames that could not
+	// be read or decoded.
+	IncrementFrameErrors()
+
+	// SetMetrics updates all metrics based on the provided status.
+	SetMetrics(status *BatteryStatus)
+}
@@ -0,0 +1,141 @@
+package canbms
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Metric represents a single metric with its value, unit, and timestamp
+type Metric struct {
+	Name      string
+	Value     any
+	Unit      string
+	Timestamp int64
+}
+
+// MetricPayload is the JSON structure published for each metric
+type MetricPayload struct {
+	Value     any    `json:"value"`
+	Unit      string `json:"unit"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// ToJSON converts a Metric to its JSON representation
+func (m *Metric) ToJSON() (string, error) {
+	payload := MetricPayload{
+		Value:     m.Value,
+		Unit:      m.Unit,
+		Timestamp: m.Timestamp,
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal metric payload: %w", err)
+	}
+
+	return string(b), nil
+}
+
+// ConvertStatusToMetrics converts a BatteryStatus into individual metrics.
+// Alarm flags are published as the raw protection and warning bitmasks - one
+// topic each rather than one per flag - and decoded by name in the HTTP API
+// and Prometheus.
+func ConvertStatusToMetrics(status *BatteryStatus) []Metric {
+	if status == nil {
+		return []Metric{}
+	}
+
+	timestamp := status.Timestamp
+
+	return []Metric{
+		{
+			Name:      "battery-voltage",
+			Value:     status.Voltage,
+			Unit:      "volts",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      "battery-current",
+			Value:     status.Current,
+			Unit:      "amperes",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      "battery-power",
+			Value:     status.Voltage * status.Current,
+			Unit:      "watts",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      "battery-soc",
+			Value:     status.SOC,
+			Unit:      "percent",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      "battery-soh",
+			Value:     status.SOH,
+			Unit:      "percent",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      "battery-temp",
+			Value:     status.Temperature,
+			Unit:      "celsius",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      "charge-voltage-limit",
+			Value:     status.Limits.ChargeVoltage,
+			Unit:      "volts",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      "charge-current-limit",
+			Value:     status.Limits.ChargeCurrent,
+			Unit:      "amperes",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      "discharge-current-limit",
+			Value:     status.Limits.DischargeCurrent,
+			Unit:      "amperes",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      "discharge-voltage-limit",
+			Value:     status.Limits.DischargeVoltage,
+			Unit:      "volts",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      "protection-flags",
+			Value:     status.Alarms.Protection,
+			Unit:      "code",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      "warning-flags",
+			Value:     status.Alarms.Warning,
+			Unit:      "code",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      "module-count",
+			Value:     status.Alarms.ModuleCount,
+			Unit:      "count",
+			Timestamp: timestamp,
+		},
+	}
+}
+
+// CreateCollectionFailureMetric creates a failure metric when collection fails
+func CreateCollectionFailureMetric() Metric {
+	return Metric{
+		Name:      "collection-failure",
+		Value:     1,
+		Unit:      "count",
+		Timestamp: time.Now().Unix(),
+	}
+}
@@ -0,0 +1,80 @@
+package canbms
+
+import (
+	"context"
+	"sync"
+)
+
+// MockFrameReader is a mock implementation of the FrameReader interface for testing.
+// Frames queued in Frames are returned in order; once they run out, ReadFrame
+// blocks until the context is cancelled, as a quiet bus would.
+type MockFrameReader struct {
+	mu sync.Mutex
+
+	Frames    []Frame
+	CloseFunc func() error
+
+	// Call tracking
+	ReadCalls  int
+	CloseCalls int
+}
+
+// Verify MockFrameReader implements FrameReader
+var _ FrameReader = (*MockFrameReader)(nil)
+
+func (m *MockFrameReader) ReadFrame(ctx context.Context) (Frame, error) {
+	m.mu.Lock()
+	m.ReadCalls++
+	if len(m.Frames) > 0 {
+		frame := m.Frames[0]
+		m.Frames = m.Frames[1:]
+		m.mu.Unlock()
+		return frame, nil
+	}
+	m.mu.Unlock()
+
+	<-ctx.Done()
+	return Frame{}, ctx.Err()
+}
+
+func (m *MockFrameReader) Close() error {
+	m.mu.Lock()
+	m.CloseCalls++
+	m.mu.Unlock()
+
+	if m.CloseFunc != nil {
+		return m.CloseFunc()
+	}
+	return nil
+}
+
+// MockMetricsCollector is a mock implementation of the MetricsCollector interface for testing.
+type MockMetricsCollector struct {
+	mu sync.RWMutex
+
+	// Call tracking
+	FailuresCount    int
+	FrameErrorsCount int
+	SetMetricsCalls  []*BatteryStatus
+}
+
+// Verify MockMetricsCollector implements MetricsCollector
+var _ MetricsCollector = (*MockMetricsCollector)(nil)
+
+func (m *MockMetricsCollector) IncrementFailures() {
+	m.mu.Lock()
+	m.FailuresCount++
+	m.mu.Unlock()
+}
+
+func (m *MockMetricsCollector) IncrementFrameErrors() {
+	m.mu.Lock()
+	m.FrameErrorsCount++
+	m.mu.Unlock()
+}
+
+func (m *MockMetricsCollector) SetMetrics(status *BatteryStatus) {
+	m.mu.Lock()
+	m.SetMetricsCalls = append(m.SetMetricsCalls, status)
+	m.mu.Unlock()
+}
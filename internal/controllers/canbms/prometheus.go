@@ -0,0 +1,161 @@
+package canbms
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type PrometheusCollector struct {
+	failures    prometheus.Counter
+	frameErrors prometheus.Counter
+
+	batteryVoltage prometheus.Gauge
+	batteryCurrent prometheus.Gauge
+	batteryPower   prometheus.Gauge
+	batterySoc     prometheus.Gauge
+	batterySoh     prometheus.Gauge
+	batteryTemp    prometheus.Gauge
+
+	chargeVoltageLimit    prometheus.Gauge
+	chargeCurrentLimit    prometheus.Gauge
+	dischargeCurrentLimit prometheus.Gauge
+	dischargeVoltageLimit prometheus.Gauge
+
+	moduleCount prometheus.Gauge
+	alarm       *prometheus.GaugeVec
+}
+
+func NewPrometheusCollector() *PrometheusCollector {
+	endpoint := &PrometheusCollector{
+		failures: promauto.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "read_failures",
+			Help:      "Number of collection cycles without current data from the BMS.",
+		}),
+		frameErrors: promauto.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "frame_errors_total",
+			Help:      "CAN bus read errors and BMS frames that failed to decode.",
+		}),
+	}
+
+	// Initialize all metrics immediately to avoid race conditions
+	endpoint.initializeMetrics()
+
+	return endpoint
+}
+
+func (b *PrometheusCollector) IncrementFailures() {
+	b.failures.Inc()
+}
+
+func (b *PrometheusCollector) IncrementFrameErrors() {
+	b.frameErrors.Inc()
+}
+
+func (b *PrometheusCollector) initializeMetrics() {
+	b.batteryVoltage = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "battery_voltage",
+		Help:      "Battery pack voltage (V).",
+	})
+
+	b.batteryCurrent = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "battery_current",
+		Help:      "Battery pack current (A), positive when charging, negative when discharging.",
+	})
+
+	b.batteryPower = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "battery_power",
+		Help:      "Battery pack power (W), derived from voltage and current.",
+	})
+
+	b.batterySoc = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "battery_soc",
+		Help:      "Battery state of charge (%).",
+	})
+
+	b.batterySoh = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "battery_soh",
+		Help:      "Battery state of health (%).",
+	})
+
+	b.batteryTemp = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "battery_temp",
+		Help:      "Battery temperature (C).",
+	})
+
+	b.chargeVoltageLimit = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "charge_voltage_limit",
+		Help:      "Maximum charge voltage requested by the BMS (V).",
+	})
+
+	b.chargeCurrentLimit = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "charge_current_limit",
+		Help:      "Maximum charge current allowed by the BMS (A).",
+	})
+
+	b.dischargeCurrentLimit = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "discharge_current_limit",
+		Help:      "Maximum discharge current allowed by the BMS (A).",
+	})
+
+	b.dischargeVoltageLimit = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "discharge_voltage_limit",
+		Help:      "Minimum discharge voltage requested by the BMS (V).",
+	})
+
+	b.moduleCount = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "module_count",
+		Help:      "Number of battery modules reported by the BMS.",
+	})
+
+	b.alarm = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "alarm",
+			Help:      "BMS alarm flag, 1 when active, by flag and level (protection or warning).",
+		},
+		[]string{"flag", "level"},
+	)
+}
+
+func (b *PrometheusCollector) SetMetrics(status *BatteryStatus) {
+	b.batteryVoltage.Set(status.Voltage)
+	b.batteryCurrent.Set(status.Current)
+	b.batteryPower.Set(status.Voltage * status.Current)
+	b.batterySoc.Set(float64(status.SOC))
+	b.batterySoh.Set(float64(status.SOH))
+	b.batteryTemp.Set(status.Temperature)
+
+	b.chargeVoltageLimit.Set(status.Limits.ChargeVoltage)
+	b.chargeCurrentLimit.Set(status.Limits.ChargeCurrent)
+	b.dischargeCurrentLimit.Set(status.Limits.DischargeCurrent)
+	b.dischargeVoltageLimit.Set(status.Limits.DischargeVoltage)
+
+	b.moduleCount.Set(float64(status.Alarms.ModuleCount))
+
+	// Every flag is set on every cycle, cleared ones to 0, so a resolved
+	// alarm drops back rather than holding its last active value
+	for _, flag := range alarmFlags {
+		b.alarm.WithLabelValues(flag.name, "protection").Set(flagValue(status.Alarms.Protection, flag.bit))
+		b.alarm.WithLabelValues(flag.name, "warning").Set(flagValue(status.Alarms.Warning, flag.bit))
+	}
+}
+
+func flagValue(bits, flag uint16) float64 {
+	if bits&flag != 0 {
+		return 1
+	}
+	return 0
+}
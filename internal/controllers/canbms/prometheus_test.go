@@ -0,0 +1,58 @@
+package canbms
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Verify PrometheusCollector implements MetricsCollector
+var _ MetricsCollector = (*PrometheusCollector)(nil)
+
+// The collector registers with the global Prometheus registry via promauto,
+// so it can only be created once per test binary.
+func TestPrometheusCollector(t *testing.T) {
+	collector := NewPrometheusCollector()
+
+	status := &BatteryStatus{
+		Voltage: 53.2,
+		Current: -10,
+		SOC:     76,
+		Limits:  ChargeLimits{ChargeVoltage: 56.8, ChargeCurrent: 100},
+		Alarms:  Alarms{Protection: flagCellOverVoltage, Warning: flagCellUnderTemp, ModuleCount: 2},
+	}
+	collector.SetMetrics(status)
+
+	checks := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"battery_voltage", testutil.ToFloat64(collector.batteryVoltage), 53.2},
+		{"battery_power", testutil.ToFloat64(collector.batteryPower), -532},
+		{"battery_soc", testutil.ToFloat64(collector.batterySoc), 76},
+		{"charge_voltage_limit", testutil.ToFloat64(collector.chargeVoltageLimit), 56.8},
+		{"module_count", testutil.ToFloat64(collector.moduleCount), 2},
+		{"alarm cell-over-voltage protection", testutil.ToFloat64(collector.alarm.WithLabelValues("cell-over-voltage", "protection")), 1},
+		{"alarm cell-over-voltage warning", testutil.ToFloat64(collector.alarm.WithLabelValues("cell-over-voltage", "warning")), 0},
+		{"alarm cell-under-temp warning", testutil.ToFloat64(collector.alarm.WithLabelValues("cell-under-temp", "warning")), 1},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+
+	// A cleared flag drops back to 0 on the next cycle
+	status.Alarms.Protection = 0
+	collector.SetMetrics(status)
+	if got := testutil.ToFloat64(collector.alarm.WithLabelValues("cell-over-voltage", "protection")); got != 0 {
+		t.Errorf("cleared protection flag = %v, want 0", got)
+	}
+
+	collector.IncrementFailures()
+	collector.IncrementFrameErrors()
+	if got := testutil.ToFloat64(collector.frameErrors); got != 1 {
+		t.Errorf("frame_errors_total = %v, want 1", got)
+	}
+}
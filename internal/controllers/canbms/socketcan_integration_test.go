@@ -0,0 +1,67 @@
+//go:build integration && linux
+
+package canbms
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// vcanInterface is the virtual CAN interface the test uses. Create it with:
+//
+//	sudo modprobe vcan
+//	sudo ip link add dev vcan0 type vcan
+//	sudo ip link set up vcan0
+const vcanInterface = "vcan0"
+
+func TestSocketCANReader_ReadsFramesFromVCAN(t *testing.T) {
+	iface, err := net.InterfaceByName(vcanInterface)
+	if err != nil {
+		t.Skipf("%s not available: %v", vcanInterface, err)
+	}
+
+	reader, err := NewSocketCANReader(vcanInterface)
+	if err != nil {
+		t.Fatalf("NewSocketCANReader() error = %v", err)
+	}
+	defer reader.Close()
+
+	// A second raw socket plays the part of the BMS
+	writer, err := unix.Socket(unix.AF_CAN, unix.SOCK_RAW, unix.CAN_RAW)
+	if err != nil {
+		t.Fatalf("failed to open writer socket: %v", err)
+	}
+	defer unix.Close(writer)
+	if err := unix.Bind(writer, &unix.SockaddrCAN{Ifindex: iface.Index}); err != nil {
+		t.Fatalf("failed to bind writer socket: %v", err)
+	}
+
+	payload := []byte{0x4C, 0x00, 0x62, 0x00}
+	raw := make([]byte, canFrameSize)
+	binary.NativeEndian.PutUint32(raw[0:4], frameSOC)
+	raw[4] = byte(len(payload))
+	copy(raw[8:], payload)
+	if _, err := unix.Write(writer, raw); err != nil {
+		t.Fatalf("failed to write frame: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	frame, err := reader.ReadFrame(ctx)
+	if err != nil {
+		t.Fatalf("ReadFrame() error = %v", err)
+	}
+	if frame.ID != frameSOC {
+		t.Errorf("frame ID = 0x%03X, want 0x%03X", frame.ID, frameSOC)
+	}
+	soc, soh, err := DecodeSOC(frame)
+	if err != nil || soc != 76 || soh != 98 {
+		t.Errorf("DecodeSOC() = %d, %d, %v, want 76, 98, nil", soc, soh, err)
+	}
+}
@@ -0,0 +1,104 @@
+//go:build linux
+
+package canbms
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// canFrameSize is sizeof(struct can_frame): a 4-byte ID, a length byte,
+	// three bytes of padding and eight data bytes.
+	canFrameSize = 16
+
+	// readPollInterval bounds how long a read blocks before ReadFrame
+	// rechecks its context, so a collector can be stopped promptly.
+	readPollInterval = 500 * time.Millisecond
+)
+
+// SocketCANReader is the production FrameReader backed by a raw SocketCAN
+// socket. It works the same against a real adapter (can0) and a virtual
+// one (vcan0).
+type SocketCANReader struct {
+	fd    int
+	iface string
+}
+
+// Verify SocketCANReader implements FrameReader
+var _ FrameReader = (*SocketCANReader)(nil)
+
+func NewSocketCANReader(iface string) (*SocketCANReader, error) {
+	netIface, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find CAN interface %s: %w: %w", iface, controllers.ErrDeviceUnavailable, err)
+	}
+
+	fd, err := unix.Socket(unix.AF_CAN, unix.SOCK_RAW, unix.CAN_RAW)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CAN socket: %w", err)
+	}
+
+	if err := unix.Bind(fd, &unix.SockaddrCAN{Ifindex: netIface.Index}); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("failed to bind CAN socket to %s: %w", iface, err)
+	}
+
+	timeout := unix.NsecToTimeval(readPollInterval.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &timeout); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("failed to set CAN read timeout: %w", err)
+	}
+
+	return &SocketCANReader{fd: fd, iface: iface}, nil
+}
+
+func (r *SocketCANReader) ReadFrame(ctx context.Context) (Frame, error) {
+	buf := make([]byte, canFrameSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return Frame{}, err
+		}
+
+		n, err := unix.Read(r.fd, buf)
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return Frame{}, fmt.Errorf("failed to read from %s: %w", r.iface, err)
+		}
+		if n < canFrameSize {
+			return Frame{}, fmt.Errorf("short read from %s: %d bytes", r.iface, n)
+		}
+
+		// can_id is in host byte order, with flags in the top three bits
+		rawID := binary.NativeEndian.Uint32(buf[0:4])
+		if rawID&(unix.CAN_RTR_FLAG|unix.CAN_ERR_FLAG) != 0 {
+			continue
+		}
+		id := rawID & unix.CAN_SFF_MASK
+		if rawID&unix.CAN_EFF_FLAG != 0 {
+			id = rawID & unix.CAN_EFF_MASK
+		}
+
+		length := int(buf[4])
+		if length > 8 {
+			length = 8
+		}
+		data := make([]byte, length)
+		copy(data, buf[8:8+length])
+
+		return Frame{ID: id, Data: data}, nil
+	}
+}
+
+func (r *SocketCANReader) Close() error {
+	return unix.Close(r.fd)
+}
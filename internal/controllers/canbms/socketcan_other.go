@@ -0,0 +1,24 @@
+//go:build !linux
+
+package canbms
+
+import (
+	"context"
+	"errors"
+)
+
+// SocketCANReader is unavailable off Linux; SocketCAN is a Linux kernel
+// interface.
+type SocketCANReader struct{}
+
+func NewSocketCANReader(_ string) (*SocketCANReader, error) {
+	return nil, errors.New("SocketCAN is only supported on Linux")
+}
+
+func (r *SocketCANReader) ReadFrame(_ context.Context) (Frame, error) {
+	return Frame{}, errors.New("SocketCAN is only supported on Linux")
+}
+
+func (r *SocketCANReader) Close() error {
+	return nil
+}
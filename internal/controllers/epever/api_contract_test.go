@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lumberbarons/solar-controller/internal/estimator"
 	"github.com/lumberbarons/solar-controller/internal/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -18,7 +19,7 @@ import (
 // checks from the other side, so the two cannot drift apart silently.
 func TestGetHandlersMatchAPIContract(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	configurer := NewConfigurer(fullConfigMockClient(), &MockMetricsCollector{})
+	configurer := NewConfigurer(fullConfigMockClient(), &MockMetricsCollector{}, standardReadSpan)
 
 	router := gin.New()
 	router.GET("/api/epever/battery-profile", configurer.BatteryProfileGet())
@@ -49,15 +50,50 @@ func TestGetHandlersMatchAPIContract(t *testing.T) {
 	}
 }
 
-// MetricsGet serialises *ControllerStatus directly, so the struct's json tags
-// are the wire contract for that endpoint.
+// MetricsGet serialises *ControllerStatus with a health object alongside, so
+// the struct's json tags plus "health" are the wire contract for that
+// endpoint.
 func TestMetricsPayloadMatchesAPIContract(t *testing.T) {
-	payload, err := json.Marshal(ControllerStatus{})
-	require.NoError(t, err)
+	gin.SetMode(gin.TestMode)
+	mockClient := &MockModbusClient{}
+	controller := newControllerForTest(mockClient, NewCollector(mockClient, &MockMetricsCollector{}, standardReadSpan), nil,
+		&testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "test-device-1")
+	controller.last.Set(&ControllerStatus{})
+
+	router := gin.New()
+	router.GET("/api/epever/metrics", controller.MetricsGet())
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/epever/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
 
 	assert.Equal(t,
 		testutil.APIContractFields(t, "GET /api/epever/metrics"),
+		testutil.JSONFieldNames(t, recorder.Body.Bytes()),
+		"GET /api/epever/metrics no longer returns the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
+
+// The runtime estimate is the shared estimator's, as Voltgo's is.
+func TestRuntimePayloadMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(estimator.Estimate{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/epever/metrics#runtime"),
+		testutil.JSONFieldNames(t, payload),
+		"estimator.Estimate no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
+
+// ConnectionGet serialises ConnectionStatus directly. The optional fields are
+// pointers without omitempty, so every key is present whatever the state.
+func TestConnectionStatusMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(ConnectionStatus{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/epever/connection"),
 		testutil.JSONFieldNames(t, payload),
-		"ControllerStatus no longer marshals to the fields docs/api-contract.json "+
+		"ConnectionStatus no longer marshals to the fields docs/api-contract.json "+
 			"records; update the contract and site/src/api/types.ts together")
 }
@@ -2,218 +2,396 @@ package epever
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/avast/retry-go/v4"
+	"github.com/lumberbarons/solar-controller/internal/controllers"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/lumberbarons/modbus"
 )
 
+// ConnectionState is whether the serial port is open.
+type ConnectionState string
+
+const (
+	ConnectionConnected    ConnectionState = "connected"
+	ConnectionDisconnected ConnectionState = "disconnected"
+)
+
+// ErrDisconnected is returned without touching the port while it is closed
+// and waiting for its next reconnect attempt.
+var ErrDisconnected = errors.New("epever serial port disconnected")
+
+const (
+	minReconnectBackoff = 1 * time.Second
+	maxReconnectBackoff = 60 * time.Second
+)
+
+// serialByIDDir holds udev's stable symlinks for USB serial adapters.
+var serialByIDDir = "/dev/serial/by-id"
+
+// ConnectionStatus reports the serial link for GET /api/epever/connection.
+type ConnectionStatus struct {
+	State ConnectionState `json:"state"`
+
+	// SerialPort is the configured path, Path is the one opened (a
+	// /dev/serial/by-id link when one exists), and Device is the tty it
+	// currently resolves to.
+	SerialPort string `json:"serialPort"`
+	Path       string `json:"path"`
+	Device     string `json:"device"`
+
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	Reconnects          int        `json:"reconnects"`
+	LastError           string     `json:"lastError"`
+	ConnectedSince      *time.Time `json:"connectedSince"`
+	NextAttempt         *time.Time `json:"nextAttempt"`
+
+	// InterFrameDelay is the gap between requests in seconds. It is the
+	// configured value unless adaptive pacing has moved it.
+	InterFrameDelay float64 `json:"interFrameDelay"`
+}
+
+// SerialModbusClient talks to the controller over an RS-485 adapter. When the
+// adapter is unplugged or re-enumerates, it closes the dead port and reopens it
+// with exponential backoff, so collection resumes without a restart. Every
+// operation is paced according to its Timing, so callers need not sleep
+// between requests.
 type SerialModbusClient struct {
-	handler *modbus.RTUClientHandler
-	client  modbus.Client
-	lock    sync.Mutex
+	serialPort string
+	handler    *modbus.RTUClientHandler
+	client     modbus.Client
+	lock       sync.Mutex
+	timing     Timing
+	pacer      *pacer
+
+	// open and now are replaced in tests
+	open func(path string) (*modbus.RTUClientHandler, error)
+	now  func() time.Time
+
+	state               ConnectionState
+	path                string
+	device              string
+	consecutiveFailures int
+	reconnects          int
+	lastError           string
+	connectedSince      time.Time
+	backoff             time.Duration
+	nextAttempt         time.Time
 }
 
-const retryAttempts = 2
-const retryDelay = 2 * time.Second
-const perReadTimeout = 5 * time.Second
+func NewSerialModbusClient(serialPort string, timing Timing) (*SerialModbusClient, error) {
+	c := &SerialModbusClient{
+		serialPort: serialPort,
+		timing:     timing,
+		pacer:      newPacer(timing, time.Now),
+		open: func(path string) (*modbus.RTUClientHandler, error) {
+			return openRTUHandler(path, timing)
+		},
+		now: time.Now,
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.connect(); err != nil {
+		if portGone(err) {
+			return nil, fmt.Errorf("failed to connect to epever: %w: %w", controllers.ErrDeviceUnavailable, err)
+		}
+		return nil, fmt.Errorf("failed to connect to epever: %w", err)
+	}
+
+	return c, nil
+}
 
-func NewSerialModbusClient(serialPort string) (*SerialModbusClient, error) {
-	handler := modbus.NewRTUClientHandler(serialPort)
+func openRTUHandler(path string, timing Timing) (*modbus.RTUClientHandler, error) {
+	handler := modbus.NewRTUClientHandler(path)
 
-	handler.BaudRate = 115200
+	handler.BaudRate = timing.BaudRate
 	handler.DataBits = 8
-	handler.Parity = modbus.NoParity
+	handler.Parity = timing.Parity
 	handler.StopBits = 1
 	handler.SlaveID = 1
-	handler.Timeout = 5 * time.Second
+	handler.Timeout = timing.ResponseTimeout
 
-	err := handler.Connect()
+	if err := handler.Connect(); err != nil {
+		return nil, err
+	}
+	return handler, nil
+}
 
+// resolveSerialPort returns the path to open for a configured port and the
+// tty it points at. A /dev/ttyUSBn name can change when the adapter
+// re-enumerates, so when udev has a by-id link to the same tty, that link is
+// opened instead and reconnects follow the adapter rather than the number.
+func resolveSerialPort(serialPort string) (path, device string) {
+	device, err := filepath.EvalSymlinks(serialPort)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to epever: %w", err)
+		return serialPort, serialPort
+	}
+	if filepath.Dir(serialPort) == serialByIDDir {
+		return serialPort, device
 	}
 
-	client := modbus.NewClient(handler)
+	links, err := os.ReadDir(serialByIDDir)
+	if err != nil {
+		return serialPort, device
+	}
+	for _, link := range links {
+		candidate := filepath.Join(serialByIDDir, link.Name())
+		if target, err := filepath.EvalSymlinks(candidate); err == nil && target == device {
+			return candidate, device
+		}
+	}
+	return serialPort, device
+}
 
-	return &SerialModbusClient{handler: handler, client: client}, nil
+// connect opens the port. The caller must hold the lock.
+func (c *SerialModbusClient) connect() error {
+	path, device := resolveSerialPort(c.serialPort)
+	handler, err := c.open(path)
+	if err != nil {
+		return err
+	}
+
+	if path != c.serialPort {
+		log.Infof("epever serial port %s opened as %s", c.serialPort, path)
+	}
+
+	c.handler = handler
+	c.client = modbus.NewClient(handler)
+	c.state = ConnectionConnected
+	c.path = path
+	c.device = device
+	c.consecutiveFailures = 0
+	c.connectedSince = c.now()
+	c.backoff = 0
+	c.nextAttempt = time.Time{}
+	return nil
 }
 
-func (c *SerialModbusClient) Close() {
+// disconnect closes a port that has stopped working and schedules the first
+// reconnect attempt. The caller must hold the lock.
+func (c *SerialModbusClient) disconnect(cause error) {
+	log.Errorf("epever serial port %s has gone, closing it: %v", c.device, cause)
+
 	if c.handler != nil {
-		c.handler.Close()
+		_ = c.handler.Close()
 	}
+	c.handler = nil
+	c.client = nil
+	c.state = ConnectionDisconnected
+	c.backoff = minReconnectBackoff
+	c.nextAttempt = c.now().Add(c.backoff)
 }
 
-func (c *SerialModbusClient) ReadInputRegisters(ctx context.Context, address, quantity uint16) ([]byte, error) {
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
-	// Create a timeout context for this specific read operation
-	readCtx, cancel := context.WithTimeout(ctx, perReadTimeout)
-	defer cancel()
+// ensureConnected reopens a closed port once its backoff has passed. The
+// caller must hold the lock.
+func (c *SerialModbusClient) ensureConnected() error {
+	if c.state == ConnectionConnected {
+		return nil
+	}
 
-	var value []byte
+	now := c.now()
+	if now.Before(c.nextAttempt) {
+		return fmt.Errorf("%w: next reconnect attempt in %s", ErrDisconnected, c.nextAttempt.Sub(now).Round(time.Millisecond))
+	}
 
-	err := retry.Do(
-		func() error {
-			var retryErr error
-			value, retryErr = c.client.ReadInputRegisters(readCtx, address, quantity)
-			return retryErr
-		},
-		retry.Attempts(retryAttempts),
-		retry.Delay(retryDelay),
-		retry.OnRetry(func(n uint, err error) {
-			log.Warnf("ReadInputRegisters address %d retry #%d: %s\n", address, n, err)
-		}),
-		retry.Context(readCtx),
-	)
+	if err := c.connect(); err != nil {
+		c.lastError = err.Error()
+		c.backoff = min(c.backoff*2, maxReconnectBackoff)
+		c.nextAttempt = now.Add(c.backoff)
+		log.Warnf("failed to reopen epever serial port %s, retrying in %s: %v", c.serialPort, c.backoff, err)
+		return fmt.Errorf("%w: %w", ErrDisconnected, err)
+	}
 
-	return value, err
+	c.reconnects++
+	log.Infof("reconnected to epever on %s", c.device)
+	return nil
 }
 
-func (c *SerialModbusClient) ReadHoldingRegisters(ctx context.Context, address, quantity uint16) ([]byte, error) {
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
-	// Create a timeout context for this specific read operation
-	readCtx, cancel := context.WithTimeout(ctx, perReadTimeout)
-	defer cancel()
+// recordResult updates the failure count after an operation and closes the
+// port when the error says the adapter has gone. Timeouts alone leave it
+// open: an unpowered charge controller times out on a working adapter, and
+// reopening would not bring it back. It reports whether the operation counted
+// as a failure. The caller must hold the lock.
+func (c *SerialModbusClient) recordResult(err error) bool {
+	var exception *modbus.ModbusError
+	switch {
+	case err == nil, errors.As(err, &exception):
+		// An exception response proves the link works
+		c.consecutiveFailures = 0
+		return false
+	case errors.Is(err, context.Canceled):
+		// The caller gave up; that says nothing about the port
+		return false
+	}
 
-	var value []byte
+	c.consecutiveFailures++
+	c.lastError = err.Error()
 
-	err := retry.Do(
-		func() error {
-			var retryErr error
-			value, retryErr = c.client.ReadHoldingRegisters(readCtx, address, quantity)
-			return retryErr
-		},
-		retry.Attempts(retryAttempts),
-		retry.Delay(retryDelay),
-		retry.OnRetry(func(n uint, err error) {
-			log.Warnf("ReadHoldingRegisters address %d retry #%d: %s\n", address, n, err)
-		}),
-		retry.Context(readCtx),
-	)
+	if portGone(err) {
+		c.disconnect(err)
+	}
+	return true
+}
 
-	return value, err
+// portGone reports whether err means the adapter itself has gone away, as
+// opposed to the controller not answering on it.
+func portGone(err error) bool {
+	for _, target := range []error{syscall.EIO, syscall.ENXIO, syscall.ENODEV, syscall.EBADF, os.ErrNotExist, os.ErrClosed} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
 }
 
-func (c *SerialModbusClient) ReadCoils(ctx context.Context, address, quantity uint16) ([]byte, error) {
+// do runs one Modbus operation with retries, reconnecting first if the port
+// is closed and waiting out the gap the previous operation needs. written is
+// the number of registers or coils the operation writes, 0 for a read. A
+// non-zero timeout bounds the operation and its retries, starting once the
+// gap has been waited out; fn gets the context that carries it.
+func (c *SerialModbusClient) do(ctx context.Context, operation string, address, written uint16, timeout time.Duration, fn func(ctx context.Context, client modbus.Client) error) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	var value []byte
+	if err := c.ensureConnected(); err != nil {
+		return err
+	}
+	if err := c.pacer.wait(ctx, written > 0); err != nil {
+		return err
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
 	err := retry.Do(
 		func() error {
-			var retryErr error
-			value, retryErr = c.client.ReadCoils(ctx, address, quantity)
-			return retryErr
+			return fn(ctx, c.client)
 		},
-		retry.Attempts(retryAttempts),
-		retry.Delay(retryDelay),
+		retry.Attempts(uint(c.timing.RetryAttempts)),
+		retry.Delay(c.timing.RetryDelay),
+		retry.RetryIf(func(err error) bool {
+			return !portGone(err)
+		}),
 		retry.OnRetry(func(n uint, err error) {
-			log.Warnf("ReadCoils address %d retry #%d: %s\n", address, n, err)
+			log.Warnf("%s address %d retry #%d: %s\n", operation, address, n, err)
 		}),
 		retry.Context(ctx),
 	)
 
-	return value, err
+	c.pacer.done(written, c.recordResult(err))
+	return err
 }
 
-func (c *SerialModbusClient) ReadDiscreteInputs(ctx context.Context, address, quantity uint16) ([]byte, error) {
+// ConnectionStatus reports the state of the serial link.
+func (c *SerialModbusClient) ConnectionStatus() ConnectionStatus {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	var value []byte
-
-	err := retry.Do(
-		func() error {
-			var retryErr error
-			value, retryErr = c.client.ReadDiscreteInputs(ctx, address, quantity)
-			return retryErr
-		},
-		retry.Attempts(retryAttempts),
-		retry.Delay(retryDelay),
-		retry.OnRetry(func(n uint, err error) {
-			log.Warnf("ReadDiscreteInputs address %d retry #%d: %s\n", address, n, err)
-		}),
-		retry.Context(ctx),
-	)
-
-	return value, err
+	status := ConnectionStatus{
+		State:               c.state,
+		SerialPort:          c.serialPort,
+		Path:                c.path,
+		Device:              c.device,
+		ConsecutiveFailures: c.consecutiveFailures,
+		Reconnects:          c.reconnects,
+		LastError:           c.lastError,
+		InterFrameDelay:     c.pacer.currentDelay().Seconds(),
+	}
+	if c.state == ConnectionConnected {
+		since := c.connectedSince
+		status.ConnectedSince = &since
+	} else {
+		next := c.nextAttempt
+		status.NextAttempt = &next
+	}
+	return status
 }
 
-func (c *SerialModbusClient) WriteSingleRegister(ctx context.Context, address, value uint16) (results []byte, err error) {
+func (c *SerialModbusClient) Close() {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	var result []byte
+	if c.handler != nil {
+		c.handler.Close()
+	}
+}
 
-	err = retry.Do(
-		func() error {
-			var retryErr error
-			result, retryErr = c.client.WriteSingleRegister(ctx, address, value)
-			return retryErr
-		},
-		retry.Attempts(retryAttempts),
-		retry.Delay(retryDelay),
-		retry.OnRetry(func(n uint, err error) {
-			log.Warnf("WriteSingleRegister address %d retry #%d: %s\n", address, n, err)
-		}),
-		retry.Context(ctx),
-	)
+func (c *SerialModbusClient) ReadInputRegisters(ctx context.Context, address, quantity uint16) ([]byte, error) {
+	var value []byte
+	err := c.do(ctx, "ReadInputRegisters", address, 0, c.timing.ReadTimeout, func(ctx context.Context, client modbus.Client) error {
+		var err error
+		value, err = client.ReadInputRegisters(ctx, address, quantity)
+		return err
+	})
+	return value, err
+}
 
-	return result, err
+func (c *SerialModbusClient) ReadHoldingRegisters(ctx context.Context, address, quantity uint16) ([]byte, error) {
+	var value []byte
+	err := c.do(ctx, "ReadHoldingRegisters", address, 0, c.timing.ReadTimeout, func(ctx context.Context, client modbus.Client) error {
+		var err error
+		value, err = client.ReadHoldingRegisters(ctx, address, quantity)
+		return err
+	})
+	return value, err
 }
 
-func (c *SerialModbusClient) WriteMultipleRegisters(ctx context.Context, address, quantity uint16, value []byte) (results []byte, err error) {
-	c.lock.Lock()
-	defer c.lock.Unlock()
+func (c *SerialModbusClient) ReadCoils(ctx context.Context, address, quantity uint16) ([]byte, error) {
+	var value []byte
+	err := c.do(ctx, "ReadCoils", address, 0, 0, func(ctx context.Context, client modbus.Client) error {
+		var err error
+		value, err = client.ReadCoils(ctx, address, quantity)
+		return err
+	})
+	return value, err
+}
 
-	var result []byte
+func (c *SerialModbusClient) ReadDiscreteInputs(ctx context.Context, address, quantity uint16) ([]byte, error) {
+	var value []byte
+	err := c.do(ctx, "ReadDiscreteInputs", address, 0, 0, func(ctx context.Context, client modbus.Client) error {
+		var err error
+		value, err = client.ReadDiscreteInputs(ctx, address, quantity)
+		return err
+	})
+	return value, err
+}
 
-	err = retry.Do(
-		func() error {
-			var retryErr error
-			result, retryErr = c.client.WriteMultipleRegisters(ctx, address, quantity, value)
-			return retryErr
-		},
-		retry.Attempts(retryAttempts),
-		retry.Delay(retryDelay),
-		retry.OnRetry(func(n uint, err error) {
-			log.Warnf("WriteMultipleRegisters address %d retry #%d: %s\n", address, n, err)
-		}),
-		retry.Context(ctx),
-	)
+func (c *SerialModbusClient) WriteSingleRegister(ctx context.Context, address, value uint16) (results []byte, err error) {
+	err = c.do(ctx, "WriteSingleRegister", address, 1, 0, func(ctx context.Context, client modbus.Client) error {
+		var err error
+		results, err = client.WriteSingleRegister(ctx, address, value)
+		return err
+	})
+	return results, err
+}
 
-	return result, err
+func (c *SerialModbusClient) WriteMultipleRegisters(ctx context.Context, address, quantity uint16, value []byte) (results []byte, err error) {
+	err = c.do(ctx, "WriteMultipleRegisters", address, quantity, 0, func(ctx context.Context, client modbus.Client) error {
+		var err error
+		results, err = client.WriteMultipleRegisters(ctx, address, quantity, value)
+		return err
+	})
+	return results, err
 }
 
 func (c *SerialModbusClient) WriteSingleCoil(ctx context.Context, address, value uint16) (results []byte, err error) {
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
-	var result []byte
-
-	err = retry.Do(
-		func() error {
-			var retryErr error
-			result, retryErr = c.client.WriteSingleCoil(ctx, address, value)
-			return retryErr
-		},
-		retry.Attempts(retryAttempts),
-		retry.Delay(retryDelay),
-		retry.OnRetry(func(n uint, err error) {
-			log.Warnf("WriteSingleCoil address %d retry #%d: %s\n", address, n, err)
-		}),
-		retry.Context(ctx),
-	)
-
-	return result, err
+	err = c.do(ctx, "WriteSingleCoil", address, 1, 0, func(ctx context.Context, client modbus.Client) error {
+		var err error
+		results, err = client.WriteSingleCoil(ctx, address, value)
+		return err
+	})
+	return results, err
 }
@@ -0,0 +1,377 @@
+package epever
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"github.com/lumberbarons/solar-controller/internal/simulator"
+
+	"github.com/lumberbarons/modbus"
+)
+
+// fakeClock is a settable time source for backoff tests.
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time { return f.now }
+
+func (f *fakeClock) Advance(d time.Duration) { f.now = f.now.Add(d) }
+
+// useByIDDir points serialByIDDir at a temporary directory for one test.
+func useByIDDir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	previous := serialByIDDir
+	serialByIDDir = dir
+	t.Cleanup(func() { serialByIDDir = previous })
+	return dir
+}
+
+func TestResolveSerialPort(t *testing.T) {
+	byID := useByIDDir(t)
+
+	devDir := t.TempDir()
+	tty := filepath.Join(devDir, "ttyUSB0")
+	if err := os.WriteFile(tty, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(devDir, "ttyUSB1")
+	if err := os.WriteFile(other, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(byID, "usb-FTDI_USB-RS485_A10KBQ3X-if00-port0")
+	if err := os.Symlink(tty, link); err != nil {
+		t.Fatal(err)
+	}
+	// EvalSymlinks canonicalises the temp dir, which may itself be a link
+	resolvedTTY, err := filepath.EvalSymlinks(tty)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		serialPort string
+		wantPath   string
+		wantDevice string
+	}{
+		{name: "tty with a by-id link opens the link", serialPort: tty, wantPath: link, wantDevice: resolvedTTY},
+		{name: "by-id link is opened as configured", serialPort: link, wantPath: link, wantDevice: resolvedTTY},
+		{name: "tty without a by-id link", serialPort: other, wantPath: other},
+		{name: "missing path is left alone", serialPort: "/dev/ttyMISSING", wantPath: "/dev/ttyMISSING", wantDevice: "/dev/ttyMISSING"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, device := resolveSerialPort(tt.serialPort)
+			if path != tt.wantPath {
+				t.Errorf("path = %q, want %q", path, tt.wantPath)
+			}
+			if tt.wantDevice != "" && device != tt.wantDevice {
+				t.Errorf("device = %q, want %q", device, tt.wantDevice)
+			}
+		})
+	}
+}
+
+func TestResolveSerialPort_NoByIDDir(t *testing.T) {
+	previous := serialByIDDir
+	serialByIDDir = filepath.Join(t.TempDir(), "missing")
+	defer func() { serialByIDDir = previous }()
+
+	tty := filepath.Join(t.TempDir(), "ttyUSB0")
+	if err := os.WriteFile(tty, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if path, _ := resolveSerialPort(tty); path != tty {
+		t.Errorf("path = %q, want %q", path, tty)
+	}
+}
+
+// newStateTestClient returns a connected client whose port is never used, for
+// exercising the reconnect state machine directly.
+func newStateTestClient(t *testing.T, open func(string) (*modbus.RTUClientHandler, error)) (*SerialModbusClient, *fakeClock) {
+	t.Helper()
+	useByIDDir(t)
+
+	clock := &fakeClock{now: time.Date(2025, 6, 21, 12, 0, 0, 0, time.UTC)}
+	c := &SerialModbusClient{
+		serialPort: "/dev/ttyTEST",
+		timing:     Timing{RetryAttempts: 1},
+		pacer:      newPacer(Timing{}, time.Now),
+		open:       open,
+		now:        clock.Now,
+	}
+	if err := c.connect(); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	return c, clock
+}
+
+func unopenedHandler(path string) (*modbus.RTUClientHandler, error) {
+	return modbus.NewRTUClientHandler(path), nil
+}
+
+func TestSerialModbusClient_RecordResult(t *testing.T) {
+	timeout := fmt.Errorf("reading response: %w", modbus.ErrTimeout)
+
+	tests := []struct {
+		name             string
+		errs             []error
+		wantState        ConnectionState
+		wantFailuresLeft int
+	}{
+		{
+			name:             "isolated timeouts keep the port open",
+			errs:             []error{timeout, timeout},
+			wantState:        ConnectionConnected,
+			wantFailuresLeft: 2,
+		},
+		{
+			name:             "persistent timeouts keep the port open",
+			errs:             []error{timeout, timeout, timeout, timeout, timeout},
+			wantState:        ConnectionConnected,
+			wantFailuresLeft: 5,
+		},
+		{
+			name:             "a success resets the count",
+			errs:             []error{timeout, timeout, nil, timeout},
+			wantState:        ConnectionConnected,
+			wantFailuresLeft: 1,
+		},
+		{
+			name:             "an exception response proves the link works",
+			errs:             []error{timeout, timeout, &modbus.ModbusError{FunctionCode: 0x84, ExceptionCode: 0x02}},
+			wantState:        ConnectionConnected,
+			wantFailuresLeft: 0,
+		},
+		{
+			name:             "cancellation by the caller is not counted",
+			errs:             []error{timeout, timeout, context.Canceled},
+			wantState:        ConnectionConnected,
+			wantFailuresLeft: 2,
+		},
+		{
+			name:      "an I/O error closes the port at once",
+			errs:      []error{fmt.Errorf("writing request: %w", syscall.EIO)},
+			wantState: ConnectionDisconnected,
+		},
+		{
+			name:      "a vanished device closes the port at once",
+			errs:      []error{&os.PathError{Op: "open", Path: "/dev/ttyUSB0", Err: syscall.ENODEV}},
+			wantState: ConnectionDisconnected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newStateTestClient(t, unopenedHandler)
+
+			for _, err := range tt.errs {
+				c.recordResult(err)
+			}
+
+			status := c.ConnectionStatus()
+			if status.State != tt.wantState {
+				t.Errorf("state = %s, want %s", status.State, tt.wantState)
+			}
+			if tt.wantState == ConnectionConnected && status.ConsecutiveFailures != tt.wantFailuresLeft {
+				t.Errorf("consecutive failures = %d, want %d", status.ConsecutiveFailures, tt.wantFailuresLeft)
+			}
+		})
+	}
+}
+
+// The wait after a write can be longer than a read's timeout, which only
+// starts once the bus is clear.
+func TestSerialModbusClient_ReadTimeoutStartsAfterThePacer(t *testing.T) {
+	c, _ := newStateTestClient(t, unopenedHandler)
+	c.pacer = newPacer(Timing{CommitDelay: 100 * time.Millisecond}, time.Now)
+	c.pacer.done(2, false)
+
+	const timeout = 50 * time.Millisecond
+	err := c.do(context.Background(), "ReadInputRegisters", 0x3100, 0, timeout, func(ctx context.Context, _ modbus.Client) error {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return errors.New("the operation has no deadline")
+		}
+		if left := time.Until(deadline); left < timeout/2 {
+			return fmt.Errorf("%s of the timeout left once the pacer let the read start", left)
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Errorf("do() error = %v", err)
+	}
+}
+
+func TestSerialModbusClient_ReconnectBackoff(t *testing.T) {
+	openErr := errors.New("no such device")
+	failing := false
+	opens := 0
+	c, clock := newStateTestClient(t, func(path string) (*modbus.RTUClientHandler, error) {
+		opens++
+		if failing {
+			return nil, openErr
+		}
+		return modbus.NewRTUClientHandler(path), nil
+	})
+	failing, opens = true, 0
+
+	c.recordResult(syscall.EIO)
+
+	// Within the backoff the port is not touched
+	err := c.ensureConnected()
+	if !errors.Is(err, ErrDisconnected) {
+		t.Fatalf("err = %v, want ErrDisconnected", err)
+	}
+	if opens != 0 {
+		t.Errorf("port opened %d times during backoff, want 0", opens)
+	}
+
+	// Each failed attempt doubles the wait, up to the cap
+	wantBackoffs := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 32 * time.Second, 60 * time.Second, 60 * time.Second}
+	for i, want := range wantBackoffs {
+		clock.Advance(c.nextAttempt.Sub(clock.now))
+		err := c.ensureConnected()
+		if !errors.Is(err, ErrDisconnected) || !errors.Is(err, openErr) {
+			t.Fatalf("attempt %d: err = %v, want ErrDisconnected wrapping the open error", i+1, err)
+		}
+		if c.backoff != want {
+			t.Errorf("attempt %d: backoff = %s, want %s", i+1, c.backoff, want)
+		}
+	}
+	if opens != len(wantBackoffs) {
+		t.Errorf("port opened %d times, want %d", opens, len(wantBackoffs))
+	}
+
+	status := c.ConnectionStatus()
+	if status.NextAttempt == nil || !status.NextAttempt.Equal(clock.now.Add(60*time.Second)) {
+		t.Errorf("next attempt = %v, want %v", status.NextAttempt, clock.now.Add(60*time.Second))
+	}
+	if status.LastError != openErr.Error() {
+		t.Errorf("last error = %q, want %q", status.LastError, openErr.Error())
+	}
+
+	// Once the device is back, the next attempt reconnects and resets the backoff
+	failing = false
+	clock.Advance(time.Minute)
+	if err := c.ensureConnected(); err != nil {
+		t.Fatalf("reconnect failed: %v", err)
+	}
+
+	status = c.ConnectionStatus()
+	if status.State != ConnectionConnected {
+		t.Errorf("state = %s, want connected", status.State)
+	}
+	if status.Reconnects != 1 {
+		t.Errorf("reconnects = %d, want 1", status.Reconnects)
+	}
+	if status.ConnectedSince == nil || !status.ConnectedSince.Equal(clock.now) {
+		t.Errorf("connected since = %v, want %v", status.ConnectedSince, clock.now)
+	}
+	if c.backoff != 0 {
+		t.Errorf("backoff = %s after reconnecting, want 0", c.backoff)
+	}
+}
+
+// startRTUSimulator serves the epever register map on a fresh pty.
+func startRTUSimulator(t *testing.T, regs *simulator.RegisterMap) *simulator.RTUServer {
+	t.Helper()
+
+	server, err := simulator.NewRTUServer(regs, 1)
+	if err != nil {
+		t.Skipf("pty unavailable: %v", err)
+	}
+	go func() { _ = server.Serve() }()
+	t.Cleanup(func() { _ = server.Close() })
+	return server
+}
+
+// A port that is not there is a missing device, which the application waits
+// for, rather than a configuration error.
+func TestNewSerialModbusClient_MissingPort(t *testing.T) {
+	useByIDDir(t)
+
+	_, err := NewSerialModbusClient(filepath.Join(t.TempDir(), "ttyUSB0"), testTiming())
+	if !errors.Is(err, controllers.ErrDeviceUnavailable) {
+		t.Errorf("NewSerialModbusClient() error = %v, want ErrDeviceUnavailable", err)
+	}
+}
+
+// An adapter that is unplugged and comes back as a different tty is followed
+// through its by-id link, without restarting the client.
+func TestSerialModbusClient_ReconnectsAfterReenumeration(t *testing.T) {
+	byID := useByIDDir(t)
+
+	regs, err := simulator.LoadRegisterMap(filepath.Join("..", "..", "..", "testdata", "simulator", "epever.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := startRTUSimulator(t, regs)
+	link := filepath.Join(byID, "usb-EPEVER_RS485-if00")
+	if err := os.Symlink(first.DevicePath(), link); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewSerialModbusClient(first.DevicePath(), testTiming())
+	if err != nil {
+		t.Fatalf("NewSerialModbusClient failed: %v", err)
+	}
+	defer client.Close()
+	clock := &fakeClock{now: time.Now()}
+	client.now = clock.Now
+
+	if status := client.ConnectionStatus(); status.Path != link {
+		t.Errorf("opened %q, want the by-id link %q", status.Path, link)
+	}
+	if _, err := client.ReadInputRegisters(context.Background(), regBatterySOC, 1); err != nil {
+		t.Fatalf("read before unplug failed: %v", err)
+	}
+
+	// Unplug: the tty goes away and reads fail
+	_ = first.Close()
+	if _, err := client.ReadInputRegisters(context.Background(), regBatterySOC, 1); err == nil {
+		t.Fatal("read after unplug succeeded")
+	}
+	if status := client.ConnectionStatus(); status.State != ConnectionDisconnected {
+		t.Fatalf("state = %s after unplug, want disconnected", status.State)
+	}
+
+	// Replug as a new tty; udev moves the by-id link
+	second := startRTUSimulator(t, regs)
+	if err := os.Remove(link); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(second.DevicePath(), link); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(maxReconnectBackoff)
+	data, err := client.ReadInputRegisters(context.Background(), regBatterySOC, 1)
+	if err != nil {
+		t.Fatalf("read after replug failed: %v", err)
+	}
+	if len(data) != 2 || data[1] != 80 {
+		t.Errorf("SOC = % x, want 00 50", data)
+	}
+
+	status := client.ConnectionStatus()
+	if status.State != ConnectionConnected || status.Reconnects != 1 {
+		t.Errorf("state = %s with %d reconnects, want connected with 1", status.State, status.Reconnects)
+	}
+	if want, _ := filepath.EvalSymlinks(second.DevicePath()); status.Device != want {
+		t.Errorf("device = %q, want %q", status.Device, want)
+	}
+}
@@ -2,10 +2,13 @@ package epever
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/lumberbarons/solar-controller/internal/controllers/epever/parser"
+	"github.com/lumberbarons/solar-controller/internal/estimator"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -23,6 +26,7 @@ const (
 	regBatterySOC           = 0x311A
 	regControllerStatus     = 0x3201
 	regEnergyGeneratedDaily = 0x330C
+	regBatteryCurrent       = 0x331B
 )
 
 // Charging status bit mask
@@ -34,163 +38,207 @@ const (
 type Collector struct {
 	modbusClient        ModbusClient
 	prometheusCollector MetricsCollector
+	planner             *readPlanner
+
+	// last is the previous status, whose values stand in for fields that
+	// fail to read
+	mu   sync.Mutex
+	last *ControllerStatus
+}
+
+// statusRegisters are the input registers one collection reads. The planner
+// serves the first three with a single read of 0x3100-0x3111.
+var statusRegisters = []registerRange{
+	{regArrayVoltage, 4},       // array voltage, current and 32-bit power
+	{regBatteryVoltage, 4},     // battery voltage, charging current and 32-bit power
+	{regBatteryTemperature, 2}, // battery and device temperature
+	{regBatterySOC, 1},
+	{regControllerStatus, 1},
+	{regEnergyGeneratedDaily, 2},
+	{regBatteryCurrent, 2},
 }
 
+// Quality says how far a ControllerStatus field can be trusted.
+type Quality string
+
+const (
+	// QualityGood is a value read in this collection
+	QualityGood Quality = "good"
+	// QualityStale is a value carried over from an earlier collection
+	// because its registers failed to read in this one
+	QualityStale Quality = "stale"
+	// QualityFailed is a field that has never been read; its value is zero
+	QualityFailed Quality = "failed"
+)
+
 type ControllerStatus struct {
-	Timestamp            int64   `json:"timestamp"`
-	CollectionTime       float64 `json:"collectionTime"`
-	ArrayVoltage         float32 `json:"arrayVoltage"`
-	ArrayCurrent         float32 `json:"arrayCurrent"`
-	ArrayPower           float32 `json:"arrayPower"`
-	ChargingCurrent      float32 `json:"chargingCurrent"`
-	ChargingPower        float32 `json:"chargingPower"`
-	BatteryVoltage       float32 `json:"batteryVoltage"`
+	Timestamp       int64   `json:"timestamp"`
+	CollectionTime  float64 `json:"collectionTime"`
+	ArrayVoltage    float32 `json:"arrayVoltage"`
+	ArrayCurrent    float32 `json:"arrayCurrent"`
+	ArrayPower      float32 `json:"arrayPower"`
+	ChargingCurrent float32 `json:"chargingCurrent"`
+	ChargingPower   float32 `json:"chargingPower"`
+	BatteryVoltage  float32 `json:"batteryVoltage"`
+
+	// BatteryCurrent is the battery's net current, positive when charging
+	BatteryCurrent float32 `json:"batteryCurrent"`
+
 	BatterySOC           int32   `json:"batterySoc"`
 	BatteryTemp          float32 `json:"batteryTemp"`
 	DeviceTemp           float32 `json:"deviceTemp"`
 	EnergyGeneratedDaily float32 `json:"energyGeneratedDaily"`
 	ChargingStatus       int32   `json:"chargingStatus"`
+
+	// Quality holds a flag for every field in statusFields, keyed by its
+	// JSON name
+	Quality map[string]Quality `json:"quality"`
+
+	// Runtime is estimated from the battery current and SOC against the
+	// battery capacity; nil while either is unknown
+	Runtime *estimator.Estimate `json:"runtime"`
+}
+
+// Good reports whether field was read in this collection. A field without a
+// quality flag is taken to be good.
+func (c *ControllerStatus) Good(field string) bool {
+	q, ok := c.Quality[field]
+	return !ok || q == QualityGood
+}
+
+// statusField is one ControllerStatus value and the registers it comes from.
+type statusField struct {
+	name      string
+	registers registerRange
+	parse     func(c *ControllerStatus, data []byte) error
 }
 
-func NewCollector(client ModbusClient, prometheusCollector MetricsCollector) *Collector {
+var statusFields = []statusField{
+	{"arrayVoltage", registerRange{regArrayVoltage, 1}, func(c *ControllerStatus, data []byte) (err error) {
+		c.ArrayVoltage, err = parser.ParseFloat(data)
+		return err
+	}},
+	{"arrayCurrent", registerRange{regArrayCurrent, 1}, func(c *ControllerStatus, data []byte) (err error) {
+		c.ArrayCurrent, err = parser.ParseFloat(data)
+		return err
+	}},
+	{"arrayPower", registerRange{regArrayPower, 2}, func(c *ControllerStatus, data []byte) (err error) {
+		c.ArrayPower, err = parser.ParseFloat32(data)
+		return err
+	}},
+	{"batteryVoltage", registerRange{regBatteryVoltage, 1}, func(c *ControllerStatus, data []byte) (err error) {
+		c.BatteryVoltage, err = parser.ParseFloat(data)
+		return err
+	}},
+	{"batteryCurrent", registerRange{regBatteryCurrent, 2}, func(c *ControllerStatus, data []byte) (err error) {
+		c.BatteryCurrent, err = parser.ParseSignedFloat32(data)
+		return err
+	}},
+	{"chargingCurrent", registerRange{regChargingCurrent, 1}, func(c *ControllerStatus, data []byte) (err error) {
+		c.ChargingCurrent, err = parser.ParseFloat(data)
+		return err
+	}},
+	{"chargingPower", registerRange{regChargingPower, 2}, func(c *ControllerStatus, data []byte) (err error) {
+		c.ChargingPower, err = parser.ParseFloat32(data)
+		return err
+	}},
+	{"batteryTemp", registerRange{regBatteryTemperature, 1}, func(c *ControllerStatus, data []byte) error {
+		raw, err := parser.ParseInt(data)
+		c.BatteryTemp = parser.ParseSignedTemperature(raw)
+		return err
+	}},
+	{"deviceTemp", registerRange{regDeviceTemperature, 1}, func(c *ControllerStatus, data []byte) error {
+		raw, err := parser.ParseInt(data)
+		c.DeviceTemp = parser.ParseSignedTemperature(raw)
+		return err
+	}},
+	{"batterySoc", registerRange{regBatterySOC, 1}, func(c *ControllerStatus, data []byte) (err error) {
+		c.BatterySOC, err = parser.ParseInt(data)
+		return err
+	}},
+	{"energyGeneratedDaily", registerRange{regEnergyGeneratedDaily, 2}, func(c *ControllerStatus, data []byte) (err error) {
+		c.EnergyGeneratedDaily, err = parser.ParseFloat32(data)
+		return err
+	}},
+	{"chargingStatus", registerRange{regControllerStatus, 1}, func(c *ControllerStatus, data []byte) error {
+		controllerStatus, err := parser.ParseInt(data)
+		c.ChargingStatus = (controllerStatus & chargingStatusMask) >> chargingStatusShift
+		return err
+	}},
+}
+
+// NewCollector returns a Collector whose reads each cover at most
+// maxReadSpan registers, the resolved Timing's MaxReadSpan.
+func NewCollector(client ModbusClient, prometheusCollector MetricsCollector, maxReadSpan uint16) *Collector {
 	collector := &Collector{
 		modbusClient:        client,
 		prometheusCollector: prometheusCollector,
+		planner:             newReadPlanner(maxReadSpan, epeverInputGaps),
 	}
 
 	return collector
 }
 
+// GetStatus reads the status registers. A read that fails only costs the
+// fields it covers: they keep their previous value, flagged stale, or are
+// flagged failed if they have never been read. GetStatus returns an error
+// only when no field could be read at all.
 func (e *Collector) GetStatus(ctx context.Context) (*ControllerStatus, error) {
 	startTime := time.Now()
 	log.Debug("Starting GetStatus collection")
 
-	c := &ControllerStatus{
-		Timestamp: startTime.Unix(),
+	values, readErr := e.planner.read(ctx, e.modbusClient.ReadInputRegisters, statusRegisters)
+	for _, failed := range failedReads(readErr) {
+		e.prometheusCollector.IncrementRegisterFailure(failed.Range.start, "input")
 	}
 
-	// Batch read all PV, battery, charging, and temperature registers (0x3100-0x3111)
-	log.Debugf("Reading batch data from registers 0x%04X-0x%04X (18 registers)", regArrayVoltage, regDeviceTemperature)
-	batchData, err := e.modbusClient.ReadInputRegisters(ctx, regArrayVoltage, 18)
-	if err != nil {
-		log.Debugf("Failed to read batch data: %v", err)
-		e.prometheusCollector.IncrementRegisterFailure(regArrayVoltage, "input")
-		return nil, fmt.Errorf("failed to read batch data (0x%04X-0x%04X): %w", regArrayVoltage, regDeviceTemperature, err)
-	}
-	if len(batchData) < 36 {
-		return nil, fmt.Errorf("insufficient batch data: expected 36 bytes, got %d", len(batchData))
-	}
-	time.Sleep(100 * time.Millisecond) // Allow device to recover after large read
-
-	// Extract values from batch data
-	// Register offsets within the batch (each register is 2 bytes):
-	// 0x3100 (offset 0): Array voltage
-	// 0x3101 (offset 2): Array current
-	// 0x3102-0x3103 (offset 4-8): Array power (32-bit)
-	// 0x3104 (offset 8): Battery voltage
-	// 0x3105 (offset 10): Charging current
-	// 0x3106-0x3107 (offset 12-16): Charging power (32-bit)
-	// 0x3108-0x310F (offset 16-32): Unused registers (skipped)
-	// 0x3110 (offset 32): Battery temperature
-	// 0x3111 (offset 34): Device temperature
-
-	c.ArrayVoltage, err = parser.ParseFloat(batchData[0:2])
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse array voltage: %w", err)
-	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
-	c.ArrayCurrent, err = parser.ParseFloat(batchData[2:4])
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse array current: %w", err)
+	c := &ControllerStatus{}
+	if e.last != nil {
+		*c = *e.last
 	}
+	c.Timestamp = startTime.Unix()
+	c.Quality = make(map[string]Quality, len(statusFields))
 
-	c.ArrayPower, err = parser.ParseFloat32(batchData[4:8])
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse array power: %w", err)
-	}
+	var good int
+	var missing []string
+	for _, field := range statusFields {
+		if values.has(field.registers) {
+			if err := field.parse(c, values.bytes(field.registers.start, field.registers.count)); err == nil {
+				c.Quality[field.name] = QualityGood
+				good++
+				continue
+			}
+		}
 
-	c.BatteryVoltage, err = parser.ParseFloat(batchData[8:10])
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse battery voltage: %w", err)
+		missing = append(missing, field.name)
+		if e.last != nil && e.last.Quality[field.name] != QualityFailed {
+			c.Quality[field.name] = QualityStale
+		} else {
+			c.Quality[field.name] = QualityFailed
+		}
 	}
 
-	c.ChargingCurrent, err = parser.ParseFloat(batchData[10:12])
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse charging current: %w", err)
+	if good == 0 {
+		if readErr == nil {
+			readErr = errors.New("no status field could be parsed")
+		}
+		log.Debugf("Failed to read status registers: %v", readErr)
+		return nil, readErr
 	}
-
-	c.ChargingPower, err = parser.ParseFloat32(batchData[12:16])
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse charging power: %w", err)
-	}
-
-	c.BatteryTemp, c.DeviceTemp, err = parser.ParseTemperatures(batchData[32:36])
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse temperatures: %w", err)
+	if len(missing) > 0 {
+		log.Warnf("partial epever collection, missing %s: %v", strings.Join(missing, ", "), readErr)
 	}
 
-	log.Debugf("Batch data parsed - Array: %.2fV/%.2fA/%.2fW, Battery: %.2fV/%.1f°C, Charging: %.2fA/%.2fW, Device: %.1f°C",
-		c.ArrayVoltage, c.ArrayCurrent, c.ArrayPower, c.BatteryVoltage, c.BatteryTemp,
-		c.ChargingCurrent, c.ChargingPower, c.DeviceTemp)
-
-	// Read remaining registers individually
-	log.Debugf("Reading battery SOC from register 0x%04X", regBatterySOC)
-	c.BatterySOC, err = e.getValueInt(ctx, regBatterySOC)
-	if err != nil {
-		log.Debugf("Failed to read battery SOC: %v", err)
-		return nil, err
-	}
-	log.Debugf("Battery SOC: %d%%", c.BatterySOC)
-	time.Sleep(100 * time.Millisecond) // Allow device to recover before next read
-
-	log.Debugf("Reading energy generated daily from register 0x%04X", regEnergyGeneratedDaily)
-	c.EnergyGeneratedDaily, err = e.getValueFloat32(ctx, regEnergyGeneratedDaily)
-	if err != nil {
-		log.Debugf("Failed to read energy generated daily: %v", err)
-		return nil, err
-	}
-	log.Debugf("Energy generated daily: %.2fkWh", c.EnergyGeneratedDaily)
-	time.Sleep(100 * time.Millisecond) // Allow device to recover before next read
-
-	log.Debugf("Reading controller status from register 0x%04X", regControllerStatus)
-	controllerStatus, err := e.getValueInt(ctx, regControllerStatus)
-	if err != nil {
-		log.Debugf("Failed to read controller status: %v", err)
-		return nil, err
-	}
-	// No delay needed after final read
-
-	chargingStatus := (controllerStatus & chargingStatusMask) >> chargingStatusShift
-	c.ChargingStatus = chargingStatus
-	log.Debugf("Controller status: 0x%04X, Charging status: %d", controllerStatus, chargingStatus)
+	log.Debugf("Status parsed - Array: %.2fV/%.2fA/%.2fW, Battery: %.2fV/%.2fA/%.1f°C/%d%%, Charging: %.2fA/%.2fW/status %d, Device: %.1f°C, Generated today: %.2fkWh",
+		c.ArrayVoltage, c.ArrayCurrent, c.ArrayPower, c.BatteryVoltage, c.BatteryCurrent, c.BatteryTemp, c.BatterySOC,
+		c.ChargingCurrent, c.ChargingPower, c.ChargingStatus, c.DeviceTemp, c.EnergyGeneratedDaily)
 
 	c.CollectionTime = time.Since(startTime).Seconds()
 	log.Debugf("GetStatus completed in %.3fs", c.CollectionTime)
 
+	e.last = c
 	return c, nil
 }
-
-func (e *Collector) getValueInt(ctx context.Context, address uint16) (int32, error) {
-	log.Debugf("Reading input register 0x%04X (quantity: 1)", address)
-	data, err := e.modbusClient.ReadInputRegisters(ctx, address, 1)
-	if err != nil {
-		log.Debugf("ReadInputRegisters failed for 0x%04X: %v", address, err)
-		e.prometheusCollector.IncrementRegisterFailure(address, "input")
-		return 0, fmt.Errorf("failed to get data from address %d, error: %w", address, err)
-	}
-	log.Debugf("ReadInputRegisters 0x%04X returned %d bytes: %v", address, len(data), data)
-	return parser.ParseInt(data)
-}
-
-func (e *Collector) getValueFloat32(ctx context.Context, address uint16) (float32, error) {
-	log.Debugf("Reading input registers 0x%04X (quantity: 2)", address)
-	data, err := e.modbusClient.ReadInputRegisters(ctx, address, 2)
-	if err != nil {
-		log.Debugf("ReadInputRegisters failed for 0x%04X: %v", address, err)
-		e.prometheusCollector.IncrementRegisterFailure(address, "input")
-		return 0, fmt.Errorf("failed to get data from address %d, error: %w", address, err)
-	}
-	log.Debugf("ReadInputRegisters 0x%04X returned %d bytes: %v", address, len(data), data)
-
-	return parser.ParseFloat32(data)
-}
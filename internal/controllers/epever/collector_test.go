@@ -2,6 +2,7 @@ package epever
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
 	"github.com/lumberbarons/solar-controller/internal/testutil"
@@ -40,8 +41,8 @@ func TestCollector_GetStatus(t *testing.T) {
 					return testutil.CreateModbusResponse(1850, 520), nil // Legacy fallback
 				case regBatterySOC: // Battery SOC (1 register)
 					return testutil.CreateModbusResponse(85), nil // 85%
-				case regEnergyGeneratedDaily: // Daily energy (2 registers for 32-bit)
-					return testutil.CreateModbusResponse(1550, 0), nil // 15.5 kWh (low word, high word)
+				case regEnergyGeneratedDaily: // Daily energy through battery current (0x330C-0x331C)
+					return statisticsResponse(1550, 0, 0xFE0C, 0xFFFF), nil // 15.5 kWh, -5 A
 				case regControllerStatus: // Controller status (1 register)
 					return testutil.CreateModbusResponse(0x0004), nil // Charging status bits
 				default:
@@ -52,7 +53,7 @@ func TestCollector_GetStatus(t *testing.T) {
 		}
 
 		mockMetrics := &MockMetricsCollector{}
-		collector := NewCollector(mockClient, mockMetrics)
+		collector := NewCollector(mockClient, mockMetrics, standardReadSpan)
 		status, err := collector.GetStatus(ctx)
 
 		if err != nil {
@@ -78,6 +79,7 @@ func TestCollector_GetStatus(t *testing.T) {
 			{"BatteryTemp", status.BatteryTemp, 25.0},
 			{"DeviceTemp", status.DeviceTemp, 32.0},
 			{"EnergyGeneratedDaily", status.EnergyGeneratedDaily, 15.5},
+			{"BatteryCurrent", status.BatteryCurrent, -5},
 		}
 
 		for _, tt := range tests {
@@ -107,20 +109,24 @@ func TestCollector_GetStatus(t *testing.T) {
 
 	t.Run("modbus read failure for batch data", func(t *testing.T) {
 		mockClient := &MockModbusClient{
-			ReadInputRegistersFunc: func(_ context.Context, address, _ uint16) ([]byte, error) {
+			ReadInputRegistersFunc: func(_ context.Context, address, quantity uint16) ([]byte, error) {
 				if address == regArrayVoltage {
 					return nil, &testutil.ModbusTestError{Message: "timeout"}
 				}
-				return testutil.CreateModbusResponse(0), nil
+				return make([]byte, 2*quantity), nil
 			},
 		}
 
 		mockMetrics := &MockMetricsCollector{}
-		collector := NewCollector(mockClient, mockMetrics)
-		_, err := collector.GetStatus(ctx)
+		collector := NewCollector(mockClient, mockMetrics, standardReadSpan)
+		status, err := collector.GetStatus(ctx)
+		if err != nil {
+			t.Fatalf("GetStatus() error = %v, want a partial status", err)
+		}
 
-		if err == nil {
-			t.Error("GetStatus() should return error when batch read fails")
+		assertQuality(t, status, QualityFailed, "arrayVoltage", "arrayCurrent", "arrayPower", "batteryVoltage", "chargingCurrent", "chargingPower", "batteryTemp", "deviceTemp")
+		if len(mockMetrics.RegisterFailures) != 1 {
+			t.Errorf("register failures = %v, want 1", mockMetrics.RegisterFailures)
 		}
 	})
 
@@ -137,20 +143,24 @@ func TestCollector_GetStatus(t *testing.T) {
 						2500, 3200,
 					), nil
 				}
-				return testutil.CreateModbusResponse(0), nil
+				return make([]byte, 2*quantity), nil
 			},
 		}
 
 		mockMetrics := &MockMetricsCollector{}
-		collector := NewCollector(mockClient, mockMetrics)
-		_, err := collector.GetStatus(ctx)
+		collector := NewCollector(mockClient, mockMetrics, standardReadSpan)
+		status, err := collector.GetStatus(ctx)
+		if err != nil {
+			t.Fatalf("GetStatus() error = %v, want a partial status", err)
+		}
 
-		if err == nil {
-			t.Error("GetStatus() should return error when battery SOC read fails")
+		assertQuality(t, status, QualityFailed, "batterySoc")
+		if len(mockMetrics.RegisterFailures) != 1 {
+			t.Errorf("register failures = %v, want 1", mockMetrics.RegisterFailures)
 		}
 	})
 
-	t.Run("modbus read failure for energy generated daily", func(t *testing.T) {
+	t.Run("modbus read failure for the energy statistics", func(t *testing.T) {
 		mockClient := &MockModbusClient{
 			ReadInputRegistersFunc: func(_ context.Context, address, quantity uint16) ([]byte, error) {
 				if address == regEnergyGeneratedDaily {
@@ -166,16 +176,21 @@ func TestCollector_GetStatus(t *testing.T) {
 				if address == regBatterySOC {
 					return testutil.CreateModbusResponse(85), nil
 				}
-				return testutil.CreateModbusResponse(0), nil
+				return make([]byte, 2*quantity), nil
 			},
 		}
 
 		mockMetrics := &MockMetricsCollector{}
-		collector := NewCollector(mockClient, mockMetrics)
-		_, err := collector.GetStatus(ctx)
+		collector := NewCollector(mockClient, mockMetrics, standardReadSpan)
+		status, err := collector.GetStatus(ctx)
+		if err != nil {
+			t.Fatalf("GetStatus() error = %v, want a partial status", err)
+		}
 
-		if err == nil {
-			t.Error("GetStatus() should return error when energy generated daily read fails")
+		// One read covers the daily energy and the battery current
+		assertQuality(t, status, QualityFailed, "energyGeneratedDaily", "batteryCurrent")
+		if len(mockMetrics.RegisterFailures) != 1 {
+			t.Errorf("register failures = %v, want 1", mockMetrics.RegisterFailures)
 		}
 	})
 
@@ -196,18 +211,22 @@ func TestCollector_GetStatus(t *testing.T) {
 					return testutil.CreateModbusResponse(85), nil
 				}
 				if address == regEnergyGeneratedDaily {
-					return testutil.CreateModbusResponse(1550, 0), nil
+					return statisticsResponse(1550, 0, 0, 0), nil
 				}
-				return testutil.CreateModbusResponse(0), nil
+				return make([]byte, 2*quantity), nil
 			},
 		}
 
 		mockMetrics := &MockMetricsCollector{}
-		collector := NewCollector(mockClient, mockMetrics)
-		_, err := collector.GetStatus(ctx)
+		collector := NewCollector(mockClient, mockMetrics, standardReadSpan)
+		status, err := collector.GetStatus(ctx)
+		if err != nil {
+			t.Fatalf("GetStatus() error = %v, want a partial status", err)
+		}
 
-		if err == nil {
-			t.Error("GetStatus() should return error when controller status read fails")
+		assertQuality(t, status, QualityFailed, "chargingStatus")
+		if len(mockMetrics.RegisterFailures) != 1 {
+			t.Errorf("register failures = %v, want 1", mockMetrics.RegisterFailures)
 		}
 	})
 
@@ -232,7 +251,7 @@ func TestCollector_GetStatus(t *testing.T) {
 				case regBatterySOC:
 					return testutil.CreateModbusResponse(85), nil
 				case regEnergyGeneratedDaily:
-					return testutil.CreateModbusResponse(0, 1550), nil
+					return statisticsResponse(0, 1550, 0, 0), nil
 				case regControllerStatus:
 					return testutil.CreateModbusResponse(0x0004), nil
 				default:
@@ -242,7 +261,7 @@ func TestCollector_GetStatus(t *testing.T) {
 		}
 
 		mockMetrics := &MockMetricsCollector{}
-		collector := NewCollector(mockClient, mockMetrics)
+		collector := NewCollector(mockClient, mockMetrics, standardReadSpan)
 		status, err := collector.GetStatus(ctx)
 
 		if err != nil {
@@ -267,7 +286,7 @@ func TestCollector_GetStatus(t *testing.T) {
 		}
 
 		mockMetrics := &MockMetricsCollector{}
-		collector := NewCollector(mockClient, mockMetrics)
+		collector := NewCollector(mockClient, mockMetrics, standardReadSpan)
 		_, err := collector.GetStatus(ctx)
 
 		if err == nil {
@@ -276,6 +295,104 @@ func TestCollector_GetStatus(t *testing.T) {
 	})
 }
 
+// assertQuality checks that the given fields have quality want and every
+// other field is good.
+func assertQuality(t *testing.T, status *ControllerStatus, want Quality, fields ...string) {
+	t.Helper()
+
+	expected := make(map[string]Quality)
+	for _, field := range statusFields {
+		expected[field.name] = QualityGood
+	}
+	for _, field := range fields {
+		expected[field] = want
+	}
+
+	if !reflect.DeepEqual(status.Quality, expected) {
+		t.Errorf("Quality = %v, want %v", status.Quality, expected)
+	}
+}
+
+// A field that fails after a good read keeps that value, flagged stale, and
+// is good again once it reads.
+func TestCollector_GetStatusCarriesStaleValues(t *testing.T) {
+	socFails := false
+	bank := registerBank(map[uint16][]byte{
+		regArrayVoltage: testutil.CreateModbusResponse(1850),
+		regBatterySOC:   testutil.CreateModbusResponse(85),
+	})
+	mockClient := &MockModbusClient{
+		ReadInputRegistersFunc: func(ctx context.Context, address, quantity uint16) ([]byte, error) {
+			if socFails && address == regBatterySOC {
+				return nil, &testutil.ModbusTestError{Message: "timeout"}
+			}
+			return bank(ctx, address, quantity)
+		},
+	}
+	collector := NewCollector(mockClient, &MockMetricsCollector{}, standardReadSpan)
+
+	if _, err := collector.GetStatus(context.Background()); err != nil {
+		t.Fatalf("first GetStatus failed: %v", err)
+	}
+
+	socFails = true
+	status, err := collector.GetStatus(context.Background())
+	if err != nil {
+		t.Fatalf("second GetStatus failed: %v", err)
+	}
+	assertQuality(t, status, QualityStale, "batterySoc")
+	if status.BatterySOC != 85 {
+		t.Errorf("BatterySOC = %d, want the previous 85", status.BatterySOC)
+	}
+	if !floatEqual(status.ArrayVoltage, 18.5) {
+		t.Errorf("ArrayVoltage = %v, want 18.5", status.ArrayVoltage)
+	}
+
+	socFails = false
+	status, err = collector.GetStatus(context.Background())
+	if err != nil {
+		t.Fatalf("third GetStatus failed: %v", err)
+	}
+	assertQuality(t, status, QualityGood)
+}
+
+func TestCollector_GetStatusFailsWhenNothingReads(t *testing.T) {
+	mockClient := &MockModbusClient{
+		ReadInputRegistersFunc: func(_ context.Context, _, _ uint16) ([]byte, error) {
+			return nil, &testutil.ModbusTestError{Message: "timeout"}
+		},
+	}
+	mockMetrics := &MockMetricsCollector{}
+	collector := NewCollector(mockClient, mockMetrics, standardReadSpan)
+
+	if _, err := collector.GetStatus(context.Background()); err == nil {
+		t.Error("GetStatus() succeeded with every read failing")
+	}
+	if len(mockMetrics.RegisterFailures) != len(collector.planner.plan(statusRegisters)) {
+		t.Errorf("register failures = %v, want one per planned read", mockMetrics.RegisterFailures)
+	}
+}
+
+// The span a collector is built with caps its reads from the first one.
+func TestCollector_ReadsWithinItsSpan(t *testing.T) {
+	const span = 4
+	mockClient := &MockModbusClient{ReadInputRegistersFunc: registerBank(nil)}
+	collector := NewCollector(mockClient, &MockMetricsCollector{}, span)
+
+	if _, err := collector.GetStatus(context.Background()); err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	for _, call := range mockClient.ReadInputRegistersCalls {
+		if call.Quantity > span {
+			t.Errorf("read of %d registers at 0x%04X, want at most %d", call.Quantity, call.Address, span)
+		}
+	}
+	standard := NewCollector(mockClient, &MockMetricsCollector{}, standardReadSpan).planner.plan(statusRegisters)
+	if got := len(mockClient.ReadInputRegistersCalls); got <= len(standard) {
+		t.Errorf("%d reads at a span of %d, want more than the standard span's %d", got, span, len(standard))
+	}
+}
+
 // floatEqual checks if two float32 values are approximately equal
 func floatEqual(a, b float32) bool {
 	tolerance := float32(0.01)
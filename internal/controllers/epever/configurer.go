@@ -83,18 +83,25 @@ func validateVoltageBounds(name string, volts float32) error {
 	return nil
 }
 
+// Configurer reads and writes the controller's holding registers. It does not
+// pace itself: the client spaces its requests and holds off after a write
+// until the controller has committed it to EEPROM.
 type Configurer struct {
 	modbusClient        ModbusClient
 	prometheusCollector MetricsCollector
+	planner             *readPlanner
 	cache               *cachedConfig
 	cacheMutex          sync.RWMutex
 	cacheTTL            time.Duration
 }
 
-func NewConfigurer(client ModbusClient, prometheusCollector MetricsCollector) *Configurer {
+// NewConfigurer returns a Configurer whose reads each cover at most
+// maxReadSpan registers, the resolved Timing's MaxReadSpan.
+func NewConfigurer(client ModbusClient, prometheusCollector MetricsCollector, maxReadSpan uint16) *Configurer {
 	return &Configurer{
 		modbusClient:        client,
 		prometheusCollector: prometheusCollector,
+		planner:             newReadPlanner(maxReadSpan, epeverHoldingGaps),
 		cacheTTL:            10 * time.Minute,
 	}
 }
@@ -137,105 +144,68 @@ func (sc *Configurer) ConfigGet() gin.HandlerFunc {
 	}
 }
 
-func (sc *Configurer) getFloatValue(data []byte, index int) float32 {
-	offset := index * 2
-	if len(data) < offset+2 {
-		log.Warnf("getFloatValue: insufficient data at index %d (need %d bytes, have %d)", index, offset+2, len(data))
-		return 0
-	}
-	return float32(binary.BigEndian.Uint16(data[offset:offset+2])) / voltageDivisor
+// configRegisters are the holding registers getConfig reads. The planner
+// serves them with three reads: 0x9000-0x900E, 0x9013-0x901A and
+// 0x906B-0x906C.
+var configRegisters = []registerRange{
+	{regBatteryType, 3},         // battery type, capacity, temp compensation
+	{regOverVoltDisconnect, 12}, // voltage parameters
+	{regRealTimeClock, 3},
+	{regEqualizationChargingCycle, 1},
+	{regBatteryTempUpperLimit, 4},    // battery and controller temperature limits
+	{regEqualizationChargingTime, 2}, // equalization and boost durations
 }
 
 func (sc *Configurer) getConfig(ctx context.Context) (ControllerConfig, error) {
 	startTime := time.Now()
 	log.Debug("Starting config read from device")
 
-	// Read battery type, capacity, and temp compensation coefficient
-	data, err := sc.modbusClient.ReadHoldingRegisters(ctx, regBatteryType, 3)
+	values, err := sc.planner.read(ctx, sc.modbusClient.ReadHoldingRegisters, configRegisters)
 	if err != nil {
-		sc.prometheusCollector.IncrementRegisterFailure(regBatteryType, "holding")
-		return ControllerConfig{}, fmt.Errorf("failed to read battery config (0x%X): %w", regBatteryType, err)
+		for _, readErr := range failedReads(err) {
+			sc.prometheusCollector.IncrementRegisterFailure(readErr.Range.start, "holding")
+		}
+		return ControllerConfig{}, fmt.Errorf("failed to read config: %w", err)
 	}
-	time.Sleep(75 * time.Millisecond) // Allow device to recover before next read
-
-	batteryType := binary.BigEndian.Uint16(data[0:2])
-	batteryCapacity := binary.BigEndian.Uint16(data[2:4])
-	tempCompCoefficient := sc.getFloatValue(data, 2)
 
-	// Read time
-	data, err = sc.modbusClient.ReadHoldingRegisters(ctx, regRealTimeClock, 3)
-	if err != nil {
-		sc.prometheusCollector.IncrementRegisterFailure(regRealTimeClock, "holding")
-		return ControllerConfig{}, fmt.Errorf("failed to read time (0x%X): %w", regRealTimeClock, err)
+	// Voltages are stored as centivolts, temperatures as signed centidegrees
+	volts := func(address uint16) float32 {
+		return float32(values[address]) / voltageDivisor
 	}
-	time.Sleep(75 * time.Millisecond) // Allow device to recover before next read
-	if len(data) < 6 {
-		return ControllerConfig{}, fmt.Errorf("insufficient time data: expected 6 bytes, got %d", len(data))
+	degrees := func(address uint16) float32 {
+		return float32(int16(values[address])) / voltageDivisor
 	}
 
-	year := int(data[4]) + 2000
+	clock := values.bytes(regRealTimeClock, 3)
+	year := int(clock[4]) + 2000
 	timeStr := fmt.Sprintf("%d-%d-%d %02d:%02d:%02d",
-		data[2], data[5], year, data[3], data[0], data[1])
-
-	// Read voltage parameters (largest read - 12 registers)
-	data, err = sc.modbusClient.ReadHoldingRegisters(ctx, regOverVoltDisconnect, 12)
-	if err != nil {
-		sc.prometheusCollector.IncrementRegisterFailure(regOverVoltDisconnect, "holding")
-		return ControllerConfig{}, fmt.Errorf("failed to read voltage parameters (0x%X): %w", regOverVoltDisconnect, err)
-	}
-	time.Sleep(100 * time.Millisecond) // Extra delay after large read
-	overVoltDisconnectVoltage := sc.getFloatValue(data, 0)
-	chargingLimitVoltage := sc.getFloatValue(data, 1)
-	overVoltReconnectVoltage := sc.getFloatValue(data, 2)
-	equalizationVoltage := sc.getFloatValue(data, 3)
-	boostVoltage := sc.getFloatValue(data, 4)
-	floatVoltage := sc.getFloatValue(data, 5)
-	boostReconnectVoltage := sc.getFloatValue(data, 6)
-	lowVoltageReconnect := sc.getFloatValue(data, 7)
-	underVoltageRecover := sc.getFloatValue(data, 8)
-	underVoltageWarning := sc.getFloatValue(data, 9)
-	lowVoltageDisconnect := sc.getFloatValue(data, 10)
-	dischargingLimitVoltage := sc.getFloatValue(data, 11)
-
-	// Read equalization cycle
-	data, err = sc.modbusClient.ReadHoldingRegisters(ctx, regEqualizationChargingCycle, 1)
-	if err != nil {
-		sc.prometheusCollector.IncrementRegisterFailure(regEqualizationChargingCycle, "holding")
-		return ControllerConfig{}, fmt.Errorf("failed to read equalization cycle (0x%X): %w", regEqualizationChargingCycle, err)
-	}
-	time.Sleep(75 * time.Millisecond) // Allow device to recover before next read
-	if len(data) < 2 {
-		return ControllerConfig{}, fmt.Errorf("insufficient equalization cycle data: expected 2 bytes, got %d", len(data))
-	}
-	equalizationCycle := binary.BigEndian.Uint16(data[0:2])
-
-	// Read durations
-	data, err = sc.modbusClient.ReadHoldingRegisters(ctx, regEqualizationChargingTime, 2)
-	if err != nil {
-		sc.prometheusCollector.IncrementRegisterFailure(regEqualizationChargingTime, "holding")
-		return ControllerConfig{}, fmt.Errorf("failed to read durations (0x%X): %w", regEqualizationChargingTime, err)
-	}
-	time.Sleep(75 * time.Millisecond) // Allow device to recover before next read
-	if len(data) < 4 {
-		return ControllerConfig{}, fmt.Errorf("insufficient duration data: expected 4 bytes, got %d", len(data))
-	}
-	equalizationDuration := binary.BigEndian.Uint16(data[0:2])
-	boostDuration := binary.BigEndian.Uint16(data[2:4])
-
-	// Read temperature limits
-	data, err = sc.modbusClient.ReadHoldingRegisters(ctx, regBatteryTempUpperLimit, 4)
-	if err != nil {
-		sc.prometheusCollector.IncrementRegisterFailure(regBatteryTempUpperLimit, "holding")
-		return ControllerConfig{}, fmt.Errorf("failed to read temperature limits (0x%X): %w", regBatteryTempUpperLimit, err)
-	}
-	// No delay needed after final read
-	if len(data) < 8 {
-		return ControllerConfig{}, fmt.Errorf("insufficient temperature data: expected 8 bytes, got %d", len(data))
-	}
-	batteryTempUpperLimit := float32(int16(binary.BigEndian.Uint16(data[0:2]))) / voltageDivisor
-	batteryTempLowerLimit := float32(int16(binary.BigEndian.Uint16(data[2:4]))) / voltageDivisor
-	controllerTempUpperLimit := float32(int16(binary.BigEndian.Uint16(data[4:6]))) / voltageDivisor
-	controllerTempLowerLimit := float32(int16(binary.BigEndian.Uint16(data[6:8]))) / voltageDivisor
+		clock[2], clock[5], year, clock[3], clock[0], clock[1])
+
+	batteryType := values[regBatteryType]
+	batteryCapacity := values[regBatteryCapacity]
+	tempCompCoefficient := volts(regTempCompCoefficient)
+
+	overVoltDisconnectVoltage := volts(regOverVoltDisconnect)
+	chargingLimitVoltage := volts(regChargingLimitVoltage)
+	overVoltReconnectVoltage := volts(regOverVoltReconnect)
+	equalizationVoltage := volts(regEqualizationVoltage)
+	boostVoltage := volts(regBoostVoltage)
+	floatVoltage := volts(regFloatVoltage)
+	boostReconnectVoltage := volts(regBoostReconnectVoltage)
+	lowVoltageReconnect := volts(regLowVoltReconnect)
+	underVoltageRecover := volts(regUnderVoltRecover)
+	underVoltageWarning := volts(regUnderVoltWarning)
+	lowVoltageDisconnect := volts(regLowVoltDisconnect)
+	dischargingLimitVoltage := volts(regDischargingLimitVoltage)
+
+	equalizationCycle := values[regEqualizationChargingCycle]
+	equalizationDuration := values[regEqualizationChargingTime]
+	boostDuration := values[regBoostChargingTime]
+
+	batteryTempUpperLimit := degrees(regBatteryTempUpperLimit)
+	batteryTempLowerLimit := degrees(regBatteryTempLowerLimit)
+	controllerTempUpperLimit := degrees(regControllerTempUpperLimit)
+	controllerTempLowerLimit := degrees(regControllerTempLowerLimit)
 
 	elapsed := time.Since(startTime)
 	log.Debugf("Config read completed in %v", elapsed)
@@ -282,8 +252,6 @@ func (sc *Configurer) writeSingle(c *gin.Context, address, value uint16, descrip
 		}
 		return fmt.Errorf("%s: %w", errorMessage, err)
 	}
-	// Allow device time to commit write to EEPROM before next operation
-	time.Sleep(150 * time.Millisecond)
 	return nil
 }
 
@@ -393,8 +361,6 @@ func (sc *Configurer) writeVoltageParametersBlock(c *gin.Context, config *Contro
 		return fmt.Errorf("failed to write voltage parameters block: %w", err)
 	}
 
-	// Allow device time to commit all writes to EEPROM
-	time.Sleep(500 * time.Millisecond)
 	return nil
 }
 
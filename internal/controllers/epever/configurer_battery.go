@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
@@ -152,8 +151,6 @@ func (sc *Configurer) BatteryProfilePatch() gin.HandlerFunc {
 		// Invalidate cache after successful write
 		if writeSucceeded {
 			sc.invalidateCache()
-			// Allow device time to fully commit all changes to EEPROM before reading back
-			time.Sleep(500 * time.Millisecond)
 		}
 
 		// Return updated profile (this will fetch fresh data from device)
@@ -13,43 +13,27 @@ func TestConfigurer_getCachedConfig(t *testing.T) {
 	ctx := context.Background()
 
 	t.Run("cache miss - fetches from device", func(t *testing.T) {
-		callCount := 0
 		mockClient := &MockModbusClient{
-			ReadHoldingRegistersFunc: func(_ context.Context, address, _ uint16) ([]byte, error) {
-				callCount++
+			ReadHoldingRegistersFunc: registerBank(map[uint16][]byte{
 				// Return minimal valid data for getConfig()
-				switch address {
-				case regBatteryType: // Battery type, capacity, temp comp (3 registers)
-					return testutil.CreateModbusResponse(0, 100, 3), nil
-				case regRealTimeClock: // Time (3 registers, 6 bytes)
-					return []byte{0, 0, 1, 12, 25, 1}, nil // HH:MM, Month, Hour, Year-2000, Day
-				case regOverVoltDisconnect: // Voltage parameters (12 registers)
-					return testutil.CreateModbusResponse(
-						1600, 1500, 1500, 1460, 1440, 1380,
-						1320, 1260, 1220, 1200, 1110, 1080,
-					), nil
-				case regEqualizationChargingCycle: // Equalization cycle (1 register)
-					return testutil.CreateModbusResponse(30), nil
-				case regEqualizationChargingTime: // Durations (2 registers)
-					return testutil.CreateModbusResponse(120, 120), nil
-				case regBatteryTempUpperLimit: // Temperature limits (4 registers)
-					return testutil.CreateModbusResponse(4500, 65436, 4500, 4000), nil // 45°C, -10°C, 45°C, 40°C
-				case 0x903A: // Power component temps (2 registers)
-					return testutil.CreateModbusResponse(8500, 8000), nil // 85°C, 80°C
-				case 0x903C: // Line impedance (1 register)
-					return testutil.CreateModbusResponse(0), nil
-				case 0x903D: // Night/day threshold (2 registers)
-					return testutil.CreateModbusResponse(500, 10), nil
-				case 0x903F: // Day threshold and delay (2 registers)
-					return testutil.CreateModbusResponse(600, 10), nil
-				default:
-					return testutil.CreateModbusResponse(0), nil
-				}
-			},
+				regBatteryType:   testutil.CreateModbusResponse(0, 100, 3), // Battery type, capacity, temp comp (3 registers)
+				regRealTimeClock: []byte{0, 0, 1, 12, 25, 1},               // Time (3 registers, 6 bytes); HH:MM, Month, Hour, Year-2000, Day
+				regOverVoltDisconnect: testutil.CreateModbusResponse(
+					1600, 1500, 1500, 1460, 1440, 1380,
+					1320, 1260, 1220, 1200, 1110, 1080,
+				), // Voltage parameters (12 registers)
+				regEqualizationChargingCycle: testutil.CreateModbusResponse(30),                      // Equalization cycle (1 register)
+				regEqualizationChargingTime:  testutil.CreateModbusResponse(120, 120),                // Durations (2 registers)
+				regBatteryTempUpperLimit:     testutil.CreateModbusResponse(4500, 65436, 4500, 4000), // Temperature limits (4 registers); 45°C, -10°C, 45°C, 40°C
+				0x903A:                       testutil.CreateModbusResponse(8500, 8000),              // Power component temps (2 registers); 85°C, 80°C
+				0x903C:                       testutil.CreateModbusResponse(0),                       // Line impedance (1 register)
+				0x903D:                       testutil.CreateModbusResponse(500, 10),                 // Night/day threshold (2 registers)
+				0x903F:                       testutil.CreateModbusResponse(600, 10),                 // Day threshold and delay (2 registers)
+			}),
 		}
 
 		mockMetrics := &MockMetricsCollector{}
-		configurer := NewConfigurer(mockClient, mockMetrics)
+		configurer := NewConfigurer(mockClient, mockMetrics, standardReadSpan)
 
 		// First call should fetch from device
 		config1, err := configurer.getCachedConfig(ctx)
@@ -60,7 +44,7 @@ func TestConfigurer_getCachedConfig(t *testing.T) {
 			t.Fatal("getCachedConfig() returned nil config")
 		}
 
-		if callCount == 0 {
+		if len(mockClient.ReadHoldingRegistersCalls) == 0 {
 			t.Error("Expected modbus calls to fetch config, got 0")
 		}
 
@@ -86,42 +70,26 @@ func TestConfigurer_getCachedConfig(t *testing.T) {
 	})
 
 	t.Run("cache hit - no device fetch", func(t *testing.T) {
-		callCount := 0
 		mockClient := &MockModbusClient{
-			ReadHoldingRegistersFunc: func(_ context.Context, address, _ uint16) ([]byte, error) {
-				callCount++
-				switch address {
-				case regBatteryType:
-					return testutil.CreateModbusResponse(4, 100, 3), nil
-				case regRealTimeClock:
-					return []byte{0, 0, 1, 12, 25, 1}, nil
-				case regOverVoltDisconnect:
-					return testutil.CreateModbusResponse(
-						1600, 1500, 1500, 1460, 1440, 1380,
-						1320, 1260, 1220, 1200, 1110, 1080,
-					), nil
-				case regEqualizationChargingCycle:
-					return testutil.CreateModbusResponse(30), nil
-				case regEqualizationChargingTime:
-					return testutil.CreateModbusResponse(120, 120), nil
-				case regBatteryTempUpperLimit:
-					return testutil.CreateModbusResponse(4500, 65436, 4500, 4000), nil
-				case 0x903A:
-					return testutil.CreateModbusResponse(8500, 8000), nil
-				case 0x903C:
-					return testutil.CreateModbusResponse(0), nil
-				case 0x903D:
-					return testutil.CreateModbusResponse(500, 10), nil
-				case 0x903F:
-					return testutil.CreateModbusResponse(600, 10), nil
-				default:
-					return testutil.CreateModbusResponse(0), nil
-				}
-			},
+			ReadHoldingRegistersFunc: registerBank(map[uint16][]byte{
+				regBatteryType:   testutil.CreateModbusResponse(4, 100, 3),
+				regRealTimeClock: []byte{0, 0, 1, 12, 25, 1},
+				regOverVoltDisconnect: testutil.CreateModbusResponse(
+					1600, 1500, 1500, 1460, 1440, 1380,
+					1320, 1260, 1220, 1200, 1110, 1080,
+				),
+				regEqualizationChargingCycle: testutil.CreateModbusResponse(30),
+				regEqualizationChargingTime:  testutil.CreateModbusResponse(120, 120),
+				regBatteryTempUpperLimit:     testutil.CreateModbusResponse(4500, 65436, 4500, 4000),
+				0x903A:                       testutil.CreateModbusResponse(8500, 8000),
+				0x903C:                       testutil.CreateModbusResponse(0),
+				0x903D:                       testutil.CreateModbusResponse(500, 10),
+				0x903F:                       testutil.CreateModbusResponse(600, 10),
+			}),
 		}
 
 		mockMetrics := &MockMetricsCollector{}
-		configurer := NewConfigurer(mockClient, mockMetrics)
+		configurer := NewConfigurer(mockClient, mockMetrics, standardReadSpan)
 
 		// First call - populates cache
 		config1, err := configurer.getCachedConfig(ctx)
@@ -129,7 +97,7 @@ func TestConfigurer_getCachedConfig(t *testing.T) {
 			t.Fatalf("getCachedConfig() first call error = %v", err)
 		}
 
-		firstCallCount := callCount
+		firstCallCount := len(mockClient.ReadHoldingRegistersCalls)
 		if firstCallCount == 0 {
 			t.Error("Expected modbus calls on first fetch")
 		}
@@ -140,9 +108,9 @@ func TestConfigurer_getCachedConfig(t *testing.T) {
 			t.Fatalf("getCachedConfig() second call error = %v", err)
 		}
 
-		if callCount != firstCallCount {
+		if len(mockClient.ReadHoldingRegistersCalls) != firstCallCount {
 			t.Errorf("Expected no additional modbus calls (cache hit), but got %d total calls vs %d on first call",
-				callCount, firstCallCount)
+				len(mockClient.ReadHoldingRegistersCalls), firstCallCount)
 		}
 
 		// Verify all fields of both configs are equal
@@ -157,42 +125,26 @@ func TestConfigurer_getCachedConfig(t *testing.T) {
 	})
 
 	t.Run("cache expiration - refetches after TTL", func(t *testing.T) {
-		callCount := 0
 		mockClient := &MockModbusClient{
-			ReadHoldingRegistersFunc: func(_ context.Context, address, _ uint16) ([]byte, error) {
-				callCount++
-				switch address {
-				case regBatteryType:
-					return testutil.CreateModbusResponse(4, 100, 3), nil
-				case regRealTimeClock:
-					return []byte{0, 0, 1, 12, 25, 1}, nil
-				case regOverVoltDisconnect:
-					return testutil.CreateModbusResponse(
-						1600, 1500, 1500, 1460, 1440, 1380,
-						1320, 1260, 1220, 1200, 1110, 1080,
-					), nil
-				case regEqualizationChargingCycle:
-					return testutil.CreateModbusResponse(30), nil
-				case regEqualizationChargingTime:
-					return testutil.CreateModbusResponse(120, 120), nil
-				case regBatteryTempUpperLimit:
-					return testutil.CreateModbusResponse(4500, 65436, 4500, 4000), nil
-				case 0x903A:
-					return testutil.CreateModbusResponse(8500, 8000), nil
-				case 0x903C:
-					return testutil.CreateModbusResponse(0), nil
-				case 0x903D:
-					return testutil.CreateModbusResponse(500, 10), nil
-				case 0x903F:
-					return testutil.CreateModbusResponse(600, 10), nil
-				default:
-					return testutil.CreateModbusResponse(0), nil
-				}
-			},
+			ReadHoldingRegistersFunc: registerBank(map[uint16][]byte{
+				regBatteryType:   testutil.CreateModbusResponse(4, 100, 3),
+				regRealTimeClock: []byte{0, 0, 1, 12, 25, 1},
+				regOverVoltDisconnect: testutil.CreateModbusResponse(
+					1600, 1500, 1500, 1460, 1440, 1380,
+					1320, 1260, 1220, 1200, 1110, 1080,
+				),
+				regEqualizationChargingCycle: testutil.CreateModbusResponse(30),
+				regEqualizationChargingTime:  testutil.CreateModbusResponse(120, 120),
+				regBatteryTempUpperLimit:     testutil.CreateModbusResponse(4500, 65436, 4500, 4000),
+				0x903A:                       testutil.CreateModbusResponse(8500, 8000),
+				0x903C:                       testutil.CreateModbusResponse(0),
+				0x903D:                       testutil.CreateModbusResponse(500, 10),
+				0x903F:                       testutil.CreateModbusResponse(600, 10),
+			}),
 		}
 
 		mockMetrics := &MockMetricsCollector{}
-		configurer := NewConfigurer(mockClient, mockMetrics)
+		configurer := NewConfigurer(mockClient, mockMetrics, standardReadSpan)
 		// Set a very short TTL for testing
 		configurer.cacheTTL = 1 * time.Millisecond
 
@@ -202,7 +154,7 @@ func TestConfigurer_getCachedConfig(t *testing.T) {
 			t.Fatalf("getCachedConfig() first call error = %v", err)
 		}
 
-		firstCallCount := callCount
+		firstCallCount := len(mockClient.ReadHoldingRegistersCalls)
 
 		// Wait for cache to expire
 		time.Sleep(10 * time.Millisecond)
@@ -213,48 +165,32 @@ func TestConfigurer_getCachedConfig(t *testing.T) {
 			t.Fatalf("getCachedConfig() second call error = %v", err)
 		}
 
-		if callCount == firstCallCount {
+		if len(mockClient.ReadHoldingRegistersCalls) == firstCallCount {
 			t.Error("Expected cache to expire and refetch from device")
 		}
 	})
 
 	t.Run("cache invalidation", func(t *testing.T) {
-		callCount := 0
 		mockClient := &MockModbusClient{
-			ReadHoldingRegistersFunc: func(_ context.Context, address, _ uint16) ([]byte, error) {
-				callCount++
-				switch address {
-				case regBatteryType:
-					return testutil.CreateModbusResponse(4, 100, 3), nil
-				case regRealTimeClock:
-					return []byte{0, 0, 1, 12, 25, 1}, nil
-				case regOverVoltDisconnect:
-					return testutil.CreateModbusResponse(
-						1600, 1500, 1500, 1460, 1440, 1380,
-						1320, 1260, 1220, 1200, 1110, 1080,
-					), nil
-				case regEqualizationChargingCycle:
-					return testutil.CreateModbusResponse(30), nil
-				case regEqualizationChargingTime:
-					return testutil.CreateModbusResponse(120, 120), nil
-				case regBatteryTempUpperLimit:
-					return testutil.CreateModbusResponse(4500, 65436, 4500, 4000), nil
-				case 0x903A:
-					return testutil.CreateModbusResponse(8500, 8000), nil
-				case 0x903C:
-					return testutil.CreateModbusResponse(0), nil
-				case 0x903D:
-					return testutil.CreateModbusResponse(500, 10), nil
-				case 0x903F:
-					return testutil.CreateModbusResponse(600, 10), nil
-				default:
-					return testutil.CreateModbusResponse(0), nil
-				}
-			},
+			ReadHoldingRegistersFunc: registerBank(map[uint16][]byte{
+				regBatteryType:   testutil.CreateModbusResponse(4, 100, 3),
+				regRealTimeClock: []byte{0, 0, 1, 12, 25, 1},
+				regOverVoltDisconnect: testutil.CreateModbusResponse(
+					1600, 1500, 1500, 1460, 1440, 1380,
+					1320, 1260, 1220, 1200, 1110, 1080,
+				),
+				regEqualizationChargingCycle: testutil.CreateModbusResponse(30),
+				regEqualizationChargingTime:  testutil.CreateModbusResponse(120, 120),
+				regBatteryTempUpperLimit:     testutil.CreateModbusResponse(4500, 65436, 4500, 4000),
+				0x903A:                       testutil.CreateModbusResponse(8500, 8000),
+				0x903C:                       testutil.CreateModbusResponse(0),
+				0x903D:                       testutil.CreateModbusResponse(500, 10),
+				0x903F:                       testutil.CreateModbusResponse(600, 10),
+			}),
 		}
 
 		mockMetrics := &MockMetricsCollector{}
-		configurer := NewConfigurer(mockClient, mockMetrics)
+		configurer := NewConfigurer(mockClient, mockMetrics, standardReadSpan)
 
 		// First call - populates cache
 		_, err := configurer.getCachedConfig(ctx)
@@ -262,7 +198,7 @@ func TestConfigurer_getCachedConfig(t *testing.T) {
 			t.Fatalf("getCachedConfig() first call error = %v", err)
 		}
 
-		firstCallCount := callCount
+		firstCallCount := len(mockClient.ReadHoldingRegistersCalls)
 
 		// Invalidate cache
 		configurer.invalidateCache()
@@ -273,7 +209,7 @@ func TestConfigurer_getCachedConfig(t *testing.T) {
 			t.Fatalf("getCachedConfig() after invalidation error = %v", err)
 		}
 
-		if callCount == firstCallCount {
+		if len(mockClient.ReadHoldingRegistersCalls) == firstCallCount {
 			t.Error("Expected refetch after cache invalidation")
 		}
 	})
@@ -286,7 +222,7 @@ func TestConfigurer_getCachedConfig(t *testing.T) {
 		}
 
 		mockMetrics := &MockMetricsCollector{}
-		configurer := NewConfigurer(mockClient, mockMetrics)
+		configurer := NewConfigurer(mockClient, mockMetrics, standardReadSpan)
 
 		_, err := configurer.getCachedConfig(ctx)
 		if err == nil {
@@ -298,7 +234,7 @@ func TestConfigurer_getCachedConfig(t *testing.T) {
 func TestConfigurer_invalidateCache(t *testing.T) {
 	mockClient := &MockModbusClient{}
 	mockMetrics := &MockMetricsCollector{}
-	configurer := NewConfigurer(mockClient, mockMetrics)
+	configurer := NewConfigurer(mockClient, mockMetrics, standardReadSpan)
 
 	// Manually set cache
 	configurer.cache = &cachedConfig{
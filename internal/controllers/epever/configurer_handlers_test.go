@@ -15,7 +15,7 @@ import (
 // registered against a mock modbus client that reports a userDefined battery.
 func newPatchTestRouter(mockClient *MockModbusClient) *gin.Engine {
 	gin.SetMode(gin.TestMode)
-	configurer := NewConfigurer(mockClient, &MockMetricsCollector{})
+	configurer := NewConfigurer(mockClient, &MockMetricsCollector{}, standardReadSpan)
 
 	router := gin.New()
 	router.PATCH("/api/epever/config", configurer.ConfigPatch())
@@ -29,27 +29,17 @@ func newPatchTestRouter(mockClient *MockModbusClient) *gin.Engine {
 // run their read-modify-write flow; writes are recorded but not applied.
 func fullConfigMockClient() *MockModbusClient {
 	return &MockModbusClient{
-		ReadHoldingRegistersFunc: func(_ context.Context, address, _ uint16) ([]byte, error) {
-			switch address {
-			case regBatteryType:
-				return testutil.CreateModbusResponse(batteryTypeUserDefined, 100, 3), nil
-			case regRealTimeClock:
-				return []byte{0, 0, 1, 12, 25, 1}, nil
-			case regOverVoltDisconnect:
-				return testutil.CreateModbusResponse(
-					1600, 1500, 1500, 1460, 1440, 1380,
-					1320, 1260, 1220, 1200, 1110, 1080,
-				), nil
-			case regEqualizationChargingCycle:
-				return testutil.CreateModbusResponse(30), nil
-			case regEqualizationChargingTime:
-				return testutil.CreateModbusResponse(120, 120), nil
-			case regBatteryTempUpperLimit:
-				return testutil.CreateModbusResponse(4500, 65436, 4500, 4000), nil
-			default:
-				return testutil.CreateModbusResponse(0), nil
-			}
-		},
+		ReadHoldingRegistersFunc: registerBank(map[uint16][]byte{
+			regBatteryType:   testutil.CreateModbusResponse(batteryTypeUserDefined, 100, 3),
+			regRealTimeClock: []byte{0, 0, 1, 12, 25, 1},
+			regOverVoltDisconnect: testutil.CreateModbusResponse(
+				1600, 1500, 1500, 1460, 1440, 1380,
+				1320, 1260, 1220, 1200, 1110, 1080,
+			),
+			regEqualizationChargingCycle: testutil.CreateModbusResponse(30),
+			regEqualizationChargingTime:  testutil.CreateModbusResponse(120, 120),
+			regBatteryTempUpperLimit:     testutil.CreateModbusResponse(4500, 65436, 4500, 4000),
+		}),
 		WriteMultipleRegistersFunc: func(_ context.Context, _, _ uint16, _ []byte) ([]byte, error) {
 			return nil, nil
 		},
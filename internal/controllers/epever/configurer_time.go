@@ -80,8 +80,6 @@ func (sc *Configurer) TimePatch() gin.HandlerFunc {
 
 		// Invalidate cache after time write
 		sc.invalidateCache()
-		// Allow device time to fully commit changes to EEPROM before reading back
-		time.Sleep(500 * time.Millisecond)
 
 		// Return the updated time
 		data, err = sc.modbusClient.ReadHoldingRegisters(c.Request.Context(), regRealTimeClock, 3)
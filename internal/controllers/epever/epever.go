@@ -9,6 +9,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-co-op/gocron"
+	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"github.com/lumberbarons/solar-controller/internal/estimator"
+	"github.com/lumberbarons/solar-controller/internal/health"
 	"github.com/lumberbarons/solar-controller/internal/publish"
 	log "github.com/sirupsen/logrus"
 )
@@ -21,6 +24,29 @@ type Configuration struct {
 	Enabled       bool   `yaml:"enabled"`
 	SerialPort    string `yaml:"serialPort"`
 	PublishPeriod int    `yaml:"publishPeriod"`
+
+	// OfflineAfter is how many failed collection cycles in a row publish the
+	// controller as offline (default: 3)
+	OfflineAfter int `yaml:"offlineAfter"`
+
+	// Timing sets the serial framing and bus pacing (default: the standard
+	// profile)
+	Timing TimingConfiguration `yaml:"timing"`
+
+	// Runtime tunes the time-to-full and time-to-empty estimates. Without a
+	// capacity here, the battery capacity set on the controller is used
+	Runtime estimator.Config `yaml:"runtime"`
+}
+
+// Validate checks the configuration for errors. Only called when enabled.
+func (c *Configuration) Validate() error {
+	if err := c.Timing.Validate(); err != nil {
+		return fmt.Errorf("timing: %w", err)
+	}
+	if err := c.Runtime.Validate(); err != nil {
+		return fmt.Errorf("runtime %w", err)
+	}
+	return nil
 }
 
 type Controller struct {
@@ -31,10 +57,17 @@ type Controller struct {
 	prometheusCollector MetricsCollector
 	scheduler           *gocron.Scheduler
 	deviceID            string
-	lastStatus          *ControllerStatus
-	lastStatusMutex     sync.RWMutex
+	health              *health.Tracker
+	last                controllers.Latest[ControllerStatus]
 	collectInProgress   bool
 	collectMutex        sync.Mutex
+
+	// The runtime estimate's policy and current readings, and the battery
+	// capacity last read from the controller. Only the collection cycle
+	// touches them
+	runtime    estimator.Policy
+	currents   estimator.Window
+	capacityAh float64
 }
 
 // NewController creates a new Epever controller with dependency injection for testing.
@@ -47,6 +80,8 @@ func NewController(
 	prometheusCollector MetricsCollector,
 	deviceID string,
 	publishPeriod int,
+	offlineAfter int,
+	runtime estimator.Policy,
 ) (*Controller, error) {
 	if client == nil {
 		return &Controller{}, nil
@@ -66,7 +101,9 @@ func NewController(
 		prometheusCollector: prometheusCollector,
 		publisher:           publisher,
 		deviceID:            deviceID,
+		health:              health.NewTracker(publisher, deviceID, namespace, time.Duration(publishPeriod)*time.Second, offlineAfter),
 		scheduler:           s,
+		runtime:             runtime.WithDefaults(),
 	}
 
 	_, err := s.Every(publishPeriod).Seconds().Do(controller.collectAndPublish)
@@ -102,6 +139,8 @@ func newControllerForTest(
 		prometheusCollector: prometheusCollector,
 		publisher:           publisher,
 		deviceID:            deviceID,
+		health:              health.NewTracker(publisher, deviceID, namespace, 0, 0),
+		runtime:             estimator.Policy{}.WithDefaults(),
 	}
 }
 
@@ -118,14 +157,19 @@ func NewControllerFromConfig(config Configuration, publisher publish.MessagePubl
 		return &Controller{}, nil
 	}
 
-	client, err := NewSerialModbusClient(config.SerialPort)
+	timing, err := config.Timing.Resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := NewSerialModbusClient(config.SerialPort, timing)
 	if err != nil {
 		return nil, err
 	}
 
 	prometheusCollector := NewPrometheusCollector()
-	epeverCollector := NewCollector(client, prometheusCollector)
-	epeverConfigurer := NewConfigurer(client, prometheusCollector)
+	epeverCollector := NewCollector(client, prometheusCollector, timing.MaxReadSpan)
+	epeverConfigurer := NewConfigurer(client, prometheusCollector, timing.MaxReadSpan)
 
 	log.Infof("connected to epever %s", config.SerialPort)
 
@@ -137,6 +181,8 @@ func NewControllerFromConfig(config Configuration, publisher publish.MessagePubl
 		prometheusCollector,
 		deviceID,
 		config.PublishPeriod,
+		config.OfflineAfter,
+		config.Runtime.Policy(),
 	)
 }
 
@@ -164,9 +210,11 @@ func (e *Controller) collectAndPublish() {
 	defer cancel()
 
 	status, err := e.collector.GetStatus(ctx)
+	e.reportConnection()
 	if err != nil {
 		log.Errorf("failed to collect metrics from epever: %s", err)
 		e.prometheusCollector.IncrementFailures()
+		e.health.Failure(err)
 
 		// Publish failure metric to message broker
 		failureMetric := CreateCollectionFailureMetric()
@@ -183,14 +231,14 @@ func (e *Controller) collectAndPublish() {
 		return
 	}
 
-	e.lastStatusMutex.Lock()
-	e.lastStatus = status
-	e.lastStatusMutex.Unlock()
+	e.estimateRuntime(ctx, status)
+	e.last.Set(status)
 
 	e.prometheusCollector.SetMetrics(status)
+	e.prometheusCollector.SetRuntime(status.Runtime)
 
 	// Convert status to individual metrics
-	metrics := ConvertStatusToMetrics(status)
+	metrics := append(ConvertStatusToMetrics(status), ConvertRuntimeToMetrics(status.Runtime)...)
 
 	// Publish each metric individually
 	for _, metric := range metrics {
@@ -207,23 +255,105 @@ func (e *Controller) collectAndPublish() {
 		log.Debugf("published metric %s to %s", metric.Name, topicSuffix)
 	}
 
+	e.health.Success()
+
 	log.Debug("collection done for epever controller")
 }
 
+// estimateRuntime adds the battery current to the smoothing window and
+// estimates the runtime from it, the SOC and the capacity. A current that was
+// not read this cycle is left out of the window, and a SOC never read gives
+// no estimate.
+func (e *Controller) estimateRuntime(ctx context.Context, status *ControllerStatus) {
+	if status.Good("batteryCurrent") {
+		e.currents.Add(status.Timestamp, float64(status.BatteryCurrent), e.runtime.Smoothing)
+	}
+
+	status.Runtime = nil
+	if status.Quality["batterySoc"] == QualityFailed {
+		return
+	}
+	capacity, source := e.runtimeCapacity(ctx)
+	status.Runtime = e.currents.Estimate(status.Timestamp, float64(status.BatterySOC), capacity, source, e.runtime)
+}
+
+// runtimeCapacity returns the capacity the estimate is from, and where it
+// came from: the configured one, then the battery capacity set on the
+// controller. The setting is read through the configurer's cache, so it
+// costs a bus read once per cache period; while it cannot be read, the last
+// value read stands in.
+func (e *Controller) runtimeCapacity(ctx context.Context) (float64, string) {
+	if e.runtime.CapacityAh > 0 {
+		return e.runtime.CapacityAh, estimator.CapacityConfigured
+	}
+	if e.configurer != nil {
+		config, err := e.configurer.getCachedConfig(ctx)
+		if err != nil {
+			log.Debugf("failed to read the epever battery capacity: %s", err)
+		} else {
+			e.capacityAh = float64(config.BatteryCapacity)
+		}
+	}
+	if e.capacityAh <= 0 {
+		return 0, ""
+	}
+	return e.capacityAh, estimator.CapacityController
+}
+
+// reportConnection copies the client's link state to the metrics. It runs
+// every cycle, including failed ones, so a disconnect is visible as soon as
+// the client notices it.
+func (e *Controller) reportConnection() {
+	if reporter, ok := e.client.(ConnectionReporter); ok {
+		e.prometheusCollector.SetConnectionStatus(reporter.ConnectionStatus())
+	}
+}
+
+// ConnectionGet returns the state of the serial link.
+func (e *Controller) ConnectionGet(reporter ConnectionReporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, reporter.ConnectionStatus())
+	}
+}
+
 func (e *Controller) MetricsGet() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		e.lastStatusMutex.RLock()
-		status := e.lastStatus
-		e.lastStatusMutex.RUnlock()
+		status := e.last.Last()
 
 		if status == nil {
 			c.JSON(http.StatusNoContent, gin.H{})
 			return
 		}
-		c.JSON(http.StatusOK, status)
+		c.JSON(http.StatusOK, struct {
+			*ControllerStatus
+			Health health.Health `json:"health"`
+		}{status, e.Health()})
 	}
 }
 
+// Health reports how fresh the last status is.
+func (e *Controller) Health() health.Health {
+	if e.health == nil {
+		return health.Health{Availability: health.Unknown}
+	}
+	return e.health.Health()
+}
+
+// Name returns the controller's name, which its endpoints are under.
+func (e *Controller) Name() string {
+	return namespace
+}
+
+// Type returns the kind of equipment the controller reads.
+func (e *Controller) Type() string {
+	return controllers.TypeChargeController
+}
+
+// Snapshot returns the last status, or nil before the first collection.
+func (e *Controller) Snapshot() any {
+	return e.last.Snapshot()
+}
+
 func (e *Controller) RegisterEndpoints(r *gin.Engine) {
 	if e.client == nil {
 		return
@@ -237,6 +367,10 @@ func (e *Controller) RegisterEndpoints(r *gin.Engine) {
 
 	r.GET(fmt.Sprintf("%s/metrics", prefix), e.MetricsGet())
 
+	if reporter, ok := e.client.(ConnectionReporter); ok {
+		r.GET(fmt.Sprintf("%s/connection", prefix), e.ConnectionGet(reporter))
+	}
+
 	// New split configuration endpoints
 	r.GET(fmt.Sprintf("%s/battery-profile", prefix), e.configurer.BatteryProfileGet())
 	r.PATCH(fmt.Sprintf("%s/battery-profile", prefix), e.configurer.BatteryProfilePatch())
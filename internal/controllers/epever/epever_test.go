@@ -3,9 +3,14 @@ package epever
 import (
 	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/gin-gonic/gin"
+	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"github.com/lumberbarons/solar-controller/internal/estimator"
 	"github.com/lumberbarons/solar-controller/internal/testutil"
 )
 
@@ -19,8 +24,8 @@ func TestController_CollectAndPublish_FailureMetric(t *testing.T) {
 		mockMetrics := &MockMetricsCollector{}
 		mockPublisher := &testutil.MockMessagePublisher{}
 
-		collector := NewCollector(mockClient, mockMetrics)
-		configurer := NewConfigurer(mockClient, mockMetrics)
+		collector := NewCollector(mockClient, mockMetrics, standardReadSpan)
+		configurer := NewConfigurer(mockClient, mockMetrics, standardReadSpan)
 
 		controller := newControllerForTest(
 			mockClient,
@@ -92,7 +97,7 @@ func TestController_CollectAndPublish_FailureMetric(t *testing.T) {
 				case regBatterySOC:
 					return testutil.CreateModbusResponse(85), nil
 				case regEnergyGeneratedDaily:
-					return testutil.CreateModbusResponse(1550, 0), nil // low word, high word
+					return statisticsResponse(1550, 0, 250, 0), nil // 15.5 kWh, 2.5 A
 				case regControllerStatus:
 					return testutil.CreateModbusResponse(0x0004), nil
 				default:
@@ -104,8 +109,8 @@ func TestController_CollectAndPublish_FailureMetric(t *testing.T) {
 		mockMetrics := &MockMetricsCollector{}
 		mockPublisher := &testutil.MockMessagePublisher{}
 
-		collector := NewCollector(mockClient, mockMetrics)
-		configurer := NewConfigurer(mockClient, mockMetrics)
+		collector := NewCollector(mockClient, mockMetrics, standardReadSpan)
+		configurer := NewConfigurer(mockClient, mockMetrics, standardReadSpan)
 
 		controller := newControllerForTest(
 			mockClient,
@@ -118,14 +123,19 @@ func TestController_CollectAndPublish_FailureMetric(t *testing.T) {
 
 		controller.collectAndPublish()
 
+		if controller.Snapshot() == nil {
+			t.Error("Snapshot() = nil after a collection, want the status")
+		}
+
 		// Verify failure counter was NOT incremented
 		if mockMetrics.FailuresCount != 0 {
 			t.Errorf("Expected FailuresCount = 0, got %d", mockMetrics.FailuresCount)
 		}
 
-		// Verify 12 normal metrics were published (not the failure metric)
-		if len(mockPublisher.PublishCalls) != 12 {
-			t.Fatalf("Expected 12 publish calls for normal metrics, got %d", len(mockPublisher.PublishCalls))
+		// Verify 13 normal metrics and the availability were published (not
+		// the failure metric)
+		if len(mockPublisher.PublishCalls) != 14 {
+			t.Fatalf("Expected 14 publish calls, got %d", len(mockPublisher.PublishCalls))
 		}
 
 		// Verify none of the published metrics are the failure metric
@@ -139,9 +149,9 @@ func TestController_CollectAndPublish_FailureMetric(t *testing.T) {
 		metricNames := []string{
 			"array-voltage", "array-current", "array-power",
 			"charging-current", "charging-power",
-			"battery-voltage", "battery-soc", "battery-temp",
+			"battery-voltage", "battery-current", "battery-soc", "battery-temp",
 			"device-temp", "energy-generated-daily",
-			"charging-status", "collection-time",
+			"charging-status", "collection-time", "availability",
 		}
 
 		for _, expectedMetric := range metricNames {
@@ -191,4 +201,294 @@ func TestController_CollectAndPublish_FailureMetric(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("publishes only the fields that read on a partial collection", func(t *testing.T) {
+		bank := registerBank(map[uint16][]byte{
+			regArrayVoltage: testutil.CreateModbusResponse(1850),
+		})
+		mockClient := &MockModbusClient{
+			ReadInputRegistersFunc: func(ctx context.Context, address, quantity uint16) ([]byte, error) {
+				if address == regBatterySOC || address == regEnergyGeneratedDaily {
+					return nil, &testutil.ModbusTestError{Message: "timeout"}
+				}
+				return bank(ctx, address, quantity)
+			},
+		}
+
+		mockMetrics := &MockMetricsCollector{}
+		mockPublisher := &testutil.MockMessagePublisher{}
+		controller := newControllerForTest(
+			mockClient,
+			NewCollector(mockClient, mockMetrics, standardReadSpan),
+			NewConfigurer(mockClient, mockMetrics, standardReadSpan),
+			mockPublisher,
+			mockMetrics,
+			"test-device-1",
+		)
+
+		controller.collectAndPublish()
+
+		if mockMetrics.FailuresCount != 0 {
+			t.Errorf("FailuresCount = %d, want 0 for a partial collection", mockMetrics.FailuresCount)
+		}
+
+		published := make(map[string]string)
+		for _, call := range mockPublisher.PublishCalls {
+			published[strings.TrimPrefix(call.TopicSuffix, "test-device-1/epever/")] = call.Payload
+		}
+
+		for _, skipped := range []string{"battery-soc", "energy-generated-daily", "battery-current", "collection-failure"} {
+			if _, ok := published[skipped]; ok {
+				t.Errorf("%s was published", skipped)
+			}
+		}
+		if _, ok := published["array-voltage"]; !ok {
+			t.Error("array-voltage was not published")
+		}
+
+		var partial MetricPayload
+		if err := json.Unmarshal([]byte(published["collection-partial"]), &partial); err != nil {
+			t.Fatalf("collection-partial payload: %v", err)
+		}
+		if partial.Value != float64(3) {
+			t.Errorf("collection-partial = %v, want 3", partial.Value)
+		}
+		if len(published) != 12 {
+			t.Errorf("published %d metrics, want 9 fields, collection-time, collection-partial and availability", len(published))
+		}
+	})
+}
+
+// reportingModbusClient is a MockModbusClient that also reports a connection
+// status, as SerialModbusClient does.
+type reportingModbusClient struct {
+	MockModbusClient
+	status ConnectionStatus
+}
+
+func (r *reportingModbusClient) ConnectionStatus() ConnectionStatus {
+	return r.status
+}
+
+func TestController_ReportsConnectionStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	client := &reportingModbusClient{
+		MockModbusClient: MockModbusClient{
+			ReadInputRegistersFunc: testutil.CreateModbusError("epever serial port disconnected"),
+		},
+		status: ConnectionStatus{
+			State:               ConnectionDisconnected,
+			SerialPort:          "/dev/ttyUSB0",
+			ConsecutiveFailures: 3,
+		},
+	}
+	mockMetrics := &MockMetricsCollector{}
+
+	controller := newControllerForTest(
+		client,
+		NewCollector(client, mockMetrics, standardReadSpan),
+		NewConfigurer(client, mockMetrics, standardReadSpan),
+		&testutil.MockMessagePublisher{},
+		mockMetrics,
+		"test-device-1",
+	)
+
+	// A failed cycle still reports the link, so a disconnect is visible
+	controller.collectAndPublish()
+
+	if len(mockMetrics.ConnectionStatuses) != 1 {
+		t.Fatalf("Expected 1 connection status report, got %d", len(mockMetrics.ConnectionStatuses))
+	}
+	if got := mockMetrics.ConnectionStatuses[0].State; got != ConnectionDisconnected {
+		t.Errorf("Expected reported state %q, got %q", ConnectionDisconnected, got)
+	}
+
+	router := gin.New()
+	controller.RegisterEndpoints(router)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/epever/connection", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var status ConnectionStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Failed to unmarshal connection status: %v", err)
+	}
+	if status.State != ConnectionDisconnected || status.ConsecutiveFailures != 3 {
+		t.Errorf("Expected disconnected with 3 failures, got %+v", status)
+	}
+}
+
+func TestController_NoConnectionEndpointWithoutReporter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockClient := &MockModbusClient{}
+	mockMetrics := &MockMetricsCollector{}
+	controller := newControllerForTest(
+		mockClient,
+		NewCollector(mockClient, mockMetrics, standardReadSpan),
+		NewConfigurer(mockClient, mockMetrics, standardReadSpan),
+		&testutil.MockMessagePublisher{},
+		mockMetrics,
+		"test-device-1",
+	)
+
+	router := gin.New()
+	controller.RegisterEndpoints(router)
+
+	for _, route := range router.Routes() {
+		if route.Path == "/api/epever/connection" {
+			t.Error("connection endpoint registered for a client that cannot report it")
+		}
+	}
+}
+
+func TestController_NameAndType(t *testing.T) {
+	controller := &Controller{}
+	if controller.Name() != "epever" || controller.Type() != controllers.TypeChargeController {
+		t.Errorf("Name(), Type() = %q, %q, want epever, %q", controller.Name(), controller.Type(), controllers.TypeChargeController)
+	}
+}
+
+func TestController_Runtime(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	inputs := registerBank(map[uint16][]byte{
+		regBatterySOC:     testutil.CreateModbusResponse(80),
+		regBatteryCurrent: testutil.CreateModbusResponse(0xFE0C, 0xFFFF), // -5 A
+	})
+	newController := func() (*Controller, *MockModbusClient, *testutil.MockMessagePublisher, *MockMetricsCollector) {
+		client := fullConfigMockClient()
+		client.ReadInputRegistersFunc = inputs
+		metrics := &MockMetricsCollector{}
+		publisher := &testutil.MockMessagePublisher{}
+		controller := newControllerForTest(client, NewCollector(client, metrics, standardReadSpan), NewConfigurer(client, metrics, standardReadSpan),
+			publisher, metrics, "test-device-1")
+		return controller, client, publisher, metrics
+	}
+	published := func(publisher *testutil.MockMessagePublisher) map[string]string {
+		topics := make(map[string]string)
+		for _, call := range publisher.PublishCalls {
+			topics[strings.TrimPrefix(call.TopicSuffix, "test-device-1/epever/")] = call.Payload
+		}
+		return topics
+	}
+
+	t.Run("estimates from the battery capacity set on the controller", func(t *testing.T) {
+		controller, _, publisher, metrics := newController()
+		controller.collectAndPublish()
+
+		runtime := controller.last.Last().Runtime
+		if runtime == nil || runtime.State != estimator.Discharging || runtime.CapacityAh != 100 ||
+			runtime.CapacitySource != estimator.CapacityController {
+			t.Fatalf("runtime = %+v, want discharging against the controller's 100Ah", runtime)
+		}
+		// 80% of 100Ah at 5A
+		if want := 80.0 / 5 * 3600; *runtime.TimeToEmpty != want {
+			t.Errorf("time to empty = %v, want %v", *runtime.TimeToEmpty, want)
+		}
+
+		topics := published(publisher)
+		for _, metric := range []string{"battery-current", "time-to-empty", "runtime-current", "runtime-confidence"} {
+			if _, ok := topics[metric]; !ok {
+				t.Errorf("%s was not published", metric)
+			}
+		}
+		if len(metrics.SetRuntimeCalls) != 1 || metrics.SetRuntimeCalls[0] != runtime {
+			t.Errorf("SetRuntime calls = %v, want the estimate", metrics.SetRuntimeCalls)
+		}
+
+		router := gin.New()
+		router.GET("/api/epever/metrics", controller.MetricsGet())
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/epever/metrics", nil))
+		var body struct {
+			Runtime *estimator.Estimate `json:"runtime"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatalf("metrics body: %v", err)
+		}
+		if body.Runtime == nil || body.Runtime.TimeToEmpty == nil {
+			t.Errorf("runtime = %+v, want the estimate with the metrics", body.Runtime)
+		}
+	})
+
+	t.Run("a configured capacity replaces the controller's", func(t *testing.T) {
+		controller, _, _, _ := newController()
+		controller.runtime.CapacityAh = 200
+		controller.collectAndPublish()
+
+		runtime := controller.last.Last().Runtime
+		if runtime == nil || runtime.CapacityAh != 200 || runtime.CapacitySource != estimator.CapacityConfigured {
+			t.Errorf("runtime = %+v, want the configured 200Ah", runtime)
+		}
+	})
+
+	t.Run("the last capacity read stands in while the setting cannot be read", func(t *testing.T) {
+		controller, client, _, _ := newController()
+		controller.collectAndPublish()
+
+		client.ReadHoldingRegistersFunc = testutil.CreateModbusError("timeout")
+		controller.configurer.invalidateCache()
+		controller.collectAndPublish()
+
+		runtime := controller.last.Last().Runtime
+		if runtime == nil || runtime.CapacityAh != 100 || runtime.Samples != 2 {
+			t.Errorf("runtime = %+v, want two readings against the 100Ah read before", runtime)
+		}
+	})
+
+	t.Run("no estimate before the SOC is read", func(t *testing.T) {
+		controller, client, publisher, metrics := newController()
+		client.ReadInputRegistersFunc = func(ctx context.Context, address, quantity uint16) ([]byte, error) {
+			if address == regBatterySOC {
+				return nil, &testutil.ModbusTestError{Message: "timeout"}
+			}
+			return inputs(ctx, address, quantity)
+		}
+		controller.collectAndPublish()
+
+		if runtime := controller.last.Last().Runtime; runtime != nil {
+			t.Errorf("runtime = %+v, want none without a SOC", runtime)
+		}
+		if _, ok := published(publisher)["runtime-current"]; ok {
+			t.Error("runtime-current was published without an estimate")
+		}
+		if len(metrics.SetRuntimeCalls) != 1 || metrics.SetRuntimeCalls[0] != nil {
+			t.Errorf("SetRuntime calls = %v, want one clearing the gauges", metrics.SetRuntimeCalls)
+		}
+	})
+}
+
+func TestConvertRuntimeToMetrics(t *testing.T) {
+	seconds := 5400.0
+	estimate := &estimator.Estimate{Timestamp: 1699000000, Current: 8, Confidence: 75, TimeToFull: &seconds}
+
+	metrics := ConvertRuntimeToMetrics(estimate)
+	want := []struct {
+		name  string
+		value any
+		unit  string
+	}{
+		{"time-to-full", 5400.0, "seconds"},
+		{"runtime-current", 8.0, "amperes"},
+		{"runtime-confidence", 75.0, "percent"},
+	}
+	if len(metrics) != len(want) {
+		t.Fatalf("metrics length = %d, want %d", len(metrics), len(want))
+	}
+	for i, w := range want {
+		m := metrics[i]
+		if m.Name != w.name || m.Unit != w.unit || m.Value != w.value || m.Timestamp != estimate.Timestamp {
+			t.Errorf("metrics[%d] = %+v, want %s = %v %s", i, m, w.name, w.value, w.unit)
+		}
+	}
+
+	if metrics := ConvertRuntimeToMetrics(nil); len(metrics) != 0 {
+		t.Errorf("nil estimate gave %d metrics, want none", len(metrics))
+	}
 }
@@ -2,6 +2,8 @@ package epever
 
 import (
 	"context"
+
+	"github.com/lumberbarons/solar-controller/internal/estimator"
 )
 
 // ModbusClient defines the interface for Modbus communication operations.
@@ -29,6 +31,13 @@ type ModbusClient interface {
 	Close()
 }
 
+// ConnectionReporter is implemented by clients that track the state of their
+// link, so the controller can expose it. SerialModbusClient implements it.
+type ConnectionReporter interface {
+	// ConnectionStatus reports whether the link is up and how it has fared.
+	ConnectionStatus() ConnectionStatus
+}
+
 // MetricsCollector defines the interface for collecting and exposing metrics.
 // This abstraction allows for testing without the Prometheus global registry.
 type MetricsCollector interface {
@@ -43,4 +52,11 @@ type MetricsCollector interface {
 
 	// SetMetrics updates all metrics based on the provided status.
 	SetMetrics(status *ControllerStatus)
+
+	// SetConnectionStatus updates the serial link metrics.
+	SetConnectionStatus(status ConnectionStatus)
+
+	// SetRuntime updates the time-to-full and time-to-empty metrics. A nil
+	// estimate clears them.
+	SetRuntime(estimate *estimator.Estimate)
 }
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/estimator"
 )
 
 // Metric represents a single metric with its value, unit, and timestamp
@@ -37,7 +39,9 @@ func (m *Metric) ToJSON() (string, error) {
 	return string(b), nil
 }
 
-// ConvertStatusToMetrics converts a ControllerStatus into individual metrics
+// ConvertStatusToMetrics converts a ControllerStatus into individual metrics.
+// Only fields read in this collection are included; when any field is stale
+// or failed, a collection-partial metric counts them instead.
 func ConvertStatusToMetrics(status *ControllerStatus) []Metric {
 	if status == nil {
 		return []Metric{}
@@ -45,80 +49,94 @@ func ConvertStatusToMetrics(status *ControllerStatus) []Metric {
 
 	timestamp := status.Timestamp
 
-	return []Metric{
-		{
-			Name:      "array-voltage",
-			Value:     status.ArrayVoltage,
-			Unit:      "volts",
+	fields := []struct {
+		field  string
+		metric Metric
+	}{
+		{"arrayVoltage", Metric{Name: "array-voltage", Value: status.ArrayVoltage, Unit: "volts"}},
+		{"arrayCurrent", Metric{Name: "array-current", Value: status.ArrayCurrent, Unit: "amperes"}},
+		{"arrayPower", Metric{Name: "array-power", Value: status.ArrayPower, Unit: "watts"}},
+		{"chargingCurrent", Metric{Name: "charging-current", Value: status.ChargingCurrent, Unit: "amperes"}},
+		{"chargingPower", Metric{Name: "charging-power", Value: status.ChargingPower, Unit: "watts"}},
+		{"batteryVoltage", Metric{Name: "battery-voltage", Value: status.BatteryVoltage, Unit: "volts"}},
+		{"batteryCurrent", Metric{Name: "battery-current", Value: status.BatteryCurrent, Unit: "amperes"}},
+		{"batterySoc", Metric{Name: "battery-soc", Value: status.BatterySOC, Unit: "percent"}},
+		{"batteryTemp", Metric{Name: "battery-temp", Value: status.BatteryTemp, Unit: "celsius"}},
+		{"deviceTemp", Metric{Name: "device-temp", Value: status.DeviceTemp, Unit: "celsius"}},
+		{"energyGeneratedDaily", Metric{Name: "energy-generated-daily", Value: status.EnergyGeneratedDaily, Unit: "kilowatt-hours"}},
+		{"chargingStatus", Metric{Name: "charging-status", Value: status.ChargingStatus, Unit: "code"}},
+	}
+
+	metrics := make([]Metric, 0, len(fields)+2)
+	var notGood int
+	for _, f := range fields {
+		if !status.Good(f.field) {
+			notGood++
+			continue
+		}
+		f.metric.Timestamp = timestamp
+		metrics = append(metrics, f.metric)
+	}
+
+	metrics = append(metrics, Metric{
+		Name:      "collection-time",
+		Value:     status.CollectionTime,
+		Unit:      "seconds",
+		Timestamp: timestamp,
+	})
+
+	if notGood > 0 {
+		metrics = append(metrics, Metric{
+			Name:      "collection-partial",
+			Value:     notGood,
+			Unit:      "count",
 			Timestamp: timestamp,
-		},
-		{
-			Name:      "array-current",
-			Value:     status.ArrayCurrent,
-			Unit:      "amperes",
+		})
+	}
+
+	return metrics
+}
+
+// ConvertRuntimeToMetrics converts the runtime estimate into time-to-full
+// while charging or time-to-empty while discharging, with the smoothed
+// current the estimate is from and the confidence in it.
+func ConvertRuntimeToMetrics(estimate *estimator.Estimate) []Metric {
+	if estimate == nil {
+		return []Metric{}
+	}
+
+	timestamp := estimate.Timestamp
+	metrics := make([]Metric, 0, 3)
+	if estimate.TimeToFull != nil {
+		metrics = append(metrics, Metric{
+			Name:      "time-to-full",
+			Value:     *estimate.TimeToFull,
+			Unit:      "seconds",
 			Timestamp: timestamp,
-		},
-		{
-			Name:      "array-power",
-			Value:     status.ArrayPower,
-			Unit:      "watts",
+		})
+	}
+	if estimate.TimeToEmpty != nil {
+		metrics = append(metrics, Metric{
+			Name:      "time-to-empty",
+			Value:     *estimate.TimeToEmpty,
+			Unit:      "seconds",
 			Timestamp: timestamp,
-		},
-		{
-			Name:      "charging-current",
-			Value:     status.ChargingCurrent,
+		})
+	}
+	return append(metrics,
+		Metric{
+			Name:      "runtime-current",
+			Value:     estimate.Current,
 			Unit:      "amperes",
 			Timestamp: timestamp,
 		},
-		{
-			Name:      "charging-power",
-			Value:     status.ChargingPower,
-			Unit:      "watts",
-			Timestamp: timestamp,
-		},
-		{
-			Name:      "battery-voltage",
-			Value:     status.BatteryVoltage,
-			Unit:      "volts",
-			Timestamp: timestamp,
-		},
-		{
-			Name:      "battery-soc",
-			Value:     status.BatterySOC,
+		Metric{
+			Name:      "runtime-confidence",
+			Value:     estimate.Confidence,
 			Unit:      "percent",
 			Timestamp: timestamp,
 		},
-		{
-			Name:      "battery-temp",
-			Value:     status.BatteryTemp,
-			Unit:      "celsius",
-			Timestamp: timestamp,
-		},
-		{
-			Name:      "device-temp",
-			Value:     status.DeviceTemp,
-			Unit:      "celsius",
-			Timestamp: timestamp,
-		},
-		{
-			Name:      "energy-generated-daily",
-			Value:     status.EnergyGeneratedDaily,
-			Unit:      "kilowatt-hours",
-			Timestamp: timestamp,
-		},
-		{
-			Name:      "charging-status",
-			Value:     status.ChargingStatus,
-			Unit:      "code",
-			Timestamp: timestamp,
-		},
-		{
-			Name:      "collection-time",
-			Value:     status.CollectionTime,
-			Unit:      "seconds",
-			Timestamp: timestamp,
-		},
-	}
+	)
 }
 
 // CreateCollectionFailureMetric creates a failure metric when collection fails
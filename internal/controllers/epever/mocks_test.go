@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"sync"
+
+	"github.com/lumberbarons/solar-controller/internal/estimator"
+	"github.com/lumberbarons/solar-controller/internal/testutil"
 )
 
 // MockModbusClient is a mock implementation of the ModbusClient interface for testing.
@@ -45,9 +48,44 @@ type WriteMultipleRegistersCall struct {
 	Value    []byte
 }
 
+// registerBank serves reads of any span from blocks of register data keyed by
+// their start address, the way a device would, so a mock stays valid whichever
+// reads the planner merges. Registers no block covers read as zero.
+func registerBank(blocks map[uint16][]byte) func(ctx context.Context, address, quantity uint16) ([]byte, error) {
+	words := make(map[uint16][2]byte)
+	for start, data := range blocks {
+		for i := 0; i+1 < len(data); i += 2 {
+			words[start+uint16(i/2)] = [2]byte{data[i], data[i+1]}
+		}
+	}
+
+	return func(_ context.Context, address, quantity uint16) ([]byte, error) {
+		data := make([]byte, 0, 2*int(quantity))
+		for i := range quantity {
+			word := words[address+i]
+			data = append(data, word[0], word[1])
+		}
+		return data, nil
+	}
+}
+
+// statisticsResponse is the planned read of 0x330C-0x331C: the daily energy
+// and the battery current, each a low word then a high word, with the
+// registers between them zero.
+func statisticsResponse(energyLow, energyHigh, currentLow, currentHigh uint16) []byte {
+	words := make([]uint16, regBatteryCurrent-regEnergyGeneratedDaily+2)
+	words[0], words[1] = energyLow, energyHigh
+	words[len(words)-2], words[len(words)-1] = currentLow, currentHigh
+	return testutil.CreateModbusResponse(words...)
+}
+
 // Verify MockModbusClient implements ModbusClient
 var _ ModbusClient = (*MockModbusClient)(nil)
 
+// standardReadSpan is the standard profile's read span, which most tests
+// plan their reads with.
+var standardReadSpan = timingProfiles[ProfileStandard].MaxReadSpan
+
 func (m *MockModbusClient) ReadInputRegisters(ctx context.Context, address, quantity uint16) ([]byte, error) {
 	m.mu.Lock()
 	m.ReadInputRegistersCalls = append(m.ReadInputRegistersCalls, ReadRegistersCall{Address: address, Quantity: quantity})
@@ -137,6 +175,7 @@ type MockMetricsCollector struct {
 	IncrementWriteFailuresFunc   func()
 	IncrementRegisterFailureFunc func(address uint16, registerType string)
 	SetMetricsFunc               func(status *ControllerStatus)
+	SetConnectionStatusFunc      func(status ConnectionStatus)
 
 	// Call tracking
 	FailuresCount        int
@@ -144,6 +183,8 @@ type MockMetricsCollector struct {
 	RegisterFailureCount int
 	RegisterFailures     []RegisterFailureCall
 	SetMetricsCalls      []*ControllerStatus
+	ConnectionStatuses   []ConnectionStatus
+	SetRuntimeCalls      []*estimator.Estimate
 }
 
 // RegisterFailureCall tracks individual register failure calls
@@ -198,3 +239,19 @@ func (m *MockMetricsCollector) SetMetrics(status *ControllerStatus) {
 		m.SetMetricsFunc(status)
 	}
 }
+
+func (m *MockMetricsCollector) SetConnectionStatus(status ConnectionStatus) {
+	m.mu.Lock()
+	m.ConnectionStatuses = append(m.ConnectionStatuses, status)
+	m.mu.Unlock()
+
+	if m.SetConnectionStatusFunc != nil {
+		m.SetConnectionStatusFunc(status)
+	}
+}
+
+func (m *MockMetricsCollector) SetRuntime(estimate *estimator.Estimate) {
+	m.mu.Lock()
+	m.SetRuntimeCalls = append(m.SetRuntimeCalls, estimate)
+	m.mu.Unlock()
+}
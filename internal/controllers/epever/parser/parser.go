@@ -79,6 +79,18 @@ func ParseFloat32(data []byte) (float32, error) {
 	return float32(binary.BigEndian.Uint32(swappedData)) / ValueDivisor, nil
 }
 
+// ParseSignedFloat32 parses two consecutive registers (4 bytes) as a signed
+// 32-bit value with the same word order as ParseFloat32, such as the battery
+// current, which is negative while discharging. The value is divided by 100.
+func ParseSignedFloat32(data []byte) (float32, error) {
+	if len(data) < 4 {
+		return 0, fmt.Errorf("insufficient data for float32: expected 4 bytes, got %d", len(data))
+	}
+
+	raw := uint32(binary.BigEndian.Uint16(data[2:4]))<<16 | uint32(binary.BigEndian.Uint16(data[0:2]))
+	return float32(int32(raw)) / ValueDivisor, nil
+}
+
 // ParseSignedTemperature converts a raw temperature value to a signed float32.
 // Epever devices use an unsigned representation where values >= 32768 represent negative temperatures.
 // The final value is divided by 100 to convert from centi-degrees to degrees.
@@ -258,6 +258,49 @@ func TestParseFloat32(t *testing.T) {
 	}
 }
 
+func TestParseSignedFloat32(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    float32
+		wantErr bool
+	}{
+		{
+			name: "charging at 12.34 A",
+			data: createFloat32Data(1234),
+			want: 12.34,
+		},
+		{
+			name: "discharging at 5.00 A",
+			data: createFloat32Data(uint32(0xFFFFFE0C)), // -500 centiamps
+			want: -5.00,
+		},
+		{
+			name: "large value across both words",
+			data: createFloat32Data(120000),
+			want: 1200.00,
+		},
+		{
+			name:    "insufficient data",
+			data:    []byte{0x00, 0x00},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSignedFloat32(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseSignedFloat32() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !floatEqual(got, tt.want) {
+				t.Errorf("ParseSignedFloat32() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestParseSignedTemperature(t *testing.T) {
 	tests := []struct {
 		name string
@@ -0,0 +1,258 @@
+package epever
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/lumberbarons/modbus"
+)
+
+// maxReadQuantity is the Modbus limit on registers in one read.
+const maxReadQuantity = 125
+
+// registerRange is count registers starting at start.
+type registerRange struct {
+	start uint16
+	count uint16
+}
+
+// end returns the address after the last register.
+func (r registerRange) end() uint32 {
+	return uint32(r.start) + uint32(r.count)
+}
+
+func (r registerRange) String() string {
+	return fmt.Sprintf("0x%04X-0x%04X", r.start, r.end()-1)
+}
+
+// Registers the Epever protocol leaves undefined, which some firmware answers
+// with an exception. A read may not span them. 0x3108-0x310F are undefined
+// too, but every unit tested reads across them.
+var (
+	epeverInputGaps = []registerRange{
+		{0x3113, 7}, // between device temperature and SOC
+	}
+	epeverHoldingGaps = []registerRange{
+		{0x900F, 4}, // between discharging limit voltage and the clock
+	}
+)
+
+// readPlanner turns the registers a cycle needs into as few reads as it can:
+// ranges are merged, including across registers nobody asked for, as long as
+// the read stays within maxSpan and does not touch a known unreadable gap.
+//
+// A merged read that the device rejects with an exception is retried as its
+// separate parts, and the registers it bridged are remembered as unreadable
+// so later plans avoid them. Where two of its parts were adjacent there is
+// nothing to avoid, so the address they met at is remembered as a split no
+// later read may cross.
+type readPlanner struct {
+	maxSpan uint16
+
+	mu         sync.Mutex
+	unreadable []registerRange
+	splits     []uint16
+}
+
+func newReadPlanner(maxSpan uint16, unreadable []registerRange) *readPlanner {
+	return &readPlanner{
+		maxSpan:    min(max(maxSpan, 1), maxReadQuantity),
+		unreadable: slices.Clone(unreadable),
+	}
+}
+
+// plannedRead is one read and the requested ranges it covers.
+type plannedRead struct {
+	registerRange
+	parts []registerRange
+}
+
+// plan returns the reads that cover needed, in address order.
+func (p *readPlanner) plan(needed []registerRange) []plannedRead {
+	ranges := slices.Clone(needed)
+	slices.SortFunc(ranges, func(a, b registerRange) int {
+		return int(a.start) - int(b.start)
+	})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var reads []plannedRead
+	for _, r := range ranges {
+		if r.count == 0 {
+			continue
+		}
+		if n := len(reads); n > 0 && p.canExtend(reads[n-1].registerRange, r) {
+			last := &reads[n-1]
+			last.count = uint16(max(last.end(), r.end()) - uint32(last.start))
+			last.parts = append(last.parts, r)
+			continue
+		}
+		reads = append(reads, plannedRead{registerRange: r, parts: []registerRange{r}})
+	}
+	return reads
+}
+
+// canExtend reports whether read can grow to cover next. The caller must
+// hold the lock.
+func (p *readPlanner) canExtend(read, next registerRange) bool {
+	end := max(read.end(), next.end())
+	if end-uint32(read.start) > uint32(p.maxSpan) {
+		return false
+	}
+	for _, split := range p.splits {
+		if read.end() <= uint32(split) && split <= next.start {
+			return false
+		}
+	}
+	if uint32(next.start) <= read.end() {
+		// Adjacent or overlapping: nothing to bridge
+		return true
+	}
+
+	bridge := registerRange{start: uint16(read.end()), count: uint16(uint32(next.start) - read.end())}
+	for _, gap := range p.unreadable {
+		if uint32(gap.start) < bridge.end() && uint32(bridge.start) < gap.end() {
+			return false
+		}
+	}
+	return true
+}
+
+// markUnreadable records that the registers a merged read bridged between its
+// parts cannot be read, and that its adjacent parts cannot be read together.
+func (p *readPlanner) markUnreadable(read plannedRead) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	covered := read.parts[0].end()
+	for _, part := range read.parts[1:] {
+		switch {
+		case uint32(part.start) > covered:
+			gap := registerRange{start: uint16(covered), count: uint16(uint32(part.start) - covered)}
+			p.unreadable = append(p.unreadable, gap)
+			log.Warnf("epever rejected a read across %s; reading around it from now on", gap)
+		case uint32(part.start) == covered:
+			p.splits = append(p.splits, part.start)
+			log.Warnf("epever rejected a read across 0x%04X; reading either side of it separately from now on", part.start)
+		}
+		covered = max(covered, part.end())
+	}
+}
+
+// registerReadError is a read the planner could not complete. Range is the
+// read that failed, for the per-register failure metric.
+type registerReadError struct {
+	Range registerRange
+	Err   error
+}
+
+func (e *registerReadError) Error() string {
+	return fmt.Sprintf("failed to read registers %s: %v", e.Range, e.Err)
+}
+
+func (e *registerReadError) Unwrap() error {
+	return e.Err
+}
+
+// readFunc is ModbusClient.ReadInputRegisters or ReadHoldingRegisters.
+type readFunc func(ctx context.Context, address, quantity uint16) ([]byte, error)
+
+// read fetches the needed registers and returns their values by address. A
+// failed read does not stop the ones after it: the values hold everything
+// that was read, and the error joins a registerReadError for each read that
+// failed.
+func (p *readPlanner) read(ctx context.Context, readFn readFunc, needed []registerRange) (registerValues, error) {
+	values := make(registerValues)
+	var failed []error
+
+	for _, planned := range p.plan(needed) {
+		if err := ctx.Err(); err != nil {
+			failed = append(failed, &registerReadError{Range: planned.registerRange, Err: err})
+			continue
+		}
+
+		err := values.fetch(ctx, readFn, planned.registerRange)
+
+		var exception *modbus.ModbusError
+		if err != nil && len(planned.parts) > 1 && errors.As(err, &exception) {
+			p.markUnreadable(planned)
+			for _, part := range planned.parts {
+				if err = values.fetch(ctx, readFn, part); err != nil {
+					failed = append(failed, &registerReadError{Range: part, Err: err})
+				}
+			}
+			continue
+		}
+		if err != nil {
+			failed = append(failed, &registerReadError{Range: planned.registerRange, Err: err})
+		}
+	}
+
+	return values, errors.Join(failed...)
+}
+
+// failedReads returns the reads an error from read reports as failed.
+func failedReads(err error) []*registerReadError {
+	var errs []error
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	} else if err != nil {
+		errs = []error{err}
+	}
+
+	var failed []*registerReadError
+	for _, e := range errs {
+		var readErr *registerReadError
+		if errors.As(e, &readErr) {
+			failed = append(failed, readErr)
+		}
+	}
+	return failed
+}
+
+// registerValues holds raw register words by address.
+type registerValues map[uint16]uint16
+
+// fetch performs one read and stores every register it returns.
+func (v registerValues) fetch(ctx context.Context, readFn readFunc, r registerRange) error {
+	log.Debugf("Reading registers %s (quantity: %d)", r, r.count)
+	data, err := readFn(ctx, r.start, r.count)
+	if err != nil {
+		return err
+	}
+	if len(data) < int(r.count)*2 {
+		return fmt.Errorf("insufficient data: expected %d bytes, got %d", int(r.count)*2, len(data))
+	}
+	for i := range r.count {
+		v[r.start+i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+	}
+	return nil
+}
+
+// has reports whether every register in r was read.
+func (v registerValues) has(r registerRange) bool {
+	for i := range r.count {
+		if _, ok := v[r.start+i]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// bytes returns count registers from address in wire order, for the parser
+// package. Registers that were not read are zero.
+func (v registerValues) bytes(address, count uint16) []byte {
+	data := make([]byte, 2*int(count))
+	for i := range count {
+		word := v[address+i]
+		data[2*i] = byte(word >> 8)
+		data[2*i+1] = byte(word)
+	}
+	return data
+}
@@ -0,0 +1,317 @@
+package epever
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+
+	"github.com/lumberbarons/modbus"
+)
+
+func spans(reads []plannedRead) []registerRange {
+	out := make([]registerRange, len(reads))
+	for i, read := range reads {
+		out[i] = read.registerRange
+	}
+	return out
+}
+
+func TestReadPlanner_Plan(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxSpan    uint16
+		unreadable []registerRange
+		needed     []registerRange
+		want       []registerRange
+	}{
+		{
+			name:    "adjacent ranges become one read",
+			maxSpan: 32,
+			needed:  []registerRange{{0x9000, 3}, {0x9003, 12}},
+			want:    []registerRange{{0x9000, 15}},
+		},
+		{
+			name:    "unrequested registers are bridged",
+			maxSpan: 32,
+			needed:  []registerRange{{0x3100, 4}, {0x3104, 4}, {0x3110, 2}},
+			want:    []registerRange{{0x3100, 18}},
+		},
+		{
+			name:    "input order does not matter",
+			maxSpan: 32,
+			needed:  []registerRange{{0x3110, 2}, {0x3100, 4}},
+			want:    []registerRange{{0x3100, 18}},
+		},
+		{
+			name:    "overlapping and duplicate ranges",
+			maxSpan: 32,
+			needed:  []registerRange{{0x9013, 3}, {0x9014, 1}, {0x9013, 3}, {0x9015, 2}},
+			want:    []registerRange{{0x9013, 4}},
+		},
+		{
+			name:    "a merge may not exceed the max span",
+			maxSpan: 16,
+			needed:  []registerRange{{0x9000, 3}, {0x9003, 12}, {0x9010, 2}},
+			want:    []registerRange{{0x9000, 15}, {0x9010, 2}},
+		},
+		{
+			name:    "a range longer than the span is still read whole",
+			maxSpan: 4,
+			needed:  []registerRange{{0x9003, 12}},
+			want:    []registerRange{{0x9003, 12}},
+		},
+		{
+			name:       "known gaps are never bridged",
+			maxSpan:    32,
+			unreadable: epeverHoldingGaps,
+			needed:     []registerRange{{0x9000, 15}, {0x9013, 3}},
+			want:       []registerRange{{0x9000, 15}, {0x9013, 3}},
+		},
+		{
+			name:    "zero-length ranges are ignored",
+			maxSpan: 32,
+			needed:  []registerRange{{0x9000, 0}, {0x9013, 1}},
+			want:    []registerRange{{0x9013, 1}},
+		},
+		{
+			name:    "ranges at the top of the address space",
+			maxSpan: 32,
+			needed:  []registerRange{{0xFFFE, 2}, {0xFFF0, 1}},
+			want:    []registerRange{{0xFFF0, 16}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planner := newReadPlanner(tt.maxSpan, tt.unreadable)
+			if got := spans(planner.plan(tt.needed)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("plan = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadPlanner_EpeverLayouts(t *testing.T) {
+	input := newReadPlanner(timingProfiles[ProfileStandard].MaxReadSpan, epeverInputGaps)
+	wantInput := []registerRange{{0x3100, 18}, {0x311A, 1}, {0x3201, 1}, {0x330C, 17}}
+	if got := spans(input.plan(statusRegisters)); !reflect.DeepEqual(got, wantInput) {
+		t.Errorf("status reads = %v, want %v", got, wantInput)
+	}
+
+	holding := newReadPlanner(timingProfiles[ProfileStandard].MaxReadSpan, epeverHoldingGaps)
+	wantHolding := []registerRange{{0x9000, 15}, {0x9013, 8}, {0x906B, 2}}
+	if got := spans(holding.plan(configRegisters)); !reflect.DeepEqual(got, wantHolding) {
+		t.Errorf("config reads = %v, want %v", got, wantHolding)
+	}
+}
+
+func TestReadPlanner_ReadDecodesByAddress(t *testing.T) {
+	mockClient := &MockModbusClient{
+		ReadHoldingRegistersFunc: registerBank(map[uint16][]byte{
+			0x9000: testutil.CreateModbusResponse(1, 2, 3),
+			0x9005: testutil.CreateModbusResponse(5, 6),
+		}),
+	}
+
+	planner := newReadPlanner(32, nil)
+	values, err := planner.read(context.Background(), mockClient.ReadHoldingRegisters,
+		[]registerRange{{0x9000, 2}, {0x9005, 2}})
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+
+	if n := len(mockClient.ReadHoldingRegistersCalls); n != 1 {
+		t.Errorf("made %d reads, want 1", n)
+	}
+	if values[0x9001] != 2 || values[0x9006] != 6 {
+		t.Errorf("values = %v, want 0x9001=2 and 0x9006=6", values)
+	}
+	if got := values.bytes(0x9005, 2); !reflect.DeepEqual(got, []byte{0, 5, 0, 6}) {
+		t.Errorf("bytes = %v, want [0 5 0 6]", got)
+	}
+}
+
+func TestReadPlanner_ReadReportsTheFailedRange(t *testing.T) {
+	timeout := errors.New("connection timeout")
+	mockClient := &MockModbusClient{
+		ReadHoldingRegistersFunc: func(_ context.Context, address, _ uint16) ([]byte, error) {
+			if address == 0x9013 {
+				return nil, timeout
+			}
+			return make([]byte, 64), nil
+		},
+	}
+
+	planner := newReadPlanner(32, epeverHoldingGaps)
+	_, err := planner.read(context.Background(), mockClient.ReadHoldingRegisters, configRegisters)
+
+	var readErr *registerReadError
+	if !errors.As(err, &readErr) {
+		t.Fatalf("read = %v, want a registerReadError", err)
+	}
+	if readErr.Range != (registerRange{0x9013, 8}) {
+		t.Errorf("failed range = %v, want 0x9013-0x901A", readErr.Range)
+	}
+	if !errors.Is(err, timeout) {
+		t.Errorf("read = %v, want it to wrap the client error", err)
+	}
+}
+
+func TestReadPlanner_ReadRejectsShortResponses(t *testing.T) {
+	mockClient := &MockModbusClient{
+		ReadHoldingRegistersFunc: func(_ context.Context, _, _ uint16) ([]byte, error) {
+			return []byte{0, 1}, nil
+		},
+	}
+
+	planner := newReadPlanner(32, nil)
+	_, err := planner.read(context.Background(), mockClient.ReadHoldingRegisters, []registerRange{{0x9000, 3}})
+	if err == nil {
+		t.Error("read accepted 2 bytes for 3 registers")
+	}
+}
+
+// A device that rejects a merged read gets its parts read separately, and the
+// bridged registers are avoided from then on.
+func TestReadPlanner_LearnsUnreadableGaps(t *testing.T) {
+	bank := registerBank(map[uint16][]byte{
+		0x3100: testutil.CreateModbusResponse(1, 2),
+		0x3104: testutil.CreateModbusResponse(3),
+	})
+	mockClient := &MockModbusClient{
+		ReadInputRegistersFunc: func(ctx context.Context, address, quantity uint16) ([]byte, error) {
+			if address <= 0x3102 && uint32(address)+uint32(quantity) > 0x3102 {
+				return nil, &modbus.ModbusError{FunctionCode: 0x04, ExceptionCode: modbus.ExceptionCodeIllegalDataAddress}
+			}
+			return bank(ctx, address, quantity)
+		},
+	}
+
+	needed := []registerRange{{0x3100, 2}, {0x3104, 1}}
+	planner := newReadPlanner(32, nil)
+
+	values, err := planner.read(context.Background(), mockClient.ReadInputRegisters, needed)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if values[0x3101] != 2 || values[0x3104] != 3 {
+		t.Errorf("values = %v, want 0x3101=2 and 0x3104=3", values)
+	}
+
+	wantCalls := []ReadRegistersCall{{0x3100, 5}, {0x3100, 2}, {0x3104, 1}}
+	if !reflect.DeepEqual(mockClient.ReadInputRegistersCalls, wantCalls) {
+		t.Errorf("calls = %v, want %v", mockClient.ReadInputRegistersCalls, wantCalls)
+	}
+
+	want := []registerRange{{0x3100, 2}, {0x3104, 1}}
+	if got := spans(planner.plan(needed)); !reflect.DeepEqual(got, want) {
+		t.Errorf("plan after the exception = %v, want %v", got, want)
+	}
+}
+
+// Adjacent ranges bridge no registers, so a rejected merge of them is
+// remembered as a split rather than a gap, and not tried again every cycle.
+func TestReadPlanner_LearnsUnmergeableBoundaries(t *testing.T) {
+	bank := registerBank(map[uint16][]byte{0x3100: testutil.CreateModbusResponse(1, 2, 3, 4)})
+	mockClient := &MockModbusClient{
+		ReadInputRegistersFunc: func(ctx context.Context, address, quantity uint16) ([]byte, error) {
+			if address < 0x3102 && uint32(address)+uint32(quantity) > 0x3102 {
+				return nil, &modbus.ModbusError{FunctionCode: 0x04, ExceptionCode: modbus.ExceptionCodeIllegalDataAddress}
+			}
+			return bank(ctx, address, quantity)
+		},
+	}
+
+	needed := []registerRange{{0x3100, 2}, {0x3102, 2}}
+	planner := newReadPlanner(32, nil)
+
+	for range 2 {
+		values, err := planner.read(context.Background(), mockClient.ReadInputRegisters, needed)
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		if values[0x3101] != 2 || values[0x3103] != 4 {
+			t.Errorf("values = %v, want 0x3101=2 and 0x3103=4", values)
+		}
+	}
+
+	wantCalls := []ReadRegistersCall{{0x3100, 4}, {0x3100, 2}, {0x3102, 2}, {0x3100, 2}, {0x3102, 2}}
+	if !reflect.DeepEqual(mockClient.ReadInputRegistersCalls, wantCalls) {
+		t.Errorf("calls = %v, want %v", mockClient.ReadInputRegistersCalls, wantCalls)
+	}
+
+	// Ranges on either side that leave a gap at the split still stay apart
+	want := []registerRange{{0x3101, 1}, {0x3103, 1}}
+	if got := spans(planner.plan([]registerRange{{0x3101, 1}, {0x3103, 1}})); !reflect.DeepEqual(got, want) {
+		t.Errorf("plan across the split = %v, want %v", got, want)
+	}
+}
+
+func TestReadPlanner_ExceptionOnASingleRangeIsReturned(t *testing.T) {
+	exception := &modbus.ModbusError{FunctionCode: 0x03, ExceptionCode: modbus.ExceptionCodeIllegalDataAddress}
+	mockClient := &MockModbusClient{
+		ReadHoldingRegistersFunc: func(_ context.Context, _, _ uint16) ([]byte, error) {
+			return nil, exception
+		},
+	}
+
+	planner := newReadPlanner(32, nil)
+	_, err := planner.read(context.Background(), mockClient.ReadHoldingRegisters, []registerRange{{0x9000, 3}})
+	if !errors.Is(err, exception) {
+		t.Errorf("read = %v, want the exception", err)
+	}
+	if n := len(mockClient.ReadHoldingRegistersCalls); n != 1 {
+		t.Errorf("made %d reads, want 1: there is nothing to split", n)
+	}
+}
+
+func TestCollector_GetStatusUsesPlannedReads(t *testing.T) {
+	mockClient := &MockModbusClient{ReadInputRegistersFunc: registerBank(nil)}
+	collector := NewCollector(mockClient, &MockMetricsCollector{}, standardReadSpan)
+
+	if _, err := collector.GetStatus(context.Background()); err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+
+	want := []ReadRegistersCall{{0x3100, 18}, {0x311A, 1}, {0x3201, 1}, {0x330C, 17}}
+	if !reflect.DeepEqual(mockClient.ReadInputRegistersCalls, want) {
+		t.Errorf("reads = %v, want %v", mockClient.ReadInputRegistersCalls, want)
+	}
+}
+
+func TestConfigurer_GetConfigUsesPlannedReads(t *testing.T) {
+	mockClient := fullConfigMockClient()
+	configurer := NewConfigurer(mockClient, &MockMetricsCollector{}, standardReadSpan)
+
+	if _, err := configurer.getConfig(context.Background()); err != nil {
+		t.Fatalf("getConfig failed: %v", err)
+	}
+
+	want := []ReadRegistersCall{{0x9000, 15}, {0x9013, 8}, {0x906B, 2}}
+	if !reflect.DeepEqual(mockClient.ReadHoldingRegistersCalls, want) {
+		t.Errorf("reads = %v, want %v", mockClient.ReadHoldingRegistersCalls, want)
+	}
+}
+
+func TestConfigurer_GetConfigCountsTheFailedRead(t *testing.T) {
+	mockClient := &MockModbusClient{
+		ReadHoldingRegistersFunc: func(_ context.Context, address, _ uint16) ([]byte, error) {
+			if address == 0x906B {
+				return nil, errors.New("connection timeout")
+			}
+			return make([]byte, 64), nil
+		},
+	}
+	metrics := &MockMetricsCollector{}
+	configurer := NewConfigurer(mockClient, metrics, standardReadSpan)
+
+	if _, err := configurer.getConfig(context.Background()); err == nil {
+		t.Fatal("getConfig succeeded, want the read error")
+	}
+	if len(metrics.RegisterFailures) != 1 || metrics.RegisterFailures[0].Address != 0x906B {
+		t.Errorf("register failures = %v, want one at 0x906B", metrics.RegisterFailures)
+	}
+}
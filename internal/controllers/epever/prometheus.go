@@ -3,6 +3,7 @@ package epever
 import (
 	"fmt"
 
+	"github.com/lumberbarons/solar-controller/internal/estimator"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -20,6 +21,7 @@ type PrometheusCollector struct {
 	chargingCurrent prometheus.Gauge
 
 	batteryVoltage prometheus.Gauge
+	batteryCurrent prometheus.Gauge
 	batterySoc     prometheus.Gauge
 	batteryTemp    prometheus.Gauge
 
@@ -28,6 +30,20 @@ type PrometheusCollector struct {
 	energyGeneratedDaily prometheus.Gauge
 
 	chargingStatus prometheus.Gauge
+
+	connected           prometheus.Gauge
+	consecutiveFailures prometheus.Gauge
+	reconnects          prometheus.Gauge
+	interFrameDelay     prometheus.Gauge
+
+	fieldQuality *prometheus.GaugeVec
+
+	// The runtime gauges have no labels; they are vectors so that one that
+	// does not apply can be removed rather than left at a stale value
+	timeToFull        *prometheus.GaugeVec
+	timeToEmpty       *prometheus.GaugeVec
+	runtimeCurrent    *prometheus.GaugeVec
+	runtimeConfidence *prometheus.GaugeVec
 }
 
 func NewPrometheusCollector() *PrometheusCollector {
@@ -110,6 +126,12 @@ func (e *PrometheusCollector) initializeMetrics() {
 		Help:      "Battery voltage (V).",
 	})
 
+	e.batteryCurrent = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "battery_current",
+		Help:      "Battery net current (A), positive when charging.",
+	})
+
 	e.batterySoc = promauto.NewGauge(prometheus.GaugeOpts{
 		Namespace: namespace,
 		Name:      "battery_soc",
@@ -139,23 +161,127 @@ func (e *PrometheusCollector) initializeMetrics() {
 		Name:      "charging_status",
 		Help:      "Charging status.",
 	})
+
+	e.connected = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "connected",
+		Help:      "Whether the serial port is open (1) or waiting to reconnect (0).",
+	})
+
+	e.consecutiveFailures = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "consecutive_failures",
+		Help:      "Modbus operations that have failed in a row on the serial port.",
+	})
+
+	e.reconnects = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "reconnects",
+		Help:      "Times the serial port has been reopened since startup.",
+	})
+
+	e.interFrameDelay = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "inter_frame_delay_seconds",
+		Help:      "Gap between Modbus requests; moves with the error rate when adaptive pacing is on.",
+	})
+
+	e.fieldQuality = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "field_quality",
+		Help:      "Quality of each status field in the last collection: 1 for its current quality, 0 for the others.",
+	}, []string{"field", "quality"})
+
+	newRuntimeGauge := func(name, help string) *prometheus.GaugeVec {
+		return promauto.NewGaugeVec(prometheus.GaugeOpts{Namespace: namespace, Name: name, Help: help}, nil)
+	}
+	e.timeToFull = newRuntimeGauge("time_to_full_seconds",
+		"Estimated time until the battery is full at the smoothed charge current (s).")
+	e.timeToEmpty = newRuntimeGauge("time_to_empty_seconds",
+		"Estimated time until the battery is down to the reserve SOC at the smoothed discharge current (s).")
+	e.runtimeCurrent = newRuntimeGauge("runtime_current",
+		"Battery net current averaged over the runtime smoothing window (A), positive when charging.")
+	e.runtimeConfidence = newRuntimeGauge("runtime_confidence",
+		"Confidence in the runtime estimate from how full and steady its smoothing window is (%).")
 }
 
+// SetMetrics updates the gauges from a collection. Gauges for fields that
+// were not read keep their last value; field_quality says which those are.
 func (e *PrometheusCollector) SetMetrics(status *ControllerStatus) {
-	e.panelVoltage.Set(float64(status.ArrayVoltage))
-	e.panelCurrent.Set(float64(status.ArrayCurrent))
-	e.panelPower.Set(float64(status.ArrayPower))
+	gauges := []struct {
+		field string
+		gauge prometheus.Gauge
+		value float64
+	}{
+		{"arrayVoltage", e.panelVoltage, float64(status.ArrayVoltage)},
+		{"arrayCurrent", e.panelCurrent, float64(status.ArrayCurrent)},
+		{"arrayPower", e.panelPower, float64(status.ArrayPower)},
+		{"chargingPower", e.chargingPower, float64(status.ChargingPower)},
+		{"chargingCurrent", e.chargingCurrent, float64(status.ChargingCurrent)},
+		{"batteryVoltage", e.batteryVoltage, float64(status.BatteryVoltage)},
+		{"batteryCurrent", e.batteryCurrent, float64(status.BatteryCurrent)},
+		{"batterySoc", e.batterySoc, float64(status.BatterySOC)},
+		{"batteryTemp", e.batteryTemp, float64(status.BatteryTemp)},
+		{"deviceTemp", e.deviceTemp, float64(status.DeviceTemp)},
+		{"energyGeneratedDaily", e.energyGeneratedDaily, float64(status.EnergyGeneratedDaily)},
+		{"chargingStatus", e.chargingStatus, float64(status.ChargingStatus)},
+	}
+
+	for _, g := range gauges {
+		if status.Good(g.field) {
+			g.gauge.Set(g.value)
+		}
+	}
 
-	e.chargingPower.Set(float64(status.ChargingPower))
-	e.chargingCurrent.Set(float64(status.ChargingCurrent))
+	for _, field := range statusFields {
+		current := QualityGood
+		if q, ok := status.Quality[field.name]; ok {
+			current = q
+		}
+		for _, q := range []Quality{QualityGood, QualityStale, QualityFailed} {
+			value := 0.0
+			if q == current {
+				value = 1
+			}
+			e.fieldQuality.WithLabelValues(field.name, string(q)).Set(value)
+		}
+	}
+}
 
-	e.batteryVoltage.Set(float64(status.BatteryVoltage))
-	e.batterySoc.Set(float64(status.BatterySOC))
-	e.batteryTemp.Set(float64(status.BatteryTemp))
+// SetRuntime updates the time-to-full and time-to-empty gauges. A nil
+// estimate clears them.
+func (e *PrometheusCollector) SetRuntime(estimate *estimator.Estimate) {
+	if estimate == nil {
+		for _, gauge := range []*prometheus.GaugeVec{e.timeToFull, e.timeToEmpty, e.runtimeCurrent, e.runtimeConfidence} {
+			gauge.Reset()
+		}
+		return
+	}
 
-	e.deviceTemp.Set(float64(status.DeviceTemp))
+	e.runtimeCurrent.WithLabelValues().Set(estimate.Current)
+	e.runtimeConfidence.WithLabelValues().Set(estimate.Confidence)
 
-	e.energyGeneratedDaily.Set(float64(status.EnergyGeneratedDaily))
+	// Only the one that applies is kept, so a stale time-to-empty does not
+	// linger while charging
+	if estimate.TimeToFull != nil {
+		e.timeToFull.WithLabelValues().Set(*estimate.TimeToFull)
+	} else {
+		e.timeToFull.Reset()
+	}
+	if estimate.TimeToEmpty != nil {
+		e.timeToEmpty.WithLabelValues().Set(*estimate.TimeToEmpty)
+	} else {
+		e.timeToEmpty.Reset()
+	}
+}
 
-	e.chargingStatus.Set(float64(status.ChargingStatus))
+func (e *PrometheusCollector) SetConnectionStatus(status ConnectionStatus) {
+	if status.State == ConnectionConnected {
+		e.connected.Set(1)
+	} else {
+		e.connected.Set(0)
+	}
+	e.consecutiveFailures.Set(float64(status.ConsecutiveFailures))
+	e.reconnects.Set(float64(status.Reconnects))
+	e.interFrameDelay.Set(status.InterFrameDelay)
 }
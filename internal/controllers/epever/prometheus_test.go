@@ -0,0 +1,69 @@
+package epever
+
+import (
+	"testing"
+
+	"github.com/lumberbarons/solar-controller/internal/estimator"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Verify PrometheusCollector implements MetricsCollector
+var _ MetricsCollector = (*PrometheusCollector)(nil)
+
+// The collector registers with the global Prometheus registry via promauto,
+// so it can only be created once per test binary.
+func TestPrometheusCollector(t *testing.T) {
+	collector := NewPrometheusCollector()
+
+	status := &ControllerStatus{ArrayVoltage: 18.5, BatterySOC: 85}
+	collector.SetMetrics(status)
+
+	if got := testutil.ToFloat64(collector.batterySoc); got != 85 {
+		t.Errorf("battery_soc = %v, want 85", got)
+	}
+	if got := testutil.ToFloat64(collector.fieldQuality.WithLabelValues("batterySoc", "good")); got != 1 {
+		t.Errorf("field_quality{batterySoc,good} = %v, want 1 for a status without flags", got)
+	}
+
+	// A stale field leaves its gauge alone and moves its quality
+	status = &ControllerStatus{ArrayVoltage: 19, BatterySOC: 10, Quality: map[string]Quality{
+		"arrayVoltage": QualityGood,
+		"batterySoc":   QualityStale,
+	}}
+	collector.SetMetrics(status)
+
+	checks := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"panel_voltage", testutil.ToFloat64(collector.panelVoltage), 19},
+		{"battery_soc", testutil.ToFloat64(collector.batterySoc), 85},
+		{"field_quality batterySoc good", testutil.ToFloat64(collector.fieldQuality.WithLabelValues("batterySoc", "good")), 0},
+		{"field_quality batterySoc stale", testutil.ToFloat64(collector.fieldQuality.WithLabelValues("batterySoc", "stale")), 1},
+		{"field_quality arrayVoltage good", testutil.ToFloat64(collector.fieldQuality.WithLabelValues("arrayVoltage", "good")), 1},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+
+	// Only the estimate that applies is kept
+	seconds := 3600.0
+	collector.SetRuntime(&estimator.Estimate{Current: 10, Confidence: 80, TimeToFull: &seconds})
+	if got := testutil.ToFloat64(collector.timeToFull); got != 3600 {
+		t.Errorf("time_to_full_seconds = %v, want 3600", got)
+	}
+	collector.SetRuntime(&estimator.Estimate{Current: -5, Confidence: 60, TimeToEmpty: &seconds})
+	if n := testutil.CollectAndCount(collector.timeToFull); n != 0 {
+		t.Errorf("time_to_full_seconds has %d series while discharging, want none", n)
+	}
+	if got := testutil.ToFloat64(collector.runtimeCurrent); got != -5 {
+		t.Errorf("runtime_current = %v, want -5", got)
+	}
+	collector.SetRuntime(nil)
+	if n := testutil.CollectAndCount(collector.timeToEmpty) + testutil.CollectAndCount(collector.runtimeConfidence); n != 0 {
+		t.Errorf("%d runtime series left without an estimate, want none", n)
+	}
+}
@@ -0,0 +1,305 @@
+package epever
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/lumberbarons/modbus"
+)
+
+// Timing profile names. A profile supplies every value in TimingConfiguration
+// that is not set explicitly.
+const (
+	ProfileStandard = "standard"
+	ProfileSlow     = "slow"
+	ProfileFast     = "fast"
+)
+
+// TimingConfiguration is the optional `timing` section of the epever
+// configuration. Durations are duration strings; empty fields come from the
+// profile.
+type TimingConfiguration struct {
+	// Profile is standard (default), slow or fast
+	Profile string `yaml:"profile"`
+
+	BaudRate int    `yaml:"baudRate"`
+	Parity   string `yaml:"parity"` // none, even or odd
+
+	// InterFrameDelay is the quiet time the bus gets between one response
+	// and the next request
+	InterFrameDelay string `yaml:"interFrameDelay"`
+
+	// WriteDelay follows a single-register write that is followed by another
+	// write; CommitDelay follows any other write, giving the controller time
+	// to commit to EEPROM before it is read back
+	WriteDelay  string `yaml:"writeDelay"`
+	CommitDelay string `yaml:"commitDelay"`
+
+	// ResponseTimeout bounds one request/response frame; ReadTimeout bounds a
+	// register read including its retries, from when the pacer lets it start
+	ResponseTimeout string `yaml:"responseTimeout"`
+	ReadTimeout     string `yaml:"readTimeout"`
+
+	RetryAttempts int    `yaml:"retryAttempts"`
+	RetryDelay    string `yaml:"retryDelay"`
+
+	// MaxReadSpan caps the registers in one read when the read planner
+	// merges neighbouring ranges (1-125)
+	MaxReadSpan int `yaml:"maxReadSpan"`
+
+	// Adaptive scales the inter-frame delay with the observed error rate
+	Adaptive bool `yaml:"adaptive"`
+}
+
+// Timing is a resolved TimingConfiguration.
+type Timing struct {
+	BaudRate        int
+	Parity          modbus.Parity
+	InterFrameDelay time.Duration
+	WriteDelay      time.Duration
+	CommitDelay     time.Duration
+	ResponseTimeout time.Duration
+	ReadTimeout     time.Duration
+	RetryAttempts   int
+	RetryDelay      time.Duration
+	MaxReadSpan     uint16
+	Adaptive        bool
+}
+
+// timingProfiles holds the presets. standard is what the controller has
+// always used with Epever Tracer-AN/BN units; slow suits older firmware that
+// drops requests at that pace, and fast suits units that keep up with a
+// 20ms gap. slow also keeps reads to the 18 registers the collector has always
+// read in one go.
+var timingProfiles = map[string]Timing{
+	ProfileStandard: {
+		BaudRate:        115200,
+		Parity:          modbus.NoParity,
+		InterFrameDelay: 100 * time.Millisecond,
+		WriteDelay:      150 * time.Millisecond,
+		CommitDelay:     500 * time.Millisecond,
+		ResponseTimeout: 5 * time.Second,
+		ReadTimeout:     5 * time.Second,
+		RetryAttempts:   2,
+		RetryDelay:      2 * time.Second,
+		MaxReadSpan:     32,
+	},
+	ProfileSlow: {
+		BaudRate:        115200,
+		Parity:          modbus.NoParity,
+		InterFrameDelay: 250 * time.Millisecond,
+		WriteDelay:      300 * time.Millisecond,
+		CommitDelay:     1 * time.Second,
+		ResponseTimeout: 10 * time.Second,
+		ReadTimeout:     15 * time.Second,
+		RetryAttempts:   3,
+		RetryDelay:      3 * time.Second,
+		MaxReadSpan:     18,
+	},
+	ProfileFast: {
+		BaudRate:        115200,
+		Parity:          modbus.NoParity,
+		InterFrameDelay: 20 * time.Millisecond,
+		WriteDelay:      50 * time.Millisecond,
+		CommitDelay:     250 * time.Millisecond,
+		ResponseTimeout: 2 * time.Second,
+		ReadTimeout:     3 * time.Second,
+		RetryAttempts:   2,
+		RetryDelay:      500 * time.Millisecond,
+		MaxReadSpan:     64,
+	},
+}
+
+var parities = map[string]modbus.Parity{
+	"none": modbus.NoParity,
+	"even": modbus.EvenParity,
+	"odd":  modbus.OddParity,
+}
+
+// Validate checks the timing section for errors.
+func (t *TimingConfiguration) Validate() error {
+	_, err := t.Resolve()
+	return err
+}
+
+// Resolve fills the unset fields from the profile and parses the durations.
+func (t *TimingConfiguration) Resolve() (Timing, error) {
+	profile := t.Profile
+	if profile == "" {
+		profile = ProfileStandard
+	}
+	timing, ok := timingProfiles[profile]
+	if !ok {
+		return Timing{}, fmt.Errorf("unknown timing profile %q (want %s, %s or %s)", t.Profile, ProfileStandard, ProfileSlow, ProfileFast)
+	}
+
+	if t.BaudRate < 0 {
+		return Timing{}, fmt.Errorf("baudRate must be positive")
+	}
+	if t.BaudRate > 0 {
+		timing.BaudRate = t.BaudRate
+	}
+
+	if t.Parity != "" {
+		parity, ok := parities[t.Parity]
+		if !ok {
+			return Timing{}, fmt.Errorf("unknown parity %q (want none, even or odd)", t.Parity)
+		}
+		timing.Parity = parity
+	}
+
+	durations := []struct {
+		name   string
+		value  string
+		target *time.Duration
+		zeroOK bool
+	}{
+		{"interFrameDelay", t.InterFrameDelay, &timing.InterFrameDelay, true},
+		{"writeDelay", t.WriteDelay, &timing.WriteDelay, true},
+		{"commitDelay", t.CommitDelay, &timing.CommitDelay, true},
+		{"responseTimeout", t.ResponseTimeout, &timing.ResponseTimeout, false},
+		{"readTimeout", t.ReadTimeout, &timing.ReadTimeout, false},
+		{"retryDelay", t.RetryDelay, &timing.RetryDelay, true},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			return Timing{}, fmt.Errorf("invalid %s: %w", d.name, err)
+		}
+		if parsed < 0 || (parsed == 0 && !d.zeroOK) {
+			return Timing{}, fmt.Errorf("%s must be positive", d.name)
+		}
+		*d.target = parsed
+	}
+
+	if t.RetryAttempts < 0 {
+		return Timing{}, fmt.Errorf("retryAttempts must be positive")
+	}
+	if t.RetryAttempts > 0 {
+		timing.RetryAttempts = t.RetryAttempts
+	}
+
+	if t.MaxReadSpan < 0 || t.MaxReadSpan > maxReadQuantity {
+		return Timing{}, fmt.Errorf("maxReadSpan must be between 1 and %d", maxReadQuantity)
+	}
+	if t.MaxReadSpan > 0 {
+		timing.MaxReadSpan = uint16(t.MaxReadSpan)
+	}
+
+	timing.Adaptive = t.Adaptive
+	return timing, nil
+}
+
+// Adaptive pacing bounds. The inter-frame delay moves between a quarter and
+// four times the configured value: it doubles on each failed operation and
+// drops by a tenth after adaptiveTightenAfter successes in a row.
+const (
+	adaptiveMinScale     = 0.25
+	adaptiveMaxScale     = 4
+	adaptiveTightenAfter = 20
+
+	// adaptiveFloor lets a configured delay of zero relax at all
+	adaptiveFloor = 10 * time.Millisecond
+)
+
+// pacer spaces requests on the bus. It waits out whatever part of the
+// required gap has not already passed since the last response, so a
+// collection that starts after an idle period does not wait at all.
+type pacer struct {
+	timing Timing
+	now    func() time.Time
+
+	mu        sync.Mutex
+	delay     time.Duration
+	successes int
+	lastDone  time.Time
+	lastWrite uint16 // registers written by the last operation, 0 for a read
+}
+
+func newPacer(timing Timing, now func() time.Time) *pacer {
+	return &pacer{timing: timing, now: now, delay: timing.InterFrameDelay}
+}
+
+// gap returns how long the bus must be quiet before the next operation.
+func (p *pacer) gap(write bool) time.Duration {
+	switch {
+	case p.lastWrite == 1 && write:
+		return p.timing.WriteDelay
+	case p.lastWrite > 0:
+		return p.timing.CommitDelay
+	default:
+		return p.delay
+	}
+}
+
+// wait blocks until the next operation may start or ctx is done.
+func (p *pacer) wait(ctx context.Context, write bool) error {
+	p.mu.Lock()
+	var remaining time.Duration
+	if !p.lastDone.IsZero() {
+		remaining = p.gap(write) - p.now().Sub(p.lastDone)
+	}
+	p.mu.Unlock()
+
+	if remaining <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// done records the end of an operation. written is the number of registers
+// or coils it wrote, and failed whether the device failed to answer.
+func (p *pacer) done(written uint16, failed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.lastDone = p.now()
+	p.lastWrite = written
+
+	if !p.timing.Adaptive {
+		return
+	}
+
+	base := p.timing.InterFrameDelay
+	previous := p.delay
+	if failed {
+		p.successes = 0
+		p.delay = min(max(p.delay*2, adaptiveFloor), max(time.Duration(float64(base)*adaptiveMaxScale), adaptiveFloor))
+	} else {
+		p.successes++
+		if p.successes < adaptiveTightenAfter {
+			return
+		}
+		p.successes = 0
+		p.delay = max(p.delay-p.delay/10, time.Duration(float64(base)*adaptiveMinScale))
+	}
+
+	switch {
+	case p.delay > previous:
+		log.Infof("epever errors, relaxing inter-frame delay to %s", p.delay)
+	case p.delay < previous:
+		log.Debugf("epever bus healthy, tightening inter-frame delay to %s", p.delay)
+	}
+}
+
+// currentDelay is the inter-frame delay in use.
+func (p *pacer) currentDelay() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.delay
+}
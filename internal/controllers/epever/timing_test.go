@@ -0,0 +1,229 @@
+package epever
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lumberbarons/modbus"
+)
+
+func TestTimingConfiguration_Resolve(t *testing.T) {
+	tests := []struct {
+		name   string
+		config TimingConfiguration
+		check  func(t *testing.T, timing Timing)
+	}{
+		{
+			name:   "empty section is the standard profile",
+			config: TimingConfiguration{},
+			check: func(t *testing.T, timing Timing) {
+				if timing != timingProfiles[ProfileStandard] {
+					t.Errorf("got %+v, want the standard profile", timing)
+				}
+			},
+		},
+		{
+			name:   "standard profile keeps the historical pacing",
+			config: TimingConfiguration{Profile: ProfileStandard},
+			check: func(t *testing.T, timing Timing) {
+				if timing.BaudRate != 115200 || timing.Parity != modbus.NoParity {
+					t.Errorf("framing = %d/%v, want 115200/none", timing.BaudRate, timing.Parity)
+				}
+				if timing.InterFrameDelay != 100*time.Millisecond || timing.WriteDelay != 150*time.Millisecond || timing.CommitDelay != 500*time.Millisecond {
+					t.Errorf("delays = %s/%s/%s, want 100ms/150ms/500ms", timing.InterFrameDelay, timing.WriteDelay, timing.CommitDelay)
+				}
+				if timing.RetryAttempts != 2 || timing.RetryDelay != 2*time.Second {
+					t.Errorf("retries = %d every %s, want 2 every 2s", timing.RetryAttempts, timing.RetryDelay)
+				}
+			},
+		},
+		{
+			name: "explicit fields override the profile",
+			config: TimingConfiguration{
+				Profile:         ProfileFast,
+				BaudRate:        9600,
+				Parity:          "odd",
+				InterFrameDelay: "0s",
+				ReadTimeout:     "8s",
+				RetryAttempts:   4,
+				Adaptive:        true,
+			},
+			check: func(t *testing.T, timing Timing) {
+				fast := timingProfiles[ProfileFast]
+				if timing.BaudRate != 9600 || timing.Parity != modbus.OddParity {
+					t.Errorf("framing = %d/%v, want 9600/odd", timing.BaudRate, timing.Parity)
+				}
+				if timing.InterFrameDelay != 0 {
+					t.Errorf("InterFrameDelay = %s, want 0", timing.InterFrameDelay)
+				}
+				if timing.ReadTimeout != 8*time.Second || timing.RetryAttempts != 4 || !timing.Adaptive {
+					t.Errorf("got %+v, want the overrides applied", timing)
+				}
+				if timing.WriteDelay != fast.WriteDelay || timing.ResponseTimeout != fast.ResponseTimeout {
+					t.Errorf("unset fields = %s/%s, want the fast profile's", timing.WriteDelay, timing.ResponseTimeout)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timing, err := tt.config.Resolve()
+			if err != nil {
+				t.Fatalf("Resolve failed: %v", err)
+			}
+			tt.check(t, timing)
+		})
+	}
+}
+
+func TestTimingConfiguration_ValidateRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  TimingConfiguration
+		wantErr string
+	}{
+		{"unknown profile", TimingConfiguration{Profile: "turbo"}, "unknown timing profile"},
+		{"unknown parity", TimingConfiguration{Parity: "mark"}, "unknown parity"},
+		{"negative baud rate", TimingConfiguration{BaudRate: -1}, "baudRate"},
+		{"unparseable delay", TimingConfiguration{WriteDelay: "soon"}, "invalid writeDelay"},
+		{"negative delay", TimingConfiguration{InterFrameDelay: "-5ms"}, "interFrameDelay must be positive"},
+		{"zero timeout", TimingConfiguration{ResponseTimeout: "0s"}, "responseTimeout must be positive"},
+		{"negative retries", TimingConfiguration{RetryAttempts: -1}, "retryAttempts"},
+		{"read span above the Modbus limit", TimingConfiguration{MaxReadSpan: 126}, "maxReadSpan"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPacer_Gap(t *testing.T) {
+	timing := Timing{InterFrameDelay: 100 * time.Millisecond, WriteDelay: 150 * time.Millisecond, CommitDelay: 500 * time.Millisecond}
+
+	tests := []struct {
+		name      string
+		lastWrite uint16
+		write     bool
+		want      time.Duration
+	}{
+		{"read after read", 0, false, 100 * time.Millisecond},
+		{"write after read", 0, true, 100 * time.Millisecond},
+		{"write after single write", 1, true, 150 * time.Millisecond},
+		{"read back after single write", 1, false, 500 * time.Millisecond},
+		{"write after block write", 12, true, 500 * time.Millisecond},
+		{"read back after block write", 12, false, 500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPacer(timing, time.Now)
+			p.lastWrite = tt.lastWrite
+			if got := p.gap(tt.write); got != tt.want {
+				t.Errorf("gap = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPacer_WaitsOnlyForTheRemainingGap(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 6, 21, 12, 0, 0, 0, time.UTC)}
+	p := newPacer(Timing{InterFrameDelay: time.Hour}, clock.Now)
+
+	// The first operation never waits
+	if err := p.wait(context.Background(), false); err != nil {
+		t.Fatalf("first wait failed: %v", err)
+	}
+
+	p.done(0, false)
+	clock.Advance(time.Hour)
+
+	// The whole gap has passed on the clock, so this returns at once rather
+	// than blocking the test for an hour
+	if err := p.wait(context.Background(), false); err != nil {
+		t.Errorf("wait after the gap failed: %v", err)
+	}
+}
+
+func TestPacer_WaitRespectsCancellation(t *testing.T) {
+	p := newPacer(Timing{InterFrameDelay: time.Hour}, time.Now)
+	p.done(0, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := p.wait(ctx, false)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wait = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("wait took %s after its context expired", elapsed)
+	}
+}
+
+func TestPacer_AdaptiveRelaxesOnErrorsAndTightensWhenHealthy(t *testing.T) {
+	base := 100 * time.Millisecond
+	p := newPacer(Timing{InterFrameDelay: base, Adaptive: true}, time.Now)
+
+	p.done(0, true)
+	if got := p.currentDelay(); got != 2*base {
+		t.Errorf("after one failure delay = %s, want %s", got, 2*base)
+	}
+
+	for range 5 {
+		p.done(0, true)
+	}
+	if got, limit := p.currentDelay(), time.Duration(float64(base)*adaptiveMaxScale); got != limit {
+		t.Errorf("after repeated failures delay = %s, want the %s cap", got, limit)
+	}
+
+	// A run of successes one short of the threshold changes nothing
+	relaxed := p.currentDelay()
+	for range adaptiveTightenAfter - 1 {
+		p.done(0, false)
+	}
+	if got := p.currentDelay(); got != relaxed {
+		t.Errorf("delay = %s before the success threshold, want %s", got, relaxed)
+	}
+	p.done(0, false)
+	if got := p.currentDelay(); got >= relaxed {
+		t.Errorf("delay = %s after %d successes, want less than %s", got, adaptiveTightenAfter, relaxed)
+	}
+
+	for range 100 * adaptiveTightenAfter {
+		p.done(0, false)
+	}
+	if got, floor := p.currentDelay(), time.Duration(float64(base)*adaptiveMinScale); got != floor {
+		t.Errorf("after a long healthy run delay = %s, want the %s floor", got, floor)
+	}
+}
+
+func TestPacer_AdaptiveRelaxesFromZero(t *testing.T) {
+	p := newPacer(Timing{Adaptive: true}, time.Now)
+
+	p.done(0, true)
+	if got := p.currentDelay(); got != adaptiveFloor {
+		t.Errorf("delay = %s, want %s so a zero delay can back off", got, adaptiveFloor)
+	}
+}
+
+func TestPacer_FixedWithoutAdaptive(t *testing.T) {
+	base := 100 * time.Millisecond
+	p := newPacer(Timing{InterFrameDelay: base}, time.Now)
+
+	for range 10 {
+		p.done(0, true)
+	}
+	if got := p.currentDelay(); got != base {
+		t.Errorf("delay = %s, want the configured %s", got, base)
+	}
+}
@@ -0,0 +1,133 @@
+package ina2xx
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Register addresses shared by the INA219 and INA226.
+const (
+	regConfig       = 0x00
+	regShuntVoltage = 0x01
+	regBusVoltage   = 0x02
+	regPower        = 0x03
+	regCurrent      = 0x04
+	regCalibration  = 0x05
+)
+
+// ErrOverflow is returned when the INA219 reports that its power or current
+// calculation overflowed, which happens when the shunt voltage exceeds the
+// configured range.
+var ErrOverflow = errors.New("current or power calculation overflowed")
+
+// Chip describes the register scaling of one INA2xx model. The two parts
+// share a register map but differ in every constant that matters.
+type Chip struct {
+	Name string
+
+	// config is written to the configuration register on setup.
+	config uint16
+
+	// calibrationScale is the fixed constant in the datasheet calibration
+	// equation, Cal = scale / (Current_LSB × R_shunt).
+	calibrationScale float64
+
+	// calibrationMask clears the calibration register bits that are
+	// reserved (INA226 bit 15) or read-only (INA219 bit 0).
+	calibrationMask uint16
+
+	// shuntVoltageLSB is the shunt voltage register resolution in volts.
+	shuntVoltageLSB float64
+
+	// fullScaleShunt is the largest shunt voltage the configured range
+	// measures, in volts.
+	fullScaleShunt float64
+
+	// powerLSBFactor scales Current_LSB to the power register resolution.
+	powerLSBFactor float64
+
+	// settle covers one full conversion cycle, after which the current and
+	// power registers reflect a new calibration.
+	settle time.Duration
+
+	// busVoltage decodes the bus voltage register to volts.
+	busVoltage func(raw uint16) (float64, error)
+}
+
+var chips = map[string]*Chip{
+	"ina226": {
+		Name: "ina226",
+		// 16 sample average, 1.1ms bus and shunt conversions, continuous
+		config:           0x4527,
+		calibrationScale: 0.00512,
+		calibrationMask:  0x7FFF,
+		shuntVoltageLSB:  2.5e-6,
+		fullScaleShunt:   0.08192,
+		powerLSBFactor:   25,
+		settle:           40 * time.Millisecond,
+		busVoltage: func(raw uint16) (float64, error) {
+			return float64(raw) * 1.25e-3, nil
+		},
+	},
+	"ina219": {
+		Name: "ina219",
+		// 32V bus range, ±320mV shunt range, 12-bit conversions, continuous
+		config:           0x399F,
+		calibrationScale: 0.04096,
+		calibrationMask:  0xFFFE,
+		shuntVoltageLSB:  10e-6,
+		fullScaleShunt:   0.32,
+		powerLSBFactor:   20,
+		settle:           2 * time.Millisecond,
+		busVoltage: func(raw uint16) (float64, error) {
+			if raw&0x1 != 0 {
+				return 0, ErrOverflow
+			}
+			return float64(raw>>3) * 4e-3, nil
+		},
+	},
+}
+
+// LookupChip returns the model with the given name.
+func LookupChip(name string) (*Chip, error) {
+	chip, ok := chips[name]
+	if !ok {
+		names := make([]string, 0, len(chips))
+		for n := range chips {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown chip %q (supported: %v)", name, names)
+	}
+	return chip, nil
+}
+
+// Calibration computes the calibration register value for a shunt and the
+// largest current to be measured, along with the resulting current register
+// resolution in amperes. The resolution is recomputed from the truncated
+// register value, so readings stay exact rather than off by the rounding.
+func (c *Chip) Calibration(shuntResistance, maxCurrent float64) (uint16, float64, error) {
+	if shuntResistance <= 0 || maxCurrent <= 0 {
+		return 0, 0, errors.New("shunt resistance and max current must be positive")
+	}
+	// The tolerance keeps a range chosen to the datasheet limit, like
+	// 0.1Ω × 3.2A on the INA219, from failing on float rounding
+	if shuntVoltage := shuntResistance * maxCurrent; shuntVoltage > c.fullScaleShunt*(1+1e-9) {
+		return 0, 0, fmt.Errorf("max current %.2fA across %gΩ is %.1fmV, beyond the %s's %.2fmV shunt range",
+			maxCurrent, shuntResistance, shuntVoltage*1000, c.Name, c.fullScaleShunt*1000)
+	}
+
+	// The current register is a signed 16-bit value
+	currentLSB := maxCurrent / 32768
+	raw := math.Floor(c.calibrationScale / (currentLSB * shuntResistance))
+	if raw > float64(c.calibrationMask) {
+		return 0, 0, fmt.Errorf("max current %.3fA is too small to calibrate for a %gΩ shunt", maxCurrent, shuntResistance)
+	}
+
+	calibration := uint16(raw) & c.calibrationMask
+
+	return calibration, c.calibrationScale / (float64(calibration) * shuntResistance), nil
+}
@@ -0,0 +1,82 @@
+package ina2xx
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestLookupChip(t *testing.T) {
+	for _, name := range []string{"ina226", "ina219"} {
+		chip, err := LookupChip(name)
+		if err != nil || chip.Name != name {
+			t.Errorf("LookupChip(%q) = %v, %v", name, chip, err)
+		}
+	}
+	if _, err := LookupChip("ina260"); err == nil {
+		t.Error("LookupChip(ina260) expected error")
+	}
+}
+
+func TestChip_Calibration(t *testing.T) {
+	tests := []struct {
+		name            string
+		chip            string
+		shunt           float64
+		maxCurrent      float64
+		wantCalibration uint16
+		wantErr         bool
+	}{
+		// Datasheet-style examples: 2mΩ/20A and the common 0.1Ω/3.2A breakout
+		{name: "ina226 2mΩ 20A", chip: "ina226", shunt: 0.002, maxCurrent: 20, wantCalibration: 4194},
+		{name: "ina219 0.1Ω 3.2A", chip: "ina219", shunt: 0.1, maxCurrent: 3.2, wantCalibration: 4194},
+		{name: "ina219 clears the read-only bit", chip: "ina219", shunt: 0.1, maxCurrent: 1.5, wantCalibration: 8946},
+		{name: "beyond the shunt range", chip: "ina226", shunt: 0.01, maxCurrent: 20, wantErr: true},
+		{name: "too fine to calibrate", chip: "ina226", shunt: 0.001, maxCurrent: 0.01, wantErr: true},
+		{name: "zero shunt", chip: "ina226", shunt: 0, maxCurrent: 10, wantErr: true},
+		{name: "zero max current", chip: "ina226", shunt: 0.002, maxCurrent: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chip, err := LookupChip(tt.chip)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			calibration, currentLSB, err := chip.Calibration(tt.shunt, tt.maxCurrent)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Calibration() = 0x%04x, want error", calibration)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Calibration() error: %v", err)
+			}
+			if calibration != tt.wantCalibration {
+				t.Errorf("Calibration() = %d, want %d", calibration, tt.wantCalibration)
+			}
+
+			// The full-scale current register must still reach maxCurrent
+			if fullScale := currentLSB * 32767; fullScale < tt.maxCurrent*0.999 {
+				t.Errorf("full-scale current %.3fA below max current %.3fA", fullScale, tt.maxCurrent)
+			}
+		})
+	}
+}
+
+func TestChip_BusVoltage(t *testing.T) {
+	ina226, _ := LookupChip("ina226")
+	if got, _ := ina226.busVoltage(9600); math.Abs(got-12.0) > 1e-9 {
+		t.Errorf("ina226 bus voltage = %v, want 12.0", got)
+	}
+
+	ina219, _ := LookupChip("ina219")
+	if got, _ := ina219.busVoltage(3000<<3 | 0x2); math.Abs(got-12.0) > 1e-9 {
+		t.Errorf("ina219 bus voltage = %v, want 12.0", got)
+	}
+	if _, err := ina219.busVoltage(3000<<3 | 0x1); !errors.Is(err, ErrOverflow) {
+		t.Errorf("ina219 overflow error = %v, want ErrOverflow", err)
+	}
+}
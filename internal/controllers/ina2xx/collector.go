@@ -0,0 +1,145 @@
+package ina2xx
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Collector reads one INA2xx. The chip is configured on the first collection
+// rather than at startup, and again whenever its calibration register no
+// longer holds the expected value: a brown-out resets the chip to a zero
+// calibration, after which the current and power registers read zero.
+type Collector struct {
+	device          I2CDevice
+	chip            *Chip
+	calibration     uint16
+	currentLSB      float64
+	configured      bool
+	configuredMutex sync.Mutex
+}
+
+type Status struct {
+	Timestamp      int64   `json:"timestamp"`
+	CollectionTime float64 `json:"collectionTime"`
+	Chip           string  `json:"chip"`
+	BusVoltage     float64 `json:"busVoltage"`
+	ShuntVoltage   float64 `json:"shuntVoltage"`
+	Current        float64 `json:"current"`
+	Power          float64 `json:"power"`
+}
+
+// NewCollector creates a collector for a chip wired to the given shunt.
+// maxCurrent is the largest current to be measured, in either direction.
+func NewCollector(device I2CDevice, chip *Chip, shuntResistance, maxCurrent float64) (*Collector, error) {
+	calibration, currentLSB, err := chip.Calibration(shuntResistance, maxCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("%s calibration 0x%04x, current LSB %.3gA", chip.Name, calibration, currentLSB)
+
+	return &Collector{
+		device:      device,
+		chip:        chip,
+		calibration: calibration,
+		currentLSB:  currentLSB,
+	}, nil
+}
+
+func (c *Collector) GetStatus(ctx context.Context) (*Status, error) {
+	startTime := time.Now()
+	log.Debug("Starting ina2xx GetStatus collection")
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := c.ensureConfigured(); err != nil {
+		return nil, err
+	}
+
+	shuntRaw, err := c.device.ReadRegister(regShuntVoltage)
+	if err != nil {
+		return nil, err
+	}
+
+	busRaw, err := c.device.ReadRegister(regBusVoltage)
+	if err != nil {
+		return nil, err
+	}
+	busVoltage, err := c.chip.busVoltage(busRaw)
+	if err != nil {
+		return nil, err
+	}
+
+	currentRaw, err := c.device.ReadRegister(regCurrent)
+	if err != nil {
+		return nil, err
+	}
+	current := float64(int16(currentRaw)) * c.currentLSB
+
+	powerRaw, err := c.device.ReadRegister(regPower)
+	if err != nil {
+		return nil, err
+	}
+	// The power register holds a magnitude; give it the current's sign so
+	// a bidirectional shunt reports charge and discharge apart.
+	power := float64(powerRaw) * c.chip.powerLSBFactor * c.currentLSB
+	if current < 0 {
+		power = -power
+	}
+
+	status := &Status{
+		Timestamp:    startTime.Unix(),
+		Chip:         c.chip.Name,
+		BusVoltage:   busVoltage,
+		ShuntVoltage: float64(int16(shuntRaw)) * c.chip.shuntVoltageLSB * 1000,
+		Current:      current,
+		Power:        power,
+	}
+	status.CollectionTime = time.Since(startTime).Seconds()
+
+	log.Debugf("ina2xx GetStatus completed in %.3fs - %.3fV %.3fA", status.CollectionTime, busVoltage, current)
+
+	return status, nil
+}
+
+func (c *Collector) ensureConfigured() error {
+	c.configuredMutex.Lock()
+	defer c.configuredMutex.Unlock()
+
+	if c.configured {
+		calibration, err := c.device.ReadRegister(regCalibration)
+		if err != nil {
+			return err
+		}
+		if calibration == c.calibration {
+			return nil
+		}
+		log.Warnf("%s calibration register reads 0x%04x, expected 0x%04x; reconfiguring", c.chip.Name, calibration, c.calibration)
+		c.configured = false
+	}
+
+	if err := c.device.WriteRegister(regConfig, c.chip.config); err != nil {
+		return fmt.Errorf("failed to configure %s: %w", c.chip.Name, err)
+	}
+	if err := c.device.WriteRegister(regCalibration, c.calibration); err != nil {
+		return fmt.Errorf("failed to calibrate %s: %w", c.chip.Name, err)
+	}
+	c.configured = true
+
+	// Let a conversion complete under the new calibration before reading
+	time.Sleep(c.chip.settle)
+
+	log.Infof("%s configured with calibration 0x%04x", c.chip.Name, c.calibration)
+	return nil
+}
+
+// Close releases the I2C bus.
+func (c *Collector) Close() error {
+	return c.device.Close()
+}
@@ -0,0 +1,178 @@
+package ina2xx
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+// newTestCollector returns an INA226 collector for a 2mΩ/20A shunt and a
+// device whose registers read 12V and 5A of discharge.
+func newTestCollector(t *testing.T) (*Collector, *MockI2CDevice) {
+	t.Helper()
+	chip, err := LookupChip("ina226")
+	if err != nil {
+		t.Fatal(err)
+	}
+	device := &MockI2CDevice{}
+	collector, err := NewCollector(device, chip, 0.002, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	currentRaw := int16(math.Round(-5 / collector.currentLSB))
+	shuntRaw := int16(-4000) // -10mV
+	device.Registers = map[uint8]uint16{
+		regShuntVoltage: uint16(shuntRaw),
+		regBusVoltage:   9600, // 12V
+		regCurrent:      uint16(currentRaw),
+		regPower:        uint16(math.Round(60 / (25 * collector.currentLSB))),
+	}
+	return collector, device
+}
+
+func countWrites(device *MockI2CDevice, reg uint8) int {
+	n := 0
+	for _, r := range device.WriteCalls {
+		if r == reg {
+			n++
+		}
+	}
+	return n
+}
+
+func TestCollector_GetStatus(t *testing.T) {
+	t.Run("configures the chip and reads all registers", func(t *testing.T) {
+		collector, device := newTestCollector(t)
+
+		status, err := collector.GetStatus(context.Background())
+		if err != nil {
+			t.Fatalf("GetStatus() error: %v", err)
+		}
+
+		if device.Registers[regConfig] != 0x4527 {
+			t.Errorf("config register = 0x%04x, want 0x4527", device.Registers[regConfig])
+		}
+		if device.Registers[regCalibration] != 4194 {
+			t.Errorf("calibration register = %d, want 4194", device.Registers[regCalibration])
+		}
+
+		checks := []struct {
+			name string
+			got  float64
+			want float64
+		}{
+			{"BusVoltage", status.BusVoltage, 12},
+			{"ShuntVoltage", status.ShuntVoltage, -10},
+			{"Current", status.Current, -5},
+			{"Power", status.Power, -60},
+		}
+		for _, c := range checks {
+			if math.Abs(c.got-c.want) > 0.01 {
+				t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+			}
+		}
+		if status.Chip != "ina226" {
+			t.Errorf("Chip = %q, want ina226", status.Chip)
+		}
+	})
+
+	t.Run("configures once while the calibration holds", func(t *testing.T) {
+		collector, device := newTestCollector(t)
+
+		for range 3 {
+			if _, err := collector.GetStatus(context.Background()); err != nil {
+				t.Fatalf("GetStatus() error: %v", err)
+			}
+		}
+		if got := countWrites(device, regCalibration); got != 1 {
+			t.Errorf("calibration writes = %d, want 1", got)
+		}
+	})
+
+	t.Run("reconfigures after the chip resets", func(t *testing.T) {
+		collector, device := newTestCollector(t)
+
+		if _, err := collector.GetStatus(context.Background()); err != nil {
+			t.Fatalf("GetStatus() error: %v", err)
+		}
+
+		// A brown-out restores power-on defaults
+		device.Registers[regCalibration] = 0
+
+		if _, err := collector.GetStatus(context.Background()); err != nil {
+			t.Fatalf("GetStatus() error: %v", err)
+		}
+		if got := countWrites(device, regCalibration); got != 2 {
+			t.Errorf("calibration writes = %d, want 2", got)
+		}
+		if device.Registers[regCalibration] != 4194 {
+			t.Errorf("calibration register = %d, want 4194", device.Registers[regCalibration])
+		}
+	})
+
+	t.Run("retries configuration after a failed write", func(t *testing.T) {
+		collector, device := newTestCollector(t)
+		device.WriteFunc = func(uint8, uint16) error { return errors.New("remote I/O error") }
+
+		if _, err := collector.GetStatus(context.Background()); err == nil {
+			t.Fatal("GetStatus() expected error")
+		}
+
+		device.WriteFunc = nil
+		if _, err := collector.GetStatus(context.Background()); err != nil {
+			t.Fatalf("GetStatus() error: %v", err)
+		}
+		if device.Registers[regCalibration] != 4194 {
+			t.Errorf("calibration register = %d, want 4194", device.Registers[regCalibration])
+		}
+	})
+
+	t.Run("fails on a read error", func(t *testing.T) {
+		collector, device := newTestCollector(t)
+		device.ReadFunc = func(reg uint8) (uint16, error) {
+			if reg == regCurrent {
+				return 0, errors.New("remote I/O error")
+			}
+			return device.Registers[reg], nil
+		}
+
+		if _, err := collector.GetStatus(context.Background()); err == nil {
+			t.Error("GetStatus() expected error")
+		}
+	})
+
+	t.Run("fails when the ina219 overflows", func(t *testing.T) {
+		chip, _ := LookupChip("ina219")
+		device := &MockI2CDevice{Registers: map[uint8]uint16{regBusVoltage: 3000<<3 | 0x1}}
+		collector, err := NewCollector(device, chip, 0.1, 3.2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := collector.GetStatus(context.Background()); !errors.Is(err, ErrOverflow) {
+			t.Errorf("GetStatus() error = %v, want ErrOverflow", err)
+		}
+	})
+
+	t.Run("does not touch the bus when cancelled", func(t *testing.T) {
+		collector, device := newTestCollector(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, err := collector.GetStatus(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("GetStatus() error = %v, want context.Canceled", err)
+		}
+		if len(device.ReadCalls)+len(device.WriteCalls) != 0 {
+			t.Error("cancelled collection accessed the bus")
+		}
+	})
+}
+
+func TestNewCollector_RejectsBadCalibration(t *testing.T) {
+	chip, _ := LookupChip("ina226")
+	if _, err := NewCollector(&MockI2CDevice{}, chip, 0.01, 20); err == nil {
+		t.Error("NewCollector() expected error for a shunt beyond the chip's range")
+	}
+}
@@ -0,0 +1,77 @@
+//go:build linux
+
+package ina2xx
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"golang.org/x/sys/unix"
+)
+
+// i2cSlave is the I2C_SLAVE ioctl from <linux/i2c-dev.h>, which sets the
+// address later reads and writes on the file descriptor go to.
+const i2cSlave = 0x0703
+
+// LinuxI2CDevice is the production I2CDevice backed by an i2c-dev character
+// device such as /dev/i2c-1.
+type LinuxI2CDevice struct {
+	file *os.File
+	lock sync.Mutex
+}
+
+// Verify LinuxI2CDevice implements I2CDevice
+var _ I2CDevice = (*LinuxI2CDevice)(nil)
+
+func NewLinuxI2CDevice(path string, address uint16) (*LinuxI2CDevice, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0) //nolint:gosec // path is the configured I2C bus device
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, unix.ENODEV) || errors.Is(err, unix.ENXIO) {
+		return nil, fmt.Errorf("failed to open I2C bus %s: %w: %w", path, controllers.ErrDeviceUnavailable, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open I2C bus %s: %w", path, err)
+	}
+
+	if err := unix.IoctlSetInt(int(file.Fd()), i2cSlave, int(address)); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to select I2C address 0x%02x on %s: %w", address, path, err)
+	}
+
+	return &LinuxI2CDevice{file: file}, nil
+}
+
+// ReadRegister sets the register pointer and reads the register back. The
+// INA2xx keeps the pointer between transactions, so a separate write and
+// read is equivalent to a repeated-start read.
+func (d *LinuxI2CDevice) ReadRegister(reg uint8) (uint16, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if _, err := d.file.Write([]byte{reg}); err != nil {
+		return 0, fmt.Errorf("failed to select register 0x%02x: %w", reg, err)
+	}
+
+	buf := make([]byte, 2)
+	if _, err := d.file.Read(buf); err != nil {
+		return 0, fmt.Errorf("failed to read register 0x%02x: %w", reg, err)
+	}
+
+	return uint16(buf[0])<<8 | uint16(buf[1]), nil
+}
+
+func (d *LinuxI2CDevice) WriteRegister(reg uint8, value uint16) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if _, err := d.file.Write([]byte{reg, byte(value >> 8), byte(value)}); err != nil {
+		return fmt.Errorf("failed to write register 0x%02x: %w", reg, err)
+	}
+	return nil
+}
+
+func (d *LinuxI2CDevice) Close() error {
+	return d.file.Close()
+}
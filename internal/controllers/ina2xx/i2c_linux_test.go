@@ -0,0 +1,33 @@
+//go:build linux
+
+package ina2xx
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lumberbarons/solar-controller/internal/controllers"
+)
+
+func TestNewLinuxI2CDevice(t *testing.T) {
+	t.Run("a missing bus is an unavailable device", func(t *testing.T) {
+		_, err := NewLinuxI2CDevice(filepath.Join(t.TempDir(), "i2c-1"), 0x40)
+		if !errors.Is(err, controllers.ErrDeviceUnavailable) {
+			t.Errorf("NewLinuxI2CDevice() error = %v, want ErrDeviceUnavailable", err)
+		}
+	})
+
+	t.Run("a file that is not a bus fails to select the address", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "i2c-1")
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := NewLinuxI2CDevice(path, 0x40)
+		if err == nil || errors.Is(err, controllers.ErrDeviceUnavailable) {
+			t.Errorf("NewLinuxI2CDevice() error = %v, want a failure other than ErrDeviceUnavailable", err)
+		}
+	})
+}
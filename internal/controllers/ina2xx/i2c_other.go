@@ -0,0 +1,25 @@
+//go:build !linux
+
+package ina2xx
+
+import "errors"
+
+// LinuxI2CDevice is unavailable off Linux; i2c-dev is a Linux kernel
+// interface.
+type LinuxI2CDevice struct{}
+
+func NewLinuxI2CDevice(_ string, _ uint16) (*LinuxI2CDevice, error) {
+	return nil, errors.New("I2C is only supported on Linux")
+}
+
+func (d *LinuxI2CDevice) ReadRegister(_ uint8) (uint16, error) {
+	return 0, errors.New("I2C is only supported on Linux")
+}
+
+func (d *LinuxI2CDevice) WriteRegister(_ uint8, _ uint16) error {
+	return errors.New("I2C is only supported on Linux")
+}
+
+func (d *LinuxI2CDevice) Close() error {
+	return nil
+}
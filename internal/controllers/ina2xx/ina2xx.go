@@ -0,0 +1,343 @@
+package ina2xx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-co-op/gocron"
+	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"github.com/lumberbarons/solar-controller/internal/health"
+	"github.com/lumberbarons/solar-controller/internal/publish"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	namespace = "ina2xx"
+
+	defaultDevice  = "/dev/i2c-1"
+	defaultAddress = 0x40
+	defaultChip    = "ina226"
+
+	// collectTimeout bounds a collection cycle: a handful of register reads
+	// plus, after a reset, reconfiguring the chip.
+	collectTimeout = 10 * time.Second
+)
+
+type Configuration struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Device is the i2c-dev bus the chip is on (default: /dev/i2c-1)
+	Device string `yaml:"device"`
+
+	// Address is the chip's 7-bit I2C address (default: 0x40)
+	Address int `yaml:"address"`
+
+	// Chip is the model, ina226 or ina219 (default: ina226)
+	Chip string `yaml:"chip"`
+
+	// ShuntResistance is the shunt resistor value in ohms
+	ShuntResistance float64 `yaml:"shuntResistance"`
+
+	// MaxCurrent is the largest expected current in amperes, which sets the
+	// measurement resolution
+	MaxCurrent float64 `yaml:"maxCurrent"`
+
+	PublishPeriod int `yaml:"publishPeriod"`
+
+	// OfflineAfter is how many failed collection cycles in a row publish the
+	// controller as offline (default: 3)
+	OfflineAfter int `yaml:"offlineAfter"`
+}
+
+// Validate checks the configuration for errors. Only called when enabled.
+func (c *Configuration) Validate() error {
+	if address := c.GetAddress(); address < 0x03 || address > 0x77 {
+		return fmt.Errorf("address 0x%02x is outside the 7-bit I2C range", address)
+	}
+	chip, err := LookupChip(c.GetChip())
+	if err != nil {
+		return err
+	}
+	_, _, err = chip.Calibration(c.ShuntResistance, c.MaxCurrent)
+	return err
+}
+
+// GetDevice returns the configured I2C bus device or the default (/dev/i2c-1).
+func (c *Configuration) GetDevice() string {
+	if c.Device == "" {
+		return defaultDevice
+	}
+	return c.Device
+}
+
+// GetAddress returns the configured I2C address or the default (0x40).
+func (c *Configuration) GetAddress() int {
+	if c.Address == 0 {
+		return defaultAddress
+	}
+	return c.Address
+}
+
+// GetChip returns the configured chip model or the default (ina226).
+func (c *Configuration) GetChip() string {
+	if c.Chip == "" {
+		return defaultChip
+	}
+	return c.Chip
+}
+
+type Controller struct {
+	collector           *Collector
+	publisher           publish.MessagePublisher
+	prometheusCollector MetricsCollector
+	scheduler           *gocron.Scheduler
+	deviceID            string
+	health              *health.Tracker
+	last                controllers.Latest[Status]
+	collectInProgress   bool
+	collectMutex        sync.Mutex
+}
+
+// NewController creates a new INA2xx controller with dependency injection for testing.
+// For production use, call NewControllerFromConfig instead.
+func NewController(
+	collector *Collector,
+	publisher publish.MessagePublisher,
+	prometheusCollector MetricsCollector,
+	deviceID string,
+	publishPeriod int,
+	offlineAfter int,
+) (*Controller, error) {
+	if collector == nil {
+		return &Controller{}, nil
+	}
+
+	// Default device ID if not provided
+	if deviceID == "" {
+		deviceID = "controller-1"
+	}
+
+	s := gocron.NewScheduler(time.UTC)
+
+	controller := &Controller{
+		collector:           collector,
+		publisher:           publisher,
+		prometheusCollector: prometheusCollector,
+		deviceID:            deviceID,
+		health:              health.NewTracker(publisher, deviceID, namespace, time.Duration(publishPeriod)*time.Second, offlineAfter),
+		scheduler:           s,
+	}
+
+	_, err := s.Every(publishPeriod).Seconds().Do(controller.collectAndPublish)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start ina2xx publisher %w", err)
+	}
+
+	s.StartAsync()
+
+	// Run initial collection immediately
+	go controller.collectAndPublish()
+
+	return controller, nil
+}
+
+// newControllerForTest creates a Controller without starting the scheduler or background goroutine.
+// This allows tests to call collectAndPublish synchronously without racing.
+func newControllerForTest(
+	collector *Collector,
+	publisher publish.MessagePublisher,
+	prometheusCollector MetricsCollector,
+	deviceID string,
+) *Controller {
+	if deviceID == "" {
+		deviceID = "controller-1"
+	}
+	return &Controller{
+		collector:           collector,
+		publisher:           publisher,
+		prometheusCollector: prometheusCollector,
+		deviceID:            deviceID,
+		health:              health.NewTracker(publisher, deviceID, namespace, 0, 0),
+	}
+}
+
+// NewControllerFromConfig creates a new INA2xx controller from configuration.
+// This is the production entry point that creates all concrete dependencies.
+func NewControllerFromConfig(config Configuration, publisher publish.MessagePublisher, deviceID string) (*Controller, error) {
+	if !config.Enabled {
+		log.Info("ina2xx disabled via configuration")
+		return &Controller{}, nil
+	}
+
+	if config.ShuntResistance <= 0 || config.MaxCurrent <= 0 {
+		log.Warn("ina2xx enabled but no shunt resistance or max current provided")
+		return &Controller{}, nil
+	}
+
+	chip, err := LookupChip(config.GetChip())
+	if err != nil {
+		return nil, err
+	}
+
+	device, err := NewLinuxI2CDevice(config.GetDevice(), uint16(config.GetAddress())) //nolint:gosec // address is validated to the 7-bit range
+	if err != nil {
+		return nil, err
+	}
+
+	collector, err := NewCollector(device, chip, config.ShuntResistance, config.MaxCurrent)
+	if err != nil {
+		_ = device.Close()
+		return nil, err
+	}
+
+	prometheusCollector := NewPrometheusCollector()
+
+	log.Infof("%s configured at %s address 0x%02x", chip.Name, config.GetDevice(), config.GetAddress())
+
+	return NewController(
+		collector,
+		publisher,
+		prometheusCollector,
+		deviceID,
+		config.PublishPeriod,
+		config.OfflineAfter,
+	)
+}
+
+func (i *Controller) collectAndPublish() {
+	// Check if a collection is already in progress
+	i.collectMutex.Lock()
+	if i.collectInProgress {
+		log.Warn("collection already in progress for ina2xx controller, skipping this collection cycle")
+		i.collectMutex.Unlock()
+		return
+	}
+	i.collectInProgress = true
+	i.collectMutex.Unlock()
+
+	// Ensure we clear the flag when done
+	defer func() {
+		i.collectMutex.Lock()
+		i.collectInProgress = false
+		i.collectMutex.Unlock()
+	}()
+
+	log.Debug("collecting and publishing metrics for ina2xx controller")
+
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	status, err := i.collector.GetStatus(ctx)
+	if err != nil {
+		log.Errorf("failed to collect metrics from ina2xx: %s", err)
+		i.prometheusCollector.IncrementFailures()
+		i.health.Failure(err)
+
+		// Publish failure metric to message broker
+		failureMetric := CreateCollectionFailureMetric()
+		payload, err := failureMetric.ToJSON()
+		if err != nil {
+			log.Errorf("failed to marshal failure metric: %s", err)
+			return
+		}
+
+		topicSuffix := fmt.Sprintf("%s/%s/%s", i.deviceID, namespace, failureMetric.Name)
+		i.publisher.Publish(topicSuffix, payload)
+		log.Debugf("published failure metric to %s", topicSuffix)
+
+		return
+	}
+
+	i.last.Set(status)
+
+	i.prometheusCollector.SetMetrics(status)
+
+	// Convert status to individual metrics
+	metrics := ConvertStatusToMetrics(status)
+
+	// Publish each metric individually
+	for _, metric := range metrics {
+		payload, err := metric.ToJSON()
+		if err != nil {
+			log.Errorf("failed to marshal metric %s for publishing: %s", metric.Name, err)
+			continue
+		}
+
+		// Topic format: {deviceId}/ina2xx/{metric-name}
+		topicSuffix := fmt.Sprintf("%s/%s/%s", i.deviceID, namespace, metric.Name)
+		i.publisher.Publish(topicSuffix, payload)
+
+		log.Debugf("published metric %s to %s", metric.Name, topicSuffix)
+	}
+
+	i.health.Success()
+
+	log.Debug("collection done for ina2xx controller")
+}
+
+func (i *Controller) MetricsGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := i.last.Last()
+
+		if status == nil {
+			c.JSON(http.StatusNoContent, gin.H{})
+			return
+		}
+		c.JSON(http.StatusOK, struct {
+			*Status
+			Health health.Health `json:"health"`
+		}{status, i.Health()})
+	}
+}
+
+// Health reports how fresh the last status is.
+func (i *Controller) Health() health.Health {
+	if i.health == nil {
+		return health.Health{Availability: health.Unknown}
+	}
+	return i.health.Health()
+}
+
+// Name returns the controller's name, which its endpoints are under.
+func (i *Controller) Name() string {
+	return namespace
+}
+
+// Type returns the kind of equipment the controller reads.
+func (i *Controller) Type() string {
+	return controllers.TypeCurrentSensor
+}
+
+// Snapshot returns the last status, or nil before the first collection.
+func (i *Controller) Snapshot() any {
+	return i.last.Snapshot()
+}
+
+func (i *Controller) RegisterEndpoints(r *gin.Engine) {
+	if i.collector == nil {
+		return
+	}
+
+	prefix := fmt.Sprintf("/api/%s", namespace)
+
+	r.GET(fmt.Sprintf("%s/metrics", prefix), i.MetricsGet())
+}
+
+func (i *Controller) Enabled() bool {
+	return i.collector != nil
+}
+
+func (i *Controller) Close() error {
+	if i.scheduler != nil {
+		i.scheduler.Stop()
+		log.Debug("ina2xx scheduler stopped")
+	}
+	if i.collector != nil {
+		return i.collector.Close()
+	}
+	return nil
+}
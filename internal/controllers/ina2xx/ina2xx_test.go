@@ -0,0 +1,202 @@
+package ina2xx
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"github.com/lumberbarons/solar-controller/internal/health"
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+)
+
+func TestController_CollectAndPublish(t *testing.T) {
+	t.Run("publishes failure metric when the chip does not answer", func(t *testing.T) {
+		collector, device := newTestCollector(t)
+		device.WriteFunc = func(uint8, uint16) error { return errors.New("remote I/O error") }
+		mockMetrics := &MockMetricsCollector{}
+		mockPublisher := &testutil.MockMessagePublisher{}
+
+		controller := newControllerForTest(collector, mockPublisher, mockMetrics, "test-device-1")
+		controller.collectAndPublish()
+
+		if mockMetrics.FailuresCount != 1 {
+			t.Errorf("Expected FailuresCount = 1, got %d", mockMetrics.FailuresCount)
+		}
+		if len(mockPublisher.PublishCalls) != 1 {
+			t.Fatalf("Expected 1 publish call, got %d", len(mockPublisher.PublishCalls))
+		}
+		if got := mockPublisher.PublishCalls[0].TopicSuffix; got != "test-device-1/ina2xx/collection-failure" {
+			t.Errorf("Expected failure topic, got %q", got)
+		}
+	})
+
+	t.Run("publishes one message per metric", func(t *testing.T) {
+		collector, _ := newTestCollector(t)
+		mockMetrics := &MockMetricsCollector{}
+		mockPublisher := &testutil.MockMessagePublisher{}
+
+		controller := newControllerForTest(collector, mockPublisher, mockMetrics, "test-device-1")
+		controller.collectAndPublish()
+
+		if controller.Snapshot() == nil {
+			t.Error("Snapshot() = nil after a collection, want the status")
+		}
+
+		if mockMetrics.FailuresCount != 0 {
+			t.Errorf("Expected FailuresCount = 0, got %d", mockMetrics.FailuresCount)
+		}
+		if len(mockMetrics.SetMetricsCalls) != 1 {
+			t.Errorf("Expected 1 SetMetrics call, got %d", len(mockMetrics.SetMetricsCalls))
+		}
+
+		metricNames := []string{"bus-voltage", "shunt-voltage", "current", "power", "collection-time", "availability"}
+		if len(mockPublisher.PublishCalls) != len(metricNames) {
+			t.Fatalf("Expected %d publish calls, got %d", len(metricNames), len(mockPublisher.PublishCalls))
+		}
+		for i, name := range metricNames {
+			if got, want := mockPublisher.PublishCalls[i].TopicSuffix, "test-device-1/ina2xx/"+name; got != want {
+				t.Errorf("publish %d topic = %q, want %q", i, got, want)
+			}
+		}
+
+		var payload MetricPayload
+		if err := json.Unmarshal([]byte(mockPublisher.PublishCalls[0].Payload), &payload); err != nil {
+			t.Fatalf("Failed to unmarshal payload: %v", err)
+		}
+		if payload.Value != 12.0 || payload.Unit != "volts" {
+			t.Errorf("bus-voltage payload = %+v, want 12 volts", payload)
+		}
+	})
+
+	t.Run("skips a cycle while one is in progress", func(t *testing.T) {
+		collector, device := newTestCollector(t)
+		controller := newControllerForTest(collector, &testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "")
+		controller.collectInProgress = true
+
+		controller.collectAndPublish()
+
+		if len(device.ReadCalls)+len(device.WriteCalls) != 0 {
+			t.Error("Expected no bus access while a collection is in progress")
+		}
+	})
+}
+
+func TestController_Endpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	collector, _ := newTestCollector(t)
+	controller := newControllerForTest(collector, &testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "")
+
+	router := gin.New()
+	controller.RegisterEndpoints(router)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/ina2xx/metrics", nil))
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("before first collection: status = %d, want 204", recorder.Code)
+	}
+
+	controller.collectAndPublish()
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/ina2xx/metrics", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("after collection: status = %d, want 200", recorder.Code)
+	}
+
+	var status Status
+	if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Failed to unmarshal status: %v", err)
+	}
+	if status.BusVoltage != 12 {
+		t.Errorf("BusVoltage = %v, want 12", status.BusVoltage)
+	}
+}
+
+func TestController_Close(t *testing.T) {
+	collector, device := newTestCollector(t)
+	controller := newControllerForTest(collector, &testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "")
+
+	if err := controller.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if device.CloseCalls != 1 {
+		t.Errorf("device CloseCalls = %d, want 1", device.CloseCalls)
+	}
+}
+
+func TestController_Disabled(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Configuration
+	}{
+		{name: "disabled", config: Configuration{Enabled: false}},
+		{name: "enabled but no shunt", config: Configuration{Enabled: true, PublishPeriod: 30}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, err := NewControllerFromConfig(tt.config, &testutil.MockMessagePublisher{}, "")
+			if err != nil {
+				t.Fatalf("NewControllerFromConfig() error = %v", err)
+			}
+			if controller.Enabled() {
+				t.Error("controller without a shunt reports enabled")
+			}
+
+			if got := controller.Health().Availability; got != health.Unknown {
+				t.Errorf("Health() availability = %s, want unknown", got)
+			}
+			if got := controller.Snapshot(); got != nil {
+				t.Errorf("Snapshot() = %v, want nil", got)
+			}
+
+			router := gin.New()
+			controller.RegisterEndpoints(router)
+			if len(router.Routes()) != 0 {
+				t.Errorf("controller registered %d routes", len(router.Routes()))
+			}
+			if err := controller.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestConfiguration(t *testing.T) {
+	defaults := Configuration{}
+	if defaults.GetDevice() != "/dev/i2c-1" || defaults.GetAddress() != 0x40 || defaults.GetChip() != "ina226" {
+		t.Errorf("defaults = %s 0x%02x %s", defaults.GetDevice(), defaults.GetAddress(), defaults.GetChip())
+	}
+
+	tests := []struct {
+		name    string
+		config  Configuration
+		wantErr bool
+	}{
+		{name: "ina226 defaults", config: Configuration{ShuntResistance: 0.002, MaxCurrent: 20}},
+		{name: "ina219 at 0x41", config: Configuration{Chip: "ina219", Address: 0x41, ShuntResistance: 0.1, MaxCurrent: 3.2}},
+		{name: "unknown chip", config: Configuration{Chip: "ina3221", ShuntResistance: 0.1, MaxCurrent: 1}, wantErr: true},
+		{name: "address out of range", config: Configuration{Address: 0x80, ShuntResistance: 0.1, MaxCurrent: 0.5}, wantErr: true},
+		{name: "shunt range exceeded", config: Configuration{ShuntResistance: 0.1, MaxCurrent: 3.2}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestController_NameAndType(t *testing.T) {
+	controller := &Controller{}
+	if controller.Name() != "ina2xx" || controller.Type() != controllers.TypeCurrentSensor {
+		t.Errorf("Name(), Type() = %q, %q, want ina2xx, %q", controller.Name(), controller.Type(), controllers.TypeCurrentSensor)
+	}
+}
@@ -0,0 +1,24 @@
+package ina2xx
+
+// I2CDevice defines register access to a single device on an I2C bus.
+// This abstraction allows for testing without physical hardware.
+type I2CDevice interface {
+	// ReadRegister reads a 16-bit register, most significant byte first.
+	ReadRegister(reg uint8) (uint16, error)
+
+	// WriteRegister writes a 16-bit register, most significant byte first.
+	WriteRegister(reg uint8, value uint16) error
+
+	// Close releases the bus.
+	Close() error
+}
+
+// MetricsCollector defines the interface for collecting and exposing metrics.
+// This abstraction allows for testing without the Prometheus global registry.
+type MetricsCollector interface {
+	// IncrementFailures increments the failure counter.
+	IncrementFailures()
+
+	// SetMetrics updates all metrics based on the provided status.
+	SetMetrics(status *Status)
+}
@@ -0,0 +1,91 @@
+package ina2xx
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Metric represents a single metric with its value, unit, and timestamp
+type Metric struct {
+	Name      string
+	Value     any
+	Unit      string
+	Timestamp int64
+}
+
+// MetricPayload is the JSON structure published for each metric
+type MetricPayload struct {
+	Value     any    `json:"value"`
+	Unit      string `json:"unit"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// ToJSON converts a Metric to its JSON representation
+func (m *Metric) ToJSON() (string, error) {
+	payload := MetricPayload{
+		Value:     m.Value,
+		Unit:      m.Unit,
+		Timestamp: m.Timestamp,
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal metric payload: %w", err)
+	}
+
+	return string(b), nil
+}
+
+// ConvertStatusToMetrics converts a Status into individual metrics. Current
+// and power are positive in the direction the shunt's IN+ to IN- is wired.
+func ConvertStatusToMetrics(status *Status) []Metric {
+	if status == nil {
+		return []Metric{}
+	}
+
+	timestamp := status.Timestamp
+
+	return []Metric{
+		{
+			Name:      "bus-voltage",
+			Value:     status.BusVoltage,
+			Unit:      "volts",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      "shunt-voltage",
+			Value:     status.ShuntVoltage,
+			Unit:      "millivolts",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      "current",
+			Value:     status.Current,
+			Unit:      "amperes",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      "power",
+			Value:     status.Power,
+			Unit:      "watts",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      "collection-time",
+			Value:     status.CollectionTime,
+			Unit:      "seconds",
+			Timestamp: timestamp,
+		},
+	}
+}
+
+// CreateCollectionFailureMetric creates a failure metric when collection fails
+func CreateCollectionFailureMetric() Metric {
+	return Metric{
+		Name:      "collection-failure",
+		Value:     1,
+		Unit:      "count",
+		Timestamp: time.Now().Unix(),
+	}
+}
@@ -0,0 +1,80 @@
+package ina2xx
+
+import (
+	"sync"
+)
+
+// MockI2CDevice is a mock implementation of the I2CDevice interface for testing.
+// Registers holds the register file; ReadFunc and WriteFunc override it.
+type MockI2CDevice struct {
+	mu sync.Mutex
+
+	Registers map[uint8]uint16
+	ReadFunc  func(reg uint8) (uint16, error)
+	WriteFunc func(reg uint8, value uint16) error
+
+	// Call tracking
+	ReadCalls  []uint8
+	WriteCalls []uint8
+	CloseCalls int
+}
+
+// Verify MockI2CDevice implements I2CDevice
+var _ I2CDevice = (*MockI2CDevice)(nil)
+
+func (m *MockI2CDevice) ReadRegister(reg uint8) (uint16, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ReadCalls = append(m.ReadCalls, reg)
+
+	if m.ReadFunc != nil {
+		return m.ReadFunc(reg)
+	}
+	return m.Registers[reg], nil
+}
+
+func (m *MockI2CDevice) WriteRegister(reg uint8, value uint16) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.WriteCalls = append(m.WriteCalls, reg)
+
+	if m.WriteFunc != nil {
+		return m.WriteFunc(reg, value)
+	}
+	if m.Registers == nil {
+		m.Registers = make(map[uint8]uint16)
+	}
+	m.Registers[reg] = value
+	return nil
+}
+
+func (m *MockI2CDevice) Close() error {
+	m.mu.Lock()
+	m.CloseCalls++
+	m.mu.Unlock()
+	return nil
+}
+
+// MockMetricsCollector is a mock implementation of the MetricsCollector interface for testing.
+type MockMetricsCollector struct {
+	mu sync.RWMutex
+
+	// Call tracking
+	FailuresCount   int
+	SetMetricsCalls []*Status
+}
+
+// Verify MockMetricsCollector implements MetricsCollector
+var _ MetricsCollector = (*MockMetricsCollector)(nil)
+
+func (m *MockMetricsCollector) IncrementFailures() {
+	m.mu.Lock()
+	m.FailuresCount++
+	m.mu.Unlock()
+}
+
+func (m *MockMetricsCollector) SetMetrics(status *Status) {
+	m.mu.Lock()
+	m.SetMetricsCalls = append(m.SetMetricsCalls, status)
+	m.mu.Unlock()
+}
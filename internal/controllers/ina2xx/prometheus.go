@@ -0,0 +1,67 @@
+package ina2xx
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type PrometheusCollector struct {
+	failures prometheus.Counter
+
+	busVoltage   prometheus.Gauge
+	shuntVoltage prometheus.Gauge
+	current      prometheus.Gauge
+	power        prometheus.Gauge
+}
+
+func NewPrometheusCollector() *PrometheusCollector {
+	endpoint := &PrometheusCollector{
+		failures: promauto.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "read_failures",
+			Help:      "Number of errors while reading from the INA2xx.",
+		}),
+	}
+
+	// Initialize all metrics immediately to avoid race conditions
+	endpoint.initializeMetrics()
+
+	return endpoint
+}
+
+func (i *PrometheusCollector) IncrementFailures() {
+	i.failures.Inc()
+}
+
+func (i *PrometheusCollector) initializeMetrics() {
+	i.busVoltage = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "bus_voltage",
+		Help:      "Bus voltage (V).",
+	})
+
+	i.shuntVoltage = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "shunt_voltage",
+		Help:      "Voltage across the shunt (mV).",
+	})
+
+	i.current = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "current",
+		Help:      "Shunt current (A), signed by the direction of flow through the shunt.",
+	})
+
+	i.power = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "power",
+		Help:      "Power (W) as calculated by the chip, signed like current.",
+	})
+}
+
+func (i *PrometheusCollector) SetMetrics(status *Status) {
+	i.busVoltage.Set(status.BusVoltage)
+	i.shuntVoltage.Set(status.ShuntVoltage)
+	i.current.Set(status.Current)
+	i.power.Set(status.Power)
+}
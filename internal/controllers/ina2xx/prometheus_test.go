@@ -0,0 +1,39 @@
+package ina2xx
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Verify PrometheusCollector implements MetricsCollector
+var _ MetricsCollector = (*PrometheusCollector)(nil)
+
+// The collector registers with the global Prometheus registry via promauto,
+// so it can only be created once per test binary.
+func TestPrometheusCollector(t *testing.T) {
+	collector := NewPrometheusCollector()
+
+	collector.SetMetrics(&Status{BusVoltage: 12.5, ShuntVoltage: -10, Current: -5, Power: -62.5})
+
+	checks := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"bus_voltage", testutil.ToFloat64(collector.busVoltage), 12.5},
+		{"shunt_voltage", testutil.ToFloat64(collector.shuntVoltage), -10},
+		{"current", testutil.ToFloat64(collector.current), -5},
+		{"power", testutil.ToFloat64(collector.power), -62.5},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+
+	collector.IncrementFailures()
+	if got := testutil.ToFloat64(collector.failures); got != 1 {
+		t.Errorf("read_failures = %v, want 1", got)
+	}
+}
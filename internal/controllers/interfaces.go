@@ -1,12 +1,49 @@
 package controllers
 
 import (
+	"errors"
+
 	"github.com/gin-gonic/gin"
+	"github.com/lumberbarons/solar-controller/internal/health"
 )
 
+// The kinds of equipment a controller can read, as returned by Type.
+const (
+	TypeChargeController  = "charge-controller"
+	TypeBattery           = "battery"
+	TypeCurrentSensor     = "current-sensor"
+	TypeTemperatureSensor = "temperature-sensor"
+
+	// TypeSystem is a controller that works its data out from other
+	// controllers' rather than reading equipment
+	TypeSystem = "system"
+)
+
+// ErrDeviceUnavailable marks a constructor error caused by the controller's
+// device being absent: an unplugged adapter, a missing CAN interface or bus.
+// Such a controller waits for its device and keeps retrying; any other
+// constructor error fails startup.
+var ErrDeviceUnavailable = errors.New("device unavailable")
+
 // SolarController defines the interface that all solar equipment controllers must implement.
 // Controllers manage the lifecycle of hardware communication, metrics collection, and API endpoints.
 type SolarController interface {
+	// Name returns the controller's name, which its endpoints are under
+	// (/api/{name}) and GET /api/controllers lists it by.
+	Name() string
+
+	// Type returns the kind of equipment the controller reads, one of the
+	// Type constants.
+	Type() string
+
+	// Health reports how fresh the controller's data is. GET
+	// /api/controllers/{name}/health reports it.
+	Health() health.Health
+
+	// Snapshot returns the latest data the controller collected, or nil
+	// before its first successful collection. GET /api/snapshot reports it.
+	Snapshot() any
+
 	// RegisterEndpoints registers HTTP endpoints for this controller.
 	RegisterEndpoints(r *gin.Engine)
 
@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"sync"
+)
+
+// Latest holds the last status a controller collected, for its metrics
+// endpoint and GET /api/snapshot. Controllers keep one in a last field and
+// return its Snapshot from their own. The zero value holds none.
+type Latest[T any] struct {
+	mu     sync.RWMutex
+	status *T
+}
+
+// Set replaces the last status.
+func (l *Latest[T]) Set(status *T) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.status = status
+}
+
+// Last returns the last status, or nil before the first collection.
+func (l *Latest[T]) Last() *T {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.status
+}
+
+// Snapshot returns the last status, or nil before the first collection. The
+// nil is untyped, so callers can compare the result with nil.
+func (l *Latest[T]) Snapshot() any {
+	if status := l.Last(); status != nil {
+		return status
+	}
+	return nil
+}
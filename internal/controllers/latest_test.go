@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"testing"
+)
+
+type testStatus struct {
+	Timestamp int64
+}
+
+func TestLatest(t *testing.T) {
+	var latest Latest[testStatus]
+	if got := latest.Snapshot(); got != nil {
+		t.Errorf("Snapshot() before the first collection = %#v, want nil", got)
+	}
+	if got := latest.Last(); got != nil {
+		t.Errorf("Last() before the first collection = %v, want nil", got)
+	}
+
+	status := &testStatus{Timestamp: 1700000000}
+	latest.Set(status)
+	if got := latest.Last(); got != status {
+		t.Errorf("Last() = %v, want the status set", got)
+	}
+	if got := latest.Snapshot(); got != status {
+		t.Errorf("Snapshot() = %v, want the status set", got)
+	}
+}
@@ -0,0 +1,147 @@
+package onewire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// crcAttempts is how many times a sensor is read before a CRC failure is
+// reported. CRC errors are usually one-off bus noise, and each read is a
+// fresh conversion.
+const crcAttempts = 3
+
+// Collector reads every configured sensor, plus any unconfigured sensor
+// found on the bus, which is reported under its ID.
+type Collector struct {
+	bus                 SensorBus
+	sensors             []SensorConfig
+	prometheusCollector MetricsCollector
+}
+
+type Status struct {
+	Timestamp      int64           `json:"timestamp"`
+	CollectionTime float64         `json:"collectionTime"`
+	Sensors        []SensorReading `json:"sensors"`
+}
+
+// SensorReading is one sensor's result for a cycle. Temperature is null and
+// Error set when the sensor could not be read.
+type SensorReading struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Temperature *float64 `json:"temperature"`
+	Error       string   `json:"error,omitempty"`
+}
+
+func NewCollector(bus SensorBus, sensors []SensorConfig, prometheusCollector MetricsCollector) *Collector {
+	return &Collector{
+		bus:                 bus,
+		sensors:             sensors,
+		prometheusCollector: prometheusCollector,
+	}
+}
+
+// GetStatus reads all sensors. A sensor that fails is reported in its
+// reading without failing the cycle; the cycle fails only when no sensor
+// could be read at all.
+func (c *Collector) GetStatus(ctx context.Context) (*Status, error) {
+	startTime := time.Now()
+	log.Debug("Starting onewire GetStatus collection")
+
+	present, err := c.bus.List()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{
+		Timestamp: startTime.Unix(),
+		Sensors:   make([]SensorReading, 0, len(c.sensors)+len(present)),
+	}
+
+	presentSet := make(map[string]bool, len(present))
+	for _, id := range present {
+		presentSet[id] = true
+	}
+
+	configured := make(map[string]bool, len(c.sensors))
+	targets := make([]SensorConfig, 0, len(c.sensors)+len(present))
+	for _, sensor := range c.sensors {
+		configured[sensor.ID] = true
+		targets = append(targets, sensor)
+	}
+	for _, id := range present {
+		if !configured[id] {
+			targets = append(targets, SensorConfig{ID: id, Name: id})
+		}
+	}
+
+	succeeded := 0
+	for _, sensor := range targets {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("onewire collection interrupted: %w", err)
+		}
+
+		reading := SensorReading{ID: sensor.ID, Name: sensor.Name}
+
+		var temperature float64
+		if !presentSet[sensor.ID] {
+			err = ErrSensorMissing
+		} else {
+			temperature, err = c.readWithRetry(sensor.ID)
+		}
+
+		if err != nil {
+			log.Warnf("failed to read 1-Wire sensor %s (%s): %s", sensor.Name, sensor.ID, err)
+			c.prometheusCollector.IncrementSensorFailure(sensor.Name, failureReason(err))
+			reading.Error = err.Error()
+		} else {
+			reading.Temperature = &temperature
+			succeeded++
+		}
+		status.Sensors = append(status.Sensors, reading)
+	}
+
+	if succeeded == 0 {
+		if len(targets) == 0 {
+			return nil, errors.New("no 1-Wire temperature sensors found")
+		}
+		return nil, fmt.Errorf("none of %d 1-Wire sensors could be read", len(targets))
+	}
+
+	status.CollectionTime = time.Since(startTime).Seconds()
+	log.Debugf("onewire GetStatus completed in %.3fs - %d of %d sensors read",
+		status.CollectionTime, succeeded, len(targets))
+
+	return status, nil
+}
+
+func (c *Collector) readWithRetry(id string) (float64, error) {
+	var err error
+	for attempt := 1; attempt <= crcAttempts; attempt++ {
+		var temperature float64
+		temperature, err = c.bus.Read(id)
+		if !errors.Is(err, ErrCRC) {
+			return temperature, err
+		}
+		log.Debugf("1-Wire sensor %s CRC failure, attempt %d of %d", id, attempt, crcAttempts)
+	}
+	return 0, err
+}
+
+// failureReason maps a read error to the reason label used in metrics.
+func failureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrCRC):
+		return "crc"
+	case errors.Is(err, ErrSensorMissing):
+		return "missing"
+	case errors.Is(err, ErrPowerOnReset):
+		return "power-on-reset"
+	default:
+		return "read"
+	}
+}
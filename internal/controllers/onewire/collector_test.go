@@ -0,0 +1,153 @@
+package onewire
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCollector_GetStatus(t *testing.T) {
+	t.Run("reads configured and discovered sensors against a fake tree", func(t *testing.T) {
+		root := writeFakeW1(t, map[string]string{
+			"28-000000000001": w1Good,
+			"28-000000000002": w1Bad,
+			"28-000000000003": w1Good,
+		})
+		sensors := []SensorConfig{
+			{ID: "28-000000000001", Name: "battery-box"},
+			{ID: "28-000000000002", Name: "enclosure"},
+			{ID: "28-0000000000ff", Name: "outside"},
+		}
+		mockMetrics := &MockMetricsCollector{}
+		collector := NewCollector(NewSysfsBus(root), sensors, mockMetrics)
+
+		status, err := collector.GetStatus(context.Background())
+		if err != nil {
+			t.Fatalf("GetStatus() error: %v", err)
+		}
+
+		names := make([]string, 0, len(status.Sensors))
+		for _, s := range status.Sensors {
+			names = append(names, s.Name)
+		}
+		wantNames := []string{"battery-box", "enclosure", "outside", "28-000000000003"}
+		if !reflect.DeepEqual(names, wantNames) {
+			t.Errorf("sensor names = %v, want %v", names, wantNames)
+		}
+
+		if s := status.Sensors[0]; s.Temperature == nil || *s.Temperature != 23.125 || s.Error != "" {
+			t.Errorf("battery-box reading = %+v, want 23.125", s)
+		}
+		if s := status.Sensors[1]; s.Temperature != nil || s.Error != ErrCRC.Error() {
+			t.Errorf("enclosure reading = %+v, want CRC error", s)
+		}
+		if s := status.Sensors[2]; s.Temperature != nil || s.Error != ErrSensorMissing.Error() {
+			t.Errorf("outside reading = %+v, want missing error", s)
+		}
+
+		wantFailures := []string{"enclosure/crc", "outside/missing"}
+		if !reflect.DeepEqual(mockMetrics.SensorFailures, wantFailures) {
+			t.Errorf("SensorFailures = %v, want %v", mockMetrics.SensorFailures, wantFailures)
+		}
+	})
+
+	t.Run("retries CRC failures", func(t *testing.T) {
+		attempts := 0
+		bus := &MockSensorBus{
+			ListFunc: func() ([]string, error) { return []string{"28-000000000001"}, nil },
+			ReadFunc: func(string) (float64, error) {
+				attempts++
+				if attempts < crcAttempts {
+					return 0, ErrCRC
+				}
+				return 21.5, nil
+			},
+		}
+		mockMetrics := &MockMetricsCollector{}
+
+		status, err := NewCollector(bus, nil, mockMetrics).GetStatus(context.Background())
+		if err != nil {
+			t.Fatalf("GetStatus() error: %v", err)
+		}
+		if got := status.Sensors[0].Temperature; got == nil || *got != 21.5 {
+			t.Errorf("temperature = %v, want 21.5", got)
+		}
+		if len(bus.ReadCalls) != crcAttempts {
+			t.Errorf("Read calls = %d, want %d", len(bus.ReadCalls), crcAttempts)
+		}
+		if len(mockMetrics.SensorFailures) != 0 {
+			t.Errorf("SensorFailures = %v, want none", mockMetrics.SensorFailures)
+		}
+	})
+
+	t.Run("does not retry other errors", func(t *testing.T) {
+		bus := &MockSensorBus{
+			ListFunc: func() ([]string, error) { return []string{"28-000000000001", "28-000000000002"}, nil },
+			ReadFunc: func(id string) (float64, error) {
+				if id == "28-000000000001" {
+					return 0, ErrPowerOnReset
+				}
+				return 20, nil
+			},
+		}
+		mockMetrics := &MockMetricsCollector{}
+
+		if _, err := NewCollector(bus, nil, mockMetrics).GetStatus(context.Background()); err != nil {
+			t.Fatalf("GetStatus() error: %v", err)
+		}
+		if len(bus.ReadCalls) != 2 {
+			t.Errorf("Read calls = %v, want one per sensor", bus.ReadCalls)
+		}
+		if want := []string{"28-000000000001/power-on-reset"}; !reflect.DeepEqual(mockMetrics.SensorFailures, want) {
+			t.Errorf("SensorFailures = %v, want %v", mockMetrics.SensorFailures, want)
+		}
+	})
+
+	t.Run("fails when no sensor can be read", func(t *testing.T) {
+		bus := &MockSensorBus{
+			ListFunc: func() ([]string, error) { return []string{"28-000000000001"}, nil },
+			ReadFunc: func(string) (float64, error) { return 0, errors.New("i/o error") },
+		}
+		mockMetrics := &MockMetricsCollector{}
+
+		if _, err := NewCollector(bus, nil, mockMetrics).GetStatus(context.Background()); err == nil {
+			t.Error("GetStatus() expected error")
+		}
+		if want := []string{"28-000000000001/read"}; !reflect.DeepEqual(mockMetrics.SensorFailures, want) {
+			t.Errorf("SensorFailures = %v, want %v", mockMetrics.SensorFailures, want)
+		}
+	})
+
+	t.Run("fails when the bus is empty", func(t *testing.T) {
+		_, err := NewCollector(&MockSensorBus{}, nil, &MockMetricsCollector{}).GetStatus(context.Background())
+		if err == nil {
+			t.Error("GetStatus() expected error")
+		}
+	})
+
+	t.Run("fails when the bus cannot be listed", func(t *testing.T) {
+		bus := &MockSensorBus{
+			ListFunc: func() ([]string, error) { return nil, errors.New("no w1 bus") },
+		}
+		_, err := NewCollector(bus, nil, &MockMetricsCollector{}).GetStatus(context.Background())
+		if err == nil {
+			t.Error("GetStatus() expected error")
+		}
+	})
+
+	t.Run("stops when the context is cancelled", func(t *testing.T) {
+		bus := &MockSensorBus{
+			ListFunc: func() ([]string, error) { return []string{"28-000000000001"}, nil },
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, err := NewCollector(bus, nil, &MockMetricsCollector{}).GetStatus(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("GetStatus() error = %v, want context.Canceled", err)
+		}
+		if len(bus.ReadCalls) != 0 {
+			t.Errorf("Read calls = %v, want none", bus.ReadCalls)
+		}
+	})
+}
@@ -0,0 +1,24 @@
+package onewire
+
+// SensorBus defines the interface for reading 1-Wire temperature sensors.
+// This abstraction allows for testing without a w1 bus.
+type SensorBus interface {
+	// List returns the IDs of the temperature sensors present on the bus.
+	List() ([]string, error)
+
+	// Read returns one sensor's temperature in degrees Celsius.
+	Read(id string) (float64, error)
+}
+
+// MetricsCollector defines the interface for collecting and exposing metrics.
+// This abstraction allows for testing without the Prometheus global registry.
+type MetricsCollector interface {
+	// IncrementFailures increments the collection failure counter.
+	IncrementFailures()
+
+	// IncrementSensorFailure increments the failure counter for one sensor.
+	IncrementSensorFailure(sensor, reason string)
+
+	// SetMetrics updates all metrics based on the provided status.
+	SetMetrics(status *Status)
+}
@@ -0,0 +1,89 @@
+package onewire
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Metric represents a single metric with its value, unit, and timestamp
+type Metric struct {
+	Name      string
+	Value     any
+	Unit      string
+	Timestamp int64
+}
+
+// MetricPayload is the JSON structure published for each metric
+type MetricPayload struct {
+	Value     any    `json:"value"`
+	Unit      string `json:"unit"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// ToJSON converts a Metric to its JSON representation
+func (m *Metric) ToJSON() (string, error) {
+	payload := MetricPayload{
+		Value:     m.Value,
+		Unit:      m.Unit,
+		Timestamp: m.Timestamp,
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal metric payload: %w", err)
+	}
+
+	return string(b), nil
+}
+
+// ConvertStatusToMetrics converts a Status into individual metrics: one
+// {name}-temp metric per sensor that was read, plus a count of the sensors
+// that failed. A failed sensor publishes nothing rather than a stale value.
+func ConvertStatusToMetrics(status *Status) []Metric {
+	if status == nil {
+		return []Metric{}
+	}
+
+	timestamp := status.Timestamp
+	metrics := make([]Metric, 0, len(status.Sensors)+2)
+
+	failed := 0
+	for _, sensor := range status.Sensors {
+		if sensor.Temperature == nil {
+			failed++
+			continue
+		}
+		metrics = append(metrics, Metric{
+			Name:      sensor.Name + "-temp",
+			Value:     *sensor.Temperature,
+			Unit:      "celsius",
+			Timestamp: timestamp,
+		})
+	}
+
+	return append(metrics,
+		Metric{
+			Name:      "sensor-failures",
+			Value:     failed,
+			Unit:      "count",
+			Timestamp: timestamp,
+		},
+		Metric{
+			Name:      "collection-time",
+			Value:     status.CollectionTime,
+			Unit:      "seconds",
+			Timestamp: timestamp,
+		},
+	)
+}
+
+// CreateCollectionFailureMetric creates a failure metric when collection fails
+func CreateCollectionFailureMetric() Metric {
+	return Metric{
+		Name:      "collection-failure",
+		Value:     1,
+		Unit:      "count",
+		Timestamp: time.Now().Unix(),
+	}
+}
@@ -0,0 +1,73 @@
+package onewire
+
+import (
+	"sync"
+)
+
+// MockSensorBus is a mock implementation of the SensorBus interface for testing.
+type MockSensorBus struct {
+	mu sync.Mutex
+
+	ListFunc func() ([]string, error)
+	ReadFunc func(id string) (float64, error)
+
+	// Call tracking
+	ListCalls int
+	ReadCalls []string
+}
+
+// Verify MockSensorBus implements SensorBus
+var _ SensorBus = (*MockSensorBus)(nil)
+
+func (m *MockSensorBus) List() ([]string, error) {
+	m.mu.Lock()
+	m.ListCalls++
+	m.mu.Unlock()
+
+	if m.ListFunc != nil {
+		return m.ListFunc()
+	}
+	return []string{}, nil
+}
+
+func (m *MockSensorBus) Read(id string) (float64, error) {
+	m.mu.Lock()
+	m.ReadCalls = append(m.ReadCalls, id)
+	m.mu.Unlock()
+
+	if m.ReadFunc != nil {
+		return m.ReadFunc(id)
+	}
+	return 0, nil
+}
+
+// MockMetricsCollector is a mock implementation of the MetricsCollector interface for testing.
+type MockMetricsCollector struct {
+	mu sync.RWMutex
+
+	// Call tracking
+	FailuresCount   int
+	SensorFailures  []string
+	SetMetricsCalls []*Status
+}
+
+// Verify MockMetricsCollector implements MetricsCollector
+var _ MetricsCollector = (*MockMetricsCollector)(nil)
+
+func (m *MockMetricsCollector) IncrementFailures() {
+	m.mu.Lock()
+	m.FailuresCount++
+	m.mu.Unlock()
+}
+
+func (m *MockMetricsCollector) IncrementSensorFailure(sensor, reason string) {
+	m.mu.Lock()
+	m.SensorFailures = append(m.SensorFailures, sensor+"/"+reason)
+	m.mu.Unlock()
+}
+
+func (m *MockMetricsCollector) SetMetrics(status *Status) {
+	m.mu.Lock()
+	m.SetMetricsCalls = append(m.SetMetricsCalls, status)
+	m.mu.Unlock()
+}
@@ -0,0 +1,310 @@
+package onewire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-co-op/gocron"
+	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"github.com/lumberbarons/solar-controller/internal/health"
+	"github.com/lumberbarons/solar-controller/internal/publish"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	namespace = "onewire"
+
+	// collectTimeout bounds a full collection cycle. A DS18B20 conversion
+	// takes up to 750ms, so this covers a few dozen sensors with retries.
+	collectTimeout = 60 * time.Second
+)
+
+// sensorNamePattern restricts sensor names to what is safe as a topic
+// segment and converts cleanly to a Prometheus metric name.
+var sensorNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+type Configuration struct {
+	Enabled       bool   `yaml:"enabled"`
+	DevicesPath   string `yaml:"devicesPath"`
+	PublishPeriod int    `yaml:"publishPeriod"`
+
+	// OfflineAfter is how many failed collection cycles in a row publish the
+	// controller as offline (default: 3)
+	OfflineAfter int `yaml:"offlineAfter"`
+
+	Sensors []SensorConfig `yaml:"sensors"`
+}
+
+// SensorConfig gives a sensor, identified by its w1 device ID (for example
+// 28-0316a2798aff), the friendly name it is published under.
+type SensorConfig struct {
+	ID   string `yaml:"id"`
+	Name string `yaml:"name"`
+}
+
+// Validate checks the configuration for errors. Only called when enabled.
+func (c *Configuration) Validate() error {
+	ids := make(map[string]bool, len(c.Sensors))
+	names := make(map[string]bool, len(c.Sensors))
+	for i, sensor := range c.Sensors {
+		if sensor.ID == "" {
+			return fmt.Errorf("sensor %d has no id", i)
+		}
+		if !sensorNamePattern.MatchString(sensor.Name) {
+			return fmt.Errorf("sensor %s name %q must be lowercase letters, digits and dashes", sensor.ID, sensor.Name)
+		}
+		if ids[sensor.ID] {
+			return fmt.Errorf("sensor id %s is listed more than once", sensor.ID)
+		}
+		if names[sensor.Name] {
+			return fmt.Errorf("sensor name %s is used more than once", sensor.Name)
+		}
+		ids[sensor.ID] = true
+		names[sensor.Name] = true
+	}
+	return nil
+}
+
+type Controller struct {
+	collector           *Collector
+	publisher           publish.MessagePublisher
+	prometheusCollector MetricsCollector
+	scheduler           *gocron.Scheduler
+	deviceID            string
+	health              *health.Tracker
+	last                controllers.Latest[Status]
+	collectInProgress   bool
+	collectMutex        sync.Mutex
+}
+
+// NewController creates a new 1-Wire controller with dependency injection for testing.
+// For production use, call NewControllerFromConfig instead.
+func NewController(
+	collector *Collector,
+	publisher publish.MessagePublisher,
+	prometheusCollector MetricsCollector,
+	deviceID string,
+	publishPeriod int,
+	offlineAfter int,
+) (*Controller, error) {
+	if collector == nil {
+		return &Controller{}, nil
+	}
+
+	// Default device ID if not provided
+	if deviceID == "" {
+		deviceID = "controller-1"
+	}
+
+	s := gocron.NewScheduler(time.UTC)
+
+	controller := &Controller{
+		collector:           collector,
+		publisher:           publisher,
+		prometheusCollector: prometheusCollector,
+		deviceID:            deviceID,
+		health:              health.NewTracker(publisher, deviceID, namespace, time.Duration(publishPeriod)*time.Second, offlineAfter),
+		scheduler:           s,
+	}
+
+	_, err := s.Every(publishPeriod).Seconds().Do(controller.collectAndPublish)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start onewire publisher %w", err)
+	}
+
+	s.StartAsync()
+
+	// Run initial collection immediately
+	go controller.collectAndPublish()
+
+	return controller, nil
+}
+
+// newControllerForTest creates a Controller without starting the scheduler or background goroutine.
+// This allows tests to call collectAndPublish synchronously without racing.
+func newControllerForTest(
+	collector *Collector,
+	publisher publish.MessagePublisher,
+	prometheusCollector MetricsCollector,
+	deviceID string,
+) *Controller {
+	if deviceID == "" {
+		deviceID = "controller-1"
+	}
+	return &Controller{
+		collector:           collector,
+		publisher:           publisher,
+		prometheusCollector: prometheusCollector,
+		deviceID:            deviceID,
+		health:              health.NewTracker(publisher, deviceID, namespace, 0, 0),
+	}
+}
+
+// NewControllerFromConfig creates a new 1-Wire controller from configuration.
+// This is the production entry point that creates all concrete dependencies.
+//
+// A missing w1 directory is reported as controllers.ErrDeviceUnavailable: the
+// w1-gpio overlay or module may load after the service, and the controller
+// waits for it.
+func NewControllerFromConfig(config Configuration, publisher publish.MessagePublisher, deviceID string) (*Controller, error) {
+	if !config.Enabled {
+		log.Info("onewire disabled via configuration")
+		return &Controller{}, nil
+	}
+
+	bus := NewSysfsBus(config.DevicesPath)
+	if _, err := os.Stat(bus.root); errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("1-Wire bus %s not found: %w: %w", bus.root, controllers.ErrDeviceUnavailable, err)
+	}
+	prometheusCollector := NewPrometheusCollector()
+	collector := NewCollector(bus, config.Sensors, prometheusCollector)
+
+	log.Infof("reading 1-Wire sensors from %s (%d named)", bus.root, len(config.Sensors))
+
+	return NewController(
+		collector,
+		publisher,
+		prometheusCollector,
+		deviceID,
+		config.PublishPeriod,
+		config.OfflineAfter,
+	)
+}
+
+func (o *Controller) collectAndPublish() {
+	// Check if a collection is already in progress
+	o.collectMutex.Lock()
+	if o.collectInProgress {
+		log.Warn("collection already in progress for onewire controller, skipping this collection cycle")
+		o.collectMutex.Unlock()
+		return
+	}
+	o.collectInProgress = true
+	o.collectMutex.Unlock()
+
+	// Ensure we clear the flag when done
+	defer func() {
+		o.collectMutex.Lock()
+		o.collectInProgress = false
+		o.collectMutex.Unlock()
+	}()
+
+	log.Debug("collecting and publishing metrics for onewire controller")
+
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	status, err := o.collector.GetStatus(ctx)
+	if err != nil {
+		log.Errorf("failed to collect metrics from 1-Wire sensors: %s", err)
+		o.prometheusCollector.IncrementFailures()
+		o.health.Failure(err)
+
+		// Publish failure metric to message broker
+		failureMetric := CreateCollectionFailureMetric()
+		payload, err := failureMetric.ToJSON()
+		if err != nil {
+			log.Errorf("failed to marshal failure metric: %s", err)
+			return
+		}
+
+		topicSuffix := fmt.Sprintf("%s/%s/%s", o.deviceID, namespace, failureMetric.Name)
+		o.publisher.Publish(topicSuffix, payload)
+		log.Debugf("published failure metric to %s", topicSuffix)
+
+		return
+	}
+
+	o.last.Set(status)
+
+	o.prometheusCollector.SetMetrics(status)
+
+	// Convert status to individual metrics
+	metrics := ConvertStatusToMetrics(status)
+
+	// Publish each metric individually
+	for _, metric := range metrics {
+		payload, err := metric.ToJSON()
+		if err != nil {
+			log.Errorf("failed to marshal metric %s for publishing: %s", metric.Name, err)
+			continue
+		}
+
+		// Topic format: {deviceId}/onewire/{metric-name}
+		topicSuffix := fmt.Sprintf("%s/%s/%s", o.deviceID, namespace, metric.Name)
+		o.publisher.Publish(topicSuffix, payload)
+
+		log.Debugf("published metric %s to %s", metric.Name, topicSuffix)
+	}
+
+	o.health.Success()
+
+	log.Debug("collection done for onewire controller")
+}
+
+func (o *Controller) MetricsGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := o.last.Last()
+
+		if status == nil {
+			c.JSON(http.StatusNoContent, gin.H{})
+			return
+		}
+		c.JSON(http.StatusOK, struct {
+			*Status
+			Health health.Health `json:"health"`
+		}{status, o.Health()})
+	}
+}
+
+// Health reports how fresh the last status is.
+func (o *Controller) Health() health.Health {
+	if o.health == nil {
+		return health.Health{Availability: health.Unknown}
+	}
+	return o.health.Health()
+}
+
+// Name returns the controller's name, which its endpoints are under.
+func (o *Controller) Name() string {
+	return namespace
+}
+
+// Type returns the kind of equipment the controller reads.
+func (o *Controller) Type() string {
+	return controllers.TypeTemperatureSensor
+}
+
+// Snapshot returns the last status, or nil before the first collection.
+func (o *Controller) Snapshot() any {
+	return o.last.Snapshot()
+}
+
+func (o *Controller) RegisterEndpoints(r *gin.Engine) {
+	if o.collector == nil {
+		return
+	}
+
+	prefix := fmt.Sprintf("/api/%s", namespace)
+
+	r.GET(fmt.Sprintf("%s/metrics", prefix), o.MetricsGet())
+}
+
+func (o *Controller) Enabled() bool {
+	return o.collector != nil
+}
+
+func (o *Controller) Close() error {
+	if o.scheduler != nil {
+		o.scheduler.Stop()
+		log.Debug("onewire scheduler stopped")
+	}
+	return nil
+}
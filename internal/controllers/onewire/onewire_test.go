@@ -0,0 +1,224 @@
+package onewire
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-co-op/gocron"
+	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"github.com/lumberbarons/solar-controller/internal/health"
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+)
+
+func TestController_CollectAndPublish(t *testing.T) {
+	t.Run("publishes failure metric when no sensor can be read", func(t *testing.T) {
+		mockMetrics := &MockMetricsCollector{}
+		mockPublisher := &testutil.MockMessagePublisher{}
+		collector := NewCollector(&MockSensorBus{}, nil, mockMetrics)
+
+		controller := newControllerForTest(collector, mockPublisher, mockMetrics, "test-device-1")
+		controller.collectAndPublish()
+
+		if mockMetrics.FailuresCount != 1 {
+			t.Errorf("Expected FailuresCount = 1, got %d", mockMetrics.FailuresCount)
+		}
+		if len(mockPublisher.PublishCalls) != 1 {
+			t.Fatalf("Expected 1 publish call, got %d", len(mockPublisher.PublishCalls))
+		}
+		if got := mockPublisher.PublishCalls[0].TopicSuffix; got != "test-device-1/onewire/collection-failure" {
+			t.Errorf("Expected failure topic, got %q", got)
+		}
+	})
+
+	t.Run("publishes a temperature per readable sensor", func(t *testing.T) {
+		root := writeFakeW1(t, map[string]string{
+			"28-000000000001": w1Good,
+			"28-000000000002": w1Bad,
+		})
+		sensors := []SensorConfig{
+			{ID: "28-000000000001", Name: "battery-box"},
+			{ID: "28-000000000002", Name: "enclosure"},
+		}
+		mockMetrics := &MockMetricsCollector{}
+		mockPublisher := &testutil.MockMessagePublisher{}
+		collector := NewCollector(NewSysfsBus(root), sensors, mockMetrics)
+
+		controller := newControllerForTest(collector, mockPublisher, mockMetrics, "test-device-1")
+		controller.collectAndPublish()
+
+		if controller.Snapshot() == nil {
+			t.Error("Snapshot() = nil after a collection, want the status")
+		}
+
+		if mockMetrics.FailuresCount != 0 {
+			t.Errorf("Expected FailuresCount = 0, got %d", mockMetrics.FailuresCount)
+		}
+		if len(mockMetrics.SetMetricsCalls) != 1 {
+			t.Errorf("Expected 1 SetMetrics call, got %d", len(mockMetrics.SetMetricsCalls))
+		}
+
+		topics := []string{
+			"test-device-1/onewire/battery-box-temp",
+			"test-device-1/onewire/sensor-failures",
+			"test-device-1/onewire/collection-time",
+			"test-device-1/onewire/availability",
+		}
+		if len(mockPublisher.PublishCalls) != len(topics) {
+			t.Fatalf("Expected %d publish calls, got %d", len(topics), len(mockPublisher.PublishCalls))
+		}
+		for i, topic := range topics {
+			if got := mockPublisher.PublishCalls[i].TopicSuffix; got != topic {
+				t.Errorf("publish %d topic = %q, want %q", i, got, topic)
+			}
+		}
+
+		var payload MetricPayload
+		if err := json.Unmarshal([]byte(mockPublisher.PublishCalls[0].Payload), &payload); err != nil {
+			t.Fatalf("Failed to unmarshal payload: %v", err)
+		}
+		if payload.Value != 23.125 || payload.Unit != "celsius" {
+			t.Errorf("battery-box-temp payload = %+v, want 23.125 celsius", payload)
+		}
+
+		if err := json.Unmarshal([]byte(mockPublisher.PublishCalls[1].Payload), &payload); err != nil {
+			t.Fatalf("Failed to unmarshal payload: %v", err)
+		}
+		if payload.Value != float64(1) {
+			t.Errorf("sensor-failures = %v, want 1", payload.Value)
+		}
+	})
+
+	t.Run("skips a cycle while one is in progress", func(t *testing.T) {
+		bus := &MockSensorBus{}
+		controller := newControllerForTest(NewCollector(bus, nil, &MockMetricsCollector{}),
+			&testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "")
+		controller.collectInProgress = true
+
+		controller.collectAndPublish()
+
+		if bus.ListCalls != 0 {
+			t.Errorf("Expected no bus access while a collection is in progress, got %d List calls", bus.ListCalls)
+		}
+	})
+}
+
+func TestController_Endpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	root := writeFakeW1(t, map[string]string{"28-000000000001": w1Good})
+	collector := NewCollector(NewSysfsBus(root), []SensorConfig{{ID: "28-000000000001", Name: "battery-box"}}, &MockMetricsCollector{})
+	controller := newControllerForTest(collector, &testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "")
+
+	router := gin.New()
+	controller.RegisterEndpoints(router)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/onewire/metrics", nil))
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("before first collection: status = %d, want 204", recorder.Code)
+	}
+
+	controller.collectAndPublish()
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/onewire/metrics", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("after collection: status = %d, want 200", recorder.Code)
+	}
+
+	var status Status
+	if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Failed to unmarshal status: %v", err)
+	}
+	if len(status.Sensors) != 1 || status.Sensors[0].Name != "battery-box" {
+		t.Errorf("sensors = %+v, want one battery-box reading", status.Sensors)
+	}
+}
+
+func TestController_Close(t *testing.T) {
+	controller := newControllerForTest(NewCollector(&MockSensorBus{}, nil, &MockMetricsCollector{}),
+		&testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "")
+	controller.scheduler = gocron.NewScheduler(time.UTC)
+	controller.scheduler.StartAsync()
+
+	if err := controller.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if controller.scheduler.IsRunning() {
+		t.Error("Close() left the scheduler running")
+	}
+}
+
+func TestController_Disabled(t *testing.T) {
+	controller, err := NewControllerFromConfig(Configuration{Enabled: false}, &testutil.MockMessagePublisher{}, "")
+	if err != nil {
+		t.Fatalf("NewControllerFromConfig() error = %v", err)
+	}
+	if controller.Enabled() {
+		t.Error("disabled controller reports enabled")
+	}
+
+	if got := controller.Health().Availability; got != health.Unknown {
+		t.Errorf("Health() availability = %s, want unknown", got)
+	}
+	if got := controller.Snapshot(); got != nil {
+		t.Errorf("Snapshot() = %v, want nil", got)
+	}
+
+	router := gin.New()
+	controller.RegisterEndpoints(router)
+	if len(router.Routes()) != 0 {
+		t.Errorf("disabled controller registered %d routes", len(router.Routes()))
+	}
+	if err := controller.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+func TestNewControllerFromConfig_MissingBus(t *testing.T) {
+	config := Configuration{Enabled: true, PublishPeriod: 60, DevicesPath: filepath.Join(t.TempDir(), "w1")}
+	_, err := NewControllerFromConfig(config, &testutil.MockMessagePublisher{}, "")
+	if !errors.Is(err, controllers.ErrDeviceUnavailable) {
+		t.Errorf("NewControllerFromConfig() error = %v, want ErrDeviceUnavailable", err)
+	}
+}
+
+func TestConfiguration_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		sensors []SensorConfig
+		wantErr bool
+	}{
+		{name: "no sensors", sensors: nil},
+		{name: "named sensors", sensors: []SensorConfig{{ID: "28-1", Name: "battery-box"}, {ID: "28-2", Name: "enclosure"}}},
+		{name: "missing id", sensors: []SensorConfig{{Name: "battery-box"}}, wantErr: true},
+		{name: "missing name", sensors: []SensorConfig{{ID: "28-1"}}, wantErr: true},
+		{name: "name with spaces", sensors: []SensorConfig{{ID: "28-1", Name: "battery box"}}, wantErr: true},
+		{name: "name with slash", sensors: []SensorConfig{{ID: "28-1", Name: "battery/box"}}, wantErr: true},
+		{name: "duplicate id", sensors: []SensorConfig{{ID: "28-1", Name: "a"}, {ID: "28-1", Name: "b"}}, wantErr: true},
+		{name: "duplicate name", sensors: []SensorConfig{{ID: "28-1", Name: "a"}, {ID: "28-2", Name: "a"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Configuration{Enabled: true, PublishPeriod: 30, Sensors: tt.sensors}
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestController_NameAndType(t *testing.T) {
+	controller := &Controller{}
+	if controller.Name() != "onewire" || controller.Type() != controllers.TypeTemperatureSensor {
+		t.Errorf("Name(), Type() = %q, %q, want onewire, %q", controller.Name(), controller.Type(), controllers.TypeTemperatureSensor)
+	}
+}
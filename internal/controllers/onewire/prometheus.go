@@ -0,0 +1,66 @@
+package onewire
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type PrometheusCollector struct {
+	failures       prometheus.Counter
+	sensorFailures *prometheus.CounterVec
+
+	temperature *prometheus.GaugeVec
+}
+
+func NewPrometheusCollector() *PrometheusCollector {
+	endpoint := &PrometheusCollector{
+		failures: promauto.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "read_failures",
+			Help:      "Number of collection cycles in which no 1-Wire sensor could be read.",
+		}),
+		sensorFailures: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "sensor_read_failures_total",
+				Help:      "1-Wire sensor read failures by sensor and reason (crc, missing, power-on-reset, read).",
+			},
+			[]string{"sensor", "reason"},
+		),
+	}
+
+	// Initialize all metrics immediately to avoid race conditions
+	endpoint.initializeMetrics()
+
+	return endpoint
+}
+
+func (o *PrometheusCollector) IncrementFailures() {
+	o.failures.Inc()
+}
+
+func (o *PrometheusCollector) IncrementSensorFailure(sensor, reason string) {
+	o.sensorFailures.WithLabelValues(sensor, reason).Inc()
+}
+
+func (o *PrometheusCollector) initializeMetrics() {
+	o.temperature = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "temperature",
+			Help:      "1-Wire sensor temperature (C).",
+		},
+		[]string{"sensor", "id"},
+	)
+}
+
+func (o *PrometheusCollector) SetMetrics(status *Status) {
+	for _, sensor := range status.Sensors {
+		if sensor.Temperature == nil {
+			// Drop the series rather than keep serving the last reading
+			o.temperature.DeleteLabelValues(sensor.Name, sensor.ID)
+			continue
+		}
+		o.temperature.WithLabelValues(sensor.Name, sensor.ID).Set(*sensor.Temperature)
+	}
+}
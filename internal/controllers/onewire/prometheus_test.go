@@ -0,0 +1,48 @@
+package onewire
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Verify PrometheusCollector implements MetricsCollector
+var _ MetricsCollector = (*PrometheusCollector)(nil)
+
+// The collector registers with the global Prometheus registry via promauto,
+// so it can only be created once per test binary.
+func TestPrometheusCollector(t *testing.T) {
+	collector := NewPrometheusCollector()
+
+	temperature := 23.125
+	collector.SetMetrics(&Status{Sensors: []SensorReading{
+		{ID: "28-000000000001", Name: "battery-box", Temperature: &temperature},
+		{ID: "28-000000000002", Name: "enclosure", Temperature: &temperature},
+	}})
+
+	if got := testutil.ToFloat64(collector.temperature.WithLabelValues("battery-box", "28-000000000001")); got != 23.125 {
+		t.Errorf("battery-box temperature = %v, want 23.125", got)
+	}
+	if got := testutil.CollectAndCount(collector.temperature); got != 2 {
+		t.Errorf("temperature series = %d, want 2", got)
+	}
+
+	// A sensor that fails drops its series instead of serving a stale value
+	collector.SetMetrics(&Status{Sensors: []SensorReading{
+		{ID: "28-000000000001", Name: "battery-box", Temperature: &temperature},
+		{ID: "28-000000000002", Name: "enclosure", Error: ErrCRC.Error()},
+	}})
+	if got := testutil.CollectAndCount(collector.temperature); got != 1 {
+		t.Errorf("temperature series after failure = %d, want 1", got)
+	}
+
+	collector.IncrementFailures()
+	collector.IncrementSensorFailure("enclosure", "crc")
+	collector.IncrementSensorFailure("enclosure", "crc")
+	if got := testutil.ToFloat64(collector.failures); got != 1 {
+		t.Errorf("read_failures = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(collector.sensorFailures.WithLabelValues("enclosure", "crc")); got != 2 {
+		t.Errorf("sensor_read_failures_total = %v, want 2", got)
+	}
+}
@@ -0,0 +1,118 @@
+package onewire
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultDevicesPath is where the Linux w1 subsystem exposes bus devices.
+const DefaultDevicesPath = "/sys/bus/w1/devices"
+
+// powerOnResetMilliC is the value a DS18B20 reports before its first
+// conversion completes. It is a valid temperature in range, so it has to be
+// rejected explicitly rather than trusted.
+const powerOnResetMilliC = 85000
+
+// temperatureFamilies are the w1 family codes of the supported thermometers:
+// DS18S20 (10), DS1822 (22), DS18B20 (28), DS1825 (3b) and MAX31850 (42).
+var temperatureFamilies = map[string]bool{
+	"10": true,
+	"22": true,
+	"28": true,
+	"3b": true,
+	"42": true,
+}
+
+var (
+	// ErrCRC is returned when the sensor's scratchpad fails its CRC check,
+	// usually from noise on a long or poorly terminated bus.
+	ErrCRC = errors.New("CRC check failed")
+
+	// ErrSensorMissing is returned when a sensor is not present on the bus.
+	ErrSensorMissing = errors.New("sensor not present on the bus")
+
+	// ErrPowerOnReset is returned for the 85°C power-on reset value.
+	ErrPowerOnReset = errors.New("sensor returned its power-on reset value")
+)
+
+// SysfsBus reads temperature sensors through the w1 sysfs tree. The root
+// is configurable so tests can point it at a fake tree.
+type SysfsBus struct {
+	root string
+}
+
+func NewSysfsBus(root string) *SysfsBus {
+	if root == "" {
+		root = DefaultDevicesPath
+	}
+	return &SysfsBus{root: root}
+}
+
+// List returns the IDs of the temperature sensors currently on the bus, in
+// sorted order. Bus masters and other device families are skipped.
+func (b *SysfsBus) List() ([]string, error) {
+	entries, err := os.ReadDir(b.root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list 1-Wire devices in %s: %w", b.root, err)
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		family, _, ok := strings.Cut(entry.Name(), "-")
+		if ok && temperatureFamilies[strings.ToLower(family)] {
+			ids = append(ids, entry.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Read returns the temperature of one sensor in degrees Celsius.
+func (b *SysfsBus) Read(id string) (float64, error) {
+	path := filepath.Join(b.root, id, "w1_slave")
+	data, err := os.ReadFile(path) //nolint:gosec // path is the configured w1 root plus a sensor ID
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, ErrSensorMissing
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return ParseW1Slave(string(data))
+}
+
+// ParseW1Slave parses the contents of a w1_slave file:
+//
+//	72 01 4b 46 7f ff 0e 10 57 : crc=57 YES
+//	72 01 4b 46 7f ff 0e 10 57 t=23125
+//
+// The first line carries the kernel's CRC verdict and the second the
+// temperature in thousandths of a degree.
+func ParseW1Slave(content string) (float64, error) {
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+	if len(lines) < 2 {
+		return 0, fmt.Errorf("unexpected w1_slave format: %q", content)
+	}
+
+	if !strings.HasSuffix(strings.TrimSpace(lines[0]), "YES") {
+		return 0, ErrCRC
+	}
+
+	_, raw, ok := strings.Cut(lines[1], "t=")
+	if !ok {
+		return 0, fmt.Errorf("no temperature in w1_slave: %q", lines[1])
+	}
+	milliC, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil {
+		return 0, fmt.Errorf("invalid temperature in w1_slave: %w", err)
+	}
+	if milliC == powerOnResetMilliC {
+		return 0, ErrPowerOnReset
+	}
+
+	return float64(milliC) / 1000, nil
+}
@@ -0,0 +1,125 @@
+package onewire
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const (
+	w1Good = "72 01 4b 46 7f ff 0e 10 57 : crc=57 YES\n72 01 4b 46 7f ff 0e 10 57 t=23125\n"
+	w1Bad  = "72 01 4b 46 7f ff 0e 10 57 : crc=00 NO\n72 01 4b 46 7f ff 0e 10 57 t=23125\n"
+	w1POR  = "50 05 4b 46 7f ff 0c 10 1c : crc=1c YES\n50 05 4b 46 7f ff 0c 10 1c t=85000\n"
+)
+
+// writeFakeW1 builds a fake /sys/bus/w1/devices tree. Each key is a device
+// directory; an empty value creates the directory without a w1_slave file.
+func writeFakeW1(t *testing.T, devices map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for id, content := range devices {
+		dir := filepath.Join(root, id)
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if content == "" {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, "w1_slave"), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestParseW1Slave(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    float64
+		wantErr error
+	}{
+		{name: "valid reading", content: w1Good, want: 23.125},
+		{name: "negative reading", content: "ff : crc=aa YES\nff t=-10062\n", want: -10.062},
+		{name: "CRC failure", content: w1Bad, wantErr: ErrCRC},
+		{name: "power-on reset value", content: w1POR, wantErr: ErrPowerOnReset},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseW1Slave(tt.content)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseW1Slave() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseW1Slave() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseW1Slave() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, content := range []string{"", "one line only", "a YES\nno temperature", "a YES\nb t=abc"} {
+		if _, err := ParseW1Slave(content); err == nil {
+			t.Errorf("ParseW1Slave(%q) expected error", content)
+		}
+	}
+}
+
+func TestSysfsBus_List(t *testing.T) {
+	root := writeFakeW1(t, map[string]string{
+		"28-0316a2798aff":     w1Good,
+		"10-000802b4a1c2":     w1Good,
+		"w1_bus_master1":      "",
+		"29-00000001aaaa":     "",
+		"28-0000000000ff-bad": w1Good,
+	})
+
+	ids, err := NewSysfsBus(root).List()
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	want := []string{"10-000802b4a1c2", "28-0000000000ff-bad", "28-0316a2798aff"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("List() = %v, want %v", ids, want)
+	}
+
+	if _, err := NewSysfsBus(filepath.Join(root, "missing")).List(); err == nil {
+		t.Error("List() on a missing root expected error")
+	}
+}
+
+func TestSysfsBus_Read(t *testing.T) {
+	root := writeFakeW1(t, map[string]string{
+		"28-000000000001": w1Good,
+		"28-000000000002": w1Bad,
+		"28-000000000003": "",
+	})
+	bus := NewSysfsBus(root)
+
+	got, err := bus.Read("28-000000000001")
+	if err != nil || got != 23.125 {
+		t.Errorf("Read() = %v, %v; want 23.125, nil", got, err)
+	}
+	if _, err := bus.Read("28-000000000002"); !errors.Is(err, ErrCRC) {
+		t.Errorf("Read() CRC failure error = %v, want ErrCRC", err)
+	}
+	if _, err := bus.Read("28-000000000003"); !errors.Is(err, ErrSensorMissing) {
+		t.Errorf("Read() without w1_slave error = %v, want ErrSensorMissing", err)
+	}
+	if _, err := bus.Read("28-00000000dead"); !errors.Is(err, ErrSensorMissing) {
+		t.Errorf("Read() unknown sensor error = %v, want ErrSensorMissing", err)
+	}
+}
+
+func TestNewSysfsBus_DefaultRoot(t *testing.T) {
+	if got := NewSysfsBus("").root; got != DefaultDevicesPath {
+		t.Errorf("NewSysfsBus(\"\").root = %q, want %q", got, DefaultDevicesPath)
+	}
+}
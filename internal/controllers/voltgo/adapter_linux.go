@@ -0,0 +1,35 @@
+//go:build linux
+
+package voltgo
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// hciDevUp and hciDevDown are the HCIDEVUP and HCIDEVDOWN ioctls from
+// <bluetooth/hci.h>, which power a controller up and down by its index.
+const (
+	hciDevUp   = 0x400448c9
+	hciDevDown = 0x400448ca
+)
+
+// resetAdapter powers the HCI controller with the index down and up again,
+// as hciconfig's reset does. BlueZ sees the adapter return and powers it as
+// before. It needs CAP_NET_ADMIN.
+func resetAdapter(index int) error {
+	fd, err := unix.Socket(unix.AF_BLUETOOTH, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.BTPROTO_HCI)
+	if err != nil {
+		return fmt.Errorf("failed to open HCI socket: %w", err)
+	}
+	defer unix.Close(fd) // nolint:errcheck // Nothing was written to it
+
+	if err := unix.IoctlSetInt(fd, hciDevDown, index); err != nil {
+		return fmt.Errorf("failed to power down hci%d: %w", index, err)
+	}
+	if err := unix.IoctlSetInt(fd, hciDevUp, index); err != nil {
+		return fmt.Errorf("failed to power up hci%d: %w", index, err)
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package voltgo
+
+import "errors"
+
+// resetAdapter is unavailable off Linux; HCI sockets are a Linux kernel
+// interface.
+func resetAdapter(_ int) error {
+	return errors.New("resetting the BLE adapter is only supported on Linux")
+}
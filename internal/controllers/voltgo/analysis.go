@@ -0,0 +1,322 @@
+package voltgo
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// cellHistorySize is how many samples the cell analysis keeps per
+	// battery: a week at a 60s publish period.
+	cellHistorySize = 7 * 24 * 60
+
+	// topOfChargeSOC and bottomOfDischargeSOC bound the ends of the charge
+	// curve, where a weak cell gives itself away: it runs high near full
+	// while charging and low near empty while discharging. In between, a
+	// LiFePO4 curve is too flat for a deviation to say much.
+	topOfChargeSOC       = 90
+	bottomOfDischargeSOC = 20
+
+	// restingCurrent is the current, in amperes either way, below which the
+	// pack is neither charging nor discharging.
+	restingCurrent = 0.5
+
+	// outlierDeviation is how far, in volts, a cell must be from the pack
+	// mean to count as out of line.
+	outlierDeviation = 0.02
+
+	// A cell is flagged when it is out of line in at least outlierShare of
+	// at least minFlagSamples samples at an end of the charge.
+	outlierShare   = 0.8
+	minFlagSamples = 5
+
+	// driftThreshold is the trend of a cell's deviation, in volts per day,
+	// that flags it as drifting, once the history spans minDriftSpan.
+	driftThreshold = 0.005
+	minDriftSpan   = 24 * time.Hour
+)
+
+// The regimes a cell sample can be taken in, by SOC and current
+const (
+	RegimeTopOfCharge       = "topOfCharge"
+	RegimeCharging          = "charging"
+	RegimeResting           = "resting"
+	RegimeDischarging       = "discharging"
+	RegimeBottomOfDischarge = "bottomOfDischarge"
+)
+
+// The flags the cell analysis can raise on a cell
+const (
+	FlagHighAtTopOfCharge      = "high-at-top-of-charge"
+	FlagLowAtBottomOfDischarge = "low-at-bottom-of-discharge"
+	FlagDrifting               = "drifting"
+)
+
+// cellFlags lists every flag, so each one's gauge can be cleared as well as set.
+var cellFlags = []string{FlagHighAtTopOfCharge, FlagLowAtBottomOfDischarge, FlagDrifting}
+
+// CellAnalysis summarises how each cell has tracked the pack mean over the
+// cell history.
+type CellAnalysis struct {
+	// Timestamp is that of the latest sample
+	Timestamp int64 `json:"timestamp"`
+	Samples   int   `json:"samples"`
+
+	// Span is the time from the oldest sample to the latest, in seconds
+	Span float64 `json:"span"`
+
+	// ImbalanceScore is the root mean square deviation of the cells from
+	// the pack mean over every sample, in volts. A balanced pack scores a
+	// few millivolts.
+	ImbalanceScore float64 `json:"imbalanceScore"`
+
+	Cells []CellTrend `json:"cells"`
+}
+
+// CellTrend is one cell's part of the analysis. Deviations are in volts from
+// the mean of the pack's cells at the time, positive above it.
+type CellTrend struct {
+	Index     int     `json:"index"`
+	Deviation float64 `json:"deviation"`
+
+	// Regimes summarises the deviation in each regime that was sampled
+	Regimes map[string]CellRegime `json:"regimes"`
+
+	// Drift is the least-squares trend of the deviation, in volts per day
+	Drift float64  `json:"drift"`
+	Flags []string `json:"flags"`
+}
+
+// CellRegime summarises a cell's deviation over the samples taken in one
+// regime. High and Low are the shares of them in which the cell was more than
+// 20mV above or below the mean.
+type CellRegime struct {
+	Samples       int     `json:"samples"`
+	MeanDeviation float64 `json:"meanDeviation"`
+	High          float64 `json:"high"`
+	Low           float64 `json:"low"`
+}
+
+// cellRegime classifies a sample by the pack's SOC and current.
+func cellRegime(soc int, current float64) string {
+	switch {
+	case current >= restingCurrent && soc >= topOfChargeSOC:
+		return RegimeTopOfCharge
+	case current >= restingCurrent:
+		return RegimeCharging
+	case current <= -restingCurrent && soc <= bottomOfDischargeSOC:
+		return RegimeBottomOfDischarge
+	case current <= -restingCurrent:
+		return RegimeDischarging
+	default:
+		return RegimeResting
+	}
+}
+
+// cellSample is each cell's deviation from the pack mean at one collection,
+// keyed by cell index.
+type cellSample struct {
+	timestamp  int64
+	regime     string
+	deviations map[int]float64
+}
+
+// cellHistory is a ring of the latest cellHistorySize cell samples, with
+// running sums over them that each sample is added to as it comes in and
+// taken from as it drops out, so analysing a week of samples does not mean
+// rescanning them. It is only touched by the collection cycle.
+type cellHistory struct {
+	samples []cellSample
+	next    int
+
+	// origin is the timestamp drift is fitted from: the first sample's
+	origin int64
+
+	// squares and deviations are the sum of every cell's squared deviation
+	// and how many were summed
+	squares    float64
+	deviations int
+
+	cells map[int]*cellSums
+}
+
+// cellSums are one cell's running sums over the samples it is in.
+type cellSums struct {
+	samples int
+	regimes map[string]*regimeSums
+
+	// The sums of a least-squares fit of deviation against time, in days
+	// since the history's origin
+	t, d, tt, td float64
+}
+
+// regimeSums are one cell's running sums over the samples of one regime.
+type regimeSums struct {
+	samples   int
+	deviation float64
+	high, low int
+}
+
+// add records the status's cells. A status without cells adds nothing.
+func (h *cellHistory) add(status *BatteryStatus) {
+	if len(status.Cells) == 0 {
+		return
+	}
+
+	var sum float64
+	for _, cell := range status.Cells {
+		sum += cell.Voltage
+	}
+	mean := sum / float64(len(status.Cells))
+
+	sample := cellSample{
+		timestamp:  status.Timestamp,
+		regime:     cellRegime(status.SOC, status.Current),
+		deviations: make(map[int]float64, len(status.Cells)),
+	}
+	for _, cell := range status.Cells {
+		sample.deviations[cell.Index] = cell.Voltage - mean
+	}
+
+	if len(h.samples) == 0 {
+		h.origin = sample.timestamp
+		h.cells = make(map[int]*cellSums)
+	}
+	h.count(sample, 1)
+
+	if len(h.samples) < cellHistorySize {
+		h.samples = append(h.samples, sample)
+		return
+	}
+	h.count(h.samples[h.next], -1)
+	h.samples[h.next] = sample
+	h.next = (h.next + 1) % cellHistorySize
+}
+
+// count adds a sample to the running sums, or with sign -1 takes it out.
+func (h *cellHistory) count(sample cellSample, sign int) {
+	weight := float64(sign)
+	t := float64(sample.timestamp-h.origin) / (24 * 60 * 60)
+
+	for index, deviation := range sample.deviations {
+		h.squares += weight * deviation * deviation
+		h.deviations += sign
+
+		cell := h.cells[index]
+		if cell == nil {
+			cell = &cellSums{regimes: make(map[string]*regimeSums)}
+			h.cells[index] = cell
+		}
+		cell.samples += sign
+		cell.t += weight * t
+		cell.d += weight * deviation
+		cell.tt += weight * t * t
+		cell.td += weight * t * deviation
+
+		regime := cell.regimes[sample.regime]
+		if regime == nil {
+			regime = &regimeSums{}
+			cell.regimes[sample.regime] = regime
+		}
+		regime.samples += sign
+		regime.deviation += weight * deviation
+		if deviation > outlierDeviation {
+			regime.high += sign
+		}
+		if deviation < -outlierDeviation {
+			regime.low += sign
+		}
+
+		if regime.samples == 0 {
+			delete(cell.regimes, sample.regime)
+		}
+		if cell.samples == 0 {
+			delete(h.cells, index)
+		}
+	}
+}
+
+// ordered returns the samples oldest first.
+func (h *cellHistory) ordered() []cellSample {
+	return append(append([]cellSample{}, h.samples[h.next:]...), h.samples[:h.next]...)
+}
+
+// analyze summarises the history, or returns nil while it is empty. It reads
+// the running sums, so it costs the same however many samples there are.
+func (h *cellHistory) analyze() *CellAnalysis {
+	if len(h.samples) == 0 {
+		return nil
+	}
+
+	first := h.samples[h.next%len(h.samples)]
+	latest := h.samples[(h.next+len(h.samples)-1)%len(h.samples)]
+	analysis := &CellAnalysis{
+		Timestamp:      latest.timestamp,
+		Samples:        len(h.samples),
+		Span:           float64(latest.timestamp - first.timestamp),
+		ImbalanceScore: math.Sqrt(max(h.squares, 0) / float64(h.deviations)),
+		Cells:          []CellTrend{},
+	}
+
+	indices := make([]int, 0, len(latest.deviations))
+	for index := range latest.deviations {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+
+	span := time.Duration(analysis.Span) * time.Second
+	for _, index := range indices {
+		analysis.Cells = append(analysis.Cells, h.cellTrend(index, latest.deviations[index], span))
+	}
+	return analysis
+}
+
+// cellTrend analyses one cell, whose latest deviation is given, over
+// samples that span the given time.
+func (h *cellHistory) cellTrend(index int, deviation float64, span time.Duration) CellTrend {
+	cell := h.cells[index]
+	trend := CellTrend{
+		Index:     index,
+		Deviation: deviation,
+		Regimes:   make(map[string]CellRegime, len(cell.regimes)),
+		Flags:     []string{},
+	}
+
+	for name, regime := range cell.regimes {
+		samples := float64(regime.samples)
+		trend.Regimes[name] = CellRegime{
+			Samples:       regime.samples,
+			MeanDeviation: regime.deviation / samples,
+			High:          float64(regime.high) / samples,
+			Low:           float64(regime.low) / samples,
+		}
+	}
+
+	n := float64(cell.samples)
+	if denominator := n*cell.tt - cell.t*cell.t; cell.samples > 1 && denominator > 0 {
+		trend.Drift = (n*cell.td - cell.t*cell.d) / denominator
+	}
+
+	if top := trend.Regimes[RegimeTopOfCharge]; top.Samples >= minFlagSamples && top.High >= outlierShare {
+		trend.Flags = append(trend.Flags, FlagHighAtTopOfCharge)
+	}
+	if bottom := trend.Regimes[RegimeBottomOfDischarge]; bottom.Samples >= minFlagSamples && bottom.Low >= outlierShare {
+		trend.Flags = append(trend.Flags, FlagLowAtBottomOfDischarge)
+	}
+	if span >= minDriftSpan && math.Abs(trend.Drift) >= driftThreshold {
+		trend.Flags = append(trend.Flags, FlagDrifting)
+	}
+	return trend
+}
+
+// flaggedCells counts the cells with at least one flag.
+func (a *CellAnalysis) flaggedCells() int {
+	flagged := 0
+	for _, cell := range a.Cells {
+		if len(cell.Flags) > 0 {
+			flagged++
+		}
+	}
+	return flagged
+}
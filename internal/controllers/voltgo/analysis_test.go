@@ -0,0 +1,252 @@
+package voltgo
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// analysisStatus is a four-cell status with cell 2 offset from the others.
+func analysisStatus(timestamp int64, soc int, current, offset float64) *BatteryStatus {
+	return &BatteryStatus{
+		Timestamp: timestamp,
+		SOC:       soc,
+		Current:   current,
+		Cells: []Cell{
+			{Index: 0, Voltage: 3.300},
+			{Index: 1, Voltage: 3.300},
+			{Index: 2, Voltage: 3.300 + offset},
+			{Index: 3, Voltage: 3.300},
+		},
+	}
+}
+
+func TestCellRegime(t *testing.T) {
+	tests := []struct {
+		soc     int
+		current float64
+		want    string
+	}{
+		{95, 10, RegimeTopOfCharge},
+		{90, 0.5, RegimeTopOfCharge},
+		{60, 10, RegimeCharging},
+		{95, 0.2, RegimeResting},
+		{10, -0.2, RegimeResting},
+		{60, -10, RegimeDischarging},
+		{20, -0.5, RegimeBottomOfDischarge},
+		{95, -10, RegimeDischarging},
+		{10, 10, RegimeCharging},
+	}
+
+	for _, tt := range tests {
+		if got := cellRegime(tt.soc, tt.current); got != tt.want {
+			t.Errorf("cellRegime(%d, %v) = %s, want %s", tt.soc, tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestCellHistory(t *testing.T) {
+	t.Run("empty history has no analysis", func(t *testing.T) {
+		var history cellHistory
+		history.add(&BatteryStatus{Timestamp: 1})
+		if analysis := history.analyze(); analysis != nil {
+			t.Errorf("analyze() = %+v, want nil without cells", analysis)
+		}
+	})
+
+	t.Run("keeps only the latest samples", func(t *testing.T) {
+		var history cellHistory
+		for i := range cellHistorySize + 10 {
+			history.add(analysisStatus(int64(i), 50, 0, 0))
+		}
+
+		samples := history.ordered()
+		if len(samples) != cellHistorySize {
+			t.Fatalf("samples = %d, want %d", len(samples), cellHistorySize)
+		}
+		if samples[0].timestamp != 10 || samples[len(samples)-1].timestamp != cellHistorySize+9 {
+			t.Errorf("samples run %d to %d, want 10 to %d", samples[0].timestamp, samples[len(samples)-1].timestamp, cellHistorySize+9)
+		}
+	})
+
+	t.Run("the running sums follow the samples kept", func(t *testing.T) {
+		// A cell drifting up through every regime, a sample a minute
+		status := func(i int) *BatteryStatus {
+			soc := []int{95, 60, 50, 40, 10}[i%5]
+			current := []float64{10, 10, 0, -10, -10}[i%5]
+			return analysisStatus(1699000000+int64(i)*60, soc, current, float64(i)/cellHistorySize*0.05)
+		}
+
+		var running, fresh cellHistory
+		for i := range cellHistorySize + 500 {
+			running.add(status(i))
+			if i >= 500 {
+				fresh.add(status(i))
+			}
+		}
+
+		got, want := running.analyze(), fresh.analyze()
+		if got.Samples != want.Samples || got.Span != want.Span || math.Abs(got.ImbalanceScore-want.ImbalanceScore) > 1e-9 {
+			t.Errorf("analysis = %d samples over %vs scoring %v, want %d over %vs scoring %v",
+				got.Samples, got.Span, got.ImbalanceScore, want.Samples, want.Span, want.ImbalanceScore)
+		}
+		for i, cell := range got.Cells {
+			expected := want.Cells[i]
+			if math.Abs(cell.Drift-expected.Drift) > 1e-9 || !slices.Equal(cell.Flags, expected.Flags) {
+				t.Errorf("cell %d drifts %v flagged %v, want %v flagged %v", i, cell.Drift, cell.Flags, expected.Drift, expected.Flags)
+			}
+			for name, regime := range expected.Regimes {
+				if r := cell.Regimes[name]; r.Samples != regime.Samples || math.Abs(r.MeanDeviation-regime.MeanDeviation) > 1e-9 ||
+					r.High != regime.High || r.Low != regime.Low {
+					t.Errorf("cell %d %s = %+v, want %+v", i, name, r, regime)
+				}
+			}
+			if len(cell.Regimes) != len(expected.Regimes) {
+				t.Errorf("cell %d regimes = %d, want %d", i, len(cell.Regimes), len(expected.Regimes))
+			}
+		}
+	})
+
+	t.Run("deviations are from the pack mean", func(t *testing.T) {
+		var history cellHistory
+		history.add(analysisStatus(100, 50, 0, 0.040))
+
+		analysis := history.analyze()
+		if analysis.Samples != 1 || analysis.Timestamp != 100 || analysis.Span != 0 {
+			t.Errorf("analysis = %d samples at %d over %vs, want 1 at 100 over 0s", analysis.Samples, analysis.Timestamp, analysis.Span)
+		}
+		if len(analysis.Cells) != 4 {
+			t.Fatalf("cells = %d, want 4", len(analysis.Cells))
+		}
+
+		// The mean is 3.310, so cell 2 is 30mV above it and the rest 10mV below
+		if got := analysis.Cells[2].Deviation; math.Abs(got-0.030) > 1e-9 {
+			t.Errorf("cell 2 deviation = %v, want 0.030", got)
+		}
+		if got := analysis.Cells[0].Deviation; math.Abs(got+0.010) > 1e-9 {
+			t.Errorf("cell 0 deviation = %v, want -0.010", got)
+		}
+		wantScore := math.Sqrt((0.030*0.030 + 3*0.010*0.010) / 4)
+		if math.Abs(analysis.ImbalanceScore-wantScore) > 1e-9 {
+			t.Errorf("imbalance score = %v, want %v", analysis.ImbalanceScore, wantScore)
+		}
+
+		regime := analysis.Cells[2].Regimes[RegimeResting]
+		if regime.Samples != 1 || regime.High != 1 || regime.Low != 0 {
+			t.Errorf("cell 2 resting = %+v, want 1 sample, all high", regime)
+		}
+	})
+}
+
+func TestCellTrendFlags(t *testing.T) {
+	t.Run("a cell that runs high at top of charge", func(t *testing.T) {
+		var history cellHistory
+		for i := range minFlagSamples {
+			history.add(analysisStatus(int64(i*60), 95, 10, 0.060))
+		}
+		// Balanced mid-charge samples do not dilute the top of charge
+		for i := range 20 {
+			history.add(analysisStatus(int64(1000+i*60), 50, -10, 0))
+		}
+
+		analysis := history.analyze()
+		if got := analysis.Cells[2].Flags; !slices.Equal(got, []string{FlagHighAtTopOfCharge}) {
+			t.Errorf("cell 2 flags = %v, want [%s]", got, FlagHighAtTopOfCharge)
+		}
+		if got := analysis.Cells[0].Flags; len(got) != 0 {
+			t.Errorf("cell 0 flags = %v, want none", got)
+		}
+		if flagged := analysis.flaggedCells(); flagged != 1 {
+			t.Errorf("flagged cells = %d, want 1", flagged)
+		}
+	})
+
+	t.Run("a cell that runs low at bottom of discharge", func(t *testing.T) {
+		var history cellHistory
+		for i := range minFlagSamples {
+			history.add(analysisStatus(int64(i*60), 15, -10, -0.060))
+		}
+
+		analysis := history.analyze()
+		if got := analysis.Cells[2].Flags; !slices.Equal(got, []string{FlagLowAtBottomOfDischarge}) {
+			t.Errorf("cell 2 flags = %v, want [%s]", got, FlagLowAtBottomOfDischarge)
+		}
+	})
+
+	t.Run("too few samples flag nothing", func(t *testing.T) {
+		var history cellHistory
+		for i := range minFlagSamples - 1 {
+			history.add(analysisStatus(int64(i*60), 95, 10, 0.060))
+		}
+
+		if got := history.analyze().Cells[2].Flags; len(got) != 0 {
+			t.Errorf("cell 2 flags = %v, want none", got)
+		}
+	})
+
+	t.Run("a cell drifting away from the others", func(t *testing.T) {
+		var history cellHistory
+		// Two days, one sample an hour, cell 2 losing 8mV a day relative to
+		// a mean it is a quarter of
+		for hour := range 48 {
+			offset := -0.008 * float64(hour) / 24 * 4 / 3
+			history.add(analysisStatus(int64(hour*3600), 50, 0, offset))
+		}
+
+		analysis := history.analyze()
+		cell := analysis.Cells[2]
+		if math.Abs(cell.Drift+0.008) > 1e-6 {
+			t.Errorf("cell 2 drift = %v, want -0.008", cell.Drift)
+		}
+		if !slices.Contains(cell.Flags, FlagDrifting) {
+			t.Errorf("cell 2 flags = %v, want %s", cell.Flags, FlagDrifting)
+		}
+		if slices.Contains(analysis.Cells[0].Flags, FlagDrifting) {
+			t.Errorf("cell 0 flags = %v, should not be drifting", analysis.Cells[0].Flags)
+		}
+	})
+
+	t.Run("drift over a short history is not flagged", func(t *testing.T) {
+		var history cellHistory
+		for hour := range 6 {
+			history.add(analysisStatus(int64(hour*3600), 50, 0, -0.010*float64(hour)))
+		}
+
+		cell := history.analyze().Cells[2]
+		if cell.Drift >= 0 {
+			t.Errorf("cell 2 drift = %v, want negative", cell.Drift)
+		}
+		if slices.Contains(cell.Flags, FlagDrifting) {
+			t.Errorf("cell 2 flags = %v, want no drifting before %s", cell.Flags, minDriftSpan)
+		}
+	})
+}
+
+func TestConvertCellAnalysisToMetrics(t *testing.T) {
+	analysis := &CellAnalysis{
+		Timestamp:      1699000000,
+		ImbalanceScore: 0.012,
+		Cells: []CellTrend{
+			{Index: 0, Flags: []string{}},
+			{Index: 1, Flags: []string{FlagDrifting}},
+		},
+	}
+
+	metrics := ConvertCellAnalysisToMetrics(analysis)
+	if len(metrics) != 2 {
+		t.Fatalf("metrics length = %d, want 2", len(metrics))
+	}
+	if metrics[0].Name != "cell-imbalance-score" || metrics[0].Value != 0.012 || metrics[0].Unit != "volts" {
+		t.Errorf("metrics[0] = %s %v %s, want cell-imbalance-score 0.012 volts", metrics[0].Name, metrics[0].Value, metrics[0].Unit)
+	}
+	if metrics[1].Name != "cells-flagged" || metrics[1].Value != 1 || metrics[1].Unit != "count" {
+		t.Errorf("metrics[1] = %s %v %s, want cells-flagged 1 count", metrics[1].Name, metrics[1].Value, metrics[1].Unit)
+	}
+	if metrics[0].Timestamp != analysis.Timestamp {
+		t.Errorf("Timestamp = %d, want %d", metrics[0].Timestamp, analysis.Timestamp)
+	}
+
+	if got := ConvertCellAnalysisToMetrics(nil); len(got) != 0 {
+		t.Errorf("nil analysis metrics length = %d, want 0", len(got))
+	}
+}
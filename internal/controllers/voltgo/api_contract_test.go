@@ -2,25 +2,39 @@ package voltgo
 
 import (
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/gin-gonic/gin"
+	"github.com/lumberbarons/solar-controller/internal/estimator"
 	"github.com/lumberbarons/solar-controller/internal/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-// MetricsGet and InfoGet serialise the collector structs directly, so their
-// json tags are the wire contract. These tests pin those tags against
+// MetricsGet, InfoGet, BankGet, CellAnalysisGet, EnergyGet, HealthReportGet and ScanGet serialise the collector structs, so their json tags
+// are the wire contract. These tests pin those tags against
 // docs/api-contract.json, which site/src/api/types.ts is checked against from
 // the other side, so a rename cannot reach the browser as `undefined`.
+//
+// MetricsGet adds the health object alongside the status fields, so it is
+// driven through the handler.
 func TestStatusPayloadMatchesAPIContract(t *testing.T) {
-	payload, err := json.Marshal(BatteryStatus{})
-	require.NoError(t, err)
+	gin.SetMode(gin.TestMode)
+	controller := newControllerForTest(&Collector{}, &testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "test-device-1")
+	controller.batteries[0].status = &BatteryStatus{}
+
+	router := gin.New()
+	router.GET("/api/voltgo/metrics", controller.MetricsGet())
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/voltgo/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
 
 	assert.Equal(t,
 		testutil.APIContractFields(t, "GET /api/voltgo/metrics"),
-		testutil.JSONFieldNames(t, payload),
-		"BatteryStatus no longer marshals to the fields docs/api-contract.json "+
+		testutil.JSONFieldNames(t, recorder.Body.Bytes()),
+		"GET /api/voltgo/metrics no longer returns the fields docs/api-contract.json "+
 			"records; update the contract and site/src/api/types.ts together")
 }
 
@@ -37,6 +51,18 @@ func TestCellPayloadMatchesAPIContract(t *testing.T) {
 			"update the contract and site/src/api/types.ts together")
 }
 
+// The bank's runtime estimate is the same type as the battery's.
+func TestRuntimePayloadMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(estimator.Estimate{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/voltgo/metrics#runtime"),
+		testutil.JSONFieldNames(t, payload),
+		"estimator.Estimate no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
+
 func TestInfoPayloadMatchesAPIContract(t *testing.T) {
 	payload, err := json.Marshal(BatteryInfo{})
 	require.NoError(t, err)
@@ -47,3 +73,125 @@ func TestInfoPayloadMatchesAPIContract(t *testing.T) {
 		"BatteryInfo no longer marshals to the fields docs/api-contract.json "+
 			"records; update the contract and site/src/api/types.ts together")
 }
+
+func TestBankPayloadMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(BankStatus{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/voltgo/bank"),
+		testutil.JSONFieldNames(t, payload),
+		"BankStatus no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
+
+func TestBankBatteryPayloadMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(BankBattery{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/voltgo/bank#batteries[]"),
+		testutil.JSONFieldNames(t, payload),
+		"BankBattery no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
+
+func TestScanPayloadMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(ScanResponse{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/voltgo/scan"),
+		testutil.JSONFieldNames(t, payload),
+		"ScanResponse no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
+
+func TestScanDevicePayloadMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(Device{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/voltgo/scan#devices[]"),
+		testutil.JSONFieldNames(t, payload),
+		"Device no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
+
+func TestCellAnalysisPayloadMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(CellAnalysis{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/voltgo/cells/analysis"),
+		testutil.JSONFieldNames(t, payload),
+		"CellAnalysis no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
+
+func TestCellTrendPayloadMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(CellTrend{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/voltgo/cells/analysis#cells[]"),
+		testutil.JSONFieldNames(t, payload),
+		"CellTrend no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
+
+func TestEnergyPayloadMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(EnergyStatus{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/voltgo/energy"),
+		testutil.JSONFieldNames(t, payload),
+		"EnergyStatus no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
+
+// daily and monthly share the lifetime object's type.
+func TestEnergyTotalsPayloadMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(EnergyTotals{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/voltgo/energy#lifetime"),
+		testutil.JSONFieldNames(t, payload),
+		"EnergyTotals no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
+
+func TestHealthReportPayloadMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(HealthReport{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/voltgo/health-report"),
+		testutil.JSONFieldNames(t, payload),
+		"HealthReport no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
+
+func TestSOHPointPayloadMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(SOHPoint{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/voltgo/health-report#sohHistory[]"),
+		testutil.JSONFieldNames(t, payload),
+		"SOHPoint no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
+
+func TestCapacityEstimatePayloadMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(CapacityEstimate{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/voltgo/health-report#capacityEstimates[]"),
+		testutil.JSONFieldNames(t, payload),
+		"CapacityEstimate no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
@@ -0,0 +1,123 @@
+package voltgo
+
+import "github.com/lumberbarons/solar-controller/internal/estimator"
+
+// BankStatus aggregates the batteries read in the last collection cycle.
+type BankStatus struct {
+	Timestamp int64 `json:"timestamp"`
+
+	// Current is the sum of the pack currents, positive when charging
+	Current float64 `json:"current"`
+	Power   float64 `json:"power"`
+
+	// SOC is weighted by each pack's energy capacity (nominal voltage times
+	// capacity) once the info of every pack read this cycle is known, and a
+	// plain average until then
+	SOC float64 `json:"soc"`
+
+	// The lowest and highest cell across all packs, and the pack it is in
+	MinCellVoltage float64 `json:"minCellVoltage"`
+	MinCellBattery string  `json:"minCellBattery"`
+	MaxCellVoltage float64 `json:"maxCellVoltage"`
+	MaxCellBattery string  `json:"maxCellBattery"`
+
+	// VoltageImbalance is the spread between the highest and lowest pack
+	// voltage
+	VoltageImbalance float64 `json:"voltageImbalance"`
+
+	// Batteries are the packs read this cycle; Missing names the ones that
+	// could not be
+	Batteries []BankBattery `json:"batteries"`
+	Missing   []string      `json:"missing"`
+
+	// Runtime is estimated from the bank current and SOC against the
+	// capacity of all packs read this cycle
+	Runtime *estimator.Estimate `json:"runtime"`
+}
+
+// BankBattery is one pack's contribution to the bank.
+type BankBattery struct {
+	Name    string  `json:"name"`
+	Voltage float64 `json:"voltage"`
+	Current float64 `json:"current"`
+	SOC     int     `json:"soc"`
+}
+
+// newBankStatus aggregates the batteries collected this cycle. Every battery
+// not among them is listed as missing. Callers must hold the controller's
+// stateMutex.
+func newBankStatus(collected, all []*batteryState) *BankStatus {
+	bank := &BankStatus{
+		Batteries: make([]BankBattery, 0, len(collected)),
+		Missing:   []string{},
+	}
+
+	read := make(map[*batteryState]bool, len(collected))
+	for _, b := range collected {
+		read[b] = true
+	}
+	for _, b := range all {
+		if !read[b] {
+			bank.Missing = append(bank.Missing, b.name)
+		}
+	}
+	if len(collected) == 0 {
+		return bank
+	}
+
+	weights := energyWeights(collected)
+	var totalWeight, weightedSOC float64
+	minVoltage, maxVoltage := collected[0].status.Voltage, collected[0].status.Voltage
+	haveCell := false
+
+	for i, b := range collected {
+		status := b.status
+		bank.Timestamp = max(bank.Timestamp, status.Timestamp)
+		bank.Current += status.Current
+		bank.Power += status.Voltage * status.Current
+		bank.Batteries = append(bank.Batteries, BankBattery{
+			Name:    b.name,
+			Voltage: status.Voltage,
+			Current: status.Current,
+			SOC:     status.SOC,
+		})
+
+		totalWeight += weights[i]
+		weightedSOC += weights[i] * float64(status.SOC)
+
+		minVoltage = min(minVoltage, status.Voltage)
+		maxVoltage = max(maxVoltage, status.Voltage)
+
+		for _, cell := range status.Cells {
+			if !haveCell || cell.Voltage < bank.MinCellVoltage {
+				bank.MinCellVoltage, bank.MinCellBattery = cell.Voltage, b.name
+			}
+			if !haveCell || cell.Voltage > bank.MaxCellVoltage {
+				bank.MaxCellVoltage, bank.MaxCellBattery = cell.Voltage, b.name
+			}
+			haveCell = true
+		}
+	}
+
+	bank.SOC = weightedSOC / totalWeight
+	bank.VoltageImbalance = maxVoltage - minVoltage
+	return bank
+}
+
+// energyWeights returns each battery's energy capacity in watt-hours, or
+// equal weights if any of them has no info with a capacity yet: weighting
+// some packs by capacity and guessing the rest would skew the SOC more than
+// a plain average does.
+func energyWeights(batteries []*batteryState) []float64 {
+	weights := make([]float64, len(batteries))
+	for i, b := range batteries {
+		if b.info == nil || b.info.NominalVoltage <= 0 || b.info.CapacityAh <= 0 {
+			for j := range weights {
+				weights[j] = 1
+			}
+			return weights
+		}
+		weights[i] = b.info.NominalVoltage * b.info.CapacityAh
+	}
+	return weights
+}
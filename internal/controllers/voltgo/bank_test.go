@@ -0,0 +1,78 @@
+package voltgo
+
+import (
+	"math"
+	"testing"
+)
+
+func bankBattery(name string, status *BatteryStatus, info *BatteryInfo) *batteryState {
+	return &batteryState{name: name, status: status, info: info}
+}
+
+func TestNewBankStatus(t *testing.T) {
+	a := bankBattery("house-a", &BatteryStatus{
+		Timestamp: 1699000000,
+		Voltage:   13.30,
+		Current:   5,
+		SOC:       90,
+		Cells:     []Cell{{Index: 0, Voltage: 3.320}, {Index: 1, Voltage: 3.342}},
+	}, &BatteryInfo{NominalVoltage: 12.8, CapacityAh: 300})
+	b := bankBattery("house-b", &BatteryStatus{
+		Timestamp: 1699000002,
+		Voltage:   13.26,
+		Current:   -2,
+		SOC:       50,
+		Cells:     []Cell{{Index: 0, Voltage: 3.301}, {Index: 1, Voltage: 3.315}},
+	}, &BatteryInfo{NominalVoltage: 12.8, CapacityAh: 100})
+	c := bankBattery("house-c", nil, nil)
+
+	bank := newBankStatus([]*batteryState{a, b}, []*batteryState{a, b, c})
+
+	if bank.Timestamp != 1699000002 {
+		t.Errorf("Timestamp = %d, want the latest pack's", bank.Timestamp)
+	}
+	if bank.Current != 3 {
+		t.Errorf("Current = %v, want 3", bank.Current)
+	}
+	if want := 13.30*5 + 13.26*-2; math.Abs(bank.Power-want) > 1e-9 {
+		t.Errorf("Power = %v, want %v", bank.Power, want)
+	}
+	// 300Ah at 90% and 100Ah at 50%
+	if bank.SOC != 80 {
+		t.Errorf("SOC = %v, want 80 (energy weighted)", bank.SOC)
+	}
+	if bank.MinCellVoltage != 3.301 || bank.MinCellBattery != "house-b" {
+		t.Errorf("min cell = %v in %q, want 3.301 in house-b", bank.MinCellVoltage, bank.MinCellBattery)
+	}
+	if bank.MaxCellVoltage != 3.342 || bank.MaxCellBattery != "house-a" {
+		t.Errorf("max cell = %v in %q, want 3.342 in house-a", bank.MaxCellVoltage, bank.MaxCellBattery)
+	}
+	if math.Abs(bank.VoltageImbalance-0.04) > 1e-9 {
+		t.Errorf("VoltageImbalance = %v, want 0.04", bank.VoltageImbalance)
+	}
+	if len(bank.Batteries) != 2 || bank.Batteries[1].Name != "house-b" || bank.Batteries[1].SOC != 50 {
+		t.Errorf("Batteries = %+v, want house-a and house-b", bank.Batteries)
+	}
+	if len(bank.Missing) != 1 || bank.Missing[0] != "house-c" {
+		t.Errorf("Missing = %v, want [house-c]", bank.Missing)
+	}
+}
+
+func TestNewBankStatus_AveragesSOCUntilEveryCapacityIsKnown(t *testing.T) {
+	a := bankBattery("house-a", &BatteryStatus{SOC: 90}, &BatteryInfo{NominalVoltage: 12.8, CapacityAh: 300})
+	b := bankBattery("house-b", &BatteryStatus{SOC: 50}, nil)
+
+	bank := newBankStatus([]*batteryState{a, b}, []*batteryState{a, b})
+	if bank.SOC != 70 {
+		t.Errorf("SOC = %v, want the plain average 70", bank.SOC)
+	}
+}
+
+func TestNewBankStatus_NothingCollected(t *testing.T) {
+	a := bankBattery("house-a", nil, nil)
+
+	bank := newBankStatus(nil, []*batteryState{a})
+	if len(bank.Batteries) != 0 || len(bank.Missing) != 1 {
+		t.Errorf("bank = %+v, want no batteries and house-a missing", bank)
+	}
+}
@@ -2,9 +2,13 @@ package voltgo
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 
+	"github.com/lumberbarons/solar-controller/internal/controllers"
 	voltgolib "github.com/lumberbarons/voltgo"
+	"tinygo.org/x/bluetooth"
 )
 
 // BLEConnector is the production BatteryConnector backed by the voltgo
@@ -13,13 +17,16 @@ type BLEConnector struct {
 	client *voltgolib.Client
 }
 
-// Verify BLEConnector implements BatteryConnector
-var _ BatteryConnector = (*BLEConnector)(nil)
+// Verify BLEConnector implements BatteryConnector and AdapterResetter
+var (
+	_ BatteryConnector = (*BLEConnector)(nil)
+	_ AdapterResetter  = (*BLEConnector)(nil)
+)
 
 func NewBLEConnector() (*BLEConnector, error) {
 	client, err := voltgolib.NewClient()
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize BLE client: %w", err)
+		return nil, fmt.Errorf("failed to initialize BLE client: %w: %w", controllers.ErrDeviceUnavailable, err)
 	}
 	return &BLEConnector{client: client}, nil
 }
@@ -32,6 +39,128 @@ func (c *BLEConnector) Connect(ctx context.Context, address string) (BatteryClie
 	return bat, nil
 }
 
+// Scan listens on the adapter the voltgo client uses. Enabling an adapter
+// that is already enabled is harmless.
+func (c *BLEConnector) Scan(ctx context.Context) ([]Device, error) {
+	adapter := bluetooth.DefaultAdapter
+	if err := adapter.Enable(); err != nil {
+		return nil, fmt.Errorf("failed to enable BLE adapter: %w", err)
+	}
+
+	return collectAdvertisements(ctx, func(heard func(Device)) error {
+		return adapter.Scan(func(_ *bluetooth.Adapter, result bluetooth.ScanResult) {
+			heard(Device{Address: result.Address.String(), Name: result.LocalName(), RSSI: int(result.RSSI)})
+		})
+	}, adapter.StopScan)
+}
+
+// collectAdvertisements runs scan, which blocks until stop is called, for as
+// long as ctx lasts. Each address is kept once, with its latest signal
+// strength and the last name it advertised: not every advertisement carries
+// the name.
+func collectAdvertisements(ctx context.Context, scan func(heard func(Device)) error, stop func() error) ([]Device, error) {
+	var mu sync.Mutex
+	devices := map[string]Device{}
+	done := make(chan error, 1)
+	go func() {
+		done <- scan(func(advertised Device) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			device := devices[advertised.Address]
+			device.Address = advertised.Address
+			device.RSSI = advertised.RSSI
+			if advertised.Name != "" {
+				device.Name = advertised.Name
+			}
+			devices[advertised.Address] = device
+		})
+	}()
+
+	select {
+	case err := <-done:
+		return nil, fmt.Errorf("BLE scan stopped early: %w", err)
+	case <-ctx.Done():
+	}
+
+	if err := stop(); err != nil {
+		return nil, fmt.Errorf("failed to stop BLE scan: %w", err)
+	}
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("BLE scan failed: %w", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	heard := make([]Device, 0, len(devices))
+	for _, device := range devices {
+		heard = append(heard, device)
+	}
+	return heard, nil
+}
+
+// ResetAdapter resets hci0, the adapter the voltgo client uses.
+func (c *BLEConnector) ResetAdapter() error {
+	return resetAdapter(0)
+}
+
 func (c *BLEConnector) Close() error {
 	return c.client.Close()
 }
+
+// sharedConnector lets several collectors use one BLE adapter. Connects are
+// serialised, and the adapter is released when the last collector closes.
+type sharedConnector struct {
+	connector BatteryConnector
+
+	mu    sync.Mutex
+	users int
+}
+
+// Verify sharedConnector implements BatteryConnector and AdapterResetter
+var (
+	_ BatteryConnector = (*sharedConnector)(nil)
+	_ AdapterResetter  = (*sharedConnector)(nil)
+)
+
+func shareConnector(connector BatteryConnector, users int) *sharedConnector {
+	return &sharedConnector{connector: connector, users: users}
+}
+
+func (s *sharedConnector) Connect(ctx context.Context, address string) (BatteryClient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.connector.Connect(ctx, address)
+}
+
+func (s *sharedConnector) Scan(ctx context.Context) ([]Device, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.connector.Scan(ctx)
+}
+
+// ResetAdapter resets the adapter, if the connector can, for every
+// collector sharing it.
+func (s *sharedConnector) ResetAdapter() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resetter, ok := s.connector.(AdapterResetter)
+	if !ok {
+		return errors.New("BLE connector cannot reset its adapter")
+	}
+	return resetter.ResetAdapter()
+}
+
+func (s *sharedConnector) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users--
+	if s.users > 0 {
+		return nil
+	}
+	return s.connector.Close()
+}
@@ -3,22 +3,115 @@ package voltgo
 import (
 	"context"
 	"fmt"
+	"math/rand/v2"
 	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// The connection modes
+const (
+	// ConnectionPersistent holds the connection open between cycles
+	ConnectionPersistent = "persistent"
+	// ConnectionPerCycle connects for each cycle and disconnects after it
+	ConnectionPerCycle = "per-cycle"
+	// ConnectionIdle disconnects once the connection has been unused for
+	// the idle timeout
+	ConnectionIdle = "idle"
+)
+
+const (
+	defaultIdleTimeout = 30 * time.Second
+	defaultMaxBackoff  = 5 * time.Minute
+
+	// minConnectBackoff is the wait after the first failed connect; each
+	// failure after doubles it, up to the policy's MaxBackoff.
+	minConnectBackoff = 10 * time.Second
+)
+
+// ConnectionPolicy is how a Collector manages its BLE connection. Zero
+// fields take their defaults.
+type ConnectionPolicy struct {
+	// Mode is ConnectionPersistent (default), ConnectionPerCycle or
+	// ConnectionIdle
+	Mode string
+
+	// Timeout bounds each connect (default 30s)
+	Timeout time.Duration
+
+	// IdleTimeout is how long an unused connection is held in
+	// ConnectionIdle mode (default 30s)
+	IdleTimeout time.Duration
+
+	// MaxBackoff caps the wait between failed connects (default 5m)
+	MaxBackoff time.Duration
+
+	// ResetAdapterAfter resets the BLE adapter after that many failed
+	// connects in a row, and again after as many more; 0 never does
+	ResetAdapterAfter int
+}
+
+// withDefaults fills in the zero fields.
+func (p ConnectionPolicy) withDefaults() ConnectionPolicy {
+	if p.Mode == "" {
+		p.Mode = ConnectionPersistent
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = defaultConnectTimeout
+	}
+	if p.IdleTimeout <= 0 {
+		p.IdleTimeout = defaultIdleTimeout
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaultMaxBackoff
+	}
+	return p
+}
+
+// ConnectionStats describes a Collector's connection.
+type ConnectionStats struct {
+	Connected bool
+
+	// Connects counts the connections made, and Reconnects those after the
+	// first
+	Connects   int
+	Reconnects int
+
+	// ConnectLatency is how long the last successful connect took
+	ConnectLatency time.Duration
+
+	ConsecutiveFailures int
+	AdapterResets       int
+
+	// NextAttempt is when a connect is next allowed; zero when it is now
+	NextAttempt time.Time
+}
+
 // Collector owns the BLE connection lifecycle: it connects lazily on first
-// collection, reuses the connection while it stays alive, and drops it on
-// read errors so the next cycle reconnects.
+// collection and drops the connection on read errors so the next cycle
+// reconnects. Between cycles it holds the connection open or closes it, as
+// its ConnectionPolicy says. Failed connects are retried with exponential
+// backoff, so an absent battery is not hammered every cycle.
 type Collector struct {
-	connector      BatteryConnector
-	address        string
-	connectTimeout time.Duration
+	connector BatteryConnector
+	address   string
+	policy    ConnectionPolicy
 
-	mu      sync.Mutex
-	battery BatteryClient
+	// now and jitter are replaced in tests
+	now    func() time.Time
+	jitter func(time.Duration) time.Duration
+
+	mu                  sync.Mutex
+	battery             BatteryClient
+	idleTimer           *time.Timer
+	idleGeneration      int
+	connects            int
+	connectLatency      time.Duration
+	consecutiveFailures int
+	adapterResets       int
+	backoff             time.Duration
+	nextAttempt         time.Time
 }
 
 type BatteryStatus struct {
@@ -46,15 +139,23 @@ type BatteryInfo struct {
 	DeviceStrings  []string `json:"deviceStrings"`
 }
 
-func NewCollector(connector BatteryConnector, address string, connectTimeout time.Duration) *Collector {
-	if connectTimeout <= 0 {
-		connectTimeout = defaultConnectTimeout
-	}
+func NewCollector(connector BatteryConnector, address string, policy ConnectionPolicy) *Collector {
 	return &Collector{
-		connector:      connector,
-		address:        address,
-		connectTimeout: connectTimeout,
+		connector: connector,
+		address:   address,
+		policy:    policy.withDefaults(),
+		now:       time.Now,
+		jitter:    equalJitter,
+	}
+}
+
+// equalJitter returns a random wait between half of d and d, so batteries
+// that failed together do not all retry together.
+func equalJitter(d time.Duration) time.Duration {
+	if d < 2 {
+		return d
 	}
+	return d/2 + rand.N(d/2)
 }
 
 func (c *Collector) GetStatus(ctx context.Context) (*BatteryStatus, error) {
@@ -122,6 +223,78 @@ func (c *Collector) GetInfo(ctx context.Context) (*BatteryInfo, error) {
 	}, nil
 }
 
+// Disconnect drops any active battery connection; the next collection
+// reconnects. With several batteries on one adapter, this keeps only one
+// connection open at a time.
+func (c *Collector) Disconnect() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dropConnectionLocked()
+}
+
+// Release ends a collection cycle's use of the connection: it is dropped in
+// ConnectionPerCycle mode, and dropped once it has gone unused for the idle
+// timeout in ConnectionIdle mode.
+func (c *Collector) Release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch c.policy.Mode {
+	case ConnectionPerCycle:
+		c.dropConnectionLocked()
+	case ConnectionIdle:
+		if c.battery == nil {
+			return
+		}
+		c.stopIdleTimerLocked()
+		generation := c.idleGeneration
+		c.idleTimer = time.AfterFunc(c.policy.IdleTimeout, func() {
+			c.disconnectIdle(generation)
+		})
+	}
+}
+
+// disconnectIdle drops the connection unless it has been used since the idle
+// timer for generation was started.
+func (c *Collector) disconnectIdle(generation int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.idleGeneration || c.battery == nil {
+		return
+	}
+	log.Debugf("voltgo battery %s idle for %s, disconnecting", c.address, c.policy.IdleTimeout)
+	c.dropConnectionLocked()
+}
+
+// stopIdleTimerLocked cancels a pending idle disconnect. A timer that has
+// already fired and is waiting for the lock finds the generation moved on.
+// Callers must hold c.mu.
+func (c *Collector) stopIdleTimerLocked() {
+	c.idleGeneration++
+	if c.idleTimer != nil {
+		c.idleTimer.Stop()
+		c.idleTimer = nil
+	}
+}
+
+// ConnectionStats reports the connection's state and counters.
+func (c *Collector) ConnectionStats() ConnectionStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return ConnectionStats{
+		Connected:           c.battery != nil,
+		Connects:            c.connects,
+		Reconnects:          max(c.connects-1, 0),
+		ConnectLatency:      c.connectLatency,
+		ConsecutiveFailures: c.consecutiveFailures,
+		AdapterResets:       c.adapterResets,
+		NextAttempt:         c.nextAttempt,
+	}
+}
+
 // Close drops any active battery connection and releases the BLE adapter.
 func (c *Collector) Close() error {
 	c.mu.Lock()
@@ -132,8 +305,11 @@ func (c *Collector) Close() error {
 }
 
 // connectLocked returns the current battery connection, establishing or
-// re-establishing it as needed. Callers must hold c.mu.
+// re-establishing it as needed. A connect is not attempted until the backoff
+// from the last failed one has passed. Callers must hold c.mu.
 func (c *Collector) connectLocked(ctx context.Context) (BatteryClient, error) {
+	c.stopIdleTimerLocked()
+
 	if c.battery != nil {
 		if c.battery.IsConnected() {
 			return c.battery, nil
@@ -142,23 +318,63 @@ func (c *Collector) connectLocked(ctx context.Context) (BatteryClient, error) {
 		c.dropConnectionLocked()
 	}
 
-	connectCtx, cancel := context.WithTimeout(ctx, c.connectTimeout)
+	start := c.now()
+	if start.Before(c.nextAttempt) {
+		return nil, fmt.Errorf("waiting %s before reconnecting to voltgo battery %s",
+			c.nextAttempt.Sub(start).Round(time.Second), c.address)
+	}
+
+	connectCtx, cancel := context.WithTimeout(ctx, c.policy.Timeout)
 	defer cancel()
 
 	log.Debugf("connecting to voltgo battery %s", c.address)
 	bat, err := c.connector.Connect(connectCtx, c.address)
 	if err != nil {
+		c.connectFailedLocked()
 		return nil, fmt.Errorf("failed to connect to voltgo battery %s: %w", c.address, err)
 	}
 
-	log.Infof("connected to voltgo battery %s", c.address)
+	c.connectLatency = c.now().Sub(start)
+	c.connects++
+	c.consecutiveFailures = 0
+	c.backoff = 0
+	c.nextAttempt = time.Time{}
+	log.Infof("connected to voltgo battery %s in %s", c.address, c.connectLatency.Round(time.Millisecond))
 	c.battery = bat
 	return bat, nil
 }
 
+// connectFailedLocked backs off from a failed connect and resets the adapter
+// when the failures in a row reach the policy's threshold. Callers must hold
+// c.mu.
+func (c *Collector) connectFailedLocked() {
+	c.consecutiveFailures++
+	c.backoff = min(max(c.backoff*2, minConnectBackoff), c.policy.MaxBackoff)
+	wait := c.jitter(c.backoff)
+	c.nextAttempt = c.now().Add(wait)
+	log.Warnf("failed to connect to voltgo battery %s %d times in a row, retrying in %s",
+		c.address, c.consecutiveFailures, wait.Round(time.Second))
+
+	if c.policy.ResetAdapterAfter <= 0 || c.consecutiveFailures%c.policy.ResetAdapterAfter != 0 {
+		return
+	}
+	resetter, ok := c.connector.(AdapterResetter)
+	if !ok {
+		return
+	}
+	log.Warnf("resetting the BLE adapter after %d failed connects to voltgo battery %s",
+		c.consecutiveFailures, c.address)
+	if err := resetter.ResetAdapter(); err != nil {
+		log.Errorf("failed to reset the BLE adapter: %s", err)
+		return
+	}
+	c.adapterResets++
+}
+
 // dropConnectionLocked disconnects and forgets the current battery so the
 // next collection cycle reconnects. Callers must hold c.mu.
 func (c *Collector) dropConnectionLocked() {
+	c.stopIdleTimerLocked()
 	if c.battery == nil {
 		return
 	}
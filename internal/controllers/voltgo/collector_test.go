@@ -3,9 +3,11 @@ package voltgo
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/lumberbarons/solar-controller/internal/estimator"
 	"github.com/lumberbarons/voltgo/battery"
 )
 
@@ -44,7 +46,7 @@ func TestCollector_GetStatus(t *testing.T) {
 			},
 		}
 
-		collector := NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", 10*time.Second)
+		collector := NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", ConnectionPolicy{Timeout: 10 * time.Second})
 		status, err := collector.GetStatus(ctx)
 
 		if err != nil {
@@ -111,7 +113,7 @@ func TestCollector_GetStatus(t *testing.T) {
 			},
 		}
 
-		collector := NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", 10*time.Second)
+		collector := NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", ConnectionPolicy{Timeout: 10 * time.Second})
 
 		for i := range 3 {
 			if _, err := collector.GetStatus(ctx); err != nil {
@@ -147,7 +149,7 @@ func TestCollector_GetStatus(t *testing.T) {
 			return batteries[len(mockConnector.ConnectCalls)-1], nil
 		}
 
-		collector := NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", 10*time.Second)
+		collector := NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", ConnectionPolicy{Timeout: 10 * time.Second})
 
 		if _, err := collector.GetStatus(ctx); err != nil {
 			t.Fatalf("first GetStatus() error = %v", err)
@@ -189,7 +191,7 @@ func TestCollector_GetStatus(t *testing.T) {
 			return batteries[len(mockConnector.ConnectCalls)-1], nil
 		}
 
-		collector := NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", 10*time.Second)
+		collector := NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", ConnectionPolicy{Timeout: 10 * time.Second})
 
 		if _, err := collector.GetStatus(ctx); err == nil {
 			t.Fatal("first GetStatus() should return the read error")
@@ -217,18 +219,217 @@ func TestCollector_GetStatus(t *testing.T) {
 			},
 		}
 
-		collector := NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", 10*time.Second)
+		collector := NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", ConnectionPolicy{Timeout: 10 * time.Second})
+		now := time.Now()
+		collector.now = func() time.Time { return now }
 
-		if _, err := collector.GetStatus(ctx); err == nil {
-			t.Fatal("GetStatus() should return the connect error")
+		if _, err := collector.GetStatus(ctx); err == nil || !strings.Contains(err.Error(), "device not found") {
+			t.Fatalf("GetStatus() error = %v, want the connect error", err)
 		}
 
 		// A failed connect leaves no battery behind; the next cycle retries
+		// once the backoff has passed
+		now = now.Add(minConnectBackoff)
 		if _, err := collector.GetStatus(ctx); err == nil {
 			t.Fatal("second GetStatus() should also return the connect error")
 		}
 		if len(mockConnector.ConnectCalls) != 2 {
-			t.Errorf("Connect calls = %d, want 2 (should retry connect each cycle)", len(mockConnector.ConnectCalls))
+			t.Errorf("Connect calls = %d, want 2 (should retry once the backoff has passed)", len(mockConnector.ConnectCalls))
+		}
+	})
+}
+
+func TestCollector_Backoff(t *testing.T) {
+	ctx := context.Background()
+
+	newFailingConnector := func() *MockBatteryConnector {
+		return &MockBatteryConnector{
+			ConnectFunc: func(_ context.Context, _ string) (BatteryClient, error) {
+				return nil, errors.New("device not found")
+			},
+		}
+	}
+
+	t.Run("waits longer after each failed connect up to the maximum", func(t *testing.T) {
+		connector := newFailingConnector()
+		collector := NewCollector(connector, "AA:BB:CC:DD:EE:FF", ConnectionPolicy{MaxBackoff: 30 * time.Second})
+		now := time.Now()
+		collector.now = func() time.Time { return now }
+		collector.jitter = func(d time.Duration) time.Duration { return d }
+
+		for _, wantWait := range []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second} {
+			if _, err := collector.GetStatus(ctx); err == nil {
+				t.Fatal("GetStatus() should fail while the battery cannot be reached")
+			}
+			if wait := collector.ConnectionStats().NextAttempt.Sub(now); wait != wantWait {
+				t.Errorf("wait = %s, want %s", wait, wantWait)
+			}
+
+			calls := len(connector.ConnectCalls)
+			now = now.Add(wantWait - time.Second)
+			_, err := collector.GetStatus(ctx)
+			if err == nil || !strings.Contains(err.Error(), "before reconnecting") {
+				t.Errorf("GetStatus() during the backoff error = %v, want a wait", err)
+			}
+			if len(connector.ConnectCalls) != calls {
+				t.Error("no connect should be attempted during the backoff")
+			}
+			now = now.Add(time.Second)
+		}
+		if failures := collector.ConnectionStats().ConsecutiveFailures; failures != 4 {
+			t.Errorf("consecutive failures = %d, want 4", failures)
+		}
+	})
+
+	t.Run("a successful connect clears the backoff", func(t *testing.T) {
+		failing := true
+		connector := &MockBatteryConnector{
+			ConnectFunc: func(_ context.Context, _ string) (BatteryClient, error) {
+				if failing {
+					return nil, errors.New("device not found")
+				}
+				return &MockBatteryClient{GetStatusFunc: func(_ context.Context) (*battery.Status, error) {
+					return testBatteryStatus(), nil
+				}}, nil
+			},
+		}
+		collector := NewCollector(connector, "AA:BB:CC:DD:EE:FF", ConnectionPolicy{})
+		now := time.Now()
+		collector.now = func() time.Time { return now }
+
+		collector.GetStatus(ctx) //nolint:errcheck // Fails by design
+		now = now.Add(minConnectBackoff)
+		failing = false
+		if _, err := collector.GetStatus(ctx); err != nil {
+			t.Fatalf("GetStatus() error = %v", err)
+		}
+
+		stats := collector.ConnectionStats()
+		if !stats.Connected || stats.Connects != 1 || stats.ConsecutiveFailures != 0 || !stats.NextAttempt.IsZero() {
+			t.Errorf("stats = %+v, want connected once with no backoff", stats)
+		}
+	})
+
+	t.Run("jitter keeps the wait between half and all of the backoff", func(t *testing.T) {
+		for range 100 {
+			if wait := equalJitter(time.Minute); wait < 30*time.Second || wait >= time.Minute {
+				t.Fatalf("equalJitter(1m) = %s, want within [30s, 1m)", wait)
+			}
+		}
+		if wait := equalJitter(1); wait != 1 {
+			t.Errorf("equalJitter(1ns) = %s, want 1ns", wait)
+		}
+	})
+
+	t.Run("resets the adapter after repeated failures", func(t *testing.T) {
+		connector := newFailingConnector()
+		collector := NewCollector(connector, "AA:BB:CC:DD:EE:FF", ConnectionPolicy{ResetAdapterAfter: 2})
+		now := time.Now()
+		collector.now = func() time.Time { return now }
+
+		for range 5 {
+			collector.GetStatus(ctx) //nolint:errcheck // Fails by design
+			now = now.Add(defaultMaxBackoff)
+		}
+		if connector.ResetAdapterCalls != 2 {
+			t.Errorf("ResetAdapter calls = %d, want 2 (after the 2nd and 4th failures)", connector.ResetAdapterCalls)
+		}
+		if resets := collector.ConnectionStats().AdapterResets; resets != 2 {
+			t.Errorf("adapter resets = %d, want 2", resets)
+		}
+
+		connector.ResetAdapterFunc = func() error { return errors.New("operation not permitted") }
+		for range 2 {
+			collector.GetStatus(ctx) //nolint:errcheck // Fails by design
+			now = now.Add(defaultMaxBackoff)
+		}
+		if resets := collector.ConnectionStats().AdapterResets; resets != 2 {
+			t.Errorf("adapter resets = %d, want 2 when the reset fails", resets)
+		}
+	})
+}
+
+func TestCollector_Release(t *testing.T) {
+	ctx := context.Background()
+
+	newConnector := func() (*MockBatteryConnector, *MockBatteryClient) {
+		client := &MockBatteryClient{GetStatusFunc: func(_ context.Context) (*battery.Status, error) {
+			return testBatteryStatus(), nil
+		}}
+		return &MockBatteryConnector{
+			ConnectFunc: func(_ context.Context, _ string) (BatteryClient, error) {
+				return client, nil
+			},
+		}, client
+	}
+
+	t.Run("persistent keeps the connection", func(t *testing.T) {
+		connector, client := newConnector()
+		collector := NewCollector(connector, "AA:BB:CC:DD:EE:FF", ConnectionPolicy{})
+		collector.GetStatus(ctx) //nolint:errcheck // Checked through the stats
+		collector.Release()
+
+		if client.DisconnectCalls != 0 || !collector.ConnectionStats().Connected {
+			t.Error("persistent mode should stay connected after a cycle")
+		}
+	})
+
+	t.Run("per-cycle disconnects after each cycle", func(t *testing.T) {
+		connector, client := newConnector()
+		collector := NewCollector(connector, "AA:BB:CC:DD:EE:FF", ConnectionPolicy{Mode: ConnectionPerCycle})
+		for range 2 {
+			if _, err := collector.GetStatus(ctx); err != nil {
+				t.Fatalf("GetStatus() error = %v", err)
+			}
+			collector.Release()
+		}
+
+		stats := collector.ConnectionStats()
+		if client.DisconnectCalls != 2 || stats.Connected || stats.Reconnects != 1 {
+			t.Errorf("disconnects = %d, stats = %+v, want 2 disconnects and 1 reconnect", client.DisconnectCalls, stats)
+		}
+	})
+
+	t.Run("idle disconnects once unused for the timeout", func(t *testing.T) {
+		connector, client := newConnector()
+		collector := NewCollector(connector, "AA:BB:CC:DD:EE:FF",
+			ConnectionPolicy{Mode: ConnectionIdle, IdleTimeout: 20 * time.Millisecond})
+		collector.GetStatus(ctx) //nolint:errcheck // Checked through the stats
+		collector.Release()
+
+		deadline := time.Now().Add(time.Second)
+		for collector.ConnectionStats().Connected && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if collector.ConnectionStats().Connected {
+			t.Fatal("idle connection should be dropped after the timeout")
+		}
+		client.mu.RLock()
+		defer client.mu.RUnlock()
+		if client.DisconnectCalls != 1 {
+			t.Errorf("Disconnect calls = %d, want 1", client.DisconnectCalls)
+		}
+	})
+
+	t.Run("using the connection cancels the idle disconnect", func(t *testing.T) {
+		connector, _ := newConnector()
+		collector := NewCollector(connector, "AA:BB:CC:DD:EE:FF",
+			ConnectionPolicy{Mode: ConnectionIdle, IdleTimeout: time.Hour})
+		collector.GetStatus(ctx) //nolint:errcheck // Checked through the stats
+		collector.Release()
+
+		collector.mu.Lock()
+		generation := collector.idleGeneration
+		collector.mu.Unlock()
+
+		// A timer that fired while the connection was being used again
+		collector.GetStatus(ctx) //nolint:errcheck // Checked through the stats
+		collector.disconnectIdle(generation)
+		if !collector.ConnectionStats().Connected {
+			t.Error("a stale idle timer should not drop a connection in use")
+		}
+		if err := collector.Close(); err != nil {
+			t.Errorf("Close() error = %v", err)
 		}
 	})
 }
@@ -253,7 +454,7 @@ func TestCollector_GetInfo(t *testing.T) {
 			},
 		}
 
-		collector := NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", 10*time.Second)
+		collector := NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", ConnectionPolicy{Timeout: 10 * time.Second})
 		info, err := collector.GetInfo(ctx)
 
 		if err != nil {
@@ -285,7 +486,7 @@ func TestCollector_GetInfo(t *testing.T) {
 			},
 		}
 
-		collector := NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", 10*time.Second)
+		collector := NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", ConnectionPolicy{Timeout: 10 * time.Second})
 
 		if _, err := collector.GetInfo(ctx); err == nil {
 			t.Fatal("GetInfo() should return the read error")
@@ -311,7 +512,7 @@ func TestCollector_Close(t *testing.T) {
 			},
 		}
 
-		collector := NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", 10*time.Second)
+		collector := NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", ConnectionPolicy{Timeout: 10 * time.Second})
 		if _, err := collector.GetStatus(ctx); err != nil {
 			t.Fatalf("GetStatus() error = %v", err)
 		}
@@ -330,7 +531,7 @@ func TestCollector_Close(t *testing.T) {
 	t.Run("closes connector when never connected", func(t *testing.T) {
 		mockConnector := &MockBatteryConnector{}
 
-		collector := NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", 10*time.Second)
+		collector := NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", ConnectionPolicy{Timeout: 10 * time.Second})
 		if err := collector.Close(); err != nil {
 			t.Fatalf("Close() error = %v", err)
 		}
@@ -361,6 +562,36 @@ func TestConfiguration_GetConnectTimeout(t *testing.T) {
 	}
 }
 
+func TestConfiguration_GetConnection(t *testing.T) {
+	defaults := (&Configuration{}).GetConnection()
+	want := ConnectionPolicy{
+		Mode:        ConnectionPersistent,
+		Timeout:     30 * time.Second,
+		IdleTimeout: 30 * time.Second,
+		MaxBackoff:  5 * time.Minute,
+	}
+	if defaults != want {
+		t.Errorf("GetConnection() = %+v, want %+v", defaults, want)
+	}
+
+	configured := (&Configuration{
+		ConnectTimeout: "20s",
+		Connection: ConnectionConfig{
+			Mode: ConnectionIdle, IdleTimeout: "90s", MaxBackoff: "bogus", ResetAdapterAfter: 3,
+		},
+	}).GetConnection()
+	want = ConnectionPolicy{
+		Mode:              ConnectionIdle,
+		Timeout:           20 * time.Second,
+		IdleTimeout:       90 * time.Second,
+		MaxBackoff:        5 * time.Minute,
+		ResetAdapterAfter: 3,
+	}
+	if configured != want {
+		t.Errorf("GetConnection() = %+v, want %+v", configured, want)
+	}
+}
+
 func TestConfiguration_Validate(t *testing.T) {
 	valid := &Configuration{ConnectTimeout: "10s"}
 	if err := valid.Validate(); err != nil {
@@ -376,4 +607,193 @@ func TestConfiguration_Validate(t *testing.T) {
 	if err := invalid.Validate(); err == nil {
 		t.Error("Validate() should return an error for an invalid duration")
 	}
+
+	batteries := []struct {
+		name    string
+		config  Configuration
+		wantErr string
+	}{
+		{
+			name: "named batteries",
+			config: Configuration{Batteries: []BatteryConfig{
+				{Name: "house-a", Address: "AA:BB:CC:DD:EE:01"},
+				{Name: "house-b", Address: "AA:BB:CC:DD:EE:02"},
+			}},
+		},
+		{
+			name: "address and batteries together",
+			config: Configuration{
+				Address:   "AA:BB:CC:DD:EE:01",
+				Batteries: []BatteryConfig{{Name: "house-b", Address: "AA:BB:CC:DD:EE:02"}},
+			},
+			wantErr: "not both",
+		},
+		{
+			name:    "battery without an address",
+			config:  Configuration{Batteries: []BatteryConfig{{Name: "house-a"}}},
+			wantErr: "has no address",
+		},
+		{
+			name:    "name that is not a topic segment",
+			config:  Configuration{Batteries: []BatteryConfig{{Name: "House A", Address: "AA:BB:CC:DD:EE:01"}}},
+			wantErr: "lowercase letters",
+		},
+		{
+			name:    "reserved name",
+			config:  Configuration{Batteries: []BatteryConfig{{Name: "bank", Address: "AA:BB:CC:DD:EE:01"}}},
+			wantErr: "reserved",
+		},
+		{
+			name: "duplicate name",
+			config: Configuration{Batteries: []BatteryConfig{
+				{Name: "house-a", Address: "AA:BB:CC:DD:EE:01"},
+				{Name: "house-a", Address: "AA:BB:CC:DD:EE:02"},
+			}},
+			wantErr: "used more than once",
+		},
+		{
+			name: "duplicate address",
+			config: Configuration{Batteries: []BatteryConfig{
+				{Name: "house-a", Address: "AA:BB:CC:DD:EE:01"},
+				{Name: "house-b", Address: "AA:BB:CC:DD:EE:01"},
+			}},
+			wantErr: "listed more than once",
+		},
+		{
+			name:   "cells as topics",
+			config: Configuration{Cells: CellsConfig{Publish: CellsTopics, PublishPeriod: 300}},
+		},
+		{
+			name:   "cells as an array",
+			config: Configuration{Cells: CellsConfig{Publish: CellsArray}},
+		},
+		{
+			name:    "unknown cells mode",
+			config:  Configuration{Cells: CellsConfig{Publish: "all"}},
+			wantErr: "cells publish",
+		},
+		{
+			name:    "negative cells period",
+			config:  Configuration{Cells: CellsConfig{Publish: CellsTopics, PublishPeriod: -1}},
+			wantErr: "must not be negative",
+		},
+		{
+			name: "idle connection",
+			config: Configuration{Connection: ConnectionConfig{
+				Mode: ConnectionIdle, IdleTimeout: "45s", MaxBackoff: "10m", ResetAdapterAfter: 5,
+			}},
+		},
+		{
+			name:    "unknown connection mode",
+			config:  Configuration{Connection: ConnectionConfig{Mode: "sometimes"}},
+			wantErr: "connection mode",
+		},
+		{
+			name:    "invalid idle timeout",
+			config:  Configuration{Connection: ConnectionConfig{IdleTimeout: "soon"}},
+			wantErr: "connection idle timeout",
+		},
+		{
+			name:    "zero max backoff",
+			config:  Configuration{Connection: ConnectionConfig{MaxBackoff: "0s"}},
+			wantErr: "connection max backoff must be positive",
+		},
+		{
+			name:    "negative adapter reset threshold",
+			config:  Configuration{Connection: ConnectionConfig{ResetAdapterAfter: -1}},
+			wantErr: "must not be negative",
+		},
+		{
+			name:   "runtime",
+			config: Configuration{Runtime: estimator.Config{ReserveSOC: 20, Smoothing: "10m", CapacityAh: 200}},
+		},
+		{
+			name:    "reserve SOC of 100",
+			config:  Configuration{Runtime: estimator.Config{ReserveSOC: 100}},
+			wantErr: "runtime reserve SOC",
+		},
+		{
+			name:    "invalid runtime smoothing",
+			config:  Configuration{Runtime: estimator.Config{Smoothing: "a while"}},
+			wantErr: "runtime smoothing",
+		},
+		{
+			name:    "zero runtime smoothing",
+			config:  Configuration{Runtime: estimator.Config{Smoothing: "0s"}},
+			wantErr: "runtime smoothing must be positive",
+		},
+		{
+			name:    "negative runtime capacity",
+			config:  Configuration{Runtime: estimator.Config{CapacityAh: -100}},
+			wantErr: "runtime capacity must not be negative",
+		},
+	}
+	for _, tt := range batteries {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfiguration_BatteryList(t *testing.T) {
+	single := Configuration{Address: "AA:BB:CC:DD:EE:FF"}
+	if got := single.BatteryList(); len(got) != 1 || got[0].Name != "" || got[0].Address != "AA:BB:CC:DD:EE:FF" {
+		t.Errorf("BatteryList() = %+v, want one unnamed battery", got)
+	}
+
+	named := Configuration{Batteries: []BatteryConfig{
+		{Name: "house-a", Address: "AA:BB:CC:DD:EE:01"},
+		{Name: "house-b", Address: "AA:BB:CC:DD:EE:02"},
+	}}
+	if got := named.BatteryList(); len(got) != 2 || got[1].Name != "house-b" {
+		t.Errorf("BatteryList() = %+v, want both named batteries", got)
+	}
+
+	if got := (&Configuration{}).BatteryList(); len(got) != 0 {
+		t.Errorf("BatteryList() = %+v, want none", got)
+	}
+}
+
+func TestSharedConnector(t *testing.T) {
+	mockConnector := &MockBatteryConnector{}
+	shared := shareConnector(mockConnector, 2)
+
+	if err := shared.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if mockConnector.CloseCalls != 0 {
+		t.Errorf("adapter closed while a collector still uses it")
+	}
+
+	if err := shared.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if mockConnector.CloseCalls != 1 {
+		t.Errorf("connector Close calls = %d, want 1 after the last collector closes", mockConnector.CloseCalls)
+	}
+}
+
+func TestSharedConnector_ResetAdapter(t *testing.T) {
+	mockConnector := &MockBatteryConnector{}
+	if err := shareConnector(mockConnector, 2).ResetAdapter(); err != nil {
+		t.Fatalf("ResetAdapter() error = %v", err)
+	}
+	if mockConnector.ResetAdapterCalls != 1 {
+		t.Errorf("connector ResetAdapter calls = %d, want 1", mockConnector.ResetAdapterCalls)
+	}
+
+	// A connector that cannot reset its adapter says so
+	type plainConnector struct{ BatteryConnector }
+	if err := shareConnector(plainConnector{mockConnector}, 1).ResetAdapter(); err == nil {
+		t.Error("ResetAdapter() should fail when the connector cannot reset")
+	}
 }
@@ -0,0 +1,135 @@
+package voltgo
+
+import (
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/energy"
+)
+
+// energyFileName is the file under the data directory that holds the charge
+// and discharge totals of every battery.
+const energyFileName = "voltgo-energy.json"
+
+// EnergyTotals is what a battery charged and discharged over one period.
+type EnergyTotals struct {
+	ChargedAh     float64 `json:"chargedAh"`
+	DischargedAh  float64 `json:"dischargedAh"`
+	ChargedKWh    float64 `json:"chargedKwh"`
+	DischargedKWh float64 `json:"dischargedKwh"`
+}
+
+func (t *EnergyTotals) add(other EnergyTotals) {
+	t.ChargedAh += other.ChargedAh
+	t.DischargedAh += other.DischargedAh
+	t.ChargedKWh += other.ChargedKWh
+	t.DischargedKWh += other.DischargedKWh
+}
+
+// EnergyStatus is a battery's charge and discharge accounting.
+type EnergyStatus struct {
+	// Timestamp is that of the last sample counted
+	Timestamp int64 `json:"timestamp"`
+
+	// Day and Month are the local day (2006-01-02) and month (2006-01) the
+	// Daily and Monthly totals are for
+	Day      string       `json:"day"`
+	Month    string       `json:"month"`
+	Daily    EnergyTotals `json:"daily"`
+	Monthly  EnergyTotals `json:"monthly"`
+	Lifetime EnergyTotals `json:"lifetime"`
+
+	// RoundTripEfficiency is the lifetime energy discharged over the energy
+	// charged, in percent; nil until something has been charged
+	RoundTripEfficiency *float64 `json:"roundTripEfficiency"`
+
+	// EquivalentFullCycles is the lifetime charge discharged over the
+	// battery's capacity; nil until the capacity is known
+	EquivalentFullCycles *float64 `json:"equivalentFullCycles"`
+}
+
+// energySample is the reading the next one is integrated from.
+type energySample struct {
+	Timestamp int64   `json:"timestamp"`
+	Current   float64 `json:"current"`
+	Power     float64 `json:"power"`
+}
+
+// energyCounter integrates a battery's current and power across collection
+// cycles. It is saved as is, last sample included, so counting carries on
+// across a restart that is shorter than the gap.
+type energyCounter struct {
+	Day      string        `json:"day"`
+	Month    string        `json:"month"`
+	Daily    EnergyTotals  `json:"daily"`
+	Monthly  EnergyTotals  `json:"monthly"`
+	Lifetime EnergyTotals  `json:"lifetime"`
+	Last     *energySample `json:"last,omitempty"`
+}
+
+// add counts the interval from the last sample to this status and returns
+// what it added; an interval longer than maxGap is not counted. The interval
+// counts towards the day and month of the status, so one spanning midnight
+// lands in the new day.
+func (c *energyCounter) add(status *BatteryStatus, maxGap time.Duration) EnergyTotals {
+	sample := &energySample{
+		Timestamp: status.Timestamp,
+		Current:   status.Current,
+		Power:     status.Voltage * status.Current,
+	}
+
+	local := time.Unix(status.Timestamp, 0).Local()
+	if day := local.Format("2006-01-02"); day != c.Day {
+		c.Day, c.Daily = day, EnergyTotals{}
+	}
+	if month := local.Format("2006-01"); month != c.Month {
+		c.Month, c.Monthly = month, EnergyTotals{}
+	}
+
+	last := c.Last
+	c.Last = sample
+	if last == nil {
+		return EnergyTotals{}
+	}
+	hours := energy.Hours(last.Timestamp, sample.Timestamp, maxGap)
+	if hours == 0 {
+		return EnergyTotals{}
+	}
+
+	chargedAh, dischargedAh := energy.Integrate(last.Current, sample.Current, hours)
+	chargedWh, dischargedWh := energy.Integrate(last.Power, sample.Power, hours)
+	added := EnergyTotals{
+		ChargedAh:     chargedAh,
+		DischargedAh:  dischargedAh,
+		ChargedKWh:    chargedWh / 1000,
+		DischargedKWh: dischargedWh / 1000,
+	}
+
+	c.Daily.add(added)
+	c.Monthly.add(added)
+	c.Lifetime.add(added)
+	return added
+}
+
+// status reports the counter, with the cycles worked out from the capacity
+// in info, if known.
+func (c *energyCounter) status(info *BatteryInfo) *EnergyStatus {
+	status := &EnergyStatus{
+		Day:      c.Day,
+		Month:    c.Month,
+		Daily:    c.Daily,
+		Monthly:  c.Monthly,
+		Lifetime: c.Lifetime,
+	}
+	if c.Last != nil {
+		status.Timestamp = c.Last.Timestamp
+	}
+	if c.Lifetime.ChargedKWh > 0 {
+		efficiency := c.Lifetime.DischargedKWh / c.Lifetime.ChargedKWh * 100
+		status.RoundTripEfficiency = &efficiency
+	}
+	if info != nil && info.CapacityAh > 0 {
+		cycles := c.Lifetime.DischargedAh / info.CapacityAh
+		status.EquivalentFullCycles = &cycles
+	}
+	return status
+}
@@ -0,0 +1,237 @@
+package voltgo
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+	"github.com/lumberbarons/voltgo/battery"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// energyStatusAt is a status at 12.5V, so power is 12.5 times the current.
+func energyStatusAt(when time.Time, current float64) *BatteryStatus {
+	return &BatteryStatus{Timestamp: when.Unix(), Voltage: 12.5, Current: current}
+}
+
+func TestEnergyCounter(t *testing.T) {
+	noon := time.Date(2026, 3, 14, 12, 0, 0, 0, time.Local)
+
+	t.Run("counts charge and discharge between samples", func(t *testing.T) {
+		counter := &energyCounter{}
+		if added := counter.add(energyStatusAt(noon, 10), time.Hour); added != (EnergyTotals{}) {
+			t.Errorf("first sample added %+v, want nothing", added)
+		}
+
+		added := counter.add(energyStatusAt(noon.Add(30*time.Minute), 10), time.Hour)
+		if !near(added.ChargedAh, 5) || !near(added.ChargedKWh, 0.0625) || added.DischargedAh != 0 {
+			t.Errorf("charging half an hour at 10A added %+v, want 5Ah and 0.0625kWh charged", added)
+		}
+
+		counter.add(energyStatusAt(noon.Add(60*time.Minute), -10), time.Hour)
+		if !near(counter.Daily.ChargedAh, 6.25) || !near(counter.Daily.DischargedAh, 1.25) {
+			t.Errorf("daily = %+v, want 6.25Ah charged and 1.25Ah discharged", counter.Daily)
+		}
+		if counter.Monthly != counter.Daily || counter.Lifetime != counter.Daily {
+			t.Errorf("monthly %+v and lifetime %+v should match daily %+v", counter.Monthly, counter.Lifetime, counter.Daily)
+		}
+		if counter.Day != "2026-03-14" || counter.Month != "2026-03" {
+			t.Errorf("period = %s %s, want 2026-03-14 2026-03", counter.Day, counter.Month)
+		}
+	})
+
+	t.Run("does not count across a gap", func(t *testing.T) {
+		counter := &energyCounter{}
+		counter.add(energyStatusAt(noon, 10), 5*time.Minute)
+
+		if added := counter.add(energyStatusAt(noon.Add(10*time.Minute), 10), 5*time.Minute); added != (EnergyTotals{}) {
+			t.Errorf("sample after a gap added %+v, want nothing", added)
+		}
+		if added := counter.add(energyStatusAt(noon.Add(11*time.Minute), 6), 5*time.Minute); !near(added.ChargedAh, 8.0/60) {
+			t.Errorf("sample after that added %vAh, want %vAh", added.ChargedAh, 8.0/60)
+		}
+	})
+
+	t.Run("ignores a sample that is not newer", func(t *testing.T) {
+		counter := &energyCounter{}
+		counter.add(energyStatusAt(noon, 10), time.Hour)
+		if added := counter.add(energyStatusAt(noon, 10), time.Hour); added != (EnergyTotals{}) {
+			t.Errorf("repeated sample added %+v, want nothing", added)
+		}
+	})
+
+	t.Run("daily and monthly totals roll over", func(t *testing.T) {
+		counter := &energyCounter{}
+		lastOfMonth := time.Date(2026, 3, 31, 23, 50, 0, 0, time.Local)
+		counter.add(energyStatusAt(lastOfMonth, -6), time.Hour)
+		counter.add(energyStatusAt(lastOfMonth.Add(5*time.Minute), -6), time.Hour)
+
+		counter.add(energyStatusAt(lastOfMonth.Add(15*time.Minute), -6), time.Hour)
+		if counter.Day != "2026-04-01" || counter.Month != "2026-04" {
+			t.Errorf("period = %s %s, want 2026-04-01 2026-04", counter.Day, counter.Month)
+		}
+		// The interval across midnight counts towards the new day
+		if !near(counter.Daily.DischargedAh, 1) || !near(counter.Monthly.DischargedAh, 1) {
+			t.Errorf("daily %+v and monthly %+v should hold the 1Ah since the last sample", counter.Daily, counter.Monthly)
+		}
+		if !near(counter.Lifetime.DischargedAh, 1.5) {
+			t.Errorf("lifetime discharged = %vAh, want 1.5Ah", counter.Lifetime.DischargedAh)
+		}
+	})
+
+	t.Run("status reports efficiency and cycles once known", func(t *testing.T) {
+		counter := &energyCounter{}
+		status := counter.status(nil)
+		if status.RoundTripEfficiency != nil || status.EquivalentFullCycles != nil {
+			t.Errorf("empty counter status = %+v, want no efficiency or cycles", status)
+		}
+
+		counter.add(energyStatusAt(noon, 0), time.Hour)
+		counter.Lifetime = EnergyTotals{ChargedAh: 220, DischargedAh: 200, ChargedKWh: 2.8, DischargedKWh: 2.52}
+
+		status = counter.status(&BatteryInfo{CapacityAh: 100})
+		if status.Timestamp != noon.Unix() {
+			t.Errorf("Timestamp = %d, want %d", status.Timestamp, noon.Unix())
+		}
+		if status.RoundTripEfficiency == nil || !near(*status.RoundTripEfficiency, 90) {
+			t.Errorf("round-trip efficiency = %v, want 90", status.RoundTripEfficiency)
+		}
+		if status.EquivalentFullCycles == nil || !near(*status.EquivalentFullCycles, 2) {
+			t.Errorf("equivalent full cycles = %v, want 2", status.EquivalentFullCycles)
+		}
+	})
+}
+
+func TestConvertEnergyToMetrics(t *testing.T) {
+	efficiency, cycles := 90.0, 2.0
+	energy := &EnergyStatus{
+		Timestamp: 1699000000,
+		Daily:     EnergyTotals{ChargedAh: 1, DischargedAh: 2, ChargedKWh: 0.0125, DischargedKWh: 0.025},
+		Lifetime:  EnergyTotals{ChargedAh: 220},
+	}
+
+	metrics := ConvertEnergyToMetrics(energy)
+	if len(metrics) != 12 {
+		t.Fatalf("metrics length = %d, want 12 without efficiency or cycles", len(metrics))
+	}
+	want := []struct {
+		name  string
+		value float64
+		unit  string
+	}{
+		{"charged-ah-daily", 1, "amp-hours"},
+		{"discharged-ah-daily", 2, "amp-hours"},
+		{"energy-charged-daily", 0.0125, "kilowatt-hours"},
+		{"energy-discharged-daily", 0.025, "kilowatt-hours"},
+	}
+	for i, w := range want {
+		m := metrics[i]
+		if m.Name != w.name || m.Value != w.value || m.Unit != w.unit {
+			t.Errorf("metrics[%d] = %s %v %s, want %s %v %s", i, m.Name, m.Value, m.Unit, w.name, w.value, w.unit)
+		}
+	}
+	if metrics[8].Name != "charged-ah-total" || metrics[8].Value != 220.0 {
+		t.Errorf("metrics[8] = %s %v, want charged-ah-total 220", metrics[8].Name, metrics[8].Value)
+	}
+
+	energy.RoundTripEfficiency, energy.EquivalentFullCycles = &efficiency, &cycles
+	metrics = ConvertEnergyToMetrics(energy)
+	if len(metrics) != 14 {
+		t.Fatalf("metrics length = %d, want 14", len(metrics))
+	}
+	if metrics[12].Name != "round-trip-efficiency" || metrics[12].Value != 90.0 || metrics[12].Unit != "percent" {
+		t.Errorf("metrics[12] = %s %v %s, want round-trip-efficiency 90 percent", metrics[12].Name, metrics[12].Value, metrics[12].Unit)
+	}
+	if metrics[13].Name != "equivalent-full-cycles" || metrics[13].Value != 2.0 || metrics[13].Unit != "cycles" {
+		t.Errorf("metrics[13] = %s %v %s, want equivalent-full-cycles 2 cycles", metrics[13].Name, metrics[13].Value, metrics[13].Unit)
+	}
+
+	if got := ConvertEnergyToMetrics(nil); len(got) != 0 {
+		t.Errorf("nil energy metrics length = %d, want 0", len(got))
+	}
+}
+
+func TestController_EnergyPersistence(t *testing.T) {
+	t.Run("totals are saved on close and picked up again", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), energyFileName)
+		adapter := newBankAdapter(map[string]*battery.Status{
+			"AA:BB:CC:DD:EE:01": bankTestStatus(13.30, 5, 90),
+		})
+		first := newBankControllerForTest([]Battery{adapter.battery("house-a", "AA:BB:CC:DD:EE:01")},
+			&testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "test-device-1")
+		first.dataDir = filepath.Dir(path)
+		first.batteries[0].energy.Lifetime = EnergyTotals{ChargedAh: 42}
+
+		first.collectAndPublish()
+		if err := first.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+
+		counters := loadEnergy(filepath.Dir(path))
+		counter := counters["house-a"]
+		if counter == nil {
+			t.Fatalf("saved counters = %v, want house-a", counters)
+		}
+		if counter.Lifetime.ChargedAh != 42 || counter.Last == nil {
+			t.Errorf("saved counter = %+v, want 42Ah charged and the last sample", counter)
+		}
+
+		states := newBatteryStates([]Battery{{Name: "house-a"}, {Name: "house-b"}}, counters, nil)
+		if states[0].energy.Lifetime.ChargedAh != 42 {
+			t.Errorf("house-a lifetime = %+v, want the saved totals", states[0].energy.Lifetime)
+		}
+		if states[1].energy == nil || states[1].energy.Lifetime != (EnergyTotals{}) {
+			t.Errorf("house-b energy = %+v, want a new counter", states[1].energy)
+		}
+	})
+
+	t.Run("saves are spaced out unless forced", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), energyFileName)
+		controller := newControllerForTest(&Collector{}, &testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "test-device-1")
+		controller.dataDir = filepath.Dir(path)
+
+		controller.saveState(false)
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("first save did not write the file: %v", err)
+		}
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+
+		controller.saveState(false)
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Error("a save within the interval should be skipped")
+		}
+
+		controller.saveState(true)
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("forced save did not write the file: %v", err)
+		}
+	})
+
+	t.Run("an unreadable file is set aside", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), energyFileName)
+		if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		if counters := loadEnergy(filepath.Dir(path)); len(counters) != 0 {
+			t.Errorf("counters = %v, want none", counters)
+		}
+		if _, err := os.Stat(path + ".bad"); err != nil {
+			t.Errorf("unreadable file was not set aside: %v", err)
+		}
+	})
+
+	t.Run("no file keeps totals in memory", func(t *testing.T) {
+		if counters := loadEnergy(""); len(counters) != 0 {
+			t.Errorf("counters = %v, want none", counters)
+		}
+	})
+}
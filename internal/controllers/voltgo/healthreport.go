@@ -0,0 +1,301 @@
+package voltgo
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"time"
+)
+
+const (
+	// healthFileName is the file under the data directory that holds the
+	// SOH history and capacity estimates of every battery.
+	healthFileName = "voltgo-health.json"
+
+	// fullSOC is the SOC a capacity segment starts from. The BMS
+	// recalibrates its SOC at full, so it is most trustworthy there.
+	fullSOC = 100
+
+	// minSegmentDepth is the least SOC, in percent, a segment must discharge
+	// through to estimate the capacity from; shallower ones magnify the
+	// error in the BMS's SOC too much.
+	minSegmentDepth = 50
+
+	// maxSOHPoints and maxCapacityEstimates cap the history kept, oldest
+	// dropped first. SOH changes a few times a year and a segment completes
+	// at most about once a day, so this is years of either.
+	maxSOHPoints         = 1000
+	maxCapacityEstimates = 1000
+
+	// recentEstimates is how many of the latest estimates the estimated
+	// capacity is the median of, to smooth out a single odd one.
+	recentEstimates = 5
+
+	// A fade projection needs points over minFadeSpan, and at least
+	// minFadePoints of them when they are capacity estimates.
+	minFadePoints = 3
+	minFadeSpan   = 30 * 24 * time.Hour
+
+	// EndOfLifeSOH is the health, in percent of rated capacity, that the
+	// end-of-life projection is to. 80% is the usual warranty threshold.
+	EndOfLifeSOH = 80.0
+)
+
+// The basis a fade projection can be made on
+const (
+	FadeBasisCapacity = "capacity"
+	FadeBasisSOH      = "soh"
+)
+
+// SOHPoint is the SOH the BMS reported from a time on.
+type SOHPoint struct {
+	Timestamp int64 `json:"timestamp"`
+	SOH       int   `json:"soh"`
+}
+
+// CapacityEstimate is the usable capacity measured over one discharge from
+// full: the charge discharged scaled up by the share of the SOC it took.
+type CapacityEstimate struct {
+	// Timestamp is when charging resumed and ended the discharge
+	Timestamp  int64   `json:"timestamp"`
+	CapacityAh float64 `json:"capacityAh"`
+	FromSOC    int     `json:"fromSoc"`
+	ToSOC      int     `json:"toSoc"`
+}
+
+// HealthReport compares the capacity measured by current integration with
+// the BMS's SOH and the rated capacity, and projects the capacity fade.
+// Fields are nil until there is enough to work them out.
+type HealthReport struct {
+	Timestamp int64 `json:"timestamp"`
+
+	RatedCapacityAh *float64 `json:"ratedCapacityAh"`
+	BMSSOH          int      `json:"bmsSoh"`
+
+	// EstimatedCapacityAh is the median of the latest capacity estimates,
+	// and EstimatedSOH that as a percentage of the rated capacity
+	EstimatedCapacityAh *float64 `json:"estimatedCapacityAh"`
+	EstimatedSOH        *float64 `json:"estimatedSoh"`
+
+	// SOHDiscrepancy is the estimated SOH minus the BMS's, in percentage
+	// points; negative when the BMS is more optimistic
+	SOHDiscrepancy *float64 `json:"sohDiscrepancy"`
+
+	// FadeBasis is what the projection is fitted to: the capacity
+	// estimates when there are enough of them, otherwise the BMS's SOH
+	// history, or empty when there is not enough of either
+	FadeBasis string `json:"fadeBasis"`
+
+	// FadePerYear is the linear trend of the health, in percentage points
+	// lost a year
+	FadePerYear *float64 `json:"fadePerYear"`
+
+	// EndOfLife is when the trend reaches EndOfLifeSOH, as a Unix
+	// timestamp; nil while the health is not falling
+	EndOfLifeSOH float64 `json:"endOfLifeSoh"`
+	EndOfLife    *int64  `json:"endOfLife"`
+
+	SOHHistory        []SOHPoint         `json:"sohHistory"`
+	CapacityEstimates []CapacityEstimate `json:"capacityEstimates"`
+}
+
+// capacitySegment is a discharge from full in progress. NetAh is the charge
+// out less the charge in since it started; NetAhAtMin is what it was when
+// the SOC was lowest.
+type capacitySegment struct {
+	StartSOC   int     `json:"startSoc"`
+	Last       int64   `json:"last"`
+	NetAh      float64 `json:"netAh"`
+	MinSOC     int     `json:"minSoc"`
+	NetAhAtMin float64 `json:"netAhAtMin"`
+}
+
+// fadeTracker records a battery's SOH history and measures its capacity
+// from discharges that start at full. It is saved as is, segment included.
+type fadeTracker struct {
+	SOH      []SOHPoint         `json:"soh"`
+	Capacity []CapacityEstimate `json:"capacity"`
+	Segment  *capacitySegment   `json:"segment,omitempty"`
+}
+
+// add records the status, given what the energy counter added for the
+// interval before it, and returns a capacity estimate if this status ended
+// a discharge deep enough to make one. A gap longer than maxGap abandons a
+// discharge in progress, since the charge that flowed in it is unknown.
+func (f *fadeTracker) add(status *BatteryStatus, added EnergyTotals, maxGap time.Duration) *CapacityEstimate {
+	if status.SOH > 0 && (len(f.SOH) == 0 || f.SOH[len(f.SOH)-1].SOH != status.SOH) {
+		f.SOH = appendCapped(f.SOH, SOHPoint{Timestamp: status.Timestamp, SOH: status.SOH}, maxSOHPoints)
+	}
+
+	segment := f.Segment
+	if segment != nil && time.Duration(status.Timestamp-segment.Last)*time.Second > maxGap {
+		segment, f.Segment = nil, nil
+	}
+
+	if status.SOC >= fullSOC {
+		f.Segment = &capacitySegment{StartSOC: status.SOC, Last: status.Timestamp, MinSOC: status.SOC}
+		return nil
+	}
+	if segment == nil {
+		return nil
+	}
+
+	segment.Last = status.Timestamp
+	segment.NetAh += added.DischargedAh - added.ChargedAh
+	if status.SOC < segment.MinSOC {
+		segment.MinSOC, segment.NetAhAtMin = status.SOC, segment.NetAh
+	}
+	if status.Current < restingCurrent {
+		return nil
+	}
+
+	// Charging has resumed, so the discharge is over
+	f.Segment = nil
+	depth := segment.StartSOC - segment.MinSOC
+	if depth < minSegmentDepth || segment.NetAhAtMin <= 0 {
+		return nil
+	}
+	estimate := CapacityEstimate{
+		Timestamp:  status.Timestamp,
+		CapacityAh: segment.NetAhAtMin / (float64(depth) / 100),
+		FromSOC:    segment.StartSOC,
+		ToSOC:      segment.MinSOC,
+	}
+	f.Capacity = appendCapped(f.Capacity, estimate, maxCapacityEstimates)
+	return &estimate
+}
+
+// clone copies the tracker, so it can be saved outside the lock.
+func (f *fadeTracker) clone() fadeTracker {
+	clone := fadeTracker{SOH: slices.Clone(f.SOH), Capacity: slices.Clone(f.Capacity)}
+	if f.Segment != nil {
+		segment := *f.Segment
+		clone.Segment = &segment
+	}
+	return clone
+}
+
+// appendCapped appends v, dropping the oldest elements past limit.
+func appendCapped[T any](s []T, v T, limit int) []T {
+	s = append(s, v)
+	if len(s) > limit {
+		s = append(s[:0:0], s[len(s)-limit:]...)
+	}
+	return s
+}
+
+// report builds the health report for the latest status, with the rated
+// capacity from info, if known.
+func (f *fadeTracker) report(status *BatteryStatus, info *BatteryInfo) *HealthReport {
+	report := &HealthReport{
+		Timestamp:         status.Timestamp,
+		BMSSOH:            status.SOH,
+		EndOfLifeSOH:      EndOfLifeSOH,
+		SOHHistory:        append([]SOHPoint{}, f.SOH...),
+		CapacityEstimates: append([]CapacityEstimate{}, f.Capacity...),
+	}
+
+	var rated float64
+	if info != nil && info.CapacityAh > 0 {
+		rated = info.CapacityAh
+		report.RatedCapacityAh = &rated
+	}
+
+	if len(f.Capacity) > 0 {
+		estimated := medianCapacity(f.Capacity[max(0, len(f.Capacity)-recentEstimates):])
+		report.EstimatedCapacityAh = &estimated
+		if rated > 0 {
+			soh := estimated / rated * 100
+			discrepancy := soh - float64(status.SOH)
+			report.EstimatedSOH, report.SOHDiscrepancy = &soh, &discrepancy
+		}
+	}
+
+	times, health, basis := f.fadePoints(rated)
+	if basis == "" {
+		return report
+	}
+	slope, intercept, ok := linearFit(times, health)
+	if !ok {
+		return report
+	}
+
+	report.FadeBasis = basis
+	fade := -slope * 365
+	report.FadePerYear = &fade
+	if slope < 0 {
+		endOfLife := int64((EndOfLifeSOH - intercept) / slope * 24 * 60 * 60)
+		report.EndOfLife = &endOfLife
+	}
+	return report
+}
+
+// fadePoints returns the health over time to fit the fade to, as days since
+// the Unix epoch and percent: the capacity estimates against the rated
+// capacity if there are enough, otherwise the SOH history if there is
+// enough of that, otherwise nothing.
+func (f *fadeTracker) fadePoints(rated float64) (times, health []float64, basis string) {
+	if rated > 0 && len(f.Capacity) >= minFadePoints && spansFade(f.Capacity[0].Timestamp, f.Capacity[len(f.Capacity)-1].Timestamp) {
+		for _, estimate := range f.Capacity {
+			times = append(times, days(estimate.Timestamp))
+			health = append(health, estimate.CapacityAh/rated*100)
+		}
+		return times, health, FadeBasisCapacity
+	}
+
+	// SOH changes in steps, so the history has few points; each counts
+	// from when it was first reported
+	if len(f.SOH) >= 2 && spansFade(f.SOH[0].Timestamp, f.SOH[len(f.SOH)-1].Timestamp) {
+		for _, point := range f.SOH {
+			times = append(times, days(point.Timestamp))
+			health = append(health, float64(point.SOH))
+		}
+		return times, health, FadeBasisSOH
+	}
+	return nil, nil, ""
+}
+
+// spansFade reports whether points from first to last span long enough to
+// fit a fade to.
+func spansFade(first, last int64) bool {
+	return time.Duration(last-first)*time.Second >= minFadeSpan
+}
+
+func days(timestamp int64) float64 {
+	return float64(timestamp) / (24 * 60 * 60)
+}
+
+func medianCapacity(estimates []CapacityEstimate) float64 {
+	values := make([]float64, 0, len(estimates))
+	for _, estimate := range estimates {
+		values = append(values, estimate.CapacityAh)
+	}
+	sort.Float64s(values)
+
+	middle := len(values) / 2
+	if len(values)%2 == 1 {
+		return values[middle]
+	}
+	return (values[middle-1] + values[middle]) / 2
+}
+
+// linearFit returns the least-squares line through the points, or false if
+// there is no single one: fewer than two distinct xs.
+func linearFit(xs, ys []float64) (slope, intercept float64, ok bool) {
+	n := float64(len(xs))
+	var sumX, sumY, sumXX, sumXY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+		sumXX += xs[i] * xs[i]
+		sumXY += xs[i] * ys[i]
+	}
+
+	denominator := n*sumXX - sumX*sumX
+	if n < 2 || math.Abs(denominator) < 1e-12 {
+		return 0, 0, false
+	}
+	slope = (n*sumXY - sumX*sumY) / denominator
+	intercept = (sumY - slope*sumX) / n
+	return slope, intercept, true
+}
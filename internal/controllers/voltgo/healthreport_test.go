@@ -0,0 +1,285 @@
+package voltgo
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+)
+
+const day = 24 * time.Hour
+
+func fadeStatusAt(when time.Time, soc int, current float64) *BatteryStatus {
+	return &BatteryStatus{Timestamp: when.Unix(), SOC: soc, SOH: 100, Current: current}
+}
+
+func TestFadeTracker_SOHHistory(t *testing.T) {
+	start := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	tracker := &fadeTracker{}
+
+	for i, soh := range []int{100, 100, 99, 99, 0, 98} {
+		status := fadeStatusAt(start.Add(time.Duration(i)*day), 50, 0)
+		status.SOH = soh
+		tracker.add(status, EnergyTotals{}, time.Hour)
+	}
+
+	want := []SOHPoint{
+		{Timestamp: start.Unix(), SOH: 100},
+		{Timestamp: start.Add(2 * day).Unix(), SOH: 99},
+		{Timestamp: start.Add(5 * day).Unix(), SOH: 98},
+	}
+	if len(tracker.SOH) != len(want) {
+		t.Fatalf("SOH history = %+v, want %+v", tracker.SOH, want)
+	}
+	for i := range want {
+		if tracker.SOH[i] != want[i] {
+			t.Errorf("SOH[%d] = %+v, want %+v", i, tracker.SOH[i], want[i])
+		}
+	}
+}
+
+func TestFadeTracker_CapacityEstimate(t *testing.T) {
+	start := time.Date(2026, 3, 14, 18, 0, 0, 0, time.UTC)
+	step := 30 * time.Minute
+	discharge20Ah := EnergyTotals{DischargedAh: 20}
+
+	t.Run("a deep discharge from full measures the capacity", func(t *testing.T) {
+		tracker := &fadeTracker{}
+		tracker.add(fadeStatusAt(start, 100, 0), EnergyTotals{}, time.Hour)
+		tracker.add(fadeStatusAt(start.Add(step), 80, -40), discharge20Ah, time.Hour)
+		tracker.add(fadeStatusAt(start.Add(2*step), 60, -40), discharge20Ah, time.Hour)
+		tracker.add(fadeStatusAt(start.Add(3*step), 40, -40), discharge20Ah, time.Hour)
+
+		// Charging resumes; what it put back after the lowest point does not count
+		estimate := tracker.add(fadeStatusAt(start.Add(4*step), 41, 5), EnergyTotals{DischargedAh: 1, ChargedAh: 2}, time.Hour)
+		if estimate == nil {
+			t.Fatal("estimate = nil, want one once charging resumed")
+		}
+		if !near(estimate.CapacityAh, 100) || estimate.FromSOC != 100 || estimate.ToSOC != 40 {
+			t.Errorf("estimate = %+v, want 100Ah from 100%% to 40%%", estimate)
+		}
+		if len(tracker.Capacity) != 1 || tracker.Segment != nil {
+			t.Errorf("tracker = %+v, want the estimate kept and the segment closed", tracker)
+		}
+	})
+
+	t.Run("a shallow discharge is not enough", func(t *testing.T) {
+		tracker := &fadeTracker{}
+		tracker.add(fadeStatusAt(start, 100, 0), EnergyTotals{}, time.Hour)
+		tracker.add(fadeStatusAt(start.Add(step), 80, -40), discharge20Ah, time.Hour)
+		tracker.add(fadeStatusAt(start.Add(2*step), 70, -20), EnergyTotals{DischargedAh: 10}, time.Hour)
+
+		if estimate := tracker.add(fadeStatusAt(start.Add(3*step), 71, 5), EnergyTotals{}, time.Hour); estimate != nil {
+			t.Errorf("estimate = %+v, want none from a 30%% discharge", estimate)
+		}
+		if tracker.Segment != nil {
+			t.Error("segment should be closed once charging resumes")
+		}
+	})
+
+	t.Run("a gap abandons the discharge", func(t *testing.T) {
+		tracker := &fadeTracker{}
+		tracker.add(fadeStatusAt(start, 100, 0), EnergyTotals{}, time.Hour)
+		tracker.add(fadeStatusAt(start.Add(step), 80, -40), discharge20Ah, time.Hour)
+		tracker.add(fadeStatusAt(start.Add(4*step), 30, -40), EnergyTotals{}, time.Hour)
+
+		if estimate := tracker.add(fadeStatusAt(start.Add(5*step), 31, 5), EnergyTotals{}, time.Hour); estimate != nil {
+			t.Errorf("estimate = %+v, want none after a gap", estimate)
+		}
+	})
+
+	t.Run("no discharge starts below full", func(t *testing.T) {
+		tracker := &fadeTracker{}
+		tracker.add(fadeStatusAt(start, 95, -40), EnergyTotals{}, time.Hour)
+		tracker.add(fadeStatusAt(start.Add(step), 20, -40), EnergyTotals{DischargedAh: 75}, time.Hour)
+
+		if estimate := tracker.add(fadeStatusAt(start.Add(2*step), 21, 5), EnergyTotals{}, time.Hour); estimate != nil {
+			t.Errorf("estimate = %+v, want none without a full charge first", estimate)
+		}
+	})
+
+	t.Run("history is capped", func(t *testing.T) {
+		estimates := make([]CapacityEstimate, 0, maxCapacityEstimates)
+		for i := range maxCapacityEstimates {
+			estimates = append(estimates, CapacityEstimate{Timestamp: int64(i)})
+		}
+		estimates = appendCapped(estimates, CapacityEstimate{Timestamp: maxCapacityEstimates}, maxCapacityEstimates)
+		if len(estimates) != maxCapacityEstimates || estimates[0].Timestamp != 1 {
+			t.Errorf("capped history = %d estimates from %d, want %d from 1",
+				len(estimates), estimates[0].Timestamp, maxCapacityEstimates)
+		}
+	})
+}
+
+func TestFadeTracker_Report(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rated := &BatteryInfo{CapacityAh: 100}
+
+	t.Run("compares the estimate with the BMS and rated capacity", func(t *testing.T) {
+		tracker := &fadeTracker{Capacity: []CapacityEstimate{
+			{CapacityAh: 80}, {CapacityAh: 96}, {CapacityAh: 94}, {CapacityAh: 95}, {CapacityAh: 99}, {CapacityAh: 93},
+		}}
+		status := fadeStatusAt(start, 50, 0)
+		status.SOH = 97
+
+		report := tracker.report(status, rated)
+		// The median of the last five ignores the older 80Ah
+		if report.EstimatedCapacityAh == nil || !near(*report.EstimatedCapacityAh, 95) {
+			t.Errorf("estimated capacity = %v, want 95", report.EstimatedCapacityAh)
+		}
+		if report.EstimatedSOH == nil || !near(*report.EstimatedSOH, 95) {
+			t.Errorf("estimated SOH = %v, want 95", report.EstimatedSOH)
+		}
+		if report.SOHDiscrepancy == nil || !near(*report.SOHDiscrepancy, -2) {
+			t.Errorf("SOH discrepancy = %v, want -2", report.SOHDiscrepancy)
+		}
+		if report.FadeBasis != "" || report.FadePerYear != nil {
+			t.Errorf("fade = %q %v, want none from estimates with no span", report.FadeBasis, report.FadePerYear)
+		}
+	})
+
+	t.Run("projects the end of life from the capacity estimates", func(t *testing.T) {
+		tracker := &fadeTracker{
+			SOH: []SOHPoint{{Timestamp: start.Unix(), SOH: 100}, {Timestamp: start.Add(60 * day).Unix(), SOH: 100}},
+			Capacity: []CapacityEstimate{
+				{Timestamp: start.Unix(), CapacityAh: 100},
+				{Timestamp: start.Add(30 * day).Unix(), CapacityAh: 99},
+				{Timestamp: start.Add(60 * day).Unix(), CapacityAh: 98},
+			},
+		}
+
+		report := tracker.report(fadeStatusAt(start.Add(60*day), 50, 0), rated)
+		if report.FadeBasis != FadeBasisCapacity {
+			t.Errorf("fade basis = %q, want %q", report.FadeBasis, FadeBasisCapacity)
+		}
+		if report.FadePerYear == nil || !near(*report.FadePerYear, 365.0/30) {
+			t.Errorf("fade per year = %v, want %v", report.FadePerYear, 365.0/30)
+		}
+		// 20% at 1% a month is 600 days from the first estimate
+		want := start.Add(600 * day).Unix()
+		if report.EndOfLife == nil || math.Abs(float64(*report.EndOfLife-want)) > 1 {
+			t.Errorf("end of life = %v, want %d", report.EndOfLife, want)
+		}
+	})
+
+	t.Run("falls back to the SOH history", func(t *testing.T) {
+		tracker := &fadeTracker{SOH: []SOHPoint{
+			{Timestamp: start.Unix(), SOH: 100},
+			{Timestamp: start.Add(73 * day).Unix(), SOH: 99},
+		}}
+
+		report := tracker.report(fadeStatusAt(start.Add(73*day), 50, 0), nil)
+		if report.FadeBasis != FadeBasisSOH || report.RatedCapacityAh != nil {
+			t.Errorf("report = %+v, want the SOH basis and no rated capacity", report)
+		}
+		if report.FadePerYear == nil || !near(*report.FadePerYear, 5) {
+			t.Errorf("fade per year = %v, want 5", report.FadePerYear)
+		}
+		want := start.Add(1460 * day).Unix()
+		if report.EndOfLife == nil || math.Abs(float64(*report.EndOfLife-want)) > 1 {
+			t.Errorf("end of life = %v, want %d", report.EndOfLife, want)
+		}
+	})
+
+	t.Run("no end of life while the health is not falling", func(t *testing.T) {
+		tracker := &fadeTracker{Capacity: []CapacityEstimate{
+			{Timestamp: start.Unix(), CapacityAh: 98},
+			{Timestamp: start.Add(30 * day).Unix(), CapacityAh: 99},
+			{Timestamp: start.Add(60 * day).Unix(), CapacityAh: 100},
+		}}
+
+		report := tracker.report(fadeStatusAt(start.Add(60*day), 50, 0), rated)
+		if report.FadePerYear == nil || *report.FadePerYear >= 0 {
+			t.Errorf("fade per year = %v, want negative", report.FadePerYear)
+		}
+		if report.EndOfLife != nil {
+			t.Errorf("end of life = %d, want none", *report.EndOfLife)
+		}
+	})
+}
+
+func TestLinearFit(t *testing.T) {
+	slope, intercept, ok := linearFit([]float64{0, 1, 2}, []float64{1, 3, 5})
+	if !ok || !near(slope, 2) || !near(intercept, 1) {
+		t.Errorf("linearFit = %v, %v, %v, want 2, 1, true", slope, intercept, ok)
+	}
+
+	if _, _, ok := linearFit([]float64{4, 4}, []float64{1, 2}); ok {
+		t.Error("linearFit with one distinct x should not fit")
+	}
+	if _, _, ok := linearFit(nil, nil); ok {
+		t.Error("linearFit with no points should not fit")
+	}
+}
+
+func TestConvertHealthReportToMetrics(t *testing.T) {
+	capacity, soh, discrepancy, fade := 95.0, 95.0, -2.0, 1.5
+	report := &HealthReport{
+		Timestamp:           1699000000,
+		EstimatedCapacityAh: &capacity,
+		EstimatedSOH:        &soh,
+		SOHDiscrepancy:      &discrepancy,
+		FadePerYear:         &fade,
+	}
+
+	metrics := ConvertHealthReportToMetrics(report)
+	want := []struct {
+		name  string
+		value float64
+		unit  string
+	}{
+		{"estimated-capacity", 95, "amp-hours"},
+		{"estimated-soh", 95, "percent"},
+		{"soh-discrepancy", -2, "percent"},
+		{"capacity-fade", 1.5, "percent-per-year"},
+	}
+	if len(metrics) != len(want) {
+		t.Fatalf("metrics length = %d, want %d", len(metrics), len(want))
+	}
+	for i, w := range want {
+		m := metrics[i]
+		if m.Name != w.name || m.Value != w.value || m.Unit != w.unit || m.Timestamp != 1699000000 {
+			t.Errorf("metrics[%d] = %s %v %s, want %s %v %s", i, m.Name, m.Value, m.Unit, w.name, w.value, w.unit)
+		}
+	}
+
+	if got := ConvertHealthReportToMetrics(&HealthReport{}); len(got) != 0 {
+		t.Errorf("metrics of an empty report = %d, want 0", len(got))
+	}
+	if got := ConvertHealthReportToMetrics(nil); len(got) != 0 {
+		t.Errorf("nil report metrics length = %d, want 0", len(got))
+	}
+}
+
+func TestController_HealthPersistence(t *testing.T) {
+	dir := t.TempDir()
+	controller := newControllerForTest(&Collector{}, &testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "test-device-1")
+	controller.dataDir = dir
+	controller.batteries[0].fade = &fadeTracker{
+		SOH:      []SOHPoint{{Timestamp: 1699000000, SOH: 99}},
+		Capacity: []CapacityEstimate{{Timestamp: 1699000000, CapacityAh: 97, FromSOC: 100, ToSOC: 30}},
+		Segment:  &capacitySegment{StartSOC: 100, MinSOC: 90, NetAh: 10},
+	}
+
+	controller.saveHealth(true)
+	if _, err := os.Stat(filepath.Join(dir, healthFileName)); err != nil {
+		t.Fatalf("health history was not saved: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, energyFileName)); !os.IsNotExist(err) {
+		t.Errorf("saving the health history wrote the energy totals too")
+	}
+
+	tracker := loadHealth(dir)[""]
+	if tracker == nil {
+		t.Fatal("saved trackers have no entry for the unnamed battery")
+	}
+	if len(tracker.SOH) != 1 || len(tracker.Capacity) != 1 || tracker.Capacity[0].CapacityAh != 97 {
+		t.Errorf("loaded tracker = %+v, want the saved history", tracker)
+	}
+	if tracker.Segment == nil || tracker.Segment.NetAh != 10 {
+		t.Errorf("loaded segment = %+v, want the discharge in progress", tracker.Segment)
+	}
+}
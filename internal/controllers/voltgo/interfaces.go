@@ -3,6 +3,7 @@ package voltgo
 import (
 	"context"
 
+	"github.com/lumberbarons/solar-controller/internal/estimator"
 	"github.com/lumberbarons/voltgo/battery"
 )
 
@@ -27,11 +28,32 @@ type BatteryClient interface {
 // MetricsCollector defines the interface for collecting and exposing metrics.
 // This abstraction allows for testing without the Prometheus global registry.
 type MetricsCollector interface {
-	// IncrementFailures increments the collection failure counter.
-	IncrementFailures()
+	// IncrementFailures increments the collection failure counter of the
+	// named battery ("" for a single unnamed battery).
+	IncrementFailures(battery string)
 
-	// SetMetrics updates all metrics based on the provided status.
-	SetMetrics(status *BatteryStatus)
+	// SetMetrics updates the named battery's metrics from its status.
+	SetMetrics(battery string, status *BatteryStatus)
+
+	// SetCellAnalysis updates the named battery's cell analysis metrics.
+	SetCellAnalysis(battery string, analysis *CellAnalysis)
+
+	// SetEnergy adds what the named battery charged and discharged since the
+	// last call to its counters and updates its energy gauges.
+	SetEnergy(battery string, energy *EnergyStatus, added EnergyTotals)
+
+	// SetHealthReport updates the named battery's capacity fade metrics.
+	SetHealthReport(battery string, report *HealthReport)
+
+	// SetConnection updates the named battery's connection metrics.
+	SetConnection(battery string, stats ConnectionStats)
+
+	// SetBankMetrics updates the aggregates across all batteries.
+	SetBankMetrics(bank *BankStatus)
+
+	// SetRuntime updates the named battery's time-to-full and time-to-empty
+	// metrics, or the bank's for "bank". A nil estimate clears them.
+	SetRuntime(battery string, estimate *estimator.Estimate)
 }
 
 // BatteryConnector defines the interface for establishing BLE connections
@@ -40,6 +62,16 @@ type BatteryConnector interface {
 	// Connect establishes a BLE connection to the battery at the given address.
 	Connect(ctx context.Context, address string) (BatteryClient, error)
 
+	// Scan listens for advertisements until ctx is done and returns every
+	// device heard, each once with its latest signal strength.
+	Scan(ctx context.Context) ([]Device, error)
+
 	// Close releases the underlying BLE adapter.
 	Close() error
 }
+
+// AdapterResetter is implemented by a BatteryConnector that can reset its BLE
+// adapter, to recover one that has stopped connecting.
+type AdapterResetter interface {
+	ResetAdapter() error
+}
@@ -3,7 +3,17 @@ package voltgo
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/estimator"
+)
+
+// Per-cell voltage publish modes
+const (
+	CellsOff    = "off"
+	CellsTopics = "topics"
+	CellsArray  = "array"
 )
 
 // Metric represents a single metric with its value, unit, and timestamp
@@ -12,13 +22,27 @@ type Metric struct {
 	Value     any
 	Unit      string
 	Timestamp int64
+
+	// Series and Labels are set on metrics that are one of several values
+	// of the same series, such as a cell voltage: Series names it in place
+	// of Name and Labels tell the values apart
+	Series string
+	Labels map[string]string
 }
 
 // MetricPayload is the JSON structure published for each metric
 type MetricPayload struct {
-	Value     any    `json:"value"`
-	Unit      string `json:"unit"`
-	Timestamp int64  `json:"timestamp"`
+	Value     any               `json:"value"`
+	Unit      string            `json:"unit"`
+	Timestamp int64             `json:"timestamp"`
+	Metric    string            `json:"metric,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// CellVoltage is one cell of the cell-voltages array.
+type CellVoltage struct {
+	Cell  int     `json:"cell"`
+	Value float64 `json:"value"`
 }
 
 // ToJSON converts a Metric to its JSON representation
@@ -27,6 +51,8 @@ func (m *Metric) ToJSON() (string, error) {
 		Value:     m.Value,
 		Unit:      m.Unit,
 		Timestamp: m.Timestamp,
+		Metric:    m.Series,
+		Labels:    m.Labels,
 	}
 
 	b, err := json.Marshal(payload)
@@ -38,8 +64,8 @@ func (m *Metric) ToJSON() (string, error) {
 }
 
 // ConvertStatusToMetrics converts a BatteryStatus into individual metrics.
-// Per-cell voltages are deliberately not converted - they are exposed via
-// Prometheus and the HTTP API only, to keep the published topic space bounded.
+// Per-cell voltages are left to ConvertCellsToMetrics, since they are only
+// published on request.
 func ConvertStatusToMetrics(status *BatteryStatus) []Metric {
 	if status == nil {
 		return []Metric{}
@@ -118,6 +144,339 @@ func cellVoltageDelta(cells []Cell) float64 {
 	return maxV - minV
 }
 
+// ConvertCellsToMetrics converts the cell voltages into the cell summary and,
+// for CellsTopics, a cell-voltage-{index} metric per cell or, for CellsArray,
+// one cell-voltages metric holding them all. Either way the voltages belong
+// to the cell-voltage series, told apart by cell index. Indices are the
+// battery's own. There are no metrics without cells.
+func ConvertCellsToMetrics(status *BatteryStatus, mode string) []Metric {
+	if status == nil || len(status.Cells) == 0 || mode == CellsOff {
+		return []Metric{}
+	}
+
+	timestamp := status.Timestamp
+	lowest, highest := status.Cells[0], status.Cells[0]
+	var sum float64
+	for _, cell := range status.Cells {
+		if cell.Voltage < lowest.Voltage {
+			lowest = cell
+		}
+		if cell.Voltage > highest.Voltage {
+			highest = cell
+		}
+		sum += cell.Voltage
+	}
+
+	metrics := []Metric{
+		{
+			Name:      "cell-voltage-min",
+			Value:     lowest.Voltage,
+			Unit:      "volts",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      "cell-voltage-max",
+			Value:     highest.Voltage,
+			Unit:      "volts",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      "cell-voltage-mean",
+			Value:     sum / float64(len(status.Cells)),
+			Unit:      "volts",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      "cell-index-min",
+			Value:     lowest.Index,
+			Unit:      "index",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      "cell-index-max",
+			Value:     highest.Index,
+			Unit:      "index",
+			Timestamp: timestamp,
+		},
+	}
+
+	if mode == CellsArray {
+		cells := make([]CellVoltage, 0, len(status.Cells))
+		for _, cell := range status.Cells {
+			cells = append(cells, CellVoltage{Cell: cell.Index, Value: cell.Voltage})
+		}
+		return append(metrics, Metric{
+			Name:      "cell-voltages",
+			Value:     cells,
+			Unit:      "volts",
+			Timestamp: timestamp,
+			Series:    "cell-voltage",
+		})
+	}
+
+	for _, cell := range status.Cells {
+		index := strconv.Itoa(cell.Index)
+		metrics = append(metrics, Metric{
+			Name:      "cell-voltage-" + index,
+			Value:     cell.Voltage,
+			Unit:      "volts",
+			Timestamp: timestamp,
+			Series:    "cell-voltage",
+			Labels:    map[string]string{"cell": index},
+		})
+	}
+	return metrics
+}
+
+// ConvertCellAnalysisToMetrics converts the cell analysis into the imbalance
+// score and the number of flagged cells.
+func ConvertCellAnalysisToMetrics(analysis *CellAnalysis) []Metric {
+	if analysis == nil {
+		return []Metric{}
+	}
+
+	return []Metric{
+		{
+			Name:      "cell-imbalance-score",
+			Value:     analysis.ImbalanceScore,
+			Unit:      "volts",
+			Timestamp: analysis.Timestamp,
+		},
+		{
+			Name:      "cells-flagged",
+			Value:     analysis.flaggedCells(),
+			Unit:      "count",
+			Timestamp: analysis.Timestamp,
+		},
+	}
+}
+
+// ConvertEnergyToMetrics converts the charge and discharge accounting into
+// the daily, monthly and lifetime (-total) totals, followed by the round-trip
+// efficiency and equivalent full cycles once they are known.
+func ConvertEnergyToMetrics(energy *EnergyStatus) []Metric {
+	if energy == nil {
+		return []Metric{}
+	}
+
+	timestamp := energy.Timestamp
+	metrics := make([]Metric, 0, 14)
+	for _, period := range []struct {
+		suffix string
+		totals EnergyTotals
+	}{
+		{"daily", energy.Daily},
+		{"monthly", energy.Monthly},
+		{"total", energy.Lifetime},
+	} {
+		metrics = append(metrics,
+			Metric{
+				Name:      "charged-ah-" + period.suffix,
+				Value:     period.totals.ChargedAh,
+				Unit:      "amp-hours",
+				Timestamp: timestamp,
+			},
+			Metric{
+				Name:      "discharged-ah-" + period.suffix,
+				Value:     period.totals.DischargedAh,
+				Unit:      "amp-hours",
+				Timestamp: timestamp,
+			},
+			Metric{
+				Name:      "energy-charged-" + period.suffix,
+				Value:     period.totals.ChargedKWh,
+				Unit:      "kilowatt-hours",
+				Timestamp: timestamp,
+			},
+			Metric{
+				Name:      "energy-discharged-" + period.suffix,
+				Value:     period.totals.DischargedKWh,
+				Unit:      "kilowatt-hours",
+				Timestamp: timestamp,
+			},
+		)
+	}
+
+	if energy.RoundTripEfficiency != nil {
+		metrics = append(metrics, Metric{
+			Name:      "round-trip-efficiency",
+			Value:     *energy.RoundTripEfficiency,
+			Unit:      "percent",
+			Timestamp: timestamp,
+		})
+	}
+	if energy.EquivalentFullCycles != nil {
+		metrics = append(metrics, Metric{
+			Name:      "equivalent-full-cycles",
+			Value:     *energy.EquivalentFullCycles,
+			Unit:      "cycles",
+			Timestamp: timestamp,
+		})
+	}
+	return metrics
+}
+
+// ConvertHealthReportToMetrics converts the health report into the
+// estimated capacity and SOH, how far the estimate is from the BMS's SOH,
+// and the capacity fade, each once it is known.
+func ConvertHealthReportToMetrics(report *HealthReport) []Metric {
+	if report == nil {
+		return []Metric{}
+	}
+
+	metrics := make([]Metric, 0, 4)
+	for _, m := range []struct {
+		name  string
+		value *float64
+		unit  string
+	}{
+		{"estimated-capacity", report.EstimatedCapacityAh, "amp-hours"},
+		{"estimated-soh", report.EstimatedSOH, "percent"},
+		{"soh-discrepancy", report.SOHDiscrepancy, "percent"},
+		{"capacity-fade", report.FadePerYear, "percent-per-year"},
+	} {
+		if m.value != nil {
+			metrics = append(metrics, Metric{
+				Name:      m.name,
+				Value:     *m.value,
+				Unit:      m.unit,
+				Timestamp: report.Timestamp,
+			})
+		}
+	}
+	return metrics
+}
+
+// ConvertConnectionToMetrics converts the connection stats into the
+// reconnect count, and the connect latency when the connection is new this
+// cycle.
+func ConvertConnectionToMetrics(stats ConnectionStats, newConnection bool, timestamp int64) []Metric {
+	metrics := []Metric{
+		{
+			Name:      "reconnect-count",
+			Value:     stats.Reconnects,
+			Unit:      "count",
+			Timestamp: timestamp,
+		},
+	}
+	if newConnection {
+		metrics = append(metrics, Metric{
+			Name:      "connect-latency",
+			Value:     stats.ConnectLatency.Seconds(),
+			Unit:      "seconds",
+			Timestamp: timestamp,
+		})
+	}
+	return metrics
+}
+
+// ConvertBankToMetrics converts the bank aggregates into individual metrics,
+// each prefixed with bank-.
+func ConvertBankToMetrics(bank *BankStatus) []Metric {
+	if bank == nil {
+		return []Metric{}
+	}
+
+	timestamp := bank.Timestamp
+
+	return []Metric{
+		{
+			Name:      bankName + "-current",
+			Value:     bank.Current,
+			Unit:      "amperes",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      bankName + "-power",
+			Value:     bank.Power,
+			Unit:      "watts",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      bankName + "-soc",
+			Value:     bank.SOC,
+			Unit:      "percent",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      bankName + "-min-cell-voltage",
+			Value:     bank.MinCellVoltage,
+			Unit:      "volts",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      bankName + "-max-cell-voltage",
+			Value:     bank.MaxCellVoltage,
+			Unit:      "volts",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      bankName + "-voltage-imbalance",
+			Value:     bank.VoltageImbalance,
+			Unit:      "volts",
+			Timestamp: timestamp,
+		},
+		{
+			Name:      bankName + "-batteries-reporting",
+			Value:     len(bank.Batteries),
+			Unit:      "count",
+			Timestamp: timestamp,
+		},
+	}
+}
+
+// ConvertRuntimeToMetrics converts the runtime estimate into time-to-full
+// while charging or time-to-empty while discharging, with the smoothed
+// current the estimate is from and the confidence in it.
+func ConvertRuntimeToMetrics(estimate *estimator.Estimate) []Metric {
+	if estimate == nil {
+		return []Metric{}
+	}
+
+	timestamp := estimate.Timestamp
+	metrics := make([]Metric, 0, 3)
+	if estimate.TimeToFull != nil {
+		metrics = append(metrics, Metric{
+			Name:      "time-to-full",
+			Value:     *estimate.TimeToFull,
+			Unit:      "seconds",
+			Timestamp: timestamp,
+		})
+	}
+	if estimate.TimeToEmpty != nil {
+		metrics = append(metrics, Metric{
+			Name:      "time-to-empty",
+			Value:     *estimate.TimeToEmpty,
+			Unit:      "seconds",
+			Timestamp: timestamp,
+		})
+	}
+	return append(metrics,
+		Metric{
+			Name:      "runtime-current",
+			Value:     estimate.Current,
+			Unit:      "amperes",
+			Timestamp: timestamp,
+		},
+		Metric{
+			Name:      "runtime-confidence",
+			Value:     estimate.Confidence,
+			Unit:      "percent",
+			Timestamp: timestamp,
+		},
+	)
+}
+
+// ConvertBankRuntimeToMetrics is ConvertRuntimeToMetrics for the bank, each
+// metric prefixed with bank-.
+func ConvertBankRuntimeToMetrics(estimate *estimator.Estimate) []Metric {
+	metrics := ConvertRuntimeToMetrics(estimate)
+	for i := range metrics {
+		metrics[i].Name = bankName + "-" + metrics[i].Name
+	}
+	return metrics
+}
+
 // CreateCollectionFailureMetric creates a failure metric when collection fails
 func CreateCollectionFailureMetric() Metric {
 	return Metric{
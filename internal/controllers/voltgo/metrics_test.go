@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"testing"
 	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/estimator"
 )
 
 func TestConvertStatusToMetrics(t *testing.T) {
@@ -86,6 +88,140 @@ func TestConvertStatusToMetrics(t *testing.T) {
 	})
 }
 
+func TestConvertCellsToMetrics(t *testing.T) {
+	status := &BatteryStatus{
+		Timestamp: 1699000000,
+		Cells: []Cell{
+			{Index: 1, Voltage: 3.320},
+			{Index: 2, Voltage: 3.310},
+			{Index: 3, Voltage: 3.330},
+			{Index: 4, Voltage: 3.320},
+		},
+	}
+
+	var sum float64
+	for _, cell := range status.Cells {
+		sum += cell.Voltage
+	}
+
+	summary := []struct {
+		name  string
+		value any
+		unit  string
+	}{
+		{"cell-voltage-min", 3.310, "volts"},
+		{"cell-voltage-max", 3.330, "volts"},
+		{"cell-voltage-mean", sum / 4, "volts"},
+		{"cell-index-min", 2, "index"},
+		{"cell-index-max", 3, "index"},
+	}
+	checkSummary := func(t *testing.T, metrics []Metric) {
+		t.Helper()
+		for i, w := range summary {
+			m := metrics[i]
+			if m.Name != w.name || m.Value != w.value || m.Unit != w.unit {
+				t.Errorf("metrics[%d] = %s %v %s, want %s %v %s", i, m.Name, m.Value, m.Unit, w.name, w.value, w.unit)
+			}
+			if m.Series != "" || m.Labels != nil {
+				t.Errorf("%s should not be part of a series", m.Name)
+			}
+		}
+	}
+
+	t.Run("topics publish a labelled metric per cell", func(t *testing.T) {
+		metrics := ConvertCellsToMetrics(status, CellsTopics)
+		if len(metrics) != len(summary)+len(status.Cells) {
+			t.Fatalf("metrics length = %d, want %d", len(metrics), len(summary)+len(status.Cells))
+		}
+		checkSummary(t, metrics)
+
+		cell := metrics[len(summary)+2]
+		if cell.Name != "cell-voltage-3" || cell.Value != 3.330 || cell.Unit != "volts" {
+			t.Errorf("cell metric = %s %v %s, want cell-voltage-3 3.33 volts", cell.Name, cell.Value, cell.Unit)
+		}
+		if cell.Series != "cell-voltage" || cell.Labels["cell"] != "3" {
+			t.Errorf("cell series = %s %v, want cell-voltage with cell 3", cell.Series, cell.Labels)
+		}
+		if cell.Timestamp != status.Timestamp {
+			t.Errorf("cell Timestamp = %d, want %d", cell.Timestamp, status.Timestamp)
+		}
+	})
+
+	t.Run("array publishes one metric holding every cell", func(t *testing.T) {
+		metrics := ConvertCellsToMetrics(status, CellsArray)
+		if len(metrics) != len(summary)+1 {
+			t.Fatalf("metrics length = %d, want %d", len(metrics), len(summary)+1)
+		}
+		checkSummary(t, metrics)
+
+		array := metrics[len(summary)]
+		if array.Name != "cell-voltages" || array.Series != "cell-voltage" || array.Unit != "volts" {
+			t.Errorf("array metric = %s (series %s) %s, want cell-voltages (series cell-voltage) volts", array.Name, array.Series, array.Unit)
+		}
+		cells, ok := array.Value.([]CellVoltage)
+		if !ok || len(cells) != len(status.Cells) {
+			t.Fatalf("array value = %v, want %d cells", array.Value, len(status.Cells))
+		}
+		if cells[0] != (CellVoltage{Cell: 1, Value: 3.320}) {
+			t.Errorf("cells[0] = %+v, want cell 1 at 3.320", cells[0])
+		}
+	})
+
+	t.Run("nothing when off, without a status or without cells", func(t *testing.T) {
+		for name, metrics := range map[string][]Metric{
+			"off":      ConvertCellsToMetrics(status, CellsOff),
+			"nil":      ConvertCellsToMetrics(nil, CellsTopics),
+			"no cells": ConvertCellsToMetrics(&BatteryStatus{}, CellsArray),
+		} {
+			if len(metrics) != 0 {
+				t.Errorf("%s: metrics length = %d, want 0", name, len(metrics))
+			}
+		}
+	})
+}
+
+func TestConvertBankToMetrics(t *testing.T) {
+	bank := &BankStatus{
+		Timestamp:        1699000000,
+		Current:          -4,
+		Power:            -53.2,
+		SOC:              81.5,
+		MinCellVoltage:   3.301,
+		MaxCellVoltage:   3.342,
+		VoltageImbalance: 0.04,
+		Batteries:        []BankBattery{{Name: "house-a"}, {Name: "house-b"}},
+	}
+
+	metrics := ConvertBankToMetrics(bank)
+
+	want := []struct {
+		name  string
+		value any
+		unit  string
+	}{
+		{"bank-current", -4.0, "amperes"},
+		{"bank-power", -53.2, "watts"},
+		{"bank-soc", 81.5, "percent"},
+		{"bank-min-cell-voltage", 3.301, "volts"},
+		{"bank-max-cell-voltage", 3.342, "volts"},
+		{"bank-voltage-imbalance", 0.04, "volts"},
+		{"bank-batteries-reporting", 2, "count"},
+	}
+	if len(metrics) != len(want) {
+		t.Fatalf("metrics length = %d, want %d", len(metrics), len(want))
+	}
+	for i, w := range want {
+		m := metrics[i]
+		if m.Name != w.name || m.Unit != w.unit || m.Value != w.value || m.Timestamp != bank.Timestamp {
+			t.Errorf("metrics[%d] = %+v, want %s = %v %s", i, m, w.name, w.value, w.unit)
+		}
+	}
+
+	if got := ConvertBankToMetrics(nil); len(got) != 0 {
+		t.Errorf("nil bank gave %d metrics, want 0", len(got))
+	}
+}
+
 func TestMetricToJSON(t *testing.T) {
 	metric := Metric{
 		Name:      "battery-voltage",
@@ -113,6 +249,39 @@ func TestMetricToJSON(t *testing.T) {
 	if payload["timestamp"] != float64(1699000000) {
 		t.Errorf("timestamp = %v, want 1699000000", payload["timestamp"])
 	}
+	if _, ok := payload["metric"]; ok {
+		t.Error("metric should be omitted outside a series")
+	}
+	if _, ok := payload["labels"]; ok {
+		t.Error("labels should be omitted outside a series")
+	}
+}
+
+func TestMetricToJSON_Series(t *testing.T) {
+	metric := Metric{
+		Name:      "cell-voltage-2",
+		Value:     3.31,
+		Unit:      "volts",
+		Timestamp: 1699000000,
+		Series:    "cell-voltage",
+		Labels:    map[string]string{"cell": "2"},
+	}
+
+	jsonStr, err := metric.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON() error = %v", err)
+	}
+
+	var payload MetricPayload
+	if err := json.Unmarshal([]byte(jsonStr), &payload); err != nil {
+		t.Fatalf("failed to unmarshal JSON: %v", err)
+	}
+	if payload.Metric != "cell-voltage" {
+		t.Errorf("metric = %q, want cell-voltage", payload.Metric)
+	}
+	if payload.Labels["cell"] != "2" {
+		t.Errorf("labels = %v, want cell 2", payload.Labels)
+	}
 }
 
 func TestCreateCollectionFailureMetric(t *testing.T) {
@@ -133,3 +302,54 @@ func TestCreateCollectionFailureMetric(t *testing.T) {
 		t.Errorf("Timestamp = %d, want between %d and %d", metric.Timestamp, before, after)
 	}
 }
+
+func TestConvertConnectionToMetrics(t *testing.T) {
+	stats := ConnectionStats{Connects: 3, Reconnects: 2, ConnectLatency: 2500 * time.Millisecond}
+
+	metrics := ConvertConnectionToMetrics(stats, true, 1699000000)
+	if len(metrics) != 2 {
+		t.Fatalf("metrics length = %d, want 2", len(metrics))
+	}
+	if m := metrics[0]; m.Name != "reconnect-count" || m.Value != 2 || m.Unit != "count" || m.Timestamp != 1699000000 {
+		t.Errorf("metrics[0] = %+v, want reconnect-count 2", m)
+	}
+	if m := metrics[1]; m.Name != "connect-latency" || m.Value != 2.5 || m.Unit != "seconds" {
+		t.Errorf("metrics[1] = %+v, want connect-latency 2.5 seconds", m)
+	}
+
+	if metrics := ConvertConnectionToMetrics(stats, false, 1699000000); len(metrics) != 1 {
+		t.Errorf("metrics length = %d, want only the reconnect count without a new connection", len(metrics))
+	}
+}
+
+func TestConvertRuntimeToMetrics(t *testing.T) {
+	seconds := 5400.0
+	estimate := &estimator.Estimate{Timestamp: 1699000000, Current: -8, Confidence: 75, TimeToEmpty: &seconds}
+
+	metrics := ConvertRuntimeToMetrics(estimate)
+	want := []struct {
+		name  string
+		value any
+		unit  string
+	}{
+		{"time-to-empty", 5400.0, "seconds"},
+		{"runtime-current", -8.0, "amperes"},
+		{"runtime-confidence", 75.0, "percent"},
+	}
+	if len(metrics) != len(want) {
+		t.Fatalf("metrics length = %d, want %d", len(metrics), len(want))
+	}
+	for i, w := range want {
+		m := metrics[i]
+		if m.Name != w.name || m.Unit != w.unit || m.Value != w.value || m.Timestamp != estimate.Timestamp {
+			t.Errorf("metrics[%d] = %+v, want %s = %v %s", i, m, w.name, w.value, w.unit)
+		}
+	}
+
+	if m := ConvertBankRuntimeToMetrics(estimate)[0]; m.Name != "bank-time-to-empty" {
+		t.Errorf("bank metric = %s, want bank-time-to-empty", m.Name)
+	}
+	if got := ConvertRuntimeToMetrics(nil); len(got) != 0 {
+		t.Errorf("nil estimate gave %d metrics, want 0", len(got))
+	}
+}
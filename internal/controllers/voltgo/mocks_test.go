@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/lumberbarons/solar-controller/internal/estimator"
 	"github.com/lumberbarons/voltgo/battery"
 )
 
@@ -77,52 +78,107 @@ type MockMetricsCollector struct {
 	mu sync.RWMutex
 
 	// Function fields that can be set to customize behavior in tests
-	IncrementFailuresFunc func()
-	SetMetricsFunc        func(status *BatteryStatus)
+	IncrementFailuresFunc func(battery string)
+	SetMetricsFunc        func(battery string, status *BatteryStatus)
 
 	// Call tracking
-	FailuresCount   int
-	SetMetricsCalls []*BatteryStatus
+	FailuresCount        int
+	FailedBatteries      []string
+	SetMetricsCalls      []*BatteryStatus
+	SetCellAnalysisCalls []*CellAnalysis
+	SetEnergyCalls       []*EnergyStatus
+	EnergyAdded          EnergyTotals
+	SetHealthReportCalls []*HealthReport
+	SetConnectionCalls   []ConnectionStats
+	SetBankMetricsCalls  []*BankStatus
+	SetRuntimeCalls      []*estimator.Estimate
+	RuntimeBatteries     []string
 }
 
 // Verify MockMetricsCollector implements MetricsCollector
 var _ MetricsCollector = (*MockMetricsCollector)(nil)
 
-func (m *MockMetricsCollector) IncrementFailures() {
+func (m *MockMetricsCollector) IncrementFailures(battery string) {
 	m.mu.Lock()
 	m.FailuresCount++
+	m.FailedBatteries = append(m.FailedBatteries, battery)
 	m.mu.Unlock()
 
 	if m.IncrementFailuresFunc != nil {
-		m.IncrementFailuresFunc()
+		m.IncrementFailuresFunc(battery)
 	}
 }
 
-func (m *MockMetricsCollector) SetMetrics(status *BatteryStatus) {
+func (m *MockMetricsCollector) SetMetrics(battery string, status *BatteryStatus) {
 	m.mu.Lock()
 	m.SetMetricsCalls = append(m.SetMetricsCalls, status)
 	m.mu.Unlock()
 
 	if m.SetMetricsFunc != nil {
-		m.SetMetricsFunc(status)
+		m.SetMetricsFunc(battery, status)
 	}
 }
 
+func (m *MockMetricsCollector) SetCellAnalysis(_ string, analysis *CellAnalysis) {
+	m.mu.Lock()
+	m.SetCellAnalysisCalls = append(m.SetCellAnalysisCalls, analysis)
+	m.mu.Unlock()
+}
+
+func (m *MockMetricsCollector) SetEnergy(_ string, energy *EnergyStatus, added EnergyTotals) {
+	m.mu.Lock()
+	m.SetEnergyCalls = append(m.SetEnergyCalls, energy)
+	m.EnergyAdded.add(added)
+	m.mu.Unlock()
+}
+
+func (m *MockMetricsCollector) SetHealthReport(_ string, report *HealthReport) {
+	m.mu.Lock()
+	m.SetHealthReportCalls = append(m.SetHealthReportCalls, report)
+	m.mu.Unlock()
+}
+
+func (m *MockMetricsCollector) SetConnection(_ string, stats ConnectionStats) {
+	m.mu.Lock()
+	m.SetConnectionCalls = append(m.SetConnectionCalls, stats)
+	m.mu.Unlock()
+}
+
+func (m *MockMetricsCollector) SetBankMetrics(bank *BankStatus) {
+	m.mu.Lock()
+	m.SetBankMetricsCalls = append(m.SetBankMetricsCalls, bank)
+	m.mu.Unlock()
+}
+
+func (m *MockMetricsCollector) SetRuntime(battery string, estimate *estimator.Estimate) {
+	m.mu.Lock()
+	m.SetRuntimeCalls = append(m.SetRuntimeCalls, estimate)
+	m.RuntimeBatteries = append(m.RuntimeBatteries, battery)
+	m.mu.Unlock()
+}
+
 // MockBatteryConnector is a mock implementation of the BatteryConnector interface for testing.
 type MockBatteryConnector struct {
 	mu sync.RWMutex
 
 	// Function fields that can be set to customize behavior in tests
-	ConnectFunc func(ctx context.Context, address string) (BatteryClient, error)
-	CloseFunc   func() error
+	ConnectFunc      func(ctx context.Context, address string) (BatteryClient, error)
+	ScanFunc         func(ctx context.Context) ([]Device, error)
+	CloseFunc        func() error
+	ResetAdapterFunc func() error
 
 	// Call tracking
-	ConnectCalls []string
-	CloseCalls   int
+	ConnectCalls      []string
+	ScanCalls         int
+	CloseCalls        int
+	ResetAdapterCalls int
 }
 
-// Verify MockBatteryConnector implements BatteryConnector
-var _ BatteryConnector = (*MockBatteryConnector)(nil)
+// Verify MockBatteryConnector implements BatteryConnector and AdapterResetter
+var (
+	_ BatteryConnector = (*MockBatteryConnector)(nil)
+	_ AdapterResetter  = (*MockBatteryConnector)(nil)
+)
 
 func (m *MockBatteryConnector) Connect(ctx context.Context, address string) (BatteryClient, error) {
 	m.mu.Lock()
@@ -135,6 +191,17 @@ func (m *MockBatteryConnector) Connect(ctx context.Context, address string) (Bat
 	return nil, fmt.Errorf("Connect not implemented")
 }
 
+func (m *MockBatteryConnector) Scan(ctx context.Context) ([]Device, error) {
+	m.mu.Lock()
+	m.ScanCalls++
+	m.mu.Unlock()
+
+	if m.ScanFunc != nil {
+		return m.ScanFunc(ctx)
+	}
+	return nil, fmt.Errorf("Scan not implemented")
+}
+
 func (m *MockBatteryConnector) Close() error {
 	m.mu.Lock()
 	m.CloseCalls++
@@ -145,3 +212,14 @@ func (m *MockBatteryConnector) Close() error {
 	}
 	return nil
 }
+
+func (m *MockBatteryConnector) ResetAdapter() error {
+	m.mu.Lock()
+	m.ResetAdapterCalls++
+	m.mu.Unlock()
+
+	if m.ResetAdapterFunc != nil {
+		return m.ResetAdapterFunc()
+	}
+	return nil
+}
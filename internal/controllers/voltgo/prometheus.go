@@ -2,32 +2,78 @@ package voltgo
 
 import (
 	"strconv"
+	"sync"
 
+	"github.com/lumberbarons/solar-controller/internal/estimator"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// Per-battery metrics are labelled by battery. A single unnamed battery
+// has an empty label, which Prometheus treats as no label at all, so its
+// series are the same as before batteries were named.
 type PrometheusCollector struct {
-	failures prometheus.Counter
+	failures *prometheus.CounterVec
 
-	batteryVoltage prometheus.Gauge
-	batteryCurrent prometheus.Gauge
-	batteryPower   prometheus.Gauge
-	batterySoc     prometheus.Gauge
-	batterySoh     prometheus.Gauge
-	batteryTemp    prometheus.Gauge
+	batteryVoltage *prometheus.GaugeVec
+	batteryCurrent *prometheus.GaugeVec
+	batteryPower   *prometheus.GaugeVec
+	batterySoc     *prometheus.GaugeVec
+	batterySoh     *prometheus.GaugeVec
+	batteryTemp    *prometheus.GaugeVec
 
-	cellVoltageDelta prometheus.Gauge
+	cellVoltageDelta *prometheus.GaugeVec
 	cellVoltage      *prometheus.GaugeVec
+
+	chargedAh            *prometheus.CounterVec
+	dischargedAh         *prometheus.CounterVec
+	chargedKWh           *prometheus.CounterVec
+	dischargedKWh        *prometheus.CounterVec
+	roundTripEfficiency  *prometheus.GaugeVec
+	equivalentFullCycles *prometheus.GaugeVec
+
+	estimatedCapacity *prometheus.GaugeVec
+	estimatedSoh      *prometheus.GaugeVec
+	capacityFade      *prometheus.GaugeVec
+	endOfLife         *prometheus.GaugeVec
+
+	connectLatency *prometheus.GaugeVec
+	reconnects     *prometheus.CounterVec
+	adapterResets  *prometheus.CounterVec
+
+	// connections is the last ConnectionStats seen of each battery, to add
+	// what changed since to the counters
+	connectionsMutex sync.Mutex
+	connections      map[string]ConnectionStats
+
+	cellImbalanceScore *prometheus.GaugeVec
+	cellDrift          *prometheus.GaugeVec
+	cellFlag           *prometheus.GaugeVec
+
+	timeToFull        *prometheus.GaugeVec
+	timeToEmpty       *prometheus.GaugeVec
+	runtimeCurrent    *prometheus.GaugeVec
+	runtimeConfidence *prometheus.GaugeVec
+
+	bankCurrent            prometheus.Gauge
+	bankPower              prometheus.Gauge
+	bankSoc                prometheus.Gauge
+	bankMinCellVoltage     prometheus.Gauge
+	bankMaxCellVoltage     prometheus.Gauge
+	bankVoltageImbalance   prometheus.Gauge
+	bankBatteriesReporting prometheus.Gauge
 }
 
 func NewPrometheusCollector() *PrometheusCollector {
 	endpoint := &PrometheusCollector{
-		failures: promauto.NewCounter(prometheus.CounterOpts{
-			Namespace: namespace,
-			Name:      "read_failures",
-			Help:      "Number of errors while reading from the voltgo battery.",
-		}),
+		failures: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "read_failures",
+				Help:      "Number of errors while reading from the voltgo battery.",
+			},
+			[]string{"battery"},
+		),
 	}
 
 	// Initialize all metrics immediately to avoid race conditions
@@ -36,52 +82,51 @@ func NewPrometheusCollector() *PrometheusCollector {
 	return endpoint
 }
 
-func (v *PrometheusCollector) IncrementFailures() {
-	v.failures.Inc()
+func (v *PrometheusCollector) IncrementFailures(battery string) {
+	v.failures.WithLabelValues(battery).Inc()
 }
 
-func (v *PrometheusCollector) initializeMetrics() {
-	v.batteryVoltage = promauto.NewGauge(prometheus.GaugeOpts{
-		Namespace: namespace,
-		Name:      "battery_voltage",
-		Help:      "Battery pack voltage (V).",
-	})
-
-	v.batteryCurrent = promauto.NewGauge(prometheus.GaugeOpts{
-		Namespace: namespace,
-		Name:      "battery_current",
-		Help:      "Battery pack current (A), positive when charging, negative when discharging.",
-	})
-
-	v.batteryPower = promauto.NewGauge(prometheus.GaugeOpts{
-		Namespace: namespace,
-		Name:      "battery_power",
-		Help:      "Battery pack power (W), derived from voltage and current.",
-	})
-
-	v.batterySoc = promauto.NewGauge(prometheus.GaugeOpts{
-		Namespace: namespace,
-		Name:      "battery_soc",
-		Help:      "Battery state of charge (%).",
-	})
+func newBatteryGauge(name, help string) *prometheus.GaugeVec {
+	return promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      name,
+			Help:      help,
+		},
+		[]string{"battery"},
+	)
+}
 
-	v.batterySoh = promauto.NewGauge(prometheus.GaugeOpts{
-		Namespace: namespace,
-		Name:      "battery_soh",
-		Help:      "Battery state of health (%).",
-	})
+func newBatteryCounter(name, help string) *prometheus.CounterVec {
+	return promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      name,
+			Help:      help,
+		},
+		[]string{"battery"},
+	)
+}
 
-	v.batteryTemp = promauto.NewGauge(prometheus.GaugeOpts{
+func newBankGauge(name, help string) prometheus.Gauge {
+	return promauto.NewGauge(prometheus.GaugeOpts{
 		Namespace: namespace,
-		Name:      "battery_temp",
-		Help:      "Battery temperature (C).",
+		Subsystem: bankName,
+		Name:      name,
+		Help:      help,
 	})
+}
 
-	v.cellVoltageDelta = promauto.NewGauge(prometheus.GaugeOpts{
-		Namespace: namespace,
-		Name:      "cell_voltage_delta",
-		Help:      "Spread between the highest and lowest cell voltage (V).",
-	})
+func (v *PrometheusCollector) initializeMetrics() {
+	v.batteryVoltage = newBatteryGauge("battery_voltage", "Battery pack voltage (V).")
+	v.batteryCurrent = newBatteryGauge("battery_current",
+		"Battery pack current (A), positive when charging, negative when discharging.")
+	v.batteryPower = newBatteryGauge("battery_power", "Battery pack power (W), derived from voltage and current.")
+	v.batterySoc = newBatteryGauge("battery_soc", "Battery state of charge (%).")
+	v.batterySoh = newBatteryGauge("battery_soh", "Battery state of health (%).")
+	v.batteryTemp = newBatteryGauge("battery_temp", "Battery temperature (C).")
+	v.cellVoltageDelta = newBatteryGauge("cell_voltage_delta",
+		"Spread between the highest and lowest cell voltage (V).")
 
 	v.cellVoltage = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -89,20 +134,190 @@ func (v *PrometheusCollector) initializeMetrics() {
 			Name:      "cell_voltage",
 			Help:      "Individual cell voltage (V).",
 		},
-		[]string{"cell"},
+		[]string{"battery", "cell"},
+	)
+
+	v.chargedAh = newBatteryCounter("charged_amp_hours_total", "Charge into the battery over its lifetime (Ah).")
+	v.dischargedAh = newBatteryCounter("discharged_amp_hours_total", "Charge out of the battery over its lifetime (Ah).")
+	v.chargedKWh = newBatteryCounter("charged_kilowatt_hours_total", "Energy into the battery over its lifetime (kWh).")
+	v.dischargedKWh = newBatteryCounter("discharged_kilowatt_hours_total",
+		"Energy out of the battery over its lifetime (kWh).")
+	v.roundTripEfficiency = newBatteryGauge("round_trip_efficiency",
+		"Lifetime energy discharged over energy charged (%).")
+	v.equivalentFullCycles = newBatteryGauge("equivalent_full_cycles",
+		"Lifetime charge discharged over the battery's capacity.")
+
+	v.estimatedCapacity = newBatteryGauge("estimated_capacity_amp_hours",
+		"Usable capacity measured over recent discharges from full (Ah).")
+	v.estimatedSoh = newBatteryGauge("estimated_soh", "Estimated capacity over the rated capacity (%).")
+	v.capacityFade = newBatteryGauge("capacity_fade", "Trend of the battery's health (% of rated capacity lost a year).")
+	v.endOfLife = newBatteryGauge("end_of_life_timestamp_seconds",
+		"When the capacity fade trend reaches the end-of-life health, as a Unix timestamp.")
+
+	v.connectLatency = newBatteryGauge("connect_latency_seconds", "How long the last BLE connect took (s).")
+	v.reconnects = newBatteryCounter("reconnects_total", "BLE connections made after the first.")
+	v.adapterResets = newBatteryCounter("adapter_resets_total", "BLE adapter resets after failed connects.")
+
+	v.cellImbalanceScore = newBatteryGauge("cell_imbalance_score",
+		"Root mean square deviation of the cells from the pack mean over the cell history (V).")
+	v.cellDrift = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "cell_drift",
+			Help:      "Trend of a cell's deviation from the pack mean (V/day).",
+		},
+		[]string{"battery", "cell"},
+	)
+	v.cellFlag = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "cell_flag",
+			Help:      "Whether the cell analysis raises the flag on a cell (1) or not (0).",
+		},
+		[]string{"battery", "cell", "flag"},
 	)
+
+	// The bank's estimate is labelled battery="bank", a name no pack can have
+	v.timeToFull = newBatteryGauge("time_to_full_seconds",
+		"Estimated time until the battery is full at the smoothed charge current (s).")
+	v.timeToEmpty = newBatteryGauge("time_to_empty_seconds",
+		"Estimated time until the battery is down to the reserve SOC at the smoothed discharge current (s).")
+	v.runtimeCurrent = newBatteryGauge("runtime_current",
+		"Net current averaged over the runtime smoothing window (A), positive when charging.")
+	v.runtimeConfidence = newBatteryGauge("runtime_confidence",
+		"Confidence in the runtime estimate from how full and steady its smoothing window is (%).")
+
+	v.bankCurrent = newBankGauge("current", "Total current of all battery packs (A), positive when charging.")
+	v.bankPower = newBankGauge("power", "Total power of all battery packs (W).")
+	v.bankSoc = newBankGauge("soc", "Bank state of charge (%), weighted by each pack's energy capacity.")
+	v.bankMinCellVoltage = newBankGauge("min_cell_voltage", "Lowest cell voltage across all packs (V).")
+	v.bankMaxCellVoltage = newBankGauge("max_cell_voltage", "Highest cell voltage across all packs (V).")
+	v.bankVoltageImbalance = newBankGauge("voltage_imbalance",
+		"Spread between the highest and lowest pack voltage (V).")
+	v.bankBatteriesReporting = newBankGauge("batteries_reporting",
+		"Number of battery packs read in the last collection cycle.")
 }
 
-func (v *PrometheusCollector) SetMetrics(status *BatteryStatus) {
-	v.batteryVoltage.Set(status.Voltage)
-	v.batteryCurrent.Set(status.Current)
-	v.batteryPower.Set(status.Voltage * status.Current)
-	v.batterySoc.Set(float64(status.SOC))
-	v.batterySoh.Set(float64(status.SOH))
-	v.batteryTemp.Set(status.Temperature)
+func (v *PrometheusCollector) SetMetrics(battery string, status *BatteryStatus) {
+	v.batteryVoltage.WithLabelValues(battery).Set(status.Voltage)
+	v.batteryCurrent.WithLabelValues(battery).Set(status.Current)
+	v.batteryPower.WithLabelValues(battery).Set(status.Voltage * status.Current)
+	v.batterySoc.WithLabelValues(battery).Set(float64(status.SOC))
+	v.batterySoh.WithLabelValues(battery).Set(float64(status.SOH))
+	v.batteryTemp.WithLabelValues(battery).Set(status.Temperature)
 
-	v.cellVoltageDelta.Set(cellVoltageDelta(status.Cells))
+	v.cellVoltageDelta.WithLabelValues(battery).Set(cellVoltageDelta(status.Cells))
 	for _, cell := range status.Cells {
-		v.cellVoltage.WithLabelValues(strconv.Itoa(cell.Index)).Set(cell.Voltage)
+		v.cellVoltage.WithLabelValues(battery, strconv.Itoa(cell.Index)).Set(cell.Voltage)
+	}
+}
+
+func (v *PrometheusCollector) SetCellAnalysis(battery string, analysis *CellAnalysis) {
+	v.cellImbalanceScore.WithLabelValues(battery).Set(analysis.ImbalanceScore)
+	for _, cell := range analysis.Cells {
+		index := strconv.Itoa(cell.Index)
+		v.cellDrift.WithLabelValues(battery, index).Set(cell.Drift)
+
+		raised := make(map[string]bool, len(cell.Flags))
+		for _, flag := range cell.Flags {
+			raised[flag] = true
+		}
+		for _, flag := range cellFlags {
+			value := 0.0
+			if raised[flag] {
+				value = 1
+			}
+			v.cellFlag.WithLabelValues(battery, index, flag).Set(value)
+		}
+	}
+}
+
+func (v *PrometheusCollector) SetEnergy(battery string, energy *EnergyStatus, added EnergyTotals) {
+	v.chargedAh.WithLabelValues(battery).Add(added.ChargedAh)
+	v.dischargedAh.WithLabelValues(battery).Add(added.DischargedAh)
+	v.chargedKWh.WithLabelValues(battery).Add(added.ChargedKWh)
+	v.dischargedKWh.WithLabelValues(battery).Add(added.DischargedKWh)
+
+	if energy.RoundTripEfficiency != nil {
+		v.roundTripEfficiency.WithLabelValues(battery).Set(*energy.RoundTripEfficiency)
+	}
+	if energy.EquivalentFullCycles != nil {
+		v.equivalentFullCycles.WithLabelValues(battery).Set(*energy.EquivalentFullCycles)
+	}
+}
+
+func (v *PrometheusCollector) SetHealthReport(battery string, report *HealthReport) {
+	if report.EstimatedCapacityAh != nil {
+		v.estimatedCapacity.WithLabelValues(battery).Set(*report.EstimatedCapacityAh)
+	}
+	if report.EstimatedSOH != nil {
+		v.estimatedSoh.WithLabelValues(battery).Set(*report.EstimatedSOH)
+	}
+	if report.FadePerYear != nil {
+		v.capacityFade.WithLabelValues(battery).Set(*report.FadePerYear)
+	}
+	if report.EndOfLife != nil {
+		v.endOfLife.WithLabelValues(battery).Set(float64(*report.EndOfLife))
+	}
+}
+
+func (v *PrometheusCollector) SetConnection(battery string, stats ConnectionStats) {
+	if stats.Connects > 0 {
+		v.connectLatency.WithLabelValues(battery).Set(stats.ConnectLatency.Seconds())
+	}
+
+	v.connectionsMutex.Lock()
+	last := v.connections[battery]
+	if v.connections == nil {
+		v.connections = make(map[string]ConnectionStats)
+	}
+	v.connections[battery] = stats
+	v.connectionsMutex.Unlock()
+
+	v.reconnects.WithLabelValues(battery).Add(float64(increase(last.Reconnects, stats.Reconnects)))
+	v.adapterResets.WithLabelValues(battery).Add(float64(increase(last.AdapterResets, stats.AdapterResets)))
+}
+
+// increase is how much a count went up from last to now. A count lower than
+// last started over, so all of it is new.
+func increase(last, now int) int {
+	if now < last {
+		return now
+	}
+	return now - last
+}
+
+func (v *PrometheusCollector) SetBankMetrics(bank *BankStatus) {
+	v.bankCurrent.Set(bank.Current)
+	v.bankPower.Set(bank.Power)
+	v.bankSoc.Set(bank.SOC)
+	v.bankMinCellVoltage.Set(bank.MinCellVoltage)
+	v.bankMaxCellVoltage.Set(bank.MaxCellVoltage)
+	v.bankVoltageImbalance.Set(bank.VoltageImbalance)
+	v.bankBatteriesReporting.Set(float64(len(bank.Batteries)))
+}
+
+func (v *PrometheusCollector) SetRuntime(battery string, estimate *estimator.Estimate) {
+	if estimate == nil {
+		for _, gauge := range []*prometheus.GaugeVec{v.timeToFull, v.timeToEmpty, v.runtimeCurrent, v.runtimeConfidence} {
+			gauge.DeleteLabelValues(battery)
+		}
+		return
+	}
+
+	v.runtimeCurrent.WithLabelValues(battery).Set(estimate.Current)
+	v.runtimeConfidence.WithLabelValues(battery).Set(estimate.Confidence)
+
+	// Only the one that applies is kept, so a stale time-to-empty does not
+	// linger while charging
+	if estimate.TimeToFull != nil {
+		v.timeToFull.WithLabelValues(battery).Set(*estimate.TimeToFull)
+	} else {
+		v.timeToFull.DeleteLabelValues(battery)
+	}
+	if estimate.TimeToEmpty != nil {
+		v.timeToEmpty.WithLabelValues(battery).Set(*estimate.TimeToEmpty)
+	} else {
+		v.timeToEmpty.DeleteLabelValues(battery)
 	}
 }
@@ -2,7 +2,9 @@ package voltgo
 
 import (
 	"testing"
+	"time"
 
+	"github.com/lumberbarons/solar-controller/internal/estimator"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
@@ -30,20 +32,20 @@ func TestPrometheusCollector(t *testing.T) {
 			},
 		}
 
-		collector.SetMetrics(status)
+		collector.SetMetrics("", status)
 
 		checks := []struct {
 			name string
 			got  float64
 			want float64
 		}{
-			{"battery_voltage", testutil.ToFloat64(collector.batteryVoltage), 13.28},
-			{"battery_current", testutil.ToFloat64(collector.batteryCurrent), -2.5},
-			{"battery_power", testutil.ToFloat64(collector.batteryPower), status.Voltage * status.Current},
-			{"battery_soc", testutil.ToFloat64(collector.batterySoc), 87},
-			{"battery_soh", testutil.ToFloat64(collector.batterySoh), 100},
-			{"battery_temp", testutil.ToFloat64(collector.batteryTemp), 21.5},
-			{"cell_voltage_delta", testutil.ToFloat64(collector.cellVoltageDelta), status.Cells[2].Voltage - status.Cells[1].Voltage},
+			{"battery_voltage", testutil.ToFloat64(collector.batteryVoltage.WithLabelValues("")), 13.28},
+			{"battery_current", testutil.ToFloat64(collector.batteryCurrent.WithLabelValues("")), -2.5},
+			{"battery_power", testutil.ToFloat64(collector.batteryPower.WithLabelValues("")), status.Voltage * status.Current},
+			{"battery_soc", testutil.ToFloat64(collector.batterySoc.WithLabelValues("")), 87},
+			{"battery_soh", testutil.ToFloat64(collector.batterySoh.WithLabelValues("")), 100},
+			{"battery_temp", testutil.ToFloat64(collector.batteryTemp.WithLabelValues("")), 21.5},
+			{"cell_voltage_delta", testutil.ToFloat64(collector.cellVoltageDelta.WithLabelValues("")), status.Cells[2].Voltage - status.Cells[1].Voltage},
 		}
 
 		for _, c := range checks {
@@ -52,15 +54,210 @@ func TestPrometheusCollector(t *testing.T) {
 			}
 		}
 
-		if got := testutil.ToFloat64(collector.cellVoltage.WithLabelValues("2")); got != 3.322 {
+		if got := testutil.ToFloat64(collector.cellVoltage.WithLabelValues("", "2")); got != 3.322 {
 			t.Errorf("cell_voltage{cell=\"2\"} = %v, want 3.322", got)
 		}
 	})
 
-	t.Run("IncrementFailures increments the counter", func(t *testing.T) {
-		before := testutil.ToFloat64(collector.failures)
-		collector.IncrementFailures()
-		if got := testutil.ToFloat64(collector.failures); got != before+1 {
+	t.Run("SetMetrics labels a named battery", func(t *testing.T) {
+		collector.SetMetrics("house-a", &BatteryStatus{Voltage: 13.1, Cells: []Cell{{Index: 0, Voltage: 3.3}}})
+
+		if got := testutil.ToFloat64(collector.batteryVoltage.WithLabelValues("house-a")); got != 13.1 {
+			t.Errorf("battery_voltage{battery=\"house-a\"} = %v, want 13.1", got)
+		}
+		if got := testutil.ToFloat64(collector.cellVoltage.WithLabelValues("house-a", "0")); got != 3.3 {
+			t.Errorf("cell_voltage{battery=\"house-a\",cell=\"0\"} = %v, want 3.3", got)
+		}
+	})
+
+	t.Run("SetBankMetrics updates the bank gauges", func(t *testing.T) {
+		collector.SetBankMetrics(&BankStatus{
+			Current:          -4,
+			Power:            -53.2,
+			SOC:              81.5,
+			MinCellVoltage:   3.301,
+			MaxCellVoltage:   3.342,
+			VoltageImbalance: 0.04,
+			Batteries:        []BankBattery{{Name: "house-a"}, {Name: "house-b"}},
+		})
+
+		checks := []struct {
+			name string
+			got  float64
+			want float64
+		}{
+			{"bank_current", testutil.ToFloat64(collector.bankCurrent), -4},
+			{"bank_power", testutil.ToFloat64(collector.bankPower), -53.2},
+			{"bank_soc", testutil.ToFloat64(collector.bankSoc), 81.5},
+			{"bank_min_cell_voltage", testutil.ToFloat64(collector.bankMinCellVoltage), 3.301},
+			{"bank_max_cell_voltage", testutil.ToFloat64(collector.bankMaxCellVoltage), 3.342},
+			{"bank_voltage_imbalance", testutil.ToFloat64(collector.bankVoltageImbalance), 0.04},
+			{"bank_batteries_reporting", testutil.ToFloat64(collector.bankBatteriesReporting), 2},
+		}
+		for _, c := range checks {
+			if c.got != c.want {
+				t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+			}
+		}
+	})
+
+	t.Run("SetCellAnalysis sets the score, drift and flags", func(t *testing.T) {
+		collector.SetCellAnalysis("house-a", &CellAnalysis{
+			ImbalanceScore: 0.012,
+			Cells: []CellTrend{
+				{Index: 0, Drift: -0.001, Flags: []string{}},
+				{Index: 1, Drift: 0.008, Flags: []string{FlagHighAtTopOfCharge, FlagDrifting}},
+			},
+		})
+
+		checks := []struct {
+			name string
+			got  float64
+			want float64
+		}{
+			{"cell_imbalance_score", testutil.ToFloat64(collector.cellImbalanceScore.WithLabelValues("house-a")), 0.012},
+			{"cell_drift{cell=\"1\"}", testutil.ToFloat64(collector.cellDrift.WithLabelValues("house-a", "1")), 0.008},
+			{"cell_flag{cell=\"1\",high}", testutil.ToFloat64(collector.cellFlag.WithLabelValues("house-a", "1", FlagHighAtTopOfCharge)), 1},
+			{"cell_flag{cell=\"1\",low}", testutil.ToFloat64(collector.cellFlag.WithLabelValues("house-a", "1", FlagLowAtBottomOfDischarge)), 0},
+			{"cell_flag{cell=\"0\",drifting}", testutil.ToFloat64(collector.cellFlag.WithLabelValues("house-a", "0", FlagDrifting)), 0},
+		}
+		for _, c := range checks {
+			if c.got != c.want {
+				t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+			}
+		}
+	})
+
+	t.Run("SetEnergy adds to the counters and sets the gauges", func(t *testing.T) {
+		efficiency := 91.5
+		energy := &EnergyStatus{RoundTripEfficiency: &efficiency}
+		collector.SetEnergy("house-a", energy, EnergyTotals{ChargedAh: 40, DischargedAh: 2})
+		collector.SetEnergy("house-a", energy, EnergyTotals{ChargedAh: 2, ChargedKWh: 0.025})
+
+		checks := []struct {
+			name string
+			got  float64
+			want float64
+		}{
+			{"charged_amp_hours_total", testutil.ToFloat64(collector.chargedAh.WithLabelValues("house-a")), 42},
+			{"discharged_amp_hours_total", testutil.ToFloat64(collector.dischargedAh.WithLabelValues("house-a")), 2},
+			{"charged_kilowatt_hours_total", testutil.ToFloat64(collector.chargedKWh.WithLabelValues("house-a")), 0.025},
+			{"round_trip_efficiency", testutil.ToFloat64(collector.roundTripEfficiency.WithLabelValues("house-a")), 91.5},
+		}
+		for _, c := range checks {
+			if c.got != c.want {
+				t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+			}
+		}
+
+		cycles := 3.0
+		collector.SetEnergy("house-a", &EnergyStatus{EquivalentFullCycles: &cycles}, EnergyTotals{})
+		if got := testutil.ToFloat64(collector.equivalentFullCycles.WithLabelValues("house-a")); got != 3 {
+			t.Errorf("equivalent_full_cycles = %v, want 3", got)
+		}
+	})
+
+	t.Run("SetConnection sets the latency once connected and the counts", func(t *testing.T) {
+		collector.SetConnection("house-b", ConnectionStats{Reconnects: 0})
+		collector.SetConnection("house-a", ConnectionStats{
+			Connects: 4, Reconnects: 3, ConnectLatency: 1500 * time.Millisecond, AdapterResets: 1,
+		})
+
+		checks := []struct {
+			name string
+			got  float64
+			want float64
+		}{
+			{"connect_latency_seconds", testutil.ToFloat64(collector.connectLatency.WithLabelValues("house-a")), 1.5},
+			{"reconnects_total", testutil.ToFloat64(collector.reconnects.WithLabelValues("house-a")), 3},
+			{"adapter_resets_total", testutil.ToFloat64(collector.adapterResets.WithLabelValues("house-a")), 1},
+		}
+		for _, c := range checks {
+			if c.got != c.want {
+				t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+			}
+		}
+
+		// The counters add what changed since the last collect, and all of
+		// a count that started over
+		collector.SetConnection("house-a", ConnectionStats{Connects: 6, Reconnects: 5, AdapterResets: 1})
+		collector.SetConnection("house-a", ConnectionStats{Connects: 2, Reconnects: 1})
+		if got := testutil.ToFloat64(collector.reconnects.WithLabelValues("house-a")); got != 6 {
+			t.Errorf("reconnects_total = %v, want 6", got)
+		}
+		if got := testutil.ToFloat64(collector.adapterResets.WithLabelValues("house-a")); got != 1 {
+			t.Errorf("adapter_resets_total = %v, want 1", got)
+		}
+		if got := testutil.CollectAndCount(collector.connectLatency); got != 1 {
+			t.Errorf("connect_latency_seconds series = %d, want 1 for the battery that has connected", got)
+		}
+	})
+
+	t.Run("SetHealthReport sets the gauges that are known", func(t *testing.T) {
+		capacity, soh, fade := 95.0, 95.0, 2.5
+		endOfLife := int64(1900000000)
+		collector.SetHealthReport("house-a", &HealthReport{
+			EstimatedCapacityAh: &capacity,
+			EstimatedSOH:        &soh,
+			FadePerYear:         &fade,
+			EndOfLife:           &endOfLife,
+		})
+		collector.SetHealthReport("house-a", &HealthReport{})
+
+		checks := []struct {
+			name string
+			got  float64
+			want float64
+		}{
+			{"estimated_capacity_amp_hours", testutil.ToFloat64(collector.estimatedCapacity.WithLabelValues("house-a")), 95},
+			{"estimated_soh", testutil.ToFloat64(collector.estimatedSoh.WithLabelValues("house-a")), 95},
+			{"capacity_fade", testutil.ToFloat64(collector.capacityFade.WithLabelValues("house-a")), 2.5},
+			{"end_of_life_timestamp_seconds", testutil.ToFloat64(collector.endOfLife.WithLabelValues("house-a")), 1900000000},
+		}
+		for _, c := range checks {
+			if c.got != c.want {
+				t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+			}
+		}
+	})
+
+	t.Run("SetRuntime keeps only the estimate that applies", func(t *testing.T) {
+		seconds := 7200.0
+		collector.SetRuntime(bankName, &estimator.Estimate{Current: 10, Confidence: 80, TimeToFull: &seconds})
+		if got := testutil.ToFloat64(collector.timeToFull.WithLabelValues(bankName)); got != 7200 {
+			t.Errorf("time_to_full_seconds = %v, want 7200", got)
+		}
+		if got := testutil.ToFloat64(collector.runtimeConfidence.WithLabelValues(bankName)); got != 80 {
+			t.Errorf("runtime_confidence = %v, want 80", got)
+		}
+
+		collector.SetRuntime(bankName, &estimator.Estimate{Current: -5, Confidence: 60, TimeToEmpty: &seconds})
+		if got := testutil.CollectAndCount(collector.timeToFull); got != 0 {
+			t.Errorf("%d time_to_full_seconds series left while discharging, want 0", got)
+		}
+		if got := testutil.ToFloat64(collector.runtimeCurrent.WithLabelValues(bankName)); got != -5 {
+			t.Errorf("runtime_current = %v, want -5", got)
+		}
+
+		collector.SetRuntime(bankName, nil)
+		for _, gauge := range []struct {
+			name  string
+			count int
+		}{
+			{"time_to_empty_seconds", testutil.CollectAndCount(collector.timeToEmpty)},
+			{"runtime_current", testutil.CollectAndCount(collector.runtimeCurrent)},
+			{"runtime_confidence", testutil.CollectAndCount(collector.runtimeConfidence)},
+		} {
+			if gauge.count != 0 {
+				t.Errorf("%d %s series left without an estimate, want 0", gauge.count, gauge.name)
+			}
+		}
+	})
+
+	t.Run("IncrementFailures increments the battery's counter", func(t *testing.T) {
+		before := testutil.ToFloat64(collector.failures.WithLabelValues("house-b"))
+		collector.IncrementFailures("house-b")
+		if got := testutil.ToFloat64(collector.failures.WithLabelValues("house-b")); got != before+1 {
 			t.Errorf("read_failures = %v, want %v", got, before+1)
 		}
 	})
@@ -0,0 +1,41 @@
+package voltgo
+
+import (
+	"github.com/lumberbarons/solar-controller/internal/estimator"
+)
+
+// runtimeCapacity returns the capacity a pack's estimate is from, and where
+// it came from: the configured one, then the measured, then the rated.
+// Callers must hold the controller's stateMutex.
+func runtimeCapacity(b *batteryState, policy estimator.Policy) (float64, string) {
+	switch {
+	case policy.CapacityAh > 0:
+		return policy.CapacityAh, estimator.CapacityConfigured
+	case b.healthReport != nil && b.healthReport.EstimatedCapacityAh != nil:
+		return *b.healthReport.EstimatedCapacityAh, estimator.CapacityMeasured
+	case b.info != nil && b.info.CapacityAh > 0:
+		return b.info.CapacityAh, estimator.CapacityRated
+	}
+	return 0, ""
+}
+
+// bankCapacity returns the capacity of the packs collected this cycle, or
+// zero if any of them has none yet. Callers must hold the controller's
+// stateMutex.
+func bankCapacity(collected []*batteryState, policy estimator.Policy) (float64, string) {
+	var total float64
+	var source string
+	for i, b := range collected {
+		capacity, from := runtimeCapacity(b, policy)
+		if capacity <= 0 {
+			return 0, ""
+		}
+		total += capacity
+		if i == 0 {
+			source = from
+		} else if from != source {
+			source = estimator.CapacityMixed
+		}
+	}
+	return total, source
+}
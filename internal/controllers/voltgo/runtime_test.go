@@ -0,0 +1,118 @@
+package voltgo
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lumberbarons/solar-controller/internal/estimator"
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+)
+
+func TestRuntimeCapacity(t *testing.T) {
+	measured := 92.0
+	b := &batteryState{
+		info:         &BatteryInfo{CapacityAh: 100},
+		healthReport: &HealthReport{EstimatedCapacityAh: &measured},
+	}
+
+	if capacity, source := runtimeCapacity(b, estimator.Policy{CapacityAh: 200}); capacity != 200 || source != estimator.CapacityConfigured {
+		t.Errorf("runtimeCapacity() = %v %s, want the configured 200", capacity, source)
+	}
+	if capacity, source := runtimeCapacity(b, estimator.Policy{}); capacity != 92 || source != estimator.CapacityMeasured {
+		t.Errorf("runtimeCapacity() = %v %s, want the measured 92", capacity, source)
+	}
+	rated := &batteryState{info: &BatteryInfo{CapacityAh: 100}, healthReport: &HealthReport{}}
+	if capacity, source := runtimeCapacity(rated, estimator.Policy{}); capacity != 100 || source != estimator.CapacityRated {
+		t.Errorf("runtimeCapacity() = %v %s, want the rated 100", capacity, source)
+	}
+	if capacity, _ := runtimeCapacity(&batteryState{}, estimator.Policy{}); capacity != 0 {
+		t.Errorf("runtimeCapacity() = %v, want 0 with nothing known", capacity)
+	}
+
+	if capacity, source := bankCapacity([]*batteryState{b, rated}, estimator.Policy{}); capacity != 192 || source != estimator.CapacityMixed {
+		t.Errorf("bankCapacity() = %v %s, want 192 mixed", capacity, source)
+	}
+	if capacity, _ := bankCapacity([]*batteryState{b, {}}, estimator.Policy{}); capacity != 0 {
+		t.Errorf("bankCapacity() = %v, want 0 while a pack's capacity is unknown", capacity)
+	}
+}
+
+func TestConfiguration_GetRuntime(t *testing.T) {
+	configured := (&Configuration{Runtime: estimator.Config{ReserveSOC: 20, Smoothing: "15m", CapacityAh: 200}}).GetRuntime()
+	if want := (estimator.Policy{ReserveSOC: 20, Smoothing: 15 * time.Minute, CapacityAh: 200}); configured != want {
+		t.Errorf("GetRuntime() = %+v, want %+v", configured, want)
+	}
+}
+
+func TestController_Runtime(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("estimates each battery and returns it with the metrics", func(t *testing.T) {
+		collector, _, _ := newWorkingCollector()
+		publisher := &testutil.MockMessagePublisher{}
+		metrics := &MockMetricsCollector{}
+		controller := newControllerForTest(collector, publisher, metrics, "test-device-1")
+		controller.collectAndPublish()
+
+		topics := publishedTopics(publisher)
+		var timeToEmpty MetricPayload
+		if err := json.Unmarshal([]byte(topics["test-device-1/voltgo/time-to-empty"]), &timeToEmpty); err != nil {
+			t.Fatalf("time-to-empty payload: %v", err)
+		}
+		// 87% of the rated 100Ah at 2.5A
+		if want := 87.0 / 2.5 * 3600; math.Abs(timeToEmpty.Value.(float64)-want) > 1e-6 {
+			t.Errorf("time-to-empty = %v, want %v", timeToEmpty.Value, want)
+		}
+		if len(metrics.SetRuntimeCalls) != 1 || metrics.RuntimeBatteries[0] != "" {
+			t.Errorf("SetRuntime calls for %v, want one for the unnamed battery", metrics.RuntimeBatteries)
+		}
+
+		router := gin.New()
+		router.GET("/api/voltgo/metrics", controller.MetricsGet())
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/voltgo/metrics", nil))
+
+		var body struct {
+			Runtime *estimator.Estimate `json:"runtime"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatalf("metrics body: %v", err)
+		}
+		if body.Runtime == nil || body.Runtime.State != estimator.Discharging || body.Runtime.CapacitySource != estimator.CapacityRated {
+			t.Errorf("runtime = %+v, want discharging against the rated capacity", body.Runtime)
+		}
+	})
+
+	t.Run("estimates the bank from the packs read", func(t *testing.T) {
+		controller, _, publisher, metrics := newTestBank(t)
+		controller.collectAndPublish()
+
+		bank := controller.lastBank
+		if bank.Runtime == nil || bank.Runtime.CapacityAh != 200 || bank.Runtime.State != estimator.Charging {
+			t.Fatalf("bank runtime = %+v, want charging against the 200Ah of the two packs read", bank.Runtime)
+		}
+		// 30% of 200Ah at the 3A net of +5A and -2A
+		if want := 60.0 / 3 * 3600; *bank.Runtime.TimeToFull != want {
+			t.Errorf("bank time to full = %v, want %v", *bank.Runtime.TimeToFull, want)
+		}
+
+		topics := publishedTopics(publisher)
+		for _, topic := range []string{
+			"test-device-1/voltgo/bank-time-to-full",
+			"test-device-1/voltgo/bank-runtime-confidence",
+			"test-device-1/voltgo/house-b-time-to-empty",
+		} {
+			if _, ok := topics[topic]; !ok {
+				t.Errorf("nothing published to %s", topic)
+			}
+		}
+		if last := metrics.RuntimeBatteries[len(metrics.RuntimeBatteries)-1]; last != bankName {
+			t.Errorf("last SetRuntime for %q, want the bank", last)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package voltgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultScanWindow is how long a scan listens when not told otherwise.
+	// Voltgo packs advertise about once a second, so this hears each several
+	// times.
+	DefaultScanWindow = 10 * time.Second
+
+	// MaxScanWindow caps the scan window, since the adapter is not collecting
+	// while it scans.
+	MaxScanWindow = 60 * time.Second
+)
+
+// ErrAdapterBusy is returned by a scan that would share the Bluetooth
+// adapter with a collection cycle or another scan.
+var ErrAdapterBusy = errors.New("the Bluetooth adapter is busy collecting; try again in a few seconds")
+
+// Device is a Bluetooth LE device heard during a scan.
+type Device struct {
+	Address string `json:"address"`
+	Name    string `json:"name"`
+	RSSI    int    `json:"rssi"`
+
+	// Compatible is true when the advertised name is a Voltgo pack's
+	Compatible bool `json:"compatible"`
+}
+
+// voltgoNamePrefixes are the advertised names Voltgo packs use, in lower case.
+var voltgoNamePrefixes = []string{"voltgo", "vg-"}
+
+func isVoltgoName(name string) bool {
+	name = strings.ToLower(name)
+	for _, prefix := range voltgoNamePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseScanWindow parses a scan window duration. Empty means
+// DefaultScanWindow; anything outside (0, MaxScanWindow] is an error.
+func ParseScanWindow(s string) (time.Duration, error) {
+	if s == "" {
+		return DefaultScanWindow, nil
+	}
+	window, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid scan window %q: %w", s, err)
+	}
+	if window <= 0 || window > MaxScanWindow {
+		return 0, fmt.Errorf("scan window %s must be positive and at most %s", window, MaxScanWindow)
+	}
+	return window, nil
+}
+
+// ScanDevices listens for window and returns the devices heard, strongest
+// signal first. Unless all is set, only Voltgo-compatible devices are listed.
+func ScanDevices(ctx context.Context, connector BatteryConnector, window time.Duration, all bool) ([]Device, error) {
+	ctx, cancel := context.WithTimeout(ctx, window)
+	defer cancel()
+
+	heard, err := connector.Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan for voltgo batteries: %w", err)
+	}
+
+	devices := make([]Device, 0, len(heard))
+	for _, device := range heard {
+		device.Compatible = isVoltgoName(device.Name)
+		if all || device.Compatible {
+			devices = append(devices, device)
+		}
+	}
+	sort.SliceStable(devices, func(i, j int) bool {
+		if devices[i].RSSI != devices[j].RSSI {
+			return devices[i].RSSI > devices[j].RSSI
+		}
+		return devices[i].Address < devices[j].Address
+	})
+	return devices, nil
+}
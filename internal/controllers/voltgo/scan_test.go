@@ -0,0 +1,164 @@
+package voltgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseScanWindow(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", DefaultScanWindow, false},
+		{"5s", 5 * time.Second, false},
+		{"1m", time.Minute, false},
+		{"2m", 0, true},
+		{"0s", 0, true},
+		{"-1s", 0, true},
+		{"soon", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseScanWindow(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseScanWindow(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseScanWindow(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanDevices(t *testing.T) {
+	heard := []Device{
+		{Address: "AA:BB:CC:DD:EE:01", Name: "VOLTGO-100", RSSI: -71},
+		{Address: "11:22:33:44:55:66", Name: "Kitchen speaker", RSSI: -40},
+		{Address: "AA:BB:CC:DD:EE:02", Name: "VG-200", RSSI: -58},
+		{Address: "AA:BB:CC:DD:EE:03", RSSI: -90},
+	}
+
+	var hadDeadline bool
+	connector := &MockBatteryConnector{
+		ScanFunc: func(ctx context.Context) ([]Device, error) {
+			_, hadDeadline = ctx.Deadline()
+			return heard, nil
+		},
+	}
+
+	t.Run("lists compatible devices, strongest first", func(t *testing.T) {
+		devices, err := ScanDevices(context.Background(), connector, time.Second, false)
+		if err != nil {
+			t.Fatalf("ScanDevices() error = %v", err)
+		}
+		if !hadDeadline {
+			t.Error("scan context has no deadline; the window was not applied")
+		}
+		if len(devices) != 2 || devices[0].Address != "AA:BB:CC:DD:EE:02" || devices[1].Address != "AA:BB:CC:DD:EE:01" {
+			t.Fatalf("devices = %+v, want VG-200 then VOLTGO-100", devices)
+		}
+		if !devices[0].Compatible {
+			t.Error("VG-200 is not marked compatible")
+		}
+	})
+
+	t.Run("lists everything with all", func(t *testing.T) {
+		devices, err := ScanDevices(context.Background(), connector, time.Second, true)
+		if err != nil {
+			t.Fatalf("ScanDevices() error = %v", err)
+		}
+		if len(devices) != 4 || devices[0].Name != "Kitchen speaker" || devices[0].Compatible {
+			t.Errorf("devices = %+v, want all four with the speaker first and incompatible", devices)
+		}
+	})
+
+	t.Run("reports a scan failure", func(t *testing.T) {
+		failing := &MockBatteryConnector{
+			ScanFunc: func(context.Context) ([]Device, error) {
+				return nil, errors.New("adapter powered off")
+			},
+		}
+		if _, err := ScanDevices(context.Background(), failing, time.Second, false); err == nil {
+			t.Error("ScanDevices() should return the scan error")
+		}
+	})
+}
+
+// fakeScan advertises each device in turn, then blocks until stopped, as a
+// BLE adapter's Scan does.
+func fakeScan(advertisements []Device, scanErr error) (func(func(Device)) error, func() error) {
+	stopped := make(chan struct{})
+	scan := func(heard func(Device)) error {
+		for _, advertisement := range advertisements {
+			heard(advertisement)
+		}
+		<-stopped
+		return scanErr
+	}
+	stop := func() error {
+		close(stopped)
+		return nil
+	}
+	return scan, stop
+}
+
+func TestCollectAdvertisements(t *testing.T) {
+	t.Run("keeps each device once with its latest RSSI and name", func(t *testing.T) {
+		scan, stop := fakeScan([]Device{
+			{Address: "AA:BB:CC:DD:EE:01", Name: "VOLTGO-100", RSSI: -70},
+			{Address: "AA:BB:CC:DD:EE:01", RSSI: -64},
+			{Address: "AA:BB:CC:DD:EE:02", RSSI: -80},
+		}, nil)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		devices, err := collectAdvertisements(ctx, scan, stop)
+		if err != nil {
+			t.Fatalf("collectAdvertisements() error = %v", err)
+		}
+		if len(devices) != 2 {
+			t.Fatalf("devices = %+v, want two", devices)
+		}
+		for _, device := range devices {
+			if device.Address == "AA:BB:CC:DD:EE:01" && (device.Name != "VOLTGO-100" || device.RSSI != -64) {
+				t.Errorf("device = %+v, want VOLTGO-100 at -64", device)
+			}
+		}
+	})
+
+	t.Run("reports a scan that ends by itself", func(t *testing.T) {
+		scan := func(func(Device)) error { return errors.New("adapter removed") }
+		if _, err := collectAdvertisements(context.Background(), scan, func() error { return nil }); err == nil {
+			t.Error("collectAdvertisements() should report the early stop")
+		}
+	})
+
+	t.Run("reports a scan error on stop", func(t *testing.T) {
+		scan, stop := fakeScan(nil, errors.New("scan aborted"))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := collectAdvertisements(ctx, scan, stop); err == nil {
+			t.Error("collectAdvertisements() should report the scan error")
+		}
+	})
+
+	t.Run("reports a failure to stop", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		scan := func(func(Device)) error {
+			<-release
+			return nil
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		stop := func() error { return errors.New("not scanning") }
+		if _, err := collectAdvertisements(ctx, scan, stop); err == nil {
+			t.Error("collectAdvertisements() should report the stop error")
+		}
+	})
+}
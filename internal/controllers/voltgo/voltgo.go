@@ -2,14 +2,22 @@ package voltgo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"path/filepath"
+	"regexp"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-co-op/gocron"
+	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"github.com/lumberbarons/solar-controller/internal/energy"
+	"github.com/lumberbarons/solar-controller/internal/estimator"
+	"github.com/lumberbarons/solar-controller/internal/health"
 	"github.com/lumberbarons/solar-controller/internal/publish"
+	"github.com/lumberbarons/solar-controller/internal/state"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -18,31 +26,204 @@ const (
 
 	defaultConnectTimeout = 30 * time.Second
 
-	// collectTimeout bounds a full collection cycle: a BLE connect
-	// (default 30s) plus the status reads.
+	// collectTimeout bounds each battery's part of a collection cycle: a BLE
+	// connect (default 30s) plus the status reads. It is per battery so one
+	// unreachable pack cannot use up the time of those after it.
 	collectTimeout = 60 * time.Second
+
+	// bankName prefixes the bank aggregate metrics, so no battery may use it.
+	bankName = "bank"
 )
 
+// batteryNamePattern restricts battery names to what is safe as part of a
+// topic segment and converts cleanly to a Prometheus metric name.
+var batteryNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
 type Configuration struct {
-	Enabled       bool   `yaml:"enabled"`
-	Address       string `yaml:"address"`
-	PublishPeriod int    `yaml:"publishPeriod"`
+	Enabled bool `yaml:"enabled"`
+
+	// Address is the BLE address of a single battery, published without a
+	// name. Use Batteries for more than one.
+	Address string `yaml:"address"`
+
+	// Batteries lists named packs that share the BLE adapter. Their metrics
+	// are published as {name}-{metric}, alongside bank-{metric} aggregates.
+	Batteries []BatteryConfig `yaml:"batteries"`
+
+	PublishPeriod int `yaml:"publishPeriod"`
+
+	// OfflineAfter is how many failed collection cycles in a row publish the
+	// controller as offline (default: 3)
+	OfflineAfter int `yaml:"offlineAfter"`
 
 	// ConnectTimeout is the maximum time to wait for a BLE connection,
 	// as a duration string (default: 30s)
 	ConnectTimeout string `yaml:"connectTimeout"`
+
+	// Connection controls how long the BLE connection is held open and how
+	// failed connects are retried
+	Connection ConnectionConfig `yaml:"connection"`
+
+	// Cells opts in to publishing per-cell voltages
+	Cells CellsConfig `yaml:"cells"`
+
+	// Runtime tunes the time-to-full and time-to-empty estimates
+	Runtime estimator.Config `yaml:"runtime"`
+}
+
+// ConnectionConfig is the configured ConnectionPolicy. Holding the
+// connection open blocks the vendor app and, on some adapters, drains power.
+type ConnectionConfig struct {
+	// Mode is persistent (default), per-cycle, or idle to disconnect after
+	// IdleTimeout unused. With several batteries, each connection is always
+	// closed before the next opens.
+	Mode string `yaml:"mode"`
+
+	// IdleTimeout is a duration string (default: 30s)
+	IdleTimeout string `yaml:"idleTimeout"`
+
+	// MaxBackoff caps the wait between failed connects, as a duration
+	// string (default: 5m)
+	MaxBackoff string `yaml:"maxBackoff"`
+
+	// ResetAdapterAfter resets the BLE adapter after that many failed
+	// connects in a row (default: 0, never)
+	ResetAdapterAfter int `yaml:"resetAdapterAfter"`
+}
+
+// CellsConfig controls per-cell voltage publishing. Every cell is another
+// topic, so it is off by default.
+type CellsConfig struct {
+	// Publish is off (default), topics for a cell-voltage-{index} topic per
+	// cell, or array for a single cell-voltages topic holding every cell
+	Publish string `yaml:"publish"`
+
+	// PublishPeriod is how often, in seconds, the cells are published
+	// (default: every collection cycle). It is rounded to whole cycles.
+	PublishPeriod int `yaml:"publishPeriod"`
+}
+
+// mode returns the publish mode, CellsOff when unset.
+func (c CellsConfig) mode() string {
+	if c.Publish == "" {
+		return CellsOff
+	}
+	return c.Publish
+}
+
+// BatteryConfig gives a battery, identified by its BLE address, the name it
+// is published under.
+type BatteryConfig struct {
+	Name    string `yaml:"name"`
+	Address string `yaml:"address"`
 }
 
 // Validate checks the configuration for errors. Only called when enabled.
 func (c *Configuration) Validate() error {
+	if c.Address != "" && len(c.Batteries) > 0 {
+		return fmt.Errorf("set either address or batteries, not both")
+	}
+
+	names := make(map[string]bool, len(c.Batteries))
+	addresses := make(map[string]bool, len(c.Batteries))
+	for i, battery := range c.Batteries {
+		if battery.Address == "" {
+			return fmt.Errorf("battery %d has no address", i)
+		}
+		if !batteryNamePattern.MatchString(battery.Name) {
+			return fmt.Errorf("battery %s name %q must be lowercase letters, digits and dashes", battery.Address, battery.Name)
+		}
+		if battery.Name == bankName {
+			return fmt.Errorf("battery %s name %q is reserved for the bank aggregates", battery.Address, battery.Name)
+		}
+		if names[battery.Name] {
+			return fmt.Errorf("battery name %s is used more than once", battery.Name)
+		}
+		if addresses[battery.Address] {
+			return fmt.Errorf("battery address %s is listed more than once", battery.Address)
+		}
+		names[battery.Name] = true
+		addresses[battery.Address] = true
+	}
+
 	if c.ConnectTimeout != "" {
 		if _, err := time.ParseDuration(c.ConnectTimeout); err != nil {
 			return err
 		}
 	}
+
+	switch c.Connection.Mode {
+	case "", ConnectionPersistent, ConnectionPerCycle, ConnectionIdle:
+	default:
+		return fmt.Errorf("connection mode %q must be %s, %s or %s",
+			c.Connection.Mode, ConnectionPersistent, ConnectionPerCycle, ConnectionIdle)
+	}
+	for _, duration := range []struct{ name, value string }{
+		{"idle timeout", c.Connection.IdleTimeout},
+		{"max backoff", c.Connection.MaxBackoff},
+	} {
+		if duration.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(duration.value)
+		if err != nil {
+			return fmt.Errorf("connection %s: %w", duration.name, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("connection %s must be positive", duration.name)
+		}
+	}
+	if c.Connection.ResetAdapterAfter < 0 {
+		return fmt.Errorf("connection reset adapter after must not be negative")
+	}
+
+	switch c.Cells.mode() {
+	case CellsOff, CellsTopics, CellsArray:
+	default:
+		return fmt.Errorf("cells publish %q must be %s, %s or %s", c.Cells.Publish, CellsOff, CellsTopics, CellsArray)
+	}
+	if c.Cells.PublishPeriod < 0 {
+		return fmt.Errorf("cells publish period must not be negative")
+	}
+
+	if err := c.Runtime.Validate(); err != nil {
+		return fmt.Errorf("runtime %w", err)
+	}
 	return nil
 }
 
+// BatteryList returns the configured batteries. A lone Address is a single
+// battery with no name.
+func (c *Configuration) BatteryList() []BatteryConfig {
+	if c.Address != "" {
+		return []BatteryConfig{{Address: c.Address}}
+	}
+	return c.Batteries
+}
+
+// GetConnection returns the connection policy, with the connect timeout.
+// Durations that are unset or invalid take their defaults.
+func (c *Configuration) GetConnection() ConnectionPolicy {
+	policy := ConnectionPolicy{
+		Mode:              c.Connection.Mode,
+		Timeout:           c.GetConnectTimeout(),
+		ResetAdapterAfter: c.Connection.ResetAdapterAfter,
+	}
+	if d, err := time.ParseDuration(c.Connection.IdleTimeout); err == nil {
+		policy.IdleTimeout = d
+	}
+	if d, err := time.ParseDuration(c.Connection.MaxBackoff); err == nil {
+		policy.MaxBackoff = d
+	}
+	return policy.withDefaults()
+}
+
+// GetRuntime returns the runtime estimate policy. A smoothing window that is
+// unset or invalid takes its default.
+func (c *Configuration) GetRuntime() estimator.Policy {
+	return c.Runtime.Policy()
+}
+
 // GetConnectTimeout returns the configured connect timeout or the default (30s).
 func (c *Configuration) GetConnectTimeout() time.Duration {
 	if c.ConnectTimeout == "" {
@@ -55,29 +236,92 @@ func (c *Configuration) GetConnectTimeout() time.Duration {
 	return timeout
 }
 
+// Battery is one pack the controller collects from.
+type Battery struct {
+	// Name prefixes the battery's metrics; empty for a single unnamed battery
+	Name      string
+	Collector *Collector
+}
+
+// batteryState is a battery and what was last read from it. status, info,
+// energy, fade, cellAnalysis and healthReport are guarded by the
+// controller's stateMutex; cellHistory, cellsPublished and connects are
+// only touched by the collection cycle.
+type batteryState struct {
+	name           string
+	collector      *Collector
+	status         *BatteryStatus
+	info           *BatteryInfo
+	energy         *energyCounter
+	fade           *fadeTracker
+	healthReport   *HealthReport
+	cellHistory    cellHistory
+	cellAnalysis   *CellAnalysis
+	cellsPublished time.Time
+	currents       estimator.Window
+	runtime        *estimator.Estimate
+
+	// connects is the collector's connect count when last published, so a
+	// connect latency is only published for a new connection
+	connects int
+}
+
+// metricName prefixes a metric with the battery name, if it has one.
+func (b *batteryState) metricName(name string) string {
+	if b.name == "" {
+		return name
+	}
+	return b.name + "-" + name
+}
+
+// nameMetrics prefixes each metric, and the series it belongs to, with the
+// battery name.
+func (b *batteryState) nameMetrics(metrics []Metric) {
+	for i := range metrics {
+		metrics[i].Name = b.metricName(metrics[i].Name)
+		if metrics[i].Series != "" {
+			metrics[i].Series = b.metricName(metrics[i].Series)
+		}
+	}
+}
+
 type Controller struct {
-	collector           *Collector
+	batteries           []*batteryState
 	publisher           publish.MessagePublisher
 	prometheusCollector MetricsCollector
 	scheduler           *gocron.Scheduler
 	deviceID            string
-	lastStatus          *BatteryStatus
-	lastInfo            *BatteryInfo
-	lastStatusMutex     sync.RWMutex
+	publishPeriod       time.Duration
+	cells               CellsConfig
+	runtime             estimator.Policy
+	bankCurrents        estimator.Window
+	dataDir             string
+	energySaved         state.Throttle
+	healthSaved         state.Throttle
+	health              *health.Tracker
+	lastBank            *BankStatus
+	stateMutex          sync.RWMutex
+	last                controllers.Latest[Snapshot]
 	collectInProgress   bool
+	scanInProgress      bool
 	collectMutex        sync.Mutex
 }
 
 // NewController creates a new voltgo controller with dependency injection for testing.
-// For production use, call NewControllerFromConfig instead.
+// For production use, call NewControllerFromConfig instead. The energy totals
+// and health history are kept under dataDir; empty keeps them in memory only.
 func NewController(
-	collector *Collector,
+	batteries []Battery,
 	publisher publish.MessagePublisher,
 	prometheusCollector MetricsCollector,
 	deviceID string,
 	publishPeriod int,
+	offlineAfter int,
+	cells CellsConfig,
+	runtime estimator.Policy,
+	dataDir string,
 ) (*Controller, error) {
-	if collector == nil {
+	if len(batteries) == 0 {
 		return &Controller{}, nil
 	}
 
@@ -89,13 +333,23 @@ func NewController(
 	s := gocron.NewScheduler(time.UTC)
 
 	controller := &Controller{
-		collector:           collector,
+		batteries:           newBatteryStates(batteries, loadEnergy(dataDir), loadHealth(dataDir)),
 		publisher:           publisher,
 		prometheusCollector: prometheusCollector,
 		deviceID:            deviceID,
+		publishPeriod:       time.Duration(publishPeriod) * time.Second,
+		cells:               cells,
+		runtime:             runtime.WithDefaults(),
+		dataDir:             dataDir,
+		health:              health.NewTracker(publisher, deviceID, namespace, time.Duration(publishPeriod)*time.Second, offlineAfter),
 		scheduler:           s,
 	}
 
+	// Counters start from the saved lifetime totals
+	for _, b := range controller.batteries {
+		prometheusCollector.SetEnergy(b.name, b.energy.status(nil), b.energy.Lifetime)
+	}
+
 	_, err := s.Every(publishPeriod).Seconds().Do(controller.collectAndPublish)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start voltgo publisher %w", err)
@@ -109,54 +363,186 @@ func NewController(
 	return controller, nil
 }
 
-// newControllerForTest creates a Controller without starting the scheduler or background goroutine.
+// newBatteryStates pairs each battery with its saved energy counter and
+// fade tracker, or new ones.
+func newBatteryStates(
+	batteries []Battery,
+	counters map[string]*energyCounter,
+	trackers map[string]*fadeTracker,
+) []*batteryState {
+	states := make([]*batteryState, 0, len(batteries))
+	for _, battery := range batteries {
+		counter := counters[battery.Name]
+		if counter == nil {
+			counter = &energyCounter{}
+		}
+		tracker := trackers[battery.Name]
+		if tracker == nil {
+			tracker = &fadeTracker{}
+		}
+		states = append(states, &batteryState{
+			name:      battery.Name,
+			collector: battery.Collector,
+			energy:    counter,
+			fade:      tracker,
+		})
+	}
+	return states
+}
+
+// loadEnergy reads the saved energy counters, keyed by battery name.
+func loadEnergy(dataDir string) map[string]*energyCounter {
+	return loadBatteries[energyCounter](dataDir, energyFileName, "energy totals")
+}
+
+// loadHealth reads the saved fade trackers, keyed by battery name.
+func loadHealth(dataDir string) map[string]*fadeTracker {
+	return loadBatteries[fadeTracker](dataDir, healthFileName, "health history")
+}
+
+// loadBatteries reads a file of per-battery state under dataDir; one that
+// cannot be read starts over rather than keeping the batteries from being
+// collected.
+func loadBatteries[T any](dataDir, name, what string) map[string]*T {
+	saved := map[string]*T{}
+	if !state.Restore(dataDir, name, "voltgo "+what, &saved) {
+		return map[string]*T{}
+	}
+	return saved
+}
+
+// saveState writes the energy counters and the fade trackers, each at most
+// every state.SaveInterval unless forced.
+func (v *Controller) saveState(force bool) {
+	if v.dataDir == "" {
+		return
+	}
+	v.saveEnergy(force)
+	v.saveHealth(force)
+}
+
+func (v *Controller) saveEnergy(force bool) {
+	if !v.energySaved.Due(time.Now(), force) {
+		return
+	}
+
+	v.stateMutex.RLock()
+	counters := make(map[string]energyCounter, len(v.batteries))
+	for _, b := range v.batteries {
+		counters[b.name] = *b.energy
+	}
+	v.stateMutex.RUnlock()
+
+	if err := state.Save(filepath.Join(v.dataDir, energyFileName), counters); err != nil {
+		log.Errorf("failed to save voltgo energy totals: %s", err)
+	}
+}
+
+func (v *Controller) saveHealth(force bool) {
+	if !v.healthSaved.Due(time.Now(), force) {
+		return
+	}
+
+	v.stateMutex.RLock()
+	trackers := make(map[string]fadeTracker, len(v.batteries))
+	for _, b := range v.batteries {
+		trackers[b.name] = b.fade.clone()
+	}
+	v.stateMutex.RUnlock()
+
+	if err := state.Save(filepath.Join(v.dataDir, healthFileName), trackers); err != nil {
+		log.Errorf("failed to save voltgo health history: %s", err)
+	}
+}
+
+// energyGap is the longest interval between samples that is counted.
+func (v *Controller) energyGap() time.Duration {
+	return energy.MaxGap(v.publishPeriod)
+}
+
+// newControllerForTest creates a Controller for a single unnamed battery
+// without starting the scheduler or background goroutine.
 // This allows tests to call collectAndPublish synchronously without racing.
 func newControllerForTest(
 	collector *Collector,
 	publisher publish.MessagePublisher,
 	prometheusCollector MetricsCollector,
 	deviceID string,
+) *Controller {
+	return newBankControllerForTest([]Battery{{Collector: collector}}, publisher, prometheusCollector, deviceID)
+}
+
+// newBankControllerForTest is newControllerForTest for several batteries.
+func newBankControllerForTest(
+	batteries []Battery,
+	publisher publish.MessagePublisher,
+	prometheusCollector MetricsCollector,
+	deviceID string,
 ) *Controller {
 	if deviceID == "" {
 		deviceID = "controller-1"
 	}
 	return &Controller{
-		collector:           collector,
+		batteries:           newBatteryStates(batteries, nil, nil),
 		publisher:           publisher,
 		prometheusCollector: prometheusCollector,
 		deviceID:            deviceID,
+		runtime:             estimator.Policy{}.WithDefaults(),
+		health:              health.NewTracker(publisher, deviceID, namespace, 0, 0),
 	}
 }
 
 // NewControllerFromConfig creates a new voltgo controller from configuration.
 // This is the production entry point that creates all concrete dependencies.
-func NewControllerFromConfig(config Configuration, publisher publish.MessagePublisher, deviceID string) (*Controller, error) {
+// The energy totals and health history are saved under dataDir; empty keeps
+// them in memory only.
+func NewControllerFromConfig(config Configuration, publisher publish.MessagePublisher, deviceID, dataDir string) (*Controller, error) {
 	if !config.Enabled {
 		log.Info("voltgo disabled via configuration")
 		return &Controller{}, nil
 	}
 
-	if config.Address == "" {
+	configured := config.BatteryList()
+	if len(configured) == 0 {
 		log.Warn("voltgo enabled but no battery address provided")
 		return &Controller{}, nil
 	}
 
-	connector, err := NewBLEConnector()
+	bleConnector, err := NewBLEConnector()
 	if err != nil {
 		return nil, err
 	}
 
-	collector := NewCollector(connector, config.Address, config.GetConnectTimeout())
+	// The batteries share the one BLE adapter
+	connector := shareConnector(bleConnector, len(configured))
+	batteries := make([]Battery, 0, len(configured))
+	for _, battery := range configured {
+		batteries = append(batteries, Battery{
+			Name:      battery.Name,
+			Collector: NewCollector(connector, battery.Address, config.GetConnection()),
+		})
+		if battery.Name == "" {
+			log.Infof("voltgo battery configured at %s", battery.Address)
+		} else {
+			log.Infof("voltgo battery %s configured at %s", battery.Name, battery.Address)
+		}
+	}
 	prometheusCollector := NewPrometheusCollector()
 
-	log.Infof("voltgo battery configured at %s", config.Address)
+	if dataDir == "" {
+		log.Warn("no dataDir configured, voltgo energy totals and health history will start over on every restart")
+	}
 
 	return NewController(
-		collector,
+		batteries,
 		publisher,
 		prometheusCollector,
 		deviceID,
 		config.PublishPeriod,
+		config.OfflineAfter,
+		config.Cells,
+		config.GetRuntime(),
+		dataDir,
 	)
 }
 
@@ -168,6 +554,11 @@ func (v *Controller) collectAndPublish() {
 		v.collectMutex.Unlock()
 		return
 	}
+	if v.scanInProgress {
+		log.Warn("BLE scan in progress for voltgo controller, skipping this collection cycle")
+		v.collectMutex.Unlock()
+		return
+	}
 	v.collectInProgress = true
 	v.collectMutex.Unlock()
 
@@ -180,42 +571,155 @@ func (v *Controller) collectAndPublish() {
 
 	log.Debug("collecting and publishing metrics for voltgo controller")
 
+	// Batteries are read one after another: they share the BLE adapter, and
+	// with more than one each connection is closed before the next opens.
+	var errs []error
+	collected := make([]*batteryState, 0, len(v.batteries))
+	for _, b := range v.batteries {
+		if err := v.collectBattery(b); err != nil {
+			errs = append(errs, err)
+		} else {
+			collected = append(collected, b)
+		}
+		if len(v.batteries) > 1 {
+			b.collector.Disconnect()
+		} else {
+			b.collector.Release()
+		}
+	}
+
+	v.saveState(false)
+
+	if len(collected) == 0 {
+		v.health.Failure(errors.Join(errs...))
+		return
+	}
+
+	v.stateMutex.Lock()
+	bank := newBankStatus(collected, v.batteries)
+	v.bankCurrents.Add(bank.Timestamp, bank.Current, v.runtime.Smoothing)
+	capacity, source := bankCapacity(collected, v.runtime)
+	bank.Runtime = v.bankCurrents.Estimate(bank.Timestamp, bank.SOC, capacity, source, v.runtime)
+	v.lastBank = bank
+	v.last.Set(v.snapshot())
+	v.stateMutex.Unlock()
+
+	// A single battery is its own bank, so the aggregates would only repeat it
+	if len(v.batteries) > 1 {
+		v.prometheusCollector.SetBankMetrics(bank)
+		v.prometheusCollector.SetRuntime(bankName, bank.Runtime)
+		v.publishMetrics(append(ConvertBankToMetrics(bank), ConvertBankRuntimeToMetrics(bank.Runtime)...))
+	}
+
+	v.health.Success()
+
+	log.Debug("collection done for voltgo controller")
+}
+
+// collectBattery reads one battery and publishes its metrics, or its
+// collection failure.
+func (v *Controller) collectBattery(b *batteryState) error {
 	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
 	defer cancel()
 
-	status, err := v.collector.GetStatus(ctx)
+	status, err := b.collector.GetStatus(ctx)
+	connection := b.collector.ConnectionStats()
+	v.prometheusCollector.SetConnection(b.name, connection)
 	if err != nil {
-		log.Errorf("failed to collect metrics from voltgo battery: %s", err)
-		v.prometheusCollector.IncrementFailures()
-
-		// Publish failure metric to message broker
-		failureMetric := CreateCollectionFailureMetric()
-		payload, err := failureMetric.ToJSON()
-		if err != nil {
-			log.Errorf("failed to marshal failure metric: %s", err)
-			return
+		if b.name == "" {
+			log.Errorf("failed to collect metrics from voltgo battery: %s", err)
+		} else {
+			log.Errorf("failed to collect metrics from voltgo battery %s: %s", b.name, err)
 		}
+		v.prometheusCollector.IncrementFailures(b.name)
 
-		topicSuffix := fmt.Sprintf("%s/%s/%s", v.deviceID, namespace, failureMetric.Name)
-		v.publisher.Publish(topicSuffix, payload)
-		log.Debugf("published failure metric to %s", topicSuffix)
+		// Publish failure metric to message broker
+		failureMetric := []Metric{CreateCollectionFailureMetric()}
+		b.nameMetrics(failureMetric)
+		v.publishMetrics(failureMetric)
 
-		return
+		return err
 	}
 
-	v.lastStatusMutex.Lock()
-	v.lastStatus = status
-	v.lastStatusMutex.Unlock()
+	b.cellHistory.add(status)
+	analysis := b.cellHistory.analyze()
+
+	v.stateMutex.Lock()
+	b.status = status
+	b.cellAnalysis = analysis
+	v.stateMutex.Unlock()
 
-	v.prometheusCollector.SetMetrics(status)
+	v.prometheusCollector.SetMetrics(b.name, status)
+	if analysis != nil {
+		v.prometheusCollector.SetCellAnalysis(b.name, analysis)
+	}
 
 	// Fetch static battery info once, now that a connection is up
-	v.fetchInfoOnce(ctx)
+	v.fetchInfoOnce(ctx, b)
+
+	v.stateMutex.Lock()
+	added := b.energy.add(status, v.energyGap())
+	energyStatus := b.energy.status(b.info)
+	estimate := b.fade.add(status, added, v.energyGap())
+	report := b.fade.report(status, b.info)
+	b.healthReport = report
+	b.currents.Add(status.Timestamp, status.Current, v.runtime.Smoothing)
+	capacity, source := runtimeCapacity(b, v.runtime)
+	runtime := b.currents.Estimate(status.Timestamp, float64(status.SOC), capacity, source, v.runtime)
+	b.runtime = runtime
+	v.last.Set(v.snapshot())
+	v.stateMutex.Unlock()
+	v.prometheusCollector.SetEnergy(b.name, energyStatus, added)
+	v.prometheusCollector.SetHealthReport(b.name, report)
+	v.prometheusCollector.SetRuntime(b.name, runtime)
+	if estimate != nil {
+		logCapacityEstimate(b.name, estimate)
+	}
 
 	// Convert status to individual metrics
-	metrics := ConvertStatusToMetrics(status)
+	metrics := append(ConvertStatusToMetrics(status), ConvertCellAnalysisToMetrics(analysis)...)
+	metrics = append(metrics, ConvertEnergyToMetrics(energyStatus)...)
+	metrics = append(metrics, ConvertHealthReportToMetrics(report)...)
+	metrics = append(metrics, ConvertRuntimeToMetrics(runtime)...)
+	metrics = append(metrics, ConvertConnectionToMetrics(connection, connection.Connects != b.connects, status.Timestamp)...)
+	b.connects = connection.Connects
+	if now := time.Now(); v.cellsDue(b, now) {
+		metrics = append(metrics, ConvertCellsToMetrics(status, v.cells.mode())...)
+		b.cellsPublished = now
+	}
+	b.nameMetrics(metrics)
+	v.publishMetrics(metrics)
 
-	// Publish each metric individually
+	return nil
+}
+
+func logCapacityEstimate(battery string, estimate *CapacityEstimate) {
+	if battery == "" {
+		log.Infof("voltgo battery measured %.1fAh discharging from %d%% to %d%%",
+			estimate.CapacityAh, estimate.FromSOC, estimate.ToSOC)
+	} else {
+		log.Infof("voltgo battery %s measured %.1fAh discharging from %d%% to %d%%",
+			battery, estimate.CapacityAh, estimate.FromSOC, estimate.ToSOC)
+	}
+}
+
+// cellsDue reports whether a battery's cells are to be published with this
+// cycle's metrics. Cells are only read once a cycle, so a cycle that falls
+// up to half a collection period short of the cell period still counts;
+// otherwise scheduling jitter would skip every other one.
+func (v *Controller) cellsDue(b *batteryState, now time.Time) bool {
+	if v.cells.mode() == CellsOff {
+		return false
+	}
+	if b.cellsPublished.IsZero() {
+		return true
+	}
+	period := time.Duration(v.cells.PublishPeriod) * time.Second
+	return now.Sub(b.cellsPublished) >= period-v.publishPeriod/2
+}
+
+// publishMetrics publishes each metric individually.
+func (v *Controller) publishMetrics(metrics []Metric) {
 	for _, metric := range metrics {
 		payload, err := metric.ToJSON()
 		if err != nil {
@@ -229,51 +733,102 @@ func (v *Controller) collectAndPublish() {
 
 		log.Debugf("published metric %s to %s", metric.Name, topicSuffix)
 	}
+}
 
-	log.Debug("collection done for voltgo controller")
+// Scan lists nearby Voltgo batteries. It refuses with ErrAdapterBusy while
+// a collection cycle or another scan has the adapter, and collection cycles
+// that come due during the scan are skipped.
+func (v *Controller) Scan(ctx context.Context, window time.Duration, all bool) ([]Device, error) {
+	v.collectMutex.Lock()
+	if v.collectInProgress || v.scanInProgress {
+		v.collectMutex.Unlock()
+		return nil, ErrAdapterBusy
+	}
+	v.scanInProgress = true
+	v.collectMutex.Unlock()
+
+	defer func() {
+		v.collectMutex.Lock()
+		v.scanInProgress = false
+		v.collectMutex.Unlock()
+	}()
+
+	// The batteries share one adapter, so any collector's connector scans it
+	return ScanDevices(ctx, v.batteries[0].collector.connector, window, all)
 }
 
 // fetchInfoOnce caches static battery info on the first successful collection
 // cycle. Failures are logged but never fail the cycle - the next cycle retries.
-func (v *Controller) fetchInfoOnce(ctx context.Context) {
-	v.lastStatusMutex.RLock()
-	cached := v.lastInfo != nil
-	v.lastStatusMutex.RUnlock()
+func (v *Controller) fetchInfoOnce(ctx context.Context, b *batteryState) {
+	v.stateMutex.RLock()
+	cached := b.info != nil
+	v.stateMutex.RUnlock()
 	if cached {
 		return
 	}
 
-	info, err := v.collector.GetInfo(ctx)
+	info, err := b.collector.GetInfo(ctx)
 	if err != nil {
 		log.Warnf("failed to read voltgo battery info: %s", err)
 		return
 	}
 
-	v.lastStatusMutex.Lock()
-	v.lastInfo = info
-	v.lastStatusMutex.Unlock()
+	v.stateMutex.Lock()
+	b.info = info
+	v.stateMutex.Unlock()
 	log.Debugf("cached voltgo battery info: %s %.1fV %.0fAh", info.Chemistry, info.NominalVoltage, info.CapacityAh)
 }
 
+// battery resolves the ?battery= query parameter, defaulting to the first
+// configured battery. It answers 404 itself when the name is unknown.
+func (v *Controller) battery(c *gin.Context) (*batteryState, bool) {
+	name, ok := c.GetQuery("battery")
+	if !ok {
+		return v.batteries[0], true
+	}
+	for _, b := range v.batteries {
+		if b.name == name {
+			return b, true
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("no voltgo battery named %q", name)})
+	return nil, false
+}
+
 func (v *Controller) MetricsGet() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		v.lastStatusMutex.RLock()
-		status := v.lastStatus
-		v.lastStatusMutex.RUnlock()
+		b, ok := v.battery(c)
+		if !ok {
+			return
+		}
+
+		v.stateMutex.RLock()
+		status := b.status
+		runtime := b.runtime
+		v.stateMutex.RUnlock()
 
 		if status == nil {
 			c.JSON(http.StatusNoContent, gin.H{})
 			return
 		}
-		c.JSON(http.StatusOK, status)
+		c.JSON(http.StatusOK, struct {
+			*BatteryStatus
+			Health  health.Health       `json:"health"`
+			Runtime *estimator.Estimate `json:"runtime"`
+		}{status, v.Health(), runtime})
 	}
 }
 
 func (v *Controller) InfoGet() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		v.lastStatusMutex.RLock()
-		info := v.lastInfo
-		v.lastStatusMutex.RUnlock()
+		b, ok := v.battery(c)
+		if !ok {
+			return
+		}
+
+		v.stateMutex.RLock()
+		info := b.info
+		v.stateMutex.RUnlock()
 
 		if info == nil {
 			c.JSON(http.StatusNoContent, gin.H{})
@@ -283,8 +838,174 @@ func (v *Controller) InfoGet() gin.HandlerFunc {
 	}
 }
 
+// BankGet returns the aggregates across every battery from the last cycle.
+func (v *Controller) BankGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v.stateMutex.RLock()
+		bank := v.lastBank
+		v.stateMutex.RUnlock()
+
+		if bank == nil {
+			c.JSON(http.StatusNoContent, gin.H{})
+			return
+		}
+		c.JSON(http.StatusOK, bank)
+	}
+}
+
+// EnergyGet returns the charge and discharge accounting of the ?battery=
+// named, or the first, battery.
+func (v *Controller) EnergyGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		b, ok := v.battery(c)
+		if !ok {
+			return
+		}
+
+		v.stateMutex.RLock()
+		var status *EnergyStatus
+		if b.energy.Last != nil {
+			status = b.energy.status(b.info)
+		}
+		v.stateMutex.RUnlock()
+
+		if status == nil {
+			c.JSON(http.StatusNoContent, gin.H{})
+			return
+		}
+		c.JSON(http.StatusOK, status)
+	}
+}
+
+// HealthReportGet returns the capacity fade report of the ?battery= named,
+// or the first, battery.
+func (v *Controller) HealthReportGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		b, ok := v.battery(c)
+		if !ok {
+			return
+		}
+
+		v.stateMutex.RLock()
+		report := b.healthReport
+		v.stateMutex.RUnlock()
+
+		if report == nil {
+			c.JSON(http.StatusNoContent, gin.H{})
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// CellAnalysisGet returns the cell analysis of the ?battery= named, or the
+// first, battery.
+func (v *Controller) CellAnalysisGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		b, ok := v.battery(c)
+		if !ok {
+			return
+		}
+
+		v.stateMutex.RLock()
+		analysis := b.cellAnalysis
+		v.stateMutex.RUnlock()
+
+		if analysis == nil {
+			c.JSON(http.StatusNoContent, gin.H{})
+			return
+		}
+		c.JSON(http.StatusOK, analysis)
+	}
+}
+
+// ScanResponse is the body of GET /api/voltgo/scan.
+type ScanResponse struct {
+	// Window is how long the scan listened, in seconds
+	Window  float64  `json:"window"`
+	Devices []Device `json:"devices"`
+}
+
+// ScanGet scans for ?window= (default 10s) and lists the Voltgo batteries
+// heard, or every device with ?all=true.
+func (v *Controller) ScanGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		window, err := ParseScanWindow(c.Query("window"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		devices, err := v.Scan(c.Request.Context(), window, c.Query("all") == "true")
+		if errors.Is(err, ErrAdapterBusy) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, ScanResponse{Window: window.Seconds(), Devices: devices})
+	}
+}
+
+// Health reports how fresh the last status is.
+func (v *Controller) Health() health.Health {
+	if v.health == nil {
+		return health.Health{Availability: health.Unknown}
+	}
+	return v.health.Health()
+}
+
+// Name returns the controller's name, which its endpoints are under.
+func (v *Controller) Name() string {
+	return namespace
+}
+
+// Type returns the kind of equipment the controller reads.
+func (v *Controller) Type() string {
+	return controllers.TypeBattery
+}
+
+// Snapshot is the voltgo controller's latest data. The embedded status is the
+// first battery's, as GET /api/voltgo/metrics returns it. With several
+// batteries, Batteries has each one's by name and Bank the aggregates.
+type Snapshot struct {
+	*BatteryStatus
+	Runtime   *estimator.Estimate       `json:"runtime,omitempty"`
+	Batteries map[string]*BatteryStatus `json:"batteries,omitempty"`
+	Bank      *BankStatus               `json:"bank,omitempty"`
+}
+
+// Snapshot returns the last status of every battery, or nil before any has
+// been read.
+func (v *Controller) Snapshot() any {
+	return v.last.Snapshot()
+}
+
+// snapshot gathers the last status of every battery, or nil before any has
+// been read. Callers must hold the controller's stateMutex.
+func (v *Controller) snapshot() *Snapshot {
+	snapshot := &Snapshot{BatteryStatus: v.batteries[0].status, Runtime: v.batteries[0].runtime}
+	read := snapshot.BatteryStatus != nil
+	if len(v.batteries) > 1 {
+		snapshot.Bank = v.lastBank
+		snapshot.Batteries = make(map[string]*BatteryStatus, len(v.batteries))
+		for _, b := range v.batteries {
+			if b.status != nil {
+				snapshot.Batteries[b.name] = b.status
+				read = true
+			}
+		}
+	}
+	if !read {
+		return nil
+	}
+	return snapshot
+}
+
 func (v *Controller) RegisterEndpoints(r *gin.Engine) {
-	if v.collector == nil {
+	if len(v.batteries) == 0 {
 		return
 	}
 
@@ -292,10 +1013,15 @@ func (v *Controller) RegisterEndpoints(r *gin.Engine) {
 
 	r.GET(fmt.Sprintf("%s/metrics", prefix), v.MetricsGet())
 	r.GET(fmt.Sprintf("%s/info", prefix), v.InfoGet())
+	r.GET(fmt.Sprintf("%s/bank", prefix), v.BankGet())
+	r.GET(fmt.Sprintf("%s/cells/analysis", prefix), v.CellAnalysisGet())
+	r.GET(fmt.Sprintf("%s/energy", prefix), v.EnergyGet())
+	r.GET(fmt.Sprintf("%s/health-report", prefix), v.HealthReportGet())
+	r.GET(fmt.Sprintf("%s/scan", prefix), v.ScanGet())
 }
 
 func (v *Controller) Enabled() bool {
-	return v.collector != nil
+	return len(v.batteries) > 0
 }
 
 func (v *Controller) Close() error {
@@ -303,8 +1029,11 @@ func (v *Controller) Close() error {
 		v.scheduler.Stop()
 		log.Debug("voltgo scheduler stopped")
 	}
-	if v.collector != nil {
-		return v.collector.Close()
+	v.saveState(true)
+
+	var errs []error
+	for _, b := range v.batteries {
+		errs = append(errs, b.collector.Close())
 	}
-	return nil
+	return errors.Join(errs...)
 }
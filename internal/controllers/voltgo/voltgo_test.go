@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lumberbarons/solar-controller/internal/controllers"
 	"github.com/lumberbarons/solar-controller/internal/testutil"
 	"github.com/lumberbarons/voltgo/battery"
 )
@@ -34,7 +35,7 @@ func newWorkingCollector() (*Collector, *MockBatteryClient, *MockBatteryConnecto
 			return mockBattery, nil
 		},
 	}
-	return NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", 10*time.Second), mockBattery, mockConnector
+	return NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", ConnectionPolicy{Timeout: 10 * time.Second}), mockBattery, mockConnector
 }
 
 func newFailingCollector() *Collector {
@@ -43,7 +44,74 @@ func newFailingCollector() *Collector {
 			return nil, errors.New("device not found")
 		},
 	}
-	return NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", 10*time.Second)
+	return NewCollector(mockConnector, "AA:BB:CC:DD:EE:FF", ConnectionPolicy{Timeout: 10 * time.Second})
+}
+
+// bankAdapter is a mock BLE adapter with one battery per address. It records
+// the most connections it had open at once, which must never exceed one.
+type bankAdapter struct {
+	connector *MockBatteryConnector
+	connected map[string]bool
+	maxOpen   int
+}
+
+func newBankAdapter(statuses map[string]*battery.Status) *bankAdapter {
+	adapter := &bankAdapter{connected: map[string]bool{}}
+
+	clients := make(map[string]*MockBatteryClient, len(statuses))
+	for address, status := range statuses {
+		clients[address] = &MockBatteryClient{
+			GetStatusFunc: func(_ context.Context) (*battery.Status, error) {
+				return status, nil
+			},
+			GetInfoFunc: func(_ context.Context) (*battery.Info, error) {
+				return &battery.Info{NominalVoltage: 12.8, CapacityAh: 100}, nil
+			},
+			IsConnectedFunc: func() bool { return adapter.connected[address] },
+			DisconnectFunc: func() error {
+				adapter.connected[address] = false
+				return nil
+			},
+		}
+	}
+
+	adapter.connector = &MockBatteryConnector{
+		ConnectFunc: func(_ context.Context, address string) (BatteryClient, error) {
+			client, ok := clients[address]
+			if !ok {
+				return nil, errors.New("device not found")
+			}
+			adapter.connected[address] = true
+
+			open := 0
+			for _, connected := range adapter.connected {
+				if connected {
+					open++
+				}
+			}
+			adapter.maxOpen = max(adapter.maxOpen, open)
+			return client, nil
+		},
+	}
+	return adapter
+}
+
+func (a *bankAdapter) battery(name, address string) Battery {
+	return Battery{Name: name, Collector: NewCollector(a.connector, address, ConnectionPolicy{Timeout: 10 * time.Second})}
+}
+
+func bankTestStatus(voltage, current float64, soc int) *battery.Status {
+	status := testBatteryStatus()
+	status.Voltage, status.Current, status.SOC = voltage, current, soc
+	return status
+}
+
+func publishedTopics(publisher *testutil.MockMessagePublisher) map[string]string {
+	topics := make(map[string]string, len(publisher.PublishCalls))
+	for _, call := range publisher.PublishCalls {
+		topics[call.TopicSuffix] = call.Payload
+	}
+	return topics
 }
 
 func TestController_CollectAndPublish(t *testing.T) {
@@ -96,8 +164,12 @@ func TestController_CollectAndPublish(t *testing.T) {
 			t.Errorf("Expected 1 SetMetrics call, got %d", len(mockMetrics.SetMetricsCalls))
 		}
 
-		if len(mockPublisher.PublishCalls) != 8 {
-			t.Fatalf("Expected 8 publish calls for normal metrics, got %d", len(mockPublisher.PublishCalls))
+		// 8 metrics, 2 from the cell analysis, 12 energy totals, the
+		// equivalent full cycles, the time to empty with its current and
+		// confidence, the reconnect count and connect latency, and the
+		// availability
+		if len(mockPublisher.PublishCalls) != 29 {
+			t.Fatalf("Expected 29 publish calls, got %d", len(mockPublisher.PublishCalls))
 		}
 
 		for _, call := range mockPublisher.PublishCalls {
@@ -109,7 +181,9 @@ func TestController_CollectAndPublish(t *testing.T) {
 		metricNames := []string{
 			"battery-voltage", "battery-current", "battery-power",
 			"battery-soc", "battery-soh", "battery-temp",
-			"cell-voltage-delta", "collection-time",
+			"cell-voltage-delta", "collection-time", "availability",
+			"cell-imbalance-score", "cells-flagged",
+			"reconnect-count", "connect-latency",
 		}
 		for _, expectedMetric := range metricNames {
 			found := false
@@ -153,14 +227,51 @@ func TestController_CollectAndPublish(t *testing.T) {
 			}
 		}
 
-		controller.lastStatusMutex.RLock()
-		lastStatus := controller.lastStatus
-		controller.lastStatusMutex.RUnlock()
+		controller.stateMutex.RLock()
+		lastStatus := controller.batteries[0].status
+		controller.stateMutex.RUnlock()
 		if lastStatus == nil {
 			t.Error("lastStatus should be cached after successful collection")
 		}
 	})
 
+	t.Run("publishes the connect latency only for a new connection", func(t *testing.T) {
+		collector, mockBattery, _ := newWorkingCollector()
+		mockMetrics := &MockMetricsCollector{}
+		mockPublisher := &testutil.MockMessagePublisher{}
+		controller := newControllerForTest(collector, mockPublisher, mockMetrics, "test-device-1")
+
+		latencies := func() int {
+			count := 0
+			for _, call := range mockPublisher.PublishCalls {
+				if call.TopicSuffix == "test-device-1/voltgo/connect-latency" {
+					count++
+				}
+			}
+			return count
+		}
+
+		controller.collectAndPublish()
+		controller.collectAndPublish()
+		if got := latencies(); got != 1 {
+			t.Errorf("connect-latency published %d times over two cycles on one connection, want 1", got)
+		}
+
+		// Per-cycle mode reconnects, and publishes, every cycle
+		collector.policy.Mode = ConnectionPerCycle
+		controller.collectAndPublish()
+		controller.collectAndPublish()
+		if got := latencies(); got != 2 {
+			t.Errorf("connect-latency published %d times, want 2 once per-cycle reconnects", got)
+		}
+		if mockBattery.DisconnectCalls != 2 {
+			t.Errorf("Disconnect calls = %d, want 2 in per-cycle mode", mockBattery.DisconnectCalls)
+		}
+		if calls := mockMetrics.SetConnectionCalls; len(calls) != 4 || calls[3].Reconnects != 1 {
+			t.Errorf("SetConnection calls = %+v, want 4 ending with 1 reconnect", calls)
+		}
+	})
+
 	t.Run("fetches battery info once and caches it", func(t *testing.T) {
 		collector, mockBattery, _ := newWorkingCollector()
 		controller := newControllerForTest(collector, &testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "test-device-1")
@@ -172,9 +283,9 @@ func TestController_CollectAndPublish(t *testing.T) {
 			t.Errorf("GetInfo calls = %d, want 1 (info should be cached after first fetch)", mockBattery.GetInfoCalls)
 		}
 
-		controller.lastStatusMutex.RLock()
-		info := controller.lastInfo
-		controller.lastStatusMutex.RUnlock()
+		controller.stateMutex.RLock()
+		info := controller.batteries[0].info
+		controller.stateMutex.RUnlock()
 		if info == nil {
 			t.Fatal("lastInfo should be cached")
 		}
@@ -198,8 +309,9 @@ func TestController_CollectAndPublish(t *testing.T) {
 		if mockMetrics.FailuresCount != 0 {
 			t.Errorf("Expected FailuresCount = 0, got %d", mockMetrics.FailuresCount)
 		}
-		if len(mockPublisher.PublishCalls) != 8 {
-			t.Errorf("Expected 8 publish calls, got %d", len(mockPublisher.PublishCalls))
+		// No equivalent full cycles without the capacity from the info
+		if len(mockPublisher.PublishCalls) != 25 {
+			t.Errorf("Expected 25 publish calls, got %d", len(mockPublisher.PublishCalls))
 		}
 
 		// Info fetch is retried on the next cycle after a failure
@@ -208,15 +320,289 @@ func TestController_CollectAndPublish(t *testing.T) {
 		}
 		controller.collectAndPublish()
 
-		controller.lastStatusMutex.RLock()
-		info := controller.lastInfo
-		controller.lastStatusMutex.RUnlock()
+		controller.stateMutex.RLock()
+		info := controller.batteries[0].info
+		controller.stateMutex.RUnlock()
 		if info == nil {
 			t.Error("lastInfo should be cached after retry")
 		}
 	})
 }
 
+func newTestBank(t *testing.T) (*Controller, *bankAdapter, *testutil.MockMessagePublisher, *MockMetricsCollector) {
+	t.Helper()
+
+	adapter := newBankAdapter(map[string]*battery.Status{
+		"AA:BB:CC:DD:EE:01": bankTestStatus(13.30, 5, 90),
+		"AA:BB:CC:DD:EE:02": bankTestStatus(13.26, -2, 50),
+	})
+	publisher := &testutil.MockMessagePublisher{}
+	metrics := &MockMetricsCollector{}
+	controller := newBankControllerForTest([]Battery{
+		adapter.battery("house-a", "AA:BB:CC:DD:EE:01"),
+		adapter.battery("house-b", "AA:BB:CC:DD:EE:02"),
+		// Not in range of the adapter
+		adapter.battery("house-c", "AA:BB:CC:DD:EE:03"),
+	}, publisher, metrics, "test-device-1")
+	return controller, adapter, publisher, metrics
+}
+
+func TestController_CollectAndPublishBank(t *testing.T) {
+	t.Run("reads each battery in turn and publishes the bank", func(t *testing.T) {
+		controller, adapter, publisher, metrics := newTestBank(t)
+		controller.collectAndPublish()
+
+		if adapter.maxOpen != 1 {
+			t.Errorf("%d connections were open at once, want 1", adapter.maxOpen)
+		}
+		for address, connected := range adapter.connected {
+			if connected {
+				t.Errorf("%s is still connected after the cycle", address)
+			}
+		}
+
+		topics := publishedTopics(publisher)
+		for _, topic := range []string{
+			"test-device-1/voltgo/house-a-battery-voltage",
+			"test-device-1/voltgo/house-b-battery-soc",
+			"test-device-1/voltgo/house-c-collection-failure",
+			"test-device-1/voltgo/bank-current",
+			"test-device-1/voltgo/bank-voltage-imbalance",
+			"test-device-1/voltgo/availability",
+		} {
+			if _, ok := topics[topic]; !ok {
+				t.Errorf("nothing published to %s", topic)
+			}
+		}
+		if _, ok := topics["test-device-1/voltgo/battery-voltage"]; ok {
+			t.Error("named batteries should not publish unprefixed metrics")
+		}
+
+		var soc MetricPayload
+		if err := json.Unmarshal([]byte(topics["test-device-1/voltgo/bank-soc"]), &soc); err != nil {
+			t.Fatalf("bank-soc payload: %v", err)
+		}
+		if soc.Value != float64(70) {
+			t.Errorf("bank-soc = %v, want 70", soc.Value)
+		}
+
+		if len(metrics.FailedBatteries) != 1 || metrics.FailedBatteries[0] != "house-c" {
+			t.Errorf("failures counted for %v, want [house-c]", metrics.FailedBatteries)
+		}
+		if len(metrics.SetBankMetricsCalls) != 1 {
+			t.Errorf("SetBankMetrics calls = %d, want 1", len(metrics.SetBankMetricsCalls))
+		}
+
+		// One pack missing does not take the controller offline
+		if h := controller.Health(); h.ConsecutiveFailures != 0 {
+			t.Errorf("Health() = %+v, want no failures while some packs report", h)
+		}
+	})
+
+	t.Run("fails the cycle only when no battery can be read", func(t *testing.T) {
+		adapter := newBankAdapter(nil)
+		publisher := &testutil.MockMessagePublisher{}
+		metrics := &MockMetricsCollector{}
+		controller := newBankControllerForTest([]Battery{
+			adapter.battery("house-a", "AA:BB:CC:DD:EE:01"),
+			adapter.battery("house-b", "AA:BB:CC:DD:EE:02"),
+		}, publisher, metrics, "test-device-1")
+
+		controller.collectAndPublish()
+
+		if metrics.FailuresCount != 2 {
+			t.Errorf("FailuresCount = %d, want 2", metrics.FailuresCount)
+		}
+		if len(metrics.SetBankMetricsCalls) != 0 {
+			t.Error("bank metrics set with no battery read")
+		}
+		for topic := range publishedTopics(publisher) {
+			if strings.Contains(topic, "/bank-") {
+				t.Errorf("published %s with no battery read", topic)
+			}
+		}
+		if h := controller.Health(); h.ConsecutiveFailures != 1 {
+			t.Errorf("ConsecutiveFailures = %d, want 1", h.ConsecutiveFailures)
+		}
+	})
+}
+
+func TestController_Snapshot(t *testing.T) {
+	t.Run("a single battery is its status", func(t *testing.T) {
+		collector, _, _ := newWorkingCollector()
+		controller := newControllerForTest(collector, &testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "")
+		if controller.Name() != "voltgo" || controller.Type() != controllers.TypeBattery {
+			t.Errorf("Name(), Type() = %q, %q, want voltgo, battery", controller.Name(), controller.Type())
+		}
+		if got := controller.Snapshot(); got != nil {
+			t.Errorf("Snapshot() before the first collection = %v, want nil", got)
+		}
+
+		controller.collectAndPublish()
+
+		snapshot, ok := controller.Snapshot().(*Snapshot)
+		if !ok {
+			t.Fatalf("Snapshot() = %T, want *Snapshot", controller.Snapshot())
+		}
+		if snapshot.BatteryStatus != controller.batteries[0].status {
+			t.Error("snapshot does not embed the battery's last status")
+		}
+		if snapshot.Batteries != nil || snapshot.Bank != nil {
+			t.Errorf("single battery snapshot has batteries %v and bank %v, want neither", snapshot.Batteries, snapshot.Bank)
+		}
+	})
+
+	t.Run("a bank has every battery read and the aggregates", func(t *testing.T) {
+		controller, _, _, _ := newTestBank(t)
+		controller.collectAndPublish()
+
+		snapshot, ok := controller.Snapshot().(*Snapshot)
+		if !ok {
+			t.Fatalf("Snapshot() = %T, want *Snapshot", controller.Snapshot())
+		}
+		if len(snapshot.Batteries) != 2 || snapshot.Batteries["house-a"] == nil || snapshot.Batteries["house-b"] == nil {
+			t.Errorf("snapshot batteries = %v, want house-a and house-b, not the unreachable house-c", snapshot.Batteries)
+		}
+		if snapshot.BatteryStatus != snapshot.Batteries["house-a"] {
+			t.Error("snapshot does not embed the first battery's status")
+		}
+		if snapshot.Bank == nil {
+			t.Error("snapshot has no bank aggregates")
+		}
+	})
+
+	t.Run("a disabled controller has none", func(t *testing.T) {
+		if got := (&Controller{}).Snapshot(); got != nil {
+			t.Errorf("Snapshot() = %v, want nil", got)
+		}
+	})
+}
+
+func TestController_PublishCells(t *testing.T) {
+	t.Run("topics are named and labelled by battery and cell", func(t *testing.T) {
+		adapter := newBankAdapter(map[string]*battery.Status{
+			"AA:BB:CC:DD:EE:01": bankTestStatus(13.30, 5, 90),
+		})
+		publisher := &testutil.MockMessagePublisher{}
+		controller := newBankControllerForTest([]Battery{
+			adapter.battery("house-a", "AA:BB:CC:DD:EE:01"),
+		}, publisher, &MockMetricsCollector{}, "test-device-1")
+		controller.cells = CellsConfig{Publish: CellsTopics}
+
+		controller.collectAndPublish()
+
+		topics := publishedTopics(publisher)
+		for _, metric := range []string{"cell-voltage-min", "cell-voltage-max", "cell-voltage-mean", "cell-index-min", "cell-index-max"} {
+			if _, ok := topics["test-device-1/voltgo/house-a-"+metric]; !ok {
+				t.Errorf("house-a-%s not published", metric)
+			}
+		}
+
+		payload, ok := topics["test-device-1/voltgo/house-a-cell-voltage-0"]
+		if !ok {
+			t.Fatal("house-a-cell-voltage-0 not published")
+		}
+		var cell MetricPayload
+		if err := json.Unmarshal([]byte(payload), &cell); err != nil {
+			t.Fatalf("failed to unmarshal cell payload: %v", err)
+		}
+		if cell.Metric != "house-a-cell-voltage" || cell.Labels["cell"] != "0" {
+			t.Errorf("cell payload series = %s %v, want house-a-cell-voltage with cell 0", cell.Metric, cell.Labels)
+		}
+	})
+
+	t.Run("cells follow their own period", func(t *testing.T) {
+		collector, _, _ := newWorkingCollector()
+		publisher := &testutil.MockMessagePublisher{}
+		controller := newControllerForTest(collector, publisher, &MockMetricsCollector{}, "test-device-1")
+		controller.publishPeriod = 60 * time.Second
+		controller.cells = CellsConfig{Publish: CellsArray, PublishPeriod: 300}
+
+		cellsPublished := func() bool {
+			_, ok := publishedTopics(publisher)["test-device-1/voltgo/cell-voltages"]
+			publisher.PublishCalls = nil
+			return ok
+		}
+
+		controller.collectAndPublish()
+		if !cellsPublished() {
+			t.Error("cells should be published on the first cycle")
+		}
+
+		controller.collectAndPublish()
+		if cellsPublished() {
+			t.Error("cells should not be published again before their period")
+		}
+
+		// A cycle a little early still counts, so jitter does not skip it
+		controller.batteries[0].cellsPublished = time.Now().Add(-290 * time.Second)
+		controller.collectAndPublish()
+		if !cellsPublished() {
+			t.Error("cells should be published once their period is up")
+		}
+	})
+
+	t.Run("off publishes no cells", func(t *testing.T) {
+		collector, _, _ := newWorkingCollector()
+		publisher := &testutil.MockMessagePublisher{}
+		controller := newControllerForTest(collector, publisher, &MockMetricsCollector{}, "test-device-1")
+
+		controller.collectAndPublish()
+
+		for topic := range publishedTopics(publisher) {
+			if strings.Contains(topic, "/cell-index-") ||
+				(strings.Contains(topic, "/cell-voltage") && !strings.HasSuffix(topic, "cell-voltage-delta")) {
+				t.Errorf("%s published with cells off", topic)
+			}
+		}
+	})
+}
+
+func TestController_Scan(t *testing.T) {
+	newScanningController := func() (*Controller, *MockBatteryConnector) {
+		collector, _, connector := newWorkingCollector()
+		connector.ScanFunc = func(context.Context) ([]Device, error) {
+			return []Device{{Address: "AA:BB:CC:DD:EE:01", Name: "VOLTGO-100", RSSI: -60}}, nil
+		}
+		return newControllerForTest(collector, &testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "test-device-1"), connector
+	}
+
+	t.Run("refuses while a collection cycle holds the adapter", func(t *testing.T) {
+		controller, connector := newScanningController()
+		controller.collectInProgress = true
+
+		if _, err := controller.Scan(context.Background(), time.Second, false); !errors.Is(err, ErrAdapterBusy) {
+			t.Errorf("Scan() error = %v, want ErrAdapterBusy", err)
+		}
+		if connector.ScanCalls != 0 {
+			t.Error("the adapter was scanned during a collection")
+		}
+	})
+
+	t.Run("collection skips its cycle while a scan runs", func(t *testing.T) {
+		controller, connector := newScanningController()
+		collected := make(chan int, 1)
+		connector.ScanFunc = func(context.Context) ([]Device, error) {
+			controller.collectAndPublish()
+			collected <- len(connector.ConnectCalls)
+			return nil, nil
+		}
+
+		if _, err := controller.Scan(context.Background(), time.Second, false); err != nil {
+			t.Fatalf("Scan() error = %v", err)
+		}
+		if n := <-collected; n != 0 {
+			t.Errorf("collection connected %d times during the scan, want 0", n)
+		}
+
+		// The adapter is free again afterwards
+		controller.collectAndPublish()
+		if len(connector.ConnectCalls) != 1 {
+			t.Errorf("collection after the scan connected %d times, want 1", len(connector.ConnectCalls))
+		}
+	})
+}
+
 func TestController_Endpoints(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -241,6 +627,108 @@ func TestController_Endpoints(t *testing.T) {
 		}
 	})
 
+	t.Run("cell analysis returns 204 before first collection and the analysis after", func(t *testing.T) {
+		collector, _, _ := newWorkingCollector()
+		controller := newControllerForTest(collector, &testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "test-device-1")
+		router := newRouter(controller)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/voltgo/cells/analysis", nil))
+		if w.Code != http.StatusNoContent {
+			t.Errorf("GET /api/voltgo/cells/analysis status = %d, want %d", w.Code, http.StatusNoContent)
+		}
+
+		controller.collectAndPublish()
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/voltgo/cells/analysis", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /api/voltgo/cells/analysis status = %d, want %d", w.Code, http.StatusOK)
+		}
+
+		var analysis CellAnalysis
+		if err := json.Unmarshal(w.Body.Bytes(), &analysis); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if analysis.Samples != 1 || len(analysis.Cells) != 4 {
+			t.Errorf("analysis = %d samples of %d cells, want 1 of 4", analysis.Samples, len(analysis.Cells))
+		}
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/voltgo/cells/analysis?battery=nope", nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("GET /api/voltgo/cells/analysis?battery=nope status = %d, want %d", w.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("energy returns 204 before first collection and the totals after", func(t *testing.T) {
+		collector, _, _ := newWorkingCollector()
+		controller := newControllerForTest(collector, &testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "test-device-1")
+		router := newRouter(controller)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/voltgo/energy", nil))
+		if w.Code != http.StatusNoContent {
+			t.Errorf("GET /api/voltgo/energy status = %d, want %d", w.Code, http.StatusNoContent)
+		}
+
+		controller.collectAndPublish()
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/voltgo/energy", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /api/voltgo/energy status = %d, want %d", w.Code, http.StatusOK)
+		}
+
+		var energy EnergyStatus
+		if err := json.Unmarshal(w.Body.Bytes(), &energy); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if energy.Day == "" || energy.EquivalentFullCycles == nil {
+			t.Errorf("energy = %+v, want the day and cycles from the info capacity", energy)
+		}
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/voltgo/energy?battery=nope", nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("GET /api/voltgo/energy?battery=nope status = %d, want %d", w.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("health report returns 204 before first collection and the report after", func(t *testing.T) {
+		collector, _, _ := newWorkingCollector()
+		controller := newControllerForTest(collector, &testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "test-device-1")
+		router := newRouter(controller)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/voltgo/health-report", nil))
+		if w.Code != http.StatusNoContent {
+			t.Errorf("GET /api/voltgo/health-report status = %d, want %d", w.Code, http.StatusNoContent)
+		}
+
+		controller.collectAndPublish()
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/voltgo/health-report", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /api/voltgo/health-report status = %d, want %d", w.Code, http.StatusOK)
+		}
+
+		var report HealthReport
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if report.RatedCapacityAh == nil || len(report.SOHHistory) != 1 || report.EndOfLifeSOH != EndOfLifeSOH {
+			t.Errorf("report = %+v, want the rated capacity, one SOH point and the end-of-life SOH", report)
+		}
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/voltgo/health-report?battery=nope", nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("GET /api/voltgo/health-report?battery=nope status = %d, want %d", w.Code, http.StatusNotFound)
+		}
+	})
+
 	t.Run("metrics returns last status including cells", func(t *testing.T) {
 		collector, _, _ := newWorkingCollector()
 		controller := newControllerForTest(collector, &testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "test-device-1")
@@ -293,6 +781,130 @@ func TestController_Endpoints(t *testing.T) {
 		}
 	})
 
+	t.Run("battery query selects a named battery", func(t *testing.T) {
+		controller, _, _, _ := newTestBank(t)
+		controller.collectAndPublish()
+		router := newRouter(controller)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/voltgo/metrics?battery=house-b", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /api/voltgo/metrics?battery=house-b status = %d, want %d", w.Code, http.StatusOK)
+		}
+		var status BatteryStatus
+		if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if status.Voltage != 13.26 {
+			t.Errorf("voltage = %v, want house-b's 13.26", status.Voltage)
+		}
+
+		for _, path := range []string{"/api/voltgo/metrics?battery=nope", "/api/voltgo/info?battery=nope"} {
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if w.Code != http.StatusNotFound {
+				t.Errorf("GET %s status = %d, want %d", path, w.Code, http.StatusNotFound)
+			}
+		}
+	})
+
+	t.Run("bank returns the aggregates", func(t *testing.T) {
+		controller, _, _, _ := newTestBank(t)
+		router := newRouter(controller)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/voltgo/bank", nil))
+		if w.Code != http.StatusNoContent {
+			t.Errorf("GET /api/voltgo/bank before the first collection status = %d, want %d", w.Code, http.StatusNoContent)
+		}
+
+		controller.collectAndPublish()
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/voltgo/bank", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /api/voltgo/bank status = %d, want %d", w.Code, http.StatusOK)
+		}
+		var bank BankStatus
+		if err := json.Unmarshal(w.Body.Bytes(), &bank); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if bank.Current != 3 || len(bank.Batteries) != 2 {
+			t.Errorf("bank = %+v, want 3A from two batteries", bank)
+		}
+		if len(bank.Missing) != 1 || bank.Missing[0] != "house-c" {
+			t.Errorf("missing = %v, want [house-c]", bank.Missing)
+		}
+	})
+
+	t.Run("scan lists nearby batteries", func(t *testing.T) {
+		collector, _, connector := newWorkingCollector()
+		connector.ScanFunc = func(context.Context) ([]Device, error) {
+			return []Device{
+				{Address: "AA:BB:CC:DD:EE:01", Name: "VOLTGO-100", RSSI: -60},
+				{Address: "11:22:33:44:55:66", Name: "Kitchen speaker", RSSI: -40},
+			}, nil
+		}
+		controller := newControllerForTest(collector, &testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "test-device-1")
+		router := newRouter(controller)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/voltgo/scan?window=2s", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /api/voltgo/scan status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+		}
+		var response ScanResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if response.Window != 2 || len(response.Devices) != 1 || response.Devices[0].Name != "VOLTGO-100" {
+			t.Errorf("response = %+v, want the one voltgo device from a 2s scan", response)
+		}
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/voltgo/scan?window=2s&all=true", nil))
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if len(response.Devices) != 2 {
+			t.Errorf("all=true listed %d devices, want 2", len(response.Devices))
+		}
+	})
+
+	t.Run("scan rejects a bad window", func(t *testing.T) {
+		collector, _, _ := newWorkingCollector()
+		controller := newControllerForTest(collector, &testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "test-device-1")
+		router := newRouter(controller)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/voltgo/scan?window=10m", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GET /api/voltgo/scan?window=10m status = %d, want %d", w.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("scan answers 409 while collecting and 500 when the scan fails", func(t *testing.T) {
+		collector, _, connector := newWorkingCollector()
+		connector.ScanFunc = func(context.Context) ([]Device, error) {
+			return nil, errors.New("adapter powered off")
+		}
+		controller := newControllerForTest(collector, &testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "test-device-1")
+		router := newRouter(controller)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/voltgo/scan", nil))
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("failed scan status = %d, want %d", w.Code, http.StatusInternalServerError)
+		}
+
+		controller.collectInProgress = true
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/voltgo/scan", nil))
+		if w.Code != http.StatusConflict {
+			t.Errorf("scan during collection status = %d, want %d", w.Code, http.StatusConflict)
+		}
+	})
+
 	t.Run("disabled controller registers no endpoints", func(t *testing.T) {
 		controller := &Controller{}
 		router := newRouter(controller)
@@ -346,7 +958,7 @@ func TestController_Close(t *testing.T) {
 
 func TestNewControllerFromConfig_Disabled(t *testing.T) {
 	t.Run("disabled via configuration", func(t *testing.T) {
-		controller, err := NewControllerFromConfig(Configuration{Enabled: false}, &testutil.MockMessagePublisher{}, "test-device-1")
+		controller, err := NewControllerFromConfig(Configuration{Enabled: false}, &testutil.MockMessagePublisher{}, "test-device-1", "")
 		if err != nil {
 			t.Fatalf("NewControllerFromConfig() error = %v", err)
 		}
@@ -356,7 +968,7 @@ func TestNewControllerFromConfig_Disabled(t *testing.T) {
 	})
 
 	t.Run("enabled but missing address", func(t *testing.T) {
-		controller, err := NewControllerFromConfig(Configuration{Enabled: true}, &testutil.MockMessagePublisher{}, "test-device-1")
+		controller, err := NewControllerFromConfig(Configuration{Enabled: true}, &testutil.MockMessagePublisher{}, "test-device-1", "")
 		if err != nil {
 			t.Fatalf("NewControllerFromConfig() error = %v", err)
 		}
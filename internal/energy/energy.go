@@ -0,0 +1,49 @@
+// Package energy integrates power and current readings taken at intervals
+// into energy and charge.
+package energy
+
+import (
+	"time"
+)
+
+// minGap is the shortest gap between samples that is not counted across.
+// With a long publish period the gap is three periods instead.
+const minGap = 5 * time.Minute
+
+// MaxGap is the longest interval between samples published every
+// publishPeriod that is counted.
+func MaxGap(publishPeriod time.Duration) time.Duration {
+	return max(3*publishPeriod, minGap)
+}
+
+// Hours returns the interval from one sample to the next, both in Unix
+// seconds, in hours. An interval that is not positive or is longer than
+// maxGap, from a failed collection or the service being down, is zero:
+// guessing what flowed would be worse than leaving it out.
+func Hours(from, to int64, maxGap time.Duration) float64 {
+	elapsed := time.Duration(to-from) * time.Second
+	if elapsed <= 0 || elapsed > maxGap {
+		return 0
+	}
+	return elapsed.Hours()
+}
+
+// Integrate returns the area under a line from a to b over hours, split
+// into the part above zero and the part below it, both positive. A line
+// that crosses zero is split where it crosses.
+func Integrate(a, b, hours float64) (positive, negative float64) {
+	switch {
+	case a >= 0 && b >= 0:
+		return (a + b) / 2 * hours, 0
+	case a <= 0 && b <= 0:
+		return 0, -(a + b) / 2 * hours
+	}
+
+	// The line crosses zero a share of the way along proportional to how
+	// far a is from it
+	crossing := a / (a - b) * hours
+	if a > 0 {
+		return a / 2 * crossing, -b / 2 * (hours - crossing)
+	}
+	return b / 2 * (hours - crossing), -a / 2 * crossing
+}
@@ -0,0 +1,68 @@
+package energy
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestMaxGap(t *testing.T) {
+	if got := MaxGap(10 * time.Second); got != 5*time.Minute {
+		t.Errorf("MaxGap(10s) = %v, want 5m", got)
+	}
+	if got := MaxGap(10 * time.Minute); got != 30*time.Minute {
+		t.Errorf("MaxGap(10m) = %v, want 30m", got)
+	}
+}
+
+func TestHours(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to int64
+		want     float64
+	}{
+		{"half an hour", 0, 1800, 0.5},
+		{"up to the gap", 0, 3600, 1},
+		{"over the gap", 0, 3601, 0},
+		{"same time", 100, 100, 0},
+		{"backwards", 100, 50, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Hours(tt.from, tt.to, time.Hour); !near(got, tt.want) {
+				t.Errorf("Hours(%d, %d, 1h) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntegrate(t *testing.T) {
+	tests := []struct {
+		name         string
+		a, b, hours  float64
+		wantPositive float64
+		wantNegative float64
+	}{
+		{"steady charge", 10, 10, 0.5, 5, 0},
+		{"rising charge", 0, 10, 1, 5, 0},
+		{"steady discharge", -4, -4, 2, 0, 8},
+		{"charge to discharge", 10, -10, 1, 2.5, 2.5},
+		{"discharge to charge", -2, 6, 1, 2.25, 0.25},
+		{"idle", 0, 0, 1, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			positive, negative := Integrate(tt.a, tt.b, tt.hours)
+			if !near(positive, tt.wantPositive) || !near(negative, tt.wantNegative) {
+				t.Errorf("Integrate(%v, %v, %v) = %v, %v, want %v, %v",
+					tt.a, tt.b, tt.hours, positive, negative, tt.wantPositive, tt.wantNegative)
+			}
+		})
+	}
+}
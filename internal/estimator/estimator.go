@@ -0,0 +1,214 @@
+// Package estimator works out how long until a battery is full or down to a
+// reserve, from its state of charge, its capacity and its net current
+// averaged over a smoothing window. The Voltgo and Epever controllers share
+// it, each feeding it from its own readings.
+package estimator
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	defaultSmoothing = 5 * time.Minute
+
+	// idleCurrent is the smoothed current below which the battery is taken
+	// to be resting, with neither estimate: a trickle either way would give
+	// one of days
+	idleCurrent = 0.5
+
+	Charging    = "charging"
+	Discharging = "discharging"
+	Idle        = "idle"
+
+	// Where the capacity an estimate is from came from: the configuration,
+	// the Voltgo health report's measurement, the Voltgo BMS's rating, or the
+	// battery capacity set on the Epever. A Voltgo bank whose packs'
+	// capacities came from different places is mixed.
+	CapacityConfigured = "configured"
+	CapacityMeasured   = "measured"
+	CapacityRated      = "rated"
+	CapacityController = "controller"
+	CapacityMixed      = "mixed"
+)
+
+// Config tunes the time-to-full and time-to-empty estimates.
+type Config struct {
+	// ReserveSOC is the SOC time-to-empty runs down to (default: 0)
+	ReserveSOC float64 `yaml:"reserveSoc"`
+
+	// Smoothing is the window the current is averaged over, as a duration
+	// string (default: 5m)
+	Smoothing string `yaml:"smoothing"`
+
+	// CapacityAh replaces the capacity the controller reads, such as when
+	// the BMS reports none
+	CapacityAh float64 `yaml:"capacityAh"`
+}
+
+// Validate checks the configuration for errors.
+func (c *Config) Validate() error {
+	if c.ReserveSOC < 0 || c.ReserveSOC >= 100 {
+		return fmt.Errorf("reserve SOC %v must be from 0 to below 100", c.ReserveSOC)
+	}
+	if c.Smoothing != "" {
+		d, err := time.ParseDuration(c.Smoothing)
+		if err != nil {
+			return fmt.Errorf("smoothing: %w", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("smoothing must be positive")
+		}
+	}
+	if c.CapacityAh < 0 {
+		return fmt.Errorf("capacity must not be negative")
+	}
+	return nil
+}
+
+// Policy returns the parsed configuration. A smoothing window that is unset
+// or invalid takes its default.
+func (c *Config) Policy() Policy {
+	policy := Policy{
+		ReserveSOC: c.ReserveSOC,
+		CapacityAh: c.CapacityAh,
+	}
+	if d, err := time.ParseDuration(c.Smoothing); err == nil {
+		policy.Smoothing = d
+	}
+	return policy.WithDefaults()
+}
+
+// Policy is the parsed Config.
+type Policy struct {
+	ReserveSOC float64
+	Smoothing  time.Duration
+	CapacityAh float64
+}
+
+// WithDefaults fills in the zero fields.
+func (p Policy) WithDefaults() Policy {
+	if p.Smoothing <= 0 {
+		p.Smoothing = defaultSmoothing
+	}
+	return p
+}
+
+// Estimate is how long until the battery is full or down to the reserve at
+// the smoothed current. Times are in seconds.
+type Estimate struct {
+	Timestamp int64 `json:"timestamp"`
+
+	// State is charging, discharging or idle, from the smoothed current
+	State string `json:"state"`
+
+	// Current is the net current averaged over the smoothing window, the one
+	// the estimate is from, and Samples how many readings went into it
+	Current float64 `json:"current"`
+	Samples int     `json:"samples"`
+
+	// Confidence, in percent, is how much of the smoothing window the
+	// samples span times how steady the current was across them (one less
+	// its coefficient of variation). A spike or a fresh start lowers it
+	Confidence float64 `json:"confidence"`
+
+	SOC            float64 `json:"soc"`
+	ReserveSOC     float64 `json:"reserveSoc"`
+	CapacityAh     float64 `json:"capacityAh"`
+	CapacitySource string  `json:"capacitySource"`
+
+	// TimeToFull is set while charging and TimeToEmpty while discharging
+	TimeToFull  *float64 `json:"timeToFull"`
+	TimeToEmpty *float64 `json:"timeToEmpty"`
+}
+
+// sample is one reading of the net current.
+type sample struct {
+	timestamp int64
+	current   float64
+}
+
+// Window holds the current readings of the last smoothing window. It is not
+// safe for concurrent use; each controller only touches it from its
+// collection cycle.
+type Window struct {
+	samples []sample
+}
+
+// Add records a reading of the net current, positive when charging, and
+// drops those that have left the smoothing window.
+func (w *Window) Add(timestamp int64, current float64, smoothing time.Duration) {
+	w.samples = append(w.samples, sample{timestamp: timestamp, current: current})
+
+	oldest := timestamp - int64(smoothing.Seconds())
+	keep := 0
+	for keep < len(w.samples) && w.samples[keep].timestamp < oldest {
+		keep++
+	}
+	w.samples = w.samples[keep:]
+}
+
+// smoothed returns the mean current across the window and the confidence in
+// it.
+func (w *Window) smoothed(smoothing time.Duration) (mean, confidence float64) {
+	if len(w.samples) == 0 {
+		return 0, 0
+	}
+
+	for _, s := range w.samples {
+		mean += s.current
+	}
+	mean /= float64(len(w.samples))
+	if mean == 0 {
+		return 0, 0
+	}
+
+	var variance float64
+	for _, s := range w.samples {
+		variance += (s.current - mean) * (s.current - mean)
+	}
+	variance /= float64(len(w.samples))
+	steadiness := max(0, 1-math.Sqrt(variance)/math.Abs(mean))
+
+	span := time.Duration(w.samples[len(w.samples)-1].timestamp-w.samples[0].timestamp) * time.Second
+	fill := min(1, span.Seconds()/smoothing.Seconds())
+
+	return mean, fill * steadiness * 100
+}
+
+// Estimate works out the runtime from the window's smoothed current, the SOC
+// and the capacity. It is nil while the capacity is not known or there are
+// no readings.
+func (w *Window) Estimate(timestamp int64, soc, capacityAh float64, source string, policy Policy) *Estimate {
+	if capacityAh <= 0 || len(w.samples) == 0 {
+		return nil
+	}
+
+	current, confidence := w.smoothed(policy.Smoothing)
+	estimate := &Estimate{
+		Timestamp:      timestamp,
+		State:          Idle,
+		Current:        current,
+		Samples:        len(w.samples),
+		Confidence:     confidence,
+		SOC:            soc,
+		ReserveSOC:     policy.ReserveSOC,
+		CapacityAh:     capacityAh,
+		CapacitySource: source,
+	}
+
+	switch {
+	case current >= idleCurrent:
+		estimate.State = Charging
+		remainingAh := capacityAh * max(100-soc, 0) / 100
+		seconds := remainingAh / current * 3600
+		estimate.TimeToFull = &seconds
+	case current <= -idleCurrent:
+		estimate.State = Discharging
+		usableAh := capacityAh * max(soc-policy.ReserveSOC, 0) / 100
+		seconds := usableAh / -current * 3600
+		estimate.TimeToEmpty = &seconds
+	}
+	return estimate
+}
@@ -0,0 +1,171 @@
+package estimator
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{name: "defaults", config: Config{}},
+		{name: "everything set", config: Config{ReserveSOC: 20, Smoothing: "10m", CapacityAh: 200}},
+		{name: "reserve SOC of 100", config: Config{ReserveSOC: 100}, wantErr: "reserve SOC"},
+		{name: "negative reserve SOC", config: Config{ReserveSOC: -1}, wantErr: "reserve SOC"},
+		{name: "invalid smoothing", config: Config{Smoothing: "a while"}, wantErr: "smoothing"},
+		{name: "zero smoothing", config: Config{Smoothing: "0s"}, wantErr: "smoothing must be positive"},
+		{name: "negative capacity", config: Config{CapacityAh: -100}, wantErr: "capacity must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_Policy(t *testing.T) {
+	defaults := (&Config{}).Policy()
+	if want := (Policy{Smoothing: 5 * time.Minute}); defaults != want {
+		t.Errorf("Policy() = %+v, want %+v", defaults, want)
+	}
+
+	configured := (&Config{ReserveSOC: 20, Smoothing: "15m", CapacityAh: 200}).Policy()
+	if want := (Policy{ReserveSOC: 20, Smoothing: 15 * time.Minute, CapacityAh: 200}); configured != want {
+		t.Errorf("Policy() = %+v, want %+v", configured, want)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	window := defaultSmoothing
+
+	t.Run("drops readings older than the window", func(t *testing.T) {
+		var w Window
+		for i := int64(0); i <= 600; i += 60 {
+			w.Add(1699000000+i, -10, window)
+		}
+		if len(w.samples) != 6 {
+			t.Fatalf("samples = %d, want the 6 of the last 5 minutes", len(w.samples))
+		}
+		if w.samples[0].timestamp != 1699000300 {
+			t.Errorf("oldest sample at %d, want 1699000300", w.samples[0].timestamp)
+		}
+	})
+
+	t.Run("a steady current over the whole window is fully trusted", func(t *testing.T) {
+		var w Window
+		for i := int64(0); i <= 300; i += 30 {
+			w.Add(1699000000+i, -10, window)
+		}
+		mean, confidence := w.smoothed(window)
+		if mean != -10 || confidence != 100 {
+			t.Errorf("smoothed() = %v A at %v%%, want -10 A at 100%%", mean, confidence)
+		}
+	})
+
+	t.Run("a fresh window is not trusted", func(t *testing.T) {
+		var w Window
+		w.Add(1699000000, -10, window)
+		w.Add(1699000030, -10, window)
+		_, confidence := w.smoothed(window)
+		if confidence != 10 {
+			t.Errorf("confidence = %v, want 10 with 30s of a 5m window", confidence)
+		}
+	})
+
+	t.Run("a spike is smoothed and lowers the confidence", func(t *testing.T) {
+		var w Window
+		for i := int64(0); i < 300; i += 30 {
+			w.Add(1699000000+i, -5, window)
+		}
+		w.Add(1699000300, -100, window)
+
+		mean, confidence := w.smoothed(window)
+		if want := -150.0 / 11; math.Abs(mean-want) > 1e-9 {
+			t.Errorf("mean = %v, want %v with the spike averaged in", mean, want)
+		}
+		if confidence >= 50 {
+			t.Errorf("confidence = %v, want it well down after a spike", confidence)
+		}
+	})
+
+	t.Run("no current is no confidence", func(t *testing.T) {
+		var w Window
+		if mean, confidence := w.smoothed(window); mean != 0 || confidence != 0 {
+			t.Errorf("empty smoothed() = %v, %v, want 0, 0", mean, confidence)
+		}
+		w.Add(1699000000, 0, window)
+		if mean, confidence := w.smoothed(window); mean != 0 || confidence != 0 {
+			t.Errorf("zero smoothed() = %v, %v, want 0, 0", mean, confidence)
+		}
+	})
+}
+
+func TestWindow_Estimate(t *testing.T) {
+	policy := Policy{ReserveSOC: 20}.WithDefaults()
+
+	steady := func(current float64) *Window {
+		var w Window
+		for i := int64(0); i <= 300; i += 60 {
+			w.Add(1699000000+i, current, policy.Smoothing)
+		}
+		return &w
+	}
+
+	t.Run("discharging runs down to the reserve", func(t *testing.T) {
+		estimate := steady(-10).Estimate(1699000300, 70, 100, CapacityRated, policy)
+		if estimate.State != Discharging || estimate.TimeToFull != nil || estimate.TimeToEmpty == nil {
+			t.Fatalf("estimate = %+v, want discharging with only a time to empty", estimate)
+		}
+		// 50% of 100Ah above the reserve at 10A
+		if *estimate.TimeToEmpty != 5*3600 {
+			t.Errorf("time to empty = %v, want %v", *estimate.TimeToEmpty, 5*3600)
+		}
+		if estimate.Samples != 6 || estimate.Confidence != 100 || estimate.CapacitySource != CapacityRated {
+			t.Errorf("estimate = %+v, want 6 samples at 100%% from the rated capacity", estimate)
+		}
+	})
+
+	t.Run("charging runs up to full", func(t *testing.T) {
+		estimate := steady(20).Estimate(1699000300, 60, 100, CapacityRated, policy)
+		if estimate.State != Charging || estimate.TimeToEmpty != nil || estimate.TimeToFull == nil {
+			t.Fatalf("estimate = %+v, want charging with only a time to full", estimate)
+		}
+		if *estimate.TimeToFull != 2*3600 {
+			t.Errorf("time to full = %v, want %v", *estimate.TimeToFull, 2*3600)
+		}
+	})
+
+	t.Run("at or below the reserve there is no time left", func(t *testing.T) {
+		estimate := steady(-10).Estimate(1699000300, 15, 100, CapacityRated, policy)
+		if estimate.TimeToEmpty == nil || *estimate.TimeToEmpty != 0 {
+			t.Errorf("estimate = %+v, want a time to empty of 0", estimate)
+		}
+	})
+
+	t.Run("a trickle is idle", func(t *testing.T) {
+		estimate := steady(0.2).Estimate(1699000300, 60, 100, CapacityRated, policy)
+		if estimate.State != Idle || estimate.TimeToFull != nil || estimate.TimeToEmpty != nil {
+			t.Errorf("estimate = %+v, want idle with neither time", estimate)
+		}
+	})
+
+	t.Run("no capacity is no estimate", func(t *testing.T) {
+		if estimate := steady(-10).Estimate(1699000300, 60, 0, "", policy); estimate != nil {
+			t.Errorf("estimate = %+v, want nil without a capacity", estimate)
+		}
+	})
+}
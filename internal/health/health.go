@@ -0,0 +1,184 @@
+// Package health tracks how fresh each controller's data is and publishes
+// its availability, so consumers can tell live values from ones frozen at the
+// last successful collection.
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/publish"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultOfflineAfter is how many failed collection cycles in a row mark a
+// controller offline when its configuration does not say.
+const DefaultOfflineAfter = 3
+
+// staleCycles is how many collection periods may pass since the last success
+// before the data counts as stale even without a recorded failure, which
+// catches a collection loop that has stopped running at all.
+const staleCycles = 2
+
+// Availability is whether a controller is delivering data.
+type Availability string
+
+const (
+	// Unknown is the availability before the first collection cycle ends.
+	Unknown Availability = "unknown"
+	// Online controllers collected successfully within the last offlineAfter
+	// cycles.
+	Online Availability = "online"
+	// Offline controllers have failed offlineAfter cycles in a row, or have
+	// never collected and failed that many times.
+	Offline Availability = "offline"
+)
+
+// Health is a controller's data freshness, as returned with its metrics and
+// by GET /api/controllers/{name}/health.
+type Health struct {
+	Availability Availability `json:"availability"`
+
+	// Stale is true when the last status is not from the latest cycle
+	Stale bool `json:"stale"`
+
+	// Age is the seconds since the last successful collection; null before
+	// the first
+	Age *float64 `json:"age"`
+
+	LastSuccess         *time.Time `json:"lastSuccess"`
+	LastError           string     `json:"lastError"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+}
+
+// availabilityPayload is published to {deviceId}/{controller}/availability.
+// It has the value/unit/timestamp shape of every other metric, so publishers
+// that convert metrics (remote write) can carry it as a 0/1 series, plus the
+// state as a string for MQTT consumers.
+type availabilityPayload struct {
+	Value     int          `json:"value"`
+	Unit      string       `json:"unit"`
+	State     Availability `json:"state"`
+	Timestamp int64        `json:"timestamp"`
+}
+
+// Tracker records the outcome of each collection cycle for one controller.
+// It is safe for concurrent use.
+type Tracker struct {
+	publisher    publish.MessagePublisher
+	topic        string
+	period       time.Duration
+	offlineAfter int
+	now          func() time.Time
+
+	mu           sync.Mutex
+	lastSuccess  time.Time
+	lastError    string
+	failures     int
+	availability Availability
+}
+
+// NewTracker creates a tracker for a controller that collects every period.
+// offlineAfter of zero or less means DefaultOfflineAfter.
+func NewTracker(publisher publish.MessagePublisher, deviceID, controller string, period time.Duration, offlineAfter int) *Tracker {
+	if offlineAfter <= 0 {
+		offlineAfter = DefaultOfflineAfter
+	}
+	return &Tracker{
+		publisher:    publisher,
+		topic:        fmt.Sprintf("%s/%s/availability", deviceID, controller),
+		period:       period,
+		offlineAfter: offlineAfter,
+		now:          time.Now,
+		availability: Unknown,
+	}
+}
+
+// Success records a successful collection and publishes availability.
+func (t *Tracker) Success() {
+	t.mu.Lock()
+	t.lastSuccess = t.now()
+	t.failures = 0
+	t.lastError = ""
+	availability := t.update(Online)
+	t.mu.Unlock()
+
+	t.publish(availability)
+}
+
+// Failure records a failed collection and publishes availability once it is
+// known.
+func (t *Tracker) Failure(err error) {
+	t.mu.Lock()
+	t.failures++
+	t.lastError = err.Error()
+
+	availability := t.availability
+	if t.failures >= t.offlineAfter {
+		availability = t.update(Offline)
+	}
+	t.mu.Unlock()
+
+	if availability != Unknown {
+		t.publish(availability)
+	}
+}
+
+// update moves to availability, logging the change. The caller must hold the
+// lock.
+func (t *Tracker) update(availability Availability) Availability {
+	if t.availability != availability && !(t.availability == Unknown && availability == Online) {
+		log.Infof("%s is now %s", t.topic, availability)
+	}
+	t.availability = availability
+	return availability
+}
+
+// publish sends the availability every cycle rather than on change only, so
+// a consumer that subscribes late learns it within one period.
+func (t *Tracker) publish(availability Availability) {
+	if t.publisher == nil {
+		return
+	}
+
+	payload := availabilityPayload{
+		Unit:      "boolean",
+		State:     availability,
+		Timestamp: t.now().Unix(),
+	}
+	if availability == Online {
+		payload.Value = 1
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		log.Errorf("failed to marshal availability for %s: %s", t.topic, err)
+		return
+	}
+	t.publisher.Publish(t.topic, string(b))
+}
+
+// Health returns the current freshness.
+func (t *Tracker) Health() Health {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := Health{
+		Availability:        t.availability,
+		LastError:           t.lastError,
+		ConsecutiveFailures: t.failures,
+	}
+	if t.lastSuccess.IsZero() {
+		return h
+	}
+
+	lastSuccess := t.lastSuccess
+	age := t.now().Sub(lastSuccess)
+	seconds := age.Seconds()
+	h.LastSuccess = &lastSuccess
+	h.Age = &seconds
+	h.Stale = t.failures > 0 || (t.period > 0 && age > staleCycles*t.period)
+	return h
+}
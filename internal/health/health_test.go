@@ -0,0 +1,121 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+)
+
+func newTestTracker(publisher *testutil.MockMessagePublisher, offlineAfter int) (*Tracker, *time.Time) {
+	now := time.Date(2025, 6, 21, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker(publisher, "device-1", "epever", time.Minute, offlineAfter)
+	tracker.now = func() time.Time { return now }
+	return tracker, &now
+}
+
+func lastAvailability(t *testing.T, publisher *testutil.MockMessagePublisher) availabilityPayload {
+	t.Helper()
+
+	if len(publisher.PublishCalls) == 0 {
+		t.Fatal("no availability published")
+	}
+	call := publisher.PublishCalls[len(publisher.PublishCalls)-1]
+	if call.TopicSuffix != "device-1/epever/availability" {
+		t.Errorf("topic = %q, want device-1/epever/availability", call.TopicSuffix)
+	}
+
+	var payload availabilityPayload
+	if err := json.Unmarshal([]byte(call.Payload), &payload); err != nil {
+		t.Fatalf("availability payload %q: %v", call.Payload, err)
+	}
+	return payload
+}
+
+func TestTracker_BeforeTheFirstCycle(t *testing.T) {
+	tracker, _ := newTestTracker(&testutil.MockMessagePublisher{}, 3)
+
+	h := tracker.Health()
+	if h.Availability != Unknown || h.Stale || h.Age != nil || h.LastSuccess != nil {
+		t.Errorf("Health() = %+v, want unknown with no age", h)
+	}
+}
+
+func TestTracker_GoesOfflineAfterNFailures(t *testing.T) {
+	publisher := &testutil.MockMessagePublisher{}
+	tracker, now := newTestTracker(publisher, 3)
+
+	tracker.Success()
+	if got := lastAvailability(t, publisher); got.State != Online || got.Value != 1 {
+		t.Errorf("availability = %+v, want online/1", got)
+	}
+
+	*now = now.Add(time.Minute)
+	tracker.Failure(errors.New("timeout"))
+	tracker.Failure(errors.New("timeout"))
+
+	h := tracker.Health()
+	if h.Availability != Online {
+		t.Errorf("availability after 2 failures = %s, want online", h.Availability)
+	}
+	if !h.Stale || h.ConsecutiveFailures != 2 || h.LastError != "timeout" {
+		t.Errorf("Health() = %+v, want stale with 2 failures", h)
+	}
+	if h.Age == nil || *h.Age != 60 {
+		t.Errorf("Age = %v, want 60", h.Age)
+	}
+
+	tracker.Failure(errors.New("timeout"))
+	if got := lastAvailability(t, publisher); got.State != Offline || got.Value != 0 {
+		t.Errorf("availability after 3 failures = %+v, want offline/0", got)
+	}
+
+	tracker.Success()
+	h = tracker.Health()
+	if h.Availability != Online || h.Stale || h.ConsecutiveFailures != 0 || h.LastError != "" {
+		t.Errorf("Health() after recovery = %+v, want online and fresh", h)
+	}
+}
+
+func TestTracker_FailuresBeforeTheFirstSuccess(t *testing.T) {
+	publisher := &testutil.MockMessagePublisher{}
+	tracker, _ := newTestTracker(publisher, 2)
+
+	tracker.Failure(errors.New("no device"))
+	if len(publisher.PublishCalls) != 0 {
+		t.Errorf("published %v while availability is unknown", publisher.PublishCalls)
+	}
+
+	tracker.Failure(errors.New("no device"))
+	if got := lastAvailability(t, publisher); got.State != Offline {
+		t.Errorf("availability = %s, want offline", got.State)
+	}
+}
+
+// Data goes stale on age alone if the collection loop stops reporting.
+func TestTracker_StaleWithoutFailures(t *testing.T) {
+	tracker, now := newTestTracker(&testutil.MockMessagePublisher{}, 3)
+
+	tracker.Success()
+	*now = now.Add(2 * time.Minute)
+	if tracker.Health().Stale {
+		t.Error("stale after two periods, want fresh until more than two have passed")
+	}
+
+	*now = now.Add(time.Second)
+	if !tracker.Health().Stale {
+		t.Error("fresh after more than two periods without a collection")
+	}
+}
+
+func TestNewTracker_DefaultsOfflineAfter(t *testing.T) {
+	tracker := NewTracker(nil, "device-1", "voltgo", time.Minute, 0)
+	if tracker.offlineAfter != DefaultOfflineAfter {
+		t.Errorf("offlineAfter = %d, want %d", tracker.offlineAfter, DefaultOfflineAfter)
+	}
+
+	// A nil publisher is allowed and publishes nothing
+	tracker.Success()
+}
@@ -0,0 +1,286 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultRange is how far back GET /api/history reads without a from
+	defaultRange = 24 * time.Hour
+
+	// lookback is how old the newest point of an instant query may be, as
+	// in Prometheus
+	lookback = 5 * 60
+)
+
+// selectorPattern matches the one selector the query API understands: a
+// metric name, bare or as {__name__="..."}.
+var selectorPattern = regexp.MustCompile(
+	`^\s*(?:([a-zA-Z_:][a-zA-Z0-9_:]*)\s*(?:\{\s*\})?|\{\s*__name__\s*=\s*"([a-zA-Z_:][a-zA-Z0-9_:]*)"\s*\})\s*$`)
+
+// SeriesResponse is the body of GET /api/history/metrics.
+type SeriesResponse struct {
+	Metrics []SeriesInfo `json:"metrics"`
+}
+
+// HistoryGet returns ?metric= from ?from= to ?to= (Unix seconds or RFC 3339,
+// default the last 24 hours), merged into one point per ?step= (a duration
+// or seconds) when given.
+func (s *Store) HistoryGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		metric := c.Query("metric")
+		if metric == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "metric is required, such as voltgo/battery-soc"})
+			return
+		}
+
+		to, err := parseTime(c.Query("to"), s.now().Unix())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("to: %s", err)})
+			return
+		}
+		from, err := parseTime(c.Query("from"), to-int64(defaultRange.Seconds()))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("from: %s", err)})
+			return
+		}
+		step, err := parseStep(c.Query("step"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("step: %s", err)})
+			return
+		}
+
+		result, err := s.Query(metric, from, to, step)
+		if errors.Is(err, ErrUnknownMetric) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// SeriesGet lists every metric with history.
+func (s *Store) SeriesGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, SeriesResponse{Metrics: s.Series()})
+	}
+}
+
+// promResponse is the envelope of every Prometheus API response.
+type promResponse struct {
+	Status    string `json:"status"`
+	Data      any    `json:"data,omitempty"`
+	ErrorType string `json:"errorType,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type promData struct {
+	ResultType string `json:"resultType"`
+	Result     any    `json:"result"`
+}
+
+type promSeries struct {
+	Metric map[string]string `json:"metric"`
+	Values [][2]any          `json:"values,omitempty"`
+	Value  *[2]any           `json:"value,omitempty"`
+}
+
+func promSuccess(c *gin.Context, data any) {
+	c.JSON(http.StatusOK, promResponse{Status: "success", Data: data})
+}
+
+func promBadData(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, promResponse{Status: "error", ErrorType: "bad_data", Error: err.Error()})
+}
+
+// promValue is a Prometheus sample: the time in seconds and the value as a
+// string.
+func promValue(timestamp int64, value float64) [2]any {
+	return [2]any{float64(timestamp), strconv.FormatFloat(value, 'f', -1, 64)}
+}
+
+// QueryRangeGet answers the Prometheus /api/v1/query_range for a metric name,
+// with each step the mean of the samples in it. Steps start at ?start=, as
+// Prometheus evaluates them.
+func (s *Store) QueryRangeGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, err := parseSelector(c.Request.FormValue("query"))
+		if err != nil {
+			promBadData(c, err)
+			return
+		}
+		start, err := parseTime(c.Request.FormValue("start"), 0)
+		if err != nil || start == 0 {
+			promBadData(c, fmt.Errorf("invalid start %q", c.Request.FormValue("start")))
+			return
+		}
+		end, err := parseTime(c.Request.FormValue("end"), 0)
+		if err != nil || end == 0 {
+			promBadData(c, fmt.Errorf("invalid end %q", c.Request.FormValue("end")))
+			return
+		}
+		step, err := parseStep(c.Request.FormValue("step"))
+		if err != nil || step <= 0 {
+			promBadData(c, fmt.Errorf("invalid step %q", c.Request.FormValue("step")))
+			return
+		}
+
+		result := []promSeries{}
+		metric, ok := s.seriesNamed(name)
+		if !ok {
+			// Prometheus answers an unknown metric with no series
+			promSuccess(c, promData{ResultType: "matrix", Result: result})
+			return
+		}
+		history, err := s.query(metric, start, end, step, start)
+		if err != nil {
+			promBadData(c, err)
+			return
+		}
+		if len(history.Points) > 0 {
+			values := make([][2]any, 0, len(history.Points))
+			for _, p := range history.Points {
+				values = append(values, promValue(p.Timestamp, p.Value))
+			}
+			result = append(result, promSeries{Metric: map[string]string{"__name__": name}, Values: values})
+		}
+		promSuccess(c, promData{ResultType: "matrix", Result: result})
+	}
+}
+
+// QueryGet answers the Prometheus /api/v1/query for a metric name, with its
+// newest point no more than 5 minutes before ?time=, or for a number, which
+// is what Grafana sends to test a data source.
+func (s *Store) QueryGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		at, err := parseTime(c.Request.FormValue("time"), s.now().Unix())
+		if err != nil {
+			promBadData(c, fmt.Errorf("invalid time %q", c.Request.FormValue("time")))
+			return
+		}
+
+		query := c.Request.FormValue("query")
+		if number, err := strconv.ParseFloat(query, 64); err == nil {
+			promSuccess(c, promData{ResultType: "scalar", Result: promValue(at, number)})
+			return
+		}
+
+		name, err := parseSelector(query)
+		if err != nil {
+			promBadData(c, err)
+			return
+		}
+
+		result := []promSeries{}
+		if metric, ok := s.seriesNamed(name); ok {
+			if p, ok := s.Latest(metric, at, lookback); ok {
+				value := promValue(p.Timestamp, p.Value)
+				result = append(result, promSeries{Metric: map[string]string{"__name__": name}, Value: &value})
+			}
+		}
+		promSuccess(c, promData{ResultType: "vector", Result: result})
+	}
+}
+
+// LabelsGet answers the Prometheus /api/v1/labels: the only label is the
+// metric name.
+func (s *Store) LabelsGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		promSuccess(c, []string{"__name__"})
+	}
+}
+
+// LabelValuesGet answers the Prometheus /api/v1/label/{name}/values, which
+// Grafana lists the metrics with.
+func (s *Store) LabelValuesGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		values := []string{}
+		if c.Param("name") == "__name__" {
+			for _, info := range s.Series() {
+				values = append(values, info.PrometheusName)
+			}
+			sort.Strings(values)
+		}
+		promSuccess(c, values)
+	}
+}
+
+// seriesNamed finds the series a Prometheus name stands for.
+func (s *Store) seriesNamed(name string) (string, bool) {
+	for _, info := range s.Series() {
+		if info.PrometheusName == name {
+			return info.Name, true
+		}
+	}
+	return "", false
+}
+
+// RegisterEndpoints adds the history and Prometheus query endpoints.
+func (s *Store) RegisterEndpoints(r *gin.Engine) {
+	r.GET("/api/history", s.HistoryGet())
+	r.GET("/api/history/metrics", s.SeriesGet())
+
+	// Grafana sends queries as GET or as a POSTed form
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		r.Handle(method, "/api/v1/query_range", s.QueryRangeGet())
+		r.Handle(method, "/api/v1/query", s.QueryGet())
+		r.Handle(method, "/api/v1/labels", s.LabelsGet())
+		r.Handle(method, "/api/v1/label/:name/values", s.LabelValuesGet())
+	}
+}
+
+// parseSelector returns the metric name of a query.
+func parseSelector(query string) (string, error) {
+	match := selectorPattern.FindStringSubmatch(query)
+	if match == nil {
+		return "", fmt.Errorf("only a metric name is supported, such as voltgo_battery_soc, not %q", query)
+	}
+	if match[1] != "" {
+		return match[1], nil
+	}
+	return match[2], nil
+}
+
+// parseTime parses Unix seconds, with or without a fraction, or an RFC 3339
+// time. Empty is fallback.
+func parseTime(value string, fallback int64) (int64, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return int64(math.Floor(seconds)), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is neither Unix seconds nor an RFC 3339 time", value)
+	}
+	return t.Unix(), nil
+}
+
+// parseStep parses seconds or a duration such as 5m. Empty is no step.
+func parseStep(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return int64(math.Ceil(seconds)), nil
+	}
+	d, err := ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	return int64(math.Ceil(d.Seconds())), nil
+}
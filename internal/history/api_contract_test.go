@@ -0,0 +1,51 @@
+package history
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// HistoryGet serialises Result directly; each point is checked on its own.
+func TestResultMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(Result{Points: []Point{}})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/history"),
+		testutil.JSONFieldNames(t, payload),
+		"Result no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+
+	point, err := json.Marshal(Point{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/history#points[]"),
+		testutil.JSONFieldNames(t, point),
+		"Point no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
+
+func TestSeriesResponseMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(SeriesResponse{Metrics: []SeriesInfo{}})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/history/metrics"),
+		testutil.JSONFieldNames(t, payload),
+		"SeriesResponse no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+
+	entry, err := json.Marshal(SeriesInfo{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/history/metrics#metrics[]"),
+		testutil.JSONFieldNames(t, entry),
+		"SeriesInfo no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
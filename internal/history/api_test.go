@@ -0,0 +1,288 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter serves a store holding one sample a minute of
+// epever/array-power for the hour before testNow, valued by the minute.
+func newTestRouter(t *testing.T) (*gin.Engine, int64) {
+	t.Helper()
+
+	now := testNow()
+	store, _, err := newTestStore("", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := now.Unix() - 3600
+	for i := int64(0); i < 60; i++ {
+		store.Add("epever/array-power", "watts", base+i*60, float64(i))
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	store.RegisterEndpoints(router)
+	return router, base
+}
+
+func serve(router *gin.Engine, request *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestHistoryGet(t *testing.T) {
+	router, base := newTestRouter(t)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantPoints int
+	}{
+		{"the last 24 hours", "metric=epever/array-power", http.StatusOK, 60},
+		{"a range in Unix seconds", fmt.Sprintf("metric=epever/array-power&from=%d&to=%d", base, base+240), http.StatusOK, 5},
+		{"a step as a duration", fmt.Sprintf("metric=epever/array-power&from=%d&step=10m", base), http.StatusOK, 6},
+		{"a step in seconds", fmt.Sprintf("metric=epever/array-power&from=%d&step=600", base), http.StatusOK, 6},
+		{"an RFC 3339 range", "metric=epever/array-power&from=2000-01-01T00:00:00Z&to=2000-01-02T00:00:00Z", http.StatusOK, 0},
+		{"no metric", "", http.StatusBadRequest, 0},
+		{"a bad from", "metric=epever/array-power&from=yesterday", http.StatusBadRequest, 0},
+		{"a bad to", "metric=epever/array-power&to=now", http.StatusBadRequest, 0},
+		{"a bad step", "metric=epever/array-power&step=often", http.StatusBadRequest, 0},
+		{"to before from", fmt.Sprintf("metric=epever/array-power&from=%d&to=%d", base, base-1), http.StatusBadRequest, 0},
+		{"an unknown metric", "metric=epever/load-power", http.StatusNotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serve(router, httptest.NewRequest(http.MethodGet, "/api/history?"+tt.query, nil))
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				var body map[string]string
+				if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil || body["error"] == "" {
+					t.Errorf("body = %s, want an error", recorder.Body.String())
+				}
+				return
+			}
+			var result Result
+			if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Points) != tt.wantPoints {
+				t.Errorf("got %d points, want %d", len(result.Points), tt.wantPoints)
+			}
+		})
+	}
+}
+
+func TestSeriesGet(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	recorder := serve(router, httptest.NewRequest(http.MethodGet, "/api/history/metrics", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", recorder.Code)
+	}
+	var body SeriesResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := SeriesInfo{Name: "epever/array-power", Unit: "watts", PrometheusName: "epever_array_power"}
+	if len(body.Metrics) != 1 || body.Metrics[0] != want {
+		t.Errorf("metrics = %+v, want %+v", body.Metrics, want)
+	}
+}
+
+// promBody is a decoded Prometheus API response.
+type promBody struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Data      struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Values [][2]any          `json:"values"`
+			Value  [2]any            `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+func decodeProm(t *testing.T, recorder *httptest.ResponseRecorder) promBody {
+	t.Helper()
+
+	var body promBody
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body %s: %v", recorder.Body.String(), err)
+	}
+	return body
+}
+
+func TestQueryRangeGet(t *testing.T) {
+	router, base := newTestRouter(t)
+
+	t.Run("a GET of a metric name", func(t *testing.T) {
+		target := fmt.Sprintf("/api/v1/query_range?query=epever_array_power&start=%d&end=%d&step=600", base, base+3599)
+		recorder := serve(router, httptest.NewRequest(http.MethodGet, target, nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body.String())
+		}
+		body := decodeProm(t, recorder)
+		if body.Status != "success" || body.Data.ResultType != "matrix" || len(body.Data.Result) != 1 {
+			t.Fatalf("body = %+v, want one series in a matrix", body)
+		}
+		series := body.Data.Result[0]
+		if series.Metric["__name__"] != "epever_array_power" || len(series.Values) != 6 {
+			t.Errorf("series = %+v, want 6 steps of epever_array_power", series)
+		}
+		if first := series.Values[0]; first[0] != float64(base) || first[1] != "4.5" {
+			t.Errorf("first value = %v, want [%d \"4.5\"]", first, base)
+		}
+	})
+
+	t.Run("steps start at the start", func(t *testing.T) {
+		target := fmt.Sprintf("/api/v1/query_range?query=epever_array_power&start=%d&end=%d&step=600", base+300, base+3599)
+		body := decodeProm(t, serve(router, httptest.NewRequest(http.MethodGet, target, nil)))
+		if len(body.Data.Result) != 1 || len(body.Data.Result[0].Values) != 6 {
+			t.Fatalf("body = %+v, want 6 steps", body)
+		}
+		if first := body.Data.Result[0].Values[0]; first[0] != float64(base+300) || first[1] != "9.5" {
+			t.Errorf("first value = %v, want [%d \"9.5\"]", first, base+300)
+		}
+	})
+
+	t.Run("a POSTed form with a __name__ selector", func(t *testing.T) {
+		form := url.Values{
+			"query": {`{__name__="epever_array_power"}`},
+			"start": {fmt.Sprint(base)},
+			"end":   {fmt.Sprint(base + 3599)},
+			"step":  {"1h"},
+		}
+		request := httptest.NewRequest(http.MethodPost, "/api/v1/query_range", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		body := decodeProm(t, serve(router, request))
+		if len(body.Data.Result) != 1 || len(body.Data.Result[0].Values) != 1 {
+			t.Errorf("body = %+v, want one step", body)
+		}
+	})
+
+	t.Run("an unknown metric has no series", func(t *testing.T) {
+		target := fmt.Sprintf("/api/v1/query_range?query=epever_load_power&start=%d&end=%d&step=60", base, base+3599)
+		body := decodeProm(t, serve(router, httptest.NewRequest(http.MethodGet, target, nil)))
+		if body.Status != "success" || len(body.Data.Result) != 0 {
+			t.Errorf("body = %+v, want success with no series", body)
+		}
+	})
+
+	for _, query := range []string{
+		"query=rate(epever_array_power[5m])&start=1&end=2&step=1",
+		"query=epever_array_power&end=2&step=1",
+		"query=epever_array_power&start=1&step=1",
+		"query=epever_array_power&start=1&end=2",
+		"query=epever_array_power&start=1&end=100000&step=1",
+	} {
+		t.Run("bad data: "+query, func(t *testing.T) {
+			recorder := serve(router, httptest.NewRequest(http.MethodGet, "/api/v1/query_range?"+url.PathEscape(query), nil))
+			body := decodeProm(t, recorder)
+			if recorder.Code != http.StatusBadRequest || body.Status != "error" || body.ErrorType != "bad_data" {
+				t.Errorf("status %d, body = %s, want a bad_data error", recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestQueryGet(t *testing.T) {
+	router, base := newTestRouter(t)
+
+	t.Run("a number is a scalar", func(t *testing.T) {
+		body := decodeProm(t, serve(router, httptest.NewRequest(http.MethodGet, "/api/v1/query?query=1%2B1&time=100", nil)))
+		if body.Status != "error" {
+			t.Errorf("body = %+v, want an error for an expression", body)
+		}
+
+		recorder := serve(router, httptest.NewRequest(http.MethodGet, "/api/v1/query?query=2&time=100", nil))
+		if !strings.Contains(recorder.Body.String(), `"resultType":"scalar","result":[100,"2"]`) {
+			t.Errorf("body = %s, want the scalar 2 at 100", recorder.Body.String())
+		}
+	})
+
+	t.Run("a metric name is its newest point", func(t *testing.T) {
+		target := fmt.Sprintf("/api/v1/query?query=epever_array_power&time=%d", base+3600)
+		body := decodeProm(t, serve(router, httptest.NewRequest(http.MethodGet, target, nil)))
+		if body.Data.ResultType != "vector" || len(body.Data.Result) != 1 {
+			t.Fatalf("body = %+v, want one sample in a vector", body)
+		}
+		if value := body.Data.Result[0].Value; value[0] != float64(base+3540) || value[1] != "59" {
+			t.Errorf("value = %v, want [%d \"59\"]", value, base+3540)
+		}
+	})
+
+	t.Run("a point older than the lookback is not returned", func(t *testing.T) {
+		target := fmt.Sprintf("/api/v1/query?query=epever_array_power&time=%d", base+7200)
+		body := decodeProm(t, serve(router, httptest.NewRequest(http.MethodGet, target, nil)))
+		if body.Status != "success" || len(body.Data.Result) != 0 {
+			t.Errorf("body = %+v, want an empty vector", body)
+		}
+	})
+
+	t.Run("a bad time", func(t *testing.T) {
+		recorder := serve(router, httptest.NewRequest(http.MethodGet, "/api/v1/query?query=epever_array_power&time=noon", nil))
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", recorder.Code)
+		}
+	})
+}
+
+func TestLabelsGet(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/api/v1/labels", `{"status":"success","data":["__name__"]}`},
+		{"/api/v1/label/__name__/values", `{"status":"success","data":["epever_array_power"]}`},
+		{"/api/v1/label/job/values", `{"status":"success","data":[]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			for _, method := range []string{http.MethodGet, http.MethodPost} {
+				recorder := serve(router, httptest.NewRequest(method, tt.target, nil))
+				if recorder.Body.String() != tt.want {
+					t.Errorf("%s body = %s, want %s", method, recorder.Body.String(), tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{"voltgo_battery_soc", "voltgo_battery_soc", false},
+		{" voltgo_battery_soc{} ", "voltgo_battery_soc", false},
+		{`{__name__="voltgo_battery_soc"}`, "voltgo_battery_soc", false},
+		{`voltgo_battery_soc{battery="1"}`, "", true},
+		{"sum(voltgo_battery_soc)", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := parseSelector(tt.query)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseSelector() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
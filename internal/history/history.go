@@ -0,0 +1,127 @@
+// Package history keeps the metrics the controllers publish on disk, so the
+// web UI and Grafana can chart them without an external Prometheus. Every
+// sample is kept at full resolution for a while, and in 5 minute and 1 hour
+// buckets for longer.
+package history
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	namespace = "history"
+
+	// The resolutions a series is kept at, finest first
+	ResolutionRaw        = "raw"
+	ResolutionFiveMinute = "5m"
+	ResolutionHour       = "1h"
+
+	defaultRawRetention        = 48 * time.Hour
+	defaultFiveMinuteRetention = 30 * 24 * time.Hour
+	defaultHourRetention       = 365 * 24 * time.Hour
+)
+
+type Configuration struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Retention is how long each resolution is kept
+	Retention RetentionConfig `yaml:"retention"`
+}
+
+// RetentionConfig holds durations such as 48h or 30d.
+type RetentionConfig struct {
+	// Raw is how long every sample is kept (default: 48h)
+	Raw string `yaml:"raw"`
+
+	// FiveMinute is how long the 5 minute buckets are kept (default: 30d)
+	FiveMinute string `yaml:"fiveMinute"`
+
+	// Hour is how long the 1 hour buckets are kept (default: 365d)
+	Hour string `yaml:"hour"`
+}
+
+// Retention is the parsed RetentionConfig.
+type Retention struct {
+	Raw        time.Duration
+	FiveMinute time.Duration
+	Hour       time.Duration
+}
+
+// withDefaults fills in the zero fields.
+func (r Retention) withDefaults() Retention {
+	if r.Raw <= 0 {
+		r.Raw = defaultRawRetention
+	}
+	if r.FiveMinute <= 0 {
+		r.FiveMinute = defaultFiveMinuteRetention
+	}
+	if r.Hour <= 0 {
+		r.Hour = defaultHourRetention
+	}
+	return r
+}
+
+func (c *Configuration) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	var parsed []time.Duration
+	for _, retention := range []struct{ name, value string }{
+		{"raw", c.Retention.Raw},
+		{"fiveMinute", c.Retention.FiveMinute},
+		{"hour", c.Retention.Hour},
+	} {
+		if retention.value == "" {
+			parsed = append(parsed, 0)
+			continue
+		}
+		d, err := ParseDuration(retention.value)
+		if err != nil {
+			return fmt.Errorf("history %s retention: %w", retention.name, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("history %s retention must be positive", retention.name)
+		}
+		parsed = append(parsed, d)
+	}
+
+	// A coarser resolution kept for less time than a finer one would never
+	// be read
+	retention := Retention{Raw: parsed[0], FiveMinute: parsed[1], Hour: parsed[2]}.withDefaults()
+	if retention.Raw > retention.FiveMinute || retention.FiveMinute > retention.Hour {
+		return fmt.Errorf("history retention must not shorten from raw to fiveMinute to hour")
+	}
+	return nil
+}
+
+// GetRetention returns the retention of each resolution. Durations that are
+// unset or invalid take their defaults.
+func (c *Configuration) GetRetention() Retention {
+	var retention Retention
+	if d, err := ParseDuration(c.Retention.Raw); err == nil {
+		retention.Raw = d
+	}
+	if d, err := ParseDuration(c.Retention.FiveMinute); err == nil {
+		retention.FiveMinute = d
+	}
+	if d, err := ParseDuration(c.Retention.Hour); err == nil {
+		retention.Hour = d
+	}
+	return retention.withDefaults()
+}
+
+// ParseDuration parses a Go duration, or a whole number of days such as 30d.
+func ParseDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
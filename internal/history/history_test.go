@@ -0,0 +1,77 @@
+package history
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfiguration_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Configuration
+		wantErr string
+	}{
+		{name: "disabled", config: Configuration{Retention: RetentionConfig{Raw: "bogus"}}},
+		{name: "defaults", config: Configuration{Enabled: true}},
+		{
+			name:   "days",
+			config: Configuration{Enabled: true, Retention: RetentionConfig{Raw: "24h", FiveMinute: "7d", Hour: "730d"}},
+		},
+		{
+			name:    "invalid duration",
+			config:  Configuration{Enabled: true, Retention: RetentionConfig{FiveMinute: "a month"}},
+			wantErr: "fiveMinute retention",
+		},
+		{
+			name:    "zero retention",
+			config:  Configuration{Enabled: true, Retention: RetentionConfig{Hour: "0d"}},
+			wantErr: "hour retention must be positive",
+		},
+		{
+			name:    "raw longer than the 5m buckets",
+			config:  Configuration{Enabled: true, Retention: RetentionConfig{Raw: "60d"}},
+			wantErr: "must not shorten",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfiguration_GetRetention(t *testing.T) {
+	defaults := (&Configuration{}).GetRetention()
+	want := Retention{Raw: 48 * time.Hour, FiveMinute: 30 * 24 * time.Hour, Hour: 365 * 24 * time.Hour}
+	if defaults != want {
+		t.Errorf("GetRetention() = %+v, want %+v", defaults, want)
+	}
+
+	configured := (&Configuration{Retention: RetentionConfig{Raw: "12h", FiveMinute: "bogus", Hour: "90d"}}).GetRetention()
+	want = Retention{Raw: 12 * time.Hour, FiveMinute: 30 * 24 * time.Hour, Hour: 90 * 24 * time.Hour}
+	if configured != want {
+		t.Errorf("GetRetention() = %+v, want %+v", configured, want)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	if d, err := ParseDuration("30d"); err != nil || d != 30*24*time.Hour {
+		t.Errorf("ParseDuration(30d) = %v, %v, want 720h", d, err)
+	}
+	if d, err := ParseDuration("90m"); err != nil || d != 90*time.Minute {
+		t.Errorf("ParseDuration(90m) = %v, %v, want 90m", d, err)
+	}
+	if _, err := ParseDuration("1.5d"); err == nil {
+		t.Error("ParseDuration(1.5d) should fail")
+	}
+}
@@ -0,0 +1,15 @@
+package history
+
+// MetricsCollector defines the interface for collecting and exposing metrics.
+// This abstraction allows for testing without the Prometheus global registry.
+type MetricsCollector interface {
+	// IncrementWriteFailures increments the counter of samples that could
+	// not be written to the history segments.
+	IncrementWriteFailures()
+
+	// SetSeries updates the number of series with history.
+	SetSeries(count int)
+
+	// SetSamples updates the number of points kept at a resolution.
+	SetSamples(resolution string, count int)
+}
@@ -0,0 +1,58 @@
+package history
+
+import (
+	"sync"
+	"time"
+)
+
+// MockMetricsCollector is a mock implementation of the MetricsCollector interface for testing.
+type MockMetricsCollector struct {
+	mu sync.Mutex
+
+	// Call tracking
+	WriteFailures int
+	Series        int
+	Samples       map[string]int
+}
+
+// Verify MockMetricsCollector implements MetricsCollector
+var _ MetricsCollector = (*MockMetricsCollector)(nil)
+
+func (m *MockMetricsCollector) IncrementWriteFailures() {
+	m.mu.Lock()
+	m.WriteFailures++
+	m.mu.Unlock()
+}
+
+func (m *MockMetricsCollector) SetSeries(count int) {
+	m.mu.Lock()
+	m.Series = count
+	m.mu.Unlock()
+}
+
+func (m *MockMetricsCollector) SetSamples(resolution string, count int) {
+	m.mu.Lock()
+	if m.Samples == nil {
+		m.Samples = make(map[string]int)
+	}
+	m.Samples[resolution] = count
+	m.mu.Unlock()
+}
+
+// testNow is a time on the hour shortly before the tests run, so samples
+// from it fall into known buckets and within every retention.
+func testNow() time.Time {
+	return time.Now().Truncate(time.Hour)
+}
+
+// newTestStore creates a store under dataDir whose clock reads now.
+func newTestStore(dataDir string, now time.Time) (*Store, *MockMetricsCollector, error) {
+	metrics := &MockMetricsCollector{}
+	store, err := NewStore(dataDir, Retention{}, metrics)
+	if err != nil {
+		return nil, nil, err
+	}
+	store.now = func() time.Time { return now }
+	store.compacted = now
+	return store, metrics, nil
+}
@@ -0,0 +1,47 @@
+package history
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type PrometheusCollector struct {
+	writeFailures prometheus.Counter
+	series        prometheus.Gauge
+	samples       *prometheus.GaugeVec
+}
+
+func NewPrometheusCollector() *PrometheusCollector {
+	return &PrometheusCollector{
+		writeFailures: promauto.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "write_failures",
+			Help:      "Number of samples that could not be written to the history segments.",
+		}),
+		series: promauto.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "series",
+			Help:      "Number of metrics with history.",
+		}),
+		samples: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "samples",
+				Help:      "Number of points kept at each resolution.",
+			},
+			[]string{"resolution"},
+		),
+	}
+}
+
+func (h *PrometheusCollector) IncrementWriteFailures() {
+	h.writeFailures.Inc()
+}
+
+func (h *PrometheusCollector) SetSeries(count int) {
+	h.series.Set(float64(count))
+}
+
+func (h *PrometheusCollector) SetSamples(resolution string, count int) {
+	h.samples.WithLabelValues(resolution).Set(float64(count))
+}
@@ -0,0 +1,30 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Verify PrometheusCollector implements MetricsCollector
+var _ MetricsCollector = (*PrometheusCollector)(nil)
+
+// The collector registers with the global Prometheus registry via promauto,
+// so it can only be created once per test binary.
+func TestPrometheusCollector(t *testing.T) {
+	collector := NewPrometheusCollector()
+
+	collector.IncrementWriteFailures()
+	collector.SetSeries(3)
+	collector.SetSamples(ResolutionRaw, 120)
+
+	if got := testutil.ToFloat64(collector.writeFailures); got != 1 {
+		t.Errorf("history_write_failures = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(collector.series); got != 3 {
+		t.Errorf("history_series = %v, want 3", got)
+	}
+	if got := testutil.ToFloat64(collector.samples.WithLabelValues(ResolutionRaw)); got != 120 {
+		t.Errorf("history_samples{resolution=\"raw\"} = %v, want 120", got)
+	}
+}
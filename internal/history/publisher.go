@@ -0,0 +1,80 @@
+package history
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/lumberbarons/solar-controller/internal/publish"
+	log "github.com/sirupsen/logrus"
+)
+
+// Publisher passes every message on to the publisher it wraps and records
+// each numeric metric of this device in the store.
+type Publisher struct {
+	next     publish.MessagePublisher
+	store    *Store
+	deviceID string
+}
+
+// NewPublisher creates a publisher that records what passes through it to
+// next in store.
+func NewPublisher(next publish.MessagePublisher, store *Store, deviceID string) *Publisher {
+	// Default device ID if not provided
+	if deviceID == "" {
+		deviceID = "controller-1"
+	}
+	return &Publisher{next: next, store: store, deviceID: deviceID}
+}
+
+// NewPublisherFromConfig wraps next with a store kept under dataDir. This is
+// the production entry point; when history is disabled it returns next
+// unchanged and no store.
+func NewPublisherFromConfig(config Configuration, next publish.MessagePublisher, deviceID, dataDir string) (publish.MessagePublisher, *Store, error) {
+	if !config.Enabled {
+		return next, nil, nil
+	}
+
+	if dataDir == "" {
+		log.Warn("no dataDir configured, history will start over on every restart")
+	}
+
+	retention := config.GetRetention()
+	store, err := NewStore(dataDir, retention, NewPrometheusCollector())
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Infof("keeping history raw for %s, in 5m buckets for %s and in 1h buckets for %s",
+		retention.Raw, retention.FiveMinute, retention.Hour)
+
+	return NewPublisher(next, store, deviceID), store, nil
+}
+
+// Publish passes the message on, then records it if it is a numeric metric
+// of this device.
+func (p *Publisher) Publish(topicSuffix, payload string) {
+	p.next.Publish(topicSuffix, payload)
+
+	name, ok := strings.CutPrefix(topicSuffix, p.deviceID+"/")
+	if !ok || strings.Count(name, "/") != 1 {
+		return
+	}
+
+	var metric struct {
+		Value     float64 `json:"value"`
+		Unit      string  `json:"unit"`
+		Timestamp int64   `json:"timestamp"`
+	}
+	if err := json.Unmarshal([]byte(payload), &metric); err != nil || metric.Timestamp == 0 {
+		// Not a number, such as the voltgo cell-voltages list
+		return
+	}
+	p.store.Add(name, metric.Unit, metric.Timestamp, metric.Value)
+}
+
+// Close saves the store and closes the wrapped publisher.
+func (p *Publisher) Close() {
+	if err := p.store.Close(); err != nil {
+		log.Errorf("failed to close history: %s", err)
+	}
+	p.next.Close()
+}
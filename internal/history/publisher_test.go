@@ -0,0 +1,68 @@
+package history
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lumberbarons/solar-controller/internal/publish"
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+)
+
+func TestPublisher_RecordsNumericMetrics(t *testing.T) {
+	now := testNow()
+	store, _, err := newTestStore("", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := now.Unix()
+	next := &testutil.MockMessagePublisher{}
+	publisher := NewPublisher(next, store, "test-device-1")
+
+	publisher.Publish("test-device-1/epever/array-power", fmt.Sprintf(`{"value":120.5,"unit":"watts","timestamp":%d}`, ts))
+	publisher.Publish("test-device-1/virtual/mppt-efficiency", fmt.Sprintf(`{"value":95,"unit":"percent","timestamp":%d}`, ts))
+
+	// Another device, a list value, no timestamp, and a topic without a metric
+	publisher.Publish("other-device/epever/array-power", fmt.Sprintf(`{"value":1,"unit":"watts","timestamp":%d}`, ts))
+	publisher.Publish("test-device-1/voltgo/cell-voltages", fmt.Sprintf(`{"value":[{"cell":0,"value":3.3}],"unit":"volts","timestamp":%d}`, ts))
+	publisher.Publish("test-device-1/voltgo/availability", `{"value":1}`)
+	publisher.Publish("test-device-1/voltgo", fmt.Sprintf(`{"value":1,"unit":"count","timestamp":%d}`, ts))
+
+	if len(next.PublishCalls) != 6 {
+		t.Errorf("publish calls = %d, want every message passed on", len(next.PublishCalls))
+	}
+
+	series := store.Series()
+	if len(series) != 2 || series[0].Name != "epever/array-power" || series[1].Name != "virtual/mppt-efficiency" {
+		t.Fatalf("Series() = %+v, want epever/array-power and virtual/mppt-efficiency", series)
+	}
+	if raw := store.series["epever/array-power"].Raw; raw[0] != (sample{Timestamp: ts, Value: 120.5}) {
+		t.Errorf("recorded %+v, want 120.5 at %d", raw, ts)
+	}
+}
+
+func TestPublisher_Close(t *testing.T) {
+	store, _, err := newTestStore(t.TempDir(), testNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := &testutil.MockMessagePublisher{}
+	NewPublisher(next, store, "").Close()
+
+	if next.CloseCalls != 1 {
+		t.Errorf("CloseCalls = %d, want 1", next.CloseCalls)
+	}
+	if len(store.segments.files) != 0 {
+		t.Error("store segments still open after Close")
+	}
+}
+
+func TestNewPublisherFromConfig_Disabled(t *testing.T) {
+	next := &testutil.MockMessagePublisher{}
+	publisher, store, err := NewPublisherFromConfig(Configuration{}, next, "test-device-1", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewPublisherFromConfig() error = %v", err)
+	}
+	if publisher != publish.MessagePublisher(next) || store != nil {
+		t.Error("publisher wrapped with history disabled")
+	}
+}
@@ -0,0 +1,188 @@
+package history
+
+import (
+	"fmt"
+	"math"
+)
+
+// Point is a value of a series over a span: one sample at the raw
+// resolution, or the mean, lowest and highest of the samples in a bucket or
+// step.
+type Point struct {
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+
+	// count weights the point when steps merge points
+	count int
+}
+
+// Result is a series between two times.
+type Result struct {
+	Metric string `json:"metric"`
+	Unit   string `json:"unit"`
+
+	// Resolution is the one the points were read at; Step, in seconds, is
+	// the span each point covers when asked for, or zero for the points as
+	// they are stored
+	Resolution string  `json:"resolution"`
+	From       int64   `json:"from"`
+	To         int64   `json:"to"`
+	Step       int64   `json:"step"`
+	Points     []Point `json:"points"`
+}
+
+// Query returns the named series from from to to, in Unix seconds. With a
+// step, in seconds, the points are merged into one per step, aligned to the
+// Unix epoch.
+//
+// It reads the finest resolution that reaches back to from, or a coarser
+// one that still has a point per step, which is cheaper. A range older than
+// every retention reads the hourly buckets, which is all there is.
+func (s *Store) Query(name string, from, to, step int64) (*Result, error) {
+	return s.query(name, from, to, step, 0)
+}
+
+// query is Query with the steps aligned to origin, a Unix time, rather than
+// the epoch: Prometheus steps start at the start of the range.
+func (s *Store) query(name string, from, to, step, origin int64) (*Result, error) {
+	if to < from {
+		return nil, fmt.Errorf("to is before from")
+	}
+	if step < 0 {
+		return nil, fmt.Errorf("step must not be negative")
+	}
+	if step > 0 && (to-from)/step > maxPoints {
+		return nil, fmt.Errorf("%d points exceed the maximum of %d; use a longer step", (to-from)/step, maxPoints)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	se, ok := s.series[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownMetric, name)
+	}
+
+	resolution := s.resolution(from, step)
+	var points []Point
+	if resolution == ResolutionRaw {
+		for _, sample := range se.Raw {
+			if sample.Timestamp >= from && sample.Timestamp <= to {
+				points = append(points, Point{
+					Timestamp: sample.Timestamp,
+					Value:     sample.Value,
+					Min:       sample.Value,
+					Max:       sample.Value,
+					count:     1,
+				})
+			}
+		}
+	} else {
+		width := bucketWidth(resolution)
+		for _, b := range se.Buckets[resolution] {
+			if b.Start <= to && b.Start+width > from {
+				points = append(points, Point{
+					Timestamp: b.Start,
+					Value:     b.Sum / float64(b.Count),
+					Min:       b.Min,
+					Max:       b.Max,
+					count:     b.Count,
+				})
+			}
+		}
+	}
+
+	if step > 0 {
+		points = merge(points, step, origin)
+	}
+	if points == nil {
+		points = []Point{}
+	}
+	return &Result{
+		Metric:     name,
+		Unit:       se.Unit,
+		Resolution: resolution,
+		From:       from,
+		To:         to,
+		Step:       step,
+		Points:     points,
+	}, nil
+}
+
+// resolution picks the resolution a query from from with step reads.
+// Callers must hold mu.
+func (s *Store) resolution(from, step int64) string {
+	// chosen indexes raw, then the bucketed resolutions
+	now := s.now().Unix()
+	chosen := len(resolutions)
+	for i, name := range []string{ResolutionRaw, ResolutionFiveMinute, ResolutionHour} {
+		if from >= now-int64(s.retention.of(name).Seconds()) {
+			chosen = i
+			break
+		}
+	}
+
+	// A coarser resolution whose buckets are no wider than the step gives
+	// the same answer from fewer points
+	for chosen < len(resolutions) && step >= resolutions[chosen].width {
+		chosen++
+	}
+	if chosen == 0 {
+		return ResolutionRaw
+	}
+	return resolutions[chosen-1].name
+}
+
+func bucketWidth(resolution string) int64 {
+	for _, r := range resolutions {
+		if r.name == resolution {
+			return r.width
+		}
+	}
+	return 0
+}
+
+// merge combines points into one per step, weighting each by the samples
+// it holds. Steps start at origin and every step after it; a point before
+// origin, a bucket that began before the range, counts in the first. Points
+// must be in time order.
+func merge(points []Point, step, origin int64) []Point {
+	var merged []Point
+	var sum float64
+	for _, p := range points {
+		start := origin
+		if p.Timestamp > origin {
+			start = p.Timestamp - (p.Timestamp-origin)%step
+		}
+		n := len(merged)
+		if n == 0 || merged[n-1].Timestamp != start {
+			if n > 0 {
+				merged[n-1].Value = sum / float64(merged[n-1].count)
+			}
+			merged = append(merged, Point{Timestamp: start, Min: math.Inf(1), Max: math.Inf(-1)})
+			sum = 0
+			n++
+		}
+		last := &merged[n-1]
+		sum += p.Value * float64(p.count)
+		last.count += p.count
+		last.Min = min(last.Min, p.Min)
+		last.Max = max(last.Max, p.Max)
+	}
+	if n := len(merged); n > 0 {
+		merged[n-1].Value = sum / float64(merged[n-1].count)
+	}
+	return merged
+}
+
+// Latest returns the newest point of the named series at or before at, and
+// no older than lookback seconds, as Prometheus evaluates an instant query.
+func (s *Store) Latest(name string, at, lookback int64) (Point, bool) {
+	result, err := s.Query(name, at-lookback, at, 0)
+	if err != nil || len(result.Points) == 0 {
+		return Point{}, false
+	}
+	return result.Points[len(result.Points)-1], true
+}
@@ -0,0 +1,184 @@
+package history
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStore_Query(t *testing.T) {
+	now := testNow()
+	store, _, err := newTestStore("", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := now.Unix() - 3600
+	// One sample a minute for the last hour, valued by the minute
+	for i := int64(0); i < 60; i++ {
+		store.Add("epever/array-power", "watts", base+i*60, float64(i))
+	}
+
+	tests := []struct {
+		name           string
+		from, to, step int64
+		wantResolution string
+		wantPoints     int
+		wantFirst      Point
+	}{
+		{
+			name: "raw samples in the range", from: base, to: base + 240,
+			wantResolution: ResolutionRaw, wantPoints: 5,
+			wantFirst: Point{Timestamp: base, Value: 0, Min: 0, Max: 0},
+		},
+		{
+			name: "raw samples merged into steps", from: base, to: base + 239, step: 120,
+			wantResolution: ResolutionRaw, wantPoints: 2,
+			wantFirst: Point{Timestamp: base, Value: 0.5, Min: 0, Max: 1},
+		},
+		{
+			name: "a step of five minutes reads the 5m buckets", from: base, to: now.Unix(), step: 300,
+			wantResolution: ResolutionFiveMinute, wantPoints: 12,
+			wantFirst: Point{Timestamp: base, Value: 2, Min: 0, Max: 4},
+		},
+		{
+			name: "a step of an hour reads the 1h buckets", from: base, to: now.Unix(), step: 3600,
+			wantResolution: ResolutionHour, wantPoints: 1,
+			wantFirst: Point{Timestamp: base, Value: 29.5, Min: 0, Max: 59},
+		},
+		{
+			name: "a range past the raw retention reads the 5m buckets",
+			from: now.Add(-72 * time.Hour).Unix(), to: now.Unix(),
+			wantResolution: ResolutionFiveMinute, wantPoints: 12,
+			wantFirst: Point{Timestamp: base, Value: 2, Min: 0, Max: 4},
+		},
+		{
+			name: "a range past every retention reads the 1h buckets",
+			from: now.AddDate(-2, 0, 0).Unix(), to: now.Unix(), step: 86400,
+			wantResolution: ResolutionHour, wantPoints: 1,
+			wantFirst: Point{Timestamp: base - base%86400, Value: 29.5, Min: 0, Max: 59},
+		},
+		{
+			name: "a range with nothing in it", from: base - 7200, to: base - 3601,
+			wantResolution: ResolutionRaw, wantPoints: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := store.Query("epever/array-power", tt.from, tt.to, tt.step)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			if result.Resolution != tt.wantResolution || result.Unit != "watts" {
+				t.Errorf("resolution = %s, unit = %s, want %s in watts", result.Resolution, result.Unit, tt.wantResolution)
+			}
+			if len(result.Points) != tt.wantPoints {
+				t.Fatalf("got %d points, want %d", len(result.Points), tt.wantPoints)
+			}
+			if tt.wantPoints == 0 {
+				if result.Points == nil {
+					t.Error("Points should be empty, not nil, so it encodes as []")
+				}
+				return
+			}
+			first := result.Points[0]
+			first.count = 0
+			if first != tt.wantFirst {
+				t.Errorf("first point = %+v, want %+v", first, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestStore_QueryErrors(t *testing.T) {
+	now := testNow()
+	store, _, err := newTestStore("", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Add("epever/array-power", "watts", now.Unix(), 100)
+
+	tests := []struct {
+		name           string
+		metric         string
+		from, to, step int64
+	}{
+		{"to before from", "epever/array-power", now.Unix(), now.Unix() - 1, 0},
+		{"negative step", "epever/array-power", now.Unix() - 60, now.Unix(), -1},
+		{"too many points", "epever/array-power", now.Unix() - 86400, now.Unix(), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := store.Query(tt.metric, tt.from, tt.to, tt.step); err == nil {
+				t.Error("Query() expected an error")
+			}
+		})
+	}
+
+	_, err = store.Query("epever/load-power", now.Unix()-60, now.Unix(), 0)
+	if !errors.Is(err, ErrUnknownMetric) {
+		t.Errorf("Query() of an unknown metric error = %v, want ErrUnknownMetric", err)
+	}
+}
+
+func TestMerge_WeightsBySamples(t *testing.T) {
+	points := []Point{
+		{Timestamp: 0, Value: 10, Min: 5, Max: 20, count: 3},
+		{Timestamp: 300, Value: 40, Min: 40, Max: 40, count: 1},
+		{Timestamp: 600, Value: 7, Min: 7, Max: 7, count: 2},
+	}
+
+	merged := merge(points, 600, 0)
+	if len(merged) != 2 {
+		t.Fatalf("merge() = %+v, want 2 points", merged)
+	}
+	if p := merged[0]; p.Timestamp != 0 || p.Value != 17.5 || p.Min != 5 || p.Max != 40 || p.count != 4 {
+		t.Errorf("first step = %+v, want the mean of 4 samples, 17.5, from 5 to 40", p)
+	}
+	if p := merged[1]; p.Timestamp != 600 || p.Value != 7 || p.count != 2 {
+		t.Errorf("second step = %+v, want 7 from 2 samples", p)
+	}
+}
+
+func TestMerge_AlignsStepsToTheOrigin(t *testing.T) {
+	points := []Point{
+		{Timestamp: 0, Value: 1, Min: 1, Max: 1, count: 1},
+		{Timestamp: 300, Value: 2, Min: 2, Max: 2, count: 1},
+		{Timestamp: 900, Value: 3, Min: 3, Max: 3, count: 1},
+		{Timestamp: 1000, Value: 5, Min: 5, Max: 5, count: 1},
+	}
+
+	merged := merge(points, 600, 100)
+	if len(merged) != 2 {
+		t.Fatalf("merge() = %+v, want 2 points", merged)
+	}
+	if p := merged[0]; p.Timestamp != 100 || p.Value != 1.5 || p.count != 2 {
+		t.Errorf("first step = %+v, want 1.5 from the sample before the origin and the one after", p)
+	}
+	if p := merged[1]; p.Timestamp != 700 || p.Value != 4 || p.count != 2 {
+		t.Errorf("second step = %+v, want 4 from 2 samples", p)
+	}
+}
+
+func TestStore_Latest(t *testing.T) {
+	now := testNow()
+	store, _, err := newTestStore("", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Add("voltgo/battery-soc", "percent", now.Unix()-600, 70)
+	store.Add("voltgo/battery-soc", "percent", now.Unix()-120, 75)
+
+	if p, ok := store.Latest("voltgo/battery-soc", now.Unix(), 300); !ok || p.Value != 75 {
+		t.Errorf("Latest() = %+v, %v, want 75", p, ok)
+	}
+	if p, ok := store.Latest("voltgo/battery-soc", now.Unix()-300, 300); !ok || p.Value != 70 {
+		t.Errorf("Latest() five minutes ago = %+v, %v, want 70", p, ok)
+	}
+	if _, ok := store.Latest("voltgo/battery-soc", now.Unix()+600, 300); ok {
+		t.Error("Latest() should find nothing older than the lookback")
+	}
+	if _, ok := store.Latest("voltgo/battery-voltage", now.Unix(), 300); ok {
+		t.Error("Latest() should find nothing for an unknown metric")
+	}
+}
@@ -0,0 +1,291 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// segmentExtension ends the name of every segment file, which is
+// {resolution}-{window start}.jsonl: a JSON object per line.
+const segmentExtension = ".jsonl"
+
+// segmentWidths is the span of time, in seconds, one segment of each
+// resolution covers. A segment is deleted once all of it is past its
+// retention, so the disk holds up to one segment more than the retention.
+var segmentWidths = map[string]int64{
+	ResolutionRaw:        3600,
+	ResolutionFiveMinute: 24 * 3600,
+	ResolutionHour:       7 * 24 * 3600,
+}
+
+// rawEntry is one line of a raw segment.
+type rawEntry struct {
+	Series    string  `json:"s"`
+	Unit      string  `json:"u"`
+	Timestamp int64   `json:"t"`
+	Value     float64 `json:"v"`
+}
+
+// bucketEntry is one line of a bucket segment.
+type bucketEntry struct {
+	Series string `json:"s"`
+	Unit   string `json:"u"`
+	bucket
+}
+
+// segmentLine is an entry to append to the segment of resolution that holds
+// timestamp.
+type segmentLine struct {
+	resolution string
+	timestamp  int64
+	entry      any
+}
+
+func rawLine(name, unit string, timestamp int64, value float64) segmentLine {
+	return segmentLine{
+		resolution: ResolutionRaw,
+		timestamp:  timestamp,
+		entry:      rawEntry{Series: name, Unit: unit, Timestamp: timestamp, Value: value},
+	}
+}
+
+func bucketLine(name, unit, resolution string, b bucket) segmentLine {
+	return segmentLine{
+		resolution: resolution,
+		timestamp:  b.Start,
+		entry:      bucketEntry{Series: name, Unit: unit, bucket: b},
+	}
+}
+
+// segmentName returns the name of the segment of resolution that holds
+// timestamp.
+func segmentName(resolution string, timestamp int64) string {
+	return fmt.Sprintf("%s-%d%s", resolution, timestamp-timestamp%segmentWidths[resolution], segmentExtension)
+}
+
+// parseSegmentName reads a segment's resolution and window start from its
+// name. Other files are not segments.
+func parseSegmentName(name string) (resolution string, start int64, ok bool) {
+	base, ok := strings.CutSuffix(name, segmentExtension)
+	if !ok {
+		return "", 0, false
+	}
+	resolution, startText, ok := strings.Cut(base, "-")
+	if _, known := segmentWidths[resolution]; !ok || !known {
+		return "", 0, false
+	}
+	start, err := strconv.ParseInt(startText, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return resolution, start, true
+}
+
+// segments are the files of a store under one directory. Each is opened on
+// its first append and closed once its window has ended.
+type segments struct {
+	dir                 string
+	prometheusCollector MetricsCollector
+
+	// remove deletes a segment; tests replace it
+	remove func(name string) error
+
+	mu      sync.Mutex
+	files   map[string]*os.File
+	failing bool
+}
+
+func newSegments(dir string, prometheusCollector MetricsCollector) (*segments, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	return &segments{
+		dir:                 dir,
+		prometheusCollector: prometheusCollector,
+		remove:              os.Remove,
+		files:               make(map[string]*os.File),
+	}, nil
+}
+
+// read returns every bucket on disk, by resolution and series, and every raw
+// sample, and counts the lines it could not read: most likely the last of a
+// segment, cut short by a power cut.
+func (g *segments) read() (buckets map[string]map[string][]bucketEntry, raw []rawEntry, skipped int) {
+	buckets = make(map[string]map[string][]bucketEntry, len(resolutions))
+	for _, resolution := range resolutions {
+		buckets[resolution.name] = make(map[string][]bucketEntry)
+	}
+
+	entries, err := os.ReadDir(g.dir)
+	if err != nil {
+		log.Errorf("failed to list history segments: %s", err)
+		return buckets, nil, 0
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if _, _, ok := parseSegmentName(entry.Name()); ok {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		resolution, _, _ := parseSegmentName(name)
+		file, err := os.Open(filepath.Join(g.dir, name))
+		if err != nil {
+			log.Errorf("failed to read history segment %s: %s", name, err)
+			continue
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if resolution == ResolutionRaw {
+				var entry rawEntry
+				if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Series == "" {
+					skipped++
+					continue
+				}
+				raw = append(raw, entry)
+				continue
+			}
+
+			var entry bucketEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Series == "" || entry.Count == 0 {
+				skipped++
+				continue
+			}
+			buckets[resolution][entry.Series] = append(buckets[resolution][entry.Series], entry)
+		}
+		file.Close() // nolint:errcheck // Only read
+	}
+	return buckets, raw, skipped
+}
+
+// append writes lines to their segments. A line that cannot be written is
+// counted and dropped; what it held stays in memory.
+func (g *segments) append(lines []segmentLine) {
+	if len(lines) == 0 {
+		return
+	}
+
+	// Marshal before taking the lock, and group the lines by segment
+	var names []string
+	data := make(map[string][]byte)
+	counts := make(map[string]int)
+	for _, line := range lines {
+		encoded, err := json.Marshal(line.entry)
+		if err != nil {
+			g.failed(1, err)
+			continue
+		}
+		name := segmentName(line.resolution, line.timestamp)
+		if _, ok := data[name]; !ok {
+			names = append(names, name)
+		}
+		data[name] = append(append(data[name], encoded...), '\n')
+		counts[name]++
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, name := range names {
+		file, err := g.open(name)
+		if err == nil {
+			_, err = file.Write(data[name])
+		}
+		if err != nil {
+			g.failed(counts[name], err)
+			continue
+		}
+		if g.failing {
+			log.Info("writing history segments again")
+			g.failing = false
+		}
+	}
+}
+
+// open returns the named segment, opening it to append. Callers must hold mu.
+func (g *segments) open(name string) (*os.File, error) {
+	if file := g.files[name]; file != nil {
+		return file, nil
+	}
+	file, err := os.OpenFile(filepath.Join(g.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	g.files[name] = file
+	return file, nil
+}
+
+// failed counts lines that could not be written. The error is logged once
+// until writes work again, or a full disk would flood the log.
+func (g *segments) failed(lines int, err error) {
+	for range lines {
+		g.prometheusCollector.IncrementWriteFailures()
+	}
+	if !g.failing {
+		log.Errorf("failed to write history segment: %s", err)
+	}
+	g.failing = true
+}
+
+// expire closes the segments whose window has ended and deletes those past
+// their resolution's retention. Only closing holds mu.
+func (g *segments) expire(now time.Time, retention Retention) {
+	entries, err := os.ReadDir(g.dir)
+	if err != nil {
+		log.Errorf("failed to list history segments: %s", err)
+		return
+	}
+
+	var expired []string
+	g.mu.Lock()
+	for _, entry := range entries {
+		resolution, start, ok := parseSegmentName(entry.Name())
+		if !ok {
+			continue
+		}
+		end := start + segmentWidths[resolution]
+		if file := g.files[entry.Name()]; file != nil && end <= now.Unix() {
+			file.Close() // nolint:errcheck // Every write was checked
+			delete(g.files, entry.Name())
+		}
+		if end <= now.Add(-retention.of(resolution)).Unix() {
+			expired = append(expired, entry.Name())
+		}
+	}
+	g.mu.Unlock()
+
+	for _, name := range expired {
+		if err := g.remove(filepath.Join(g.dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Errorf("failed to delete history segment %s: %s", name, err)
+			continue
+		}
+		log.Debugf("deleted history segment %s", name)
+	}
+}
+
+// close closes every open segment.
+func (g *segments) close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var errs []error
+	for name, file := range g.files {
+		errs = append(errs, file.Close())
+		delete(g.files, name)
+	}
+	return errors.Join(errs...)
+}
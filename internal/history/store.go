@@ -0,0 +1,429 @@
+package history
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	dirName = "history"
+
+	// compactInterval is how often the store is pruned, the open buckets
+	// written and the segments past their retention deleted
+	compactInterval = time.Hour
+
+	// maxPoints caps a query, as Prometheus does, so a tiny step over a
+	// long range cannot exhaust the memory of a small device
+	maxPoints = 11000
+)
+
+// ErrUnknownMetric is returned for a metric with no history.
+var ErrUnknownMetric = errors.New("no history for metric")
+
+// resolutions are the bucketed resolutions and their widths in seconds.
+var resolutions = []struct {
+	name  string
+	width int64
+}{
+	{ResolutionFiveMinute, 300},
+	{ResolutionHour, 3600},
+}
+
+// sample is one published value.
+type sample struct {
+	Timestamp int64   `json:"t"`
+	Value     float64 `json:"v"`
+}
+
+// bucket summarises the samples from Start for one bucket width. Last is the
+// newest sample counted in it.
+type bucket struct {
+	Start int64   `json:"t"`
+	Sum   float64 `json:"sum"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int     `json:"n"`
+	Last  int64   `json:"last"`
+}
+
+func (b *bucket) add(timestamp int64, value float64) {
+	b.Sum += value
+	b.Min = min(b.Min, value)
+	b.Max = max(b.Max, value)
+	b.Count++
+	b.Last = timestamp
+}
+
+// closedBucket is a bucket a sample has just closed by starting the next.
+type closedBucket struct {
+	resolution string
+	bucket     bucket
+}
+
+// series is one metric at every resolution.
+type series struct {
+	Unit string
+
+	// Last is the newest sample's timestamp. A sample no newer is dropped
+	Last int64
+
+	Raw     []sample
+	Buckets map[string][]bucket
+}
+
+func newSeries(unit string) *series {
+	return &series{Unit: unit, Buckets: make(map[string][]bucket)}
+}
+
+// add records a sample and returns the buckets it closed, reporting whether
+// it was newer than the last.
+func (s *series) add(timestamp int64, value float64) ([]closedBucket, bool) {
+	if timestamp <= s.Last {
+		return nil, false
+	}
+	s.Last = timestamp
+	s.Raw = append(s.Raw, sample{Timestamp: timestamp, Value: value})
+
+	var closed []closedBucket
+	for _, resolution := range resolutions {
+		if previous, ok := s.addToBucket(resolution.name, resolution.width, timestamp, value); ok {
+			closed = append(closed, closedBucket{resolution: resolution.name, bucket: previous})
+		}
+	}
+	return closed, true
+}
+
+// addToBucket counts a sample in its bucket at one resolution. A sample
+// that starts a new bucket closes the one before, which is returned.
+func (s *series) addToBucket(resolution string, width, timestamp int64, value float64) (bucket, bool) {
+	buckets := s.Buckets[resolution]
+	start := timestamp - timestamp%width
+	n := len(buckets)
+	if n > 0 && buckets[n-1].Start == start {
+		buckets[n-1].add(timestamp, value)
+		return bucket{}, false
+	}
+	s.Buckets[resolution] = append(buckets, bucket{Start: start, Sum: value, Min: value, Max: value, Count: 1, Last: timestamp})
+	if n == 0 {
+		return bucket{}, false
+	}
+	return buckets[n-1], true
+}
+
+// prune drops what is past each resolution's retention and returns how many
+// points it dropped at each.
+func (s *series) prune(now int64, retention Retention) map[string]int {
+	dropped := make(map[string]int, len(resolutions)+1)
+
+	cut := now - int64(retention.Raw.Seconds())
+	keep := sort.Search(len(s.Raw), func(i int) bool { return s.Raw[i].Timestamp >= cut })
+	s.Raw = s.Raw[keep:]
+	dropped[ResolutionRaw] = keep
+
+	for _, resolution := range resolutions {
+		buckets := s.Buckets[resolution.name]
+		cut := now - int64(retention.of(resolution.name).Seconds())
+		keep := sort.Search(len(buckets), func(i int) bool { return buckets[i].Start+resolution.width > cut })
+		s.Buckets[resolution.name] = buckets[keep:]
+		dropped[resolution.name] = keep
+	}
+	return dropped
+}
+
+func (s *series) empty() bool {
+	if len(s.Raw) > 0 {
+		return false
+	}
+	for _, buckets := range s.Buckets {
+		if len(buckets) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// of returns the retention of a resolution.
+func (r Retention) of(resolution string) time.Duration {
+	switch resolution {
+	case ResolutionRaw:
+		return r.Raw
+	case ResolutionFiveMinute:
+		return r.FiveMinute
+	default:
+		return r.Hour
+	}
+}
+
+// Store keeps every series in memory, and on disk in segments: files of one
+// resolution over one window of time, only ever appended to. Each sample is
+// appended to the raw segment of its time as it arrives, and each bucket to
+// its resolution's segment as the next one starts. Every compactInterval,
+// and on Close, the buckets still open are appended too, and the segments
+// past their retention are deleted whole. On start the segments are read
+// back, the raw samples filling in what the buckets on disk do not count.
+//
+// Disk writes happen outside mu, so a slow SD card holds up neither Add nor
+// the queries.
+type Store struct {
+	retention           Retention
+	prometheusCollector MetricsCollector
+	segments            *segments
+	now                 func() time.Time
+
+	mu        sync.Mutex
+	series    map[string]*series
+	samples   map[string]int
+	compacted time.Time
+
+	// closed drops the samples added after Close; writing counts the
+	// segment writes and compactions under way outside mu, which Close
+	// waits for
+	closed  bool
+	writing sync.WaitGroup
+}
+
+// NewStore creates a store kept under dataDir/history, loading what is
+// there. An empty dataDir keeps the history in memory only.
+func NewStore(dataDir string, retention Retention, prometheusCollector MetricsCollector) (*Store, error) {
+	s := &Store{
+		retention:           retention.withDefaults(),
+		prometheusCollector: prometheusCollector,
+		now:                 time.Now,
+		series:              make(map[string]*series),
+		samples:             make(map[string]int),
+	}
+	s.compacted = s.now()
+
+	if dataDir != "" {
+		segments, err := newSegments(filepath.Join(dataDir, dirName), prometheusCollector)
+		if err != nil {
+			return nil, err
+		}
+		s.segments = segments
+		s.load()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneAll()
+	s.publishStats()
+	return s, nil
+}
+
+// load reads the segments back: the buckets first, then the raw samples
+// newer than what each bucket on disk had counted. A bucket written more
+// than once, open and then closed, keeps the write that counted the most.
+func (s *Store) load() {
+	buckets, raw, skipped := s.segments.read()
+	if skipped > 0 {
+		log.Warnf("skipped %d unreadable lines of the history segments", skipped)
+	}
+
+	// counted is the newest sample each series' buckets had counted, by
+	// resolution
+	counted := make(map[string]map[string]int64)
+	for _, resolution := range resolutions {
+		for name, entries := range buckets[resolution.name] {
+			se := s.seriesFor(name, entries[0].Unit)
+			kept := make(map[int64]bucket, len(entries))
+			for _, entry := range entries {
+				if b, ok := kept[entry.Start]; !ok || entry.Last > b.Last {
+					kept[entry.Start] = entry.bucket
+				}
+			}
+			for _, b := range kept {
+				se.Buckets[resolution.name] = append(se.Buckets[resolution.name], b)
+				se.Last = max(se.Last, b.Last)
+				if counted[name] == nil {
+					counted[name] = make(map[string]int64)
+				}
+				counted[name][resolution.name] = max(counted[name][resolution.name], b.Last)
+			}
+			sort.Slice(se.Buckets[resolution.name], func(i, j int) bool {
+				return se.Buckets[resolution.name][i].Start < se.Buckets[resolution.name][j].Start
+			})
+			s.samples[resolution.name] += len(kept)
+		}
+	}
+
+	sort.SliceStable(raw, func(i, j int) bool { return raw[i].Timestamp < raw[j].Timestamp })
+	for _, entry := range raw {
+		se := s.seriesFor(entry.Series, entry.Unit)
+		if n := len(se.Raw); n > 0 && entry.Timestamp <= se.Raw[n-1].Timestamp {
+			continue
+		}
+		se.Raw = append(se.Raw, sample{Timestamp: entry.Timestamp, Value: entry.Value})
+		se.Last = max(se.Last, entry.Timestamp)
+		s.samples[ResolutionRaw]++
+		for _, resolution := range resolutions {
+			if entry.Timestamp <= counted[entry.Series][resolution.name] {
+				continue
+			}
+			before := len(se.Buckets[resolution.name])
+			se.addToBucket(resolution.name, resolution.width, entry.Timestamp, entry.Value)
+			s.samples[resolution.name] += len(se.Buckets[resolution.name]) - before
+		}
+	}
+	log.Infof("loaded history of %d metrics, %d raw samples", len(s.series), len(raw))
+}
+
+// seriesFor returns the named series, creating it. Callers must hold mu,
+// or be loading.
+func (s *Store) seriesFor(name, unit string) *series {
+	se := s.series[name]
+	if se == nil {
+		se = newSeries(unit)
+		s.series[name] = se
+	}
+	return se
+}
+
+// Add records a sample of the named series, such as voltgo/battery-soc. A
+// sample no newer than the series' last, or added after Close, is dropped.
+func (s *Store) Add(name, unit string, timestamp int64, value float64) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	se := s.seriesFor(name, unit)
+	before := make(map[string]int, len(resolutions))
+	for _, resolution := range resolutions {
+		before[resolution.name] = len(se.Buckets[resolution.name])
+	}
+	closed, ok := se.add(timestamp, value)
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	se.Unit = unit
+	s.samples[ResolutionRaw]++
+	for _, resolution := range resolutions {
+		s.samples[resolution.name] += len(se.Buckets[resolution.name]) - before[resolution.name]
+	}
+	for resolution, dropped := range se.prune(s.now().Unix(), s.retention) {
+		s.samples[resolution] -= dropped
+	}
+
+	compact := s.now().Sub(s.compacted) >= compactInterval
+	if compact {
+		s.compacted = s.now()
+		s.writing.Add(1)
+	}
+	s.writing.Add(1)
+	defer s.writing.Done()
+	s.publishStats()
+	s.mu.Unlock()
+
+	if s.segments != nil {
+		lines := []segmentLine{rawLine(name, unit, timestamp, value)}
+		for _, c := range closed {
+			lines = append(lines, bucketLine(name, unit, c.resolution, c.bucket))
+		}
+		s.segments.append(lines)
+	}
+	if compact {
+		go func() {
+			defer s.writing.Done()
+			s.compact()
+		}()
+	}
+}
+
+// pruneAll drops what is past its retention from every series, and the
+// series that are left empty. Callers must hold mu.
+func (s *Store) pruneAll() {
+	now := s.now().Unix()
+	for name, se := range s.series {
+		for resolution, dropped := range se.prune(now, s.retention) {
+			s.samples[resolution] -= dropped
+		}
+		if se.empty() {
+			delete(s.series, name)
+		}
+	}
+}
+
+// compact prunes the store, appends the buckets still open and deletes the
+// segments past their retention. Only the pruning holds mu.
+func (s *Store) compact() {
+	s.mu.Lock()
+	s.pruneAll()
+	s.publishStats()
+	var open []segmentLine
+	if s.segments != nil {
+		for name, se := range s.series {
+			for _, resolution := range resolutions {
+				if buckets := se.Buckets[resolution.name]; len(buckets) > 0 {
+					open = append(open, bucketLine(name, se.Unit, resolution.name, buckets[len(buckets)-1]))
+				}
+			}
+		}
+	}
+	now := s.now()
+	s.mu.Unlock()
+
+	if s.segments == nil {
+		return
+	}
+	s.segments.append(open)
+	s.segments.expire(now, s.retention)
+}
+
+// publishStats updates the store's gauges. Callers must hold mu.
+func (s *Store) publishStats() {
+	s.prometheusCollector.SetSeries(len(s.series))
+	for _, resolution := range []string{ResolutionRaw, ResolutionFiveMinute, ResolutionHour} {
+		s.prometheusCollector.SetSamples(resolution, s.samples[resolution])
+	}
+}
+
+// SeriesInfo describes one metric with history.
+type SeriesInfo struct {
+	// Name is namespace/metric, as published under the device ID
+	Name string `json:"name"`
+	Unit string `json:"unit"`
+
+	// PrometheusName is what the query API calls it
+	PrometheusName string `json:"prometheusName"`
+}
+
+// Series lists every metric with history, by name.
+func (s *Store) Series() []SeriesInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	infos := make([]SeriesInfo, 0, len(s.series))
+	for name, se := range s.series {
+		infos = append(infos, SeriesInfo{Name: name, Unit: se.Unit, PrometheusName: PrometheusName(name)})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// PrometheusName converts namespace/metric-name into namespace_metric_name,
+// the name the remote write publisher gives the same metric.
+func PrometheusName(name string) string {
+	return strings.NewReplacer("/", "_", "-", "_").Replace(name)
+}
+
+// Close stops taking samples, waits for the writes and compaction under
+// way, appends the open buckets and closes the segments.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	s.writing.Wait()
+	s.compact()
+	if s.segments == nil {
+		return nil
+	}
+	return s.segments.close()
+}
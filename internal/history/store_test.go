@@ -0,0 +1,351 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStore_Add(t *testing.T) {
+	now := testNow()
+	store, metrics, err := newTestStore("", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := now.Unix() - 600
+
+	for _, offset := range []int64{0, 60, 120, 360} {
+		store.Add("epever/array-power", "watts", base+offset, float64(offset))
+	}
+	// Out of order, and a repeat: both dropped
+	store.Add("epever/array-power", "watts", base+300, 1000)
+	store.Add("epever/array-power", "watts", base+360, 1000)
+
+	se := store.series["epever/array-power"]
+	if len(se.Raw) != 4 || se.Unit != "watts" || se.Last != base+360 {
+		t.Fatalf("series = %+v, want the 4 samples in order", se)
+	}
+
+	fiveMinute := se.Buckets[ResolutionFiveMinute]
+	if len(fiveMinute) != 2 {
+		t.Fatalf("5m buckets = %+v, want 2", fiveMinute)
+	}
+	if b := fiveMinute[0]; b.Start != base || b.Count != 3 || b.Sum != 180 || b.Min != 0 || b.Max != 120 {
+		t.Errorf("first 5m bucket = %+v, want 3 samples from 0 to 120", b)
+	}
+	if hour := se.Buckets[ResolutionHour]; len(hour) != 1 || hour[0].Count != 4 {
+		t.Errorf("1h buckets = %+v, want one of 4 samples", hour)
+	}
+
+	if metrics.Series != 1 || metrics.Samples[ResolutionRaw] != 4 || metrics.Samples[ResolutionFiveMinute] != 2 ||
+		metrics.Samples[ResolutionHour] != 1 {
+		t.Errorf("stats = %d series, %v samples, want 1 series of 4 raw, 2 5m and 1 1h", metrics.Series, metrics.Samples)
+	}
+}
+
+func TestStore_Retention(t *testing.T) {
+	now := testNow()
+	metrics := &MockMetricsCollector{}
+	store, err := NewStore("", Retention{Raw: time.Hour, FiveMinute: 2 * time.Hour, Hour: 3 * time.Hour}, metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.now = func() time.Time { return now }
+
+	store.Add("voltgo/battery-soc", "percent", now.Unix()-150*60, 80)
+	store.Add("voltgo/battery-soc", "percent", now.Unix()-90*60, 75)
+	store.Add("voltgo/battery-soc", "percent", now.Unix()-30*60, 70)
+
+	se := store.series["voltgo/battery-soc"]
+	if len(se.Raw) != 1 || se.Raw[0].Value != 70 {
+		t.Errorf("raw = %+v, want only the sample of the last hour", se.Raw)
+	}
+	if len(se.Buckets[ResolutionFiveMinute]) != 2 {
+		t.Errorf("5m buckets = %+v, want the 2 of the last two hours", se.Buckets[ResolutionFiveMinute])
+	}
+	if len(se.Buckets[ResolutionHour]) != 3 {
+		t.Errorf("1h buckets = %+v, want 3", se.Buckets[ResolutionHour])
+	}
+	if metrics.Samples[ResolutionRaw] != 1 || metrics.Samples[ResolutionFiveMinute] != 2 {
+		t.Errorf("samples = %v, want 1 raw and 2 5m after pruning", metrics.Samples)
+	}
+
+	// A series that stops reporting is pruned away at compaction
+	store.now = func() time.Time { return now.Add(4 * time.Hour) }
+	store.compact()
+	if len(store.Series()) != 0 {
+		t.Errorf("Series() = %+v, want none left", store.Series())
+	}
+}
+
+func TestStore_Persistence(t *testing.T) {
+	now := testNow()
+	dataDir := t.TempDir()
+
+	store, _, err := newTestStore(dataDir, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Add("epever/array-power", "watts", now.Unix()-120, 100)
+	store.Add("epever/array-power", "watts", now.Unix()-60, 200)
+
+	t.Run("raw samples are replayed after a crash", func(t *testing.T) {
+		reopened, _, err := newTestStore(dataDir, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reopened.segments.close() // nolint:errcheck // Test cleanup
+		se := reopened.series["epever/array-power"]
+		if se == nil || len(se.Raw) != 2 || se.Buckets[ResolutionHour][0].Count != 2 {
+			t.Fatalf("reopened series = %+v, want the 2 written samples", se)
+		}
+	})
+
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	t.Run("a bucket written open is not counted twice, and a torn line is skipped", func(t *testing.T) {
+		path := filepath.Join(dataDir, dirName, segmentName(ResolutionRaw, now.Unix()-60))
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o640)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.WriteString(`{"s":"epever/array-po`); err != nil {
+			t.Fatal(err)
+		}
+		if err := file.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		reopened, _, err := newTestStore(dataDir, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		se := reopened.series["epever/array-power"]
+		if se == nil || len(se.Raw) != 2 || se.Buckets[ResolutionHour][0].Count != 2 || se.Buckets[ResolutionFiveMinute][0].Count != 2 {
+			t.Fatalf("reopened series = %+v, want the 2 samples once", se)
+		}
+
+		// Closing the 5m bucket writes it again, as it was when open
+		reopened.Add("epever/array-power", "watts", now.Unix(), 300)
+		if err := reopened.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+	})
+
+	t.Run("a bucket written open and then closed is counted once", func(t *testing.T) {
+		reopened, _, err := newTestStore(dataDir, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reopened.Close() // nolint:errcheck // Test cleanup
+		se := reopened.series["epever/array-power"]
+		if se == nil || len(se.Raw) != 3 {
+			t.Fatalf("reopened series = %+v, want the 3 samples", se)
+		}
+		if b := se.Buckets[ResolutionFiveMinute]; len(b) != 2 || b[0].Count != 2 || b[1].Count != 1 {
+			t.Errorf("5m buckets = %+v, want 2 samples and 1", b)
+		}
+		if b := se.Buckets[ResolutionHour]; len(b) != 2 || b[0].Count != 2 || b[1].Count != 1 {
+			t.Errorf("1h buckets = %+v, want 2 samples and 1", b)
+		}
+	})
+}
+
+func TestStore_DeletesSegmentsPastRetention(t *testing.T) {
+	now := testNow()
+	dataDir := t.TempDir()
+	store, err := NewStore(dataDir, Retention{Raw: time.Hour}, &MockMetricsCollector{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close() // nolint:errcheck // Test cleanup
+	store.now = func() time.Time { return now }
+
+	store.Add("voltgo/battery-soc", "percent", now.Unix()-3*3600, 80)
+	store.Add("voltgo/battery-soc", "percent", now.Unix()-30*60, 70)
+	other := filepath.Join(dataDir, dirName, "notes.txt")
+	if err := os.WriteFile(other, nil, 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	store.compact()
+
+	dir := filepath.Join(dataDir, dirName)
+	if _, err := os.Stat(filepath.Join(dir, segmentName(ResolutionRaw, now.Unix()-3*3600))); !os.IsNotExist(err) {
+		t.Errorf("raw segment past retention: %v, want it deleted", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, segmentName(ResolutionRaw, now.Unix()-30*60))); err != nil {
+		t.Errorf("raw segment within retention: %v, want it kept", err)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("file that is not a segment: %v, want it kept", err)
+	}
+}
+
+func TestStore_CompactsEveryInterval(t *testing.T) {
+	now := testNow()
+	dataDir := t.TempDir()
+	store, _, err := newTestStore(dataDir, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close() // nolint:errcheck // Test cleanup
+	hourSegment := filepath.Join(dataDir, dirName, segmentName(ResolutionHour, now.Unix()))
+
+	store.Add("epever/array-power", "watts", now.Unix(), 100)
+	store.writing.Wait()
+	if _, err := os.Stat(hourSegment); err == nil {
+		t.Fatal("open bucket written before the compaction interval")
+	}
+
+	store.now = func() time.Time { return now.Add(compactInterval) }
+	store.Add("epever/array-power", "watts", now.Unix()+60, 200)
+	store.writing.Wait()
+	if _, err := os.Stat(hourSegment); err != nil {
+		t.Errorf("open bucket not written after the compaction interval: %v", err)
+	}
+}
+
+func TestStore_AddDoesNotBlockDuringCompaction(t *testing.T) {
+	now := testNow()
+	dataDir := t.TempDir()
+	store, _, err := newTestStore(dataDir, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close() // nolint:errcheck // Test cleanup
+
+	// A segment long past retention, whose deletion stalls as on a slow card
+	expired := filepath.Join(dataDir, dirName, segmentName(ResolutionRaw, 0))
+	if err := os.WriteFile(expired, nil, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	removing := make(chan struct{})
+	release := make(chan struct{})
+	store.segments.remove = func(name string) error {
+		close(removing)
+		<-release
+		return os.Remove(name)
+	}
+
+	compacted := make(chan struct{})
+	go func() {
+		store.compact()
+		close(compacted)
+	}()
+	<-removing
+
+	added := make(chan struct{})
+	go func() {
+		store.Add("epever/array-power", "watts", now.Unix(), 100)
+		close(added)
+	}()
+	select {
+	case <-added:
+	case <-time.After(5 * time.Second):
+		t.Error("Add blocked while a compaction was deleting a segment")
+	}
+
+	close(release)
+	<-compacted
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Errorf("expired segment: %v, want it deleted", err)
+	}
+	if len(store.series["epever/array-power"].Raw) != 1 {
+		t.Error("sample added during the compaction was not kept")
+	}
+}
+
+func TestStore_AddDuringClose(t *testing.T) {
+	now := testNow()
+	store, _, err := newTestStore(t.TempDir(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.now = func() time.Time { return now.Add(compactInterval) }
+
+	var adding sync.WaitGroup
+	for i := range 4 {
+		adding.Add(1)
+		go func() {
+			defer adding.Done()
+			for j := range 100 {
+				store.Add(fmt.Sprintf("epever/metric-%d", i), "watts", now.Unix()+int64(j), float64(j))
+			}
+		}()
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	adding.Wait()
+
+	store.Add("epever/array-power", "watts", now.Unix(), 100)
+	if _, ok := store.series["epever/array-power"]; ok {
+		t.Error("sample added after Close was kept")
+	}
+	if len(store.segments.files) != 0 {
+		t.Errorf("segments open after Close: %v, want none", store.segments.files)
+	}
+}
+
+func TestStore_WriteFailures(t *testing.T) {
+	now := testNow()
+	dataDir := t.TempDir()
+	store, metrics, err := newTestStore(dataDir, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close() // nolint:errcheck // Test cleanup
+
+	// Segments cannot be created where the directory was
+	dir := filepath.Join(dataDir, dirName)
+	if err := os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir, nil, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	store.Add("epever/array-power", "watts", now.Unix(), 100)
+	store.Add("epever/array-power", "watts", now.Unix()+1, 100)
+
+	if metrics.WriteFailures != 2 || !store.segments.failing {
+		t.Errorf("WriteFailures = %d, want 2 while the segments cannot be written", metrics.WriteFailures)
+	}
+	if len(store.series["epever/array-power"].Raw) != 2 {
+		t.Error("samples that could not be written should still be kept in memory")
+	}
+
+	if err := os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(dir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	store.Add("epever/array-power", "watts", now.Unix()+2, 100)
+	if metrics.WriteFailures != 2 || store.segments.failing {
+		t.Errorf("WriteFailures = %d after the directory came back, want still 2", metrics.WriteFailures)
+	}
+}
+
+func TestStore_Series(t *testing.T) {
+	store, _, err := newTestStore("", testNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Add("voltgo/battery-soc", "percent", testNow().Unix(), 80)
+	store.Add("epever/array-power", "watts", testNow().Unix(), 100)
+
+	series := store.Series()
+	want := []SeriesInfo{
+		{Name: "epever/array-power", Unit: "watts", PrometheusName: "epever_array_power"},
+		{Name: "voltgo/battery-soc", Unit: "percent", PrometheusName: "voltgo_battery_soc"},
+	}
+	if len(series) != len(want) || series[0] != want[0] || series[1] != want[1] {
+		t.Errorf("Series() = %+v, want %+v", series, want)
+	}
+}
@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChannelStatus is a channel as GET /api/notifications lists it. Its
+// credentials are never shown.
+type ChannelStatus struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	MinSeverity string `json:"minSeverity"`
+
+	// Sent, Failed and Dropped count notifications since startup
+	Sent    int `json:"sent"`
+	Failed  int `json:"failed"`
+	Dropped int `json:"dropped"`
+
+	// LastSent is when the last notification was delivered, in Unix
+	// seconds, and LastError why the last failure failed; each is null
+	// before it happens
+	LastSent  *int64  `json:"lastSent"`
+	LastError *string `json:"lastError"`
+}
+
+// ChannelsResponse is the body of GET /api/notifications.
+type ChannelsResponse struct {
+	Channels []ChannelStatus `json:"channels"`
+}
+
+// ChannelsGet lists the channels and what they have sent.
+func (p *Publisher) ChannelsGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, ChannelsResponse{Channels: p.Statuses()})
+	}
+}
+
+// TestPost sends a test notification through a channel.
+func (p *Publisher) TestPost() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := p.Test(c.Request.Context(), c.Param("name"))
+		switch {
+		case errors.Is(err, ErrUnknownChannel):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case err != nil:
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusNoContent, gin.H{})
+		}
+	}
+}
+
+// RegisterEndpoints adds the notification endpoints.
+func (p *Publisher) RegisterEndpoints(r *gin.Engine) {
+	r.GET("/api/notifications", p.ChannelsGet())
+	r.POST("/api/notifications/:name/test", p.TestPost())
+}
@@ -0,0 +1,32 @@
+package notify
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ChannelsGet serialises ChannelsResponse directly; each channel is checked
+// on its own.
+func TestChannelsMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(ChannelsResponse{Channels: []ChannelStatus{}})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/notifications"),
+		testutil.JSONFieldNames(t, payload),
+		"ChannelsResponse no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+
+	status, err := json.Marshal(ChannelStatus{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/notifications#channels[]"),
+		testutil.JSONFieldNames(t, status),
+		"ChannelStatus no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestRouter(t *testing.T) (*gin.Engine, []*fakeSender) {
+	t.Helper()
+
+	publisher, _, fakes, _ := newTestPublisher(t,
+		Channel{Name: "phone", Type: TypeNtfy},
+		Channel{Name: "email", Type: TypeSMTP, MinSeverity: "critical"},
+	)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	publisher.RegisterEndpoints(router)
+	return router, fakes
+}
+
+func TestTestPost(t *testing.T) {
+	router, fakes := newTestRouter(t)
+	fakes[1].failures, fakes[1].err = 1, errUnavailable
+
+	tests := []struct {
+		name       string
+		wantStatus int
+	}{
+		{"phone", http.StatusNoContent},
+		{"email", http.StatusBadGateway},
+		{"pager", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/notifications/"+tt.name+"/test", nil))
+		if recorder.Code != tt.wantStatus {
+			t.Errorf("test %s: status = %d, want %d: %s", tt.name, recorder.Code, tt.wantStatus, recorder.Body.String())
+		}
+	}
+}
+
+func TestChannelsGet(t *testing.T) {
+	router, fakes := newTestRouter(t)
+	fakes[1].failures, fakes[1].err = 1, errUnavailable
+	for _, name := range []string{"phone", "email"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/notifications/"+name+"/test", nil))
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/notifications", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+
+	var response ChannelsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Channels) != 2 {
+		t.Fatalf("channels = %+v, want 2", response.Channels)
+	}
+	phone, email := response.Channels[0], response.Channels[1]
+	if phone.Name != "phone" || phone.Type != TypeNtfy || phone.MinSeverity != "info" || phone.Sent != 1 || phone.LastSent == nil || phone.LastError != nil {
+		t.Errorf("phone = %+v, want one sent", phone)
+	}
+	if email.MinSeverity != "critical" || email.Failed != 1 || email.LastError == nil || *email.LastError != errUnavailable.Error() {
+		t.Errorf("email = %+v, want one failed", email)
+	}
+}
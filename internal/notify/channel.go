@@ -0,0 +1,206 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+	"github.com/lumberbarons/solar-controller/internal/alerts"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// queueSize is how many notifications wait for a channel before the
+	// newest are dropped
+	queueSize = 32
+
+	// The reasons a notification is dropped
+	reasonRateLimited = "rate_limited"
+	reasonQueueFull   = "queue_full"
+)
+
+// sender delivers one notification in one attempt.
+type sender interface {
+	send(ctx context.Context, n Notification) error
+}
+
+// unrecoverable marks an error that retrying will not fix.
+func unrecoverable(err error) error {
+	return retry.Unrecoverable(err)
+}
+
+func newSender(config *Channel) (sender, error) {
+	client := &http.Client{Timeout: config.GetTimeout()}
+	switch config.Type {
+	case TypeSMTP:
+		return newSMTPSender(config.SMTP), nil
+	case TypeNtfy:
+		return &ntfySender{config: config.Ntfy, client: client}, nil
+	case TypeGotify:
+		return &gotifySender{config: config.Gotify, client: client}, nil
+	case TypeWebhook:
+		return newWebhookSender(config.Webhook, client)
+	default:
+		return nil, fmt.Errorf("unknown channel type %q", config.Type)
+	}
+}
+
+// channel sends the notifications it accepts one at a time, in the order
+// they were queued.
+type channel struct {
+	config              Channel
+	sender              sender
+	prometheusCollector MetricsCollector
+	now                 func() time.Time
+	queue               chan Notification
+
+	mu        sync.Mutex
+	limiter   limiter
+	sent      int
+	failed    int
+	dropped   int
+	lastSent  int64
+	lastError string
+}
+
+func newChannel(config Channel, sender sender, prometheusCollector MetricsCollector, now func() time.Time) *channel {
+	perHour := float64(config.GetMaxPerHour())
+	return &channel{
+		config:              config,
+		sender:              sender,
+		prometheusCollector: prometheusCollector,
+		now:                 now,
+		queue:               make(chan Notification, queueSize),
+		limiter:             limiter{capacity: perHour, tokens: perHour, rate: perHour / time.Hour.Seconds()},
+	}
+}
+
+// accepts reports whether the channel sends n: severe enough, and resolved
+// only if the channel sends resolved alerts.
+func (c *channel) accepts(n Notification) bool {
+	if severityRank[n.Severity] < severityRank[c.config.GetMinSeverity()] {
+		return false
+	}
+	return n.State != alerts.StateResolved || c.config.GetSendResolved()
+}
+
+// enqueue queues n unless the channel is over its rate or its queue is full.
+func (c *channel) enqueue(n Notification) {
+	c.mu.Lock()
+	allowed := c.limiter.allow(c.now())
+	c.mu.Unlock()
+	if !allowed {
+		c.drop(n, reasonRateLimited)
+		return
+	}
+
+	select {
+	case c.queue <- n:
+	default:
+		c.drop(n, reasonQueueFull)
+	}
+}
+
+func (c *channel) drop(n Notification, reason string) {
+	log.Warnf("dropped notification %q on channel %s: %s", n.Title, c.config.Name, reason)
+
+	c.mu.Lock()
+	c.dropped++
+	c.mu.Unlock()
+	c.prometheusCollector.IncrementDropped(c.config.Name, reason)
+}
+
+// run sends what is queued until the queue is closed. Closing ctx abandons
+// the retries of the notification being sent.
+func (c *channel) run(ctx context.Context) {
+	for n := range c.queue {
+		if ctx.Err() != nil {
+			return
+		}
+		_ = c.deliver(ctx, n, c.config.GetRetries())
+	}
+}
+
+// deliver sends n, retrying a failure with a doubling delay, and records the
+// outcome.
+func (c *channel) deliver(ctx context.Context, n Notification, retries int) error {
+	err := retry.Do(
+		func() error {
+			attemptCtx, cancel := context.WithTimeout(ctx, c.config.GetTimeout())
+			defer cancel()
+			return c.sender.send(attemptCtx, n)
+		},
+		retry.Context(ctx),
+		retry.Attempts(uint(retries)+1),
+		retry.Delay(c.config.GetRetryDelay()),
+		retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true),
+		retry.OnRetry(func(attempt uint, err error) {
+			log.Warnf("notification channel %s retry #%d: %s", c.config.Name, attempt+1, err)
+		}),
+	)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		log.Errorf("failed to send notification %q on channel %s: %s", n.Title, c.config.Name, err)
+		c.failed++
+		c.lastError = err.Error()
+		c.prometheusCollector.IncrementFailed(c.config.Name)
+		return err
+	}
+
+	log.Debugf("sent notification %q on channel %s", n.Title, c.config.Name)
+	c.sent++
+	c.lastSent = c.now().Unix()
+	c.prometheusCollector.IncrementSent(c.config.Name)
+	return nil
+}
+
+// status reports the channel for the API.
+func (c *channel) status() ChannelStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := ChannelStatus{
+		Name:        c.config.Name,
+		Type:        c.config.Type,
+		MinSeverity: c.config.GetMinSeverity(),
+		Sent:        c.sent,
+		Failed:      c.failed,
+		Dropped:     c.dropped,
+	}
+	if c.lastSent != 0 {
+		lastSent := c.lastSent
+		status.LastSent = &lastSent
+	}
+	if c.lastError != "" {
+		lastError := c.lastError
+		status.LastError = &lastError
+	}
+	return status
+}
+
+// limiter is a token bucket: up to capacity notifications at once, refilled
+// at rate per second.
+type limiter struct {
+	capacity float64
+	tokens   float64
+	rate     float64
+	last     time.Time
+}
+
+func (l *limiter) allow(now time.Time) bool {
+	if !l.last.IsZero() {
+		l.tokens = min(l.capacity, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/alerts"
+)
+
+func TestChannelAccepts(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Channel
+		severity string
+		state    string
+		want     bool
+	}{
+		{"info by default", Channel{}, alerts.SeverityInfo, alerts.StateFiring, true},
+		{"below minSeverity", Channel{MinSeverity: "warning"}, alerts.SeverityInfo, alerts.StateFiring, false},
+		{"at minSeverity", Channel{MinSeverity: "warning"}, alerts.SeverityWarning, alerts.StateFiring, true},
+		{"above minSeverity", Channel{MinSeverity: "warning"}, alerts.SeverityCritical, alerts.StateFiring, true},
+		{"resolved", Channel{}, alerts.SeverityWarning, alerts.StateResolved, true},
+		{"resolved without sendResolved", Channel{SendResolved: boolPtr(false)}, alerts.SeverityWarning, alerts.StateResolved, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newChannel(tt.config, newFakeSender(), &MockMetricsCollector{}, time.Now)
+			if got := c.accepts(Notification{Severity: tt.severity, State: tt.state}); got != tt.want {
+				t.Errorf("accepts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelRetries(t *testing.T) {
+	fake := newFakeSender()
+	fake.failures, fake.err = 2, errUnavailable
+	metrics := &MockMetricsCollector{}
+	c := newChannel(Channel{Name: "phone", RetryDelay: "1ms"}, fake, metrics, func() time.Time { return time.Unix(5000, 0) })
+
+	if err := c.deliver(context.Background(), sampleNotification(), 2); err != nil {
+		t.Fatalf("deliver() error = %v, want success on the third attempt", err)
+	}
+	if fake.attempts != 3 || len(fake.sent) != 1 {
+		t.Errorf("attempts = %d, sent = %d, want 3 and 1", fake.attempts, len(fake.sent))
+	}
+	status := c.status()
+	if status.Sent != 1 || status.Failed != 0 || *status.LastSent != 5000 || status.LastError != nil {
+		t.Errorf("status() = %+v, want one sent at 5000", status)
+	}
+	if metrics.Sent["phone"] != 1 {
+		t.Errorf("Sent = %v, want 1 for phone", metrics.Sent)
+	}
+
+	fake.attempts, fake.failures = 0, 5
+	err := c.deliver(context.Background(), sampleNotification(), 2)
+	if !errors.Is(err, errUnavailable) {
+		t.Fatalf("deliver() error = %v, want %v", err, errUnavailable)
+	}
+	if fake.attempts != 3 {
+		t.Errorf("attempts = %d, want 3", fake.attempts)
+	}
+	status = c.status()
+	if status.Failed != 1 || status.LastError == nil || *status.LastError != errUnavailable.Error() {
+		t.Errorf("status() = %+v, want one failed with its error", status)
+	}
+	if metrics.Failed["phone"] != 1 {
+		t.Errorf("Failed = %v, want 1 for phone", metrics.Failed)
+	}
+}
+
+func TestChannelDoesNotRetryUnrecoverable(t *testing.T) {
+	fake := newFakeSender()
+	fake.failures, fake.err = 5, unrecoverable(errors.New("401 Unauthorized"))
+	c := newChannel(Channel{Name: "phone", RetryDelay: "1ms"}, fake, &MockMetricsCollector{}, time.Now)
+
+	if err := c.deliver(context.Background(), sampleNotification(), 3); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("deliver() error = %v, want the 401", err)
+	}
+	if fake.attempts != 1 {
+		t.Errorf("attempts = %d, want 1", fake.attempts)
+	}
+}
+
+func TestChannelRateLimit(t *testing.T) {
+	now := time.Unix(10000, 0)
+	metrics := &MockMetricsCollector{}
+	c := newChannel(Channel{Name: "phone", MaxPerHour: 2}, newFakeSender(), metrics, func() time.Time { return now })
+
+	for range 3 {
+		c.enqueue(sampleNotification())
+	}
+	if len(c.queue) != 2 || metrics.Dropped["phone/rate_limited"] != 1 {
+		t.Fatalf("queued %d, dropped %v, want 2 queued and 1 rate limited", len(c.queue), metrics.Dropped)
+	}
+
+	// One comes back every half hour
+	now = now.Add(30 * time.Minute)
+	c.enqueue(sampleNotification())
+	c.enqueue(sampleNotification())
+	if len(c.queue) != 3 || metrics.Dropped["phone/rate_limited"] != 2 {
+		t.Errorf("queued %d, dropped %v, want 3 queued and 2 rate limited", len(c.queue), metrics.Dropped)
+	}
+	if status := c.status(); status.Dropped != 2 {
+		t.Errorf("Dropped = %d, want 2", status.Dropped)
+	}
+}
+
+func TestChannelQueueFull(t *testing.T) {
+	metrics := &MockMetricsCollector{}
+	c := newChannel(Channel{Name: "phone", MaxPerHour: 1000}, newFakeSender(), metrics, time.Now)
+
+	for range queueSize + 1 {
+		c.enqueue(sampleNotification())
+	}
+	if len(c.queue) != queueSize || metrics.Dropped["phone/queue_full"] != 1 {
+		t.Errorf("queued %d, dropped %v, want %d queued and 1 for a full queue", len(c.queue), metrics.Dropped, queueSize)
+	}
+}
+
+func TestNewSenderUnknownType(t *testing.T) {
+	if _, err := newSender(&Channel{Name: "pager", Type: "pagerduty"}); err == nil {
+		t.Error("newSender() error = nil, want an error")
+	}
+}
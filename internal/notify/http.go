@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/lumberbarons/solar-controller/internal/alerts"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of a webhook body, as
+// sha256=<hex>, when the webhook has a secret.
+const SignatureHeader = "X-Signature-256"
+
+// do sends a request and reads a 2xx response as success. A 4xx other than
+// 429 is not retried: the request itself is wrong.
+func do(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Redacted(), resp.Status, strings.TrimSpace(string(body)))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return unrecoverable(err)
+	}
+	return err
+}
+
+// ntfySender publishes to an ntfy topic, the message as the body and the rest
+// as headers.
+type ntfySender struct {
+	config *NtfyConfiguration
+	client *http.Client
+}
+
+// ntfyPriority maps a severity to ntfy's 1 (min) to 5 (max); a resolved
+// alert is the default 3.
+var ntfyPriority = map[string]string{
+	alerts.SeverityInfo:     "3",
+	alerts.SeverityWarning:  "4",
+	alerts.SeverityCritical: "5",
+}
+
+func (s *ntfySender) send(ctx context.Context, n Notification) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.URL, strings.NewReader(n.Message))
+	if err != nil {
+		return unrecoverable(err)
+	}
+	req.Header.Set("Title", n.Title)
+	req.Header.Set("Tags", n.Severity)
+	priority := ntfyPriority[n.Severity]
+	if n.State == alerts.StateResolved || priority == "" {
+		priority = "3"
+	}
+	req.Header.Set("Priority", priority)
+	if s.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.config.Token)
+	}
+	return do(s.client, req)
+}
+
+// gotifySender posts a message to a Gotify application.
+type gotifySender struct {
+	config *GotifyConfiguration
+	client *http.Client
+}
+
+// gotifyPriority maps a severity to Gotify's 0 to 10, where the Android app
+// notifies from 4 and pops up from 8; a resolved alert is 2.
+var gotifyPriority = map[string]int{
+	alerts.SeverityInfo:     4,
+	alerts.SeverityWarning:  6,
+	alerts.SeverityCritical: 8,
+}
+
+func (s *gotifySender) send(ctx context.Context, n Notification) error {
+	priority, ok := gotifyPriority[n.Severity]
+	if n.State == alerts.StateResolved || !ok {
+		priority = 2
+	}
+	body, err := json.Marshal(map[string]any{"title": n.Title, "message": n.Message, "priority": priority})
+	if err != nil {
+		return unrecoverable(err)
+	}
+
+	url := strings.TrimSuffix(s.config.URL, "/") + "/message"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return unrecoverable(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", s.config.Token)
+	return do(s.client, req)
+}
+
+// webhookSender sends the notification rendered by the body template, or as
+// JSON.
+type webhookSender struct {
+	config *WebhookConfiguration
+	client *http.Client
+	body   *template.Template
+}
+
+func newWebhookSender(config *WebhookConfiguration, client *http.Client) (*webhookSender, error) {
+	s := &webhookSender{config: config, client: client}
+	if config.Body != "" {
+		body, err := parseBody(config.Body)
+		if err != nil {
+			return nil, fmt.Errorf("webhook.body: %w", err)
+		}
+		s.body = body
+	}
+	return s, nil
+}
+
+func (s *webhookSender) send(ctx context.Context, n Notification) error {
+	body, err := s.render(n)
+	if err != nil {
+		return unrecoverable(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, s.config.GetMethod(), s.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return unrecoverable(err)
+	}
+	req.Header.Set("Content-Type", s.config.GetContentType())
+	for name, value := range s.config.Headers {
+		req.Header.Set(name, value)
+	}
+	if s.config.Secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+sign(s.config.Secret, body))
+	}
+	return do(s.client, req)
+}
+
+func (s *webhookSender) render(n Notification) ([]byte, error) {
+	if s.body == nil {
+		body, err := toJSON(n)
+		return []byte(body), err
+	}
+	var b bytes.Buffer
+	if err := s.body.Execute(&b, n); err != nil {
+		return nil, fmt.Errorf("failed to render webhook body: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// sign returns the hex HMAC-SHA256 of body, which the receiver recomputes
+// with the shared secret to check the request came from this controller.
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
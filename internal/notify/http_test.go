@@ -0,0 +1,249 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/alerts"
+)
+
+// request is what a stand-in HTTP server received.
+type request struct {
+	method string
+	path   string
+	header http.Header
+	body   string
+}
+
+// newHTTPServer starts a stand-in server answering each request with the
+// next of statuses, then 200.
+func newHTTPServer(t *testing.T, statuses ...int) (*httptest.Server, func() []request) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, request{r.Method, r.URL.RequestURI(), r.Header.Clone(), string(body)})
+		status := http.StatusOK
+		if len(statuses) > 0 {
+			status, statuses = statuses[0], statuses[1:]
+		}
+		mu.Unlock()
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("stand-in says " + http.StatusText(status)))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]request(nil), requests...)
+	}
+}
+
+func TestNtfySender(t *testing.T) {
+	server, requests := newHTTPServer(t)
+	sender := &ntfySender{config: &NtfyConfiguration{URL: server.URL + "/cabin-alerts", Token: "tk_secret"}, client: server.Client()}
+
+	n := sampleNotification()
+	if err := sender.send(context.Background(), n); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+	n.State = alerts.StateResolved
+	if err := sender.send(context.Background(), n); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+
+	got := requests()
+	if len(got) != 2 {
+		t.Fatalf("requests = %d, want 2", len(got))
+	}
+	fired := got[0]
+	if fired.method != http.MethodPost || fired.path != "/cabin-alerts" || fired.body != n.Message {
+		t.Errorf("request = %s %s %q, want the message posted to the topic", fired.method, fired.path, fired.body)
+	}
+	if fired.header.Get("Title") != n.Title || fired.header.Get("Priority") != "5" || fired.header.Get("Tags") != "critical" {
+		t.Errorf("headers = %v, want the title, priority 5 and the severity tag", fired.header)
+	}
+	if fired.header.Get("Authorization") != "Bearer tk_secret" {
+		t.Errorf("Authorization = %q, want the token", fired.header.Get("Authorization"))
+	}
+	if got[1].header.Get("Priority") != "3" {
+		t.Errorf("resolved Priority = %q, want 3", got[1].header.Get("Priority"))
+	}
+}
+
+func TestGotifySender(t *testing.T) {
+	server, requests := newHTTPServer(t)
+	sender := &gotifySender{config: &GotifyConfiguration{URL: server.URL + "/", Token: "app-token"}, client: server.Client()}
+
+	if err := sender.send(context.Background(), sampleNotification()); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+
+	got := requests()
+	if len(got) != 1 || got[0].path != "/message" || got[0].header.Get("X-Gotify-Key") != "app-token" {
+		t.Fatalf("requests = %+v, want one to /message with the token", got)
+	}
+	var message struct {
+		Title    string `json:"title"`
+		Message  string `json:"message"`
+		Priority int    `json:"priority"`
+	}
+	if err := json.Unmarshal([]byte(got[0].body), &message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Title != "cabin-1: low-soc firing" || message.Priority != 8 || !strings.HasPrefix(message.Message, "low-soc fired") {
+		t.Errorf("message = %+v, want the alert at priority 8", message)
+	}
+}
+
+func TestWebhookSender(t *testing.T) {
+	server, requests := newHTTPServer(t)
+
+	tests := []struct {
+		name     string
+		config   WebhookConfiguration
+		wantBody string
+	}{
+		{
+			name:     "JSON by default",
+			config:   WebhookConfiguration{URL: server.URL + "/hook"},
+			wantBody: `{"deviceId":"cabin-1","rule":"low-soc","severity":"critical","state":"firing","condition":"voltgo/battery-soc < 20","value":15,"timestamp":1717243200,"title":"cabin-1: low-soc firing","message":"low-soc fired: voltgo/battery-soc is 15 (voltgo/battery-soc < 20)","test":false}`,
+		},
+		{
+			name: "templated",
+			config: WebhookConfiguration{
+				URL:         server.URL + "/hook",
+				Method:      http.MethodPut,
+				Body:        `{"text":{{json .Title}},"at":{{.Timestamp}}}`,
+				ContentType: "application/vnd.chat+json",
+				Headers:     map[string]string{"X-Room": "cabin"},
+				Secret:      "shared",
+			},
+			wantBody: `{"text":"cabin-1: low-soc firing","at":1717243200}`,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender, err := newWebhookSender(&tt.config, server.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := sender.send(context.Background(), sampleNotification()); err != nil {
+				t.Fatalf("send() error = %v", err)
+			}
+
+			got := requests()[i]
+			if got.method != tt.config.GetMethod() || got.body != tt.wantBody {
+				t.Errorf("request = %s %s, want %s %s", got.method, got.body, tt.config.GetMethod(), tt.wantBody)
+			}
+			if got.header.Get("Content-Type") != tt.config.GetContentType() {
+				t.Errorf("Content-Type = %q, want %q", got.header.Get("Content-Type"), tt.config.GetContentType())
+			}
+			for name, value := range tt.config.Headers {
+				if got.header.Get(name) != value {
+					t.Errorf("%s = %q, want %q", name, got.header.Get(name), value)
+				}
+			}
+
+			signature := got.header.Get(SignatureHeader)
+			if tt.config.Secret == "" {
+				if signature != "" {
+					t.Errorf("%s = %q, want none without a secret", SignatureHeader, signature)
+				}
+				return
+			}
+			// The receiver's check: HMAC-SHA256 of the body with the secret
+			if want := "sha256=" + sign(tt.config.Secret, []byte(got.body)); signature != want {
+				t.Errorf("%s = %q, want %q", SignatureHeader, signature, want)
+			}
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	// From RFC 4231, test case 2
+	got := sign("Jefe", []byte("what do ya want for nothing?"))
+	if want := "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"; got != want {
+		t.Errorf("sign() = %s, want %s", got, want)
+	}
+}
+
+func TestWebhookSenderTemplateError(t *testing.T) {
+	if _, err := newWebhookSender(&WebhookConfiguration{URL: "http://127.0.0.1/hook", Body: "{{.Message"}, http.DefaultClient); err == nil {
+		t.Error("newWebhookSender() error = nil, want a template error")
+	}
+
+	sender, err := newWebhookSender(&WebhookConfiguration{URL: "http://127.0.0.1/hook", Body: "{{.Missing}}"}, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := newChannel(Channel{Name: "hook", RetryDelay: "1ms"}, sender, &MockMetricsCollector{}, time.Now)
+	if err := fake.deliver(context.Background(), sampleNotification(), 3); err == nil || !strings.Contains(err.Error(), "render") {
+		t.Errorf("deliver() error = %v, want a render error", err)
+	}
+}
+
+func TestHTTPRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantErr      bool
+		wantRequests int
+	}{
+		{"server error then success", []int{http.StatusServiceUnavailable, http.StatusBadGateway}, false, 3},
+		{"too many requests then success", []int{http.StatusTooManyRequests}, false, 2},
+		{"client error is not retried", []int{http.StatusUnauthorized}, true, 1},
+		{"server errors until the retries run out", []int{500, 500, 500, 500}, true, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newHTTPServer(t, tt.statuses...)
+			sender := &ntfySender{config: &NtfyConfiguration{URL: server.URL + "/cabin"}, client: server.Client()}
+			c := newChannel(Channel{Name: "phone", RetryDelay: "1ms"}, sender, &MockMetricsCollector{}, time.Now)
+
+			err := c.deliver(context.Background(), sampleNotification(), 2)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("deliver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "stand-in says") {
+				t.Errorf("error = %v, want the response body in it", err)
+			}
+			if got := len(requests()); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestHTTPTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	sender := &ntfySender{config: &NtfyConfiguration{URL: server.URL}, client: server.Client()}
+	c := newChannel(Channel{Name: "phone", Timeout: "50ms"}, sender, &MockMetricsCollector{}, time.Now)
+
+	start := time.Now()
+	if err := c.deliver(context.Background(), sampleNotification(), 0); err == nil {
+		t.Fatal("deliver() error = nil, want a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("deliver() took %s, want the 50ms timeout", elapsed)
+	}
+}
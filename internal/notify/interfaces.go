@@ -0,0 +1,16 @@
+package notify
+
+// MetricsCollector defines the interface for collecting and exposing metrics.
+// This abstraction allows for testing without the Prometheus global registry.
+type MetricsCollector interface {
+	// IncrementSent counts a notification a channel delivered.
+	IncrementSent(channel string)
+
+	// IncrementFailed counts a notification a channel gave up on after its
+	// retries.
+	IncrementFailed(channel string)
+
+	// IncrementDropped counts a notification a channel did not try to send,
+	// for being over its rate limit or behind.
+	IncrementDropped(channel, reason string)
+}
@@ -0,0 +1,154 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/alerts"
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+)
+
+// MockMetricsCollector is a mock implementation of MetricsCollector for testing.
+type MockMetricsCollector struct {
+	mu      sync.Mutex
+	Sent    map[string]int
+	Failed  map[string]int
+	Dropped map[string]int // by channel/reason
+}
+
+func (m *MockMetricsCollector) IncrementSent(channel string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Sent == nil {
+		m.Sent = make(map[string]int)
+	}
+	m.Sent[channel]++
+}
+
+func (m *MockMetricsCollector) IncrementFailed(channel string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Failed == nil {
+		m.Failed = make(map[string]int)
+	}
+	m.Failed[channel]++
+}
+
+func (m *MockMetricsCollector) IncrementDropped(channel, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Dropped == nil {
+		m.Dropped = make(map[string]int)
+	}
+	m.Dropped[channel+"/"+reason]++
+}
+
+// fakeSender records what it is asked to send, failing the first failures
+// attempts with err.
+type fakeSender struct {
+	mu       sync.Mutex
+	sent     []Notification
+	attempts int
+	failures int
+	err      error
+
+	// delivered receives each notification sent
+	delivered chan Notification
+}
+
+func newFakeSender() *fakeSender {
+	return &fakeSender{delivered: make(chan Notification, 64)}
+}
+
+func (f *fakeSender) send(ctx context.Context, n Notification) error {
+	f.mu.Lock()
+	f.attempts++
+	if f.attempts <= f.failures {
+		f.mu.Unlock()
+		return f.err
+	}
+	f.sent = append(f.sent, n)
+	f.mu.Unlock()
+
+	f.delivered <- n
+	return nil
+}
+
+// wait returns the next notification sent, or fails the test after a second.
+func (f *fakeSender) wait(t *testing.T) Notification {
+	t.Helper()
+
+	select {
+	case n := <-f.delivered:
+		return n
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a notification")
+		return Notification{}
+	}
+}
+
+var errUnavailable = errors.New("server unavailable")
+
+// newTestPublisher starts a publisher of the channels over fake senders.
+func newTestPublisher(t *testing.T, channels ...Channel) (*Publisher, *testutil.MockMessagePublisher, []*fakeSender, *MockMetricsCollector) {
+	t.Helper()
+
+	next := &testutil.MockMessagePublisher{}
+	metrics := &MockMetricsCollector{}
+	fakes := make([]*fakeSender, 0, len(channels))
+	senders := make([]sender, 0, len(channels))
+	for range channels {
+		fake := newFakeSender()
+		fakes = append(fakes, fake)
+		senders = append(senders, fake)
+	}
+	publisher := newPublisher(next, channels, senders, metrics, "test-device-1")
+	t.Cleanup(publisher.Close)
+	return publisher, next, fakes, metrics
+}
+
+// publishAlert publishes an alert event as the alerts publisher does.
+func publishAlert(t *testing.T, p *Publisher, rule, severity, state string, timestamp int64) {
+	t.Helper()
+
+	event := &alerts.Event{
+		Rule:      rule,
+		Severity:  severity,
+		State:     state,
+		Condition: "voltgo/battery-soc < 20",
+		Value:     15,
+		Timestamp: timestamp,
+		Message:   rule + " " + state + ": voltgo/battery-soc is 15 (voltgo/battery-soc < 20)",
+	}
+	payload, err := event.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Publish("test-device-1/alerts/"+rule, payload)
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// sampleNotification is a firing alert as a channel receives it.
+func sampleNotification() Notification {
+	return Notification{
+		DeviceID:  "cabin-1",
+		Rule:      "low-soc",
+		Severity:  alerts.SeverityCritical,
+		State:     alerts.StateFiring,
+		Condition: "voltgo/battery-soc < 20",
+		Value:     15,
+		Timestamp: 1717243200,
+		Title:     "cabin-1: low-soc firing",
+		Message:   "low-soc fired: voltgo/battery-soc is 15 (voltgo/battery-soc < 20)",
+	}
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/alerts"
+)
+
+// Notification is what a channel sends: an alert firing or resolving. Its
+// fields are also what a webhook body template reads.
+type Notification struct {
+	DeviceID  string  `json:"deviceId"`
+	Rule      string  `json:"rule"`
+	Severity  string  `json:"severity"`
+	State     string  `json:"state"`
+	Condition string  `json:"condition"`
+	Value     float64 `json:"value"`
+	Timestamp int64   `json:"timestamp"`
+
+	// Title is a one-line summary, such as "cabin-1: low-soc firing"
+	Title   string `json:"title"`
+	Message string `json:"message"`
+
+	// Test is true for a notification sent from the test endpoint
+	Test bool `json:"test"`
+}
+
+// fromEvent reads an alert event published to {deviceId}/alerts/{rule}.
+func fromEvent(deviceID, rule, payload string) (Notification, error) {
+	var event alerts.EventPayload
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return Notification{}, fmt.Errorf("failed to unmarshal alert %s: %w", rule, err)
+	}
+	return Notification{
+		DeviceID:  deviceID,
+		Rule:      rule,
+		Severity:  event.Severity,
+		State:     event.State,
+		Condition: event.Condition,
+		Value:     event.Reading,
+		Timestamp: event.Timestamp,
+		Title:     fmt.Sprintf("%s: %s %s", deviceID, rule, event.State),
+		Message:   event.Message,
+	}, nil
+}
+
+// testNotification is what the test endpoint sends.
+func testNotification(deviceID, channel string, now time.Time) Notification {
+	return Notification{
+		DeviceID:  deviceID,
+		Rule:      "test",
+		Severity:  alerts.SeverityInfo,
+		State:     alerts.StateFiring,
+		Timestamp: now.Unix(),
+		Title:     fmt.Sprintf("%s: test notification", deviceID),
+		Message:   fmt.Sprintf("This is a test of the %s notification channel.", channel),
+		Test:      true,
+	}
+}
+
+// Time returns the time of the notification.
+func (n Notification) Time() time.Time {
+	return time.Unix(n.Timestamp, 0)
+}
+
+// toJSON marshals v without escaping <, > and &, which webhook receivers
+// would otherwise show as \u003c and the like.
+func toJSON(v any) (string, error) {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
@@ -0,0 +1,349 @@
+// Package notify delivers alert events to people rather than to systems: as
+// email over SMTP, as a push through ntfy or Gotify, or to any webhook. Each
+// channel sends in the background, with retries and a rate limit, so a slow
+// or unreachable server never holds up what the controllers publish.
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"text/template"
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/alerts"
+)
+
+const (
+	namespace = "notifications"
+
+	// The kinds of channel
+	TypeSMTP    = "smtp"
+	TypeNtfy    = "ntfy"
+	TypeGotify  = "gotify"
+	TypeWebhook = "webhook"
+
+	defaultRetries    = 3
+	defaultRetryDelay = 10 * time.Second
+	defaultMaxPerHour = 20
+	defaultTimeout    = 10 * time.Second
+	defaultSMTPPort   = 587
+)
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// severityRank orders the alert severities for MinSeverity.
+var severityRank = map[string]int{
+	alerts.SeverityInfo:     0,
+	alerts.SeverityWarning:  1,
+	alerts.SeverityCritical: 2,
+}
+
+type Configuration struct {
+	Channels []Channel `yaml:"channels"`
+}
+
+// Channel is one place notifications are sent. Type picks the section that
+// configures it.
+type Channel struct {
+	// Name identifies the channel, such as phone
+	Name string `yaml:"name"`
+
+	// Type is smtp, ntfy, gotify or webhook
+	Type string `yaml:"type"`
+
+	// MinSeverity is the least severe alert sent: info, warning or critical
+	// (default: info)
+	MinSeverity string `yaml:"minSeverity"`
+
+	// SendResolved also sends alerts resolving (default: true)
+	SendResolved *bool `yaml:"sendResolved"`
+
+	// Retries is how many times a failed send is tried again (default: 3),
+	// RetryDelay the wait before the first retry, doubling after each
+	// (default: 10s)
+	Retries    *int   `yaml:"retries"`
+	RetryDelay string `yaml:"retryDelay"`
+
+	// MaxPerHour is how many notifications the channel sends an hour at
+	// most; the rest are dropped (default: 20)
+	MaxPerHour int `yaml:"maxPerHour"`
+
+	// Timeout bounds each attempt (default: 10s)
+	Timeout string `yaml:"timeout"`
+
+	SMTP    *SMTPConfiguration    `yaml:"smtp"`
+	Ntfy    *NtfyConfiguration    `yaml:"ntfy"`
+	Gotify  *GotifyConfiguration  `yaml:"gotify"`
+	Webhook *WebhookConfiguration `yaml:"webhook"`
+}
+
+// SMTPConfiguration sends email.
+type SMTPConfiguration struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"` // default: 587
+
+	// Username and Password log in with PLAIN auth, which net/smtp only
+	// allows over TLS or to localhost (optional)
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+
+	From string   `yaml:"from"`
+	To   []string `yaml:"to"`
+
+	// StartTLS upgrades the connection before logging in, and fails if the
+	// server does not offer it (default: true)
+	StartTLS *bool `yaml:"startTLS"`
+}
+
+// NtfyConfiguration pushes to an ntfy topic.
+type NtfyConfiguration struct {
+	// URL is the topic's, such as https://ntfy.sh/cabin-alerts
+	URL string `yaml:"url"`
+
+	// Token is an access token (optional)
+	Token string `yaml:"token"`
+}
+
+// GotifyConfiguration pushes through a Gotify server.
+type GotifyConfiguration struct {
+	// URL is the server's, such as https://gotify.example.com
+	URL string `yaml:"url"`
+
+	// Token is the application token
+	Token string `yaml:"token"`
+}
+
+// WebhookConfiguration sends an HTTP request.
+type WebhookConfiguration struct {
+	URL    string `yaml:"url"`
+	Method string `yaml:"method"` // default: POST
+
+	// Headers are added to each request (optional)
+	Headers map[string]string `yaml:"headers"`
+
+	// Body is a Go text/template of the Notification (default: the
+	// Notification as JSON)
+	Body string `yaml:"body"`
+
+	// ContentType is the body's (default: application/json)
+	ContentType string `yaml:"contentType"`
+
+	// Secret signs each body with HMAC-SHA256 in the X-Signature-256 header
+	// (optional)
+	Secret string `yaml:"secret"`
+}
+
+// GetMinSeverity returns the configured severity or the default (info).
+func (c *Channel) GetMinSeverity() string {
+	if c.MinSeverity == "" {
+		return alerts.SeverityInfo
+	}
+	return c.MinSeverity
+}
+
+// GetSendResolved returns whether resolved alerts are sent (default: true).
+func (c *Channel) GetSendResolved() bool {
+	return c.SendResolved == nil || *c.SendResolved
+}
+
+// GetRetries returns the configured retries or the default (3).
+func (c *Channel) GetRetries() int {
+	if c.Retries == nil {
+		return defaultRetries
+	}
+	return *c.Retries
+}
+
+// GetRetryDelay returns the configured delay or the default (10s).
+func (c *Channel) GetRetryDelay() time.Duration {
+	d, err := parseDuration("retryDelay", c.RetryDelay)
+	if err != nil || d == 0 {
+		return defaultRetryDelay
+	}
+	return d
+}
+
+// GetMaxPerHour returns the configured limit or the default (20).
+func (c *Channel) GetMaxPerHour() int {
+	if c.MaxPerHour == 0 {
+		return defaultMaxPerHour
+	}
+	return c.MaxPerHour
+}
+
+// GetTimeout returns the configured timeout or the default (10s).
+func (c *Channel) GetTimeout() time.Duration {
+	d, err := parseDuration("timeout", c.Timeout)
+	if err != nil || d == 0 {
+		return defaultTimeout
+	}
+	return d
+}
+
+// GetPort returns the configured port or the default (587).
+func (c *SMTPConfiguration) GetPort() int {
+	if c.Port == 0 {
+		return defaultSMTPPort
+	}
+	return c.Port
+}
+
+// GetStartTLS returns whether to upgrade with STARTTLS (default: true).
+func (c *SMTPConfiguration) GetStartTLS() bool {
+	return c.StartTLS == nil || *c.StartTLS
+}
+
+// GetMethod returns the configured method or the default (POST).
+func (c *WebhookConfiguration) GetMethod() string {
+	if c.Method == "" {
+		return http.MethodPost
+	}
+	return c.Method
+}
+
+// GetContentType returns the configured content type or the default
+// (application/json).
+func (c *WebhookConfiguration) GetContentType() string {
+	if c.ContentType == "" {
+		return "application/json"
+	}
+	return c.ContentType
+}
+
+// Validate checks every channel and that their names are unique.
+func (c *Configuration) Validate() error {
+	names := make(map[string]bool, len(c.Channels))
+	for i, channel := range c.Channels {
+		if !namePattern.MatchString(channel.Name) {
+			return fmt.Errorf("channel %d: name %q must be lowercase letters, digits and dashes", i+1, channel.Name)
+		}
+		if names[channel.Name] {
+			return fmt.Errorf("channel %s is defined twice", channel.Name)
+		}
+		names[channel.Name] = true
+
+		if err := channel.validate(); err != nil {
+			return fmt.Errorf("channel %s: %w", channel.Name, err)
+		}
+	}
+	return nil
+}
+
+func (c *Channel) validate() error {
+	if _, ok := severityRank[c.GetMinSeverity()]; !ok {
+		return fmt.Errorf("minSeverity %q must be info, warning or critical", c.MinSeverity)
+	}
+	if c.GetRetries() < 0 {
+		return errors.New("retries must not be negative")
+	}
+	if c.MaxPerHour < 0 {
+		return errors.New("maxPerHour must not be negative")
+	}
+	if _, err := parseDuration("retryDelay", c.RetryDelay); err != nil {
+		return err
+	}
+	if _, err := parseDuration("timeout", c.Timeout); err != nil {
+		return err
+	}
+
+	switch c.Type {
+	case TypeSMTP:
+		if c.SMTP == nil {
+			return errors.New("an smtp channel needs an smtp section")
+		}
+		return c.SMTP.validate()
+	case TypeNtfy:
+		if c.Ntfy == nil {
+			return errors.New("an ntfy channel needs an ntfy section")
+		}
+		return validateURL(c.Ntfy.URL)
+	case TypeGotify:
+		if c.Gotify == nil {
+			return errors.New("a gotify channel needs a gotify section")
+		}
+		if c.Gotify.Token == "" {
+			return errors.New("gotify.token is required")
+		}
+		return validateURL(c.Gotify.URL)
+	case TypeWebhook:
+		if c.Webhook == nil {
+			return errors.New("a webhook channel needs a webhook section")
+		}
+		return c.Webhook.validate()
+	default:
+		return fmt.Errorf("type %q must be smtp, ntfy, gotify or webhook", c.Type)
+	}
+}
+
+func (c *SMTPConfiguration) validate() error {
+	if c.Host == "" {
+		return errors.New("smtp.host is required")
+	}
+	if port := c.GetPort(); port < 1 || port > 65535 {
+		return fmt.Errorf("smtp.port %d is out of range", port)
+	}
+	if c.From == "" {
+		return errors.New("smtp.from is required")
+	}
+	if len(c.To) == 0 {
+		return errors.New("smtp.to needs at least one address")
+	}
+	if c.Username != "" && c.Password == "" {
+		return errors.New("smtp.password is required with smtp.username")
+	}
+	return nil
+}
+
+func (c *WebhookConfiguration) validate() error {
+	if err := validateURL(c.URL); err != nil {
+		return err
+	}
+	switch c.GetMethod() {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return fmt.Errorf("webhook.method %q must be POST, PUT or PATCH", c.Method)
+	}
+	if c.Body != "" {
+		if _, err := parseBody(c.Body); err != nil {
+			return fmt.Errorf("webhook.body: %w", err)
+		}
+	}
+	return nil
+}
+
+func validateURL(value string) error {
+	if value == "" {
+		return errors.New("url is required")
+	}
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("url is invalid: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("url %s must use http or https", value)
+	}
+	return nil
+}
+
+// parseDuration parses an optional non-negative duration.
+func parseDuration(name, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%s must not be negative", name)
+	}
+	return d, nil
+}
+
+// parseBody parses a webhook body template.
+func parseBody(body string) (*template.Template, error) {
+	return template.New("body").Funcs(template.FuncMap{"json": toJSON}).Parse(body)
+}
@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	smtp := &SMTPConfiguration{Host: "mail.example.com", From: "cabin@example.com", To: []string{"me@example.com"}}
+
+	tests := []struct {
+		name    string
+		config  Configuration
+		wantErr bool
+	}{
+		{"no channels", Configuration{}, false},
+		{"every type", Configuration{Channels: []Channel{
+			{Name: "email", Type: TypeSMTP, SMTP: smtp, MinSeverity: "critical", Retries: intPtr(0), RetryDelay: "30s", MaxPerHour: 5, Timeout: "5s"},
+			{Name: "phone", Type: TypeNtfy, Ntfy: &NtfyConfiguration{URL: "https://ntfy.sh/cabin-alerts"}},
+			{Name: "gotify", Type: TypeGotify, Gotify: &GotifyConfiguration{URL: "https://gotify.example.com", Token: "app-token"}},
+			{Name: "hook", Type: TypeWebhook, Webhook: &WebhookConfiguration{URL: "http://10.0.0.2/hook", Method: http.MethodPut, Body: `{"text":{{json .Message}}}`}},
+		}}, false},
+		{"name with capitals", Configuration{Channels: []Channel{{Name: "Phone", Type: TypeNtfy, Ntfy: &NtfyConfiguration{URL: "https://ntfy.sh/a"}}}}, true},
+		{"name used twice", Configuration{Channels: []Channel{
+			{Name: "phone", Type: TypeNtfy, Ntfy: &NtfyConfiguration{URL: "https://ntfy.sh/a"}},
+			{Name: "phone", Type: TypeNtfy, Ntfy: &NtfyConfiguration{URL: "https://ntfy.sh/b"}},
+		}}, true},
+		{"unknown type", Configuration{Channels: []Channel{{Name: "pager", Type: "pagerduty"}}}, true},
+		{"type without its section", Configuration{Channels: []Channel{{Name: "phone", Type: TypeNtfy}}}, true},
+		{"bad severity", Configuration{Channels: []Channel{{Name: "phone", Type: TypeNtfy, MinSeverity: "page", Ntfy: &NtfyConfiguration{URL: "https://ntfy.sh/a"}}}}, true},
+		{"negative retries", Configuration{Channels: []Channel{{Name: "phone", Type: TypeNtfy, Retries: intPtr(-1), Ntfy: &NtfyConfiguration{URL: "https://ntfy.sh/a"}}}}, true},
+		{"negative maxPerHour", Configuration{Channels: []Channel{{Name: "phone", Type: TypeNtfy, MaxPerHour: -1, Ntfy: &NtfyConfiguration{URL: "https://ntfy.sh/a"}}}}, true},
+		{"bad retryDelay", Configuration{Channels: []Channel{{Name: "phone", Type: TypeNtfy, RetryDelay: "soon", Ntfy: &NtfyConfiguration{URL: "https://ntfy.sh/a"}}}}, true},
+		{"negative timeout", Configuration{Channels: []Channel{{Name: "phone", Type: TypeNtfy, Timeout: "-1s", Ntfy: &NtfyConfiguration{URL: "https://ntfy.sh/a"}}}}, true},
+		{"ntfy without a URL", Configuration{Channels: []Channel{{Name: "phone", Type: TypeNtfy, Ntfy: &NtfyConfiguration{}}}}, true},
+		{"ntfy URL not http", Configuration{Channels: []Channel{{Name: "phone", Type: TypeNtfy, Ntfy: &NtfyConfiguration{URL: "ftp://ntfy.sh/a"}}}}, true},
+		{"gotify without a token", Configuration{Channels: []Channel{{Name: "gotify", Type: TypeGotify, Gotify: &GotifyConfiguration{URL: "https://gotify.example.com"}}}}, true},
+		{"smtp without a host", Configuration{Channels: []Channel{{Name: "email", Type: TypeSMTP, SMTP: &SMTPConfiguration{From: "a@example.com", To: []string{"b@example.com"}}}}}, true},
+		{"smtp without recipients", Configuration{Channels: []Channel{{Name: "email", Type: TypeSMTP, SMTP: &SMTPConfiguration{Host: "mail.example.com", From: "a@example.com"}}}}, true},
+		{"smtp without a sender", Configuration{Channels: []Channel{{Name: "email", Type: TypeSMTP, SMTP: &SMTPConfiguration{Host: "mail.example.com", To: []string{"b@example.com"}}}}}, true},
+		{"smtp port out of range", Configuration{Channels: []Channel{{Name: "email", Type: TypeSMTP, SMTP: &SMTPConfiguration{Host: "mail.example.com", Port: 70000, From: "a@example.com", To: []string{"b@example.com"}}}}}, true},
+		{"smtp username without a password", Configuration{Channels: []Channel{{Name: "email", Type: TypeSMTP, SMTP: &SMTPConfiguration{Host: "mail.example.com", Username: "cabin", From: "a@example.com", To: []string{"b@example.com"}}}}}, true},
+		{"webhook GET", Configuration{Channels: []Channel{{Name: "hook", Type: TypeWebhook, Webhook: &WebhookConfiguration{URL: "http://10.0.0.2/hook", Method: http.MethodGet}}}}, true},
+		{"webhook body that does not parse", Configuration{Channels: []Channel{{Name: "hook", Type: TypeWebhook, Webhook: &WebhookConfiguration{URL: "http://10.0.0.2/hook", Body: "{{.Message"}}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChannelDefaults(t *testing.T) {
+	var channel Channel
+	if got := channel.GetMinSeverity(); got != "info" {
+		t.Errorf("GetMinSeverity() = %q, want info", got)
+	}
+	if !channel.GetSendResolved() {
+		t.Error("GetSendResolved() = false, want true")
+	}
+	if got := channel.GetRetries(); got != 3 {
+		t.Errorf("GetRetries() = %d, want 3", got)
+	}
+	if got := channel.GetRetryDelay(); got != 10*time.Second {
+		t.Errorf("GetRetryDelay() = %s, want 10s", got)
+	}
+	if got := channel.GetMaxPerHour(); got != 20 {
+		t.Errorf("GetMaxPerHour() = %d, want 20", got)
+	}
+	if got := channel.GetTimeout(); got != 10*time.Second {
+		t.Errorf("GetTimeout() = %s, want 10s", got)
+	}
+
+	channel = Channel{MinSeverity: "warning", SendResolved: boolPtr(false), Retries: intPtr(0), RetryDelay: "1m", MaxPerHour: 2, Timeout: "3s"}
+	if channel.GetMinSeverity() != "warning" || channel.GetSendResolved() || channel.GetRetries() != 0 ||
+		channel.GetRetryDelay() != time.Minute || channel.GetMaxPerHour() != 2 || channel.GetTimeout() != 3*time.Second {
+		t.Errorf("getters of %+v do not return the configured values", channel)
+	}
+
+	var smtp SMTPConfiguration
+	if smtp.GetPort() != 587 || !smtp.GetStartTLS() {
+		t.Errorf("GetPort() = %d, GetStartTLS() = %v, want 587 and true", smtp.GetPort(), smtp.GetStartTLS())
+	}
+	var webhook WebhookConfiguration
+	if webhook.GetMethod() != http.MethodPost || webhook.GetContentType() != "application/json" {
+		t.Errorf("GetMethod() = %q, GetContentType() = %q, want POST and application/json", webhook.GetMethod(), webhook.GetContentType())
+	}
+}
@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type PrometheusCollector struct {
+	sent    *prometheus.CounterVec
+	failed  *prometheus.CounterVec
+	dropped *prometheus.CounterVec
+}
+
+func NewPrometheusCollector() *PrometheusCollector {
+	return &PrometheusCollector{
+		sent: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "sent_total",
+				Help:      "Notifications delivered, by channel.",
+			},
+			[]string{"channel"},
+		),
+		failed: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "failed_total",
+				Help:      "Notifications that failed after every retry, by channel.",
+			},
+			[]string{"channel"},
+		),
+		dropped: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "dropped_total",
+				Help:      "Notifications not sent for the rate limit or a full queue, by channel and reason.",
+			},
+			[]string{"channel", "reason"},
+		),
+	}
+}
+
+func (n *PrometheusCollector) IncrementSent(channel string) {
+	n.sent.WithLabelValues(channel).Inc()
+}
+
+func (n *PrometheusCollector) IncrementFailed(channel string) {
+	n.failed.WithLabelValues(channel).Inc()
+}
+
+func (n *PrometheusCollector) IncrementDropped(channel, reason string) {
+	n.dropped.WithLabelValues(channel, reason).Inc()
+}
@@ -0,0 +1,31 @@
+package notify
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Verify PrometheusCollector implements MetricsCollector
+var _ MetricsCollector = (*PrometheusCollector)(nil)
+
+// The collector registers with the global Prometheus registry via promauto,
+// so it can only be created once per test binary.
+func TestPrometheusCollector(t *testing.T) {
+	collector := NewPrometheusCollector()
+
+	collector.IncrementSent("phone")
+	collector.IncrementSent("phone")
+	collector.IncrementFailed("phone")
+	collector.IncrementDropped("phone", reasonRateLimited)
+
+	if got := testutil.ToFloat64(collector.sent.WithLabelValues("phone")); got != 2 {
+		t.Errorf("notifications_sent_total = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(collector.failed.WithLabelValues("phone")); got != 1 {
+		t.Errorf("notifications_failed_total = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(collector.dropped.WithLabelValues("phone", reasonRateLimited)); got != 1 {
+		t.Errorf("notifications_dropped_total = %v, want 1", got)
+	}
+}
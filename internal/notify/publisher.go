@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/publish"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrUnknownChannel is returned for a channel that is not configured
+var ErrUnknownChannel = errors.New("no notification channel")
+
+// Publisher passes every message on to the publisher it wraps, and sends the
+// alert events among them to each channel that accepts them.
+type Publisher struct {
+	next     publish.MessagePublisher
+	deviceID string
+	now      func() time.Time
+	channels []*channel
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewPublisher creates a publisher that sends alert events through the
+// channels, and starts them.
+func NewPublisher(next publish.MessagePublisher, channels []Channel, prometheusCollector MetricsCollector, deviceID string) (*Publisher, error) {
+	// Default device ID if not provided
+	if deviceID == "" {
+		deviceID = "controller-1"
+	}
+
+	senders := make([]sender, 0, len(channels))
+	for i := range channels {
+		sender, err := newSender(&channels[i])
+		if err != nil {
+			return nil, fmt.Errorf("channel %s: %w", channels[i].Name, err)
+		}
+		senders = append(senders, sender)
+	}
+	return newPublisher(next, channels, senders, prometheusCollector, deviceID), nil
+}
+
+// newPublisher starts a publisher over the given senders, one per channel.
+func newPublisher(next publish.MessagePublisher, channels []Channel, senders []sender, prometheusCollector MetricsCollector, deviceID string) *Publisher {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Publisher{
+		next:     next,
+		deviceID: deviceID,
+		now:      time.Now,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	for i, config := range channels {
+		c := newChannel(config, senders[i], prometheusCollector, func() time.Time { return p.now() })
+		p.channels = append(p.channels, c)
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			c.run(ctx)
+		}()
+	}
+	return p
+}
+
+// NewPublisherFromConfig wraps next with the configured channels. This is the
+// production entry point; without any channels it returns next unchanged and
+// no notifier.
+func NewPublisherFromConfig(config Configuration, next publish.MessagePublisher, deviceID string) (publish.MessagePublisher, *Publisher, error) {
+	if len(config.Channels) == 0 {
+		return next, nil, nil
+	}
+
+	log.Infof("sending alerts to %d notification channels", len(config.Channels))
+
+	p, err := NewPublisher(next, config.Channels, NewPrometheusCollector(), deviceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, p, nil
+}
+
+// Publish passes the message on, then queues it on the channels that accept
+// it if it is an alert event of this device.
+func (p *Publisher) Publish(topicSuffix, payload string) {
+	p.next.Publish(topicSuffix, payload)
+
+	// Topic format: {deviceId}/alerts/{rule-name}
+	rule, ok := strings.CutPrefix(topicSuffix, p.deviceID+"/alerts/")
+	if !ok {
+		return
+	}
+	n, err := fromEvent(p.deviceID, rule, payload)
+	if err != nil {
+		log.Errorf("failed to read alert for notification: %s", err)
+		return
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return
+	}
+	for _, c := range p.channels {
+		if c.accepts(n) {
+			c.enqueue(n)
+		}
+	}
+}
+
+// Test sends a test notification through a channel at once, in a single
+// attempt, and returns why it failed. It goes around the rate limit, so a
+// channel can be checked while it is dropping alerts.
+func (p *Publisher) Test(ctx context.Context, name string) error {
+	for _, c := range p.channels {
+		if c.config.Name == name {
+			return c.deliver(ctx, testNotification(p.deviceID, name, p.now()), 0)
+		}
+	}
+	return fmt.Errorf("%w %s", ErrUnknownChannel, name)
+}
+
+// Statuses returns every channel in the order they are configured.
+func (p *Publisher) Statuses() []ChannelStatus {
+	statuses := make([]ChannelStatus, 0, len(p.channels))
+	for _, c := range p.channels {
+		statuses = append(statuses, c.status())
+	}
+	return statuses
+}
+
+// Close stops the channels, abandoning what they have not sent, and closes
+// the wrapped publisher.
+func (p *Publisher) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		p.cancel()
+		for _, c := range p.channels {
+			close(c.queue)
+		}
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	p.next.Close()
+}
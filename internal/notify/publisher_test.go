@@ -0,0 +1,146 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/alerts"
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+)
+
+func TestPublisherSendsAlertEvents(t *testing.T) {
+	publisher, next, fakes, metrics := newTestPublisher(t,
+		Channel{Name: "phone"},
+		Channel{Name: "email", MinSeverity: alerts.SeverityCritical, SendResolved: boolPtr(false)},
+	)
+	phone, email := fakes[0], fakes[1]
+
+	publisher.Publish("test-device-1/voltgo/battery-soc", `{"value":15,"unit":"percent","timestamp":1000}`)
+	publishAlert(t, publisher, "low-soc", alerts.SeverityWarning, alerts.StateFiring, 1000)
+	publishAlert(t, publisher, "empty", alerts.SeverityCritical, alerts.StateFiring, 1060)
+	publishAlert(t, publisher, "empty", alerts.SeverityCritical, alerts.StateResolved, 1120)
+
+	if len(next.PublishCalls) != 4 {
+		t.Fatalf("published %d messages, want the 4 passed on", len(next.PublishCalls))
+	}
+
+	first := phone.wait(t)
+	if first.Rule != "low-soc" || first.Severity != alerts.SeverityWarning || first.State != alerts.StateFiring || first.Value != 15 {
+		t.Errorf("first = %+v, want low-soc firing", first)
+	}
+	if first.Title != "test-device-1: low-soc firing" || first.DeviceID != "test-device-1" || first.Timestamp != 1000 {
+		t.Errorf("first = %+v, want the device in the title", first)
+	}
+	if n := phone.wait(t); n.Rule != "empty" || n.State != alerts.StateFiring {
+		t.Errorf("second = %+v, want empty firing", n)
+	}
+	if n := phone.wait(t); n.Rule != "empty" || n.State != alerts.StateResolved {
+		t.Errorf("third = %+v, want empty resolved", n)
+	}
+
+	// email takes only the critical alert firing
+	if n := email.wait(t); n.Rule != "empty" || n.State != alerts.StateFiring {
+		t.Errorf("email = %+v, want empty firing", n)
+	}
+
+	publisher.Close()
+	if len(email.sent) != 1 {
+		t.Errorf("email sent %d, want 1", len(email.sent))
+	}
+	if metrics.Sent["phone"] != 3 || metrics.Sent["email"] != 1 {
+		t.Errorf("Sent = %v, want 3 for phone and 1 for email", metrics.Sent)
+	}
+	if next.CloseCalls != 1 {
+		t.Errorf("CloseCalls = %d, want 1", next.CloseCalls)
+	}
+}
+
+func TestPublisherIgnoresOtherTopics(t *testing.T) {
+	publisher, _, fakes, _ := newTestPublisher(t, Channel{Name: "phone"})
+
+	publisher.Publish("other-device/alerts/low-soc", `{"value":1,"state":"firing","severity":"warning","timestamp":1000}`)
+	publisher.Publish("test-device-1/alerts/low-soc", `not json`)
+	publisher.Publish("test-device-1/voltgo/battery-soc", `{"value":15,"unit":"percent","timestamp":1000}`)
+	publisher.Close()
+
+	if len(fakes[0].sent) != 0 {
+		t.Errorf("sent %+v, want nothing", fakes[0].sent)
+	}
+}
+
+func TestPublisherAfterClose(t *testing.T) {
+	publisher, _, fakes, _ := newTestPublisher(t, Channel{Name: "phone"})
+	publisher.Close()
+	publisher.Close()
+
+	publishAlert(t, publisher, "low-soc", alerts.SeverityWarning, alerts.StateFiring, 1000)
+	if len(fakes[0].sent) != 0 {
+		t.Errorf("sent %+v after Close, want nothing", fakes[0].sent)
+	}
+}
+
+func TestPublisherTest(t *testing.T) {
+	publisher, _, fakes, _ := newTestPublisher(t,
+		Channel{Name: "phone", MaxPerHour: 1},
+		Channel{Name: "email", MinSeverity: alerts.SeverityCritical, Retries: intPtr(3)},
+	)
+	publisher.now = func() time.Time { return time.Unix(2000, 0) }
+
+	// The test goes around the rate limit
+	publishAlert(t, publisher, "low-soc", alerts.SeverityWarning, alerts.StateFiring, 1000)
+	fakes[0].wait(t)
+	if err := publisher.Test(context.Background(), "phone"); err != nil {
+		t.Fatalf("Test(phone) error = %v", err)
+	}
+	n := fakes[0].wait(t)
+	if !n.Test || n.Title != "test-device-1: test notification" || n.Timestamp != 2000 || n.Message != "This is a test of the phone notification channel." {
+		t.Errorf("test notification = %+v", n)
+	}
+
+	// A single attempt, so the caller hears about a failure at once
+	fakes[1].failures, fakes[1].err = 1, errUnavailable
+	if err := publisher.Test(context.Background(), "email"); !errors.Is(err, errUnavailable) {
+		t.Errorf("Test(email) error = %v, want %v", err, errUnavailable)
+	}
+	if err := publisher.Test(context.Background(), "pager"); !errors.Is(err, ErrUnknownChannel) {
+		t.Errorf("Test(pager) error = %v, want ErrUnknownChannel", err)
+	}
+
+	statuses := publisher.Statuses()
+	if len(statuses) != 2 || statuses[0].Sent != 2 || *statuses[0].LastSent != 2000 || statuses[1].Failed != 1 {
+		t.Errorf("Statuses() = %+v, want phone with 2 sent and email with 1 failed", statuses)
+	}
+}
+
+func TestNewPublisher(t *testing.T) {
+	next := &testutil.MockMessagePublisher{}
+	publisher, err := NewPublisher(next, []Channel{
+		{Name: "phone", Type: TypeNtfy, Ntfy: &NtfyConfiguration{URL: "https://ntfy.sh/cabin"}},
+		{Name: "hook", Type: TypeWebhook, Webhook: &WebhookConfiguration{URL: "https://example.com/hook"}},
+	}, &MockMetricsCollector{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+	if publisher.deviceID != "controller-1" || len(publisher.channels) != 2 {
+		t.Errorf("publisher = %+v, want the default device and 2 channels", publisher)
+	}
+
+	_, err = NewPublisher(next, []Channel{{Name: "hook", Type: TypeWebhook, Webhook: &WebhookConfiguration{URL: "https://example.com/hook", Body: "{{"}}}, &MockMetricsCollector{}, "")
+	if err == nil {
+		t.Error("NewPublisher() error = nil, want a template error")
+	}
+}
+
+func TestNewPublisherFromConfigWithoutChannels(t *testing.T) {
+	next := &testutil.MockMessagePublisher{}
+	publisher, notifier, err := NewPublisherFromConfig(Configuration{}, next, "test-device-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if publisher != next || notifier != nil {
+		t.Errorf("NewPublisherFromConfig() = %v, %v, want next unchanged and no notifier", publisher, notifier)
+	}
+}
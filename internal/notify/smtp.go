@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// smtpSender sends each notification as a plain-text email.
+type smtpSender struct {
+	config *SMTPConfiguration
+
+	// tlsConfig is used for STARTTLS; tests set it to trust their own
+	// certificate
+	tlsConfig *tls.Config
+}
+
+func newSMTPSender(config *SMTPConfiguration) *smtpSender {
+	return &smtpSender{
+		config:    config,
+		tlsConfig: &tls.Config{ServerName: config.Host, MinVersion: tls.VersionTLS12},
+	}
+}
+
+func (s *smtpSender) send(ctx context.Context, n Notification) error {
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.GetPort()))
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	// Bound the whole conversation by the attempt's timeout
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if s.config.GetStartTLS() {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return unrecoverable(fmt.Errorf("%s does not offer STARTTLS", addr))
+		}
+		if err := client.StartTLS(s.tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS: %w", err)
+		}
+	}
+	if s.config.Username != "" {
+		auth := smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return smtpError("auth", err)
+		}
+	}
+
+	if err := client.Mail(s.config.From); err != nil {
+		return smtpError("MAIL FROM", err)
+	}
+	for _, to := range s.config.To {
+		if err := client.Rcpt(to); err != nil {
+			return smtpError("RCPT TO "+to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return smtpError("DATA", err)
+	}
+	if _, err := w.Write(s.message(n)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return smtpError("DATA", err)
+	}
+	return client.Quit()
+}
+
+// message formats the email, headers and body, with CRLF line endings.
+func (s *smtpSender) message(n Notification) []byte {
+	var b bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", name, value)
+	}
+	header("From", s.config.From)
+	header("To", strings.Join(s.config.To, ", "))
+	header("Subject", "[solar-controller] "+n.Title)
+	header("Date", n.Time().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	b.WriteString("\r\n")
+
+	body := n.Message
+	if n.Condition != "" {
+		body += fmt.Sprintf("\n\nSeverity: %s\nCondition: %s\nDevice: %s\nTime: %s",
+			n.Severity, n.Condition, n.DeviceID, n.Time().Format(time.RFC3339))
+	}
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// smtpError does not retry a permanent (5xx) rejection.
+func smtpError(command string, err error) error {
+	err = fmt.Errorf("%s: %w", command, err)
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+		return unrecoverable(err)
+	}
+	return err
+}
@@ -0,0 +1,282 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpServer is a local stand-in SMTP server that speaks just enough of the
+// protocol for net/smtp: STARTTLS, AUTH PLAIN and one message per
+// connection.
+type smtpServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+
+	// offerTLS advertises STARTTLS, and rejectRcpt answers RCPT TO with
+	// 550
+	offerTLS   bool
+	rejectRcpt bool
+
+	mu   sync.Mutex
+	tls  bool
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+func newSMTPServer(t *testing.T) (*smtpServer, *tls.Config) {
+	t.Helper()
+
+	cert, pool := selfSignedCert(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{
+		listener:  listener,
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12},
+		offerTLS:  true,
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s, &tls.Config{RootCAs: pool, ServerName: "127.0.0.1", MinVersion: tls.VersionTLS12}
+}
+
+// config returns an SMTP configuration for the server.
+func (s *smtpServer) config() *SMTPConfiguration {
+	port := s.listener.Addr().(*net.TCPAddr).Port
+	return &SMTPConfiguration{
+		Host:     "127.0.0.1",
+		Port:     port,
+		Username: "cabin",
+		Password: "secret",
+		From:     "cabin@example.com",
+		To:       []string{"me@example.com", "you@example.com"},
+	}
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	_ = text.PrintfLine("220 127.0.0.1 ESMTP stand-in")
+
+	secure := false
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			lines := []string{"250-127.0.0.1"}
+			if s.offerTLS && !secure {
+				lines = append(lines, "250-STARTTLS")
+			}
+			lines = append(lines, "250 AUTH PLAIN")
+			_ = text.PrintfLine("%s", strings.Join(lines, "\r\n"))
+		case "STARTTLS":
+			_ = text.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, secure = tlsConn, true
+			text = textproto.NewConn(conn)
+			s.mu.Lock()
+			s.tls = true
+			s.mu.Unlock()
+		case "AUTH":
+			s.mu.Lock()
+			s.auth = arg
+			s.mu.Unlock()
+			_ = text.PrintfLine("235 accepted")
+		case "MAIL":
+			s.mu.Lock()
+			s.from = arg
+			s.mu.Unlock()
+			_ = text.PrintfLine("250 ok")
+		case "RCPT":
+			if s.rejectRcpt {
+				_ = text.PrintfLine("550 no such user")
+				continue
+			}
+			s.mu.Lock()
+			s.to = append(s.to, arg)
+			s.mu.Unlock()
+			_ = text.PrintfLine("250 ok")
+		case "DATA":
+			_ = text.PrintfLine("354 go ahead")
+			data, err := readDot(text.R)
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data = data
+			s.mu.Unlock()
+			_ = text.PrintfLine("250 queued")
+		case "QUIT":
+			_ = text.PrintfLine("221 bye")
+			return
+		default:
+			_ = text.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func readDot(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if line == ".\r\n" {
+			return b.String(), nil
+		}
+		b.WriteString(line)
+	}
+}
+
+// selfSignedCert makes a certificate for 127.0.0.1 and a pool that trusts
+// it.
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+func TestSMTPSenderStartTLS(t *testing.T) {
+	server, clientTLS := newSMTPServer(t)
+	sender := newSMTPSender(server.config())
+	sender.tlsConfig = clientTLS
+
+	if err := sender.send(context.Background(), sampleNotification()); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if !server.tls {
+		t.Error("the connection was not upgraded with STARTTLS")
+	}
+	wantAuth := "PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00cabin\x00secret"))
+	if server.auth != wantAuth {
+		t.Errorf("AUTH %q, want %q", server.auth, wantAuth)
+	}
+	if server.from != "FROM:<cabin@example.com>" || len(server.to) != 2 || server.to[1] != "TO:<you@example.com>" {
+		t.Errorf("MAIL %q, RCPT %q, want the configured addresses", server.from, server.to)
+	}
+	for _, want := range []string{
+		"Subject: [solar-controller] cabin-1: low-soc firing\r\n",
+		"To: me@example.com, you@example.com\r\n",
+		"Date: " + time.Unix(1717243200, 0).Format(time.RFC1123Z) + "\r\n",
+		"\r\nlow-soc fired: voltgo/battery-soc is 15 (voltgo/battery-soc < 20)\r\n\r\nSeverity: critical\r\n",
+	} {
+		if !strings.Contains(server.data, want) {
+			t.Errorf("message does not contain %q:\n%s", want, server.data)
+		}
+	}
+}
+
+func TestSMTPSenderRequiresStartTLS(t *testing.T) {
+	server, clientTLS := newSMTPServer(t)
+	server.offerTLS = false
+	sender := newSMTPSender(server.config())
+	sender.tlsConfig = clientTLS
+
+	err := sender.send(context.Background(), sampleNotification())
+	if err == nil || !strings.Contains(err.Error(), "does not offer STARTTLS") {
+		t.Errorf("send() error = %v, want STARTTLS refused", err)
+	}
+}
+
+func TestSMTPSenderWithoutTLS(t *testing.T) {
+	server, _ := newSMTPServer(t)
+	config := server.config()
+	config.StartTLS = boolPtr(false)
+	config.Username = ""
+
+	if err := newSMTPSender(config).send(context.Background(), sampleNotification()); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.tls || server.auth != "" || server.data == "" {
+		t.Errorf("tls = %v, auth = %q, want a plain message without auth", server.tls, server.auth)
+	}
+}
+
+func TestSMTPSenderRejectedRecipientIsNotRetried(t *testing.T) {
+	server, clientTLS := newSMTPServer(t)
+	server.rejectRcpt = true
+	sender := newSMTPSender(server.config())
+	sender.tlsConfig = clientTLS
+
+	c := newChannel(Channel{Name: "email", RetryDelay: "1ms"}, sender, &MockMetricsCollector{}, time.Now)
+	err := c.deliver(context.Background(), sampleNotification(), 3)
+	if err == nil || !strings.Contains(err.Error(), "550") {
+		t.Errorf("deliver() error = %v, want the 550", err)
+	}
+}
+
+func TestSMTPSenderUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+
+	sender := newSMTPSender(&SMTPConfiguration{Host: "127.0.0.1", Port: port, From: "a@example.com", To: []string{"b@example.com"}})
+	if err := sender.send(context.Background(), sampleNotification()); err == nil {
+		t.Error("send() error = nil, want a connection error")
+	}
+}
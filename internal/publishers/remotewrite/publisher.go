@@ -38,12 +38,24 @@ type metricData struct {
 }
 
 // MetricPayload represents the JSON payload structure from controllers.
+//
+// Metric, when set, names the series in place of the topic's metric name, and
+// Labels are added to it; controllers use them to publish several values of
+// one series, such as a voltage per cell, under topics of their own. A Value
+// that is a list holds several samples, each an object with a value and the
+// labels that tell it apart.
 type MetricPayload struct {
-	Value     interface{} `json:"value"`
-	Unit      string      `json:"unit"`
-	Timestamp int64       `json:"timestamp"`
+	Value     interface{}       `json:"value"`
+	Unit      string            `json:"unit"`
+	Timestamp int64             `json:"timestamp"`
+	Metric    string            `json:"metric,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
 }
 
+// reservedLabels are set by the publisher and cannot be overridden by a
+// payload's labels.
+var reservedLabels = map[string]bool{"__name__": true, "device_id": true, "controller": true, "unit": true}
+
 // NewPublisher creates a new remote_write publisher.
 // resolveTopicPrefix returns the effective topic prefix using the priority:
 // configPrefix > "solar" default.
@@ -95,8 +107,8 @@ func (p *Publisher) Publish(topicSuffix, payload string) {
 		return // Publisher not enabled
 	}
 
-	// Parse the metric from the topic and payload
-	metric, err := p.parseMetric(topicSuffix, payload)
+	// Parse the metrics from the topic and payload
+	metrics, err := p.parseMetric(topicSuffix, payload)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"topicSuffix": topicSuffix,
@@ -109,7 +121,7 @@ func (p *Publisher) Publish(topicSuffix, payload string) {
 	defer p.mu.Unlock()
 
 	// Add to batch buffer
-	p.batchBuffer = append(p.batchBuffer, metric)
+	p.batchBuffer = append(p.batchBuffer, metrics...)
 
 	// Determine if we should flush the batch
 	// Strategy: Flush when we detect all metrics from a collection cycle
@@ -122,38 +134,38 @@ func (p *Publisher) Publish(topicSuffix, payload string) {
 	}
 }
 
-// parseMetric converts a topic suffix and JSON payload into metric data.
+// parseMetric converts a topic suffix and JSON payload into metric data:
+// one sample, or one per element of a list value.
 // Topic format: {deviceId}/{controller}/{metric-name}
 // Example: controller-123/epever/battery-voltage
-func (p *Publisher) parseMetric(topicSuffix, payload string) (metricData, error) {
+func (p *Publisher) parseMetric(topicSuffix, payload string) ([]metricData, error) {
 	// Parse topic suffix
 	parts := strings.Split(topicSuffix, "/")
 	if len(parts) != 3 {
-		return metricData{}, fmt.Errorf("invalid topic format, expected 3 parts: %s", topicSuffix)
+		return nil, fmt.Errorf("invalid topic format, expected 3 parts: %s", topicSuffix)
 	}
 
 	deviceID := parts[0]
 	controller := parts[1]
 	metricNameKebab := parts[2]
 
-	// Convert kebab-case to snake_case for Prometheus naming
-	metricNameSnake := strings.ReplaceAll(metricNameKebab, "-", "_")
-
-	// Construct Prometheus metric name: {controller}_{metric_name}
-	fullMetricName := fmt.Sprintf("%s_%s", controller, metricNameSnake)
-
 	// Parse JSON payload
 	var metricPayload MetricPayload
 	if err := json.Unmarshal([]byte(payload), &metricPayload); err != nil {
-		return metricData{}, fmt.Errorf("failed to unmarshal payload: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal payload: %w", err)
 	}
 
-	// Convert value to float64
-	value, err := toFloat64(metricPayload.Value)
-	if err != nil {
-		return metricData{}, fmt.Errorf("failed to convert value to float64: %w", err)
+	// The payload may name the series in place of the topic
+	if metricPayload.Metric != "" {
+		metricNameKebab = metricPayload.Metric
 	}
 
+	// Convert kebab-case to snake_case for Prometheus naming
+	metricNameSnake := strings.ReplaceAll(metricNameKebab, "-", "_")
+
+	// Construct Prometheus metric name: {controller}_{metric_name}
+	fullMetricName := fmt.Sprintf("%s_%s", controller, metricNameSnake)
+
 	// Build labels
 	labels := map[string]string{
 		"device_id":  deviceID,
@@ -165,12 +177,85 @@ func (p *Publisher) parseMetric(topicSuffix, payload string) (metricData, error)
 		labels["unit"] = metricPayload.Unit
 	}
 
-	return metricData{
-		metricName: fullMetricName,
-		labels:     labels,
-		value:      value,
-		timestamp:  metricPayload.Timestamp,
-	}, nil
+	addLabels(labels, metricPayload.Labels)
+
+	samples, ok := metricPayload.Value.([]interface{})
+	if !ok {
+		// Convert value to float64
+		value, err := toFloat64(metricPayload.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert value to float64: %w", err)
+		}
+
+		return []metricData{{
+			metricName: fullMetricName,
+			labels:     labels,
+			value:      value,
+			timestamp:  metricPayload.Timestamp,
+		}}, nil
+	}
+
+	metrics := make([]metricData, 0, len(samples))
+	for i, sample := range samples {
+		metric, err := parseSample(sample)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sample %d: %w", i, err)
+		}
+
+		metric.metricName = fullMetricName
+		metric.timestamp = metricPayload.Timestamp
+		for k, v := range labels {
+			if _, set := metric.labels[k]; !set {
+				metric.labels[k] = v
+			}
+		}
+		metrics = append(metrics, metric)
+	}
+
+	return metrics, nil
+}
+
+// parseSample converts one element of a list value, an object holding a
+// value and the labels for it, into its value and labels.
+func parseSample(sample interface{}) (metricData, error) {
+	fields, ok := sample.(map[string]interface{})
+	if !ok {
+		return metricData{}, fmt.Errorf("expected an object, got %T", sample)
+	}
+
+	value, err := toFloat64(fields["value"])
+	if err != nil {
+		return metricData{}, fmt.Errorf("failed to convert value to float64: %w", err)
+	}
+
+	labels := make(map[string]string, len(fields)-1)
+	for k, v := range fields {
+		if k == "value" {
+			continue
+		}
+		switch v := v.(type) {
+		case string:
+			labels[k] = v
+		case float64, bool:
+			labels[k] = fmt.Sprint(v)
+		default:
+			return metricData{}, fmt.Errorf("label %s has unsupported type %T", k, v)
+		}
+	}
+
+	added := make(map[string]string, len(labels))
+	addLabels(added, labels)
+	return metricData{labels: added, value: value}, nil
+}
+
+// addLabels adds the payload's labels to labels, leaving out any that would
+// replace one the publisher sets.
+func addLabels(labels, extra map[string]string) {
+	for k, v := range extra {
+		if !reservedLabels[k] {
+			labels[k] = v
+		}
+	}
 }
 
 // flushBatch sends the buffered metrics to the remote_write endpoint.
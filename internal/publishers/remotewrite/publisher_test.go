@@ -188,11 +188,64 @@ func TestParseMetric(t *testing.T) {
 			wantErr:     true,
 			errContains: "failed to convert",
 		},
+		{
+			name:        "payload metric and labels name a labelled series",
+			topicSuffix: "controller-123/voltgo/cell-voltage-3",
+			payload:     `{"value": 3.321, "unit": "volts", "timestamp": 1699000003, "metric": "cell-voltage", "labels": {"cell": "3"}}`,
+			wantMetric: metricData{
+				metricName: "voltgo_cell_voltage",
+				labels: map[string]string{
+					"device_id":  "controller-123",
+					"controller": "voltgo",
+					"unit":       "volts",
+					"cell":       "3",
+				},
+				value:     3.321,
+				timestamp: 1699000003,
+			},
+		},
+		{
+			name:        "payload labels cannot replace the publisher's",
+			topicSuffix: "controller-123/voltgo/cell-voltage-3",
+			payload:     `{"value": 3.321, "unit": "volts", "timestamp": 1699000003, "labels": {"device_id": "other", "cell": "3"}}`,
+			wantMetric: metricData{
+				metricName: "voltgo_cell_voltage_3",
+				labels: map[string]string{
+					"device_id":  "controller-123",
+					"controller": "voltgo",
+					"unit":       "volts",
+					"cell":       "3",
+				},
+				value:     3.321,
+				timestamp: 1699000003,
+			},
+		},
+		{
+			name:        "list sample that is not an object",
+			topicSuffix: "controller-123/voltgo/cell-voltages",
+			payload:     `{"value": [3.321], "unit": "volts", "timestamp": 1699000000}`,
+			wantErr:     true,
+			errContains: "invalid sample 0",
+		},
+		{
+			name:        "list sample without a number value",
+			topicSuffix: "controller-123/voltgo/cell-voltages",
+			payload:     `{"value": [{"cell": 0}], "unit": "volts", "timestamp": 1699000000}`,
+			wantErr:     true,
+			errContains: "failed to convert",
+		},
+		{
+			name:        "list sample with an object label",
+			topicSuffix: "controller-123/voltgo/cell-voltages",
+			payload:     `{"value": [{"cell": {}, "value": 3.3}], "unit": "volts", "timestamp": 1699000000}`,
+			wantErr:     true,
+			errContains: "unsupported type",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			metric, err := publisher.parseMetric(tt.topicSuffix, tt.payload)
+			metrics, err := publisher.parseMetric(tt.topicSuffix, tt.payload)
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -201,6 +254,8 @@ func TestParseMetric(t *testing.T) {
 				}
 			} else {
 				require.NoError(t, err)
+				require.Len(t, metrics, 1)
+				metric := metrics[0]
 				assert.Equal(t, tt.wantMetric.metricName, metric.metricName)
 				assert.Equal(t, tt.wantMetric.value, metric.value)
 				assert.Equal(t, tt.wantMetric.timestamp, metric.timestamp)
@@ -210,6 +265,29 @@ func TestParseMetric(t *testing.T) {
 	}
 }
 
+func TestParseMetricList(t *testing.T) {
+	publisher := &Publisher{deviceID: "controller-1"}
+
+	payload := `{"value": [{"cell": 0, "value": 3.321}, {"cell": "1", "value": 3.318, "balancing": true}],` +
+		` "unit": "volts", "timestamp": 1699000000, "metric": "house-a-cell-voltage"}`
+	metrics, err := publisher.parseMetric("controller-123/voltgo/house-a-cell-voltages", payload)
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+
+	for _, metric := range metrics {
+		assert.Equal(t, "voltgo_house_a_cell_voltage", metric.metricName)
+		assert.Equal(t, int64(1699000000), metric.timestamp)
+		assert.Equal(t, "controller-123", metric.labels["device_id"])
+		assert.Equal(t, "voltgo", metric.labels["controller"])
+		assert.Equal(t, "volts", metric.labels["unit"])
+	}
+	assert.Equal(t, 3.321, metrics[0].value)
+	assert.Equal(t, "0", metrics[0].labels["cell"])
+	assert.Equal(t, 3.318, metrics[1].value)
+	assert.Equal(t, "1", metrics[1].labels["cell"])
+	assert.Equal(t, "true", metrics[1].labels["balancing"])
+}
+
 func TestPublishWithMockServer(t *testing.T) {
 	// Track requests received by the mock server
 	var receivedRequests []receivedRequest
@@ -0,0 +1,95 @@
+package reports
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Report is the body of GET /api/reports/{report}.
+type Report struct {
+	// Report is daily, monthly or yearly
+	Report    string    `json:"report"`
+	Summaries []Summary `json:"summaries"`
+}
+
+// csvHeader names the columns of a CSV report, as the JSON fields.
+var csvHeader = []string{
+	"period", "days", "complete", "pvKwh", "consumedKwh", "peakPvPower", "peakLoadPower",
+	"minBatteryVoltage", "maxBatteryVoltage", "minSoc", "floatHours",
+}
+
+// ReportGet returns the daily, monthly or yearly report of the days from
+// ?from= to ?to= (2006-01-02, inclusive, default every day kept), as JSON or,
+// with ?format=csv, as a CSV download.
+func (p *Publisher) ReportGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := c.Param("report")
+		if report != ReportDaily && report != ReportMonthly && report != ReportYearly {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("no %s report; use daily, monthly or yearly", report)})
+			return
+		}
+
+		var bounds [2]string
+		for i, name := range []string{"from", "to"} {
+			value := c.Query(name)
+			if value == "" {
+				continue
+			}
+			if _, err := time.Parse(dayFormat, value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be a day such as 2024-06-01, not %q", name, value)})
+				return
+			}
+			bounds[i] = value
+		}
+
+		summaries := p.Summaries(report, bounds[0], bounds[1])
+
+		switch format := c.DefaultQuery("format", "json"); format {
+		case "json":
+			c.JSON(http.StatusOK, Report{Report: report, Summaries: summaries})
+		case "csv":
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-report.csv"`, report))
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			c.Status(http.StatusOK)
+			if err := writeCSV(c.Writer, summaries); err != nil {
+				_ = c.Error(err)
+			}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("format must be json or csv, not %q", format)})
+		}
+	}
+}
+
+// writeCSV writes summaries under csvHeader. A null value is an empty cell.
+func writeCSV(w io.Writer, summaries []Summary) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, summary := range summaries {
+		record := []string{summary.Period, strconv.Itoa(summary.Days), strconv.FormatBool(summary.Complete)}
+		for _, value := range summary.values() {
+			cell := ""
+			if *value != nil {
+				cell = strconv.FormatFloat(**value, 'f', -1, 64)
+			}
+			record = append(record, cell)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// RegisterEndpoints adds the report endpoints.
+func (p *Publisher) RegisterEndpoints(r *gin.Engine) {
+	r.GET("/api/reports/:report", p.ReportGet())
+}
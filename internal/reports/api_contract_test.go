@@ -0,0 +1,32 @@
+package reports
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ReportGet serialises Report directly; each summary is checked on its own,
+// and is also the header of the CSV form.
+func TestReportMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(Report{Summaries: []Summary{}})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/reports/{report}"),
+		testutil.JSONFieldNames(t, payload),
+		"Report no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+
+	summary, err := json.Marshal(Summary{})
+	require.NoError(t, err)
+
+	fields := testutil.APIContractFields(t, "GET /api/reports/{report}#summaries[]")
+	assert.Equal(t, fields, testutil.JSONFieldNames(t, summary),
+		"Summary no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+	assert.ElementsMatch(t, fields, csvHeader, "the CSV columns should be the JSON fields")
+}
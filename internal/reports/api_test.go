@@ -0,0 +1,95 @@
+package reports
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	publisher, _, _ := newTestPublisher(t)
+	publisher.ledger = &ledger{
+		Days: []Summary{
+			{Period: "2024-05-31", Days: 1, Complete: true, PVKWh: float(1.5), MinSOC: float(40)},
+			{Period: "2024-06-01", Days: 1, Complete: true, PVKWh: float(2.25), ConsumedKWh: float(1.75)},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	publisher.RegisterEndpoints(router)
+	return router
+}
+
+func TestReportGet(t *testing.T) {
+	router := newTestRouter(t)
+
+	tests := []struct {
+		target      string
+		wantStatus  int
+		wantPeriods []string
+	}{
+		{"/api/reports/daily", http.StatusOK, []string{"2024-05-31", "2024-06-01"}},
+		{"/api/reports/daily?from=2024-06-01&format=json", http.StatusOK, []string{"2024-06-01"}},
+		{"/api/reports/monthly", http.StatusOK, []string{"2024-05", "2024-06"}},
+		{"/api/reports/yearly?to=2024-05-31", http.StatusOK, []string{"2024"}},
+		{"/api/reports/weekly", http.StatusNotFound, nil},
+		{"/api/reports/daily?from=June", http.StatusBadRequest, nil},
+		{"/api/reports/daily?to=2024-13-01", http.StatusBadRequest, nil},
+		{"/api/reports/daily?format=xml", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var report Report
+			if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Summaries) != len(tt.wantPeriods) {
+				t.Fatalf("summaries = %+v, want %v", report.Summaries, tt.wantPeriods)
+			}
+			for i, summary := range report.Summaries {
+				if summary.Period != tt.wantPeriods[i] {
+					t.Errorf("summary %d = %s, want %s", i, summary.Period, tt.wantPeriods[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReportGet_CSV(t *testing.T) {
+	router := newTestRouter(t)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/reports/daily?format=csv", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", recorder.Code)
+	}
+	if got := recorder.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %s, want text/csv", got)
+	}
+	if got := recorder.Header().Get("Content-Disposition"); got != `attachment; filename="daily-report.csv"` {
+		t.Errorf("Content-Disposition = %s", got)
+	}
+
+	want := "period,days,complete,pvKwh,consumedKwh,peakPvPower,peakLoadPower,minBatteryVoltage,maxBatteryVoltage,minSoc,floatHours\n" +
+		"2024-05-31,1,true,1.5,,,,,,40,\n" +
+		"2024-06-01,1,true,2.25,1.75,,,,,,\n"
+	if recorder.Body.String() != want {
+		t.Errorf("body =\n%s\nwant\n%s", recorder.Body.String(), want)
+	}
+}
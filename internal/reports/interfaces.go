@@ -0,0 +1,11 @@
+package reports
+
+// MetricsCollector defines the interface for collecting and exposing metrics.
+// This abstraction allows for testing without the Prometheus global registry.
+type MetricsCollector interface {
+	// SetDays updates the number of closed days kept.
+	SetDays(count int)
+
+	// IncrementSaveFailures increments the counter of failed saves.
+	IncrementSaveFailures()
+}
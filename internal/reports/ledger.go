@@ -0,0 +1,128 @@
+package reports
+
+import (
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/energy"
+)
+
+// What each source is read for
+const (
+	rolePV             = "pv"
+	roleLoad           = "load"
+	roleBatteryVoltage = "batteryVoltage"
+	roleSOC            = "soc"
+	roleChargingStatus = "chargingStatus"
+)
+
+// reading is the last value of a source.
+type reading struct {
+	Timestamp int64   `json:"t"`
+	Value     float64 `json:"v"`
+}
+
+// ledger is every closed day and the one in progress, along with the last
+// reading of each source to count the next from.
+type ledger struct {
+	// Days are the closed days, oldest first
+	Days  []Summary          `json:"days"`
+	Today Summary            `json:"today"`
+	Last  map[string]reading `json:"last"`
+}
+
+// add counts a reading of the source read for role, first closing the day in
+// progress if the reading is from a later one; the closed day is returned. A
+// reading from an earlier day than the one in progress, or no newer than the
+// last of its source, is dropped.
+//
+// The interval since the last reading counts towards the day of this one, so
+// one spanning midnight lands in the new day.
+func (l *ledger) add(role string, timestamp int64, value float64, location *time.Location) *Summary {
+	day := time.Unix(timestamp, 0).In(location).Format(dayFormat)
+	if day < l.Today.Period {
+		return nil
+	}
+	closed := l.rollOver(day)
+
+	last, seen := l.Last[role]
+	if seen && timestamp <= last.Timestamp {
+		return closed
+	}
+	if l.Last == nil {
+		l.Last = make(map[string]reading)
+	}
+	l.Last[role] = reading{Timestamp: timestamp, Value: value}
+
+	// hours is the interval counted, zero after a gap or the first reading
+	var hours float64
+	if seen {
+		hours = energy.Hours(last.Timestamp, timestamp, maxGap)
+	}
+
+	today := &l.Today
+	switch role {
+	case rolePV, roleLoad:
+		power := max(value, 0)
+		wh, _ := energy.Integrate(max(last.Value, 0), power, hours)
+		kwh := wh / 1000
+		if role == rolePV {
+			today.PVKWh = sum(today.PVKWh, &kwh)
+			today.PeakPVPower = highest(today.PeakPVPower, &power)
+		} else {
+			today.ConsumedKWh = sum(today.ConsumedKWh, &kwh)
+			today.PeakLoadPower = highest(today.PeakLoadPower, &power)
+		}
+	case roleBatteryVoltage:
+		today.MinBatteryVoltage = lowest(today.MinBatteryVoltage, &value)
+		today.MaxBatteryVoltage = highest(today.MaxBatteryVoltage, &value)
+	case roleSOC:
+		today.MinSOC = lowest(today.MinSOC, &value)
+	case roleChargingStatus:
+		// The status held from the last reading to this one
+		var floatHours float64
+		if last.Value == floatStatus {
+			floatHours = hours
+		}
+		today.FloatHours = sum(today.FloatHours, &floatHours)
+	}
+	return closed
+}
+
+// rollOver starts day if it is later than the day in progress, closing that
+// one and returning it. A day without readings is not kept.
+func (l *ledger) rollOver(day string) *Summary {
+	if day <= l.Today.Period {
+		return nil
+	}
+	previous := l.Today
+	l.Today = Summary{Period: day, Days: 1}
+	if previous.Period == "" || previous.empty() {
+		return nil
+	}
+
+	closed := previous.rounded()
+	closed.Complete = true
+	l.Days = append(l.Days, closed)
+	return &closed
+}
+
+// summaries returns the days from from to to (2006-01-02, inclusive, either
+// empty for no bound), today's so far included, as the report asks: one
+// summary per day, month or year. today is the local day now.
+func (l *ledger) summaries(report, from, to, today string) []Summary {
+	all := make([]Summary, 0, len(l.Days)+1)
+	all = append(append(all, l.Days...), l.Today)
+
+	days := make([]Summary, 0, len(all))
+	for _, day := range all {
+		if day.empty() || day.Period < from || (to != "" && day.Period > to) {
+			continue
+		}
+		days = append(days, day.rounded())
+	}
+
+	if report == ReportDaily {
+		return days
+	}
+	return rollUp(days, report, today)
+}
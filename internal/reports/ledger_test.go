@@ -0,0 +1,147 @@
+package reports
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLedger_Energy(t *testing.T) {
+	l := &ledger{}
+	start := day(2024, time.June, 1, 12, 0)
+
+	// 1000 W for an hour in 5 minute steps, a 20 minute gap that is not
+	// counted, then one more step
+	for i := 0; i <= 12; i++ {
+		l.add(rolePV, start.Add(time.Duration(i)*5*time.Minute).Unix(), 1000, time.UTC)
+	}
+	l.add(rolePV, start.Add(80*time.Minute).Unix(), 1200, time.UTC)
+	l.add(rolePV, start.Add(85*time.Minute).Unix(), 800, time.UTC)
+
+	today := l.Today.rounded()
+	if today.Period != "2024-06-01" || *today.PVKWh != 1.083 || *today.PeakPVPower != 1200 {
+		t.Errorf("today = %+v, want 1.083 kWh and a peak of 1200 W", today)
+	}
+	if today.ConsumedKWh != nil {
+		t.Errorf("consumed = %v, want null without a load reading", *today.ConsumedKWh)
+	}
+
+	// Negative noise is no power
+	l.add(roleLoad, start.Unix(), -5, time.UTC)
+	l.add(roleLoad, start.Add(5*time.Minute).Unix(), -5, time.UTC)
+	if *l.Today.ConsumedKWh != 0 || *l.Today.PeakLoadPower != 0 {
+		t.Errorf("consumed = %v at %v, want 0", *l.Today.ConsumedKWh, *l.Today.PeakLoadPower)
+	}
+}
+
+func TestLedger_RangesAndFloat(t *testing.T) {
+	l := &ledger{}
+	start := day(2024, time.June, 1, 12, 0)
+
+	for i, v := range []float64{13.2, 12.4, 14.6} {
+		l.add(roleBatteryVoltage, start.Add(time.Duration(i)*time.Minute).Unix(), v, time.UTC)
+	}
+	for i, v := range []float64{80, 45, 60} {
+		l.add(roleSOC, start.Add(time.Duration(i)*time.Minute).Unix(), v, time.UTC)
+	}
+	// Boost, then float for 10 minutes, then no charging
+	for i, v := range []float64{2, 1, 1, 0} {
+		l.add(roleChargingStatus, start.Add(time.Duration(i)*5*time.Minute).Unix(), v, time.UTC)
+	}
+
+	today := l.Today
+	if *today.MinBatteryVoltage != 12.4 || *today.MaxBatteryVoltage != 14.6 || *today.MinSOC != 45 {
+		t.Errorf("today = %+v, want 12.4 to 14.6 V and a low of 45%%", today)
+	}
+	if got := *today.rounded().FloatHours; got != 0.167 {
+		t.Errorf("float hours = %v, want 0.167", got)
+	}
+}
+
+func TestLedger_RollOver(t *testing.T) {
+	l := &ledger{}
+
+	l.add(rolePV, day(2024, time.June, 1, 23, 55).Unix(), 100, time.UTC)
+	closed := l.add(rolePV, day(2024, time.June, 2, 0, 5).Unix(), 100, time.UTC)
+	if closed == nil || closed.Period != "2024-06-01" || !closed.Complete || *closed.PVKWh != 0 {
+		t.Fatalf("closed = %+v, want June 1 with nothing counted", closed)
+	}
+	// The interval across midnight counts towards the new day
+	if l.Today.Period != "2024-06-02" || *l.Today.rounded().PVKWh != 0.017 {
+		t.Errorf("today = %+v, want June 2 with the 10 minutes across midnight", l.Today)
+	}
+
+	// A reading of an earlier day, or one no newer than the last, is dropped
+	if closed := l.add(rolePV, day(2024, time.June, 1, 23, 59).Unix(), 5000, time.UTC); closed != nil {
+		t.Errorf("an earlier day closed %+v", closed)
+	}
+	l.add(rolePV, day(2024, time.June, 2, 0, 5).Unix(), 5000, time.UTC)
+	if *l.Today.PeakPVPower != 100 {
+		t.Errorf("peak = %v, want the dropped readings left out", *l.Today.PeakPVPower)
+	}
+
+	// A day without readings is not kept
+	if closed := l.rollOver("2024-06-03"); closed == nil {
+		t.Fatal("June 2 not closed")
+	}
+	if closed := l.rollOver("2024-06-04"); closed != nil {
+		t.Errorf("an empty day closed as %+v", closed)
+	}
+	if len(l.Days) != 2 {
+		t.Errorf("days = %+v, want June 1 and 2", l.Days)
+	}
+
+	// A day is read in the location given
+	toronto, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		t.Skipf("no time zone database: %s", err)
+	}
+	l.add(roleSOC, day(2024, time.June, 5, 2, 0).Unix(), 50, toronto)
+	if l.Today.Period != "2024-06-04" {
+		t.Errorf("today = %s, want June 4 in Toronto", l.Today.Period)
+	}
+}
+
+func TestLedger_Summaries(t *testing.T) {
+	l := &ledger{
+		Days: []Summary{
+			{Period: "2024-05-31", Days: 1, Complete: true, PVKWh: float(1)},
+			{Period: "2024-06-01", Days: 1, Complete: true, PVKWh: float(2)},
+		},
+		Today: Summary{Period: "2024-06-02", Days: 1, PVKWh: float(0.5)},
+	}
+
+	tests := []struct {
+		report, from, to string
+		want             []string
+	}{
+		{ReportDaily, "", "", []string{"2024-05-31", "2024-06-01", "2024-06-02"}},
+		{ReportDaily, "2024-06-01", "", []string{"2024-06-01", "2024-06-02"}},
+		{ReportDaily, "", "2024-06-01", []string{"2024-05-31", "2024-06-01"}},
+		{ReportMonthly, "", "", []string{"2024-05", "2024-06"}},
+		{ReportYearly, "", "", []string{"2024"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.report+" from "+tt.from+" to "+tt.to, func(t *testing.T) {
+			summaries := l.summaries(tt.report, tt.from, tt.to, "2024-06-02")
+			if len(summaries) != len(tt.want) {
+				t.Fatalf("summaries = %+v, want %v", summaries, tt.want)
+			}
+			for i, summary := range summaries {
+				if summary.Period != tt.want[i] {
+					t.Errorf("summary %d = %s, want %s", i, summary.Period, tt.want[i])
+				}
+			}
+		})
+	}
+
+	// Today is not complete
+	if summaries := l.summaries(ReportDaily, "2024-06-02", "", "2024-06-02"); summaries[0].Complete {
+		t.Error("today should not be complete")
+	}
+
+	// Without readings today is left out
+	l.Today = Summary{Period: "2024-06-02", Days: 1}
+	if summaries := l.summaries(ReportDaily, "", "", "2024-06-02"); len(summaries) != 2 {
+		t.Errorf("summaries = %+v, want the 2 closed days", summaries)
+	}
+}
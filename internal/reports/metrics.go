@@ -0,0 +1,62 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Metric represents a single metric with its value, unit, and timestamp
+type Metric struct {
+	Name      string
+	Value     any
+	Unit      string
+	Timestamp int64
+}
+
+// MetricPayload is the JSON structure published for each metric
+type MetricPayload struct {
+	Value     any    `json:"value"`
+	Unit      string `json:"unit"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// ToJSON converts a Metric to its JSON representation
+func (m *Metric) ToJSON() (string, error) {
+	payload := MetricPayload{
+		Value:     m.Value,
+		Unit:      m.Unit,
+		Timestamp: m.Timestamp,
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal metric payload: %w", err)
+	}
+
+	return string(b), nil
+}
+
+// ConvertSummaryToMetrics converts a closed day to the end-of-day metrics.
+// Values whose source published nothing that day are left out.
+func ConvertSummaryToMetrics(day *Summary, timestamp int64) []Metric {
+	var metrics []Metric
+	for _, m := range []struct {
+		name  string
+		value *float64
+		unit  string
+	}{
+		{"pv-energy-daily", day.PVKWh, "kilowatt-hours"},
+		{"consumed-energy-daily", day.ConsumedKWh, "kilowatt-hours"},
+		{"peak-pv-power-daily", day.PeakPVPower, "watts"},
+		{"peak-load-power-daily", day.PeakLoadPower, "watts"},
+		{"battery-voltage-min-daily", day.MinBatteryVoltage, "volts"},
+		{"battery-voltage-max-daily", day.MaxBatteryVoltage, "volts"},
+		{"soc-min-daily", day.MinSOC, "percent"},
+		{"float-hours-daily", day.FloatHours, "hours"},
+	} {
+		if m.value != nil {
+			metrics = append(metrics, Metric{Name: m.name, Value: *m.value, Unit: m.unit, Timestamp: timestamp})
+		}
+	}
+	return metrics
+}
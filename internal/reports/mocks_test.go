@@ -0,0 +1,49 @@
+package reports
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+)
+
+// MockMetricsCollector is a mock implementation of MetricsCollector for testing.
+type MockMetricsCollector struct {
+	Days         int
+	SaveFailures int
+}
+
+func (m *MockMetricsCollector) SetDays(count int) {
+	m.Days = count
+}
+
+func (m *MockMetricsCollector) IncrementSaveFailures() {
+	m.SaveFailures++
+}
+
+// newTestPublisher creates a publisher of the default sources on UTC days.
+func newTestPublisher(t *testing.T) (*Publisher, *testutil.MockMessagePublisher, *MockMetricsCollector) {
+	t.Helper()
+
+	next := &testutil.MockMessagePublisher{}
+	metrics := &MockMetricsCollector{}
+	publisher := newPublisherForTest(next, Sources{}, metrics, "test-device-1")
+	publisher.location = time.UTC
+	return publisher, next, metrics
+}
+
+// publishMetric publishes a reading of a metric, such as epever/charging-power,
+// at the given UTC time.
+func publishMetric(p *Publisher, metric string, value float64, at time.Time) {
+	payload, _ := json.Marshal(MetricPayload{Value: value, Unit: "watts", Timestamp: at.Unix()})
+	p.Publish("test-device-1/"+metric, string(payload))
+}
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func day(year int, month time.Month, d, hour, minute int) time.Time {
+	return time.Date(year, month, d, hour, minute, 0, 0, time.UTC)
+}
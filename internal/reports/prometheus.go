@@ -0,0 +1,34 @@
+package reports
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type PrometheusCollector struct {
+	days         prometheus.Gauge
+	saveFailures prometheus.Counter
+}
+
+func NewPrometheusCollector() *PrometheusCollector {
+	return &PrometheusCollector{
+		days: promauto.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "days",
+			Help:      "Number of closed days kept for the reports.",
+		}),
+		saveFailures: promauto.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "save_failures",
+			Help:      "Number of times the reports could not be saved.",
+		}),
+	}
+}
+
+func (r *PrometheusCollector) SetDays(count int) {
+	r.days.Set(float64(count))
+}
+
+func (r *PrometheusCollector) IncrementSaveFailures() {
+	r.saveFailures.Inc()
+}
@@ -0,0 +1,26 @@
+package reports
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Verify PrometheusCollector implements MetricsCollector
+var _ MetricsCollector = (*PrometheusCollector)(nil)
+
+// The collector registers with the global Prometheus registry via promauto,
+// so it can only be created once per test binary.
+func TestPrometheusCollector(t *testing.T) {
+	collector := NewPrometheusCollector()
+
+	collector.SetDays(31)
+	collector.IncrementSaveFailures()
+
+	if got := testutil.ToFloat64(collector.days); got != 31 {
+		t.Errorf("reports_days = %v, want 31", got)
+	}
+	if got := testutil.ToFloat64(collector.saveFailures); got != 1 {
+		t.Errorf("reports_save_failures = %v, want 1", got)
+	}
+}
@@ -0,0 +1,232 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-co-op/gocron"
+	"github.com/lumberbarons/solar-controller/internal/publish"
+	"github.com/lumberbarons/solar-controller/internal/state"
+	log "github.com/sirupsen/logrus"
+)
+
+// fileName is the file under the data directory that holds the days
+const fileName = "reports.json"
+
+// Publisher passes every message on to the publisher it wraps and counts the
+// readings of its sources into the day in progress. At local midnight, or at
+// the first reading of a later day, the day is closed, kept, and published as
+// the end-of-day summary.
+type Publisher struct {
+	next                publish.MessagePublisher
+	prometheusCollector MetricsCollector
+	scheduler           *gocron.Scheduler
+	deviceID            string
+	dataDir             string
+	location            *time.Location
+	now                 func() time.Time
+
+	// roles maps each source, as namespace/metric, to what it is read for
+	roles map[string]string
+
+	mu     sync.Mutex
+	ledger *ledger
+	saved  state.Throttle
+}
+
+// NewPublisher creates a publisher that summarises sources from what passes
+// through it to next, and closes each day at local midnight.
+//
+// The days are saved under dataDir; empty keeps them in memory only.
+func NewPublisher(next publish.MessagePublisher, sources Sources, prometheusCollector MetricsCollector, deviceID, dataDir string) (*Publisher, error) {
+	p := newPublisherForTest(next, sources, prometheusCollector, deviceID)
+	p.dataDir = dataDir
+	p.ledger = p.load()
+	p.prometheusCollector.SetDays(len(p.ledger.Days))
+
+	s := gocron.NewScheduler(p.location)
+	if _, err := s.Every(1).Day().At("00:00").Do(p.endOfDay); err != nil {
+		return nil, fmt.Errorf("failed to schedule the end of day %w", err)
+	}
+	s.StartAsync()
+	p.scheduler = s
+
+	return p, nil
+}
+
+// newPublisherForTest creates a Publisher without the midnight job or a data
+// directory, so tests close days by the readings they publish.
+func newPublisherForTest(next publish.MessagePublisher, sources Sources, prometheusCollector MetricsCollector, deviceID string) *Publisher {
+	// Default device ID if not provided
+	if deviceID == "" {
+		deviceID = "controller-1"
+	}
+
+	sources = sources.withDefaults()
+	return &Publisher{
+		next:                next,
+		prometheusCollector: prometheusCollector,
+		deviceID:            deviceID,
+		location:            time.Local,
+		now:                 time.Now,
+		roles: map[string]string{
+			sources.PVPower:        rolePV,
+			sources.LoadPower:      roleLoad,
+			sources.BatteryVoltage: roleBatteryVoltage,
+			sources.SOC:            roleSOC,
+			sources.ChargingStatus: roleChargingStatus,
+		},
+		ledger: &ledger{},
+	}
+}
+
+// NewPublisherFromConfig wraps next with the configured reports. This is the
+// production entry point; when reports are disabled it returns next unchanged
+// and no reports.
+func NewPublisherFromConfig(config Configuration, next publish.MessagePublisher, deviceID, dataDir string) (publish.MessagePublisher, *Publisher, error) {
+	if !config.Enabled {
+		return next, nil, nil
+	}
+
+	if dataDir == "" {
+		log.Warn("no dataDir configured, reports will start over on every restart")
+	}
+
+	sources := config.Sources.withDefaults()
+	log.Infof("reporting solar from %s, load from %s, battery voltage from %s, state of charge from %s and float from %s",
+		sources.PVPower, sources.LoadPower, sources.BatteryVoltage, sources.SOC, sources.ChargingStatus)
+
+	p, err := NewPublisher(next, sources, NewPrometheusCollector(), deviceID, dataDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, p, nil
+}
+
+// Publish passes the message on, then counts it if it is a reading of a
+// source.
+func (p *Publisher) Publish(topicSuffix, payload string) {
+	p.next.Publish(topicSuffix, payload)
+
+	name, ok := strings.CutPrefix(topicSuffix, p.deviceID+"/")
+	if !ok {
+		return
+	}
+	role, ok := p.roles[name]
+	if !ok {
+		return
+	}
+
+	var metric struct {
+		Value     float64 `json:"value"`
+		Timestamp int64   `json:"timestamp"`
+	}
+	if err := json.Unmarshal([]byte(payload), &metric); err != nil || metric.Timestamp == 0 {
+		return
+	}
+
+	p.mu.Lock()
+	closed := p.ledger.add(role, metric.Timestamp, metric.Value, p.location)
+	p.mu.Unlock()
+
+	p.finish(closed)
+}
+
+// endOfDay closes the day in progress at midnight, so its summary does not
+// wait for the first reading of the next day.
+func (p *Publisher) endOfDay() {
+	p.mu.Lock()
+	closed := p.ledger.rollOver(p.now().In(p.location).Format(dayFormat))
+	p.mu.Unlock()
+
+	p.finish(closed)
+}
+
+// finish publishes a day that has just closed and saves the days: at once
+// when a day closed, otherwise at most every state.SaveInterval.
+func (p *Publisher) finish(closed *Summary) {
+	if closed == nil {
+		p.save(false)
+		return
+	}
+
+	log.Infof("closed the report of %s", closed.Period)
+	p.publishSummary(closed)
+	p.save(true)
+
+	p.mu.Lock()
+	days := len(p.ledger.Days)
+	p.mu.Unlock()
+	p.prometheusCollector.SetDays(days)
+}
+
+// publishSummary publishes each value of a closed day, timestamped at the
+// midnight that ended it.
+func (p *Publisher) publishSummary(day *Summary) {
+	start, err := time.ParseInLocation(dayFormat, day.Period, p.location)
+	if err != nil {
+		log.Errorf("failed to publish the report of %s: %s", day.Period, err)
+		return
+	}
+
+	for _, metric := range ConvertSummaryToMetrics(day, start.AddDate(0, 0, 1).Unix()) {
+		payload, err := metric.ToJSON()
+		if err != nil {
+			log.Errorf("failed to marshal report metric %s for publishing: %s", metric.Name, err)
+			continue
+		}
+
+		// Topic format: {deviceId}/reports/{metric-name}
+		topicSuffix := fmt.Sprintf("%s/%s/%s", p.deviceID, namespace, metric.Name)
+		p.next.Publish(topicSuffix, payload)
+
+		log.Debugf("published report metric %s to %s", metric.Name, topicSuffix)
+	}
+}
+
+// load reads the saved days from dataDir.
+func (p *Publisher) load() *ledger {
+	l := &ledger{}
+	if !state.Restore(p.dataDir, fileName, "reports", l) {
+		return &ledger{}
+	}
+	return l
+}
+
+// save writes the days, at most every state.SaveInterval unless forced.
+func (p *Publisher) save(force bool) {
+	if p.dataDir == "" || !p.saved.Due(p.now(), force) {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := state.Save(filepath.Join(p.dataDir, fileName), p.ledger); err != nil {
+		p.prometheusCollector.IncrementSaveFailures()
+		log.Errorf("failed to save reports: %s", err)
+	}
+}
+
+// Summaries returns the report from from to to, as days (2006-01-02), either
+// empty for no bound.
+func (p *Publisher) Summaries(report, from, to string) []Summary {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.ledger.summaries(report, from, to, p.now().In(p.location).Format(dayFormat))
+}
+
+// Close stops the midnight job, saves the days and closes the wrapped
+// publisher.
+func (p *Publisher) Close() {
+	if p.scheduler != nil {
+		p.scheduler.Stop()
+	}
+	p.save(true)
+	p.next.Close()
+}
@@ -0,0 +1,207 @@
+package reports
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/publish"
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+)
+
+// reportPayloads returns what was published under the reports namespace.
+func reportPayloads(t *testing.T, next *testutil.MockMessagePublisher) map[string]MetricPayload {
+	t.Helper()
+
+	payloads := make(map[string]MetricPayload)
+	for _, call := range next.PublishCalls {
+		name, ok := strings.CutPrefix(call.TopicSuffix, "test-device-1/reports/")
+		if !ok {
+			continue
+		}
+		var payload MetricPayload
+		if err := json.Unmarshal([]byte(call.Payload), &payload); err != nil {
+			t.Fatalf("payload of %s: %v", call.TopicSuffix, err)
+		}
+		payloads[name] = payload
+	}
+	return payloads
+}
+
+func TestPublisher_PublishesTheEndOfDay(t *testing.T) {
+	publisher, next, metrics := newTestPublisher(t)
+
+	publishMetric(publisher, "epever/charging-power", 600, day(2024, time.June, 1, 12, 0))
+	publishMetric(publisher, "epever/charging-power", 600, day(2024, time.June, 1, 12, 10))
+	publishMetric(publisher, "voltgo/battery-soc", 70, day(2024, time.June, 1, 12, 10))
+	// Another device, a metric that is not a source, and a list value
+	publisher.Publish("other-device/epever/charging-power", `{"value":5000,"unit":"watts","timestamp":1717243800}`)
+	publishMetric(publisher, "epever/array-power", 5000, day(2024, time.June, 1, 12, 10))
+	publisher.Publish("test-device-1/epever/battery-voltage", `{"value":[13.2],"unit":"volts","timestamp":1717243800}`)
+
+	if len(next.PublishCalls) != 6 {
+		t.Errorf("publish calls = %d, want every message passed on", len(next.PublishCalls))
+	}
+	if payloads := reportPayloads(t, next); len(payloads) != 0 {
+		t.Fatalf("published %v before the day ended", payloads)
+	}
+
+	publishMetric(publisher, "voltgo/battery-soc", 65, day(2024, time.June, 2, 0, 1))
+
+	payloads := reportPayloads(t, next)
+	midnight := day(2024, time.June, 2, 0, 0).Unix()
+	want := map[string]MetricPayload{
+		"pv-energy-daily":     {Value: 0.1, Unit: "kilowatt-hours", Timestamp: midnight},
+		"peak-pv-power-daily": {Value: float64(600), Unit: "watts", Timestamp: midnight},
+		"soc-min-daily":       {Value: float64(70), Unit: "percent", Timestamp: midnight},
+	}
+	if len(payloads) != len(want) {
+		t.Errorf("published %v, want %v", payloads, want)
+	}
+	for name, w := range want {
+		if got := payloads[name]; got != w {
+			t.Errorf("%s = %+v, want %+v", name, got, w)
+		}
+	}
+	if metrics.Days != 1 {
+		t.Errorf("days = %d, want 1", metrics.Days)
+	}
+}
+
+func TestPublisher_EndOfDay(t *testing.T) {
+	publisher, next, _ := newTestPublisher(t)
+	publisher.now = func() time.Time { return day(2024, time.June, 2, 0, 0) }
+
+	publishMetric(publisher, "epever/battery-voltage", 13.1, day(2024, time.June, 1, 12, 0))
+	publisher.endOfDay()
+
+	payloads := reportPayloads(t, next)
+	if payloads["battery-voltage-min-daily"].Value != 13.1 || payloads["battery-voltage-max-daily"].Value != 13.1 {
+		t.Errorf("published %v, want the voltage range of June 1 at midnight", payloads)
+	}
+	if summaries := publisher.Summaries(ReportDaily, "", ""); len(summaries) != 1 || !summaries[0].Complete {
+		t.Errorf("summaries = %+v, want June 1, complete", summaries)
+	}
+
+	// Nothing more to close until the next midnight
+	calls := len(next.PublishCalls)
+	publisher.endOfDay()
+	if len(next.PublishCalls) != calls {
+		t.Error("an empty day was published")
+	}
+}
+
+func TestPublisher_SummariesJustAfterMidnight(t *testing.T) {
+	publisher, _, _ := newTestPublisher(t)
+	publisher.now = func() time.Time { return day(2024, time.June, 30, 0, 5) }
+
+	publishMetric(publisher, "epever/battery-voltage", 13.1, day(2024, time.June, 29, 12, 0))
+	publisher.endOfDay()
+
+	// The new day has no readings yet, but its month and year are not over
+	if summaries := publisher.Summaries(ReportMonthly, "", ""); len(summaries) != 1 || summaries[0].Complete {
+		t.Errorf("monthly = %+v, want June, not complete", summaries)
+	}
+	if summaries := publisher.Summaries(ReportYearly, "", ""); len(summaries) != 1 || summaries[0].Complete {
+		t.Errorf("yearly = %+v, want 2024, not complete", summaries)
+	}
+
+	publisher.now = func() time.Time { return day(2024, time.July, 1, 0, 5) }
+	publishMetric(publisher, "epever/battery-voltage", 13.2, day(2024, time.June, 30, 12, 0))
+	publisher.endOfDay()
+
+	if summaries := publisher.Summaries(ReportMonthly, "", ""); len(summaries) != 1 || !summaries[0].Complete {
+		t.Errorf("monthly = %+v, want June, complete once July has begun", summaries)
+	}
+	if summaries := publisher.Summaries(ReportYearly, "", ""); len(summaries) != 1 || summaries[0].Complete {
+		t.Errorf("yearly = %+v, want 2024, still not complete", summaries)
+	}
+}
+
+func TestPublisher_Persistence(t *testing.T) {
+	dataDir := t.TempDir()
+
+	next := &testutil.MockMessagePublisher{}
+	publisher, err := NewPublisher(next, Sources{}, &MockMetricsCollector{}, "test-device-1", dataDir)
+	if err != nil {
+		t.Fatalf("NewPublisher() error = %v", err)
+	}
+	publisher.location = time.UTC
+	publishMetric(publisher, "epever/charging-power", 100, day(2024, time.June, 1, 12, 0))
+	publishMetric(publisher, "epever/charging-power", 100, day(2024, time.June, 2, 12, 0))
+	publisher.Close()
+	if next.CloseCalls != 1 {
+		t.Errorf("CloseCalls = %d, want 1", next.CloseCalls)
+	}
+
+	metrics := &MockMetricsCollector{}
+	reopened, err := NewPublisher(&testutil.MockMessagePublisher{}, Sources{}, metrics, "test-device-1", dataDir)
+	if err != nil {
+		t.Fatalf("NewPublisher() error = %v", err)
+	}
+	defer reopened.Close()
+
+	summaries := reopened.Summaries(ReportDaily, "", "")
+	if len(summaries) != 2 || summaries[0].Period != "2024-06-01" || summaries[1].Period != "2024-06-02" {
+		t.Errorf("summaries = %+v, want June 1 and the day in progress", summaries)
+	}
+	if metrics.Days != 1 {
+		t.Errorf("days = %d, want the 1 closed day", metrics.Days)
+	}
+}
+
+func TestPublisher_UnreadableFileIsSetAside(t *testing.T) {
+	dataDir := t.TempDir()
+	path := filepath.Join(dataDir, fileName)
+	if err := os.WriteFile(path, []byte("not json"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	publisher, err := NewPublisher(&testutil.MockMessagePublisher{}, Sources{}, &MockMetricsCollector{}, "", dataDir)
+	if err != nil {
+		t.Fatalf("NewPublisher() error = %v", err)
+	}
+	defer publisher.Close()
+
+	if summaries := publisher.Summaries(ReportDaily, "", ""); len(summaries) != 0 {
+		t.Errorf("summaries = %+v, want none", summaries)
+	}
+	matches, _ := filepath.Glob(path + ".*")
+	if len(matches) != 1 {
+		t.Errorf("unreadable file not set aside: %v", matches)
+	}
+}
+
+func TestPublisher_SaveFailures(t *testing.T) {
+	publisher, _, metrics := newTestPublisher(t)
+	// A file where the directory should be
+	publisher.dataDir = filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(publisher.dataDir, nil, 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	publisher.save(true)
+	if metrics.SaveFailures != 1 {
+		t.Errorf("SaveFailures = %d, want 1", metrics.SaveFailures)
+	}
+
+	// Not retried before the interval
+	publisher.save(false)
+	if metrics.SaveFailures != 1 {
+		t.Errorf("SaveFailures = %d, want 1 within the save interval", metrics.SaveFailures)
+	}
+}
+
+func TestNewPublisherFromConfig_Disabled(t *testing.T) {
+	next := &testutil.MockMessagePublisher{}
+	publisher, reporter, err := NewPublisherFromConfig(Configuration{}, next, "test-device-1", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewPublisherFromConfig() error = %v", err)
+	}
+	if publisher != publish.MessagePublisher(next) || reporter != nil {
+		t.Error("publisher wrapped with reports disabled")
+	}
+}
@@ -0,0 +1,231 @@
+// Package reports summarises each local day from the metrics the controllers
+// publish: the solar energy produced and the energy used, the peak powers,
+// the battery's voltage range and lowest state of charge, and the time spent
+// in float. The days roll up into months and years, served as JSON or CSV.
+package reports
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+)
+
+const (
+	namespace = "reports"
+
+	// The report periods
+	ReportDaily   = "daily"
+	ReportMonthly = "monthly"
+	ReportYearly  = "yearly"
+
+	dayFormat = "2006-01-02"
+
+	// floatStatus is the charging-status code of float charging, as the
+	// Epever reports it
+	floatStatus = 1
+
+	// maxGap is the longest interval between two readings of a power that
+	// is counted as energy, or between two of the charging status that is
+	// counted as time in float
+	maxGap = 15 * time.Minute
+)
+
+var sourcePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*/[a-z0-9][a-z0-9-]*$`)
+
+type Configuration struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Sources names the published metrics the summaries are read from
+	Sources Sources `yaml:"sources"`
+}
+
+// Sources are published metrics, each written as namespace/metric.
+type Sources struct {
+	// PVPower is the solar power (default: epever/charging-power)
+	PVPower string `yaml:"pvPower"`
+
+	// LoadPower is the power the house draws (default: system/load-power)
+	LoadPower string `yaml:"loadPower"`
+
+	// BatteryVoltage defaults to epever/battery-voltage
+	BatteryVoltage string `yaml:"batteryVoltage"`
+
+	// SOC is the battery's state of charge (default: voltgo/battery-soc)
+	SOC string `yaml:"soc"`
+
+	// ChargingStatus is the Epever charging status code, whose float
+	// readings count as time in float (default: epever/charging-status)
+	ChargingStatus string `yaml:"chargingStatus"`
+}
+
+// withDefaults fills in the empty sources.
+func (s Sources) withDefaults() Sources {
+	if s.PVPower == "" {
+		s.PVPower = "epever/charging-power"
+	}
+	if s.LoadPower == "" {
+		s.LoadPower = "system/load-power"
+	}
+	if s.BatteryVoltage == "" {
+		s.BatteryVoltage = "epever/battery-voltage"
+	}
+	if s.SOC == "" {
+		s.SOC = "voltgo/battery-soc"
+	}
+	if s.ChargingStatus == "" {
+		s.ChargingStatus = "epever/charging-status"
+	}
+	return s
+}
+
+// Validate checks the configuration for errors. A disabled configuration is
+// not checked.
+func (c *Configuration) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	for _, source := range []struct{ name, value string }{
+		{"pvPower", c.Sources.PVPower},
+		{"loadPower", c.Sources.LoadPower},
+		{"batteryVoltage", c.Sources.BatteryVoltage},
+		{"soc", c.Sources.SOC},
+		{"chargingStatus", c.Sources.ChargingStatus},
+	} {
+		if source.value != "" && !sourcePattern.MatchString(source.value) {
+			return fmt.Errorf("source %s %q must be namespace/metric, such as epever/charging-power", source.name, source.value)
+		}
+	}
+
+	// Each metric is read for one thing
+	sources := c.Sources.withDefaults()
+	seen := make(map[string]bool)
+	for _, source := range []string{sources.PVPower, sources.LoadPower, sources.BatteryVoltage, sources.SOC, sources.ChargingStatus} {
+		if seen[source] {
+			return fmt.Errorf("source %s is used twice", source)
+		}
+		seen[source] = true
+	}
+	return nil
+}
+
+// Summary is one day, month or year. A value is null when its source
+// published nothing in the period.
+type Summary struct {
+	// Period is the local day (2006-01-02), month (2006-01) or year (2006)
+	Period string `json:"period"`
+
+	// Days is how many days with readings the period holds
+	Days int `json:"days"`
+
+	// Complete is false for a period that includes today
+	Complete bool `json:"complete"`
+
+	PVKWh       *float64 `json:"pvKwh"`
+	ConsumedKWh *float64 `json:"consumedKwh"`
+
+	// PeakPVPower and PeakLoadPower are in watts
+	PeakPVPower   *float64 `json:"peakPvPower"`
+	PeakLoadPower *float64 `json:"peakLoadPower"`
+
+	MinBatteryVoltage *float64 `json:"minBatteryVoltage"`
+	MaxBatteryVoltage *float64 `json:"maxBatteryVoltage"`
+	MinSOC            *float64 `json:"minSoc"`
+	FloatHours        *float64 `json:"floatHours"`
+}
+
+// empty reports whether no source published anything in the period.
+func (s *Summary) empty() bool {
+	for _, value := range s.values() {
+		if *value != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// values points at every value of the summary, in the order of its fields.
+func (s *Summary) values() []**float64 {
+	return []**float64{
+		&s.PVKWh, &s.ConsumedKWh, &s.PeakPVPower, &s.PeakLoadPower,
+		&s.MinBatteryVoltage, &s.MaxBatteryVoltage, &s.MinSOC, &s.FloatHours,
+	}
+}
+
+// rounded returns the summary with its values to three decimal places.
+func (s Summary) rounded() Summary {
+	for _, value := range s.values() {
+		if *value != nil {
+			r := math.Round(**value*1000) / 1000
+			*value = &r
+		}
+	}
+	return s
+}
+
+// add folds another period's summary into this one.
+func (s *Summary) add(other Summary) {
+	s.Days += other.Days
+	s.PVKWh = sum(s.PVKWh, other.PVKWh)
+	s.ConsumedKWh = sum(s.ConsumedKWh, other.ConsumedKWh)
+	s.PeakPVPower = highest(s.PeakPVPower, other.PeakPVPower)
+	s.PeakLoadPower = highest(s.PeakLoadPower, other.PeakLoadPower)
+	s.MinBatteryVoltage = lowest(s.MinBatteryVoltage, other.MinBatteryVoltage)
+	s.MaxBatteryVoltage = highest(s.MaxBatteryVoltage, other.MaxBatteryVoltage)
+	s.MinSOC = lowest(s.MinSOC, other.MinSOC)
+	s.FloatHours = sum(s.FloatHours, other.FloatHours)
+}
+
+// rollUp combines days, oldest first, into one summary per month or year.
+// A period is complete when it ended before today (2006-01-02) and all its
+// days are: until the first reading of a new day, the month and year it
+// belongs to have no day in progress to say so.
+func rollUp(days []Summary, report, today string) []Summary {
+	length := len("2006-01")
+	if report == ReportYearly {
+		length = len("2006")
+	}
+
+	var periods []Summary
+	for _, day := range days {
+		period := day.Period[:length]
+		n := len(periods)
+		if n == 0 || periods[n-1].Period != period {
+			periods = append(periods, Summary{Period: period, Complete: period < today[:length]})
+			n++
+		}
+		periods[n-1].add(day)
+		periods[n-1].Complete = periods[n-1].Complete && day.Complete
+	}
+	// Sums of rounded days can pick up float error
+	for i := range periods {
+		periods[i] = periods[i].rounded()
+	}
+	return periods
+}
+
+func sum(a, b *float64) *float64 {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	v := *a + *b
+	return &v
+}
+
+func lowest(a, b *float64) *float64 {
+	if a == nil || (b != nil && *b < *a) {
+		return b
+	}
+	return a
+}
+
+func highest(a, b *float64) *float64 {
+	if a == nil || (b != nil && *b > *a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,67 @@
+package reports
+
+import (
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Configuration
+		wantErr bool
+	}{
+		{"disabled", Configuration{Sources: Sources{PVPower: "not a metric"}}, false},
+		{"defaults", Configuration{Enabled: true}, false},
+		{"other sources", Configuration{Enabled: true, Sources: Sources{LoadPower: "ina2xx/power", SOC: "voltgo/bank-soc"}}, false},
+		{"a source without a namespace", Configuration{Enabled: true, Sources: Sources{PVPower: "charging-power"}}, true},
+		{"a source with a device ID", Configuration{Enabled: true, Sources: Sources{SOC: "controller-1/voltgo/battery-soc"}}, true},
+		{"a source used twice", Configuration{Enabled: true, Sources: Sources{LoadPower: "epever/charging-power"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRollUp(t *testing.T) {
+	days := []Summary{
+		{Period: "2024-05-31", Days: 1, Complete: true, PVKWh: float(1.1), MinSOC: float(40), FloatHours: float(2)},
+		{Period: "2024-06-01", Days: 1, Complete: true, PVKWh: float(2.2), MinSOC: float(55), PeakPVPower: float(800)},
+		{Period: "2024-06-02", Days: 1, Complete: false, PVKWh: float(3.3), MinSOC: float(50), PeakPVPower: float(600)},
+	}
+
+	monthly := rollUp(days, ReportMonthly, "2024-06-02")
+	if len(monthly) != 2 {
+		t.Fatalf("rollUp() = %+v, want 2 months", monthly)
+	}
+	if may := monthly[0]; may.Period != "2024-05" || may.Days != 1 || !may.Complete || *may.FloatHours != 2 || may.PeakPVPower != nil {
+		t.Errorf("May = %+v, want the one complete day", may)
+	}
+	june := monthly[1]
+	if june.Period != "2024-06" || june.Days != 2 || june.Complete {
+		t.Errorf("June = %+v, want 2 days, not complete", june)
+	}
+	if *june.PVKWh != 5.5 || *june.MinSOC != 50 || *june.PeakPVPower != 800 || june.FloatHours != nil || june.ConsumedKWh != nil {
+		t.Errorf("June = %+v, want 5.5 kWh, a low of 50%% and a peak of 800 W", june)
+	}
+
+	yearly := rollUp(days, ReportYearly, "2024-06-02")
+	if len(yearly) != 1 || yearly[0].Period != "2024" || yearly[0].Days != 3 || *yearly[0].PVKWh != 6.6 {
+		t.Errorf("rollUp() yearly = %+v, want one year of 3 days and 6.6 kWh", yearly)
+	}
+}
+
+func TestSummary_Rounded(t *testing.T) {
+	summary := Summary{PVKWh: float(1.23456), MinSOC: float(50)}
+	rounded := summary.rounded()
+	if *rounded.PVKWh != 1.235 || *rounded.MinSOC != 50 || rounded.ConsumedKWh != nil {
+		t.Errorf("rounded() = %+v, want 1.235", rounded)
+	}
+	if *summary.PVKWh != 1.23456 {
+		t.Error("rounded() changed the summary it was called on")
+	}
+}
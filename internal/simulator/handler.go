@@ -0,0 +1,159 @@
+package simulator
+
+import (
+	"encoding/binary"
+)
+
+// Function codes the simulator answers. They cover everything the epever
+// controller sends.
+const (
+	funcReadCoils              = 0x01
+	funcReadHoldingRegisters   = 0x03
+	funcReadInputRegisters     = 0x04
+	funcWriteSingleCoil        = 0x05
+	funcWriteSingleRegister    = 0x06
+	funcWriteMultipleRegisters = 0x10
+)
+
+// Exception codes from the Modbus application protocol specification.
+const (
+	exceptionIllegalFunction    = 0x01
+	exceptionIllegalDataAddress = 0x02
+	exceptionIllegalDataValue   = 0x03
+)
+
+// Quantity limits from the Modbus application protocol specification; a
+// request over them does not fit in a 256-byte RTU frame.
+const (
+	maxReadRegisters  = 125
+	maxWriteRegisters = 123
+	maxReadCoils      = 2000
+)
+
+// handle executes one request PDU against the register map and returns the
+// response PDU's function code and data. Exceptions are returned with the
+// high bit of the function code set.
+func (m *RegisterMap) handle(function byte, data []byte) (byte, []byte) {
+	switch function {
+	case funcReadCoils:
+		return m.handleReadCoils(function, data)
+	case funcReadHoldingRegisters:
+		return m.handleReadRegisters(function, HoldingRegisters, data)
+	case funcReadInputRegisters:
+		return m.handleReadRegisters(function, InputRegisters, data)
+	case funcWriteSingleCoil:
+		return m.handleWriteSingleCoil(function, data)
+	case funcWriteSingleRegister:
+		return m.handleWriteSingleRegister(function, data)
+	case funcWriteMultipleRegisters:
+		return m.handleWriteMultipleRegisters(function, data)
+	default:
+		return exception(function, exceptionIllegalFunction)
+	}
+}
+
+func (m *RegisterMap) handleReadRegisters(function byte, table Table, data []byte) (byte, []byte) {
+	if len(data) != 4 {
+		return exception(function, exceptionIllegalDataValue)
+	}
+	address := binary.BigEndian.Uint16(data[0:2])
+	quantity := binary.BigEndian.Uint16(data[2:4])
+	if quantity == 0 || quantity > maxReadRegisters {
+		return exception(function, exceptionIllegalDataValue)
+	}
+	if !inRange(address, quantity) {
+		return exception(function, exceptionIllegalDataAddress)
+	}
+
+	values := m.ReadRegisters(table, address, quantity)
+	response := make([]byte, 1+2*len(values))
+	response[0] = byte(2 * len(values))
+	for i, value := range values {
+		binary.BigEndian.PutUint16(response[1+2*i:], value)
+	}
+	return function, response
+}
+
+func (m *RegisterMap) handleReadCoils(function byte, data []byte) (byte, []byte) {
+	if len(data) != 4 {
+		return exception(function, exceptionIllegalDataValue)
+	}
+	address := binary.BigEndian.Uint16(data[0:2])
+	quantity := binary.BigEndian.Uint16(data[2:4])
+	if quantity == 0 || quantity > maxReadCoils {
+		return exception(function, exceptionIllegalDataValue)
+	}
+	if !inRange(address, quantity) {
+		return exception(function, exceptionIllegalDataAddress)
+	}
+
+	values := m.ReadCoils(address, quantity)
+	byteCount := (len(values) + 7) / 8
+	response := make([]byte, 1+byteCount)
+	response[0] = byte(byteCount)
+	for i, on := range values {
+		if on {
+			response[1+i/8] |= 1 << (i % 8)
+		}
+	}
+	return function, response
+}
+
+func (m *RegisterMap) handleWriteSingleCoil(function byte, data []byte) (byte, []byte) {
+	if len(data) != 4 {
+		return exception(function, exceptionIllegalDataValue)
+	}
+	address := binary.BigEndian.Uint16(data[0:2])
+	switch binary.BigEndian.Uint16(data[2:4]) {
+	case 0xFF00:
+		m.WriteCoil(address, true)
+	case 0x0000:
+		m.WriteCoil(address, false)
+	default:
+		return exception(function, exceptionIllegalDataValue)
+	}
+	// The response echoes the request
+	return function, data
+}
+
+func (m *RegisterMap) handleWriteSingleRegister(function byte, data []byte) (byte, []byte) {
+	if len(data) != 4 {
+		return exception(function, exceptionIllegalDataValue)
+	}
+	address := binary.BigEndian.Uint16(data[0:2])
+	m.WriteRegisters(address, []uint16{binary.BigEndian.Uint16(data[2:4])})
+	// The response echoes the request
+	return function, data
+}
+
+func (m *RegisterMap) handleWriteMultipleRegisters(function byte, data []byte) (byte, []byte) {
+	if len(data) < 5 {
+		return exception(function, exceptionIllegalDataValue)
+	}
+	address := binary.BigEndian.Uint16(data[0:2])
+	quantity := binary.BigEndian.Uint16(data[2:4])
+	byteCount := int(data[4])
+	if quantity == 0 || quantity > maxWriteRegisters || byteCount != 2*int(quantity) || len(data) != 5+byteCount {
+		return exception(function, exceptionIllegalDataValue)
+	}
+	if !inRange(address, quantity) {
+		return exception(function, exceptionIllegalDataAddress)
+	}
+
+	values := make([]uint16, quantity)
+	for i := range values {
+		values[i] = binary.BigEndian.Uint16(data[5+2*i:])
+	}
+	m.WriteRegisters(address, values)
+
+	// The response is the starting address and quantity
+	return function, data[0:4]
+}
+
+func inRange(address, quantity uint16) bool {
+	return int(address)+int(quantity) <= 0x10000
+}
+
+func exception(function, code byte) (byte, []byte) {
+	return function | 0x80, []byte{code}
+}
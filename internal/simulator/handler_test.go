@@ -0,0 +1,158 @@
+package simulator
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name         string
+		function     byte
+		data         []byte
+		wantFunction byte
+		wantData     []byte
+	}{
+		{
+			name:         "read input registers",
+			function:     funcReadInputRegisters,
+			data:         []byte{0x31, 0x00, 0x00, 0x02},
+			wantFunction: funcReadInputRegisters,
+			wantData:     []byte{0x04, 0x0B, 0xB8, 0x01, 0xF4},
+		},
+		{
+			name:         "read holding registers",
+			function:     funcReadHoldingRegisters,
+			data:         []byte{0x90, 0x01, 0x00, 0x01},
+			wantFunction: funcReadHoldingRegisters,
+			wantData:     []byte{0x02, 0x00, 0x64},
+		},
+		{
+			name:         "read coils packs bits low first",
+			function:     funcReadCoils,
+			data:         []byte{0x00, 0x00, 0x00, 0x09},
+			wantFunction: funcReadCoils,
+			wantData:     []byte{0x02, 0x04, 0x00},
+		},
+		{
+			name:         "write single coil echoes the request",
+			function:     funcWriteSingleCoil,
+			data:         []byte{0x00, 0x02, 0x00, 0x00},
+			wantFunction: funcWriteSingleCoil,
+			wantData:     []byte{0x00, 0x02, 0x00, 0x00},
+		},
+		{
+			name:         "write single register echoes the request",
+			function:     funcWriteSingleRegister,
+			data:         []byte{0x90, 0x01, 0x00, 0xC8},
+			wantFunction: funcWriteSingleRegister,
+			wantData:     []byte{0x90, 0x01, 0x00, 0xC8},
+		},
+		{
+			name:         "write multiple registers returns address and quantity",
+			function:     funcWriteMultipleRegisters,
+			data:         []byte{0x90, 0x00, 0x00, 0x02, 0x04, 0x00, 0x02, 0x00, 0xC8},
+			wantFunction: funcWriteMultipleRegisters,
+			wantData:     []byte{0x90, 0x00, 0x00, 0x02},
+		},
+		{
+			name:         "unsupported function",
+			function:     0x2B,
+			data:         []byte{0x0E},
+			wantFunction: 0x2B | 0x80,
+			wantData:     []byte{exceptionIllegalFunction},
+		},
+		{
+			name:         "read of zero registers",
+			function:     funcReadInputRegisters,
+			data:         []byte{0x31, 0x00, 0x00, 0x00},
+			wantFunction: funcReadInputRegisters | 0x80,
+			wantData:     []byte{exceptionIllegalDataValue},
+		},
+		{
+			name:         "read over the quantity limit",
+			function:     funcReadHoldingRegisters,
+			data:         []byte{0x00, 0x00, 0x00, 0x7E},
+			wantFunction: funcReadHoldingRegisters | 0x80,
+			wantData:     []byte{exceptionIllegalDataValue},
+		},
+		{
+			name:         "read past the end of the address space",
+			function:     funcReadInputRegisters,
+			data:         []byte{0xFF, 0xFF, 0x00, 0x02},
+			wantFunction: funcReadInputRegisters | 0x80,
+			wantData:     []byte{exceptionIllegalDataAddress},
+		},
+		{
+			name:         "coil read past the end of the address space",
+			function:     funcReadCoils,
+			data:         []byte{0xFF, 0xFF, 0x00, 0x02},
+			wantFunction: funcReadCoils | 0x80,
+			wantData:     []byte{exceptionIllegalDataAddress},
+		},
+		{
+			name:         "truncated read",
+			function:     funcReadCoils,
+			data:         []byte{0x00, 0x00},
+			wantFunction: funcReadCoils | 0x80,
+			wantData:     []byte{exceptionIllegalDataValue},
+		},
+		{
+			name:         "coil write with an invalid value",
+			function:     funcWriteSingleCoil,
+			data:         []byte{0x00, 0x02, 0x12, 0x34},
+			wantFunction: funcWriteSingleCoil | 0x80,
+			wantData:     []byte{exceptionIllegalDataValue},
+		},
+		{
+			name:         "truncated single register write",
+			function:     funcWriteSingleRegister,
+			data:         []byte{0x90},
+			wantFunction: funcWriteSingleRegister | 0x80,
+			wantData:     []byte{exceptionIllegalDataValue},
+		},
+		{
+			name:         "multiple register write with a mismatched byte count",
+			function:     funcWriteMultipleRegisters,
+			data:         []byte{0x90, 0x00, 0x00, 0x02, 0x02, 0x00, 0x02},
+			wantFunction: funcWriteMultipleRegisters | 0x80,
+			wantData:     []byte{exceptionIllegalDataValue},
+		},
+		{
+			name:         "multiple register write past the end of the address space",
+			function:     funcWriteMultipleRegisters,
+			data:         []byte{0xFF, 0xFF, 0x00, 0x02, 0x04, 0x00, 0x01, 0x00, 0x02},
+			wantFunction: funcWriteMultipleRegisters | 0x80,
+			wantData:     []byte{exceptionIllegalDataAddress},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			regs := newTestRegisterMap(t)
+
+			function, data := regs.handle(tt.function, tt.data)
+
+			if function != tt.wantFunction {
+				t.Errorf("function = 0x%02X, want 0x%02X", function, tt.wantFunction)
+			}
+			if !bytes.Equal(data, tt.wantData) {
+				t.Errorf("data = % X, want % X", data, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestHandle_WritesReachTheRegisterMap(t *testing.T) {
+	regs := newTestRegisterMap(t)
+
+	regs.handle(funcWriteSingleRegister, []byte{0x90, 0x01, 0x00, 0xC8})
+	if got, _ := regs.Get("BAT_CAPACITY"); got != 200 {
+		t.Errorf("BAT_CAPACITY = %d, want 200", got)
+	}
+
+	regs.handle(funcWriteSingleCoil, []byte{0x00, 0x02, 0x00, 0x00})
+	if got := regs.ReadCoils(0x0002, 1); got[0] {
+		t.Error("LOAD_ON should be off after the write")
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package simulator
+
+import "os"
+
+// pollable is a no-op off Unix, where pty.Open fails before it is reached.
+func pollable(f *os.File) (*os.File, error) {
+	return f, nil
+}
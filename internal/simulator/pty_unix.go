@@ -0,0 +1,28 @@
+//go:build unix
+
+package simulator
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// pollable returns a copy of f that supports read deadlines. pty.Open calls
+// Fd on the master, which switches it to blocking mode and so silently
+// disables deadlines; the frame-gap timer in readFrame depends on them. f is
+// closed.
+func pollable(f *os.File) (*os.File, error) {
+	defer f.Close()
+
+	fd, err := syscall.Dup(int(f.Fd())) //nolint:gosec // file descriptors fit in an int
+	if err != nil {
+		return nil, fmt.Errorf("failed to duplicate %s: %w", f.Name(), err)
+	}
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		_ = syscall.Close(fd)
+		return nil, fmt.Errorf("failed to make %s non-blocking: %w", f.Name(), err)
+	}
+	// os.NewFile registers a non-blocking descriptor with the poller
+	return os.NewFile(uintptr(fd), f.Name()), nil //nolint:gosec // fd is a valid descriptor
+}
@@ -0,0 +1,216 @@
+// Package simulator serves a Modbus register map over TCP and over a
+// pty-backed RTU serial port, so the epever controller can be run and tested
+// without a charge controller.
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Table identifies one of the Modbus data tables the simulator serves.
+type Table int
+
+const (
+	InputRegisters Table = iota
+	HoldingRegisters
+	Coils
+)
+
+func (t Table) String() string {
+	switch t {
+	case InputRegisters:
+		return "input"
+	case HoldingRegisters:
+		return "holding"
+	case Coils:
+		return "coil"
+	default:
+		return "unknown"
+	}
+}
+
+// NamedRegister is a register's name and initial value in a register file.
+type NamedRegister struct {
+	Name  string `json:"name"`
+	Value uint16 `json:"value"`
+}
+
+// NamedCoil is a coil's name and initial state in a register file.
+type NamedCoil struct {
+	Name  string `json:"name"`
+	Value bool   `json:"value"`
+}
+
+// RegisterFile is the JSON register map format, keyed by decimal address.
+// It is the format of testdata/simulator/epever.json.
+type RegisterFile struct {
+	NamedInputRegs   map[uint16]NamedRegister `json:"NamedInputRegs"`
+	NamedHoldingRegs map[uint16]NamedRegister `json:"NamedHoldingRegs"`
+	NamedCoils       map[uint16]NamedCoil     `json:"NamedCoils"`
+}
+
+type registerRef struct {
+	table   Table
+	address uint16
+}
+
+// RegisterMap is the simulated device's memory. Addresses that are not in
+// the register file read as zero, as unused registers do on a real device.
+type RegisterMap struct {
+	mu      sync.RWMutex
+	input   map[uint16]uint16
+	holding map[uint16]uint16
+	coils   map[uint16]bool
+	names   map[string]registerRef
+}
+
+// LoadRegisterMap reads a register file from disk.
+func LoadRegisterMap(path string) (*RegisterMap, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path is supplied by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to read register file: %w", err)
+	}
+
+	var file RegisterFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse register file %s: %w", path, err)
+	}
+
+	return NewRegisterMap(file)
+}
+
+// NewRegisterMap creates a register map from a parsed register file. Names
+// must be unique across all tables, since scripts refer to registers by name.
+func NewRegisterMap(file RegisterFile) (*RegisterMap, error) {
+	m := &RegisterMap{
+		input:   make(map[uint16]uint16, len(file.NamedInputRegs)),
+		holding: make(map[uint16]uint16, len(file.NamedHoldingRegs)),
+		coils:   make(map[uint16]bool, len(file.NamedCoils)),
+		names:   make(map[string]registerRef),
+	}
+
+	addName := func(name string, ref registerRef) error {
+		if name == "" {
+			return nil
+		}
+		if existing, ok := m.names[name]; ok {
+			return fmt.Errorf("register name %s is used by %s 0x%04X and %s 0x%04X",
+				name, existing.table, existing.address, ref.table, ref.address)
+		}
+		m.names[name] = ref
+		return nil
+	}
+
+	for address, reg := range file.NamedInputRegs {
+		m.input[address] = reg.Value
+		if err := addName(reg.Name, registerRef{InputRegisters, address}); err != nil {
+			return nil, err
+		}
+	}
+	for address, reg := range file.NamedHoldingRegs {
+		m.holding[address] = reg.Value
+		if err := addName(reg.Name, registerRef{HoldingRegisters, address}); err != nil {
+			return nil, err
+		}
+	}
+	for address, coil := range file.NamedCoils {
+		m.coils[address] = coil.Value
+		if err := addName(coil.Name, registerRef{Coils, address}); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// ReadRegisters returns quantity registers from an input or holding table.
+func (m *RegisterMap) ReadRegisters(table Table, address, quantity uint16) []uint16 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	registers := m.registerTable(table)
+	values := make([]uint16, quantity)
+	for i := range values {
+		values[i] = registers[address+uint16(i)] //nolint:gosec // quantity is bounded by the PDU limits
+	}
+	return values
+}
+
+// WriteRegisters stores values in the holding table, as a Modbus master would.
+func (m *RegisterMap) WriteRegisters(address uint16, values []uint16) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, value := range values {
+		m.holding[address+uint16(i)] = value //nolint:gosec // values is bounded by the PDU limits
+	}
+}
+
+// ReadCoils returns quantity coil states.
+func (m *RegisterMap) ReadCoils(address, quantity uint16) []bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	values := make([]bool, quantity)
+	for i := range values {
+		values[i] = m.coils[address+uint16(i)] //nolint:gosec // quantity is bounded by the PDU limits
+	}
+	return values
+}
+
+// WriteCoil sets a coil, as a Modbus master would.
+func (m *RegisterMap) WriteCoil(address uint16, value bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.coils[address] = value
+}
+
+// Set changes a named input or holding register. This is how scripts move
+// values that a Modbus master cannot write.
+func (m *RegisterMap) Set(name string, value uint16) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ref, err := m.lookupRegister(name)
+	if err != nil {
+		return err
+	}
+	m.registerTable(ref.table)[ref.address] = value
+	return nil
+}
+
+// Get returns the current value of a named input or holding register.
+func (m *RegisterMap) Get(name string) (uint16, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ref, err := m.lookupRegister(name)
+	if err != nil {
+		return 0, err
+	}
+	return m.registerTable(ref.table)[ref.address], nil
+}
+
+func (m *RegisterMap) lookupRegister(name string) (registerRef, error) {
+	ref, ok := m.names[name]
+	if !ok {
+		return registerRef{}, fmt.Errorf("unknown register %s", name)
+	}
+	if ref.table == Coils {
+		return registerRef{}, fmt.Errorf("%s is a coil, not a register", name)
+	}
+	return ref, nil
+}
+
+// registerTable returns the map backing an input or holding table. The
+// caller must hold the lock.
+func (m *RegisterMap) registerTable(table Table) map[uint16]uint16 {
+	if table == HoldingRegisters {
+		return m.holding
+	}
+	return m.input
+}
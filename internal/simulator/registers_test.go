@@ -0,0 +1,144 @@
+package simulator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestRegisterMap(t *testing.T) *RegisterMap {
+	t.Helper()
+
+	regs, err := NewRegisterMap(RegisterFile{
+		NamedInputRegs: map[uint16]NamedRegister{
+			0x3100: {Name: "PV_VOLTAGE", Value: 3000},
+			0x3101: {Name: "PV_CURRENT", Value: 500},
+		},
+		NamedHoldingRegs: map[uint16]NamedRegister{
+			0x9000: {Name: "BAT_TYPE", Value: 1},
+			0x9001: {Name: "BAT_CAPACITY", Value: 100},
+		},
+		NamedCoils: map[uint16]NamedCoil{
+			0x0002: {Name: "LOAD_ON", Value: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRegisterMap failed: %v", err)
+	}
+	return regs
+}
+
+func TestNewRegisterMap_DuplicateNames(t *testing.T) {
+	_, err := NewRegisterMap(RegisterFile{
+		NamedInputRegs:   map[uint16]NamedRegister{0x3100: {Name: "PV_VOLTAGE"}},
+		NamedHoldingRegs: map[uint16]NamedRegister{0x9000: {Name: "PV_VOLTAGE"}},
+	})
+	if err == nil {
+		t.Fatal("expected an error for a name used twice")
+	}
+	if !strings.Contains(err.Error(), "PV_VOLTAGE") {
+		t.Errorf("error should name the register, got %v", err)
+	}
+}
+
+func TestRegisterMap_ReadRegisters(t *testing.T) {
+	regs := newTestRegisterMap(t)
+
+	got := regs.ReadRegisters(InputRegisters, 0x3100, 3)
+	want := []uint16{3000, 500, 0}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("register 0x%04X = %d, want %d", 0x3100+i, got[i], want[i])
+		}
+	}
+
+	// The tables are separate: a holding read at an input address sees nothing
+	if got := regs.ReadRegisters(HoldingRegisters, 0x3100, 1); got[0] != 0 {
+		t.Errorf("holding 0x3100 = %d, want 0", got[0])
+	}
+}
+
+func TestRegisterMap_WriteRegisters(t *testing.T) {
+	regs := newTestRegisterMap(t)
+
+	regs.WriteRegisters(0x9000, []uint16{2, 200})
+
+	if got, _ := regs.Get("BAT_TYPE"); got != 2 {
+		t.Errorf("BAT_TYPE = %d, want 2", got)
+	}
+	if got, _ := regs.Get("BAT_CAPACITY"); got != 200 {
+		t.Errorf("BAT_CAPACITY = %d, want 200", got)
+	}
+}
+
+func TestRegisterMap_Coils(t *testing.T) {
+	regs := newTestRegisterMap(t)
+
+	if got := regs.ReadCoils(0x0002, 1); !got[0] {
+		t.Error("LOAD_ON should start on")
+	}
+
+	regs.WriteCoil(0x0002, false)
+
+	if got := regs.ReadCoils(0x0002, 1); got[0] {
+		t.Error("LOAD_ON should be off after the write")
+	}
+}
+
+func TestRegisterMap_SetAndGet(t *testing.T) {
+	regs := newTestRegisterMap(t)
+
+	if err := regs.Set("PV_VOLTAGE", 3800); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	got, err := regs.Get("PV_VOLTAGE")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got != 3800 {
+		t.Errorf("PV_VOLTAGE = %d, want 3800", got)
+	}
+
+	if err := regs.Set("NO_SUCH_REGISTER", 1); err == nil {
+		t.Error("expected an error setting an unknown register")
+	}
+	if _, err := regs.Get("LOAD_ON"); err == nil {
+		t.Error("expected an error reading a coil as a register")
+	}
+}
+
+func TestLoadRegisterMap(t *testing.T) {
+	regs, err := LoadRegisterMap(filepath.Join("..", "..", "testdata", "simulator", "epever.json"))
+	if err != nil {
+		t.Fatalf("LoadRegisterMap failed: %v", err)
+	}
+	if got, _ := regs.Get("PV_VOLTAGE"); got != 3000 {
+		t.Errorf("PV_VOLTAGE = %d, want 3000", got)
+	}
+
+	if _, err := LoadRegisterMap(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.json")
+	if err := os.WriteFile(bad, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRegisterMap(bad); err == nil {
+		t.Error("expected an error for malformed JSON")
+	}
+}
+
+func TestTableString(t *testing.T) {
+	for table, want := range map[Table]string{
+		InputRegisters:   "input",
+		HoldingRegisters: "holding",
+		Coils:            "coil",
+		Table(99):        "unknown",
+	} {
+		if got := table.String(); got != want {
+			t.Errorf("Table(%d).String() = %q, want %q", table, got, want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/creack/pty"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	rtuMinFrameSize = 4
+	rtuMaxFrameSize = 256
+
+	// rtuFrameGap is the silence that ends a request frame. On a real line
+	// that is 3.5 character times; a pty delivers a write all at once, so
+	// this only needs to outlast scheduling jitter.
+	rtuFrameGap = 10 * time.Millisecond
+)
+
+// RTUServer serves a register map over Modbus RTU on a pseudo-terminal. A
+// client opens DevicePath as it would a USB serial adapter; baud rate and
+// framing settings are accepted and ignored.
+type RTUServer struct {
+	registers *RegisterMap
+	slaveID   byte
+	master    *os.File
+
+	// slave is held open so reads on the master do not fail with EIO
+	// while no client has the device open
+	slave *os.File
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewRTUServer opens a pty for the given slave ID. Call Serve to start
+// answering requests.
+func NewRTUServer(registers *RegisterMap, slaveID byte) (*RTUServer, error) {
+	master, slave, err := pty.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pty: %w", err)
+	}
+	if master, err = pollable(master); err != nil {
+		_ = slave.Close()
+		return nil, err
+	}
+
+	return &RTUServer{
+		registers: registers,
+		slaveID:   slaveID,
+		master:    master,
+		slave:     slave,
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// DevicePath returns the serial device a client should open.
+func (s *RTUServer) DevicePath() string {
+	return s.slave.Name()
+}
+
+// Serve answers requests until Close is called.
+func (s *RTUServer) Serve() error {
+	log.Infof("Modbus RTU simulator serving slave ID %d on %s", s.slaveID, s.DevicePath())
+
+	for {
+		frame, err := s.readFrame()
+		if err != nil {
+			select {
+			case <-s.done:
+				return nil
+			default:
+			}
+			return fmt.Errorf("failed to read from pty: %w", err)
+		}
+
+		response := s.respond(frame)
+		if response == nil {
+			continue
+		}
+		if _, err := s.master.Write(response); err != nil {
+			return fmt.Errorf("failed to write to pty: %w", err)
+		}
+	}
+}
+
+// readFrame blocks until a request starts, then collects bytes until the
+// line goes quiet.
+func (s *RTUServer) readFrame() ([]byte, error) {
+	frame := make([]byte, 0, rtuMaxFrameSize)
+	buf := make([]byte, rtuMaxFrameSize)
+
+	if err := s.master.SetReadDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	for {
+		n, err := s.master.Read(buf)
+		frame = append(frame, buf[:n]...)
+		if errors.Is(err, os.ErrDeadlineExceeded) || len(frame) >= rtuMaxFrameSize {
+			return frame, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := s.master.SetReadDeadline(time.Now().Add(rtuFrameGap)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// respond decodes a request frame and returns the response frame, or nil
+// when no response should be sent: a corrupt frame or one for another
+// slave gets silence, as on a shared RS-485 bus.
+func (s *RTUServer) respond(frame []byte) []byte {
+	if len(frame) < rtuMinFrameSize {
+		log.Debugf("Modbus RTU ignoring short frame % x", frame)
+		return nil
+	}
+	body, checksum := frame[:len(frame)-2], frame[len(frame)-2:]
+	if crc := crc16(body); checksum[0] != byte(crc) || checksum[1] != byte(crc>>8) {
+		log.Debugf("Modbus RTU ignoring frame with bad CRC % x", frame)
+		return nil
+	}
+	if body[0] != s.slaveID {
+		return nil
+	}
+
+	function, data := s.registers.handle(body[1], body[2:])
+	log.Debugf("Modbus RTU function 0x%02X request % x, response 0x%02X % x", body[1], body[2:], function, data)
+
+	response := make([]byte, 0, len(data)+4)
+	response = append(response, s.slaveID, function)
+	response = append(response, data...)
+	crc := crc16(response)
+	return append(response, byte(crc), byte(crc>>8))
+}
+
+// Close stops serving and releases the pty.
+func (s *RTUServer) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		err = errors.Join(s.master.Close(), s.slave.Close())
+	})
+	return err
+}
+
+// crc16 is the Modbus RTU CRC: polynomial 0xA001 (reflected 0x8005),
+// initial value 0xFFFF, transmitted low byte first.
+func crc16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for range 8 {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
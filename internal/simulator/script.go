@@ -0,0 +1,226 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Script describes how register values change over time, for example a
+// sunrise ramp. It is loaded from JSON:
+//
+//	{
+//	  "loop": false,
+//	  "changes": [
+//	    {"register": "PV_VOLTAGE", "at": "0s", "over": "2m", "from": 0, "to": 3800},
+//	    {"register": "CHARGING_STATUS", "at": "2m", "to": 9}
+//	  ]
+//	}
+//
+// Values are raw register values, in the units the register file uses.
+type Script struct {
+	// Loop restarts the script when it ends. Registers return to their
+	// starting values at the top of each loop.
+	Loop bool `json:"loop"`
+
+	// Duration is how long one pass lasts, as a duration string. It
+	// defaults to the end of the last change.
+	Duration string `json:"duration,omitempty"`
+
+	Changes []Change `json:"changes"`
+}
+
+// Change moves one named register to a new value, either in a single step
+// or as a linear ramp.
+type Change struct {
+	Register string `json:"register"`
+
+	// At is when the change starts, as an offset from the start of the
+	// script (default: 0s).
+	At string `json:"at,omitempty"`
+
+	// Over is how long the ramp to To lasts; empty means a step change.
+	Over string `json:"over,omitempty"`
+
+	// From is where the ramp starts. It defaults to where the register was
+	// left by its previous change, or its starting value.
+	From *uint16 `json:"from,omitempty"`
+
+	To uint16 `json:"to"`
+}
+
+// LoadScript reads a script from disk.
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path is supplied by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+
+	var script Script
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("failed to parse script %s: %w", path, err)
+	}
+	return &script, nil
+}
+
+// segment is a Change resolved against the register map: durations parsed
+// and the starting value filled in.
+type segment struct {
+	start time.Duration
+	end   time.Duration
+	from  float64
+	to    float64
+}
+
+// Player applies a Script to a register map.
+type Player struct {
+	registers *RegisterMap
+	loop      bool
+	duration  time.Duration
+
+	// timelines holds each scripted register's segments in start order;
+	// initial holds the value it had when the player was created.
+	timelines map[string][]segment
+	initial   map[string]uint16
+}
+
+// NewPlayer checks a script against the register map and prepares it to
+// run. Register starting values are captured now.
+func NewPlayer(registers *RegisterMap, script *Script) (*Player, error) {
+	p := &Player{
+		registers: registers,
+		loop:      script.Loop,
+		timelines: make(map[string][]segment),
+		initial:   make(map[string]uint16),
+	}
+
+	changes := make([]Change, len(script.Changes))
+	copy(changes, script.Changes)
+
+	starts := make([]time.Duration, len(changes))
+	for i, change := range changes {
+		start, err := parseOffset(change.At)
+		if err != nil {
+			return nil, fmt.Errorf("change %d (%s): invalid at: %w", i, change.Register, err)
+		}
+		starts[i] = start
+	}
+	order := make([]int, len(changes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return starts[order[a]] < starts[order[b]] })
+
+	for _, i := range order {
+		change := changes[i]
+		if _, ok := p.initial[change.Register]; !ok {
+			value, err := registers.Get(change.Register)
+			if err != nil {
+				return nil, fmt.Errorf("change %d: %w", i, err)
+			}
+			p.initial[change.Register] = value
+		}
+
+		over, err := parseOffset(change.Over)
+		if err != nil {
+			return nil, fmt.Errorf("change %d (%s): invalid over: %w", i, change.Register, err)
+		}
+
+		from := float64(p.initial[change.Register])
+		if timeline := p.timelines[change.Register]; len(timeline) > 0 {
+			from = timeline[len(timeline)-1].to
+		}
+		if change.From != nil {
+			from = float64(*change.From)
+		}
+
+		seg := segment{start: starts[i], end: starts[i] + over, from: from, to: float64(change.To)}
+		p.timelines[change.Register] = append(p.timelines[change.Register], seg)
+		p.duration = max(p.duration, seg.end)
+	}
+
+	if script.Duration != "" {
+		duration, err := time.ParseDuration(script.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid script duration: %w", err)
+		}
+		p.duration = duration
+	}
+	if p.loop && p.duration <= 0 {
+		return nil, fmt.Errorf("a looping script needs a positive duration")
+	}
+
+	return p, nil
+}
+
+func parseOffset(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%s is negative", value)
+	}
+	return d, nil
+}
+
+// Run applies the script every interval until ctx is cancelled, or until
+// a non-looping script has finished.
+func (p *Player) Run(ctx context.Context, interval time.Duration) {
+	start := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	p.Apply(0)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			elapsed := now.Sub(start)
+			p.Apply(elapsed)
+			if !p.loop && elapsed >= p.duration {
+				log.Info("simulator script finished")
+				return
+			}
+		}
+	}
+}
+
+// Apply sets every scripted register to its value at elapsed time into the
+// script.
+func (p *Player) Apply(elapsed time.Duration) {
+	if p.loop {
+		elapsed %= p.duration
+	}
+	for name := range p.timelines {
+		// Names were checked when the player was created
+		_ = p.registers.Set(name, p.valueAt(name, elapsed))
+	}
+}
+
+// valueAt returns a register's scripted value at an offset into the script.
+func (p *Player) valueAt(name string, elapsed time.Duration) uint16 {
+	value := float64(p.initial[name])
+	for _, seg := range p.timelines[name] {
+		if elapsed < seg.start {
+			break
+		}
+		if elapsed >= seg.end {
+			value = seg.to
+			continue
+		}
+		progress := float64(elapsed-seg.start) / float64(seg.end-seg.start)
+		value = seg.from + (seg.to-seg.from)*progress
+	}
+	return uint16(math.Round(value))
+}
@@ -0,0 +1,146 @@
+package simulator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func uint16Ptr(v uint16) *uint16 { return &v }
+
+func TestPlayer_ValueAt(t *testing.T) {
+	regs := newTestRegisterMap(t)
+	player, err := NewPlayer(regs, &Script{Changes: []Change{
+		// Listed out of order: the player sorts by start time
+		{Register: "PV_VOLTAGE", At: "2m", Over: "1m", To: 0},
+		{Register: "PV_VOLTAGE", At: "0s", Over: "1m", From: uint16Ptr(0), To: 4000},
+		{Register: "PV_CURRENT", At: "30s", To: 900},
+	}})
+	if err != nil {
+		t.Fatalf("NewPlayer failed: %v", err)
+	}
+
+	tests := []struct {
+		register string
+		elapsed  time.Duration
+		want     uint16
+	}{
+		{"PV_VOLTAGE", 0, 0},
+		{"PV_VOLTAGE", 15 * time.Second, 1000},
+		{"PV_VOLTAGE", time.Minute, 4000},
+		// Held between changes
+		{"PV_VOLTAGE", 90 * time.Second, 4000},
+		// The second ramp starts where the first one ended
+		{"PV_VOLTAGE", 150 * time.Second, 2000},
+		{"PV_VOLTAGE", 10 * time.Minute, 0},
+		// A step keeps the starting value until it fires
+		{"PV_CURRENT", 29 * time.Second, 500},
+		{"PV_CURRENT", 30 * time.Second, 900},
+	}
+
+	for _, tt := range tests {
+		if got := player.valueAt(tt.register, tt.elapsed); got != tt.want {
+			t.Errorf("%s at %v = %d, want %d", tt.register, tt.elapsed, got, tt.want)
+		}
+	}
+}
+
+func TestPlayer_ApplyLoops(t *testing.T) {
+	regs := newTestRegisterMap(t)
+	player, err := NewPlayer(regs, &Script{
+		Loop:     true,
+		Duration: "2m",
+		Changes:  []Change{{Register: "PV_VOLTAGE", Over: "1m", From: uint16Ptr(0), To: 1000}},
+	})
+	if err != nil {
+		t.Fatalf("NewPlayer failed: %v", err)
+	}
+
+	player.Apply(2*time.Minute + 30*time.Second)
+
+	if got, _ := regs.Get("PV_VOLTAGE"); got != 500 {
+		t.Errorf("PV_VOLTAGE = %d, want 500 halfway up the second loop", got)
+	}
+}
+
+func TestNewPlayer_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		script Script
+	}{
+		{"unknown register", Script{Changes: []Change{{Register: "NO_SUCH_REGISTER"}}}},
+		{"coil", Script{Changes: []Change{{Register: "LOAD_ON"}}}},
+		{"invalid at", Script{Changes: []Change{{Register: "PV_VOLTAGE", At: "soon"}}}},
+		{"negative at", Script{Changes: []Change{{Register: "PV_VOLTAGE", At: "-1s"}}}},
+		{"invalid over", Script{Changes: []Change{{Register: "PV_VOLTAGE", Over: "a while"}}}},
+		{"invalid duration", Script{Duration: "long", Changes: []Change{{Register: "PV_VOLTAGE"}}}},
+		{"looping without a duration", Script{Loop: true, Changes: []Change{{Register: "PV_VOLTAGE"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPlayer(newTestRegisterMap(t), &tt.script); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestPlayer_Run(t *testing.T) {
+	regs := newTestRegisterMap(t)
+	player, err := NewPlayer(regs, &Script{Changes: []Change{
+		{Register: "PV_VOLTAGE", At: "10ms", To: 3800},
+	}})
+	if err != nil {
+		t.Fatalf("NewPlayer failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		player.Run(ctx, 5*time.Millisecond)
+		close(done)
+	}()
+
+	// A non-looping script returns once it has finished
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal("Run did not return after the script finished")
+	}
+
+	if got, _ := regs.Get("PV_VOLTAGE"); got != 3800 {
+		t.Errorf("PV_VOLTAGE = %d, want 3800", got)
+	}
+}
+
+func TestLoadScript(t *testing.T) {
+	script, err := LoadScript(filepath.Join("..", "..", "testdata", "simulator", "sunrise.json"))
+	if err != nil {
+		t.Fatalf("LoadScript failed: %v", err)
+	}
+
+	regs, err := LoadRegisterMap(filepath.Join("..", "..", "testdata", "simulator", "epever.json"))
+	if err != nil {
+		t.Fatalf("LoadRegisterMap failed: %v", err)
+	}
+	if _, err := NewPlayer(regs, script); err != nil {
+		t.Errorf("sunrise.json does not match epever.json: %v", err)
+	}
+
+	if _, err := LoadScript(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.json")
+	if err := os.WriteFile(bad, []byte("["), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadScript(bad); err == nil {
+		t.Error("expected an error for malformed JSON")
+	}
+}
@@ -0,0 +1,141 @@
+package simulator
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// mbapHeaderSize is the Modbus TCP header: transaction ID, protocol ID and
+// length (two bytes each), then the unit ID.
+const mbapHeaderSize = 7
+
+// TCPServer serves a register map over Modbus TCP. It answers every unit ID,
+// as a single-device gateway does.
+type TCPServer struct {
+	registers *RegisterMap
+	listener  net.Listener
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewTCPServer listens on address (for example ":5020"). Call Serve to start
+// answering requests.
+func NewTCPServer(registers *RegisterMap, address string) (*TCPServer, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+
+	return &TCPServer{
+		registers: registers,
+		listener:  listener,
+		conns:     make(map[net.Conn]struct{}),
+	}, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *TCPServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Serve accepts connections until Close is called.
+func (s *TCPServer) Serve() error {
+	log.Infof("Modbus TCP simulator listening on %s", s.listener.Addr())
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return nil
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+func (s *TCPServer) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+		s.wg.Done()
+	}()
+
+	log.Debugf("Modbus TCP client connected from %s", conn.RemoteAddr())
+
+	header := make([]byte, mbapHeaderSize)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Debugf("Modbus TCP read from %s failed: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		// The length counts the unit ID and the PDU
+		length := int(binary.BigEndian.Uint16(header[4:6]))
+		if binary.BigEndian.Uint16(header[2:4]) != 0 || length < 2 || length > 254 {
+			log.Warnf("Modbus TCP client %s sent an invalid header % x; closing", conn.RemoteAddr(), header)
+			return
+		}
+
+		pdu := make([]byte, length-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			return
+		}
+
+		function, data := s.registers.handle(pdu[0], pdu[1:])
+		log.Debugf("Modbus TCP function 0x%02X request % x, response 0x%02X % x", pdu[0], pdu[1:], function, data)
+
+		response := make([]byte, mbapHeaderSize+1+len(data))
+		copy(response[0:4], header[0:4])                               // transaction and protocol IDs
+		binary.BigEndian.PutUint16(response[4:6], uint16(2+len(data))) //nolint:gosec // data is at most 252 bytes
+		response[6] = header[6]
+		response[7] = function
+		copy(response[8:], data)
+
+		if _, err := conn.Write(response); err != nil {
+			return
+		}
+	}
+}
+
+// Close stops the listener, disconnects all clients and waits for their
+// handlers to exit.
+func (s *TCPServer) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
@@ -0,0 +1,73 @@
+package simulator
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/lumberbarons/modbus"
+)
+
+func startTCPServer(t *testing.T, regs *RegisterMap) *TCPServer {
+	t.Helper()
+
+	server, err := NewTCPServer(regs, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("NewTCPServer failed: %v", err)
+	}
+	errs := make(chan error, 1)
+	go func() { errs <- server.Serve() }()
+	t.Cleanup(func() {
+		if err := server.Close(); err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+		if err := <-errs; err != nil {
+			t.Errorf("Serve returned %v after Close", err)
+		}
+	})
+	return server
+}
+
+func TestTCPServer_ReadAndWrite(t *testing.T) {
+	regs := newTestRegisterMap(t)
+	server := startTCPServer(t, regs)
+
+	handler := modbus.NewTCPClientHandler(server.Addr().String())
+	handler.SlaveID = 1
+	defer handler.Close()
+	client := modbus.NewClient(handler)
+	ctx := context.Background()
+
+	results, err := client.ReadInputRegisters(ctx, 0x3100, 2)
+	if err != nil {
+		t.Fatalf("ReadInputRegisters failed: %v", err)
+	}
+	if got := binary.BigEndian.Uint16(results[0:2]); got != 3000 {
+		t.Errorf("PV_VOLTAGE = %d, want 3000", got)
+	}
+	if got := binary.BigEndian.Uint16(results[2:4]); got != 500 {
+		t.Errorf("PV_CURRENT = %d, want 500", got)
+	}
+
+	if _, err := client.WriteMultipleRegisters(ctx, 0x9000, 2, []byte{0x00, 0x02, 0x00, 0xC8}); err != nil {
+		t.Fatalf("WriteMultipleRegisters failed: %v", err)
+	}
+	if got, _ := regs.Get("BAT_CAPACITY"); got != 200 {
+		t.Errorf("BAT_CAPACITY = %d, want 200", got)
+	}
+
+	// An exception comes back as an error rather than a dropped connection,
+	// and the connection stays usable afterwards
+	if _, err := client.ReadInputRegisters(ctx, 0xFFFF, 2); err == nil {
+		t.Error("expected an exception for a read past the address space")
+	}
+	if _, err := client.ReadHoldingRegisters(ctx, 0x9001, 1); err != nil {
+		t.Errorf("read after an exception failed: %v", err)
+	}
+}
+
+func TestNewTCPServer_ListenFailure(t *testing.T) {
+	if _, err := NewTCPServer(newTestRegisterMap(t), "256.0.0.1:0"); err == nil {
+		t.Error("expected an error for an invalid address")
+	}
+}
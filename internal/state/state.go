@@ -0,0 +1,75 @@
+// Package state keeps small pieces of controller state, such as running
+// totals, on disk so they survive a restart.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Load reads the JSON file at path into v. A file that does not exist yet
+// leaves v as it is and is not an error.
+func Load(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Save writes v to path as JSON. It writes a temporary file beside path and
+// renames it into place, so a crash or power cut leaves either the old state
+// or the new one, never a partly written file.
+func Save(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create state file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // nolint:errcheck // Gone after the rename; only cleans up a failed write
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close() // nolint:errcheck // The write error is the one to report
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close() // nolint:errcheck // The sync error is the one to report
+		return fmt.Errorf("failed to sync state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	return nil
+}
+
+// SetAside renames an unreadable state file out of the way, so the next Save
+// starts afresh without destroying what it held. It returns the new name.
+func SetAside(path string) (string, error) {
+	aside := path + ".bad"
+	if err := os.Rename(path, aside); err != nil {
+		return "", fmt.Errorf("failed to set aside state file: %w", err)
+	}
+	return aside, nil
+}
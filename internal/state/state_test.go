@@ -0,0 +1,100 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type totals struct {
+	Charged    float64 `json:"charged"`
+	Discharged float64 `json:"discharged"`
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "totals.json")
+
+	require.NoError(t, Save(path, totals{Charged: 12.5, Discharged: 3}))
+
+	var loaded totals
+	require.NoError(t, Load(path, &loaded))
+	assert.Equal(t, totals{Charged: 12.5, Discharged: 3}, loaded)
+
+	require.NoError(t, Save(path, totals{Charged: 13}))
+	require.NoError(t, Load(path, &loaded))
+	assert.Equal(t, totals{Charged: 13}, loaded)
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary files should be left behind")
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("missing file leaves the value alone", func(t *testing.T) {
+		loaded := totals{Charged: 1}
+		require.NoError(t, Load(filepath.Join(t.TempDir(), "missing.json"), &loaded))
+		assert.Equal(t, totals{Charged: 1}, loaded)
+	})
+
+	t.Run("corrupt file is an error", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "totals.json")
+		require.NoError(t, os.WriteFile(path, []byte("{not json"), 0o600))
+
+		var loaded totals
+		err := Load(path, &loaded)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to parse")
+	})
+
+	t.Run("unreadable path is an error", func(t *testing.T) {
+		var loaded totals
+		err := Load(t.TempDir(), &loaded)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read")
+	})
+}
+
+func TestSave(t *testing.T) {
+	t.Run("unmarshalable value is an error", func(t *testing.T) {
+		err := Save(filepath.Join(t.TempDir(), "totals.json"), func() {})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to marshal")
+	})
+
+	t.Run("directory that cannot be created is an error", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "file")
+		require.NoError(t, os.WriteFile(file, nil, 0o600))
+
+		err := Save(filepath.Join(file, "totals.json"), totals{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to create state directory")
+	})
+
+	t.Run("path that is a directory cannot be replaced", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "totals.json")
+		require.NoError(t, os.Mkdir(path, 0o750))
+		require.NoError(t, os.WriteFile(filepath.Join(path, "keep"), nil, 0o600))
+
+		err := Save(path, totals{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to replace")
+	})
+}
+
+func TestSetAside(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "totals.json")
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0o600))
+
+	aside, err := SetAside(path)
+	require.NoError(t, err)
+	assert.Equal(t, path+".bad", aside)
+	assert.NoFileExists(t, path)
+	assert.FileExists(t, aside)
+
+	_, err = SetAside(path)
+	assert.Error(t, err)
+}
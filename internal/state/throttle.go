@@ -0,0 +1,53 @@
+package state
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SaveInterval bounds how often state that changes every collection is
+// written, to spare SD cards. A crash loses at most this much of it.
+const SaveInterval = 5 * time.Minute
+
+// Throttle spaces saves of one piece of state at least SaveInterval apart.
+// The zero value is ready to use.
+type Throttle struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// Due reports whether a save is due at now: always when forced, otherwise
+// once SaveInterval has passed since the last. A save that is due counts as
+// done, so a failed one is retried at the next interval.
+func (t *Throttle) Due(now time.Time, force bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !force && now.Sub(t.last) < SaveInterval {
+		return false
+	}
+	t.last = now
+	return true
+}
+
+// Restore reads the file name under dataDir into v, with what naming the
+// state in the log. A missing file, or no dataDir, leaves v as it is. A
+// file that cannot be read is set aside and false returned, so the caller
+// starts over rather than failing to start.
+func Restore(dataDir, name, what string, v any) bool {
+	if dataDir == "" {
+		return true
+	}
+	path := filepath.Join(dataDir, name)
+	if err := Load(path, v); err != nil {
+		log.Errorf("failed to load %s, starting over: %s", what, err)
+		if aside, err := SetAside(path); err == nil {
+			log.Warnf("unreadable %s kept as %s", what, aside)
+		}
+		return false
+	}
+	return true
+}
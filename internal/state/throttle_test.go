@@ -0,0 +1,50 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottle(t *testing.T) {
+	start := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	var throttle Throttle
+
+	assert.True(t, throttle.Due(start, false), "the first save is due")
+	assert.False(t, throttle.Due(start.Add(time.Minute), false), "a save within the interval is not")
+	assert.True(t, throttle.Due(start.Add(time.Minute), true), "a forced save always is")
+	assert.False(t, throttle.Due(start.Add(SaveInterval), false), "the interval runs from the forced save")
+	assert.True(t, throttle.Due(start.Add(time.Minute+SaveInterval), false))
+}
+
+func TestRestore(t *testing.T) {
+	t.Run("reads a saved file", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, Save(filepath.Join(dir, "totals.json"), totals{Charged: 4}))
+
+		var loaded totals
+		assert.True(t, Restore(dir, "totals.json", "totals", &loaded))
+		assert.Equal(t, totals{Charged: 4}, loaded)
+	})
+
+	t.Run("no data directory leaves the value alone", func(t *testing.T) {
+		loaded := totals{Charged: 1}
+		assert.True(t, Restore("", "totals.json", "totals", &loaded))
+		assert.Equal(t, totals{Charged: 1}, loaded)
+	})
+
+	t.Run("an unreadable file is set aside", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "totals.json")
+		require.NoError(t, os.WriteFile(path, []byte("{not json"), 0o600))
+
+		var loaded totals
+		assert.False(t, Restore(dir, "totals.json", "totals", &loaded))
+		assert.NoFileExists(t, path)
+		assert.FileExists(t, path+".bad")
+	})
+}
@@ -0,0 +1,44 @@
+package system
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// StatusGet adds the health object alongside the status fields, so it is
+// driven through the handler; the daily object is checked on its own.
+func TestStatusPayloadMatchesAPIContract(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	controller := newControllerForTest(&MockSource{}, &MockSource{}, &testutil.MockMessagePublisher{}, &MockMetricsCollector{}, "test-device-1")
+	controller.last.Set(newStatus(1000, 500, 1000, 300))
+
+	router := gin.New()
+	router.GET("/api/system", controller.StatusGet())
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/system", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/system"),
+		testutil.JSONFieldNames(t, recorder.Body.Bytes()),
+		"GET /api/system no longer returns the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
+
+func TestDailyBalancePayloadMatchesAPIContract(t *testing.T) {
+	payload, err := json.Marshal(DailyBalance{})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		testutil.APIContractFields(t, "GET /api/system#daily"),
+		testutil.JSONFieldNames(t, payload),
+		"DailyBalance no longer marshals to the fields docs/api-contract.json "+
+			"records; update the contract and site/src/api/types.ts together")
+}
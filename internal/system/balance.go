@@ -0,0 +1,168 @@
+package system
+
+import (
+	"time"
+
+	"github.com/lumberbarons/solar-controller/internal/energy"
+)
+
+// balanceFileName is the file under the data directory that holds the day's
+// energy balance.
+const balanceFileName = "system-balance.json"
+
+// Energy is what flowed through the system over a period, in kilowatt-hours.
+type Energy struct {
+	SolarKWh float64 `json:"solarKwh"`
+	LoadKWh  float64 `json:"loadKwh"`
+
+	// SolarToLoadKWh is the part of the load met directly by solar rather
+	// than from the battery
+	SolarToLoadKWh float64 `json:"solarToLoadKwh"`
+
+	BatteryChargedKWh    float64 `json:"batteryChargedKwh"`
+	BatteryDischargedKWh float64 `json:"batteryDischargedKwh"`
+}
+
+func (e *Energy) add(other Energy) {
+	e.SolarKWh += other.SolarKWh
+	e.LoadKWh += other.LoadKWh
+	e.SolarToLoadKWh += other.SolarToLoadKWh
+	e.BatteryChargedKWh += other.BatteryChargedKWh
+	e.BatteryDischargedKWh += other.BatteryDischargedKWh
+}
+
+// DailyBalance is the energy balance of one local day.
+type DailyBalance struct {
+	// Day is the local day (2006-01-02) the totals are for
+	Day string `json:"day"`
+	Energy
+
+	// BalanceKWh is the solar energy less the load's: positive when the day
+	// left more in the battery than it took out
+	BalanceKWh float64 `json:"balanceKwh"`
+
+	// SelfConsumption is the share of the solar energy the load used
+	// directly, in percent; nil until some solar energy has been counted
+	SelfConsumption *float64 `json:"selfConsumption"`
+}
+
+// Status is the system's power flows from one joined pair of readings, and
+// the day's energy balance up to it. Powers are in watts.
+type Status struct {
+	// Timestamp is that of the later of the two readings; ChargerTimestamp
+	// and BatteryTimestamp are those of each
+	Timestamp        int64 `json:"timestamp"`
+	ChargerTimestamp int64 `json:"chargerTimestamp"`
+	BatteryTimestamp int64 `json:"batteryTimestamp"`
+
+	SolarPower float64 `json:"solarPower"`
+
+	// BatteryPower is positive when charging. BatteryChargePower and
+	// BatteryDischargePower split it into the flow in and the flow out,
+	// both positive
+	BatteryPower          float64 `json:"batteryPower"`
+	BatteryChargePower    float64 `json:"batteryChargePower"`
+	BatteryDischargePower float64 `json:"batteryDischargePower"`
+
+	// LoadPower is the solar power less the battery power: what the house
+	// draws. Noise and the time between the readings can make that slightly
+	// negative, which is reported as zero
+	LoadPower float64 `json:"loadPower"`
+
+	// SelfConsumption is the share of the solar power the load uses
+	// directly, in percent; nil while there is no solar power
+	SelfConsumption *float64 `json:"selfConsumption"`
+
+	Daily DailyBalance `json:"daily"`
+}
+
+// newStatus works out the power flows from the charging power and the
+// battery power.
+func newStatus(chargerTimestamp int64, solarPower float64, batteryTimestamp int64, batteryPower float64) *Status {
+	status := &Status{
+		Timestamp:             max(chargerTimestamp, batteryTimestamp),
+		ChargerTimestamp:      chargerTimestamp,
+		BatteryTimestamp:      batteryTimestamp,
+		SolarPower:            solarPower,
+		BatteryPower:          batteryPower,
+		BatteryChargePower:    max(batteryPower, 0),
+		BatteryDischargePower: max(-batteryPower, 0),
+		LoadPower:             max(solarPower-batteryPower, 0),
+	}
+	if solarPower > 0 {
+		selfConsumption := min(status.LoadPower, solarPower) / solarPower * 100
+		status.SelfConsumption = &selfConsumption
+	}
+	return status
+}
+
+// balanceSample is the reading the next one is integrated from.
+type balanceSample struct {
+	Timestamp int64   `json:"timestamp"`
+	Solar     float64 `json:"solar"`
+	Load      float64 `json:"load"`
+	Battery   float64 `json:"battery"`
+}
+
+// balanceCounter integrates the power flows across cycles into the day's
+// energy. Keeping the last sample in the saved counter lets a short restart
+// pick up where it left off.
+type balanceCounter struct {
+	Day   string         `json:"day"`
+	Daily Energy         `json:"daily"`
+	Last  *balanceSample `json:"last,omitempty"`
+}
+
+// add counts the energy from the last sample to this status, unless failed
+// joins or downtime left more than maxGap between them, and returns it. A
+// midnight in between puts it all in the new day.
+func (c *balanceCounter) add(status *Status, maxGap time.Duration) Energy {
+	sample := &balanceSample{
+		Timestamp: status.Timestamp,
+		Solar:     status.SolarPower,
+		Load:      status.LoadPower,
+		Battery:   status.BatteryPower,
+	}
+
+	if day := time.Unix(status.Timestamp, 0).Local().Format("2006-01-02"); day != c.Day {
+		c.Day, c.Daily = day, Energy{}
+	}
+
+	last := c.Last
+	c.Last = sample
+	if last == nil {
+		return Energy{}
+	}
+	hours := energy.Hours(last.Timestamp, sample.Timestamp, maxGap)
+	if hours == 0 {
+		return Energy{}
+	}
+
+	solarWh, _ := energy.Integrate(last.Solar, sample.Solar, hours)
+	loadWh, _ := energy.Integrate(last.Load, sample.Load, hours)
+	solarToLoadWh, _ := energy.Integrate(min(last.Solar, last.Load), min(sample.Solar, sample.Load), hours)
+	chargedWh, dischargedWh := energy.Integrate(last.Battery, sample.Battery, hours)
+	added := Energy{
+		SolarKWh:             solarWh / 1000,
+		LoadKWh:              loadWh / 1000,
+		SolarToLoadKWh:       solarToLoadWh / 1000,
+		BatteryChargedKWh:    chargedWh / 1000,
+		BatteryDischargedKWh: dischargedWh / 1000,
+	}
+	c.Daily.add(added)
+	return added
+}
+
+// daily returns the day's balance.
+func (c *balanceCounter) daily() DailyBalance {
+	daily := DailyBalance{
+		Day:        c.Day,
+		Energy:     c.Daily,
+		BalanceKWh: c.Daily.SolarKWh - c.Daily.LoadKWh,
+	}
+	if c.Daily.SolarKWh > 0 {
+		selfConsumption := c.Daily.SolarToLoadKWh / c.Daily.SolarKWh * 100
+		daily.SelfConsumption = &selfConsumption
+	}
+	return daily
+}
@@ -0,0 +1,122 @@
+package system
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestNewStatus(t *testing.T) {
+	tests := []struct {
+		name                string
+		solar, battery      float64
+		wantLoad            float64
+		wantIn, wantOut     float64
+		wantSelfConsumption *float64
+	}{
+		{"solar charges the battery and runs the load", 500, 300, 200, 300, 0, ptr(40.0)},
+		{"the battery tops up the solar", 100, -150, 250, 0, 150, ptr(100.0)},
+		{"night runs on the battery", 0, -80, 80, 0, 80, nil},
+		{"noise below zero load is no load", 100, 104, 0, 104, 0, ptr(0.0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := newStatus(1000, tt.solar, 1010, tt.battery)
+
+			if status.Timestamp != 1010 {
+				t.Errorf("Timestamp = %d, want the later reading's 1010", status.Timestamp)
+			}
+			if status.LoadPower != tt.wantLoad {
+				t.Errorf("LoadPower = %v, want %v", status.LoadPower, tt.wantLoad)
+			}
+			if status.BatteryChargePower != tt.wantIn || status.BatteryDischargePower != tt.wantOut {
+				t.Errorf("battery in/out = %v/%v, want %v/%v", status.BatteryChargePower, status.BatteryDischargePower, tt.wantIn, tt.wantOut)
+			}
+			switch {
+			case tt.wantSelfConsumption == nil && status.SelfConsumption != nil:
+				t.Errorf("SelfConsumption = %v, want nil without solar", *status.SelfConsumption)
+			case tt.wantSelfConsumption != nil && (status.SelfConsumption == nil || !almostEqual(*status.SelfConsumption, *tt.wantSelfConsumption)):
+				t.Errorf("SelfConsumption = %v, want %v", status.SelfConsumption, *tt.wantSelfConsumption)
+			}
+		})
+	}
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
+
+func TestBalanceCounter_Add(t *testing.T) {
+	noon := time.Date(2025, 6, 21, 12, 0, 0, 0, time.Local).Unix()
+
+	t.Run("integrates an hour of flows", func(t *testing.T) {
+		counter := &balanceCounter{}
+		if added := counter.add(newStatus(noon, 500, noon, 300), time.Hour); added != (Energy{}) {
+			t.Errorf("first sample added %+v, want nothing", added)
+		}
+		added := counter.add(newStatus(noon+3600, 100, noon+3600, -150), time.Hour)
+
+		// Solar 500 -> 100, load 200 -> 250, battery 300 -> -150 crossing
+		// zero two thirds of the way along
+		checks := []struct {
+			name      string
+			got, want float64
+		}{
+			{"solar", added.SolarKWh, 0.3},
+			{"load", added.LoadKWh, 0.225},
+			{"solar to load", added.SolarToLoadKWh, 0.15},
+			{"battery charged", added.BatteryChargedKWh, 0.1},
+			{"battery discharged", added.BatteryDischargedKWh, 0.025},
+		}
+		for _, c := range checks {
+			if !almostEqual(c.got, c.want) {
+				t.Errorf("%s = %v kWh, want %v", c.name, c.got, c.want)
+			}
+		}
+
+		daily := counter.daily()
+		if daily.Day != "2025-06-21" {
+			t.Errorf("Day = %s, want 2025-06-21", daily.Day)
+		}
+		if !almostEqual(daily.BalanceKWh, 0.075) {
+			t.Errorf("BalanceKWh = %v, want 0.075", daily.BalanceKWh)
+		}
+		if daily.SelfConsumption == nil || !almostEqual(*daily.SelfConsumption, 50) {
+			t.Errorf("SelfConsumption = %v, want 50", daily.SelfConsumption)
+		}
+	})
+
+	t.Run("does not count across a gap", func(t *testing.T) {
+		counter := &balanceCounter{}
+		counter.add(newStatus(noon, 500, noon, 300), time.Hour)
+		if added := counter.add(newStatus(noon+7200, 500, noon+7200, 300), time.Hour); added != (Energy{}) {
+			t.Errorf("added %+v across a two hour gap, want nothing", added)
+		}
+		if counter.Last == nil || counter.Last.Timestamp != noon+7200 {
+			t.Error("the sample after a gap should start counting again")
+		}
+	})
+
+	t.Run("starts a new day at midnight", func(t *testing.T) {
+		midnight := time.Date(2025, 6, 22, 0, 0, 0, 0, time.Local).Unix()
+		counter := &balanceCounter{}
+		counter.add(newStatus(midnight-1800, 0, midnight-1800, -100), time.Hour)
+		counter.add(newStatus(midnight-600, 0, midnight-600, -100), time.Hour)
+		added := counter.add(newStatus(midnight+600, 0, midnight+600, -100), time.Hour)
+
+		daily := counter.daily()
+		if daily.Day != "2025-06-22" {
+			t.Errorf("Day = %s, want 2025-06-22", daily.Day)
+		}
+		if daily.Energy != added {
+			t.Errorf("new day's totals = %+v, want only the interval spanning midnight %+v", daily.Energy, added)
+		}
+		if daily.SelfConsumption != nil {
+			t.Errorf("SelfConsumption = %v, want nil without solar", *daily.SelfConsumption)
+		}
+	})
+}
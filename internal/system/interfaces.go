@@ -0,0 +1,21 @@
+package system
+
+// Source is a controller whose latest data the balance is worked out from.
+// Every controllers.SolarController is one.
+type Source interface {
+	// Snapshot returns the controller's latest data, or nil before its
+	// first successful collection.
+	Snapshot() any
+}
+
+// MetricsCollector defines the interface for collecting and exposing metrics.
+// This abstraction allows for testing without the Prometheus global registry.
+type MetricsCollector interface {
+	// IncrementFailures increments the counter of cycles whose readings
+	// could not be joined.
+	IncrementFailures()
+
+	// SetMetrics updates the power gauges from the status and adds what the
+	// cycle counted to the energy counters.
+	SetMetrics(status *Status, added Energy)
+}
@@ -0,0 +1,84 @@
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Metric represents a single metric with its value, unit, and timestamp
+type Metric struct {
+	Name      string
+	Value     any
+	Unit      string
+	Timestamp int64
+}
+
+// MetricPayload is the JSON structure published for each metric
+type MetricPayload struct {
+	Value     any    `json:"value"`
+	Unit      string `json:"unit"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// ToJSON converts a Metric to its JSON representation
+func (m *Metric) ToJSON() (string, error) {
+	payload := MetricPayload{
+		Value:     m.Value,
+		Unit:      m.Unit,
+		Timestamp: m.Timestamp,
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal metric payload: %w", err)
+	}
+
+	return string(b), nil
+}
+
+// ConvertStatusToMetrics converts a Status into individual metrics: the
+// power flows, and the day's energy balance. The self-consumption metrics
+// are left out until there is solar to work them out from.
+func ConvertStatusToMetrics(status *Status) []Metric {
+	if status == nil {
+		return []Metric{}
+	}
+
+	timestamp := status.Timestamp
+	metric := func(name string, value any, unit string) Metric {
+		return Metric{Name: name, Value: value, Unit: unit, Timestamp: timestamp}
+	}
+
+	daily := status.Daily
+	metrics := []Metric{
+		metric("solar-power", status.SolarPower, "watts"),
+		metric("battery-power", status.BatteryPower, "watts"),
+		metric("battery-charge-power", status.BatteryChargePower, "watts"),
+		metric("battery-discharge-power", status.BatteryDischargePower, "watts"),
+		metric("load-power", status.LoadPower, "watts"),
+		metric("energy-solar-daily", daily.SolarKWh, "kilowatt-hours"),
+		metric("energy-load-daily", daily.LoadKWh, "kilowatt-hours"),
+		metric("energy-battery-charged-daily", daily.BatteryChargedKWh, "kilowatt-hours"),
+		metric("energy-battery-discharged-daily", daily.BatteryDischargedKWh, "kilowatt-hours"),
+		metric("energy-balance-daily", daily.BalanceKWh, "kilowatt-hours"),
+	}
+	if status.SelfConsumption != nil {
+		metrics = append(metrics, metric("self-consumption", *status.SelfConsumption, "percent"))
+	}
+	if daily.SelfConsumption != nil {
+		metrics = append(metrics, metric("self-consumption-daily", *daily.SelfConsumption, "percent"))
+	}
+	return metrics
+}
+
+// CreateCollectionFailureMetric creates a failure metric when the readings
+// cannot be joined
+func CreateCollectionFailureMetric() Metric {
+	return Metric{
+		Name:      "collection-failure",
+		Value:     1,
+		Unit:      "count",
+		Timestamp: time.Now().Unix(),
+	}
+}
@@ -0,0 +1,54 @@
+package system
+
+import (
+	"testing"
+)
+
+func TestConvertStatusToMetrics(t *testing.T) {
+	if got := ConvertStatusToMetrics(nil); len(got) != 0 {
+		t.Errorf("ConvertStatusToMetrics(nil) = %v, want none", got)
+	}
+
+	t.Run("leaves out self-consumption without solar", func(t *testing.T) {
+		status := newStatus(1000, 0, 1000, -80)
+		metrics := ConvertStatusToMetrics(status)
+
+		names := make(map[string]Metric, len(metrics))
+		for _, m := range metrics {
+			names[m.Name] = m
+			if m.Timestamp != 1000 {
+				t.Errorf("%s timestamp = %d, want 1000", m.Name, m.Timestamp)
+			}
+		}
+		if len(metrics) != 10 {
+			t.Errorf("got %d metrics, want 10", len(metrics))
+		}
+		if _, ok := names["self-consumption"]; ok {
+			t.Error("self-consumption published without solar")
+		}
+		if m := names["battery-discharge-power"]; m.Value != float64(80) || m.Unit != "watts" {
+			t.Errorf("battery-discharge-power = %+v, want 80 watts", m)
+		}
+	})
+
+	t.Run("publishes self-consumption once there is solar", func(t *testing.T) {
+		status := newStatus(1000, 500, 1000, 300)
+		selfConsumption := 40.0
+		status.Daily = DailyBalance{Energy: Energy{SolarKWh: 1, LoadKWh: 0.4}, BalanceKWh: 0.6, SelfConsumption: &selfConsumption}
+		metrics := ConvertStatusToMetrics(status)
+
+		names := make(map[string]Metric, len(metrics))
+		for _, m := range metrics {
+			names[m.Name] = m
+		}
+		if m := names["self-consumption"]; m.Value != float64(40) || m.Unit != "percent" {
+			t.Errorf("self-consumption = %+v, want 40 percent", m)
+		}
+		if m := names["self-consumption-daily"]; m.Value != float64(40) {
+			t.Errorf("self-consumption-daily = %+v, want 40", m)
+		}
+		if m := names["energy-balance-daily"]; m.Value != 0.6 || m.Unit != "kilowatt-hours" {
+			t.Errorf("energy-balance-daily = %+v, want 0.6 kilowatt-hours", m)
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package system
+
+import (
+	"sync"
+)
+
+// MockSource is a Source that returns a fixed snapshot.
+type MockSource struct {
+	mu       sync.Mutex
+	snapshot any
+}
+
+// Verify MockSource implements Source
+var _ Source = (*MockSource)(nil)
+
+func (m *MockSource) Snapshot() any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.snapshot
+}
+
+func (m *MockSource) set(snapshot any) {
+	m.mu.Lock()
+	m.snapshot = snapshot
+	m.mu.Unlock()
+}
+
+// MockMetricsCollector is a mock implementation of the MetricsCollector interface for testing.
+type MockMetricsCollector struct {
+	mu sync.RWMutex
+
+	// Call tracking
+	FailuresCount   int
+	SetMetricsCalls []*Status
+	Added           Energy
+}
+
+// Verify MockMetricsCollector implements MetricsCollector
+var _ MetricsCollector = (*MockMetricsCollector)(nil)
+
+func (m *MockMetricsCollector) IncrementFailures() {
+	m.mu.Lock()
+	m.FailuresCount++
+	m.mu.Unlock()
+}
+
+func (m *MockMetricsCollector) SetMetrics(status *Status, added Energy) {
+	m.mu.Lock()
+	m.SetMetricsCalls = append(m.SetMetricsCalls, status)
+	m.Added.add(added)
+	m.mu.Unlock()
+}
@@ -0,0 +1,72 @@
+package system
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type PrometheusCollector struct {
+	failures prometheus.Counter
+
+	solarPower      prometheus.Gauge
+	batteryPower    prometheus.Gauge
+	loadPower       prometheus.Gauge
+	selfConsumption prometheus.Gauge
+
+	solarKWh             prometheus.Counter
+	loadKWh              prometheus.Counter
+	batteryChargedKWh    prometheus.Counter
+	batteryDischargedKWh prometheus.Counter
+}
+
+func NewPrometheusCollector() *PrometheusCollector {
+	endpoint := &PrometheusCollector{
+		failures: promauto.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "join_failures",
+			Help:      "Number of cycles in which the charge controller and battery readings could not be joined.",
+		}),
+	}
+
+	// Initialize all metrics immediately to avoid race conditions
+	endpoint.initializeMetrics()
+
+	return endpoint
+}
+
+func (s *PrometheusCollector) IncrementFailures() {
+	s.failures.Inc()
+}
+
+func (s *PrometheusCollector) initializeMetrics() {
+	gauge := func(name, help string) prometheus.Gauge {
+		return promauto.NewGauge(prometheus.GaugeOpts{Namespace: namespace, Name: name, Help: help})
+	}
+	counter := func(name, help string) prometheus.Counter {
+		return promauto.NewCounter(prometheus.CounterOpts{Namespace: namespace, Name: name, Help: help})
+	}
+
+	s.solarPower = gauge("solar_power", "Charging power from the solar charge controller (W).")
+	s.batteryPower = gauge("battery_power", "Battery power, positive when charging (W).")
+	s.loadPower = gauge("load_power", "House load: solar power less battery power (W).")
+	s.selfConsumption = gauge("self_consumption", "Share of the solar power the load uses directly (%).")
+
+	s.solarKWh = counter("solar_kilowatt_hours_total", "Solar energy counted since the service started (kWh).")
+	s.loadKWh = counter("load_kilowatt_hours_total", "Load energy counted since the service started (kWh).")
+	s.batteryChargedKWh = counter("battery_charged_kilowatt_hours_total", "Energy into the battery counted since the service started (kWh).")
+	s.batteryDischargedKWh = counter("battery_discharged_kilowatt_hours_total", "Energy out of the battery counted since the service started (kWh).")
+}
+
+func (s *PrometheusCollector) SetMetrics(status *Status, added Energy) {
+	s.solarPower.Set(status.SolarPower)
+	s.batteryPower.Set(status.BatteryPower)
+	s.loadPower.Set(status.LoadPower)
+	if status.SelfConsumption != nil {
+		s.selfConsumption.Set(*status.SelfConsumption)
+	}
+
+	s.solarKWh.Add(added.SolarKWh)
+	s.loadKWh.Add(added.LoadKWh)
+	s.batteryChargedKWh.Add(added.BatteryChargedKWh)
+	s.batteryDischargedKWh.Add(added.BatteryDischargedKWh)
+}
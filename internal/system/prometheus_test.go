@@ -0,0 +1,41 @@
+package system
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Verify PrometheusCollector implements MetricsCollector
+var _ MetricsCollector = (*PrometheusCollector)(nil)
+
+// The collector registers with the global Prometheus registry via promauto,
+// so it can only be created once per test binary.
+func TestPrometheusCollector(t *testing.T) {
+	collector := NewPrometheusCollector()
+
+	collector.SetMetrics(newStatus(1000, 500, 1000, 300), Energy{SolarKWh: 0.3, BatteryChargedKWh: 0.1})
+	collector.SetMetrics(newStatus(1060, 0, 1060, -80), Energy{SolarKWh: 0.2, LoadKWh: 0.5, BatteryDischargedKWh: 0.05})
+	collector.IncrementFailures()
+
+	checks := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"solar_power", testutil.ToFloat64(collector.solarPower), 0},
+		{"battery_power", testutil.ToFloat64(collector.batteryPower), -80},
+		{"load_power", testutil.ToFloat64(collector.loadPower), 80},
+		{"self_consumption", testutil.ToFloat64(collector.selfConsumption), 40},
+		{"solar_kilowatt_hours_total", testutil.ToFloat64(collector.solarKWh), 0.5},
+		{"load_kilowatt_hours_total", testutil.ToFloat64(collector.loadKWh), 0.5},
+		{"battery_charged_kilowatt_hours_total", testutil.ToFloat64(collector.batteryChargedKWh), 0.1},
+		{"battery_discharged_kilowatt_hours_total", testutil.ToFloat64(collector.batteryDischargedKWh), 0.05},
+		{"join_failures", testutil.ToFloat64(collector.failures), 1},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
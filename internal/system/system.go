@@ -0,0 +1,375 @@
+// Package system combines the latest readings of the charge controller and
+// the battery into the system's energy balance: what the house draws, the
+// flow in and out of the battery, and how much of the solar the house uses
+// directly.
+package system
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-co-op/gocron"
+	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"github.com/lumberbarons/solar-controller/internal/controllers/epever"
+	"github.com/lumberbarons/solar-controller/internal/controllers/voltgo"
+	"github.com/lumberbarons/solar-controller/internal/energy"
+	"github.com/lumberbarons/solar-controller/internal/health"
+	"github.com/lumberbarons/solar-controller/internal/publish"
+	"github.com/lumberbarons/solar-controller/internal/state"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	namespace = "system"
+
+	defaultMaxSkew = 2 * time.Minute
+)
+
+type Configuration struct {
+	Enabled       bool `yaml:"enabled"`
+	PublishPeriod int  `yaml:"publishPeriod"`
+
+	// MaxSkew is how far apart the charge controller and battery readings
+	// may be and still be joined, as a duration string (default: 2m)
+	MaxSkew string `yaml:"maxSkew"`
+
+	// OfflineAfter is how many cycles in a row without a join publish the
+	// balance as offline (default: 3)
+	OfflineAfter int `yaml:"offlineAfter"`
+}
+
+// Validate checks the configuration for errors. Only called when enabled.
+func (c *Configuration) Validate() error {
+	if c.MaxSkew != "" {
+		skew, err := time.ParseDuration(c.MaxSkew)
+		if err != nil {
+			return fmt.Errorf("max skew: %w", err)
+		}
+		if skew <= 0 {
+			return errors.New("max skew must be positive")
+		}
+	}
+	return nil
+}
+
+// GetMaxSkew returns the configured skew or the default (2m).
+func (c *Configuration) GetMaxSkew() time.Duration {
+	if c.MaxSkew == "" {
+		return defaultMaxSkew
+	}
+	skew, err := time.ParseDuration(c.MaxSkew)
+	if err != nil {
+		return defaultMaxSkew
+	}
+	return skew
+}
+
+type Controller struct {
+	charger             Source
+	battery             Source
+	publisher           publish.MessagePublisher
+	prometheusCollector MetricsCollector
+	scheduler           *gocron.Scheduler
+	deviceID            string
+	health              *health.Tracker
+	maxSkew             time.Duration
+	publishPeriod       time.Duration
+	dataDir             string
+
+	last         controllers.Latest[Status]
+	balanceMutex sync.Mutex
+	counter      *balanceCounter
+	balanceSaved state.Throttle
+}
+
+// NewController creates a new system controller with dependency injection for testing.
+// For production use, call NewControllerFromConfig instead.
+//
+// The balance is saved under dataDir; empty keeps it in memory only.
+func NewController(
+	charger, battery Source,
+	publisher publish.MessagePublisher,
+	prometheusCollector MetricsCollector,
+	deviceID string,
+	publishPeriod int,
+	offlineAfter int,
+	maxSkew time.Duration,
+	dataDir string,
+) (*Controller, error) {
+	if charger == nil || battery == nil {
+		return &Controller{}, nil
+	}
+
+	// Default device ID if not provided
+	if deviceID == "" {
+		deviceID = "controller-1"
+	}
+
+	s := gocron.NewScheduler(time.UTC)
+
+	period := time.Duration(publishPeriod) * time.Second
+	controller := &Controller{
+		charger:             charger,
+		battery:             battery,
+		publisher:           publisher,
+		prometheusCollector: prometheusCollector,
+		deviceID:            deviceID,
+		health:              health.NewTracker(publisher, deviceID, namespace, period, offlineAfter),
+		maxSkew:             maxSkew,
+		publishPeriod:       period,
+		dataDir:             dataDir,
+		counter:             loadBalance(dataDir),
+		scheduler:           s,
+	}
+
+	_, err := s.Every(publishPeriod).Seconds().Do(controller.collectAndPublish)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start system publisher %w", err)
+	}
+
+	s.StartAsync()
+
+	return controller, nil
+}
+
+// newControllerForTest creates a Controller without starting the scheduler.
+// This allows tests to call collectAndPublish synchronously without racing.
+func newControllerForTest(
+	charger, battery Source,
+	publisher publish.MessagePublisher,
+	prometheusCollector MetricsCollector,
+	deviceID string,
+) *Controller {
+	if deviceID == "" {
+		deviceID = "controller-1"
+	}
+	return &Controller{
+		charger:             charger,
+		battery:             battery,
+		publisher:           publisher,
+		prometheusCollector: prometheusCollector,
+		deviceID:            deviceID,
+		health:              health.NewTracker(publisher, deviceID, namespace, 0, 0),
+		maxSkew:             defaultMaxSkew,
+		publishPeriod:       60 * time.Second,
+		counter:             &balanceCounter{},
+	}
+}
+
+// NewControllerFromConfig creates a new system controller from configuration.
+// This is the production entry point that creates all concrete dependencies.
+// charger and battery are the epever and voltgo controllers; configuration
+// validation has made sure both are enabled.
+func NewControllerFromConfig(config Configuration, charger, battery Source, publisher publish.MessagePublisher, deviceID, dataDir string) (*Controller, error) {
+	if !config.Enabled {
+		log.Info("system balance disabled via configuration")
+		return &Controller{}, nil
+	}
+
+	log.Infof("joining charge controller and battery readings up to %s apart", config.GetMaxSkew())
+
+	return NewController(
+		charger,
+		battery,
+		publisher,
+		NewPrometheusCollector(),
+		deviceID,
+		config.PublishPeriod,
+		config.OfflineAfter,
+		config.GetMaxSkew(),
+		dataDir,
+	)
+}
+
+// loadBalance reads the saved balance from dataDir.
+func loadBalance(dataDir string) *balanceCounter {
+	counter := &balanceCounter{}
+	if !state.Restore(dataDir, balanceFileName, "system balance", counter) {
+		return &balanceCounter{}
+	}
+	return counter
+}
+
+// saveBalance writes the balance, at most every state.SaveInterval unless
+// forced.
+func (s *Controller) saveBalance(force bool) {
+	if s.dataDir == "" || !s.balanceSaved.Due(time.Now(), force) {
+		return
+	}
+
+	s.balanceMutex.Lock()
+	counter := *s.counter
+	s.balanceMutex.Unlock()
+
+	if err := state.Save(filepath.Join(s.dataDir, balanceFileName), counter); err != nil {
+		log.Errorf("failed to save system balance: %s", err)
+	}
+}
+
+// balanceGap is the longest interval between samples that is counted.
+func (s *Controller) balanceGap() time.Duration {
+	return energy.MaxGap(s.publishPeriod)
+}
+
+// join takes the latest readings of both sources and works out the power
+// flows, or says why it cannot. With several voltgo packs, the battery is
+// the bank.
+func (s *Controller) join() (*Status, error) {
+	charger, _ := s.charger.Snapshot().(*epever.ControllerStatus)
+	if charger == nil {
+		return nil, errors.New("no charge controller reading yet")
+	}
+	if charger.Quality["chargingPower"] == epever.QualityFailed {
+		return nil, errors.New("the charge controller has not read its charging power yet")
+	}
+
+	battery, _ := s.battery.Snapshot().(*voltgo.Snapshot)
+	var batteryTimestamp int64
+	var batteryPower float64
+	switch {
+	case battery != nil && battery.Bank != nil:
+		batteryTimestamp, batteryPower = battery.Bank.Timestamp, battery.Bank.Power
+	case battery != nil && battery.BatteryStatus != nil:
+		batteryTimestamp = battery.Timestamp
+		batteryPower = battery.Voltage * battery.Current
+	default:
+		return nil, errors.New("no battery reading yet")
+	}
+
+	skew := time.Duration(charger.Timestamp-batteryTimestamp) * time.Second
+	if skew.Abs() > s.maxSkew {
+		return nil, fmt.Errorf("the charge controller and battery readings are %s apart, more than %s", skew.Abs(), s.maxSkew)
+	}
+	return newStatus(charger.Timestamp, float64(charger.ChargingPower), batteryTimestamp, batteryPower), nil
+}
+
+func (s *Controller) collectAndPublish() {
+	log.Debug("joining readings for system balance")
+
+	status, err := s.join()
+	if err != nil {
+		log.Warnf("failed to work out the system balance: %s", err)
+		s.prometheusCollector.IncrementFailures()
+		s.health.Failure(err)
+
+		// Publish failure metric to message broker
+		failureMetric := CreateCollectionFailureMetric()
+		payload, err := failureMetric.ToJSON()
+		if err != nil {
+			log.Errorf("failed to marshal failure metric: %s", err)
+			return
+		}
+
+		topicSuffix := fmt.Sprintf("%s/%s/%s", s.deviceID, namespace, failureMetric.Name)
+		s.publisher.Publish(topicSuffix, payload)
+		log.Debugf("published failure metric to %s", topicSuffix)
+
+		return
+	}
+
+	s.balanceMutex.Lock()
+	last := s.last.Last()
+	if last != nil && last.ChargerTimestamp == status.ChargerTimestamp && last.BatteryTimestamp == status.BatteryTimestamp {
+		// Neither source has collected since the last cycle
+		s.balanceMutex.Unlock()
+		log.Debug("no new readings for system balance")
+		return
+	}
+	added := s.counter.add(status, s.balanceGap())
+	status.Daily = s.counter.daily()
+	s.last.Set(status)
+	s.balanceMutex.Unlock()
+
+	s.prometheusCollector.SetMetrics(status, added)
+	s.saveBalance(false)
+
+	// Convert status to individual metrics
+	metrics := ConvertStatusToMetrics(status)
+
+	// Publish each metric individually
+	for _, metric := range metrics {
+		payload, err := metric.ToJSON()
+		if err != nil {
+			log.Errorf("failed to marshal metric %s for publishing: %s", metric.Name, err)
+			continue
+		}
+
+		// Topic format: {deviceId}/system/{metric-name}
+		topicSuffix := fmt.Sprintf("%s/%s/%s", s.deviceID, namespace, metric.Name)
+		s.publisher.Publish(topicSuffix, payload)
+
+		log.Debugf("published metric %s to %s", metric.Name, topicSuffix)
+	}
+
+	s.health.Success()
+
+	log.Debug("system balance done")
+}
+
+// StatusGet returns the power flows of the last join and the day's balance.
+func (s *Controller) StatusGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := s.last.Last()
+
+		if status == nil {
+			c.JSON(http.StatusNoContent, gin.H{})
+			return
+		}
+		c.JSON(http.StatusOK, struct {
+			*Status
+			Health health.Health `json:"health"`
+		}{status, s.Health()})
+	}
+}
+
+// Name returns the controller's name, which its endpoint is.
+func (s *Controller) Name() string {
+	return namespace
+}
+
+// Type returns the kind of controller: derived from others rather than read
+// from equipment.
+func (s *Controller) Type() string {
+	return controllers.TypeSystem
+}
+
+// Health reports how fresh the last join is.
+func (s *Controller) Health() health.Health {
+	if s.health == nil {
+		return health.Health{Availability: health.Unknown}
+	}
+	return s.health.Health()
+}
+
+// Snapshot returns the last status, or nil before the first join.
+func (s *Controller) Snapshot() any {
+	return s.last.Snapshot()
+}
+
+func (s *Controller) RegisterEndpoints(r *gin.Engine) {
+	if s.charger == nil {
+		return
+	}
+
+	r.GET(fmt.Sprintf("/api/%s", namespace), s.StatusGet())
+}
+
+func (s *Controller) Enabled() bool {
+	return s.charger != nil
+}
+
+func (s *Controller) Close() error {
+	if s.scheduler != nil {
+		s.scheduler.Stop()
+		log.Debug("system scheduler stopped")
+	}
+	if s.charger != nil {
+		s.saveBalance(true)
+	}
+	return nil
+}
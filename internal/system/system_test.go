@@ -0,0 +1,293 @@
+package system
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lumberbarons/solar-controller/internal/controllers"
+	"github.com/lumberbarons/solar-controller/internal/controllers/epever"
+	"github.com/lumberbarons/solar-controller/internal/controllers/voltgo"
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+)
+
+func chargerReading(timestamp int64, chargingPower float32) *epever.ControllerStatus {
+	return &epever.ControllerStatus{Timestamp: timestamp, ChargingPower: chargingPower}
+}
+
+func batteryReading(timestamp int64, voltage, current float64) *voltgo.Snapshot {
+	return &voltgo.Snapshot{BatteryStatus: &voltgo.BatteryStatus{Timestamp: timestamp, Voltage: voltage, Current: current}}
+}
+
+func newTestController(t *testing.T) (*Controller, *MockSource, *MockSource, *testutil.MockMessagePublisher, *MockMetricsCollector) {
+	t.Helper()
+
+	charger, battery := &MockSource{}, &MockSource{}
+	publisher := &testutil.MockMessagePublisher{}
+	metrics := &MockMetricsCollector{}
+	return newControllerForTest(charger, battery, publisher, metrics, "test-device-1"), charger, battery, publisher, metrics
+}
+
+func publishedTopics(publisher *testutil.MockMessagePublisher) map[string]string {
+	topics := make(map[string]string, len(publisher.PublishCalls))
+	for _, call := range publisher.PublishCalls {
+		topics[call.TopicSuffix] = call.Payload
+	}
+	return topics
+}
+
+func TestController_CollectAndPublish(t *testing.T) {
+	now := time.Now().Unix()
+
+	t.Run("joins the readings and publishes the balance", func(t *testing.T) {
+		controller, charger, battery, publisher, metrics := newTestController(t)
+		charger.set(chargerReading(now, 500))
+		battery.set(batteryReading(now-20, 13.0, 20))
+
+		controller.collectAndPublish()
+
+		if len(metrics.SetMetricsCalls) != 1 {
+			t.Fatalf("SetMetrics calls = %d, want 1", len(metrics.SetMetricsCalls))
+		}
+		status := metrics.SetMetricsCalls[0]
+		if status.SolarPower != 500 || status.BatteryPower != 260 || status.LoadPower != 240 {
+			t.Errorf("solar, battery, load = %v, %v, %v, want 500, 260, 240", status.SolarPower, status.BatteryPower, status.LoadPower)
+		}
+
+		topics := publishedTopics(publisher)
+		for _, metric := range []string{"solar-power", "battery-charge-power", "battery-discharge-power", "load-power", "self-consumption", "energy-balance-daily", "availability"} {
+			if _, ok := topics["test-device-1/system/"+metric]; !ok {
+				t.Errorf("nothing published to test-device-1/system/%s", metric)
+			}
+		}
+
+		var load MetricPayload
+		if err := json.Unmarshal([]byte(topics["test-device-1/system/load-power"]), &load); err != nil {
+			t.Fatalf("load-power payload: %v", err)
+		}
+		if load.Value != float64(240) || load.Unit != "watts" || load.Timestamp != now {
+			t.Errorf("load-power payload = %+v, want 240 watts at the later reading", load)
+		}
+	})
+
+	t.Run("counts energy between joins", func(t *testing.T) {
+		controller, charger, battery, _, metrics := newTestController(t)
+		charger.set(chargerReading(now-60, 360))
+		battery.set(batteryReading(now-60, 12.0, 10))
+		controller.collectAndPublish()
+
+		charger.set(chargerReading(now, 360))
+		battery.set(batteryReading(now, 12.0, 10))
+		controller.collectAndPublish()
+
+		// A minute of 360W solar and 240W load
+		if !almostEqual(metrics.Added.SolarKWh, 0.006) || !almostEqual(metrics.Added.LoadKWh, 0.004) {
+			t.Errorf("added solar, load = %v, %v kWh, want 0.006, 0.004", metrics.Added.SolarKWh, metrics.Added.LoadKWh)
+		}
+	})
+
+	t.Run("readings too far apart are not joined", func(t *testing.T) {
+		controller, charger, battery, publisher, metrics := newTestController(t)
+		charger.set(chargerReading(now, 500))
+		battery.set(batteryReading(now-300, 13.0, 20))
+
+		controller.collectAndPublish()
+
+		if metrics.FailuresCount != 1 || len(metrics.SetMetricsCalls) != 0 {
+			t.Errorf("failures, SetMetrics calls = %d, %d, want 1, 0", metrics.FailuresCount, len(metrics.SetMetricsCalls))
+		}
+		if _, ok := publishedTopics(publisher)["test-device-1/system/collection-failure"]; !ok {
+			t.Error("no collection-failure published")
+		}
+		if h := controller.Health(); h.ConsecutiveFailures != 1 || h.LastError == "" {
+			t.Errorf("Health() = %+v, want one failure with the reason", h)
+		}
+	})
+
+	t.Run("waits for both readings", func(t *testing.T) {
+		failed := &epever.ControllerStatus{Timestamp: now, Quality: map[string]epever.Quality{"chargingPower": epever.QualityFailed}}
+		tests := []struct {
+			name             string
+			charger, battery any
+		}{
+			{"no charger reading", nil, batteryReading(now, 13, 1)},
+			{"charging power never read", failed, batteryReading(now, 13, 1)},
+			{"no battery reading", chargerReading(now, 100), nil},
+			{"no battery in the snapshot", chargerReading(now, 100), &voltgo.Snapshot{}},
+		}
+		for _, tt := range tests {
+			controller, charger, battery, _, metrics := newTestController(t)
+			charger.set(tt.charger)
+			battery.set(tt.battery)
+
+			controller.collectAndPublish()
+
+			if metrics.FailuresCount != 1 {
+				t.Errorf("%s: FailuresCount = %d, want 1", tt.name, metrics.FailuresCount)
+			}
+		}
+	})
+
+	t.Run("joins the bank for several batteries", func(t *testing.T) {
+		controller, charger, battery, _, metrics := newTestController(t)
+		charger.set(chargerReading(now, 500))
+		battery.set(&voltgo.Snapshot{
+			BatteryStatus: &voltgo.BatteryStatus{Timestamp: now, Voltage: 13, Current: 1},
+			Bank:          &voltgo.BankStatus{Timestamp: now, Power: -100},
+		})
+
+		controller.collectAndPublish()
+
+		if len(metrics.SetMetricsCalls) != 1 || metrics.SetMetricsCalls[0].BatteryPower != -100 {
+			t.Errorf("SetMetrics calls = %v, want one with the bank's -100W", metrics.SetMetricsCalls)
+		}
+	})
+
+	t.Run("does not publish the same join twice", func(t *testing.T) {
+		controller, charger, battery, publisher, metrics := newTestController(t)
+		charger.set(chargerReading(now, 500))
+		battery.set(batteryReading(now, 13.0, 20))
+
+		controller.collectAndPublish()
+		published := len(publisher.PublishCalls)
+		controller.collectAndPublish()
+
+		if len(metrics.SetMetricsCalls) != 1 || len(publisher.PublishCalls) != published {
+			t.Errorf("a cycle without new readings published again")
+		}
+		if metrics.FailuresCount != 0 {
+			t.Errorf("FailuresCount = %d, want 0 for a cycle without new readings", metrics.FailuresCount)
+		}
+	})
+}
+
+func TestController_Endpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	controller, charger, battery, _, _ := newTestController(t)
+	if controller.Name() != "system" || controller.Type() != controllers.TypeSystem {
+		t.Errorf("Name(), Type() = %q, %q, want system, system", controller.Name(), controller.Type())
+	}
+
+	router := gin.New()
+	controller.RegisterEndpoints(router)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/system", nil))
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("before the first join: status = %d, want 204", recorder.Code)
+	}
+	if got := controller.Snapshot(); got != nil {
+		t.Errorf("Snapshot() before the first join = %v, want nil", got)
+	}
+
+	now := time.Now().Unix()
+	charger.set(chargerReading(now, 500))
+	battery.set(batteryReading(now, 13.0, 20))
+	controller.collectAndPublish()
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/system", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("after a join: status = %d, want 200", recorder.Code)
+	}
+
+	var status Status
+	if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Failed to unmarshal status: %v", err)
+	}
+	if status.LoadPower != 240 || status.Daily.Day == "" {
+		t.Errorf("status = %+v, want a 240W load and the day's balance", status)
+	}
+	if got, ok := controller.Snapshot().(*Status); !ok || got.LoadPower != 240 {
+		t.Errorf("Snapshot() = %v, want the last status", controller.Snapshot())
+	}
+}
+
+func TestController_Balance(t *testing.T) {
+	t.Run("saves on close and carries on from it", func(t *testing.T) {
+		dataDir := t.TempDir()
+		controller, charger, battery, _, _ := newTestController(t)
+		controller.dataDir = dataDir
+
+		now := time.Now().Unix()
+		charger.set(chargerReading(now-60, 360))
+		battery.set(batteryReading(now-60, 12.0, 10))
+		controller.collectAndPublish()
+		charger.set(chargerReading(now, 360))
+		battery.set(batteryReading(now, 12.0, 10))
+		controller.collectAndPublish()
+		if err := controller.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+
+		counter := loadBalance(dataDir)
+		if !almostEqual(counter.Daily.SolarKWh, 0.006) || counter.Last == nil || counter.Last.Timestamp != now {
+			t.Errorf("loaded balance = %+v, want the minute of solar and the last sample", counter)
+		}
+	})
+
+	t.Run("an unreadable file is set aside", func(t *testing.T) {
+		dataDir := t.TempDir()
+		path := filepath.Join(dataDir, balanceFileName)
+		if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		if counter := loadBalance(dataDir); counter.Last != nil || counter.Day != "" {
+			t.Errorf("loaded %+v from an unreadable file, want nothing", counter)
+		}
+		if _, err := os.Stat(path + ".bad"); err != nil {
+			t.Errorf("unreadable file not set aside: %v", err)
+		}
+	})
+}
+
+func TestNewControllerFromConfig_Disabled(t *testing.T) {
+	controller, err := NewControllerFromConfig(Configuration{Enabled: false}, &MockSource{}, &MockSource{}, &testutil.MockMessagePublisher{}, "", "")
+	if err != nil {
+		t.Fatalf("NewControllerFromConfig() error = %v", err)
+	}
+	if controller.Enabled() {
+		t.Error("disabled controller reports enabled")
+	}
+
+	router := gin.New()
+	controller.RegisterEndpoints(router)
+	if len(router.Routes()) != 0 {
+		t.Errorf("disabled controller registered %d routes", len(router.Routes()))
+	}
+	if h := controller.Health(); h.Availability != "unknown" {
+		t.Errorf("Health() = %+v, want unknown", h)
+	}
+	if err := controller.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+func TestConfiguration(t *testing.T) {
+	tests := []struct {
+		maxSkew  string
+		wantErr  bool
+		wantSkew time.Duration
+	}{
+		{"", false, 2 * time.Minute},
+		{"45s", false, 45 * time.Second},
+		{"soon", true, 2 * time.Minute},
+		{"-1m", true, -time.Minute},
+	}
+	for _, tt := range tests {
+		config := Configuration{MaxSkew: tt.maxSkew}
+		if err := config.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%q) error = %v, wantErr %v", tt.maxSkew, err, tt.wantErr)
+		}
+		if got := config.GetMaxSkew(); got != tt.wantSkew {
+			t.Errorf("GetMaxSkew(%q) = %s, want %s", tt.maxSkew, got, tt.wantSkew)
+		}
+	}
+}
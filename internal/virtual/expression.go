@@ -0,0 +1,369 @@
+package virtual
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Input is a published metric an expression reads, written in the expression
+// as namespace.metric_name. Dashes in the metric name are written as
+// underscores, since a dash would be a minus.
+type Input struct {
+	Namespace string
+	Metric    string
+}
+
+func (i Input) String() string {
+	return i.Namespace + "." + i.Metric
+}
+
+// node is one step of a parsed expression.
+type node interface {
+	eval(values map[Input]float64) (float64, error)
+}
+
+type number float64
+
+func (n number) eval(map[Input]float64) (float64, error) {
+	return float64(n), nil
+}
+
+type reference Input
+
+func (r reference) eval(values map[Input]float64) (float64, error) {
+	value, ok := values[Input(r)]
+	if !ok {
+		return 0, fmt.Errorf("no value for %s", Input(r))
+	}
+	return value, nil
+}
+
+type negation struct {
+	operand node
+}
+
+func (n negation) eval(values map[Input]float64) (float64, error) {
+	value, err := n.operand.eval(values)
+	return -value, err
+}
+
+type binary struct {
+	op          byte
+	left, right node
+}
+
+func (b binary) eval(values map[Input]float64) (float64, error) {
+	left, err := b.left.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	right, err := b.right.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	switch b.op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	default:
+		if right == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return left / right, nil
+	}
+}
+
+type call struct {
+	function string
+	args     []node
+}
+
+// functions are those an expression may call.
+var functions = map[string]bool{"abs": true, "min": true, "max": true}
+
+func (c call) eval(values map[Input]float64) (float64, error) {
+	args := make([]float64, len(c.args))
+	for i, arg := range c.args {
+		value, err := arg.eval(values)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = value
+	}
+
+	result := args[0]
+	for _, arg := range args[1:] {
+		if c.function == "min" {
+			result = math.Min(result, arg)
+		} else {
+			result = math.Max(result, arg)
+		}
+	}
+	if c.function == "abs" {
+		result = math.Abs(result)
+	}
+	return result, nil
+}
+
+// Expression is a parsed virtual metric expression: numbers, inputs, + - * /,
+// parentheses and the functions abs, min and max.
+type Expression struct {
+	root   node
+	inputs []Input
+}
+
+// Inputs returns the metrics the expression reads, in the order they first
+// appear.
+func (e *Expression) Inputs() []Input {
+	return e.inputs
+}
+
+// Evaluate computes the expression from the inputs' values. Every input must
+// have a value; a missing one is an error naming all that are missing.
+func (e *Expression) Evaluate(values map[Input]float64) (float64, error) {
+	var missing []string
+	for _, input := range e.inputs {
+		if _, ok := values[input]; !ok {
+			missing = append(missing, input.String())
+		}
+	}
+	if len(missing) > 0 {
+		return 0, fmt.Errorf("no value for %s", strings.Join(missing, ", "))
+	}
+
+	value, err := e.root.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("result is %v", value)
+	}
+	return value, nil
+}
+
+// Parse parses an expression such as
+// "epever.charging_power / epever.array_power * 100".
+func Parse(text string) (*Expression, error) {
+	p := &parser{text: text}
+	p.next()
+
+	root, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if p.token != tokenEnd {
+		return nil, p.errorf("unexpected %q", p.value)
+	}
+	return &Expression{root: root, inputs: p.inputs}, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenNumber
+	tokenName
+	tokenOperator
+	tokenInvalid
+)
+
+// parser is a recursive descent parser over the usual precedence: unary
+// minus, then * and /, then + and -.
+type parser struct {
+	text string
+	pos  int
+
+	token tokenKind
+	value string
+	start int
+
+	inputs []Input
+	seen   map[Input]bool
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at %d: %s", p.start+1, fmt.Sprintf(format, args...))
+}
+
+// next reads the next token into token and value.
+func (p *parser) next() {
+	for p.pos < len(p.text) && p.text[p.pos] == ' ' {
+		p.pos++
+	}
+	p.start = p.pos
+	if p.pos == len(p.text) {
+		p.token, p.value = tokenEnd, ""
+		return
+	}
+
+	c := p.text[p.pos]
+	switch {
+	case isDigit(c) || c == '.':
+		for p.pos < len(p.text) && (isDigit(p.text[p.pos]) || p.text[p.pos] == '.') {
+			p.pos++
+		}
+		p.token = tokenNumber
+	case isNameChar(c):
+		// A name runs on through the dot between namespace and metric
+		for p.pos < len(p.text) && (isNameChar(p.text[p.pos]) || isDigit(p.text[p.pos]) || p.text[p.pos] == '.') {
+			p.pos++
+		}
+		p.token = tokenName
+	case strings.IndexByte("+-*/(),", c) >= 0:
+		p.pos++
+		p.token = tokenOperator
+	default:
+		p.pos++
+		p.token = tokenInvalid
+	}
+	p.value = p.text[p.start:p.pos]
+}
+
+func (p *parser) expression() (node, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.token == tokenOperator && (p.value == "+" || p.value == "-") {
+		op := p.value[0]
+		p.next()
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) term() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.token == tokenOperator && (p.value == "*" || p.value == "/") {
+		op := p.value[0]
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (node, error) {
+	if p.token == tokenOperator && p.value == "-" {
+		p.next()
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return negation{operand: operand}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	switch p.token {
+	case tokenEnd:
+		return nil, p.errorf("expression ends early")
+	case tokenNumber:
+		value, err := strconv.ParseFloat(p.value, 64)
+		if err != nil {
+			return nil, p.errorf("bad number %q", p.value)
+		}
+		p.next()
+		return number(value), nil
+	case tokenName:
+		name := p.value
+		p.next()
+		if p.token == tokenOperator && p.value == "(" {
+			return p.call(name)
+		}
+		return p.reference(name)
+	case tokenOperator:
+		if p.value == "(" {
+			p.next()
+			inner, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			if p.token != tokenOperator || p.value != ")" {
+				return nil, p.errorf("missing )")
+			}
+			p.next()
+			return inner, nil
+		}
+	}
+	return nil, p.errorf("unexpected %q", p.value)
+}
+
+func (p *parser) call(function string) (node, error) {
+	if !functions[function] {
+		return nil, p.errorf("unknown function %s", function)
+	}
+	p.next()
+
+	var args []node
+	for {
+		arg, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.token == tokenOperator && p.value == "," {
+			p.next()
+			continue
+		}
+		if p.token != tokenOperator || p.value != ")" {
+			return nil, p.errorf("missing ) after the arguments of %s", function)
+		}
+		p.next()
+		break
+	}
+
+	switch {
+	case function == "abs" && len(args) != 1:
+		return nil, errors.New("abs takes one argument")
+	case function != "abs" && len(args) < 2:
+		return nil, fmt.Errorf("%s takes at least two arguments", function)
+	}
+	return call{function: function, args: args}, nil
+}
+
+// reference parses namespace.metric_name into an input.
+func (p *parser) reference(name string) (node, error) {
+	controller, metric, ok := strings.Cut(name, ".")
+	if !ok || controller == "" || metric == "" || strings.Contains(metric, ".") {
+		return nil, fmt.Errorf("%q is not a metric: write it as namespace.metric_name, such as epever.charging_power", name)
+	}
+	if controller == namespace {
+		return nil, fmt.Errorf("%s: virtual metrics cannot read other virtual metrics", name)
+	}
+
+	input := Input{Namespace: controller, Metric: strings.ReplaceAll(metric, "_", "-")}
+	if !p.seen[input] {
+		if p.seen == nil {
+			p.seen = make(map[Input]bool)
+		}
+		p.seen[input] = true
+		p.inputs = append(p.inputs, input)
+	}
+	return reference(input), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
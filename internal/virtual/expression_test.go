@@ -0,0 +1,117 @@
+package virtual
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpression_Evaluate(t *testing.T) {
+	values := map[Input]float64{
+		{Namespace: "epever", Metric: "charging-power"}:  475,
+		{Namespace: "epever", Metric: "array-power"}:     500,
+		{Namespace: "epever", Metric: "array-voltage"}:   36.5,
+		{Namespace: "voltgo", Metric: "battery-voltage"}: 13.3,
+		{Namespace: "voltgo", Metric: "battery-current"}: -25,
+	}
+
+	tests := []struct {
+		expression string
+		want       float64
+	}{
+		{"epever.charging_power / epever.array_power * 100", 95},
+		{"epever.array_voltage - voltgo.battery_voltage", 23.2},
+		{"abs(voltgo.battery_current) / 100", 0.25},
+		{"-voltgo.battery_current", 25},
+		{"2 + 3 * 4", 14},
+		{"(2 + 3) * 4", 20},
+		{"10 - 4 - 3", 3},
+		{"12 / 3 / 2", 2},
+		{"-(1 - 3)", 2},
+		{"min(epever.array_power, 400, 450)", 400},
+		{"max(voltgo.battery_current, 0)", 0},
+	}
+	for _, tt := range tests {
+		expression, err := Parse(tt.expression)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.expression, err)
+			continue
+		}
+		got, err := expression.Evaluate(values)
+		if err != nil {
+			t.Errorf("%q: Evaluate() error = %v", tt.expression, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%q = %v, want %v", tt.expression, got, tt.want)
+		}
+	}
+}
+
+func TestExpression_EvaluateErrors(t *testing.T) {
+	values := map[Input]float64{
+		{Namespace: "epever", Metric: "charging-power"}: 0,
+		{Namespace: "epever", Metric: "array-power"}:    0,
+	}
+
+	tests := []struct {
+		expression string
+		wantErr    string
+	}{
+		{"epever.charging_power / epever.array_power", "division by zero"},
+		{"epever.array_power + voltgo.battery_soc + onewire.box_temp", "no value for voltgo.battery-soc, onewire.box-temp"},
+	}
+	for _, tt := range tests {
+		expression, err := Parse(tt.expression)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.expression, err)
+		}
+		if _, err := expression.Evaluate(values); err == nil || err.Error() != tt.wantErr {
+			t.Errorf("%q: Evaluate() error = %v, want %q", tt.expression, err, tt.wantErr)
+		}
+	}
+}
+
+func TestExpression_Inputs(t *testing.T) {
+	expression, err := Parse("epever.array_power - epever.charging_power + epever.array_power / canbms.battery_soc")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := []Input{
+		{Namespace: "epever", Metric: "array-power"},
+		{Namespace: "epever", Metric: "charging-power"},
+		{Namespace: "canbms", Metric: "battery-soc"},
+	}
+	if got := expression.Inputs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Inputs() = %v, want %v", got, want)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		expression string
+		wantErr    string
+	}{
+		{"", "ends early"},
+		{"epever.array_power +", "ends early"},
+		{"(1 + 2", "missing )"},
+		{"1 + 2)", `unexpected ")"`},
+		{"1 % 2", `unexpected "%"`},
+		{"1..2", "bad number"},
+		{"array_power", "not a metric"},
+		{"epever.array.power", "not a metric"},
+		{"virtual.mppt_efficiency * 2", "cannot read other virtual metrics"},
+		{"sqrt(4)", "unknown function sqrt"},
+		{"abs(1, 2)", "abs takes one argument"},
+		{"min(1)", "min takes at least two arguments"},
+		{"max(1, 2", "missing ) after the arguments of max"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.expression)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Parse(%q) error = %v, want one containing %q", tt.expression, err, tt.wantErr)
+		}
+	}
+}
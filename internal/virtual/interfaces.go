@@ -0,0 +1,12 @@
+package virtual
+
+// MetricsCollector defines the interface for collecting and exposing metrics.
+// This abstraction allows for testing without the Prometheus global registry.
+type MetricsCollector interface {
+	// IncrementFailures increments the counter of evaluations of the named
+	// virtual metric that failed.
+	IncrementFailures(name string)
+
+	// SetValue sets the named virtual metric's gauge.
+	SetValue(name string, value float64)
+}
@@ -0,0 +1,35 @@
+package virtual
+
+import (
+	"sync"
+)
+
+// MockMetricsCollector is a mock implementation of the MetricsCollector interface for testing.
+type MockMetricsCollector struct {
+	mu sync.RWMutex
+
+	// Call tracking
+	Failures map[string]int
+	Values   map[string]float64
+}
+
+// Verify MockMetricsCollector implements MetricsCollector
+var _ MetricsCollector = (*MockMetricsCollector)(nil)
+
+func (m *MockMetricsCollector) IncrementFailures(name string) {
+	m.mu.Lock()
+	if m.Failures == nil {
+		m.Failures = make(map[string]int)
+	}
+	m.Failures[name]++
+	m.mu.Unlock()
+}
+
+func (m *MockMetricsCollector) SetValue(name string, value float64) {
+	m.mu.Lock()
+	if m.Values == nil {
+		m.Values = make(map[string]float64)
+	}
+	m.Values[name] = value
+	m.mu.Unlock()
+}
@@ -0,0 +1,46 @@
+package virtual
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// PrometheusCollector exposes each virtual metric as a gauge of its own,
+// virtual_{name} with the dashes as underscores, as a native metric would be.
+type PrometheusCollector struct {
+	failures *prometheus.CounterVec
+	values   map[string]prometheus.Gauge
+}
+
+func NewPrometheusCollector(definitions []Definition) *PrometheusCollector {
+	endpoint := &PrometheusCollector{
+		failures: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "evaluation_failures",
+			Help:      "Number of times a virtual metric could not be computed.",
+		}, []string{"metric"}),
+		values: make(map[string]prometheus.Gauge, len(definitions)),
+	}
+
+	for _, definition := range definitions {
+		endpoint.values[definition.Name] = promauto.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      strings.ReplaceAll(definition.Name, "-", "_"),
+			Help:      definition.Expression + " (" + definition.Unit + ").",
+		})
+	}
+
+	return endpoint
+}
+
+func (v *PrometheusCollector) IncrementFailures(name string) {
+	v.failures.WithLabelValues(name).Inc()
+}
+
+func (v *PrometheusCollector) SetValue(name string, value float64) {
+	if gauge, ok := v.values[name]; ok {
+		gauge.Set(value)
+	}
+}
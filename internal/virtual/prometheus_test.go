@@ -0,0 +1,27 @@
+package virtual
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Verify PrometheusCollector implements MetricsCollector
+var _ MetricsCollector = (*PrometheusCollector)(nil)
+
+// The collector registers with the global Prometheus registry via promauto,
+// so it can only be created once per test binary.
+func TestPrometheusCollector(t *testing.T) {
+	collector := NewPrometheusCollector([]Definition{efficiency})
+
+	collector.SetValue("mppt-efficiency", 95)
+	collector.SetValue("not-defined", 1)
+	collector.IncrementFailures("mppt-efficiency")
+
+	if got := testutil.ToFloat64(collector.values["mppt-efficiency"]); got != 95 {
+		t.Errorf("virtual_mppt_efficiency = %v, want 95", got)
+	}
+	if got := testutil.ToFloat64(collector.failures.WithLabelValues("mppt-efficiency")); got != 1 {
+		t.Errorf("evaluation_failures = %v, want 1", got)
+	}
+}
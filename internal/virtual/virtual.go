@@ -0,0 +1,270 @@
+// Package virtual computes metrics from expressions over the metrics the
+// controllers publish, such as the MPPT conversion efficiency from the
+// charging and array power, and publishes them as if a controller had.
+package virtual
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/lumberbarons/solar-controller/internal/publish"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	namespace = "virtual"
+
+	// cycleEnd is the metric every controller publishes when a collection
+	// cycle is done, and failureMetric the one it publishes when the cycle
+	// failed
+	cycleEnd      = "availability"
+	failureMetric = "collection-failure"
+)
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Definition is one virtual metric.
+type Definition struct {
+	// Name is the metric name it is published under, such as mppt-efficiency
+	Name string `yaml:"name"`
+
+	// Expression computes the value from published metrics, written as
+	// namespace.metric_name
+	Expression string `yaml:"expression"`
+	Unit       string `yaml:"unit"`
+
+	// Min and Max clamp the value when set
+	Min *float64 `yaml:"min"`
+	Max *float64 `yaml:"max"`
+}
+
+// Validate checks every definition and that their names are unique.
+func Validate(definitions []Definition) error {
+	names := make(map[string]bool, len(definitions))
+	for i, definition := range definitions {
+		if !namePattern.MatchString(definition.Name) {
+			return fmt.Errorf("virtual metric %d: name %q must be lowercase letters, digits and dashes", i+1, definition.Name)
+		}
+		if definition.Name == "evaluation-failures" {
+			// Taken by the failure counter in Prometheus
+			return fmt.Errorf("virtual metric name %s is reserved", definition.Name)
+		}
+		if names[definition.Name] {
+			return fmt.Errorf("virtual metric %s is defined twice", definition.Name)
+		}
+		names[definition.Name] = true
+
+		if definition.Unit == "" {
+			return fmt.Errorf("virtual metric %s: unit is required", definition.Name)
+		}
+		if definition.Min != nil && definition.Max != nil && *definition.Min > *definition.Max {
+			return fmt.Errorf("virtual metric %s: min is above max", definition.Name)
+		}
+		if _, err := Parse(definition.Expression); err != nil {
+			return fmt.Errorf("virtual metric %s: %w", definition.Name, err)
+		}
+	}
+	return nil
+}
+
+// virtualMetric is a definition with its parsed expression and the outcome
+// of its last evaluation.
+type virtualMetric struct {
+	Definition
+	expression *Expression
+	lastError  string
+}
+
+// clamp limits value to the definition's min and max.
+func (m *virtualMetric) clamp(value float64) float64 {
+	if m.Min != nil && value < *m.Min {
+		value = *m.Min
+	}
+	if m.Max != nil && value > *m.Max {
+		value = *m.Max
+	}
+	return value
+}
+
+// sample is the last published value of an input.
+type sample struct {
+	value     float64
+	timestamp int64
+}
+
+// Publisher passes every message on to the publisher it wraps, keeping the
+// value of each metric. When a controller finishes a collection cycle, the
+// virtual metrics that read any of its metrics are evaluated and published
+// through the same publisher.
+//
+// An input is the last value its controller published. A failed cycle
+// forgets all of the controller's values, so nothing is computed from
+// readings that can no longer be refreshed.
+type Publisher struct {
+	next                publish.MessagePublisher
+	prometheusCollector MetricsCollector
+	deviceID            string
+
+	mu      sync.Mutex
+	metrics []*virtualMetric
+	values  map[Input]sample
+}
+
+// NewPublisher creates a publisher that computes definitions over what
+// passes through it to next.
+func NewPublisher(next publish.MessagePublisher, definitions []Definition, prometheusCollector MetricsCollector, deviceID string) (*Publisher, error) {
+	// Default device ID if not provided
+	if deviceID == "" {
+		deviceID = "controller-1"
+	}
+
+	metrics := make([]*virtualMetric, 0, len(definitions))
+	for _, definition := range definitions {
+		expression, err := Parse(definition.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("virtual metric %s: %w", definition.Name, err)
+		}
+		metrics = append(metrics, &virtualMetric{Definition: definition, expression: expression})
+	}
+
+	return &Publisher{
+		next:                next,
+		prometheusCollector: prometheusCollector,
+		deviceID:            deviceID,
+		metrics:             metrics,
+		values:              make(map[Input]sample),
+	}, nil
+}
+
+// NewPublisherFromConfig wraps next with the configured virtual metrics. This
+// is the production entry point; without any definitions it returns next
+// unchanged.
+func NewPublisherFromConfig(definitions []Definition, next publish.MessagePublisher, deviceID string) (publish.MessagePublisher, error) {
+	if len(definitions) == 0 {
+		return next, nil
+	}
+
+	log.Infof("computing %d virtual metrics", len(definitions))
+
+	return NewPublisher(next, definitions, NewPrometheusCollector(definitions), deviceID)
+}
+
+// Publish passes the message on, then records it if it is a metric of this
+// device.
+func (p *Publisher) Publish(topicSuffix, payload string) {
+	p.next.Publish(topicSuffix, payload)
+
+	rest, ok := strings.CutPrefix(topicSuffix, p.deviceID+"/")
+	if !ok {
+		return
+	}
+	controller, metric, ok := strings.Cut(rest, "/")
+	if !ok || controller == namespace {
+		return
+	}
+
+	switch metric {
+	case cycleEnd:
+		p.evaluate(controller)
+	case failureMetric:
+		p.forget(controller)
+	default:
+		var value struct {
+			Value     float64 `json:"value"`
+			Timestamp int64   `json:"timestamp"`
+		}
+		if err := json.Unmarshal([]byte(payload), &value); err != nil {
+			// Not a number, such as the voltgo cell-voltages list
+			return
+		}
+		p.mu.Lock()
+		p.values[Input{Namespace: controller, Metric: metric}] = sample{value: value.Value, timestamp: value.Timestamp}
+		p.mu.Unlock()
+	}
+}
+
+// forget drops every value of a controller whose cycle failed.
+func (p *Publisher) forget(controller string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for input := range p.values {
+		if input.Namespace == controller {
+			delete(p.values, input)
+		}
+	}
+}
+
+// evaluate computes and publishes the virtual metrics that read any metric of
+// controller.
+func (p *Publisher) evaluate(controller string) {
+	var results []Metric
+
+	p.mu.Lock()
+	for _, metric := range p.metrics {
+		if !reads(metric.expression, controller) {
+			continue
+		}
+
+		values := make(map[Input]float64, len(metric.expression.Inputs()))
+		var timestamp int64
+		for _, input := range metric.expression.Inputs() {
+			if s, ok := p.values[input]; ok {
+				values[input] = s.value
+				timestamp = max(timestamp, s.timestamp)
+			}
+		}
+
+		value, err := metric.expression.Evaluate(values)
+		if err != nil {
+			p.prometheusCollector.IncrementFailures(metric.Name)
+			// The same error every cycle is logged once
+			if err.Error() != metric.lastError {
+				log.Warnf("virtual metric %s not computed: %s", metric.Name, err)
+			}
+			metric.lastError = err.Error()
+			continue
+		}
+		if metric.lastError != "" {
+			log.Infof("virtual metric %s computed again", metric.Name)
+			metric.lastError = ""
+		}
+
+		value = metric.clamp(value)
+		p.prometheusCollector.SetValue(metric.Name, value)
+		results = append(results, Metric{Name: metric.Name, Value: value, Unit: metric.Unit, Timestamp: timestamp})
+	}
+	p.mu.Unlock()
+
+	for _, metric := range results {
+		payload, err := metric.ToJSON()
+		if err != nil {
+			log.Errorf("failed to marshal virtual metric %s for publishing: %s", metric.Name, err)
+			continue
+		}
+
+		// Topic format: {deviceId}/virtual/{metric-name}
+		topicSuffix := fmt.Sprintf("%s/%s/%s", p.deviceID, namespace, metric.Name)
+		p.next.Publish(topicSuffix, payload)
+
+		log.Debugf("published virtual metric %s to %s", metric.Name, topicSuffix)
+	}
+}
+
+// reads reports whether expression reads any metric of controller.
+func reads(expression *Expression, controller string) bool {
+	for _, input := range expression.Inputs() {
+		if input.Namespace == controller {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes the wrapped publisher.
+func (p *Publisher) Close() {
+	p.next.Close()
+}
@@ -0,0 +1,238 @@
+package virtual
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lumberbarons/solar-controller/internal/publish"
+	"github.com/lumberbarons/solar-controller/internal/testutil"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+var efficiency = Definition{
+	Name:       "mppt-efficiency",
+	Expression: "epever.charging_power / epever.array_power * 100",
+	Unit:       "percent",
+	Max:        float(100),
+}
+
+func newTestPublisher(t *testing.T, definitions ...Definition) (*Publisher, *testutil.MockMessagePublisher, *MockMetricsCollector) {
+	t.Helper()
+
+	next := &testutil.MockMessagePublisher{}
+	metrics := &MockMetricsCollector{}
+	publisher, err := NewPublisher(next, definitions, metrics, "test-device-1")
+	if err != nil {
+		t.Fatalf("NewPublisher() error = %v", err)
+	}
+	return publisher, next, metrics
+}
+
+func publishMetric(p *Publisher, topic string, value float64, timestamp int64) {
+	payload, _ := json.Marshal(MetricPayload{Value: value, Unit: "watts", Timestamp: timestamp})
+	p.Publish(topic, string(payload))
+}
+
+// virtualPayloads returns what was published under the virtual namespace.
+func virtualPayloads(t *testing.T, next *testutil.MockMessagePublisher) map[string]MetricPayload {
+	t.Helper()
+
+	payloads := make(map[string]MetricPayload)
+	for _, call := range next.PublishCalls {
+		if !strings.HasPrefix(call.TopicSuffix, "test-device-1/virtual/") {
+			continue
+		}
+		var payload MetricPayload
+		if err := json.Unmarshal([]byte(call.Payload), &payload); err != nil {
+			t.Fatalf("payload of %s: %v", call.TopicSuffix, err)
+		}
+		payloads[call.TopicSuffix] = payload
+	}
+	return payloads
+}
+
+func TestPublisher_EvaluatesAtTheEndOfACycle(t *testing.T) {
+	publisher, next, metrics := newTestPublisher(t, efficiency)
+
+	publishMetric(publisher, "test-device-1/epever/array-power", 500, 1000)
+	publishMetric(publisher, "test-device-1/epever/charging-power", 475, 1001)
+	if got := virtualPayloads(t, next); len(got) != 0 {
+		t.Fatalf("published %v before the cycle ended", got)
+	}
+
+	publisher.Publish("test-device-1/epever/availability", `{"value":1,"unit":"boolean","state":"online","timestamp":1001}`)
+
+	if len(next.PublishCalls) != 4 {
+		t.Errorf("publish calls = %d, want the three passed on and the virtual metric", len(next.PublishCalls))
+	}
+	got, ok := virtualPayloads(t, next)["test-device-1/virtual/mppt-efficiency"]
+	if !ok {
+		t.Fatal("mppt-efficiency not published")
+	}
+	if got.Value != float64(95) || got.Unit != "percent" || got.Timestamp != 1001 {
+		t.Errorf("mppt-efficiency = %+v, want 95 percent at the latest input's timestamp", got)
+	}
+	if metrics.Values["mppt-efficiency"] != 95 {
+		t.Errorf("gauge = %v, want 95", metrics.Values["mppt-efficiency"])
+	}
+}
+
+func TestPublisher_Clamps(t *testing.T) {
+	publisher, next, _ := newTestPublisher(t, efficiency, Definition{
+		Name:       "net-power",
+		Expression: "epever.charging_power - 600",
+		Unit:       "watts",
+		Min:        float(0),
+	})
+
+	publishMetric(publisher, "test-device-1/epever/array-power", 500, 1000)
+	publishMetric(publisher, "test-device-1/epever/charging-power", 510, 1000)
+	publisher.Publish("test-device-1/epever/availability", `{"value":1}`)
+
+	payloads := virtualPayloads(t, next)
+	if got := payloads["test-device-1/virtual/mppt-efficiency"].Value; got != float64(100) {
+		t.Errorf("mppt-efficiency = %v, want it clamped to 100", got)
+	}
+	if got := payloads["test-device-1/virtual/net-power"].Value; got != float64(0) {
+		t.Errorf("net-power = %v, want it clamped to 0", got)
+	}
+}
+
+func TestPublisher_MissingInputs(t *testing.T) {
+	publisher, next, metrics := newTestPublisher(t, efficiency, Definition{
+		Name:       "voltage-gap",
+		Expression: "epever.array_voltage - voltgo.battery_voltage",
+		Unit:       "volts",
+	})
+
+	publishMetric(publisher, "test-device-1/epever/array-power", 500, 1000)
+	publishMetric(publisher, "test-device-1/epever/array-voltage", 36, 1000)
+	publisher.Publish("test-device-1/epever/availability", `{"value":1}`)
+
+	if got := virtualPayloads(t, next); len(got) != 0 {
+		t.Errorf("published %v without all the inputs", got)
+	}
+	if metrics.Failures["mppt-efficiency"] != 1 || metrics.Failures["voltage-gap"] != 1 {
+		t.Errorf("failures = %v, want one each", metrics.Failures)
+	}
+
+	// The battery's cycle brings the last input of voltage-gap
+	publishMetric(publisher, "test-device-1/voltgo/battery-voltage", 13.5, 1010)
+	publisher.Publish("test-device-1/voltgo/availability", `{"value":1}`)
+
+	payloads := virtualPayloads(t, next)
+	if got := payloads["test-device-1/virtual/voltage-gap"]; got.Value != 22.5 || got.Timestamp != 1010 {
+		t.Errorf("voltage-gap = %+v, want 22.5 at 1010", got)
+	}
+	if metrics.Failures["mppt-efficiency"] != 1 {
+		t.Errorf("mppt-efficiency evaluated at the end of a voltgo cycle it does not read")
+	}
+}
+
+func TestPublisher_FailedCycleForgetsTheController(t *testing.T) {
+	publisher, next, metrics := newTestPublisher(t, efficiency)
+
+	publishMetric(publisher, "test-device-1/epever/array-power", 500, 1000)
+	publishMetric(publisher, "test-device-1/epever/charging-power", 475, 1000)
+	publisher.Publish("test-device-1/epever/availability", `{"value":1}`)
+
+	publishMetric(publisher, "test-device-1/epever/collection-failure", 1, 1060)
+	publisher.Publish("test-device-1/epever/availability", `{"value":0}`)
+
+	published := 0
+	for _, call := range next.PublishCalls {
+		if call.TopicSuffix == "test-device-1/virtual/mppt-efficiency" {
+			published++
+		}
+	}
+	if published != 1 || metrics.Failures["mppt-efficiency"] != 1 {
+		t.Errorf("after a failed cycle: published %d times with %d failures, want 1 and 1", published, metrics.Failures["mppt-efficiency"])
+	}
+}
+
+func TestPublisher_IgnoresWhatItCannotRead(t *testing.T) {
+	publisher, next, metrics := newTestPublisher(t, Definition{
+		Name:       "double",
+		Expression: "voltgo.cell_voltages * 2",
+		Unit:       "volts",
+	})
+
+	// Another device, a list value, a topic without a metric, and a virtual
+	// metric of the same name
+	publishMetric(publisher, "other-device/voltgo/cell-voltages", 3.3, 1000)
+	publisher.Publish("test-device-1/voltgo/cell-voltages", `{"value":[{"cell":0,"value":3.3}],"unit":"volts","timestamp":1000}`)
+	publisher.Publish("test-device-1/voltgo", `{"value":1}`)
+	publishMetric(publisher, "test-device-1/virtual/cell-voltages", 3.3, 1000)
+	publisher.Publish("test-device-1/voltgo/availability", `{"value":1}`)
+
+	if got, ok := virtualPayloads(t, next)["test-device-1/virtual/double"]; ok {
+		t.Errorf("double published as %+v", got)
+	}
+	if metrics.Failures["double"] != 1 {
+		t.Errorf("failures = %v, want one for the missing input", metrics.Failures)
+	}
+	if len(next.PublishCalls) != 5 {
+		t.Errorf("publish calls = %d, want every message passed on", len(next.PublishCalls))
+	}
+}
+
+func TestPublisher_Close(t *testing.T) {
+	publisher, next, _ := newTestPublisher(t, efficiency)
+	publisher.Close()
+	if next.CloseCalls != 1 {
+		t.Errorf("CloseCalls = %d, want 1", next.CloseCalls)
+	}
+}
+
+func TestNewPublisher_BadExpression(t *testing.T) {
+	_, err := NewPublisher(&testutil.MockMessagePublisher{}, []Definition{{Name: "x", Expression: "1 +", Unit: "count"}}, &MockMetricsCollector{}, "")
+	if err == nil {
+		t.Error("NewPublisher() error = nil, want the parse error")
+	}
+}
+
+func TestNewPublisherFromConfig_WithoutDefinitions(t *testing.T) {
+	next := &testutil.MockMessagePublisher{}
+	publisher, err := NewPublisherFromConfig(nil, next, "test-device-1")
+	if err != nil {
+		t.Fatalf("NewPublisherFromConfig() error = %v", err)
+	}
+	if publisher != publish.MessagePublisher(next) {
+		t.Error("publisher wrapped without any virtual metrics")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		definitions []Definition
+		wantErr     string
+	}{
+		{"none", nil, ""},
+		{"valid", []Definition{efficiency, {Name: "c-rate", Expression: "abs(voltgo.battery_current) / 100", Unit: "c"}}, ""},
+		{"bad name", []Definition{{Name: "MPPT efficiency", Expression: "1", Unit: "percent"}}, "lowercase"},
+		{"reserved name", []Definition{{Name: "evaluation-failures", Expression: "1", Unit: "count"}}, "reserved"},
+		{"duplicate", []Definition{efficiency, efficiency}, "defined twice"},
+		{"no unit", []Definition{{Name: "x", Expression: "1"}}, "unit is required"},
+		{"min above max", []Definition{{Name: "x", Expression: "1", Unit: "count", Min: float(2), Max: float(1)}}, "min is above max"},
+		{"bad expression", []Definition{{Name: "x", Expression: "epever.array_power +", Unit: "watts"}}, "virtual metric x: at"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.definitions)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
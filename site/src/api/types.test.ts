@@ -3,16 +3,46 @@ import { dirname, resolve } from 'node:path';
 import { describe, expect, it } from 'vitest';
 
 import {
+  ALERT_FIELDS,
+  ALERTS_FIELDS,
   BATTERY_PROFILE_FIELDS,
   CHARGING_PARAMETER_FIELDS,
+  CONTROLLER_HEALTH_FIELDS,
+  CONTROLLER_SNAPSHOT_FIELDS,
+  CONTROLLER_STATUS_FIELDS,
+  CONTROLLERS_FIELDS,
   EDITABLE_DURATION_FIELDS,
   EDITABLE_VOLTAGE_FIELDS,
+  EPEVER_CONNECTION_FIELDS,
+  HISTORY_FIELDS,
+  HISTORY_METRICS_FIELDS,
+  HISTORY_POINT_FIELDS,
+  HISTORY_SERIES_FIELDS,
   INFO_FIELDS,
   METRIC_FIELDS,
+  NOTIFICATION_CHANNEL_FIELDS,
+  NOTIFICATIONS_FIELDS,
+  REPORT_FIELDS,
+  REPORT_SUMMARY_FIELDS,
+  RUNTIME_FIELDS,
+  SNAPSHOT_FIELDS,
+  SYSTEM_DAILY_FIELDS,
+  SYSTEM_FIELDS,
   TIME_FIELDS,
+  VOLTGO_BANK_BATTERY_FIELDS,
+  VOLTGO_BANK_FIELDS,
+  VOLTGO_CAPACITY_ESTIMATE_FIELDS,
+  VOLTGO_CELL_ANALYSIS_FIELDS,
   VOLTGO_CELL_FIELDS,
+  VOLTGO_CELL_TREND_FIELDS,
+  VOLTGO_ENERGY_FIELDS,
+  VOLTGO_ENERGY_TOTALS_FIELDS,
+  VOLTGO_HEALTH_REPORT_FIELDS,
   VOLTGO_INFO_FIELDS,
   VOLTGO_METRIC_FIELDS,
+  VOLTGO_SCAN_DEVICE_FIELDS,
+  VOLTGO_SCAN_FIELDS,
+  VOLTGO_SOH_POINT_FIELDS,
 } from './types';
 
 /**
@@ -56,13 +86,44 @@ function contractFields(endpoint: string): string[] {
 describe('API contract', () => {
   it.each([
     ['GET /api/info', INFO_FIELDS],
+    ['GET /api/controllers', CONTROLLERS_FIELDS],
+    ['GET /api/controllers#controllers[]', CONTROLLER_STATUS_FIELDS],
+    ['GET /api/controllers/{name}/health', CONTROLLER_HEALTH_FIELDS],
+    ['GET /api/snapshot', SNAPSHOT_FIELDS],
+    ['GET /api/snapshot#controllers[]', CONTROLLER_SNAPSHOT_FIELDS],
     ['GET /api/epever/metrics', METRIC_FIELDS],
+    ['GET /api/epever/connection', EPEVER_CONNECTION_FIELDS],
     ['GET /api/epever/battery-profile', BATTERY_PROFILE_FIELDS],
     ['GET /api/epever/charging-parameters', CHARGING_PARAMETER_FIELDS],
     ['GET /api/epever/time', TIME_FIELDS],
     ['GET /api/voltgo/metrics', VOLTGO_METRIC_FIELDS],
     ['GET /api/voltgo/metrics#cells[]', VOLTGO_CELL_FIELDS],
+    ['GET /api/epever/metrics#runtime', RUNTIME_FIELDS],
+    ['GET /api/voltgo/metrics#runtime', RUNTIME_FIELDS],
     ['GET /api/voltgo/info', VOLTGO_INFO_FIELDS],
+    ['GET /api/voltgo/bank', VOLTGO_BANK_FIELDS],
+    ['GET /api/voltgo/bank#batteries[]', VOLTGO_BANK_BATTERY_FIELDS],
+    ['GET /api/voltgo/cells/analysis', VOLTGO_CELL_ANALYSIS_FIELDS],
+    ['GET /api/voltgo/cells/analysis#cells[]', VOLTGO_CELL_TREND_FIELDS],
+    ['GET /api/voltgo/energy', VOLTGO_ENERGY_FIELDS],
+    ['GET /api/voltgo/energy#lifetime', VOLTGO_ENERGY_TOTALS_FIELDS],
+    ['GET /api/voltgo/health-report', VOLTGO_HEALTH_REPORT_FIELDS],
+    ['GET /api/voltgo/health-report#sohHistory[]', VOLTGO_SOH_POINT_FIELDS],
+    ['GET /api/voltgo/health-report#capacityEstimates[]', VOLTGO_CAPACITY_ESTIMATE_FIELDS],
+    ['GET /api/voltgo/scan', VOLTGO_SCAN_FIELDS],
+    ['GET /api/voltgo/scan#devices[]', VOLTGO_SCAN_DEVICE_FIELDS],
+    ['GET /api/system', SYSTEM_FIELDS],
+    ['GET /api/system#daily', SYSTEM_DAILY_FIELDS],
+    ['GET /api/history', HISTORY_FIELDS],
+    ['GET /api/history#points[]', HISTORY_POINT_FIELDS],
+    ['GET /api/history/metrics', HISTORY_METRICS_FIELDS],
+    ['GET /api/history/metrics#metrics[]', HISTORY_SERIES_FIELDS],
+    ['GET /api/reports/{report}', REPORT_FIELDS],
+    ['GET /api/reports/{report}#summaries[]', REPORT_SUMMARY_FIELDS],
+    ['GET /api/alerts', ALERTS_FIELDS],
+    ['GET /api/alerts#alerts[]', ALERT_FIELDS],
+    ['GET /api/notifications', NOTIFICATIONS_FIELDS],
+    ['GET /api/notifications#channels[]', NOTIFICATION_CHANNEL_FIELDS],
   ])('%s declares the fields the Go handler returns', (endpoint, declared) => {
     expect([...declared].sort()).toEqual(contractFields(endpoint));
   });
@@ -15,11 +15,106 @@ export type VersionInfo = {
   [K in (typeof INFO_FIELDS)[number]]: string;
 };
 
+/** GET /api/controllers */
+export const CONTROLLERS_FIELDS = ['controllers'] as const;
+
+/** The objects inside the `controllers` array of GET /api/controllers. */
+export const CONTROLLER_STATUS_FIELDS = [
+  'attempts',
+  'error',
+  'name',
+  'nextAttempt',
+  'state',
+  'type',
+] as const;
+
+export type ControllerType =
+  | 'charge-controller'
+  | 'battery'
+  | 'current-sensor'
+  | 'temperature-sensor'
+  | 'system';
+
+export type ControllerStatus = {
+  name: string;
+  type: ControllerType;
+  /** `waiting` controllers failed to start and are retrying in the background. */
+  state: 'running' | 'waiting' | 'disabled';
+  /** Why a waiting controller could not start; empty otherwise. */
+  error: string;
+  attempts: number;
+  /** RFC 3339; null unless waiting. */
+  nextAttempt: string | null;
+};
+
+export type Controllers = {
+  controllers: ControllerStatus[];
+};
+
+/**
+ * The `health` object returned with each controller's metrics. A stale
+ * controller is still serving its last good values.
+ */
+export type Health = {
+  availability: 'unknown' | 'online' | 'offline';
+  /** True when the values are not from the latest collection cycle. */
+  stale: boolean;
+  /** Seconds since the last successful collection; null before the first. */
+  age: number | null;
+  /** RFC 3339; null before the first successful collection. */
+  lastSuccess: string | null;
+  lastError: string;
+  consecutiveFailures: number;
+};
+
+/** GET /api/controllers/{name}/health */
+export const CONTROLLER_HEALTH_FIELDS = [
+  'age',
+  'availability',
+  'consecutiveFailures',
+  'lastError',
+  'lastSuccess',
+  'name',
+  'stale',
+  'state',
+] as const;
+
+export type ControllerHealth = Health & {
+  name: string;
+  state: ControllerStatus['state'];
+};
+
+/** GET /api/snapshot */
+export const SNAPSHOT_FIELDS = ['controllers', 'timestamp'] as const;
+
+/** The objects inside the `controllers` array of GET /api/snapshot. */
+export const CONTROLLER_SNAPSHOT_FIELDS = ['data', 'health', 'name', 'state', 'type'] as const;
+
+export type ControllerSnapshot = {
+  name: string;
+  type: ControllerType;
+  /** Disabled controllers are left out of the snapshot. */
+  state: Exclude<ControllerStatus['state'], 'disabled'>;
+  health: Health;
+  /**
+   * The controller's latest status, shaped as its metrics endpoint returns it
+   * without `health`; null until its first successful collection.
+   */
+  data: Record<string, unknown> | null;
+};
+
+export type Snapshot = {
+  /** Unix seconds when the snapshot was taken. */
+  timestamp: number;
+  controllers: ControllerSnapshot[];
+};
+
 /** GET /api/epever/metrics */
 export const METRIC_FIELDS = [
   'arrayCurrent',
   'arrayPower',
   'arrayVoltage',
+  'batteryCurrent',
   'batterySoc',
   'batteryTemp',
   'batteryVoltage',
@@ -29,13 +124,50 @@ export const METRIC_FIELDS = [
   'collectionTime',
   'deviceTemp',
   'energyGeneratedDaily',
+  'health',
+  'quality',
+  'runtime',
   'timestamp',
 ] as const;
 
 export type MetricField = (typeof METRIC_FIELDS)[number];
 
+/**
+ * How far a field can be trusted: read in the last collection, carried over
+ * from an earlier one after a failed read, or never read at all.
+ */
+export type FieldQuality = 'good' | 'stale' | 'failed';
+
+/** Keys of `quality`: every metric field except the collection bookkeeping and the estimate. */
+export type StatusField = Exclude<
+  MetricField,
+  'timestamp' | 'collectionTime' | 'health' | 'quality' | 'runtime'
+>;
+
+type EpeverMetricTypes = {
+  timestamp: number;
+  collectionTime: number;
+  arrayVoltage: number;
+  arrayCurrent: number;
+  arrayPower: number;
+  chargingCurrent: number;
+  chargingPower: number;
+  batteryVoltage: number;
+  /** Battery net current: positive while charging, negative while discharging. */
+  batteryCurrent: number;
+  batterySoc: number;
+  batteryTemp: number;
+  deviceTemp: number;
+  energyGeneratedDaily: number;
+  chargingStatus: number;
+  health: Health;
+  quality: Record<StatusField, FieldQuality>;
+  /** Null until the SOC and a capacity are known. */
+  runtime: Runtime | null;
+};
+
 export type EpeverMetrics = {
-  [K in MetricField]: number;
+  [K in MetricField]: EpeverMetricTypes[K];
 };
 
 /**
@@ -49,12 +181,49 @@ export const CHARGING_STATUS_LABELS: Record<number, string> = {
   3: 'Equalization',
 };
 
+/** GET /api/epever/connection */
+export const EPEVER_CONNECTION_FIELDS = [
+  'connectedSince',
+  'consecutiveFailures',
+  'device',
+  'interFrameDelay',
+  'lastError',
+  'nextAttempt',
+  'path',
+  'reconnects',
+  'serialPort',
+  'state',
+] as const;
+
+type EpeverConnectionTypes = {
+  state: 'connected' | 'disconnected';
+  /** The configured path, the path opened, and the tty it resolves to. */
+  serialPort: string;
+  path: string;
+  device: string;
+  consecutiveFailures: number;
+  reconnects: number;
+  lastError: string;
+  /** RFC 3339; null while disconnected. */
+  connectedSince: string | null;
+  /** RFC 3339; null while connected. */
+  nextAttempt: string | null;
+  /** Seconds between requests; moves with the error rate in adaptive mode. */
+  interFrameDelay: number;
+};
+
+export type EpeverConnection = {
+  [K in (typeof EPEVER_CONNECTION_FIELDS)[number]]: EpeverConnectionTypes[K];
+};
+
 /** GET /api/voltgo/metrics */
 export const VOLTGO_METRIC_FIELDS = [
   'cellCount',
   'cells',
   'collectionTime',
   'current',
+  'health',
+  'runtime',
   'soc',
   'soh',
   'temperature',
@@ -71,6 +240,46 @@ export type VoltgoCell = {
   voltage: number;
 };
 
+/**
+ * The `runtime` object of GET /api/epever/metrics, GET /api/voltgo/metrics and
+ * GET /api/voltgo/bank.
+ */
+export const RUNTIME_FIELDS = [
+  'capacityAh',
+  'capacitySource',
+  'confidence',
+  'current',
+  'reserveSoc',
+  'samples',
+  'soc',
+  'state',
+  'timeToEmpty',
+  'timeToFull',
+  'timestamp',
+] as const;
+
+type RuntimeTypes = {
+  timestamp: number;
+  state: 'charging' | 'discharging' | 'idle';
+  /** Net current averaged over the smoothing window. */
+  current: number;
+  samples: number;
+  /** How full and steady the smoothing window is, in percent. */
+  confidence: number;
+  soc: number;
+  reserveSoc: number;
+  capacityAh: number;
+  capacitySource: 'configured' | 'measured' | 'rated' | 'controller' | 'mixed';
+  /** Seconds; set only while charging. */
+  timeToFull: number | null;
+  /** Seconds down to the reserve SOC; set only while discharging. */
+  timeToEmpty: number | null;
+};
+
+export type Runtime = {
+  [K in (typeof RUNTIME_FIELDS)[number]]: RuntimeTypes[K];
+};
+
 /**
  * Value types per field. VoltgoMetrics is mapped over VOLTGO_METRIC_FIELDS
  * rather than declared directly, so the field list stays authoritative: a field
@@ -88,6 +297,9 @@ type VoltgoMetricTypes = {
   temperatures: number[];
   cellCount: number;
   cells: VoltgoCell[];
+  health: Health;
+  /** Null until a capacity is known. */
+  runtime: Runtime | null;
 };
 
 export type VoltgoMetrics = {
@@ -113,6 +325,495 @@ export type VoltgoInfo = {
   [K in (typeof VOLTGO_INFO_FIELDS)[number]]: VoltgoInfoTypes[K];
 };
 
+/** GET /api/voltgo/bank: aggregates across every configured pack. */
+export const VOLTGO_BANK_FIELDS = [
+  'batteries',
+  'current',
+  'maxCellBattery',
+  'maxCellVoltage',
+  'minCellBattery',
+  'minCellVoltage',
+  'missing',
+  'power',
+  'runtime',
+  'soc',
+  'timestamp',
+  'voltageImbalance',
+] as const;
+
+/** The objects inside the `batteries` array of GET /api/voltgo/bank. */
+export const VOLTGO_BANK_BATTERY_FIELDS = ['current', 'name', 'soc', 'voltage'] as const;
+
+export type VoltgoBankBattery = {
+  /** Empty for a single battery configured by `address` alone. */
+  name: string;
+  voltage: number;
+  current: number;
+  soc: number;
+};
+
+type VoltgoBankTypes = {
+  timestamp: number;
+  /** Sum of the pack currents: positive while charging. */
+  current: number;
+  power: number;
+  /** Weighted by each pack's energy capacity once every pack's info is known. */
+  soc: number;
+  minCellVoltage: number;
+  minCellBattery: string;
+  maxCellVoltage: number;
+  maxCellBattery: string;
+  /** Highest minus lowest pack voltage. */
+  voltageImbalance: number;
+  /** The packs read in the last cycle. */
+  batteries: VoltgoBankBattery[];
+  /** The packs that could not be read in the last cycle. */
+  missing: string[];
+  /** Against the capacity of the packs read; null while any is unknown. */
+  runtime: Runtime | null;
+};
+
+export type VoltgoBank = {
+  [K in (typeof VOLTGO_BANK_FIELDS)[number]]: VoltgoBankTypes[K];
+};
+
+/** GET /api/voltgo/cells/analysis: how each cell has tracked the pack mean. */
+export const VOLTGO_CELL_ANALYSIS_FIELDS = [
+  'cells',
+  'imbalanceScore',
+  'samples',
+  'span',
+  'timestamp',
+] as const;
+
+/** The objects inside the `cells` array of GET /api/voltgo/cells/analysis. */
+export const VOLTGO_CELL_TREND_FIELDS = ['deviation', 'drift', 'flags', 'index', 'regimes'] as const;
+
+export type VoltgoCellRegimeName =
+  | 'topOfCharge'
+  | 'charging'
+  | 'resting'
+  | 'discharging'
+  | 'bottomOfDischarge';
+
+export type VoltgoCellFlag = 'high-at-top-of-charge' | 'low-at-bottom-of-discharge' | 'drifting';
+
+export type VoltgoCellRegime = {
+  samples: number;
+  /** Volts from the pack mean, positive above it. */
+  meanDeviation: number;
+  /** Shares of the samples more than 20mV above and below the mean. */
+  high: number;
+  low: number;
+};
+
+export type VoltgoCellTrend = {
+  index: number;
+  /** Volts from the pack mean in the latest sample, positive above it. */
+  deviation: number;
+  /** Only the regimes that were sampled. */
+  regimes: Partial<Record<VoltgoCellRegimeName, VoltgoCellRegime>>;
+  /** Trend of the deviation in volts per day. */
+  drift: number;
+  flags: VoltgoCellFlag[];
+};
+
+type VoltgoCellAnalysisTypes = {
+  /** Of the latest sample. */
+  timestamp: number;
+  samples: number;
+  /** Seconds from the oldest sample to the latest. */
+  span: number;
+  /** RMS deviation of the cells from the pack mean over every sample, in volts. */
+  imbalanceScore: number;
+  cells: VoltgoCellTrend[];
+};
+
+export type VoltgoCellAnalysis = {
+  [K in (typeof VOLTGO_CELL_ANALYSIS_FIELDS)[number]]: VoltgoCellAnalysisTypes[K];
+};
+
+/** GET /api/voltgo/energy: charge and discharge accounting. */
+export const VOLTGO_ENERGY_FIELDS = [
+  'daily',
+  'day',
+  'equivalentFullCycles',
+  'lifetime',
+  'month',
+  'monthly',
+  'roundTripEfficiency',
+  'timestamp',
+] as const;
+
+/** The `daily`, `monthly` and `lifetime` objects of GET /api/voltgo/energy. */
+export const VOLTGO_ENERGY_TOTALS_FIELDS = [
+  'chargedAh',
+  'chargedKwh',
+  'dischargedAh',
+  'dischargedKwh',
+] as const;
+
+export type VoltgoEnergyTotals = {
+  [K in (typeof VOLTGO_ENERGY_TOTALS_FIELDS)[number]]: number;
+};
+
+type VoltgoEnergyTypes = {
+  /** Of the last sample counted. */
+  timestamp: number;
+  /** The local day (YYYY-MM-DD) and month (YYYY-MM) the daily and monthly totals are for. */
+  day: string;
+  month: string;
+  daily: VoltgoEnergyTotals;
+  monthly: VoltgoEnergyTotals;
+  lifetime: VoltgoEnergyTotals;
+  /** Lifetime energy out over energy in, in percent; null until something has been charged. */
+  roundTripEfficiency: number | null;
+  /** Lifetime charge out over the capacity; null until the capacity is known. */
+  equivalentFullCycles: number | null;
+};
+
+export type VoltgoEnergy = {
+  [K in (typeof VOLTGO_ENERGY_FIELDS)[number]]: VoltgoEnergyTypes[K];
+};
+
+/** GET /api/voltgo/health-report: capacity fade against the BMS and rated capacity. */
+export const VOLTGO_HEALTH_REPORT_FIELDS = [
+  'bmsSoh',
+  'capacityEstimates',
+  'endOfLife',
+  'endOfLifeSoh',
+  'estimatedCapacityAh',
+  'estimatedSoh',
+  'fadeBasis',
+  'fadePerYear',
+  'ratedCapacityAh',
+  'sohDiscrepancy',
+  'sohHistory',
+  'timestamp',
+] as const;
+
+/** The objects inside the `sohHistory` array of GET /api/voltgo/health-report. */
+export const VOLTGO_SOH_POINT_FIELDS = ['soh', 'timestamp'] as const;
+
+/** The objects inside the `capacityEstimates` array of GET /api/voltgo/health-report. */
+export const VOLTGO_CAPACITY_ESTIMATE_FIELDS = ['capacityAh', 'fromSoc', 'timestamp', 'toSoc'] as const;
+
+export type VoltgoSOHPoint = {
+  /** When the BMS first reported this SOH. */
+  timestamp: number;
+  soh: number;
+};
+
+export type VoltgoCapacityEstimate = {
+  /** When charging resumed and ended the discharge. */
+  timestamp: number;
+  capacityAh: number;
+  fromSoc: number;
+  toSoc: number;
+};
+
+type VoltgoHealthReportTypes = {
+  timestamp: number;
+  /** null until the battery info has been read. */
+  ratedCapacityAh: number | null;
+  bmsSoh: number;
+  /** Median of the latest capacity estimates; null until a discharge from full has been measured. */
+  estimatedCapacityAh: number | null;
+  estimatedSoh: number | null;
+  /** Estimated SOH minus the BMS's, in percentage points. */
+  sohDiscrepancy: number | null;
+  /** What the fade is fitted to; empty until there is enough history. */
+  fadeBasis: '' | 'capacity' | 'soh';
+  /** Percentage points of rated capacity lost a year. */
+  fadePerYear: number | null;
+  endOfLifeSoh: number;
+  /** Unix timestamp the fade reaches endOfLifeSoh; null while the health is not falling. */
+  endOfLife: number | null;
+  sohHistory: VoltgoSOHPoint[];
+  capacityEstimates: VoltgoCapacityEstimate[];
+};
+
+export type VoltgoHealthReport = {
+  [K in (typeof VOLTGO_HEALTH_REPORT_FIELDS)[number]]: VoltgoHealthReportTypes[K];
+};
+
+/** GET /api/voltgo/scan */
+export const VOLTGO_SCAN_FIELDS = ['devices', 'window'] as const;
+
+/** The objects inside the `devices` array of GET /api/voltgo/scan. */
+export const VOLTGO_SCAN_DEVICE_FIELDS = ['address', 'compatible', 'name', 'rssi'] as const;
+
+export type VoltgoScanDevice = {
+  address: string;
+  name: string;
+  /** Signal strength in dBm; closer to zero is stronger. */
+  rssi: number;
+  /** Whether the advertised name is a Voltgo pack's. */
+  compatible: boolean;
+};
+
+type VoltgoScanTypes = {
+  /** How long the scan listened, in seconds. */
+  window: number;
+  /** Strongest signal first. */
+  devices: VoltgoScanDevice[];
+};
+
+export type VoltgoScan = {
+  [K in (typeof VOLTGO_SCAN_FIELDS)[number]]: VoltgoScanTypes[K];
+};
+
+/** GET /api/system: the power flows joined from the charge controller and battery. */
+export const SYSTEM_FIELDS = [
+  'batteryChargePower',
+  'batteryDischargePower',
+  'batteryPower',
+  'batteryTimestamp',
+  'chargerTimestamp',
+  'daily',
+  'health',
+  'loadPower',
+  'selfConsumption',
+  'solarPower',
+  'timestamp',
+] as const;
+
+/** The `daily` object of GET /api/system. */
+export const SYSTEM_DAILY_FIELDS = [
+  'balanceKwh',
+  'batteryChargedKwh',
+  'batteryDischargedKwh',
+  'day',
+  'loadKwh',
+  'selfConsumption',
+  'solarKwh',
+  'solarToLoadKwh',
+] as const;
+
+type SystemDailyTypes = {
+  /** The local day (YYYY-MM-DD) the totals are for. */
+  day: string;
+  /** Solar energy less the load's; positive when the day left energy in the battery. */
+  balanceKwh: number;
+  /** Share of the solar energy the load used directly, in percent; null before any solar. */
+  selfConsumption: number | null;
+} & {
+  [K in 'batteryChargedKwh' | 'batteryDischargedKwh' | 'loadKwh' | 'solarKwh' | 'solarToLoadKwh']: number;
+};
+
+export type SystemDaily = {
+  [K in (typeof SYSTEM_DAILY_FIELDS)[number]]: SystemDailyTypes[K];
+};
+
+type SystemTypes = {
+  /** Of the later of the two readings joined. */
+  timestamp: number;
+  chargerTimestamp: number;
+  batteryTimestamp: number;
+  /** Watts. The battery power is positive when charging. */
+  solarPower: number;
+  batteryPower: number;
+  batteryChargePower: number;
+  batteryDischargePower: number;
+  loadPower: number;
+  /** Share of the solar power the load uses directly, in percent; null without solar. */
+  selfConsumption: number | null;
+  daily: SystemDaily;
+  health: Health;
+};
+
+export type SystemStatus = {
+  [K in (typeof SYSTEM_FIELDS)[number]]: SystemTypes[K];
+};
+
+/** GET /api/history */
+export const HISTORY_FIELDS = [
+  'from',
+  'metric',
+  'points',
+  'resolution',
+  'step',
+  'to',
+  'unit',
+] as const;
+
+/** Each entry of `points`: one sample, or the mean, lowest and highest of a bucket or step. */
+export const HISTORY_POINT_FIELDS = ['max', 'min', 'timestamp', 'value'] as const;
+
+export type HistoryPoint = {
+  [K in (typeof HISTORY_POINT_FIELDS)[number]]: number;
+};
+
+export type HistoryResolution = 'raw' | '5m' | '1h';
+
+type HistoryTypes = {
+  /** Such as voltgo/battery-soc. */
+  metric: string;
+  unit: string;
+  resolution: HistoryResolution;
+  /** Unix seconds. */
+  from: number;
+  to: number;
+  /** Seconds each point covers; 0 for the points as stored. */
+  step: number;
+  points: HistoryPoint[];
+};
+
+export type HistoryResult = {
+  [K in (typeof HISTORY_FIELDS)[number]]: HistoryTypes[K];
+};
+
+/** GET /api/history/metrics */
+export const HISTORY_METRICS_FIELDS = ['metrics'] as const;
+
+/** Each entry of `metrics`. */
+export const HISTORY_SERIES_FIELDS = ['name', 'prometheusName', 'unit'] as const;
+
+export type HistorySeries = {
+  /** The name GET /api/history takes, such as voltgo/battery-soc. */
+  name: string;
+  unit: string;
+  /** The name the Prometheus query API takes, such as voltgo_battery_soc. */
+  prometheusName: string;
+};
+
+export type HistoryMetrics = {
+  metrics: HistorySeries[];
+};
+
+/** GET /api/reports/{report}, with report daily, monthly or yearly */
+export const REPORT_FIELDS = ['report', 'summaries'] as const;
+
+/** Each entry of `summaries`, oldest first. Also the columns of `?format=csv`. */
+export const REPORT_SUMMARY_FIELDS = [
+  'complete',
+  'consumedKwh',
+  'days',
+  'floatHours',
+  'maxBatteryVoltage',
+  'minBatteryVoltage',
+  'minSoc',
+  'peakLoadPower',
+  'peakPvPower',
+  'period',
+  'pvKwh',
+] as const;
+
+export type ReportPeriod = 'daily' | 'monthly' | 'yearly';
+
+type ReportSummaryTypes = {
+  /** The local day (YYYY-MM-DD), month (YYYY-MM) or year (YYYY). */
+  period: string;
+  /** Days with readings in the period. */
+  days: number;
+  /** False for a period that includes today. */
+  complete: boolean;
+} & {
+  /** Each null when its source published nothing in the period. */
+  [K in
+    | 'consumedKwh'
+    | 'floatHours'
+    | 'maxBatteryVoltage'
+    | 'minBatteryVoltage'
+    | 'minSoc'
+    | 'peakLoadPower'
+    | 'peakPvPower'
+    | 'pvKwh']: number | null;
+};
+
+export type ReportSummary = {
+  [K in (typeof REPORT_SUMMARY_FIELDS)[number]]: ReportSummaryTypes[K];
+};
+
+export type Report = {
+  report: ReportPeriod;
+  summaries: ReportSummary[];
+};
+
+/** GET /api/alerts, pending and firing alerts or every rule with ?all=true */
+export const ALERTS_FIELDS = ['alerts'] as const;
+
+/** Each entry of `alerts`, in the order of the rules. */
+export const ALERT_FIELDS = [
+  'condition',
+  'firedAt',
+  'name',
+  'severity',
+  'since',
+  'state',
+  'updated',
+  'value',
+] as const;
+
+export type AlertSeverity = 'info' | 'warning' | 'critical';
+
+export type AlertState = 'inactive' | 'pending' | 'firing' | 'resolved';
+
+type AlertTypes = {
+  name: string;
+  severity: AlertSeverity;
+  /** Such as voltgo/battery-soc < 20. */
+  condition: string;
+  state: AlertState;
+  /** The last reading of the metric; null before the first. */
+  value: number | null;
+  /** Unix seconds of the last reading; null before the first. */
+  updated: number | null;
+  /** Unix seconds since the alert entered its state; null before the first reading. */
+  since: number | null;
+  /** Unix seconds of when the alert last fired; null if it never has. */
+  firedAt: number | null;
+};
+
+export type Alert = {
+  [K in (typeof ALERT_FIELDS)[number]]: AlertTypes[K];
+};
+
+export type Alerts = {
+  alerts: Alert[];
+};
+
+/** GET /api/notifications */
+export const NOTIFICATIONS_FIELDS = ['channels'] as const;
+
+/** Each entry of `channels`, in the order they are configured. */
+export const NOTIFICATION_CHANNEL_FIELDS = [
+  'dropped',
+  'failed',
+  'lastError',
+  'lastSent',
+  'minSeverity',
+  'name',
+  'sent',
+  'type',
+] as const;
+
+export type NotificationChannelType = 'smtp' | 'ntfy' | 'gotify' | 'webhook';
+
+type NotificationChannelTypes = {
+  name: string;
+  type: NotificationChannelType;
+  minSeverity: AlertSeverity;
+  /** Notifications delivered since startup. */
+  sent: number;
+  /** Notifications that failed after every retry since startup. */
+  failed: number;
+  /** Notifications dropped for the rate limit or a full queue since startup. */
+  dropped: number;
+  /** Unix seconds of the last delivery; null before the first. */
+  lastSent: number | null;
+  /** Why the last failure failed; null if none has. */
+  lastError: string | null;
+};
+
+export type NotificationChannel = {
+  [K in (typeof NOTIFICATION_CHANNEL_FIELDS)[number]]: NotificationChannelTypes[K];
+};
+
+export type NotificationChannels = {
+  channels: NotificationChannel[];
+};
+
 /** GET and PATCH /api/epever/battery-profile */
 export const BATTERY_PROFILE_FIELDS = [
   'batteryCapacity',
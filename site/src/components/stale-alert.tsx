@@ -0,0 +1,40 @@
+import { Alert } from '@mui/material';
+
+import type { Health } from '../api/types';
+
+type StaleAlertProps = {
+  /** What the values belong to, e.g. "Solar controller". */
+  source: string;
+  health: Health | undefined;
+};
+
+function formatAge(seconds: number): string {
+  if (seconds < 120) {
+    return `${Math.round(seconds)} s`;
+  }
+  if (seconds < 7200) {
+    return `${Math.round(seconds / 60)} min`;
+  }
+  return `${Math.round(seconds / 3600)} h`;
+}
+
+/**
+ * Warns that the values on screen are frozen at the last successful
+ * collection, so they are not read as live. Renders nothing while fresh.
+ */
+function StaleAlert({ source, health }: StaleAlertProps) {
+  if (!health?.stale) {
+    return null;
+  }
+
+  const age = health.age === null ? '' : ` ${formatAge(health.age)} ago`;
+  const reason = health.lastError ? ` (${health.lastError})` : '';
+
+  return (
+    <Alert severity="warning" sx={{ mb: 2 }}>
+      {source} data is stale: last updated{age}{reason}.
+    </Alert>
+  );
+}
+
+export default StaleAlert;
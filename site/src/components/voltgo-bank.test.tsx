@@ -0,0 +1,119 @@
+import { render, screen, waitFor } from '@testing-library/react';
+import { beforeEach, describe, expect, it, vi, type Mock } from 'vitest';
+
+import VoltgoBank from './voltgo-bank';
+import type { VoltgoBank as VoltgoBankStatus, VoltgoMetrics } from '../api/types';
+
+vi.mock('axios', () => {
+  const isAxiosError = (error: unknown): boolean =>
+    Boolean((error as { isAxiosError?: boolean } | null)?.isAxiosError);
+  return {
+    default: { get: vi.fn(), isAxiosError },
+  };
+});
+
+import axios from 'axios';
+
+const mockedGet = (axios as unknown as { get: Mock }).get;
+
+const METRICS: VoltgoMetrics = {
+  timestamp: 1699000000,
+  collectionTime: 1.8,
+  voltage: 13.31,
+  current: 12.4,
+  soc: 74,
+  soh: 99,
+  temperature: 19.5,
+  temperatures: [19, 20],
+  cellCount: 0,
+  cells: [],
+  health: {
+    availability: 'online',
+    stale: false,
+    age: 4,
+    lastSuccess: '2023-11-03T08:26:40Z',
+    lastError: '',
+    consecutiveFailures: 0,
+  },
+  runtime: null,
+};
+
+const BANK: VoltgoBankStatus = {
+  timestamp: 1699000000,
+  current: 3,
+  power: 40,
+  soc: 80,
+  minCellVoltage: 3.301,
+  minCellBattery: 'house-b',
+  maxCellVoltage: 3.342,
+  maxCellBattery: 'house-a',
+  voltageImbalance: 0.04,
+  batteries: [
+    { name: 'house-a', voltage: 13.3, current: 5, soc: 90 },
+    { name: 'house-b', voltage: 13.26, current: -2, soc: 50 },
+  ],
+  missing: ['house-c'],
+  runtime: null,
+};
+
+function httpError(status: number, statusText: string) {
+  const error = new Error(`Request failed with status code ${status}`) as Error & {
+    isAxiosError: boolean;
+    response: { status: number; statusText: string };
+  };
+  error.isAxiosError = true;
+  error.response = { status, statusText };
+  return error;
+}
+
+/** Answers each listed URL with 200; anything else 404s, as an unregistered route does. */
+function mockApi(responses: Record<string, unknown>) {
+  mockedGet.mockImplementation((url: string) =>
+    url in responses
+      ? Promise.resolve({ status: 200, data: responses[url] })
+      : Promise.reject(httpError(404, 'Not Found')),
+  );
+}
+
+beforeEach(() => {
+  vi.clearAllMocks();
+});
+
+describe('voltgo bank panel', () => {
+  it('shows the single pack panel when there is one pack', async () => {
+    mockApi({
+      '/api/voltgo/bank': { ...BANK, batteries: [{ ...BANK.batteries[0], name: '' }], missing: [] },
+      '/api/voltgo/metrics': METRICS,
+    });
+
+    render(<VoltgoBank refreshKey={0} />);
+
+    await waitFor(() => expect(screen.getByText('74 %')).toBeInTheDocument());
+    expect(screen.getByText('Battery Bank')).toBeInTheDocument();
+    expect(screen.queryByText('Bank SOC')).not.toBeInTheDocument();
+  });
+
+  it('shows the aggregates and a panel per pack', async () => {
+    mockApi({
+      '/api/voltgo/bank': BANK,
+      '/api/voltgo/metrics?battery=house-a': METRICS,
+      '/api/voltgo/metrics?battery=house-b': METRICS,
+      '/api/voltgo/metrics?battery=house-c': METRICS,
+    });
+
+    render(<VoltgoBank refreshKey={0} />);
+
+    await waitFor(() => expect(screen.getByText('80 %')).toBeInTheDocument());
+    expect(screen.getByText('2 of 3 packs reporting')).toBeInTheDocument();
+    expect(screen.getByText('+3.00 A')).toBeInTheDocument();
+    expect(screen.getByText('40 mV')).toBeInTheDocument();
+    expect(screen.getByText('Lowest Cell · house-b')).toBeInTheDocument();
+    expect(
+      screen.getByText('Not read in the last cycle: house-c. The bank totals leave them out.'),
+    ).toBeInTheDocument();
+
+    for (const name of ['house-a', 'house-b', 'house-c']) {
+      await waitFor(() => expect(screen.getByText(`Battery ${name}`)).toBeInTheDocument());
+    }
+  });
+});
@@ -0,0 +1,111 @@
+import { useEffect, useState } from 'react';
+
+import axios from 'axios';
+import { Alert, Grid, Typography } from '@mui/material';
+
+import Metric from './metric';
+import VoltgoBattery from './voltgo-battery';
+import type { VoltgoBank as VoltgoBankStatus } from '../api/types';
+
+type VoltgoBankProps = {
+  /** Changing this triggers a refetch, so the dashboard refresh covers this panel too. */
+  refreshKey: number;
+};
+
+/** Signed, so the direction is readable from the number as well as the label. */
+function formatCurrent(current: number): string {
+  return `${current > 0 ? '+' : ''}${current.toFixed(2)}`;
+}
+
+/** Every configured pack: those read in the last cycle, then those that were not. */
+function packNames(bank: VoltgoBankStatus): string[] {
+  return [...bank.batteries.map(battery => battery.name), ...bank.missing];
+}
+
+/**
+ * The voltgo battery bank. With one pack this is just that pack's panel; with
+ * several it adds the bank aggregates above a panel per pack.
+ *
+ * The single-pack panel renders until the bank says otherwise, so a one-pack
+ * deployment, or one whose first cycle has not finished, looks as it always has.
+ */
+function VoltgoBank({ refreshKey }: VoltgoBankProps) {
+  const [bank, setBank] = useState<VoltgoBankStatus | null>(null);
+
+  useEffect(() => {
+    let cancelled = false;
+
+    axios.get<VoltgoBankStatus>('/api/voltgo/bank')
+      .then(response => {
+        if (!cancelled) {
+          setBank(response.status === 204 ? null : response.data);
+        }
+      })
+      .catch(() => {
+        // 404 when voltgo is disabled; the pack panel handles that and any
+        // other failure itself.
+        if (!cancelled) {
+          setBank(null);
+        }
+      });
+
+    return () => {
+      cancelled = true;
+    };
+  }, [refreshKey]);
+
+  const packs = bank ? packNames(bank) : [];
+  if (!bank || packs.length < 2) {
+    return <VoltgoBattery refreshKey={refreshKey} />;
+  }
+
+  return (
+    <>
+      <Typography variant="h6" sx={{ mb: 1, fontWeight: 600, color: '#1b5e20' }}>
+        Battery Bank
+        <Typography component="span" sx={{ ml: 1, fontSize: '0.875rem', color: 'text.secondary' }}>
+          {bank.batteries.length} of {packs.length} packs reporting
+        </Typography>
+      </Typography>
+      {bank.missing.length > 0 && (
+        <Alert severity="warning" sx={{ mb: 2 }}>
+          Not read in the last cycle: {bank.missing.join(', ')}. The bank totals leave them out.
+        </Alert>
+      )}
+      <Grid container spacing={2} sx={{ mb: 2 }}>
+        <Grid size={{ xs: 6, sm: 3 }}>
+          <Metric title="Bank SOC" value={bank.soc.toFixed(0)} unit="%" />
+        </Grid>
+        <Grid size={{ xs: 6, sm: 3 }}>
+          <Metric title="Bank Current" value={formatCurrent(bank.current)} unit="A" />
+        </Grid>
+        <Grid size={{ xs: 6, sm: 3 }}>
+          <Metric title="Bank Power" value={bank.power.toFixed(0)} unit="W" />
+        </Grid>
+        <Grid size={{ xs: 6, sm: 3 }}>
+          <Metric title="Pack Imbalance" value={(bank.voltageImbalance * 1000).toFixed(0)} unit="mV" />
+        </Grid>
+        <Grid size={{ xs: 6, sm: 3 }}>
+          <Metric
+            title={`Lowest Cell · ${bank.minCellBattery}`}
+            value={bank.minCellVoltage.toFixed(3)}
+            unit="V"
+          />
+        </Grid>
+        <Grid size={{ xs: 6, sm: 3 }}>
+          <Metric
+            title={`Highest Cell · ${bank.maxCellBattery}`}
+            value={bank.maxCellVoltage.toFixed(3)}
+            unit="V"
+          />
+        </Grid>
+      </Grid>
+
+      {packs.map(name => (
+        <VoltgoBattery key={name} refreshKey={refreshKey} battery={name} />
+      ))}
+    </>
+  );
+}
+
+export default VoltgoBank;
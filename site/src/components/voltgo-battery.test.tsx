@@ -32,6 +32,15 @@ const METRICS: VoltgoMetrics = {
     { index: 2, voltage: 3.338 },
     { index: 3, voltage: 3.326 },
   ],
+  health: {
+    availability: 'online',
+    stale: false,
+    age: 4,
+    lastSuccess: '2023-11-03T08:26:40Z',
+    lastError: '',
+    consecutiveFailures: 0,
+  },
+  runtime: null,
 };
 
 const INFO: VoltgoInfo = {
@@ -150,6 +159,45 @@ describe('voltgo battery panel', () => {
     expect(screen.queryByLabelText(/lowest/)).not.toBeInTheDocument();
   });
 
+  it('warns when the reading is stale', async () => {
+    withMetrics({
+      ...METRICS,
+      health: { ...METRICS.health, stale: true, age: 600, lastError: 'BLE connect timeout', consecutiveFailures: 3 },
+    });
+
+    render(<VoltgoBattery refreshKey={0} />);
+
+    await waitFor(() =>
+      expect(screen.getByText('Battery data is stale: last updated 10 min ago (BLE connect timeout).')).toBeInTheDocument(),
+    );
+    // The last values are still shown
+    expect(screen.getByText('74 %')).toBeInTheDocument();
+  });
+
+  it('shows no warning while the reading is fresh', async () => {
+    withMetrics();
+
+    render(<VoltgoBattery refreshKey={0} />);
+
+    await waitFor(() => expect(screen.getByText('74 %')).toBeInTheDocument());
+    expect(screen.queryByText(/stale/)).not.toBeInTheDocument();
+  });
+
+  it('fetches and titles one named pack of a bank', async () => {
+    mockApi({
+      '/api/voltgo/metrics?battery=house-b': { status: 200, data: METRICS },
+      '/api/voltgo/info?battery=house-b': { status: 200, data: INFO },
+    });
+
+    render(<VoltgoBattery refreshKey={0} battery="house-b" />);
+
+    await waitFor(() => expect(screen.getByText('74 %')).toBeInTheDocument());
+    expect(screen.getByText('Battery house-b')).toBeInTheDocument();
+    await waitFor(() =>
+      expect(mockedGet).toHaveBeenCalledWith('/api/voltgo/info?battery=house-b'),
+    );
+  });
+
   it('renders nothing when the controller is disabled', async () => {
     mockApi({});
 
@@ -4,6 +4,7 @@ import axios from 'axios';
 import { Alert, Box, Chip, Grid, Stack, Typography } from '@mui/material';
 
 import Metric from './metric';
+import StaleAlert from './stale-alert';
 import type { VoltgoCell, VoltgoInfo, VoltgoMetrics } from '../api/types';
 
 /**
@@ -26,6 +27,8 @@ type PanelState =
 type VoltgoBatteryProps = {
   /** Changing this triggers a refetch, so the dashboard refresh covers this panel too. */
   refreshKey: number;
+  /** One named pack of a multi-pack bank; omitted, the first configured pack. */
+  battery?: string;
 };
 
 function describeFlow(current: number): string {
@@ -66,12 +69,14 @@ function errorMessage(error: unknown): string {
  * Renders nothing when that controller is disabled — its endpoints are then
  * unregistered and answer 404 — so a solar-only deployment sees no empty panel.
  */
-function VoltgoBattery({ refreshKey }: VoltgoBatteryProps) {
+function VoltgoBattery({ refreshKey, battery }: VoltgoBatteryProps) {
   const [state, setState] = useState<PanelState>({ kind: 'loading' });
   const [info, setInfo] = useState<VoltgoInfo | null>(null);
 
+  const query = battery === undefined ? '' : `?battery=${encodeURIComponent(battery)}`;
+
   const fetchInfo = useCallback(() => {
-    axios.get<VoltgoInfo>('/api/voltgo/info')
+    axios.get<VoltgoInfo>(`/api/voltgo/info${query}`)
       .then(response => {
         // 204 until the first connection has read the static info.
         setInfo(response.status === 204 ? null : response.data);
@@ -80,12 +85,12 @@ function VoltgoBattery({ refreshKey }: VoltgoBatteryProps) {
         // Info is decoration; the panel is useful without it.
         setInfo(null);
       });
-  }, []);
+  }, [query]);
 
   useEffect(() => {
     let cancelled = false;
 
-    axios.get<VoltgoMetrics>('/api/voltgo/metrics')
+    axios.get<VoltgoMetrics>(`/api/voltgo/metrics${query}`)
       .then(response => {
         if (cancelled) {
           return;
@@ -112,15 +117,16 @@ function VoltgoBattery({ refreshKey }: VoltgoBatteryProps) {
     return () => {
       cancelled = true;
     };
-  }, [refreshKey, fetchInfo]);
+  }, [refreshKey, query, fetchInfo]);
 
   if (state.kind === 'absent' || state.kind === 'loading') {
     return null;
   }
 
+  const title = battery === undefined ? 'Battery Bank' : `Battery ${battery}`;
   const heading = (
     <Typography variant="h6" sx={{ mb: 1, fontWeight: 600, color: '#1b5e20' }}>
-      Battery Bank
+      {title}
       {info && (
         <Typography component="span" sx={{ ml: 1, fontSize: '0.875rem', color: 'text.secondary' }}>
           {info.chemistry} · {info.nominalVoltage} V · {info.capacityAh} Ah
@@ -155,6 +161,7 @@ function VoltgoBattery({ refreshKey }: VoltgoBatteryProps) {
   return (
     <>
       {heading}
+      <StaleAlert source={battery === undefined ? 'Battery' : title} health={metrics.health} />
       <Grid container spacing={2} sx={{ mb: 2 }}>
         <Grid size={{ xs: 6, sm: 3 }}>
           <Metric title="Battery SOC" value={metrics.soc} unit="%" />
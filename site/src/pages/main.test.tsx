@@ -25,11 +25,35 @@ const METRICS: EpeverMetrics = {
   chargingCurrent: 2.9,
   chargingPower: 39.2,
   batteryVoltage: 13.4,
+  batteryCurrent: 2.9,
   batterySoc: 87,
   batteryTemp: 21.5,
   deviceTemp: 24.5,
   energyGeneratedDaily: 1.24,
   chargingStatus: 2,
+  health: {
+    availability: 'online',
+    stale: false,
+    age: 4,
+    lastSuccess: '2023-11-03T08:26:40Z',
+    lastError: '',
+    consecutiveFailures: 0,
+  },
+  quality: {
+    arrayVoltage: 'good',
+    arrayCurrent: 'good',
+    arrayPower: 'good',
+    chargingCurrent: 'good',
+    chargingPower: 'good',
+    batteryVoltage: 'good',
+    batteryCurrent: 'good',
+    batterySoc: 'good',
+    batteryTemp: 'good',
+    deviceTemp: 'good',
+    energyGeneratedDaily: 'good',
+    chargingStatus: 'good',
+  },
+  runtime: null,
 };
 
 const VOLTGO_METRICS: VoltgoMetrics = {
@@ -48,6 +72,15 @@ const VOLTGO_METRICS: VoltgoMetrics = {
     { index: 2, voltage: 3.338 },
     { index: 3, voltage: 3.326 },
   ],
+  health: {
+    availability: 'online',
+    stale: false,
+    age: 4,
+    lastSuccess: '2023-11-03T08:26:40Z',
+    lastError: '',
+    consecutiveFailures: 0,
+  },
+  runtime: null,
 };
 
 const notFound = (): Promise<never> => {
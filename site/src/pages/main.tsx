@@ -6,7 +6,8 @@ import { Grid, Box, Alert, IconButton, Typography } from '@mui/material';
 import RefreshIcon from '@mui/icons-material/Refresh';
 
 import Metric from "../components/metric"
-import VoltgoBattery from "../components/voltgo-battery"
+import StaleAlert from "../components/stale-alert"
+import VoltgoBank from "../components/voltgo-bank"
 import { CHARGING_STATUS_LABELS, type EpeverMetrics } from '../api/types';
 
 type MainState = {
@@ -72,6 +73,7 @@ class Main extends React.Component<Record<string, never>, MainState> {
               {this.state.error}
             </Alert>
           )}
+          <StaleAlert source="Solar controller" health={metrics.health} />
 
           {/* Solar Panel Metrics */}
           <Typography variant="h6" sx={{ mb: 1, mt: 1, fontWeight: 600, color: '#1b5e20' }}>
@@ -122,7 +124,7 @@ class Main extends React.Component<Record<string, never>, MainState> {
           </Grid>
 
           {/* Battery bank via the voltgo BLE controller; renders nothing when it is disabled */}
-          <VoltgoBattery refreshKey={this.state.refreshKey} />
+          <VoltgoBank refreshKey={this.state.refreshKey} />
 
           <Box sx={{ display: 'flex', justifyContent: 'center', mt: 2 }}>
             <IconButton